@@ -0,0 +1,367 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"miningRoom/db"
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hosting owners (see billing.go) can log in with a password set by the admin
+// and then see only their own machines: the machine registry is filtered by
+// machinesFor and their QuestDB queries are scoped to their miners and outlets
+// by questdbFor.
+
+var requireOwnerLogin bool
+
+const (
+	ownerSessionCookie = "owner_session"
+	ownerSessionTTL    = 7 * 24 * time.Hour
+	minOwnerPassword   = 8
+)
+
+// ownerAPIRoutes lists the API routes, relative to /api and /api/v1, that a
+// logged in owner may call. Everything else answers 404.
+var ownerAPIRoutes = map[string]bool{
+	"/versions":                  true,
+	"/status":                    true,
+	"/miners/status":             true,
+	"/charts/miner-temperatures": true,
+	"/charts/miner-hashrates":    true,
+	"/charts/device-power":       true,
+	"/charts/power-total":        true,
+	"/charts/hashrate-total":     true,
+	"/charts/daily-energy":       true,
+	"/history/:metric":           true,
+	"/economics/per-miner":       true,
+	"/account":                   true,
+	"/account/statements":        true,
+	"/login":                     true,
+	"/logout":                    true,
+	"/public/:token":             true,
+}
+
+// ownerPages lists the pages a logged in owner may open; other pages
+// redirect to /miners.
+var ownerPages = map[string]bool{
+	"/miners":        true,
+	"/login":         true,
+	"/public/:token": true,
+}
+
+// loginExemptAPIRoutes lists the API routes open without a login under
+// --require-owner-login: the login itself and routes with their own token.
+var loginExemptAPIRoutes = map[string]bool{
+	"/login":                    true,
+	"/public/:token":            true,
+	"/health":                   true,
+	"/emergency/stop":           true,
+	"/scenarios/:name/activate": true,
+	"/ingest/lineprotocol":      true,
+	"/ingest/json":              true,
+	"/ingest/thermal":           true,
+}
+
+// dummyPasswordHash is compared against for unknown owners, so a failed
+// login takes as long whether or not the owner exists.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+
+// ownerFor returns the owner logged in on the request, or "" for the admin
+// and anonymous visitors.
+func ownerFor(c *gin.Context) string {
+	return c.GetString("Owner")
+}
+
+// machinesFor returns the machines visible on the request: all of them, or
+// the logged in owner's.
+func machinesFor(c *gin.Context) []db.Machine {
+	return ownerMachines(ownerFor(c))
+}
+
+// ownerMachines returns the machines of an owner, or all of them for "".
+func ownerMachines(owner string) []db.Machine {
+	machines := currentMachines()
+	if owner == "" {
+		return machines
+	}
+	ms := []db.Machine{}
+	for _, m := range machines {
+		if m.Owner == owner {
+			ms = append(ms, m)
+		}
+	}
+	return ms
+}
+
+// ownerScope limits QuestDB reads to the miners of ms and their outlets.
+func ownerScope(ms []db.Machine) *questdb.Scope {
+	s := &questdb.Scope{MinerIPs: []string{}, DeviceIDs: []string{}}
+	for _, m := range ms {
+		s.MinerIPs = append(s.MinerIPs, m.IP)
+	}
+	for _, id := range outletDeviceIDs(ms) {
+		if id != "" {
+			s.DeviceIDs = append(s.DeviceIDs, id)
+		}
+	}
+	return s
+}
+
+// apiRoute returns the route of an API path relative to its /api or /api/v1
+// prefix.
+func apiRoute(path string) string {
+	for _, prefix := range []string{"/api/v1/", "/api/"} {
+		if strings.HasPrefix(path, prefix) {
+			return path[len(prefix)-1:]
+		}
+	}
+	return ""
+}
+
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// sessionOwner returns the owner of the request's session cookie, or "".
+func sessionOwner(c *gin.Context) string {
+	token, err := c.Cookie(ownerSessionCookie)
+	if err != nil || token == "" {
+		return ""
+	}
+	owner, err := database.FetchOwnerSession(hashSessionToken(token))
+	if err != nil {
+		log.Printf("Failed to fetch owner session: %v", err)
+		return ""
+	}
+	return owner
+}
+
+// ownerSessionMiddleware resolves the owner session. Owners get no manage
+// links and only reach ownerAPIRoutes and ownerPages; with
+// --require-owner-login, visitors outside the inner network must log in.
+func ownerSessionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.FullPath()
+		if strings.HasPrefix(path, "/static/") {
+			c.Next()
+			return
+		}
+		isAPI := strings.HasPrefix(c.Request.URL.Path, "/api/")
+		route := apiRoute(path)
+
+		if owner := sessionOwner(c); owner != "" {
+			c.Set("Owner", owner)
+			c.Set("ShowManage", false)
+			switch {
+			case isAPI && !ownerAPIRoutes[route], !isAPI && path == "":
+				render404(c)
+				return
+			case !isAPI && !ownerPages[path]:
+				c.Redirect(http.StatusFound, "/miners")
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if !requireOwnerLogin || isInnerNetwork(c.ClientIP()) ||
+			isAPI && loginExemptAPIRoutes[route] || !isAPI && (path == "/login" || path == "/public/:token") {
+			c.Next()
+			return
+		}
+		if isAPI {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "login required"})
+			return
+		}
+		c.Redirect(http.StatusFound, "/login")
+		c.Abort()
+	}
+}
+
+func loginPageHandler(c *gin.Context) {
+	c.HTML(http.StatusOK, "login.html", gin.H{
+		"Title": "Mining Dashboard",
+		"Owner": ownerFor(c),
+		"Lang":  localeFor(c).Lang,
+	})
+}
+
+type LoginRequest struct {
+	Owner    string `json:"owner" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// loginHandler checks an owner's password and starts a session.
+func loginHandler(c *gin.Context) {
+	ip := c.ClientIP()
+	if d := logins.lockedFor(ip, time.Now()); d > 0 {
+		retryAfter(c, d)
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed logins, try again later"})
+		return
+	}
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash, err := database.FetchOwnerPasswordHash(req.Owner)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Failed to fetch password of owner %s: %v", req.Owner, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+		return
+	}
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(req.Password))
+		rejectLogin(c, req.Owner)
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(req.Password)) != nil {
+		rejectLogin(c, req.Owner)
+		return
+	}
+	logins.succeed(ip)
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+	token := hex.EncodeToString(b)
+	if err := database.SaveOwnerSession(hashSessionToken(token), req.Owner, time.Now().Add(ownerSessionTTL)); err != nil {
+		log.Printf("Failed to save session of owner %s: %v", req.Owner, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(ownerSessionCookie, token, int(ownerSessionTTL.Seconds()), "/", "", c.Request.TLS != nil, true)
+	log.Printf("Owner %s logged in from %s", req.Owner, ip)
+	c.JSON(http.StatusOK, gin.H{"success": true, "owner": req.Owner})
+}
+
+// rejectLogin answers a failed login, counting it towards the client's
+// lockout.
+func rejectLogin(c *gin.Context, owner string) {
+	ip := c.ClientIP()
+	log.Printf("Rejected login of owner %q from %s", owner, ip)
+	if d := logins.fail(ip, time.Now()); d > 0 {
+		retryAfter(c, d)
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed logins, try again later"})
+		return
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid owner or password"})
+}
+
+func logoutHandler(c *gin.Context) {
+	if token, err := c.Cookie(ownerSessionCookie); err == nil && token != "" {
+		if err := database.DeleteOwnerSession(hashSessionToken(token)); err != nil {
+			log.Printf("Failed to delete owner session: %v", err)
+		}
+	}
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(ownerSessionCookie, "", -1, "/", "", c.Request.TLS != nil, true)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// getAccountHandler returns the logged in owner's billing terms and machines.
+func getAccountHandler(c *gin.Context) {
+	owner := ownerFor(c)
+	if owner == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "login required"})
+		return
+	}
+	owners, err := database.FetchHostingOwners()
+	if err != nil {
+		log.Printf("Failed to fetch hosting owners: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch account"})
+		return
+	}
+	account := db.HostingOwner{Name: owner}
+	for _, o := range owners {
+		if o.Name == owner {
+			account = o
+		}
+	}
+	if account.ElecPrice == 0 {
+		account.ElecPrice = elecPrice
+	}
+
+	type accountMachine struct {
+		Name string `json:"name"`
+		IP   string `json:"ip"`
+	}
+	ms := []accountMachine{}
+	for _, m := range machinesFor(c) {
+		ms = append(ms, accountMachine{Name: m.Name, IP: m.IP})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"owner":         account.Name,
+		"email":         account.Email,
+		"elecPrice":     account.ElecPrice,
+		"hostingFeeEur": account.HostingFeeEUR,
+		"machines":      ms,
+	})
+}
+
+// getAccountStatementsHandler serves the logged in owner's own statements,
+// with the ?month and ?format of getBillingStatementsHandler.
+func getAccountStatementsHandler(c *gin.Context) {
+	if ownerFor(c) == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "login required"})
+		return
+	}
+	getBillingStatementsHandler(c)
+}
+
+type OwnerPasswordRequest struct {
+	Password string `json:"password"` // empty disables the login
+}
+
+// setOwnerPasswordHandler sets or clears an owner's login password. Either
+// way the owner's open sessions end.
+func setOwnerPasswordHandler(c *gin.Context) {
+	name := c.Param("name")
+	var req OwnerPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Password != "" && len(req.Password) < minOwnerPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password must have at least 8 characters"})
+		return
+	}
+
+	hash := ""
+	if req.Password != "" {
+		b, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		hash = string(b)
+	}
+	if err := database.SetOwnerPasswordHash(name, hash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "owner not found"})
+			return
+		}
+		log.Printf("Failed to set password of owner %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set password"})
+		return
+	}
+	log.Printf("Set login of owner %s (enabled: %t)", name, hash != "")
+	c.JSON(http.StatusOK, gin.H{"success": true, "owner": name, "hasLogin": hash != ""})
+}
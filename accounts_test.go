@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIngestRoutesSkipOwnerLogin(t *testing.T) {
+	useTestDatabase(t)
+	defer func(required bool) { requireOwnerLogin = required }(requireOwnerLogin)
+	requireOwnerLogin = true
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ownerSessionMiddleware())
+	registerAPIRoutes(r.Group("/api"))
+
+	// Token-authenticated pushes from outside the inner network are answered
+	// by the ingest token check, not the owner login
+	for _, path := range []string{"/api/ingest/lineprotocol", "/api/ingest/json", "/api/ingest/thermal"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader("{}")))
+		var resp struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.Error == "login required" {
+			t.Errorf("POST %s asked for an owner login", path)
+		}
+	}
+}
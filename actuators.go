@@ -0,0 +1,206 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ActuatorInfo is an actuator with its live relay state.
+type ActuatorInfo struct {
+	db.Actuator
+	On        bool `json:"on"`
+	Reachable bool `json:"reachable"`
+}
+
+// findActuator looks up an actuator by name.
+func findActuator(name string) (*db.Actuator, error) {
+	actuators, err := database.FetchActuators()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range actuators {
+		if a.Name == name {
+			return &a, nil
+		}
+	}
+	return nil, nil
+}
+
+func getActuatorsHandler(c *gin.Context) {
+	actuators, err := database.FetchActuators()
+	if err != nil {
+		log.Printf("Failed to fetch actuators: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch actuators"})
+		return
+	}
+
+	results := make([]ActuatorInfo, len(actuators))
+	var wg sync.WaitGroup
+	for i, a := range actuators {
+		wg.Add(1)
+		go func(idx int, act db.Actuator) {
+			defer wg.Done()
+			results[idx] = ActuatorInfo{Actuator: act}
+			on, err := getShellyStatus(c.Request.Context(), act.ShellyIP)
+			if err != nil {
+				log.Printf("Failed to get state of actuator %s: %v", act.Name, err)
+				return
+			}
+			results[idx].On = on
+			results[idx].Reachable = true
+		}(i, a)
+	}
+	wg.Wait()
+
+	c.JSON(http.StatusOK, gin.H{"actuators": results})
+}
+
+type ActuatorRequest struct {
+	Name         string  `json:"name" binding:"required"`
+	Kind         string  `json:"kind"`
+	ShellyIP     string  `json:"shellyIp" binding:"required"`
+	Location     string  `json:"location"`
+	Metric       string  `json:"metric"`
+	OnThreshold  float64 `json:"onThreshold"`
+	OffThreshold float64 `json:"offThreshold"`
+	Auto         bool    `json:"auto"`
+}
+
+func saveActuatorHandler(c *gin.Context) {
+	var req ActuatorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Auto {
+		if req.Metric != "temperature" && req.Metric != "humidity" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "metric must be temperature or humidity for automatic control"})
+			return
+		}
+		if req.Location == "" || req.OnThreshold == req.OffThreshold {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "automatic control needs a location and distinct on/off thresholds"})
+			return
+		}
+	}
+
+	actuator := db.Actuator{
+		Name:         req.Name,
+		Kind:         req.Kind,
+		ShellyIP:     req.ShellyIP,
+		Location:     req.Location,
+		Metric:       req.Metric,
+		OnThreshold:  req.OnThreshold,
+		OffThreshold: req.OffThreshold,
+		Auto:         req.Auto,
+	}
+	if err := database.SaveActuator(actuator); err != nil {
+		log.Printf("Failed to save actuator %s: %v", req.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save actuator"})
+		return
+	}
+
+	log.Printf("Saved actuator %s (%s, shelly %s)", req.Name, req.Kind, req.ShellyIP)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"name":    req.Name,
+	})
+}
+
+func deleteActuatorHandler(c *gin.Context) {
+	name := c.Param("name")
+	if err := database.DeleteActuator(name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete actuator"})
+		return
+	}
+
+	log.Printf("Deleted actuator %s", name)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"name":    name,
+	})
+}
+
+type ActuatorStateRequest struct {
+	On bool `json:"on"`
+}
+
+// setActuatorStateHandler switches an actuator manually. Automatic control is
+// disabled so the thermal controller does not immediately revert the change.
+func setActuatorStateHandler(c *gin.Context) {
+	var req ActuatorStateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := c.Param("name")
+	actuator, err := findActuator(name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch actuators"})
+		return
+	}
+	if actuator == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown actuator " + name})
+		return
+	}
+
+	if actuator.Auto {
+		if err := database.SetActuatorAuto(name, false); err != nil {
+			log.Printf("Failed to disable auto mode for actuator %s: %v", name, err)
+		}
+	}
+
+	if err := controlShelly(c.Request.Context(), actuator.ShellyIP, req.On); err != nil {
+		log.Printf("Failed to switch actuator %s via shelly %s: %v", name, actuator.ShellyIP, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	thermal.forget(name)
+
+	log.Printf("Switched actuator %s %s (manual)", name, onOff(req.On))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"name":    name,
+		"on":      req.On,
+	})
+}
+
+type ActuatorAutoRequest struct {
+	Auto bool `json:"auto"`
+}
+
+func setActuatorAutoHandler(c *gin.Context) {
+	var req ActuatorAutoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := c.Param("name")
+	if err := database.SetActuatorAuto(name, req.Auto); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update actuator"})
+		return
+	}
+	thermal.forget(name)
+
+	log.Printf("Set actuator %s auto=%v", name, req.Auto)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"name":    name,
+		"auto":    req.Auto,
+	})
+}
+
+// onOff formats a relay state for log messages.
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Machines and Shellies may be addressed by IPv4 or IPv6 address or by
+// hostname. Addresses are stored normalized, so the :ip route parameter and
+// the stored value compare equal however an address was typed.
+
+// hostnameLabel matches one RFC 1123 hostname label.
+var hostnameLabel = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// normalizeAddress validates a machine address and returns its canonical
+// form: IPs in their shortest form (IPv4-mapped IPv6 as IPv4), hostnames in
+// lower case. IPv6 addresses may be bracketed and carry a zone.
+func normalizeAddress(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		s = s[1 : len(s)-1]
+	}
+	if s == "" {
+		return "", fmt.Errorf("address required")
+	}
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap().String(), nil
+	}
+	if strings.Contains(s, ":") {
+		return "", fmt.Errorf("invalid IPv6 address %q", s)
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(s, "."))
+	if len(host) > 253 {
+		return "", fmt.Errorf("hostname %q too long", s)
+	}
+	labels := strings.Split(host, ".")
+	for _, label := range labels {
+		if !hostnameLabel.MatchString(label) {
+			return "", fmt.Errorf("invalid address %q", s)
+		}
+	}
+	// A dotted all-numeric name is a mistyped IPv4 address, not a hostname
+	if _, err := netip.ParseAddr(host); err != nil && strings.Trim(host, "0123456789.") == "" {
+		return "", fmt.Errorf("invalid IPv4 address %q", s)
+	}
+	return host, nil
+}
+
+// isHostname reports whether a normalized machine address is a hostname
+// rather than an IP.
+func isHostname(addr string) bool {
+	_, err := netip.ParseAddr(addr)
+	return addr != "" && err != nil
+}
+
+// urlHost returns a machine address as the host of a URL, bracketing IPv6
+// addresses. A host:port, such as a testsupport emulator's Addr(), is kept.
+func urlHost(addr string) string {
+	if !strings.Contains(addr, ":") {
+		return addr
+	}
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return "[" + strings.Replace(addr, "%", "%25", 1) + "]"
+}
+
+// ipParam returns the :ip route parameter normalized like stored addresses.
+// An invalid address is returned as is, so lookups simply don't find it.
+func ipParam(c *gin.Context) string {
+	ip := c.Param("ip")
+	if addr, err := normalizeAddress(ip); err == nil {
+		return addr
+	}
+	return ip
+}
+
+// parseNetworks parses a comma separated list of IPv4 and IPv6 CIDR
+// prefixes; a bare IP stands for that single host.
+func parseNetworks(s string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, p := range splitList(s) {
+		if ip := net.ParseIP(p); ip != nil {
+			bits := 128
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix %q", p)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
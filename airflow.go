@@ -0,0 +1,197 @@
+package main
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Airflow tracking compares the sensor locations marked as air intake and
+// exhaust. The temperature rise ΔT across the room, with the exhaust fan's
+// rated airflow (--exhaust-airflow), gives the heat the air carries out:
+// Q = ρ · cp · V · ΔT. Without a rating the airflow that would carry out all of
+// the miners' power at the measured ΔT is estimated instead.
+
+// Airflow location roles of db.SensorLocation.
+const (
+	airflowIntake  = "intake"
+	airflowExhaust = "exhaust"
+)
+
+var exhaustAirflow float64 // --exhaust-airflow: m³/h, 0 when unknown
+
+const (
+	airSpecificHeat    = 1005.0  // J/(kg·K), dry air
+	airGasConstant     = 287.05  // J/(kg·K), dry air
+	standardPressure   = 1013.25 // hPa, when the intake sensor has none
+	airflowMinDeltaT   = 0.5     // K below which ΔT is too small to derive airflow from
+	airflowMinPower    = 100.0   // W below which the miners' heat is too small to compare
+	airflowMaxWindow   = 31 * 24 * time.Hour
+	airflowHourlyAfter = 2 * 24 * time.Hour // longer windows are sampled hourly
+)
+
+// AirflowPoint is one sample of the airflow metrics.
+type AirflowPoint struct {
+	Timestamp    string   `json:"timestamp"`
+	Intake       float64  `json:"intake"`                 // °C
+	Exhaust      float64  `json:"exhaust"`                // °C
+	DeltaT       float64  `json:"deltaT"`                 // K
+	Power        *float64 `json:"power"`                  // W, nil without power readings
+	HeatRemoved  *float64 `json:"heatRemoved,omitempty"`  // W carried out at --exhaust-airflow
+	RemovalRatio *float64 `json:"removalRatio,omitempty"` // HeatRemoved / Power
+	Airflow      *float64 `json:"airflow,omitempty"`      // m³/h that would carry out Power at DeltaT
+}
+
+// AirflowSummary averages the points of a window.
+type AirflowSummary struct {
+	DeltaT       float64  `json:"deltaT"`
+	Power        *float64 `json:"power"`
+	HeatRemoved  *float64 `json:"heatRemoved,omitempty"`
+	RemovalRatio *float64 `json:"removalRatio,omitempty"`
+	Airflow      *float64 `json:"airflow,omitempty"`
+	Samples      int      `json:"samples"`
+}
+
+// airDensity is the density of dry air in kg/m³ at tempC and pressureHPa.
+func airDensity(tempC, pressureHPa float64) float64 {
+	return pressureHPa * 100 / (airGasConstant * (tempC + 273.15))
+}
+
+func roundTo(v float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Round(v*p) / p
+}
+
+// airflowPoint derives the metrics of a sample.
+func airflowPoint(s questdb.AirflowSample) AirflowPoint {
+	p := AirflowPoint{
+		Timestamp: s.Timestamp,
+		Intake:    roundTo(s.Intake, 1),
+		Exhaust:   roundTo(s.Exhaust, 1),
+		DeltaT:    roundTo(s.Exhaust-s.Intake, 2),
+	}
+	pressure := standardPressure
+	if s.Pressure != nil && *s.Pressure > 0 {
+		pressure = *s.Pressure
+	}
+	// Heat capacity of one m³ of intake air per K, in J/(m³·K)
+	volumetricHeat := airDensity(s.Intake, pressure) * airSpecificHeat
+	deltaT := s.Exhaust - s.Intake
+
+	if s.HasPower {
+		power := math.Round(s.Power)
+		p.Power = &power
+	}
+	if exhaustAirflow > 0 {
+		removed := math.Round(volumetricHeat * exhaustAirflow / 3600 * deltaT)
+		p.HeatRemoved = &removed
+		if s.HasPower && s.Power >= airflowMinPower {
+			ratio := roundTo(removed/s.Power, 2)
+			p.RemovalRatio = &ratio
+		}
+	}
+	if s.HasPower && s.Power >= airflowMinPower && deltaT >= airflowMinDeltaT {
+		airflow := math.Round(s.Power / (volumetricHeat * deltaT) * 3600)
+		p.Airflow = &airflow
+	}
+	return p
+}
+
+// averageOf averages the non-nil values; nil when there are none.
+func averageOf(values []*float64, decimals int) *float64 {
+	sum, n := 0.0, 0
+	for _, v := range values {
+		if v != nil {
+			sum += *v
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	avg := roundTo(sum/float64(n), decimals)
+	return &avg
+}
+
+func summarizeAirflow(points []AirflowPoint) AirflowSummary {
+	s := AirflowSummary{Samples: len(points)}
+	if len(points) == 0 {
+		return s
+	}
+	var deltaT float64
+	var power, removed, ratio, airflow []*float64
+	for _, p := range points {
+		deltaT += p.DeltaT
+		power = append(power, p.Power)
+		removed = append(removed, p.HeatRemoved)
+		ratio = append(ratio, p.RemovalRatio)
+		airflow = append(airflow, p.Airflow)
+	}
+	s.DeltaT = roundTo(deltaT/float64(len(points)), 2)
+	s.Power = averageOf(power, 0)
+	s.HeatRemoved = averageOf(removed, 0)
+	s.RemovalRatio = averageOf(ratio, 2)
+	s.Airflow = averageOf(airflow, 0)
+	return s
+}
+
+// getAirflowHandler returns the intake/exhaust ΔT, the heat removed by the
+// exhaust air and the derived airflow over ?range= or ?from=&to= (default the
+// last 24 hours), as a chart series with the latest sample and the window's
+// averages. Comparing the averages of a window before and after a fan change
+// shows its effect.
+func getAirflowHandler(c *gin.Context) {
+	from, to, err := parseTimeWindow(c, requestNow(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if to.Sub(from) > airflowMaxWindow {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "window must not exceed 31 days"})
+		return
+	}
+	bucket := "10m"
+	if to.Sub(from) > airflowHourlyAfter {
+		bucket = "1h"
+	}
+
+	intake, exhaust := airflowLocations(airflowIntake), airflowLocations(airflowExhaust)
+	response := gin.H{
+		"intake":         intake,
+		"exhaust":        exhaust,
+		"exhaustAirflow": exhaustAirflow,
+		"bucket":         bucket,
+		"points":         []AirflowPoint{},
+		"current":        nil,
+		"summary":        summarizeAirflow(nil),
+		"hasData":        false,
+	}
+	if len(intake) == 0 || len(exhaust) == 0 {
+		response["error"] = "mark sensor locations as airflow intake and exhaust"
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	samples, err := questdbFor(c).GetAirflowSeries(intake, exhaust, from, to, bucket)
+	if err != nil {
+		log.Printf("Failed to get airflow series from QuestDB: %v", err)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	points := make([]AirflowPoint, 0, len(samples))
+	for _, s := range samples {
+		points = append(points, airflowPoint(s))
+	}
+	response["points"] = points
+	response["summary"] = summarizeAirflow(points)
+	if len(points) > 0 {
+		response["current"] = points[len(points)-1]
+		response["hasData"] = true
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Alert severities
+const (
+	severityWarning  = "warning"
+	severityCritical = "critical"
+)
+
+// Alert is an active condition raised by one of the background monitors.
+type Alert struct {
+	Key      string    `json:"key"` // unique per condition, e.g. "coolant-flow:tank1/flow0"
+	Severity string    `json:"severity"`
+	Source   string    `json:"source"`
+	Message  string    `json:"message"`
+	Since    time.Time `json:"since"`
+}
+
+// alertStore keeps the currently active alerts in memory.
+type alertStore struct {
+	mu     sync.Mutex
+	active map[string]*Alert
+}
+
+var alerts = &alertStore{active: make(map[string]*Alert)}
+
+// raise activates an alert, or updates its message if it is already active.
+// New and escalated alerts are sent to the notification channels.
+func (s *alertStore) raise(key, severity, source, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a, ok := s.active[key]; ok {
+		escalated := severityRank[severity] > severityRank[a.Severity]
+		a.Severity = severity
+		a.Message = message
+		if escalated {
+			go dispatchNotification(alertNotification(*a, false))
+		}
+		return
+	}
+	a := &Alert{
+		Key:      key,
+		Severity: severity,
+		Source:   source,
+		Message:  message,
+		Since:    time.Now(),
+	}
+	s.active[key] = a
+	recordEvent(source, "%s alert: %s", severity, message)
+	go dispatchNotification(alertNotification(*a, false))
+}
+
+// clear deactivates an alert if it is active.
+func (s *alertStore) clear(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a, ok := s.active[key]; ok {
+		delete(s.active, key)
+		recordEvent(a.Source, "alert cleared: %s", a.Message)
+		go dispatchNotification(alertNotification(*a, true))
+	}
+}
+
+// list returns the active alerts, critical first and then oldest first.
+func (s *alertStore) list() []Alert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]Alert, 0, len(s.active))
+	for _, a := range s.active {
+		result = append(result, *a)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Severity != result[j].Severity {
+			return result[i].Severity == severityCritical
+		}
+		return result[i].Since.Before(result[j].Since)
+	})
+	return result
+}
+
+func getAlertsHandler(c *gin.Context) {
+	active := alerts.list()
+	c.JSON(http.StatusOK, gin.H{
+		"alerts":  active,
+		"hasData": len(active) > 0,
+	})
+}
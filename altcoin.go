@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Altcoin mining profile: GPU rigs mining a coin other than BTC, with its own
+// network, pool and price data.
+var (
+	altCoin         string // --alt-coin: ticker, empty disables the module
+	altAlgorithm    string // --alt-algorithm: gpu_status algorithm mined for altCoin, empty counts every rig
+	altHashrateUnit string // --alt-hashrate-unit
+	altCoinAPI      string // --alt-coin-api: WhatToMine coin JSON
+	altPoolAPI      string // --alt-pool-api: open-ethereum-pool account JSON
+	altPollSeconds  int    // --alt-poll
+)
+
+// hashrateUnits are the display units accepted by --alt-hashrate-unit, in H/s.
+var hashrateUnits = map[string]float64{
+	"H/s":  1,
+	"kH/s": 1e3,
+	"MH/s": 1e6,
+	"GH/s": 1e9,
+	"TH/s": 1e12,
+}
+
+// altName restricts the coin and algorithm, which end up in QuestDB queries.
+var altName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// validateAltCoin checks the --alt-* flags when the module is enabled.
+func validateAltCoin() error {
+	if !altName.MatchString(altCoin) {
+		return fmt.Errorf("invalid coin %q", altCoin)
+	}
+	if altAlgorithm != "" && !altName.MatchString(altAlgorithm) {
+		return fmt.Errorf("invalid algorithm %q", altAlgorithm)
+	}
+	if _, ok := hashrateUnits[altHashrateUnit]; !ok {
+		return fmt.Errorf("invalid hashrate unit %q (use H/s, kH/s, MH/s, GH/s or TH/s)", altHashrateUnit)
+	}
+	return nil
+}
+
+// altEfficiencyUnit is the efficiency unit matching the hashrate unit, e.g.
+// J/MH for MH/s.
+func altEfficiencyUnit() string {
+	return "J/" + strings.TrimSuffix(altHashrateUnit, "/s")
+}
+
+// getAltJSON decodes the JSON answer of a coin or pool API.
+func getAltJSON(ctx context.Context, url string, out interface{}) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := httpGet(ctx, client, url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// fetchAltNetwork reads the network hashrate, block time, block reward and
+// BTC exchange rate from a WhatToMine coin endpoint, e.g.
+// https://whattomine.com/coins/234.json. WhatToMine quotes some numbers as
+// strings, which json.Number accepts.
+func fetchAltNetwork(ctx context.Context) (*questdb.AltNetwork, error) {
+	var coin struct {
+		Tag              string      `json:"tag"`
+		NetHash          json.Number `json:"nethash"`
+		BlockTime        json.Number `json:"block_time"`
+		BlockReward      json.Number `json:"block_reward"`
+		ExchangeRate     json.Number `json:"exchange_rate"`
+		ExchangeRateCurr string      `json:"exchange_rate_curr"`
+	}
+	if err := getAltJSON(ctx, altCoinAPI, &coin); err != nil {
+		return nil, err
+	}
+	if coin.Tag != "" && !strings.EqualFold(coin.Tag, altCoin) {
+		return nil, fmt.Errorf("coin API returned %s, not %s", coin.Tag, altCoin)
+	}
+	if coin.ExchangeRateCurr != "" && coin.ExchangeRateCurr != "BTC" {
+		return nil, fmt.Errorf("exchange rate in %s, expected BTC", coin.ExchangeRateCurr)
+	}
+
+	n := &questdb.AltNetwork{}
+	n.NetHashrate, _ = coin.NetHash.Float64()
+	n.BlockTime, _ = coin.BlockTime.Float64()
+	n.BlockReward, _ = coin.BlockReward.Float64()
+	rateBTC, _ := coin.ExchangeRate.Float64()
+	if n.NetHashrate <= 0 || n.BlockTime <= 0 {
+		return nil, fmt.Errorf("invalid network hashrate %v or block time %v", n.NetHashrate, n.BlockTime)
+	}
+
+	// Priced in EUR through BTC, like the BTC revenue estimate
+	btcEUR, err := fetchBTCPriceEUR()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch BTC price: %w", err)
+	}
+	n.PriceEUR = rateBTC * btcEUR
+	return n, nil
+}
+
+// fetchAltPool reads the wallet's hashrate from an open-ethereum-pool style
+// account endpoint (2Miners, HeroMiners and others), e.g.
+// https://rvn.2miners.com/api/accounts/<wallet>.
+func fetchAltPool(ctx context.Context) (*questdb.AltPool, error) {
+	var account struct {
+		CurrentHashrate float64 `json:"currentHashrate"`
+		Hashrate        float64 `json:"hashrate"`
+		WorkersOnline   int     `json:"workersOnline"`
+	}
+	if err := getAltJSON(ctx, altPoolAPI, &account); err != nil {
+		return nil, err
+	}
+	return &questdb.AltPool{
+		HashrateCurrent: account.CurrentHashrate,
+		HashrateAverage: account.Hashrate,
+		Workers:         account.WorkersOnline,
+	}, nil
+}
+
+// runAltPoller writes the coin's network state and pool stats at the given
+// interval, starting right away so the page has data after a restart.
+func runAltPoller(interval time.Duration) {
+	pollAlt()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		pollAlt()
+	}
+}
+
+func pollAlt() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var points []questdb.Point
+	if altCoinAPI != "" {
+		if n, err := fetchAltNetwork(ctx); err != nil {
+			log.Printf("Altcoin poller: %s network: %v", altCoin, err)
+		} else {
+			points = append(points, questdb.Point{
+				Table:   "alt_network",
+				Symbols: map[string]string{"coin": altCoin},
+				Fields: map[string]interface{}{
+					"nethash":      n.NetHashrate,
+					"block_time":   n.BlockTime,
+					"block_reward": n.BlockReward,
+					"price_eur":    n.PriceEUR,
+				},
+			})
+		}
+	}
+	if altPoolAPI != "" {
+		if p, err := fetchAltPool(ctx); err != nil {
+			log.Printf("Altcoin poller: %s pool: %v", altCoin, err)
+		} else {
+			points = append(points, questdb.Point{
+				Table:   "alt_pool",
+				Symbols: map[string]string{"coin": altCoin},
+				Fields: map[string]interface{}{
+					"hashrate_current": p.HashrateCurrent,
+					"hashrate_average": p.HashrateAverage,
+					"workers":          int64(p.Workers),
+				},
+			})
+		}
+	}
+
+	if len(points) == 0 {
+		return
+	}
+	if err := tsStore.Write(points); err != nil {
+		log.Printf("Altcoin poller: failed to write %s stats: %v", altCoin, err)
+	}
+}
+
+// altDailyRevenueEUR estimates daily revenue of hashrate (H/s) the way the
+// BTC path does: our share of the network times the coins minted per day.
+func altDailyRevenueEUR(hashrate float64, n *questdb.AltNetwork) float64 {
+	if hashrate <= 0 || n == nil || n.NetHashrate <= 0 || n.BlockTime <= 0 {
+		return 0
+	}
+	dailyCoins := hashrate / n.NetHashrate * 86400 / n.BlockTime * n.BlockReward
+	return math.Round(dailyCoins*n.PriceEUR*100) / 100
+}
+
+// altOverview builds the status and gauges of the altcoin section of the
+// power page from the rigs mining --alt-algorithm.
+func altOverview(c *gin.Context, loc Locale) gin.H {
+	qdb := questdbFor(c)
+	hashrate, power := 0.0, 0.0
+	latest := ""
+	gpus, err := qdb.GetLatestGPUs()
+	if err != nil {
+		log.Printf("Failed to get GPUs from QuestDB: %v", err)
+	} else {
+		for _, g := range gpus.GPUs {
+			if altAlgorithm != "" && !strings.EqualFold(g.Algorithm, altAlgorithm) {
+				continue
+			}
+			hashrate += g.Hashrate
+			power += g.Power
+			if g.Timestamp > latest {
+				latest = g.Timestamp
+			}
+		}
+	}
+
+	statusLabel := "No Data"
+	online := false
+	if latest != "" {
+		online = isTimestampRecentAt(latest, 5*time.Minute, requestNow(c))
+		if online {
+			statusLabel = "Mining"
+		} else {
+			statusLabel = "Stale Data"
+		}
+	}
+
+	poolHashrate := 0.0
+	if pool, err := qdb.GetLatestAltPool(altCoin); err != nil {
+		log.Printf("Failed to get %s pool stats from QuestDB: %v", altCoin, err)
+	} else if pool != nil {
+		poolHashrate = pool.HashrateAverage
+	}
+	network, err := qdb.GetLatestAltNetwork(altCoin)
+	if err != nil {
+		log.Printf("Failed to get %s network from QuestDB: %v", altCoin, err)
+	}
+
+	scale := hashrateUnits[altHashrateUnit]
+	efficiency := 0.0
+	if hashrate > 0 {
+		efficiency = power / (hashrate / scale)
+	}
+	revenue := altDailyRevenueEUR(hashrate, network)
+	elecCost := math.Round(power/1000*24*elecPrice*100) / 100
+
+	return gin.H{
+		"Coin": altCoin,
+		"Status": gin.H{
+			"Online": online,
+			"Label":  loc.T(statusLabel),
+		},
+		"Gauges": localizeGauges(loc, []gin.H{
+			{"Label": "GPU Power", "Value": math.Round(power), "Unit": "W"},
+			{"Label": "Hashrate", "Value": hashrate / scale, "Unit": altHashrateUnit, "Decimals": 2},
+			{"Label": "Pool Hashrate", "Value": poolHashrate / scale, "Unit": altHashrateUnit, "Decimals": 2},
+			{"Label": "Efficiency", "Value": efficiency, "Unit": altEfficiencyUnit(), "Decimals": 1},
+			{"Label": "Elec. Cost", "Value": elecCost, "Unit": "€/day", "Money": true},
+			{"Label": "Revenue", "Value": revenue, "Unit": "€/day", "Money": true},
+		}),
+	}
+}
+
+// getAltHashrateChartHandler returns the hashrate per rig IP and the pool's
+// hashrate in --alt-hashrate-unit over the last 24 hours.
+func getAltHashrateChartHandler(c *gin.Context) {
+	if altCoin == "" {
+		c.JSON(http.StatusOK, gin.H{"rigs": gin.H{}, "pool": []interface{}{}, "hasData": false})
+		return
+	}
+	result, err := questdbFor(c).GetAltHashrateSeries(altCoin, altAlgorithm)
+	if err != nil {
+		log.Printf("Failed to get %s hashrate from QuestDB: %v", altCoin, err)
+		c.JSON(http.StatusOK, gin.H{"rigs": gin.H{}, "pool": []interface{}{}, "hasData": false})
+		return
+	}
+
+	scale := hashrateUnits[altHashrateUnit]
+	for _, points := range result.Rigs {
+		for i := range points {
+			points[i].Value /= scale
+		}
+	}
+	for i := range result.Pool {
+		result.Pool[i].Value /= scale
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"coin":    altCoin,
+		"unit":    altHashrateUnit,
+		"rigs":    result.Rigs,
+		"pool":    result.Pool,
+		"hasData": result.HasData,
+	})
+}
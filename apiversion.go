@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API versioning. Every route is served under /api/v1 and, for clients
+// written before versioning, under the unversioned /api prefix, which stays an
+// alias of v1. Responses carry the API-Version header; clients may send
+// Accept-Version to make sure they get the version they were written against.
+//
+// To change the shape of an endpoint, keep the old handler on v1, register the
+// new one in a v2 group, add 2 to apiVersions and add the v1 route to
+// apiDeprecations with a sunset date. Deprecated routes answer with
+// Deprecation, Sunset and successor Link headers (RFC 9745, RFC 8594) and are
+// listed with their recent callers by /api/v1/versions.
+const (
+	currentAPIVersion = 1
+	legacyAPIVersion  = 1 // version served by the unversioned paths
+)
+
+// apiVersions lists the served API versions.
+var apiVersions = []int{1}
+
+// legacyAPISunset is the announced removal date (YYYY-MM-DD) of the
+// unversioned /api paths; empty while they are not deprecated.
+var legacyAPISunset string
+
+// apiDeprecation announces that a route will be removed or change shape.
+type apiDeprecation struct {
+	Version   int        `json:"version"`
+	Route     string     `json:"route"` // method and path without the version prefix, e.g. "GET /miners/status"
+	Since     time.Time  `json:"since"`
+	Sunset    *time.Time `json:"sunset,omitempty"`
+	Successor string     `json:"successor,omitempty"` // path of the replacement
+	Note      string     `json:"note,omitempty"`
+}
+
+// apiDeprecations lists the deprecated routes of each version.
+var apiDeprecations = []apiDeprecation{}
+
+// deprecatedUse counts calls of deprecated routes so callers can be found
+// before the routes are removed.
+type deprecatedUse struct {
+	Route    string    `json:"route"`
+	Client   string    `json:"client"`
+	Calls    int       `json:"calls"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+var deprecatedUsage = struct {
+	mu   sync.Mutex
+	uses map[string]*deprecatedUse // by route and client
+}{uses: make(map[string]*deprecatedUse)}
+
+// recordDeprecatedUse counts a call and logs the first one per client.
+func recordDeprecatedUse(route, client string) {
+	deprecatedUsage.mu.Lock()
+	defer deprecatedUsage.mu.Unlock()
+	key := route + " " + client
+	u, ok := deprecatedUsage.uses[key]
+	if !ok {
+		u = &deprecatedUse{Route: route, Client: client}
+		deprecatedUsage.uses[key] = u
+		log.Printf("Deprecated API %s called by %s", route, client)
+	}
+	u.Calls++
+	u.LastSeen = time.Now()
+}
+
+// parseAPIVersion parses an Accept-Version value such as "1" or "v1".
+func parseAPIVersion(s string) (int, bool) {
+	v, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "v"))
+	return v, err == nil
+}
+
+// apiVersionMiddleware tags responses of the routes of an API version and
+// announces deprecations. version 0 is the unversioned /api alias.
+func apiVersionMiddleware(version int) gin.HandlerFunc {
+	legacy := version == 0
+	prefix := fmt.Sprintf("/api/v%d", version)
+	if legacy {
+		version = legacyAPIVersion
+		prefix = "/api"
+	}
+	return func(c *gin.Context) {
+		if s := c.GetHeader("Accept-Version"); s != "" {
+			if v, ok := parseAPIVersion(s); !ok || v != version {
+				c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{
+					"error":     fmt.Sprintf("API version %q is not served at %s", s, prefix),
+					"supported": apiVersions,
+				})
+				return
+			}
+		}
+		c.Header("API-Version", strconv.Itoa(version))
+
+		path := strings.TrimPrefix(c.FullPath(), prefix)
+		if legacy && legacyAPISunset != "" {
+			if sunset, err := time.Parse("2006-01-02", legacyAPISunset); err == nil {
+				c.Header("Deprecation", "true")
+				c.Header("Sunset", sunset.UTC().Format(http.TimeFormat))
+				c.Header("Link", fmt.Sprintf("</api/v%d%s>; rel=\"successor-version\"", currentAPIVersion, strings.TrimPrefix(c.Request.URL.Path, "/api")))
+				recordDeprecatedUse("unversioned "+c.Request.Method+" /api"+path, c.ClientIP())
+			}
+		}
+
+		route := c.Request.Method + " " + path
+		for _, d := range apiDeprecations {
+			if d.Version != version || d.Route != route {
+				continue
+			}
+			c.Header("Deprecation", fmt.Sprintf("@%d", d.Since.Unix()))
+			if d.Sunset != nil {
+				c.Header("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if d.Successor != "" {
+				c.Header("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", d.Successor))
+			}
+			recordDeprecatedUse(fmt.Sprintf("v%d %s", version, route), c.ClientIP())
+			break
+		}
+		c.Next()
+	}
+}
+
+// getAPIVersionsHandler lists the served API versions, the deprecated routes
+// and who still calls them.
+func getAPIVersionsHandler(c *gin.Context) {
+	deprecatedUsage.mu.Lock()
+	uses := make([]deprecatedUse, 0, len(deprecatedUsage.uses))
+	for _, u := range deprecatedUsage.uses {
+		uses = append(uses, *u)
+	}
+	deprecatedUsage.mu.Unlock()
+	sort.Slice(uses, func(i, j int) bool { return uses[i].LastSeen.After(uses[j].LastSeen) })
+
+	response := gin.H{
+		"current":        currentAPIVersion,
+		"supported":      apiVersions,
+		"deprecations":   apiDeprecations,
+		"deprecatedUses": uses,
+	}
+	if legacyAPISunset != "" {
+		response["legacySunset"] = legacyAPISunset
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MAC resolver settings. The server must share a layer 2 network with the
+// miners for their MACs to appear in its ARP table.
+var (
+	macResolveMinutes int
+	arpSubnets        string
+)
+
+const (
+	arpTablePath    = "/proc/net/arp"
+	arpProbeTimeout = 500 * time.Millisecond
+	arpProbeWorkers = 64
+	arpMaxHosts     = 1024 // largest subnet swept, a /22
+)
+
+// readARPTable returns the complete entries of the kernel ARP table as MAC by IP.
+func readARPTable() (map[string]string, error) {
+	f, err := os.Open(arpTablePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	table := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		// IP address, HW type, Flags, HW address, Mask, Device
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[2] == "0x0" || fields[3] == "00:00:00:00:00:00" {
+			continue
+		}
+		table[fields[0]] = strings.ToLower(fields[3])
+	}
+	return table, scanner.Err()
+}
+
+// probe opens a short TCP connection so the kernel resolves the IP's MAC.
+// Whether the port answers doesn't matter.
+func probe(ip string) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, "80"), arpProbeTimeout)
+	if err == nil {
+		conn.Close()
+	}
+}
+
+// lookupMAC returns the MAC of an IP or device hostname, probing it first.
+// Only IPv4 addresses have ARP entries; IPv6 neighbors can't be resolved.
+func lookupMAC(ip string) (string, error) {
+	if isHostname(ip) {
+		addr, err := deviceHosts.lookup(context.Background(), ip)
+		if err != nil {
+			return "", err
+		}
+		ip = addr
+	}
+	if parsed := net.ParseIP(ip); parsed == nil || parsed.To4() == nil {
+		return "", fmt.Errorf("no ARP entry for %s: not an IPv4 address", ip)
+	}
+	probe(ip)
+	table, err := readARPTable()
+	if err != nil {
+		return "", err
+	}
+	mac, ok := table[ip]
+	if !ok {
+		return "", fmt.Errorf("no ARP entry for %s", ip)
+	}
+	return mac, nil
+}
+
+// normalizeMAC parses a MAC in any notation accepted by net.ParseMAC.
+func normalizeMAC(s string) (string, error) {
+	hw, err := net.ParseMAC(s)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(hw.String()), nil
+}
+
+// sweepSubnets probes every host of the given subnets to fill the ARP table.
+func sweepSubnets(subnets []string) {
+	ips := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < arpProbeWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ip := range ips {
+				probe(ip)
+			}
+		}()
+	}
+	for _, subnet := range subnets {
+		hosts, err := subnetHosts(subnet)
+		if err != nil {
+			log.Printf("MAC resolver: skipping subnet %s: %v", subnet, err)
+			continue
+		}
+		for _, ip := range hosts {
+			ips <- ip
+		}
+	}
+	close(ips)
+	wg.Wait()
+}
+
+// subnetHosts lists the host addresses of an IPv4 CIDR subnet.
+func subnetHosts(cidr string) ([]string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	base := network.IP.To4()
+	if base == nil {
+		return nil, fmt.Errorf("not an IPv4 subnet")
+	}
+	ones, bits := network.Mask.Size()
+	size := 1 << (bits - ones)
+	if size > arpMaxHosts {
+		return nil, fmt.Errorf("larger than %d addresses", arpMaxHosts)
+	}
+	start := uint32(base[0])<<24 | uint32(base[1])<<16 | uint32(base[2])<<8 | uint32(base[3])
+	var hosts []string
+	for i := 1; i < size-1; i++ {
+		n := start + uint32(i)
+		hosts = append(hosts, net.IPv4(byte(n>>24), byte(n>>16), byte(n>>8), byte(n)).String())
+	}
+	return hosts, nil
+}
+
+// resolverSubnets returns --arp-subnets, or the /24 around each miner's IP.
+func resolverSubnets() []string {
+	if arpSubnets != "" {
+		return splitList(arpSubnets)
+	}
+	seen := make(map[string]bool)
+	var subnets []string
+	for _, m := range currentMachines() {
+		ip := net.ParseIP(m.IP).To4()
+		if ip == nil {
+			continue
+		}
+		subnet := fmt.Sprintf("%d.%d.%d.0/24", ip[0], ip[1], ip[2])
+		if !seen[subnet] {
+			seen[subnet] = true
+			subnets = append(subnets, subnet)
+		}
+	}
+	return subnets
+}
+
+// runMACResolver captures missing MACs and follows known MACs to new IPs.
+func runMACResolver(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		resolveMachineIPs()
+	}
+}
+
+func resolveMachineIPs() {
+	table, err := readARPTable()
+	if err != nil {
+		log.Printf("MAC resolver: %v", err)
+		return
+	}
+
+	captured, lost := false, false
+	for _, m := range currentMachines() {
+		switch {
+		case isHostname(m.IP):
+			// Hostnames follow the miner through DNS (resolver.go)
+		case m.MAC == "":
+			if mac, ok := table[m.IP]; ok {
+				if err := database.SetMachineMAC(m.IP, mac); err != nil {
+					log.Printf("MAC resolver: failed to store MAC of %s: %v", m.IP, err)
+					continue
+				}
+				log.Printf("MAC resolver: %s at %s has MAC %s", m.Name, m.IP, mac)
+				captured = true
+			}
+		case table[m.IP] != m.MAC:
+			lost = true
+		}
+	}
+	if !lost {
+		if captured {
+			refreshMachines()
+		}
+		return
+	}
+
+	// A known MAC isn't at its IP: probe the subnets and look again
+	sweepSubnets(resolverSubnets())
+	if table, err = readARPTable(); err != nil {
+		log.Printf("MAC resolver: %v", err)
+		return
+	}
+	ipsByMAC := make(map[string][]string)
+	for ip, mac := range table {
+		ipsByMAC[mac] = append(ipsByMAC[mac], ip)
+	}
+
+	for _, m := range currentMachines() {
+		if m.MAC == "" || isHostname(m.IP) || table[m.IP] == m.MAC {
+			continue
+		}
+		ips := ipsByMAC[m.MAC]
+		if len(ips) != 1 {
+			// Not on the network (powered off) or ambiguous
+			continue
+		}
+		if err := database.MoveMachineIP(m.IP, ips[0]); err != nil {
+			log.Printf("MAC resolver: failed to move %s from %s to %s: %v", m.Name, m.IP, ips[0], err)
+			continue
+		}
+		recordEvent("network", "%s (MAC %s) moved from %s to %s", m.Name, m.MAC, m.IP, ips[0])
+	}
+	refreshMachines()
+}
+
+// captureMAC stores the MAC of a newly added machine, if it is reachable.
+func captureMAC(ip string) {
+	mac, err := lookupMAC(ip)
+	if err != nil {
+		log.Printf("MAC resolver: %v; will retry in the background", err)
+		return
+	}
+	if err := database.SetMachineMAC(ip, mac); err != nil {
+		log.Printf("MAC resolver: failed to store MAC of %s: %v", ip, err)
+		return
+	}
+	refreshMachines()
+}
+
+type MachineMACRequest struct {
+	MAC string `json:"mac"` // empty reads it from the ARP table
+}
+
+// setMachineMACHandler sets or re-captures the MAC a machine is identified by.
+func setMachineMACHandler(c *gin.Context) {
+	ip := ipParam(c)
+	var req MachineMACRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	machines := currentMachines()
+	var m *db.Machine
+	for i := range machines {
+		if machines[i].IP == ip {
+			found := machines[i]
+			m = &found
+		}
+	}
+	if m == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown machine " + ip})
+		return
+	}
+
+	mac, err := normalizeMAC(req.MAC)
+	if req.MAC == "" {
+		mac, err = lookupMAC(ip)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, other := range machines {
+		if other.MAC == mac && other.IP != ip {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("MAC %s already belongs to %s", mac, other.Name)})
+			return
+		}
+	}
+	name := m.Name
+
+	if err := database.SetMachineMAC(ip, mac); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update machine"})
+		return
+	}
+	refreshMachines()
+
+	log.Printf("Set MAC of %s (%s) to %s", name, ip, mac)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"ip":      ip,
+		"mac":     mac,
+	})
+}
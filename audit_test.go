@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"miningRoom/db"
+	"miningRoom/grpcapi"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+)
+
+// auditedPaths returns the method and path of every audit entry.
+func auditedPaths(t *testing.T, d *db.DB) []string {
+	t.Helper()
+	entries, err := d.FetchAudit(time.Unix(0, 0), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, e := range entries {
+		paths = append(paths, e.Method+" "+e.Path)
+	}
+	return paths
+}
+
+func TestButtonRoutesAreAudited(t *testing.T) {
+	d := useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerAPIRoutes(r.Group("/api"))
+
+	for _, path := range []string{"/api/emergency/stop?token=x", "/api/scenarios/away/activate?token=x"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	got := auditedPaths(t, d)
+	want := []string{"GET /api/emergency/stop", "GET /api/scenarios/away/activate"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("audited %v, want %v", got, want)
+	}
+}
+
+func TestGRPCControlCallsAreAudited(t *testing.T) {
+	d := useTestDatabase(t)
+	defer func(active *db.Scenario) { scenarios.active = active }(scenarios.active)
+	scenarios.active = &db.Scenario{Name: "holiday", Away: true}
+
+	info := &grpc.UnaryServerInfo{FullMethod: grpcapi.MiningRoom_ShutdownMiners_FullMethodName}
+	called := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	}
+	if _, err := grpcUnaryInterceptor(grpcTestContext("127.0.0.1"), &grpcapi.MinersRequest{}, info, handler); err == nil || called {
+		t.Fatalf("shutdown while away: err = %v, called = %v", err, called)
+	}
+
+	entries, err := d.FetchAudit(time.Unix(0, 0), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Method != "GRPC" || entries[0].Path != info.FullMethod || entries[0].Status != http.StatusConflict {
+		t.Fatalf("audit entries = %+v, want the refused shutdown with status 409", entries)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// avalonDriver controls Canaan Avalon miners through their cgminer API. The
+// firmware offers soft-off for sleep but no portable power target; a Shelly
+// power cycle (start) also clears soft-off.
+type avalonDriver struct{}
+
+// avalonField matches the KEY[value] pairs packed into the estats "MM ID" string.
+var avalonField = regexp.MustCompile(`([A-Za-z][A-Za-z0-9 ]*?)\[([^\]]*)\]`)
+
+// avalonStats parses the first module's estats string into its fields.
+func avalonStats(ctx context.Context, ip string) (map[string]string, error) {
+	resp, err := cgminerCommand(ctx, ip, "estats", "")
+	if err != nil {
+		return nil, err
+	}
+	for _, stats := range cgminerList(resp, "STATS") {
+		for key, v := range stats {
+			mm, ok := v.(string)
+			if !ok || !strings.HasPrefix(key, "MM ID") {
+				continue
+			}
+			fields := map[string]string{}
+			for _, m := range avalonField.FindAllStringSubmatch(mm, -1) {
+				fields[strings.TrimSpace(m[1])] = m[2]
+			}
+			return fields, nil
+		}
+	}
+	return nil, errors.New("estats returned no module data")
+}
+
+func (avalonDriver) Config(ctx context.Context, ip string) (*MinerManageInfo, error) {
+	fields, err := avalonStats(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &MinerManageInfo{
+		Online:   true,
+		WorkMode: "Auto",
+	}
+	if fields["SoftOFF"] == "1" {
+		info.WorkMode = "Sleep"
+	}
+	if mode, ok := fields["WORKMODE"]; ok {
+		info.Profile = "workmode " + mode
+	}
+
+	chains, err := cgminerChains(ctx, ip)
+	if err != nil {
+		return info, nil
+	}
+	// Avalons report one device for the whole unit; temperatures and power
+	// only exist in estats
+	if len(chains) == 1 {
+		chains[0].BoardTemp, _ = strconv.ParseFloat(fields["Temp"], 64)
+		chains[0].ChipTemp, _ = strconv.ParseFloat(fields["TMax"], 64)
+		// PS[] holds the PSU readings; the fifth value is the output power in W
+		if ps := strings.Fields(fields["PS"]); len(ps) >= 5 {
+			chains[0].PowerW, _ = strconv.ParseFloat(ps[4], 64)
+		}
+	}
+	info.Chains = chains
+	return info, nil
+}
+
+func (avalonDriver) SetPowerTarget(ctx context.Context, ip string, power int) error {
+	return errors.New("power targets are not supported on Avalon; use sleep and start")
+}
+
+func (avalonDriver) SetFreqVolt(ctx context.Context, ip string, freq, volt float64) error {
+	return errors.New("fixed frequency/voltage is not supported on Avalon")
+}
+
+// SetSleep soft-offs the hashboards immediately; the control board stays up.
+func (avalonDriver) SetSleep(ctx context.Context, ip string) error {
+	_, err := cgminerCommand(ctx, ip, "ascset", fmt.Sprintf("0,softoff,1:%d", time.Now().Unix()))
+	return err
+}
+
+func (avalonDriver) RestartMining(ctx context.Context, ip string) error {
+	return errors.New("restarting the miner process is not supported on Avalon; reboot instead")
+}
+
+// Reboot restarts the control board through the cgminer API, since Avalons
+// have no SSH.
+func (avalonDriver) Reboot(ctx context.Context, ip string) error {
+	_, err := cgminerCommand(ctx, ip, "ascset", "0,reboot,0")
+	return err
+}
+
+func (avalonDriver) Pools(ctx context.Context, ip string) ([]PoolInfo, error) {
+	return cgminerPools(ctx, ip)
+}
+
+// SSHCommands is empty: Avalon firmware does not run an SSH server.
+func (avalonDriver) SSHCommands() map[string]sshCommand {
+	return map[string]sshCommand{}
+}
+
+func (avalonDriver) Identify(ctx context.Context, ip string) (*MinerIdentity, error) {
+	return cgminerIdentity(ctx, ip)
+}
+
+func (avalonDriver) Capabilities() MinerCapabilities {
+	return MinerCapabilities{SupportsSleep: true, SupportsReboot: true, SupportsPools: true}
+}
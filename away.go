@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Away mode is on while the active scenario is marked away. Destructive
+// manage requests then have to be repeated with a confirmation token
+// awayConfirmations times before they run, and a daily summary is pushed to
+// the Telegram channels.
+const (
+	awayConfirmations  = 2
+	awayConfirmTTL     = 5 * time.Minute
+	awayConfirmHeader  = "X-Away-Confirm"
+	dailySummaryPeriod = "daily-summary" // report_runs key of the summary push
+)
+
+// awayDestructiveRoutes lists the POST routes, relative to /api, that need
+// confirmation while away; every DELETE does too.
+var awayDestructiveRoutes = map[string]bool{
+	"/miner/shutdown":           true,
+	"/miners/shutdown":          true,
+	"/miners/sleep":             true,
+	"/miners/freq":              true,
+	"/machines/:ip/firmware":    true,
+	"/emergency/reset":          true,
+	"/ssh/exec":                 true,
+	"/templates/:name/apply":    true,
+	"/scenarios/:name/activate": true,
+	"/tuning":                   true,
+	"/2fa/disable":              true,
+}
+
+// awayActive reports whether away mode is on.
+func awayActive() bool {
+	a := scenarios.current()
+	return a != nil && a.Away
+}
+
+// pendingConfirmation is a destructive request waiting for confirmation.
+type pendingConfirmation struct {
+	request   string // fingerprint of the request being confirmed
+	remaining int
+	expires   time.Time
+}
+
+// awayConfirmStore holds the outstanding confirmation tokens.
+type awayConfirmStore struct {
+	mu      sync.Mutex
+	pending map[string]pendingConfirmation
+}
+
+var awayConfirms = &awayConfirmStore{pending: make(map[string]pendingConfirmation)}
+
+// issue stores a confirmation token for a request.
+func (s *awayConfirmStore) issue(request string, remaining int) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for t, p := range s.pending {
+		if now.After(p.expires) {
+			delete(s.pending, t)
+		}
+	}
+	s.pending[token] = pendingConfirmation{request: request, remaining: remaining, expires: now.Add(awayConfirmTTL)}
+	return token
+}
+
+// redeem consumes a token and returns the confirmations still needed for the
+// request, or false when the token is unknown, expired or for another request.
+func (s *awayConfirmStore) redeem(token, request string) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pending[token]
+	if !ok {
+		return 0, false
+	}
+	delete(s.pending, token)
+	if p.request != request || time.Now().After(p.expires) {
+		return 0, false
+	}
+	return p.remaining - 1, true
+}
+
+// awayGuard holds destructive manage requests while away mode is on. The
+// first attempt answers 428 with a token; repeating the identical request
+// (same route, parameters, body and client) with the token in X-Away-Confirm
+// confirms it, until awayConfirmations confirmations let it through. Dry runs
+// pass, but only on the routes whose handlers honor them (isDryRun).
+func awayGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := apiRoute(c.FullPath())
+		destructive := c.Request.Method == http.MethodDelete || c.Request.Method == http.MethodPost && awayDestructiveRoutes[route]
+		if !destructive || !awayActive() || isDryRun(c) {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		request := strings.Join([]string{c.Request.Method, c.Request.URL.Path, c.Request.URL.RawQuery, c.ClientIP(), hex.EncodeToString(sum[:])}, " ")
+
+		remaining := awayConfirmations
+		if token := c.GetHeader(awayConfirmHeader); token != "" {
+			left, ok := awayConfirms.redeem(token, request)
+			if !ok {
+				recordEvent("away", "rejected confirmation of %s %s from %s: invalid or expired token", c.Request.Method, route, c.ClientIP())
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "invalid or expired confirmation token"})
+				return
+			}
+			if left == 0 {
+				recordEvent("away", "%s %s from %s confirmed", c.Request.Method, route, c.ClientIP())
+				c.Next()
+				return
+			}
+			remaining = left
+		} else {
+			recordEvent("away", "%s %s from %s held for confirmation", c.Request.Method, route, c.ClientIP())
+		}
+
+		c.AbortWithStatusJSON(http.StatusPreconditionRequired, gin.H{
+			"error":             "away mode is on: repeat the request with " + awayConfirmHeader + " to confirm it",
+			"confirmRequired":   true,
+			"confirmToken":      awayConfirms.issue(request, remaining),
+			"confirmationsLeft": remaining,
+			"expiresIn":         int(awayConfirmTTL.Seconds()),
+		})
+	}
+}
+
+// DailySummary is the state of the room pushed once a day: current gauges,
+// yesterday's energy and the active alerts. It is also the data of the
+// --summary-webhook template.
+type DailySummary struct {
+	Date          string   `json:"date"` // day of the summary, in --timezone
+	Hashrate      float64  `json:"hashrateTh"`
+	PowerKW       float64  `json:"powerKw"`
+	Efficiency    float64  `json:"efficiency"` // J/TH
+	RoomTemp      *float64 `json:"roomTemp"`   // °C, nil without indoor sensors
+	YesterdayKWh  float64  `json:"yesterdayKwh"`
+	YesterdayCost float64  `json:"yesterdayCostEur"`
+	Revenue       float64  `json:"revenueEur"` // last 24 hours, actual where the pool has it
+	ElecCost      float64  `json:"elecCostEur"`
+	Profit        float64  `json:"profitEur"` // Revenue - ElecCost
+	ActiveAlerts  int      `json:"activeAlerts"`
+	Alerts        []string `json:"alerts"` // the first summaryTopAlerts, "[severity] message"
+}
+
+// collectDailySummary gathers the summary as of now.
+func collectDailySummary(now time.Time) DailySummary {
+	g := fetchGaugeValues(tsStore)
+	s := DailySummary{
+		Date:       now.In(roomLocation).Format("2006-01-02"),
+		Hashrate:   math.Round(g.Hashrate*10) / 10,
+		PowerKW:    math.Round(g.Power/10) / 100,
+		Efficiency: math.Round(g.Efficiency*10) / 10,
+		Revenue:    g.Revenue,
+		ElecCost:   g.ElecCost,
+		Profit:     math.Round((g.Revenue-g.ElecCost)*100) / 100,
+		Alerts:     []string{},
+	}
+
+	if room, err := tsStore.GetRoomTemperature(indoorLocations()); err != nil {
+		log.Printf("Daily summary: failed to get room temperature: %v", err)
+	} else if room.HasData {
+		t := math.Round(room.Temperature*10) / 10
+		s.RoomTemp = &t
+	}
+
+	if energy, err := questdbClient.GetDailyEnergyUsage(); err != nil {
+		log.Printf("Daily summary: failed to get daily energy: %v", err)
+	} else {
+		yesterday := now.In(roomLocation).AddDate(0, 0, -1).Format("2006-01-02")
+		for _, d := range energy.Days {
+			if d.Date == yesterday {
+				s.YesterdayKWh = math.Round(d.EnergyKWh*10) / 10
+				s.YesterdayCost = math.Round(d.EnergyKWh*elecPrice*100) / 100
+			}
+		}
+	}
+
+	active := alerts.list()
+	s.ActiveAlerts = len(active)
+	for i, a := range active {
+		if i == summaryTopAlerts {
+			break
+		}
+		s.Alerts = append(s.Alerts, fmt.Sprintf("[%s] %s", a.Severity, a.Message))
+	}
+	return s
+}
+
+// dailySummaryNotification summarizes the room for the away summary push.
+func dailySummaryNotification(now time.Time) Notification {
+	s := collectDailySummary(now)
+	lines := []string{fmt.Sprintf("Hashrate %.1f TH/s, power %.2f kW, %.1f J/TH", s.Hashrate, s.PowerKW, s.Efficiency)}
+	if s.RoomTemp != nil {
+		lines = append(lines, fmt.Sprintf("Room %.1f °C", *s.RoomTemp))
+	}
+	if s.YesterdayKWh > 0 {
+		lines = append(lines, fmt.Sprintf("Yesterday %.1f kWh, %.2f EUR", s.YesterdayKWh, s.YesterdayCost))
+	}
+	lines = append(lines, fmt.Sprintf("%d active alerts", s.ActiveAlerts))
+	for _, a := range s.Alerts {
+		lines = append(lines, "- "+a)
+	}
+
+	return Notification{
+		Title:    "Mining room daily summary",
+		Message:  strings.Join(lines, "\n"),
+		Severity: severityWarning,
+		Source:   "summary",
+		Time:     now,
+	}
+}
+
+// pushDailySummary sends the summary to the enabled Telegram channels. It
+// fails only when none of them could be reached.
+func pushDailySummary(now time.Time) error {
+	channels, err := database.FetchNotifyChannels()
+	if err != nil {
+		return err
+	}
+	var telegram []db.NotifyChannel
+	for _, ch := range channels {
+		if ch.Enabled && ch.Kind == "telegram" {
+			telegram = append(telegram, ch)
+		}
+	}
+	if len(telegram) == 0 {
+		return nil
+	}
+
+	n := dailySummaryNotification(now)
+	sent := 0
+	for _, ch := range telegram {
+		nf, err := notifierFor(ch)
+		if err == nil {
+			err = nf.Notify(n)
+		}
+		if err != nil {
+			log.Printf("Daily summary: failed to notify %s: %v", ch.Name, err)
+			continue
+		}
+		sent++
+	}
+	if sent == 0 {
+		return fmt.Errorf("no Telegram channel reached")
+	}
+	return nil
+}
+
+// runAwaySummary pushes the daily summary once a day from reportSendHour while
+// away mode is on; the last push is stored so restarts don't resend.
+func runAwaySummary(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := roomNow()
+		if !awayActive() || now.Hour() < reportSendHour {
+			continue
+		}
+		last, err := database.LastReportRun(dailySummaryPeriod)
+		if err != nil {
+			log.Printf("Daily summary: failed to read last push: %v", err)
+			continue
+		}
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		if !last.Before(today) {
+			continue
+		}
+		if err := pushDailySummary(now); err != nil {
+			log.Printf("Daily summary: %v", err)
+			continue
+		}
+		if err := database.SetReportRun(dailySummaryPeriod, now); err != nil {
+			log.Printf("Daily summary: failed to record push: %v", err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAwayGuardPassesOnlyHonoredDryRuns(t *testing.T) {
+	useTestDatabase(t)
+	defer func(active *db.Scenario) { scenarios.active = active }(scenarios.active)
+	scenarios.active = &db.Scenario{Name: "holiday", Away: true}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	api := r.Group("/api", awayGuard())
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	for _, path := range []string{"/miners/shutdown", "/miner/shutdown", "/tuning", "/ssh/exec", "/templates/:name/apply"} {
+		api.POST(path, ok)
+	}
+	api.DELETE("/machines/:ip", ok)
+
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPost, "/api/miners/shutdown", `{"ips":["10.0.0.1"],"dryRun":true}`, http.StatusNoContent},
+		{http.MethodPost, "/api/templates/night/apply", `{"ips":["10.0.0.1"],"dryRun":true}`, http.StatusNoContent},
+		{http.MethodPost, "/api/miners/shutdown", `{"ips":["10.0.0.1"]}`, http.StatusPreconditionRequired},
+		{http.MethodPost, "/api/miner/shutdown", `{"ip":"10.0.0.1","dryRun":true}`, http.StatusPreconditionRequired},
+		{http.MethodPost, "/api/tuning", `{"ip":"10.0.0.1","dryRun":true}`, http.StatusPreconditionRequired},
+		{http.MethodPost, "/api/ssh/exec", `{"ip":"10.0.0.1","command":"reboot","dryRun":true}`, http.StatusPreconditionRequired},
+		{http.MethodDelete, "/api/machines/10.0.0.1", `{"dryRun":true}`, http.StatusPreconditionRequired},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if w.Code != tt.want {
+			t.Errorf("%s %s %s = %d, want %d", tt.method, tt.path, tt.body, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BillingMachine is one machine's line on a hosting statement.
+type BillingMachine struct {
+	Name          string  `json:"name"`
+	IP            string  `json:"ip"`
+	EnergyKWh     float64 `json:"energyKwh"`
+	EnergySource  string  `json:"energySource"` // "outlet" (metered) or "miner" (reported power)
+	AvgHashrateTH float64 `json:"avgHashrateTh"`
+	BTCEarned     float64 `json:"btcEarned"` // estimate at current network hashrate
+	EnergyCostEUR float64 `json:"energyCostEur"`
+	HostingFeeEUR float64 `json:"hostingFeeEur"`
+	TotalEUR      float64 `json:"totalEur"`
+}
+
+// OwnerStatement is a month of energy, fees and attributed revenue of the
+// machines an owner hosts in the room. The room's own machines have an empty
+// Owner and no hosting fee.
+type OwnerStatement struct {
+	Owner          string           `json:"owner"`
+	Email          string           `json:"email,omitempty"`
+	Month          string           `json:"month"`
+	From           time.Time        `json:"from"`
+	To             time.Time        `json:"to"`
+	Partial        bool             `json:"partial"` // the month isn't over; fees are prorated
+	ElecPrice      float64          `json:"elecPrice"`
+	HostingFeeEUR  float64          `json:"hostingFeeEur"` // per machine and month
+	Machines       []BillingMachine `json:"machines"`
+	EnergyKWh      float64          `json:"energyKwh"`
+	EnergyCostEUR  float64          `json:"energyCostEur"`
+	HostingFeesEUR float64          `json:"hostingFeesEur"`
+	TotalDueEUR    float64          `json:"totalDueEur"`
+	BTCEarned      float64          `json:"btcEarned"`
+	BTCPriceEUR    float64          `json:"btcPriceEur"`
+	PriceSource    string           `json:"btcPriceSource"` // "history" (month average) or "current"
+	RevenueEUR     float64          `json:"revenueEur"`     // BTCEarned at BTCPriceEUR
+}
+
+// buildOwnerStatements bills the month between from and to per owner. Machines
+// are grouped by their current owner. Energy comes from the outlet where it is
+// metered and from the miner's reported power otherwise; BTC is attributed by
+// each machine's hashrate at the current network hashrate, like the period
+// reports, and valued at the month's average stored BTC price.
+func buildOwnerStatements(c *gin.Context, from, to time.Time) ([]OwnerStatement, *miningMarket, error) {
+	owners, err := database.FetchHostingOwners()
+	if err != nil {
+		return nil, nil, err
+	}
+	deviceEnergy, err := questdbFor(c).GetDeviceEnergy(from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	usage, err := questdbFor(c).GetMinerUsage(from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	market, err := fetchMiningMarket()
+	if err != nil {
+		log.Printf("Failed to fetch mining market for billing: %v", err)
+	}
+	btcPrice, priceSource := periodBTCPriceEUR(from, to, market)
+
+	terms := make(map[string]db.HostingOwner, len(owners))
+	for _, o := range owners {
+		terms[o.Name] = o
+	}
+	monthStart := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, from.Location())
+	monthFraction := to.Sub(from).Hours() / monthStart.AddDate(0, 1, 0).Sub(monthStart).Hours()
+	hours := to.Sub(from).Hours()
+
+	byOwner := make(map[string]*OwnerStatement)
+	ms := machinesFor(c)
+	deviceIDs := outletDeviceIDs(ms)
+	for i, m := range ms {
+		st := byOwner[m.Owner]
+		if st == nil {
+			t := terms[m.Owner]
+			st = &OwnerStatement{
+				Owner:     m.Owner,
+				Email:     t.Email,
+				Month:     from.Format("2006-01"),
+				From:      from,
+				To:        to,
+				Partial:   monthFraction < 1,
+				ElecPrice: elecPrice,
+				Machines:  []BillingMachine{},
+			}
+			if t.ElecPrice > 0 {
+				st.ElecPrice = t.ElecPrice
+			}
+			if m.Owner != "" {
+				st.HostingFeeEUR = t.HostingFeeEUR
+			}
+			byOwner[m.Owner] = st
+		}
+
+		u := usage[m.IP]
+		line := BillingMachine{Name: m.Name, IP: m.IP, EnergyKWh: u.EnergyKWh, EnergySource: "miner"}
+		if kwh, ok := deviceEnergy[deviceIDs[i]]; ok && deviceIDs[i] != "" {
+			line.EnergyKWh = kwh
+			line.EnergySource = "outlet"
+		}
+		if hours > 0 {
+			line.AvgHashrateTH = math.Round(u.THHours/hours*10) / 10
+		}
+		if market != nil {
+			line.BTCEarned = u.THHours / 24 * market.dailyBTCPerTH()
+		}
+		cost := line.EnergyKWh * st.ElecPrice
+		fee := st.HostingFeeEUR * monthFraction
+		line.EnergyCostEUR = math.Round(cost*100) / 100
+		line.HostingFeeEUR = math.Round(fee*100) / 100
+		line.TotalEUR = math.Round((line.EnergyCostEUR+line.HostingFeeEUR)*100) / 100
+
+		st.EnergyKWh += line.EnergyKWh
+		st.EnergyCostEUR += line.EnergyCostEUR
+		st.HostingFeesEUR += line.HostingFeeEUR
+		st.BTCEarned += line.BTCEarned
+		line.EnergyKWh = math.Round(line.EnergyKWh*100) / 100
+		st.Machines = append(st.Machines, line)
+	}
+
+	statements := make([]OwnerStatement, 0, len(byOwner))
+	for _, st := range byOwner {
+		st.EnergyKWh = math.Round(st.EnergyKWh*100) / 100
+		st.EnergyCostEUR = math.Round(st.EnergyCostEUR*100) / 100
+		st.HostingFeesEUR = math.Round(st.HostingFeesEUR*100) / 100
+		st.TotalDueEUR = math.Round((st.EnergyCostEUR+st.HostingFeesEUR)*100) / 100
+		if market != nil {
+			st.BTCPriceEUR, st.PriceSource = btcPrice, priceSource
+			st.RevenueEUR = math.Round(st.BTCEarned*btcPrice*100) / 100
+		}
+		statements = append(statements, *st)
+	}
+	sort.Slice(statements, func(i, j int) bool { return statements[i].Owner < statements[j].Owner })
+	return statements, market, nil
+}
+
+// statementsCSV renders one row per machine and a total row per owner.
+func statementsCSV(statements []OwnerStatement) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"month", "owner", "machine", "ip", "energy_kwh", "energy_source", "avg_hashrate_th", "btc_earned", "elec_price_eur_kwh", "energy_cost_eur", "hosting_fee_eur", "total_eur"})
+	money := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+	for _, st := range statements {
+		price := strconv.FormatFloat(st.ElecPrice, 'f', -1, 64)
+		for _, m := range st.Machines {
+			w.Write([]string{st.Month, st.Owner, m.Name, m.IP, strconv.FormatFloat(m.EnergyKWh, 'f', 2, 64), m.EnergySource,
+				strconv.FormatFloat(m.AvgHashrateTH, 'f', 1, 64), strconv.FormatFloat(m.BTCEarned, 'f', 8, 64),
+				price, money(m.EnergyCostEUR), money(m.HostingFeeEUR), money(m.TotalEUR)})
+		}
+		w.Write([]string{st.Month, st.Owner, "total", "", strconv.FormatFloat(st.EnergyKWh, 'f', 2, 64), "",
+			"", strconv.FormatFloat(st.BTCEarned, 'f', 8, 64),
+			price, money(st.EnergyCostEUR), money(st.HostingFeesEUR), money(st.TotalDueEUR)})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// getBillingStatementsHandler returns the hosting statements of ?month=2026-01
+// (default the previous month). ?owner= limits them to one owner (empty
+// string for the room's own machines is owner=-); ?format=csv downloads them
+// and ?format=html renders the statement of the one ?owner= for printing.
+func getBillingStatementsHandler(c *gin.Context) {
+	from, to, err := parseMonthWindow(c, requestNow(c), -1)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	owner, byOwner := c.GetQuery("owner")
+	if owner == "-" {
+		owner = ""
+	}
+	if o := ownerFor(c); o != "" {
+		owner, byOwner = o, true
+	}
+	if format == "html" && !byOwner {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format=html needs an owner"})
+		return
+	}
+
+	statements, market, err := buildOwnerStatements(c, from, to)
+	if err != nil {
+		log.Printf("Failed to build billing statements: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build statements"})
+		return
+	}
+	if byOwner {
+		filtered := statements[:0]
+		for _, st := range statements {
+			if st.Owner == owner {
+				filtered = append(filtered, st)
+			}
+		}
+		statements = filtered
+		if len(statements) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no machines for owner"})
+			return
+		}
+	}
+
+	switch format {
+	case "json":
+		btcPrice := 0.0
+		if len(statements) > 0 {
+			btcPrice = statements[0].BTCPriceEUR
+		} else if market != nil {
+			btcPrice = market.BTCPriceEUR
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"month":       from.Format("2006-01"),
+			"from":        from,
+			"to":          to,
+			"btcPriceEur": btcPrice,
+			"statements":  statements,
+		})
+	case "csv":
+		data, err := statementsCSV(statements)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		name := "statements-" + from.Format("2006-01")
+		if byOwner {
+			name = "statement-" + from.Format("2006-01") + "-" + statementFileName(owner)
+		}
+		c.Header("Content-Disposition", "attachment; filename="+name+".csv")
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
+	case "html":
+		html, err := renderTemplate("billing-statement.html", gin.H{
+			"Title":     fmt.Sprintf("Hosting statement %s", from.Format("2006-01")),
+			"Lang":      localeFor(c).Lang,
+			"Statement": statements[0],
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(html))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be one of json, csv, html"})
+	}
+}
+
+// statementFileName reduces an owner name to characters safe in a file name.
+func statementFileName(owner string) string {
+	if owner == "" {
+		return "own"
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, owner)
+}
+
+func getHostingOwnersHandler(c *gin.Context) {
+	owners, err := database.FetchHostingOwners()
+	if err != nil {
+		log.Printf("Failed to fetch hosting owners: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch owners"})
+		return
+	}
+	if owners == nil {
+		owners = []db.HostingOwner{}
+	}
+	c.JSON(http.StatusOK, gin.H{"owners": owners, "elecPrice": elecPrice})
+}
+
+func saveHostingOwnerHandler(c *gin.Context) {
+	var o db.HostingOwner
+	if err := c.ShouldBindJSON(&o); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	o.Name = strings.TrimSpace(o.Name)
+	if o.Name == "" || o.Name == "-" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	if o.ElecPrice < 0 || o.HostingFeeEUR < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "elecPrice and hostingFeeEur must not be negative"})
+		return
+	}
+
+	if err := database.SaveHostingOwner(o); err != nil {
+		log.Printf("Failed to save hosting owner %s: %v", o.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save owner"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "owner": o})
+}
+
+// deleteHostingOwnerHandler removes an owner's billing terms; their machines
+// keep the owner and are billed at --elec-price without a hosting fee.
+func deleteHostingOwnerHandler(c *gin.Context) {
+	if err := database.DeleteHostingOwner(c.Param("name")); err != nil {
+		log.Printf("Failed to delete hosting owner %s: %v", c.Param("name"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete owner"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+type MachineOwnerRequest struct {
+	Owner string `json:"owner"` // empty for the room's own machines
+}
+
+// setMachineOwnerHandler sets the hosting customer a machine is billed to.
+func setMachineOwnerHandler(c *gin.Context) {
+	ip := ipParam(c)
+	var req MachineOwnerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Owner = strings.TrimSpace(req.Owner)
+	if req.Owner == "-" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid owner"})
+		return
+	}
+
+	if err := database.UpdateMachineOwner(ip, req.Owner); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update machine"})
+		return
+	}
+	refreshMachines()
+
+	log.Printf("Set owner of machine %s to %q", ip, req.Owner)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"ip":      ip,
+		"owner":   req.Owner,
+	})
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The block reward used for revenue is the current subsidy, derived from the
+// chain tip's height, plus the average fees per block over the last week,
+// both from mempool.space. It is cached for blockRewardTTL; when a refresh
+// fails the last known reward is kept, and without one the
+// fallbackBlockRewardBTC estimate is used.
+const (
+	blockRewardTTL         = time.Hour
+	fallbackBlockRewardBTC = 3.15 // subsidy plus typical fees after the 2024 halving
+	halvingInterval        = 210000
+	satsPerBTC             = 1e8
+)
+
+// blockReward is the reward per block revenue estimates assume.
+type blockReward struct {
+	Height      int64     `json:"height"`      // chain tip the subsidy is derived from, 0 for the fallback
+	SubsidyBTC  float64   `json:"subsidyBtc"`  // from the height's halving epoch
+	AvgFeesBTC  float64   `json:"avgFeesBtc"`  // mean fees per block over feeWindow
+	FeeWindow   string    `json:"feeWindow"`   // mempool.space period of the fee average
+	TotalBTC    float64   `json:"totalBtc"`    // subsidy + fees
+	Source      string    `json:"source"`      // "mempool.space", "cached" after a failed refresh, or "fallback"
+	RetrievedAt time.Time `json:"retrievedAt"` // zero for the fallback
+}
+
+// blockSubsidyBTC returns the subsidy of a block at the given height.
+func blockSubsidyBTC(height int64) float64 {
+	halvings := height / halvingInterval
+	if halvings >= 64 {
+		return 0
+	}
+	return float64(int64(50*satsPerBTC)>>halvings) / satsPerBTC
+}
+
+type blockRewardCache struct {
+	mu     sync.Mutex
+	reward *blockReward
+}
+
+var blockRewards = &blockRewardCache{}
+
+// get returns the cached reward, refreshing it when older than
+// blockRewardTTL.
+func (c *blockRewardCache) get() blockReward {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.reward != nil && time.Since(c.reward.RetrievedAt) < blockRewardTTL {
+		return *c.reward
+	}
+
+	r, err := fetchBlockReward()
+	if err == nil {
+		c.reward = &r
+		return r
+	}
+	if c.reward != nil {
+		log.Printf("Failed to refresh block reward, using the one from %s: %v", c.reward.RetrievedAt.Format(time.RFC3339), err)
+		stale := *c.reward
+		stale.Source = "cached"
+		return stale
+	}
+	log.Printf("Failed to fetch block reward, assuming %.2f BTC: %v", fallbackBlockRewardBTC, err)
+	return blockReward{TotalBTC: fallbackBlockRewardBTC, Source: "fallback"}
+}
+
+// fetchBlockReward reads the tip height and the week's fees per block from
+// mempool.space.
+func fetchBlockReward() (blockReward, error) {
+	const feeWindow = "1w"
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	resp, err := client.Get("https://mempool.space/api/blocks/tip/height")
+	if err != nil {
+		return blockReward{}, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return blockReward{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return blockReward{}, fmt.Errorf("tip height: HTTP %d", resp.StatusCode)
+	}
+	height, err := strconv.ParseInt(strings.TrimSpace(string(body)), 10, 64)
+	if err != nil || height <= 0 {
+		return blockReward{}, fmt.Errorf("invalid tip height %q", body)
+	}
+
+	resp, err = client.Get("https://mempool.space/api/v1/mining/blocks/fees/" + feeWindow)
+	if err != nil {
+		return blockReward{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return blockReward{}, fmt.Errorf("block fees: HTTP %d", resp.StatusCode)
+	}
+	var fees []struct {
+		AvgFees float64 `json:"avgFees"` // sats per block
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&fees); err != nil {
+		return blockReward{}, err
+	}
+	if len(fees) == 0 {
+		return blockReward{}, fmt.Errorf("no block fees for %s", feeWindow)
+	}
+	total := 0.0
+	for _, f := range fees {
+		total += f.AvgFees
+	}
+	avgFees := total / float64(len(fees)) / satsPerBTC
+
+	subsidy := blockSubsidyBTC(height)
+	return blockReward{
+		Height:      height,
+		SubsidyBTC:  subsidy,
+		AvgFeesBTC:  math.Round(avgFees*1e8) / 1e8,
+		FeeWindow:   feeWindow,
+		TotalBTC:    subsidy + avgFees,
+		Source:      "mempool.space",
+		RetrievedAt: time.Now(),
+	}, nil
+}
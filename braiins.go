@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// TunerStatus is the autotuner state reported by Braiins OS+.
+type TunerStatus struct {
+	PowerLimit          float64 `json:"powerLimit"`  // W
+	ApproxPower         float64 `json:"approxPower"` // W, estimated by the tuner
+	DynamicPowerScaling string  `json:"dynamicPowerScaling"`
+	Running             bool    `json:"running"`
+	Stage               string  `json:"stage"`
+}
+
+// ChainInfo is the state of one hashboard chain.
+type ChainInfo struct {
+	Index       int     `json:"index"`
+	Status      string  `json:"status"`
+	HashrateGH  float64 `json:"hashrateGh"`
+	BoardTemp   float64 `json:"boardTemp"`
+	ChipTemp    float64 `json:"chipTemp"`
+	PowerW      float64 `json:"powerW"`
+	TunerStatus string  `json:"tunerStatus,omitempty"`
+}
+
+// braiinsDriver controls Braiins OS+ miners. State is read from BOSminer's
+// cgminer-compatible API (tunerstatus, devs, temps); changes are made by
+// editing bosminer.toml over SSH, since the gRPC API needs a client this
+// project does not ship.
+type braiinsDriver struct{}
+
+// bosminerTune sets the autotuner power target in bosminer.toml and restarts
+// BOSminer, which also wakes a stopped miner.
+const bosminerTune = `sed -i -E 's/^(power_target|psu_power_limit) *= *[0-9]+/\1 = %d/' /etc/bosminer.toml && /etc/init.d/bosminer restart`
+
+func (braiinsDriver) Config(ctx context.Context, ip string) (*MinerManageInfo, error) {
+	resp, err := cgminerCommand(ctx, ip, "tunerstatus", "")
+	if err != nil {
+		return nil, err
+	}
+
+	info := &MinerManageInfo{
+		Online:              true,
+		WorkMode:            "Auto",
+		ModeSelect:          "PowerTarget",
+		ModeSelectAvailable: []string{"PowerTarget"},
+	}
+
+	tunerChains := map[int]map[string]interface{}{}
+	if status, ok := firstObject(resp, "TUNERSTATUS"); ok {
+		tuner := &TunerStatus{
+			PowerLimit:  numberField(status, "PowerLimit"),
+			ApproxPower: numberField(status, "ApproximateMinerPowerConsumption"),
+		}
+		tuner.DynamicPowerScaling, _ = status["DynamicPowerScaling"].(string)
+		for _, chain := range cgminerList(status, "TunerChainStatus") {
+			tunerChains[int(numberField(chain, "HashchainIndex"))] = chain
+			stage, _ := chain["Status"].(string)
+			if running, _ := chain["TunerRunning"].(bool); running || tuner.Stage == "" {
+				tuner.Running = tuner.Running || running
+				tuner.Stage = stage
+			}
+		}
+		info.Tuner = tuner
+		info.TargetValue = tuner.PowerLimit
+	}
+
+	// Per-chain data is best effort; the tuner status is enough for control
+	chains, err := cgminerChains(ctx, ip)
+	if err != nil {
+		return info, nil
+	}
+	for i := range chains {
+		if tc, ok := tunerChains[chains[i].Index]; ok {
+			chains[i].TunerStatus, _ = tc["Status"].(string)
+			chains[i].PowerW = numberField(tc, "ApproximatePowerConsumptionWatt")
+		}
+	}
+	info.Chains = chains
+
+	return info, nil
+}
+
+func (d braiinsDriver) SetPowerTarget(ctx context.Context, ip string, power int) error {
+	return runDriverCommand(ctx, ip, d, "tune", &power)
+}
+
+func (braiinsDriver) SetFreqVolt(ctx context.Context, ip string, freq, volt float64) error {
+	return errors.New("fixed frequency/voltage is not supported on Braiins OS+; use a power target")
+}
+
+func (d braiinsDriver) SetSleep(ctx context.Context, ip string) error {
+	return runDriverCommand(ctx, ip, d, "stop", nil)
+}
+
+func (d braiinsDriver) RestartMining(ctx context.Context, ip string) error {
+	return runDriverCommand(ctx, ip, d, "restart", nil)
+}
+
+func (d braiinsDriver) Reboot(ctx context.Context, ip string) error {
+	return runDriverCommand(ctx, ip, d, "reboot", nil)
+}
+
+func (braiinsDriver) Pools(ctx context.Context, ip string) ([]PoolInfo, error) {
+	return cgminerPools(ctx, ip)
+}
+
+func (braiinsDriver) SSHCommands() map[string]sshCommand {
+	return map[string]sshCommand{
+		"reboot":  {Command: "reboot", Description: "Reboot the control board", Destructive: true},
+		"logs":    {Command: "tail -n 200 /var/log/bosminer/bosminer.log 2>/dev/null || logread | tail -n 200", Description: "Last 200 lines of the BOSminer log"},
+		"tune":    {Command: bosminerTune, Description: "Set the autotuner power target and restart BOSminer", Arg: "power", ArgMin: 100, ArgMax: 10000},
+		"restart": {Command: "/etc/init.d/bosminer restart", Description: "Restart BOSminer (re-runs tuning)"},
+		"stop":    {Command: "/etc/init.d/bosminer stop", Description: "Stop mining (sleep)", Destructive: true},
+	}
+}
+
+// runDriverCommand runs one of a driver's whitelisted SSH commands.
+func runDriverCommand(ctx context.Context, ip string, d minerDriver, name string, arg *int) error {
+	cmd, ok := d.SSHCommands()[name]
+	if !ok {
+		return fmt.Errorf("command %s not available", name)
+	}
+	line, err := cmd.render(arg)
+	if err != nil {
+		return err
+	}
+	if output, err := runSSHCommand(ctx, ip, line); err != nil {
+		return fmt.Errorf("%s failed: %w (%s)", name, err, output)
+	}
+	return nil
+}
+
+func (braiinsDriver) Identify(ctx context.Context, ip string) (*MinerIdentity, error) {
+	return cgminerIdentity(ctx, ip)
+}
+
+func (braiinsDriver) Capabilities() MinerCapabilities {
+	return MinerCapabilities{SupportsPowerTarget: true, SupportsSleep: true, SupportsReboot: true, SupportsRestart: true, SupportsPools: true}
+}
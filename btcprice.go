@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"miningRoom/questdb"
+)
+
+// BTC prices in every currency mempool.space quotes are stored in the
+// btc_price table, so reports of past periods value the BTC mined at the
+// prices of the time instead of today's. The ratios between the currencies
+// double as exchange rates.
+
+var (
+	btcPricePollMinutes int    // --btc-price-poll
+	backfillBTCPrices   string // --backfill-btc-prices: import daily prices since this date and exit
+)
+
+const (
+	btcPriceTable = "btc_price"
+	// btcPriceMinCoverage is the share of a period's days that need a stored
+	// price before the period's average is used instead of today's price.
+	btcPriceMinCoverage = 0.5
+	backfillBatchSize   = 1000
+)
+
+// fetchBTCPrices returns the current BTC price per currency.
+func fetchBTCPrices() (map[string]float64, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get("https://mempool.space/api/v1/prices")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	var data map[string]float64
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	delete(data, "time")
+	return data, nil
+}
+
+// btcPricePoints renders prices per currency as btc_price rows.
+func btcPricePoints(prices map[string]float64, source string, t time.Time) []questdb.Point {
+	var points []questdb.Point
+	for currency, price := range prices {
+		if price <= 0 {
+			continue
+		}
+		points = append(points, questdb.Point{
+			Table:   btcPriceTable,
+			Symbols: map[string]string{"currency": currency, "source": source},
+			Fields:  map[string]interface{}{"price": price},
+			Time:    t,
+		})
+	}
+	return points
+}
+
+// runBTCPriceRecorder stores the current prices at the given interval,
+// starting right away.
+func runBTCPriceRecorder(interval time.Duration) {
+	recordBTCPrices()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		recordBTCPrices()
+	}
+}
+
+func recordBTCPrices() {
+	prices, err := fetchBTCPrices()
+	if err != nil {
+		log.Printf("BTC price recorder: %v", err)
+		return
+	}
+	if err := questdbClient.Write(btcPricePoints(prices, "live", time.Time{})); err != nil {
+		log.Printf("BTC price recorder: failed to write prices: %v", err)
+	}
+}
+
+// backfillBTCPriceHistory imports one price per UTC day, in every currency,
+// from since up to yesterday from the mempool.space price history. Days
+// imported twice average to the same price, so it can be rerun.
+func backfillBTCPriceHistory(since time.Time) (int, error) {
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Get("https://mempool.space/api/v1/historical-price")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("price history: HTTP %d", resp.StatusCode)
+	}
+	var data struct {
+		Prices []map[string]float64 `json:"prices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return 0, fmt.Errorf("price history: %w", err)
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	seen := make(map[time.Time]bool)
+	var points []questdb.Point
+	days := 0
+	for _, p := range data.Prices {
+		t := time.Unix(int64(p["time"]), 0).UTC()
+		day := t.Truncate(24 * time.Hour)
+		if day.Before(since) || !day.Before(today) || seen[day] {
+			continue
+		}
+		seen[day] = true
+		delete(p, "time")
+		points = append(points, btcPricePoints(p, "backfill", t)...)
+		days++
+	}
+
+	for start := 0; start < len(points); start += backfillBatchSize {
+		end := min(start+backfillBatchSize, len(points))
+		if err := questdbClient.Write(points[start:end]); err != nil {
+			return 0, fmt.Errorf("failed to write prices: %w", err)
+		}
+	}
+	return days, nil
+}
+
+// periodBTCPriceEUR returns the BTC price to value a past period's mining
+// at: the average stored price over the period where enough days have one,
+// else the current price of market (nil when it couldn't be fetched). The
+// source is "history", "current" or empty without a price.
+func periodBTCPriceEUR(from, to time.Time, market *miningMarket) (float64, string) {
+	price, days, err := questdbClient.GetAvgBTCPrice("EUR", from, to)
+	if err != nil {
+		log.Printf("Failed to get historical BTC price: %v", err)
+	} else if days > 0 && float64(days) >= to.Sub(from).Hours()/24*btcPriceMinCoverage {
+		return price, "history"
+	}
+	if market == nil {
+		return 0, ""
+	}
+	return market.BTCPriceEUR, "current"
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"time"
+)
+
+// cgminerAPIPort is the TCP port of the cgminer-compatible JSON API exposed by
+// BOSminer and other aftermarket firmwares.
+const cgminerAPIPort = "4028"
+
+// cgminerCommand sends one command to the cgminer-compatible API and returns
+// the decoded response. The API answers with a single JSON object and closes
+// the connection.
+func cgminerCommand(ctx context.Context, ip, command, parameter string) (map[string]interface{}, error) {
+	conn, err := dialContext(ctx, "tcp", net.JoinHostPort(ip, cgminerAPIPort), 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(ctxDeadline(ctx, 10*time.Second))
+
+	req := map[string]string{"command": command}
+	if parameter != "" {
+		req["parameter"] = parameter
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, err
+	}
+	// Some firmwares terminate the response with a NUL byte
+	body = bytes.TrimRight(body, "\x00\n")
+
+	var resp map[string]interface{}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse %s response: %w", command, err)
+	}
+	if status, ok := firstObject(resp, "STATUS"); ok {
+		if s, _ := status["STATUS"].(string); s == "E" || s == "F" {
+			msg, _ := status["Msg"].(string)
+			return nil, fmt.Errorf("%s failed: %s", command, msg)
+		}
+	}
+	return resp, nil
+}
+
+// cgminerList returns the objects of a response section such as "DEVS".
+func cgminerList(resp map[string]interface{}, section string) []map[string]interface{} {
+	items, _ := resp[section].([]interface{})
+	out := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if obj, ok := item.(map[string]interface{}); ok {
+			out = append(out, obj)
+		}
+	}
+	return out
+}
+
+// firstObject returns the first object of a response section.
+func firstObject(resp map[string]interface{}, section string) (map[string]interface{}, bool) {
+	list := cgminerList(resp, section)
+	if len(list) == 0 {
+		return nil, false
+	}
+	return list[0], true
+}
+
+// numberField reads a numeric field, returning 0 when absent.
+func numberField(obj map[string]interface{}, key string) float64 {
+	v, _ := obj[key].(float64)
+	return v
+}
+
+// stringField returns the first non-empty string among keys of obj.
+func stringField(obj map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if s, _ := obj[key].(string); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// cgminerIdentity reads the model and firmware version from the version
+// command and the nominal hashrate from stats. Firmwares name the fields
+// differently: Bitmain and LuxOS report Type, Avalon PROD; the firmware
+// version is under its own name (BOSminer, LUXminer) or the cgminer version.
+func cgminerIdentity(ctx context.Context, ip string) (*MinerIdentity, error) {
+	resp, err := cgminerCommand(ctx, ip, "version", "")
+	if err != nil {
+		return nil, err
+	}
+	id := &MinerIdentity{}
+	if v, ok := firstObject(resp, "VERSION"); ok {
+		id.Model = stringField(v, "Type", "PROD", "Model", "MODEL")
+		id.FirmwareVersion = stringField(v, "BOSminer", "LUXminer", "Firmware", "CompileTime", "BMMiner", "CGMiner")
+	}
+
+	// stats is best effort; not every firmware reports the ideal hashrate
+	if stats, err := cgminerCommand(ctx, ip, "stats", ""); err == nil {
+		for _, s := range cgminerList(stats, "STATS") {
+			if id.Model == "" {
+				id.Model = stringField(s, "Type")
+			}
+			if ideal := numberField(s, "total_rateideal"); ideal > 0 {
+				id.NominalHashrate = math.Round(ideal*10) / 10
+			}
+		}
+	}
+	if id.Model == "" && id.FirmwareVersion == "" {
+		return nil, errors.New("version returned no model or firmware")
+	}
+	return id, nil
+}
+
+// cgminerPools reads the configured pools and their share counters from the
+// pools command.
+func cgminerPools(ctx context.Context, ip string) ([]PoolInfo, error) {
+	resp, err := cgminerCommand(ctx, ip, "pools", "")
+	if err != nil {
+		return nil, err
+	}
+
+	var pools []PoolInfo
+	for _, p := range cgminerList(resp, "POOLS") {
+		pool := PoolInfo{
+			Accepted: int64(numberField(p, "Accepted")),
+			Rejected: int64(numberField(p, "Rejected")),
+			Stale:    int64(numberField(p, "Stale")),
+		}
+		pool.URL, _ = p["URL"].(string)
+		pool.User, _ = p["User"].(string)
+		pool.Status, _ = p["Status"].(string)
+		if ts := numberField(p, "Last Share Time"); ts > 0 {
+			pool.LastShare = time.Unix(int64(ts), 0)
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+// cgminerChains reads per-chain status, hashrate and temperatures from the devs
+// and temps commands. Temperatures are best effort.
+func cgminerChains(ctx context.Context, ip string) ([]ChainInfo, error) {
+	devs, err := cgminerCommand(ctx, ip, "devs", "")
+	if err != nil {
+		return nil, err
+	}
+	temps := map[int]map[string]interface{}{}
+	if resp, err := cgminerCommand(ctx, ip, "temps", ""); err == nil {
+		for _, t := range cgminerList(resp, "TEMPS") {
+			temps[int(numberField(t, "ID"))] = t
+		}
+	}
+
+	var chains []ChainInfo
+	for _, dev := range cgminerList(devs, "DEVS") {
+		mhs := numberField(dev, "MHS 5m")
+		if mhs == 0 {
+			mhs = numberField(dev, "MHS av")
+		}
+		chain := ChainInfo{
+			Index:      int(numberField(dev, "ID")),
+			HashrateGH: math.Round(mhs/1000*10) / 10,
+		}
+		chain.Status, _ = dev["Status"].(string)
+		if t, ok := temps[chain.Index]; ok {
+			chain.BoardTemp = numberField(t, "Board")
+			chain.ChipTemp = numberField(t, "Chip")
+		}
+		chains = append(chains, chain)
+	}
+	return chains, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chartMaxRange is the longest window the chart builder reads from the raw
+// tables; longer history goes through /api/history and its rollups.
+const chartMaxRange = 31 * 24 * time.Hour
+
+// chartMetric is a column the chart builder may plot, with the symbol
+// columns its series may be grouped by.
+type chartMetric struct {
+	Table  string
+	Column string
+	Label  string
+	Unit   string
+	Groups []string
+}
+
+// chartMetrics is the whitelist of the chart builder: only these tables and
+// columns ever reach a query.
+var chartMetrics = map[string]chartMetric{
+	"power":                {Table: "shellies", Column: "power", Label: "Outlet Power", Unit: "W", Groups: []string{"device_id"}},
+	"room_power":           {Table: "room_meter", Column: "power", Label: "Room Meter Power", Unit: "W", Groups: []string{"device_id"}},
+	"pool_hashrate":        {Table: "pools", Column: "hashrate_average", Label: "Pool Hashrate", Unit: "GH/s", Groups: []string{"miner_ip", "idx"}},
+	"miner_hashrate":       {Table: "miner_status", Column: "hashrate", Label: "Miner Hashrate", Unit: "GH/s", Groups: []string{"miner_ip", "work_mode", "status"}},
+	"miner_power":          {Table: "miner_status", Column: "power", Label: "Miner Power", Unit: "W", Groups: []string{"miner_ip", "work_mode", "status"}},
+	"miner_efficiency":     {Table: "miner_status", Column: "efficiency", Label: "Miner Efficiency", Unit: "J/TH", Groups: []string{"miner_ip", "work_mode"}},
+	"miner_temperature":    {Table: "miner_status", Column: "temperature_max", Label: "Miner Temperature", Unit: "°C", Groups: []string{"miner_ip", "work_mode"}},
+	"temperature":          {Table: "bme280_readings", Column: "temperature", Label: "Temperature", Unit: "°C", Groups: []string{"location", "device_id"}},
+	"humidity":             {Table: "bme280_readings", Column: "humidity", Label: "Humidity", Unit: "%", Groups: []string{"location", "device_id"}},
+	"pressure":             {Table: "bme280_readings", Column: "pressure", Label: "Pressure", Unit: "hPa", Groups: []string{"location", "device_id"}},
+	"coolant_temperature":  {Table: "coolant_temperatures", Column: "temperature", Label: "Coolant Temperature", Unit: "°C", Groups: []string{"loop", "sensor_id", "position"}},
+	"coolant_flow":         {Table: "coolant_flow", Column: "flow", Label: "Coolant Flow", Unit: "L/min", Groups: []string{"loop", "sensor_id"}},
+	"sound_level":          {Table: "sound_levels", Column: "db", Label: "Sound Level", Unit: "dB", Groups: []string{"location", "sensor_id"}},
+	"gpu_temperature":      {Table: "gpu_status", Column: "temperature", Label: "GPU Temperature", Unit: "°C", Groups: []string{"rig_ip", "gpu", "algorithm"}},
+	"gpu_power":            {Table: "gpu_status", Column: "power", Label: "GPU Power", Unit: "W", Groups: []string{"rig_ip", "gpu", "algorithm"}},
+	"gpu_hashrate":         {Table: "gpu_status", Column: "hashrate", Label: "GPU Hashrate", Unit: "H/s", Groups: []string{"rig_ip", "gpu", "algorithm"}},
+	"pool_shares_rejected": {Table: "miner_pools", Column: "rejected", Label: "Rejected Shares", Unit: "shares", Groups: []string{"miner_ip", "pool_url"}},
+}
+
+// getChartMetricsHandler lists the chart builder's metrics, aggregations and
+// bucket sizes for the UI.
+func getChartMetricsHandler(c *gin.Context) {
+	type metricInfo struct {
+		Key    string   `json:"key"`
+		Label  string   `json:"label"`
+		Unit   string   `json:"unit"`
+		Groups []string `json:"groups"`
+	}
+	metrics := make([]metricInfo, 0, len(chartMetrics))
+	for key, m := range chartMetrics {
+		metrics = append(metrics, metricInfo{key, m.Label, m.Unit, m.Groups})
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Key < metrics[j].Key })
+
+	aggregations := make([]string, 0, len(questdb.ChartAggregations))
+	for a := range questdb.ChartAggregations {
+		aggregations = append(aggregations, a)
+	}
+	sort.Strings(aggregations)
+
+	buckets := make([]string, len(questdb.ChartBuckets))
+	for i, b := range questdb.ChartBuckets {
+		buckets[i] = b.Name
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"metrics":      metrics,
+		"aggregations": aggregations,
+		"buckets":      buckets,
+	})
+}
+
+// getCustomChartHandler builds a chart from ?metric= (see chartMetrics),
+// ?agg= (default avg), ?groupBy= (one of the metric's groups, empty for a
+// single series), the window of ?range= or ?from=&to= and an optional
+// ?bucket=. Series are keyed by group value, or "total" without grouping.
+func getCustomChartHandler(c *gin.Context) {
+	key := c.Query("metric")
+	metric, ok := chartMetrics[key]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown metric"})
+		return
+	}
+
+	agg := c.DefaultQuery("agg", "avg")
+	if !questdb.ChartAggregations[agg] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown aggregation"})
+		return
+	}
+
+	groupBy := c.Query("groupBy")
+	if groupBy != "" {
+		allowed := false
+		for _, g := range metric.Groups {
+			allowed = allowed || g == groupBy
+		}
+		if !allowed {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "metric " + key + " can't be grouped by " + groupBy})
+			return
+		}
+	}
+
+	from, to, err := parseTimeWindow(c, requestNow(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if to.Sub(from) > chartMaxRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "range is limited to 31 days; use /api/history for longer windows"})
+		return
+	}
+
+	bucket := c.Query("bucket")
+	if bucket == "" {
+		bucket = questdb.PickChartBucket(from, to)
+	}
+	if !questdb.ValidChartBucket(bucket) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown bucket"})
+		return
+	}
+
+	result, err := questdbFor(c).GetChart(questdb.ChartQuery{
+		Table:       metric.Table,
+		Column:      metric.Column,
+		Aggregation: agg,
+		GroupBy:     groupBy,
+		From:        from,
+		To:          to,
+		Bucket:      bucket,
+	})
+	if err != nil {
+		log.Printf("Failed to get %s chart from QuestDB: %v", key, err)
+		c.JSON(http.StatusOK, gin.H{
+			"metric":  key,
+			"unit":    metric.Unit,
+			"bucket":  bucket,
+			"series":  gin.H{},
+			"hasData": false,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"metric":    key,
+		"label":     metric.Label,
+		"unit":      metric.Unit,
+		"bucket":    result.Bucket,
+		"series":    result.Series,
+		"truncated": result.Truncated,
+		"hasData":   result.HasData,
+	})
+}
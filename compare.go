@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"miningRoom/questdb"
+	"miningRoom/questdb/schema"
+
+	"github.com/gin-gonic/gin"
+)
+
+// comparePreviousPeriod is the only ?compare= value of the chart endpoints:
+// the response gets a "previous" series of the period before the chart's
+// window, with timestamps moved forward by one period so both overlay.
+const comparePreviousPeriod = "previous_period"
+
+// wantsComparison reads ?compare=. An unsupported value is answered with 400
+// and ok false.
+func wantsComparison(c *gin.Context) (compare, ok bool) {
+	switch c.Query("compare") {
+	case "":
+		return false, true
+	case comparePreviousPeriod:
+		return true, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "compare must be " + comparePreviousPeriod})
+	return false, false
+}
+
+// storeAsOf returns the request's timeseries store reading the data as it was
+// at t, for the previous period of charts with a fixed window such as today
+// or the last 24 hours.
+func storeAsOf(c *gin.Context, t time.Time) TimeseriesStore {
+	if influxClient == nil {
+		return questdbFor(c).WithAsOf(t)
+	}
+	return influxClient.WithContext(c.Request.Context()).WithAsOf(t)
+}
+
+// previousDays is the moment the given number of calendar days before the
+// request, so day-aligned windows stay aligned across DST changes.
+func previousDays(c *gin.Context, days int) time.Time {
+	return requestNow(c).AddDate(0, 0, -days)
+}
+
+// shiftTimestamp moves a timestamp of the stores forward by days calendar
+// days in --timezone (or by d when days is 0), keeping QuestDB's format so
+// shifted points line up with the current ones.
+func shiftTimestamp(ts string, days int, d time.Duration) string {
+	layout := schema.TimeFormat
+	t, err := time.Parse(layout, ts)
+	if err != nil {
+		layout = time.RFC3339Nano
+		if t, err = time.Parse(layout, ts); err != nil {
+			return ts
+		}
+	}
+	if days != 0 {
+		t = t.In(roomLocation).AddDate(0, 0, days)
+	} else {
+		t = t.Add(d)
+	}
+	return t.UTC().Format(layout)
+}
+
+// shiftPoints returns points with their timestamps shifted by shiftTimestamp.
+func shiftPoints(points []questdb.TimeSeriesPoint, days int, d time.Duration) []questdb.TimeSeriesPoint {
+	shifted := make([]questdb.TimeSeriesPoint, len(points))
+	for i, p := range points {
+		shifted[i] = questdb.TimeSeriesPoint{Timestamp: shiftTimestamp(p.Timestamp, days, d), Value: p.Value}
+	}
+	return shifted
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// dewPointMargin is the minimum spread (°C) between air temperature and dew point
+// below which condensation on miner hardware is considered likely.
+var dewPointMargin float64
+
+// dewPoint returns the dew point in °C for the given temperature (°C) and relative
+// humidity (%) using the Magnus formula.
+func dewPoint(tempC, humidity float64) float64 {
+	if humidity <= 0 {
+		return math.Inf(-1)
+	}
+	const b, c = 17.62, 243.12
+	gamma := math.Log(humidity/100) + b*tempC/(c+tempC)
+	return c * gamma / (b - gamma)
+}
+
+// CondensationStatus is the latest condensation risk assessment.
+type CondensationStatus struct {
+	Location          string     `json:"location"` // indoor location assessed
+	RoomTemp          float64    `json:"roomTemp"`
+	RoomHumidity      float64    `json:"roomHumidity"`
+	RoomDewPoint      float64    `json:"roomDewPoint"`
+	OutsideDewPoint   float64    `json:"outsideDewPoint"`
+	Spread            float64    `json:"spread"` // room temp - room dew point
+	ColdStartRisk     bool       `json:"coldStartRisk"`
+	VentilationRisk   bool       `json:"ventilationRisk"`
+	BlocksColdStart   bool       `json:"blocksColdStart"`   // risk and no override
+	BlocksVentilation bool       `json:"blocksVentilation"` // risk and no override
+	OverrideUntil     *time.Time `json:"overrideUntil,omitempty"`
+	EvaluatedAt       time.Time  `json:"evaluatedAt"`
+	HasData           bool       `json:"hasData"`
+}
+
+// condensationGuard caches the latest risk assessment and the manual override.
+type condensationGuard struct {
+	mu            sync.Mutex
+	status        CondensationStatus
+	overrideUntil time.Time
+}
+
+var condensation = &condensationGuard{}
+
+// runCondensationMonitor re-evaluates condensation risk at the given interval.
+func runCondensationMonitor(interval time.Duration) {
+	condensation.evaluate()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		condensation.evaluate()
+	}
+}
+
+func (g *condensationGuard) evaluate() {
+	env, err := questdbClient.GetLatestEnvironmentTemperatures()
+	if err != nil {
+		log.Printf("Condensation monitor: failed to get environment readings: %v", err)
+		return
+	}
+
+	status := CondensationStatus{EvaluatedAt: time.Now()}
+	room := recentEnvironmentReading(env, indoorLocations(), func(r *questdb.LatestEnvironmentReading) float64 {
+		return r.Temperature - dewPoint(r.Temperature, r.Humidity) // the smallest spread is the riskiest
+	})
+	if room != nil {
+		status.HasData = true
+		status.Location = room.Location
+		status.RoomTemp = room.Temperature
+		status.RoomHumidity = room.Humidity
+		status.RoomDewPoint = math.Round(dewPoint(room.Temperature, room.Humidity)*10) / 10
+		status.Spread = math.Round((room.Temperature-status.RoomDewPoint)*10) / 10
+		status.ColdStartRisk = status.Spread < dewPointMargin
+
+		// Bringing in outside air is risky if its dew point is close to room temperature
+		outside := recentEnvironmentReading(env, outdoorLocations(), func(r *questdb.LatestEnvironmentReading) float64 {
+			return room.Temperature - dewPoint(r.Temperature, r.Humidity)
+		})
+		if outside != nil {
+			status.OutsideDewPoint = math.Round(dewPoint(outside.Temperature, outside.Humidity)*10) / 10
+			status.VentilationRisk = room.Temperature-status.OutsideDewPoint < dewPointMargin
+		}
+	}
+
+	g.mu.Lock()
+	prev := g.status
+	g.status = status
+	g.mu.Unlock()
+
+	if status.ColdStartRisk != prev.ColdStartRisk {
+		if status.ColdStartRisk {
+			alerts.raise("condensation:cold-start", severityWarning, "condensation",
+				fmt.Sprintf("Condensation risk: dew point spread in %s is below margin, miner cold starts are paused", status.Location))
+		} else {
+			alerts.clear("condensation:cold-start")
+		}
+	}
+	if status.VentilationRisk != prev.VentilationRisk {
+		if status.VentilationRisk {
+			alerts.raise("condensation:ventilation", severityWarning, "condensation",
+				"Condensation risk: outside air dew point is close to room temperature, ventilation is blocked")
+		} else {
+			alerts.clear("condensation:ventilation")
+		}
+	}
+}
+
+// recentEnvironmentReading returns the reading of the given locations that
+// is at most 10 minutes old and has the lowest score, or nil.
+func recentEnvironmentReading(env *questdb.LatestEnvironmentData, locations []string, score func(*questdb.LatestEnvironmentReading) float64) *questdb.LatestEnvironmentReading {
+	var best *questdb.LatestEnvironmentReading
+	for _, name := range locations {
+		r := findEnvironmentReading(env, name)
+		if r == nil || !isTimestampRecent(r.Timestamp, 10*time.Minute) {
+			continue
+		}
+		if best == nil || score(r) < score(best) {
+			best = r
+		}
+	}
+	return best
+}
+
+// current returns the latest assessment with the override applied.
+func (g *condensationGuard) current() CondensationStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	status := g.status
+	overridden := time.Now().Before(g.overrideUntil)
+	if overridden {
+		until := g.overrideUntil
+		status.OverrideUntil = &until
+	}
+	status.BlocksColdStart = status.ColdStartRisk && !overridden
+	status.BlocksVentilation = status.VentilationRisk && !overridden
+	return status
+}
+
+// blocksColdStart reports whether miner starts should currently be refused.
+func (g *condensationGuard) blocksColdStart() bool {
+	return g.current().BlocksColdStart
+}
+
+// blocksVentilation reports whether fans and dampers must stay off.
+func (g *condensationGuard) blocksVentilation() bool {
+	return g.current().BlocksVentilation
+}
+
+func getCondensationHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, condensation.current())
+}
+
+type CondensationOverrideRequest struct {
+	Minutes int `json:"minutes" binding:"required"`
+}
+
+// setCondensationOverrideHandler suspends condensation protection for a while.
+func setCondensationOverrideHandler(c *gin.Context) {
+	var req CondensationOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Minutes <= 0 || req.Minutes > 24*60 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "minutes must be between 1 and 1440"})
+		return
+	}
+
+	until := time.Now().Add(time.Duration(req.Minutes) * time.Minute)
+	condensation.mu.Lock()
+	condensation.overrideUntil = until
+	condensation.mu.Unlock()
+
+	recordEvent("condensation", "protection overridden for %d minutes (from %s)", req.Minutes, c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"overrideUntil": until,
+	})
+}
+
+func clearCondensationOverrideHandler(c *gin.Context) {
+	condensation.mu.Lock()
+	condensation.overrideUntil = time.Time{}
+	condensation.mu.Unlock()
+
+	recordEvent("condensation", "override cleared (from %s)", c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
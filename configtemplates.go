@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConfigTemplateRequest saves a config template. Pools is a kaonsu pools list
+// and Fan a kaonsu fan section; leave them out to keep the miners' own.
+type ConfigTemplateRequest struct {
+	Name     string          `json:"name" binding:"required"`
+	WorkMode string          `json:"workMode"` // "Auto", "Fixed", "Sleep" or empty to keep the mode
+	Power    int             `json:"power"`
+	Freq     float64         `json:"freq"`
+	Volt     float64         `json:"volt"`
+	Pools    json.RawMessage `json:"pools"`
+	Fan      json.RawMessage `json:"fan"`
+}
+
+// ApplyTemplateRequest targets a template at machines or a group.
+type ApplyTemplateRequest struct {
+	IPs     []string `json:"ips"`
+	GroupID int64    `json:"groupId"`
+	DryRun  bool     `json:"dryRun"`
+
+	// IncludeMaintenance also targets miners in maintenance
+	IncludeMaintenance bool `json:"includeMaintenance"`
+}
+
+// TemplateJobParams are the params of a "template" job. The template is
+// copied into the job so later edits don't change a queued apply.
+type TemplateJobParams struct {
+	Template db.ConfigTemplate `json:"template"`
+	IPs      []string          `json:"ips"`
+}
+
+// templateConfig returns the part of a kaonsu miner_config that a template
+// sets.
+func templateConfig(t db.ConfigTemplate) (map[string]interface{}, error) {
+	doc := make(map[string]interface{})
+	switch t.WorkMode {
+	case "Auto":
+		doc["mode"] = map[string]interface{}{
+			"work-mode-selector": "Auto",
+			"concorde": map[string]interface{}{
+				"mode-select":  "PowerTarget",
+				"power-target": float64(t.PowerTarget),
+			},
+		}
+	case "Fixed":
+		doc["mode"] = map[string]interface{}{
+			"work-mode-selector": "Fixed",
+			"fixed":              map[string]interface{}{"freq": t.Freq, "volt": t.Volt},
+		}
+	case "Sleep":
+		doc["mode"] = map[string]interface{}{"work-mode-selector": "Sleep"}
+	}
+	for key, raw := range map[string]json.RawMessage{"pools": t.Pools, "fan": t.Fan} {
+		if len(raw) == 0 {
+			continue
+		}
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("invalid %s section: %w", key, err)
+		}
+		doc[key] = v
+	}
+	return doc, nil
+}
+
+// mergeConfig writes src into dst. Nested sections are merged key by key;
+// lists and values replace what dst holds.
+func mergeConfig(dst, src map[string]interface{}) {
+	for k, v := range src {
+		sub, ok := v.(map[string]interface{})
+		if cur, isMap := dst[k].(map[string]interface{}); ok && isMap {
+			mergeConfig(cur, sub)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// templateMismatches returns the fields set by a template that a miner's
+// config doesn't hold. Lists must match in full, sections only in the keys
+// the template sets.
+func templateMismatches(path string, want, have interface{}) []ConfigDifference {
+	section, ok := want.(map[string]interface{})
+	if !ok {
+		if reflect.DeepEqual(want, have) {
+			return nil
+		}
+		return []ConfigDifference{{Path: path, Golden: want, Value: have}}
+	}
+	current, _ := have.(map[string]interface{})
+	var diffs []ConfigDifference
+	for k, v := range section {
+		p := k
+		if path != "" {
+			p = path + "." + k
+		}
+		diffs = append(diffs, templateMismatches(p, v, current[k])...)
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Path < diffs[j].Path })
+	return diffs
+}
+
+// describeMismatches formats template fields a miner didn't take.
+func describeMismatches(diffs []ConfigDifference) string {
+	parts := make([]string, 0, len(diffs))
+	for _, d := range diffs {
+		parts = append(parts, fmt.Sprintf("%s is %v, want %v", d.Path, d.Value, d.Golden))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// applyKaonsuTemplate writes a template into a stock firmware miner's config
+// in one POST and reads the config back to verify it took.
+func applyKaonsuTemplate(ctx context.Context, ip string, t db.ConfigTemplate) error {
+	doc, err := templateConfig(t)
+	if err != nil {
+		return err
+	}
+	config, err := fetchKaonsuConfig(ctx, ip)
+	if err != nil {
+		return fmt.Errorf("failed to get config: %w", err)
+	}
+	mergeConfig(config, doc)
+
+	body, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	configURL := fmt.Sprintf("http://%s/kaonsu/v1/miner_config", urlHost(ip))
+	resp, err := doDigestPost(ctx, configURL, minerUser, minerPass, body)
+	if err != nil {
+		return fmt.Errorf("failed to post config: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("miner returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	applied, err := fetchKaonsuConfig(ctx, ip)
+	if err != nil {
+		return fmt.Errorf("failed to read back config: %w", err)
+	}
+	if diffs := templateMismatches("", doc, applied); len(diffs) > 0 {
+		return fmt.Errorf("verification failed: %s", describeMismatches(diffs))
+	}
+	return nil
+}
+
+// applyDriverTemplate sets a template's work mode through the miner's driver
+// and reads the mode back to verify it took. Pools and fan settings are only
+// written on stock firmware.
+func applyDriverTemplate(ctx context.Context, ip string, t db.ConfigTemplate) error {
+	if len(t.Pools) > 0 || len(t.Fan) > 0 {
+		return errors.New("pool and fan settings can only be applied to stock firmware")
+	}
+	d := driverFor(ip)
+	caps := d.Capabilities()
+	var err error
+	switch t.WorkMode {
+	case "Auto":
+		if !caps.SupportsPowerTarget {
+			return errors.New("firmware does not support power targets")
+		}
+		err = d.SetPowerTarget(ctx, ip, t.PowerTarget)
+	case "Fixed":
+		if !caps.SupportsFreqVolt {
+			return errors.New("firmware does not support freq/volt")
+		}
+		err = d.SetFreqVolt(ctx, ip, t.Freq, t.Volt)
+	case "Sleep":
+		if !caps.SupportsSleep {
+			return errors.New("firmware does not support sleep")
+		}
+		err = d.SetSleep(ctx, ip)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	p := planConfigChange(ctx, ip, templateModeRequest(t))
+	if !p.Reachable {
+		return fmt.Errorf("failed to read back config: %s", p.Error)
+	}
+	if p.Changes {
+		return fmt.Errorf("verification failed: miner reports %v", p.Current)
+	}
+	return nil
+}
+
+// templateModeRequest is a template's work mode in planConfigChange's keys.
+func templateModeRequest(t db.ConfigTemplate) map[string]interface{} {
+	switch t.WorkMode {
+	case "Auto":
+		return map[string]interface{}{"workMode": "Auto", "modeSelect": "PowerTarget", "targetValue": t.PowerTarget}
+	case "Fixed":
+		return map[string]interface{}{"workMode": "Fixed", "freq": t.Freq, "volt": t.Volt}
+	case "Sleep":
+		return map[string]interface{}{"workMode": "Sleep"}
+	}
+	return nil
+}
+
+// templateViolation checks a template's targets against a miner's profile.
+func templateViolation(l operatingLimits, ip string, t db.ConfigTemplate) string {
+	var violation string
+	switch t.WorkMode {
+	case "Auto":
+		_, violation = l.power(ip, t.PowerTarget)
+	case "Fixed":
+		_, _, violation = l.freqVolt(ip, t.Freq, t.Volt)
+	}
+	return violation
+}
+
+// guardTemplate returns the template with its targets limited to the miner's
+// profile, or an error when the profile refuses them.
+func guardTemplate(ip string, t db.ConfigTemplate) (db.ConfigTemplate, error) {
+	var err error
+	switch t.WorkMode {
+	case "Auto":
+		t.PowerTarget, err = guardPower(ip, t.PowerTarget)
+	case "Fixed":
+		t.Freq, t.Volt, err = guardFreqVolt(ip, t.Freq, t.Volt)
+	}
+	return t, err
+}
+
+// applyTemplate is the "template" job kind. A miner whose config doesn't read
+// back as the template fails its target.
+func applyTemplate(ctx context.Context, params json.RawMessage, ip string) error {
+	var req TemplateJobParams
+	if err := json.Unmarshal(params, &req); err != nil {
+		return err
+	}
+	t, err := guardTemplate(ip, req.Template)
+	if err != nil {
+		return err
+	}
+	if _, ok := driverFor(ip).(kaonsuDriver); ok {
+		err = applyKaonsuTemplate(ctx, ip, t)
+	} else {
+		err = applyDriverTemplate(ctx, ip, t)
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Applied template %s to miner at %s", t.Name, ip)
+	return nil
+}
+
+// planTemplate is the dry-run result of applying a template to one miner.
+func planTemplate(ctx context.Context, ip string, t db.ConfigTemplate) PlannedChange {
+	if _, ok := driverFor(ip).(kaonsuDriver); !ok {
+		p := planConfigChange(ctx, ip, templateModeRequest(t))
+		if len(t.Pools) > 0 || len(t.Fan) > 0 {
+			p.Error = "pool and fan settings can only be applied to stock firmware"
+		}
+		return p
+	}
+
+	p := PlannedChange{IP: ip, Name: minerName(ip)}
+	doc, err := templateConfig(t)
+	if err != nil {
+		p.Error = err.Error()
+		return p
+	}
+	p.Requested = doc
+	config, err := fetchKaonsuConfig(ctx, ip)
+	if err != nil {
+		p.Error = err.Error()
+		return p
+	}
+	p.Reachable = true
+	p.Current = make(map[string]interface{}, len(doc))
+	for section := range doc {
+		p.Current[section] = config[section]
+	}
+	p.Changes = len(templateMismatches("", doc, config)) > 0
+	return p
+}
+
+func getConfigTemplatesHandler(c *gin.Context) {
+	templates, err := database.FetchConfigTemplates()
+	if err != nil {
+		log.Printf("Failed to fetch config templates: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch config templates"})
+		return
+	}
+	if templates == nil {
+		templates = []db.ConfigTemplate{}
+	}
+	c.JSON(http.StatusOK, gin.H{"templates": templates})
+}
+
+func saveConfigTemplateHandler(c *gin.Context) {
+	var req ConfigTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	t := db.ConfigTemplate{Name: req.Name, WorkMode: req.WorkMode, PowerTarget: req.Power, Freq: req.Freq, Volt: req.Volt}
+	if string(req.Pools) != "null" {
+		t.Pools = req.Pools
+	}
+	if string(req.Fan) != "null" {
+		t.Fan = req.Fan
+	}
+
+	switch {
+	case req.WorkMode == "Auto" && req.Power <= 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "power required for Auto mode"})
+		return
+	case req.WorkMode == "Fixed" && (req.Freq <= 0 || req.Volt <= 0):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "freq and volt required for Fixed mode"})
+		return
+	case req.WorkMode != "" && req.WorkMode != "Auto" && req.WorkMode != "Fixed" && req.WorkMode != "Sleep":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "workMode must be Auto, Fixed, Sleep or empty"})
+		return
+	case req.WorkMode == "" && len(t.Pools) == 0 && len(t.Fan) == 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "template sets nothing: give a workMode, pools or fan"})
+		return
+	}
+	if len(t.Pools) > 0 {
+		var pools []interface{}
+		if err := json.Unmarshal(t.Pools, &pools); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pools must be a list"})
+			return
+		}
+	}
+	if len(t.Fan) > 0 {
+		var fan map[string]interface{}
+		if err := json.Unmarshal(t.Fan, &fan); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "fan must be an object"})
+			return
+		}
+	}
+
+	if err := database.SaveConfigTemplate(t); err != nil {
+		log.Printf("Failed to save config template %s: %v", req.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save config template"})
+		return
+	}
+
+	log.Printf("Saved config template %s", req.Name)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"name":    req.Name,
+	})
+}
+
+func deleteConfigTemplateHandler(c *gin.Context) {
+	name := c.Param("name")
+	found, err := database.DeleteConfigTemplate(name)
+	if err != nil {
+		log.Printf("Failed to delete config template %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete config template"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+
+	log.Printf("Deleted config template %s", name)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"name":    name,
+	})
+}
+
+// applyConfigTemplateHandler queues a "template" job that applies a saved
+// template to machines or a group; dryRun plans it without changing anything.
+func applyConfigTemplateHandler(c *gin.Context) {
+	var req ApplyTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := c.Param("name")
+	t, err := database.FetchConfigTemplate(name)
+	if err != nil {
+		log.Printf("Failed to fetch config template %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch config template"})
+		return
+	}
+	if t == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+
+	ips, err := resolveBulkIPs(req.IPs, req.GroupID, req.IncludeMaintenance)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	warnings, ok := checkLimits(c, ips, req.DryRun, func(l operatingLimits, ip string) string {
+		return templateViolation(l, ip, *t)
+	})
+	if !ok {
+		return
+	}
+
+	if req.DryRun {
+		respondDryRun(c, ips, func(ip string) PlannedChange {
+			return planTemplate(c.Request.Context(), ip, *t)
+		}, warnings...)
+		return
+	}
+
+	respondJobQueued(c, "template", TemplateJobParams{Template: *t, IPs: ips}, ips)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// doorOpenMinutes is how long a door/window may stay open at full power before
+// an alert is raised.
+var doorOpenMinutes int
+
+// fullPowerShare is the share of the last day's peak power above which the
+// miners count as running at full power.
+const fullPowerShare = 0.9
+
+// doorMonitor tracks how long each contact sensor has been open. Sensors also
+// report periodically, so the latest reading's timestamp is not the opening time.
+type doorMonitor struct {
+	mu        sync.Mutex
+	openSince map[string]time.Time
+}
+
+var doors = &doorMonitor{openSince: make(map[string]time.Time)}
+
+// runDoorMonitor checks contact sensors at the given interval.
+func runDoorMonitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		doors.check()
+	}
+}
+
+func (d *doorMonitor) check() {
+	states, err := questdbClient.GetLatestContactStates()
+	if err != nil {
+		log.Printf("Door monitor: %v", err)
+		return
+	}
+
+	fullPower, power := minersAtFullPower()
+	limit := time.Duration(doorOpenMinutes) * time.Minute
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, s := range states {
+		key := "door-open:" + s.SensorID
+		if !s.Open {
+			delete(d.openSince, s.SensorID)
+			alerts.clear(key)
+			continue
+		}
+		since, ok := d.openSince[s.SensorID]
+		if !ok {
+			since = s.Timestamp
+			d.openSince[s.SensorID] = since
+		}
+		if fullPower && time.Since(since) >= limit {
+			alerts.raise(key, severityWarning, "door", fmt.Sprintf(
+				"%s open for %s while miners run at full power (%.0f W)", s.Location, time.Since(since).Round(time.Minute), power))
+		} else {
+			alerts.clear(key)
+		}
+	}
+}
+
+// minersAtFullPower reports whether the current total power is near the peak
+// of the last 24 hours.
+func minersAtFullPower() (bool, float64) {
+	current, err := tsStore.GetTotalPower()
+	if err != nil || !current.HasData {
+		return false, 0
+	}
+	series, err := tsStore.GetPowerTimeSeries()
+	if err != nil || !series.HasData {
+		return false, current.TotalPower
+	}
+	peak := 0.0
+	for _, p := range series.Points {
+		if p.Value > peak {
+			peak = p.Value
+		}
+	}
+	return peak > 0 && current.TotalPower >= peak*fullPowerShare, current.TotalPower
+}
+
+func getContactsHandler(c *gin.Context) {
+	states, err := questdbFor(c).GetLatestContactStates()
+	if err != nil {
+		log.Printf("Failed to get contact sensors: %v", err)
+		c.JSON(http.StatusOK, gin.H{"hasData": false})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"contacts": states,
+		"hasData":  len(states) > 0,
+	})
+}
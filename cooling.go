@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+func getCoolingLatestHandler(c *gin.Context) {
+	result, err := questdbFor(c).GetLatestCoolantReadings()
+	if err != nil {
+		log.Printf("Failed to get latest coolant readings from QuestDB: %v", err)
+		c.JSON(http.StatusOK, gin.H{
+			"temperatures": []interface{}{},
+			"flows":        []interface{}{},
+			"hasData":      false,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func getCoolantTemperatureChartHandler(c *gin.Context) {
+	result, err := questdbFor(c).GetCoolantTemperatureTimeSeries()
+	if err != nil {
+		log.Printf("Failed to get coolant temperatures from QuestDB: %v", err)
+		c.JSON(http.StatusOK, gin.H{
+			"sensors": map[string][]interface{}{},
+			"hasData": false,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func getCoolantFlowChartHandler(c *gin.Context) {
+	result, err := questdbFor(c).GetCoolantFlowTimeSeries()
+	if err != nil {
+		log.Printf("Failed to get coolant flow from QuestDB: %v", err)
+		c.JSON(http.StatusOK, gin.H{
+			"sensors": map[string][]interface{}{},
+			"hasData": false,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func getCoolingLoopsHandler(c *gin.Context) {
+	loops, err := database.FetchCoolingLoops()
+	if err != nil {
+		log.Printf("Failed to fetch cooling loops: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cooling loops"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"loops": loops})
+}
+
+type CoolingLoopRequest struct {
+	Name           string   `json:"name" binding:"required"`
+	MachineIPs     []string `json:"machineIps"`
+	MinFlowLPM     float64  `json:"minFlowLpm"`
+	MaxCoolantTemp float64  `json:"maxCoolantTemp"`
+}
+
+func saveCoolingLoopHandler(c *gin.Context) {
+	var req CoolingLoopRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	loop := db.CoolingLoop{
+		Name:           req.Name,
+		MachineIPs:     req.MachineIPs,
+		MinFlowLPM:     req.MinFlowLPM,
+		MaxCoolantTemp: req.MaxCoolantTemp,
+	}
+	if err := database.SaveCoolingLoop(loop); err != nil {
+		log.Printf("Failed to save cooling loop %s: %v", req.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save cooling loop"})
+		return
+	}
+
+	log.Printf("Saved cooling loop %s", req.Name)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"name":    req.Name,
+	})
+}
+
+func deleteCoolingLoopHandler(c *gin.Context) {
+	name := c.Param("name")
+	if err := database.DeleteCoolingLoop(name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete cooling loop"})
+		return
+	}
+
+	log.Printf("Deleted cooling loop %s", name)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"name":    name,
+	})
+}
+
+// runCoolingMonitor periodically checks the latest coolant readings against the
+// per-loop thresholds and raises or clears alerts.
+func runCoolingMonitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		checkCoolingLoops()
+	}
+}
+
+func checkCoolingLoops() {
+	loops, err := database.FetchCoolingLoops()
+	if err != nil {
+		log.Printf("Cooling monitor: failed to fetch loops: %v", err)
+		return
+	}
+	if len(loops) == 0 {
+		return
+	}
+
+	readings, err := questdbClient.GetLatestCoolantReadings()
+	if err != nil {
+		log.Printf("Cooling monitor: failed to get readings: %v", err)
+		return
+	}
+
+	for _, loop := range loops {
+		// Loops whose miners are all in maintenance may be drained or stopped
+		suppressed := allInMaintenance(loop.MachineIPs)
+		for _, r := range readings.Flows {
+			if r.Loop != loop.Name {
+				continue
+			}
+			key := fmt.Sprintf("coolant-flow:%s/%s", r.Loop, r.SensorID)
+			if !suppressed && loop.MinFlowLPM > 0 && r.Value < loop.MinFlowLPM {
+				alerts.raise(key, severityCritical, "cooling",
+					fmt.Sprintf("Low coolant flow in %s (%s): %.1f L/min < %.1f L/min", r.Loop, r.SensorID, r.Value, loop.MinFlowLPM))
+			} else {
+				alerts.clear(key)
+			}
+		}
+		for _, r := range readings.Temperatures {
+			if r.Loop != loop.Name {
+				continue
+			}
+			key := fmt.Sprintf("coolant-temp:%s/%s", r.Loop, r.SensorID)
+			if !suppressed && loop.MaxCoolantTemp > 0 && r.Value > loop.MaxCoolantTemp {
+				alerts.raise(key, severityCritical, "cooling",
+					fmt.Sprintf("High coolant temperature in %s (%s): %.1f °C > %.1f °C", r.Loop, r.SensorID, r.Value, loop.MaxCoolantTemp))
+			} else {
+				alerts.clear(key)
+			}
+		}
+	}
+}
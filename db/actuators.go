@@ -0,0 +1,57 @@
+package db
+
+// Actuator is a non-miner Shelly-controlled device such as an exhaust fan, intake
+// damper or heater. When Auto is set, the thermal controller switches it based on
+// the latest Metric ("temperature" or "humidity") reading at Location.
+//
+// If OnThreshold > OffThreshold the device turns on at or above OnThreshold and off
+// at or below OffThreshold (fan). If OnThreshold < OffThreshold the logic is
+// inverted (heater).
+type Actuator struct {
+	ID           int64   `json:"id"`
+	Name         string  `json:"name"`
+	Kind         string  `json:"kind"` // "fan", "damper", "heater", ...
+	ShellyIP     string  `json:"shellyIp"`
+	Location     string  `json:"location"`
+	Metric       string  `json:"metric"`
+	OnThreshold  float64 `json:"onThreshold"`
+	OffThreshold float64 `json:"offThreshold"`
+	Auto         bool    `json:"auto"`
+}
+
+func (d *DB) FetchActuators() ([]Actuator, error) {
+	rows, err := d.conn.Query("SELECT id, name, kind, shelly_ip, location, metric, on_threshold, off_threshold, auto FROM actuators ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var actuators []Actuator
+	for rows.Next() {
+		var a Actuator
+		if err := rows.Scan(&a.ID, &a.Name, &a.Kind, &a.ShellyIP, &a.Location, &a.Metric, &a.OnThreshold, &a.OffThreshold, &a.Auto); err != nil {
+			return nil, err
+		}
+		actuators = append(actuators, a)
+	}
+	return actuators, rows.Err()
+}
+
+// SaveActuator inserts an actuator or, if one with the same name exists, updates it.
+func (d *DB) SaveActuator(a Actuator) error {
+	_, err := d.conn.Exec(`INSERT INTO actuators (name, kind, shelly_ip, location, metric, on_threshold, off_threshold, auto) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET kind = excluded.kind, shelly_ip = excluded.shelly_ip, location = excluded.location,
+			metric = excluded.metric, on_threshold = excluded.on_threshold, off_threshold = excluded.off_threshold, auto = excluded.auto`,
+		a.Name, a.Kind, a.ShellyIP, a.Location, a.Metric, a.OnThreshold, a.OffThreshold, a.Auto)
+	return err
+}
+
+func (d *DB) SetActuatorAuto(name string, auto bool) error {
+	_, err := d.conn.Exec("UPDATE actuators SET auto = ? WHERE name = ?", auto, name)
+	return err
+}
+
+func (d *DB) DeleteActuator(name string) error {
+	_, err := d.conn.Exec("DELETE FROM actuators WHERE name = ?", name)
+	return err
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFetchAuditBounds(t *testing.T) {
+	d := openTestDB(t)
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// An entry written before the fixed-width format, whose whole second
+	// sorts after its fractions as text
+	if _, err := d.conn.Exec(`INSERT INTO audit_log (created_at, actor, method, path, body_sha256, status, prev_hash, hash)
+		VALUES (?, 'legacy', 'POST', '/api/x', '', 200, '', '')`, t0.Format(time.RFC3339Nano)); err != nil {
+		t.Fatal(err)
+	}
+	for _, offset := range []time.Duration{500 * time.Millisecond, time.Second, 1250 * time.Millisecond, 10 * time.Second} {
+		if err := d.AppendAudit(AuditEntry{CreatedAt: t0.Add(offset), Actor: offset.String(), Method: "POST", Path: "/api/x", Status: 200}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := d.FetchAudit(t0.Add(time.Millisecond), t0.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if broken := VerifyAuditChain(entries); broken != 0 {
+		t.Fatalf("chain broken at entry %d after reading back", broken)
+	}
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     []string
+	}{
+		{"everything", t0.Add(-time.Hour), t0.Add(time.Hour), []string{"legacy", "500ms", "1s", "1.25s", "10s"}},
+		{"from a fraction", t0.Add(500 * time.Millisecond), t0.Add(time.Second), []string{"500ms"}},
+		{"legacy entry at the lower bound", t0, t0.Add(400 * time.Millisecond), []string{"legacy"}},
+		{"legacy entry before a fractional bound", t0.Add(100 * time.Millisecond), t0.Add(2 * time.Second), []string{"500ms", "1s", "1.25s"}},
+		{"to excludes its own instant", t0.Add(time.Second), t0.Add(1250 * time.Millisecond), []string{"1s"}},
+		{"to with fewer fraction digits", t0.Add(time.Second), t0.Add(1300 * time.Millisecond), []string{"1s", "1.25s"}},
+		{"none", t0.Add(2 * time.Second), t0.Add(9 * time.Second), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := d.FetchAudit(tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Actor)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("entries = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("entries = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
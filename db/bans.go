@@ -0,0 +1,77 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Ban scopes: a ban blocks either every request or only logins.
+const (
+	BanScopeAll   = "all"
+	BanScopeLogin = "login"
+)
+
+// IPBan blocks a client IP until Until, or for good if Until is nil.
+type IPBan struct {
+	IP        string     `json:"ip"`
+	Scope     string     `json:"scope"`
+	Reason    string     `json:"reason"`
+	CreatedAt time.Time  `json:"createdAt"`
+	Until     *time.Time `json:"until,omitempty"`
+}
+
+// Active reports whether the ban is in force at t.
+func (b IPBan) Active(t time.Time) bool {
+	return b.Until == nil || t.Before(*b.Until)
+}
+
+// FetchIPBans returns the bans that have not expired.
+func (d *DB) FetchIPBans() ([]IPBan, error) {
+	rows, err := d.conn.Query("SELECT ip, scope, reason, created_at, until FROM ip_bans WHERE until IS NULL OR until > ? ORDER BY created_at",
+		time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bans []IPBan
+	for rows.Next() {
+		var b IPBan
+		var until sql.NullTime
+		if err := rows.Scan(&b.IP, &b.Scope, &b.Reason, &b.CreatedAt, &until); err != nil {
+			return nil, err
+		}
+		if until.Valid {
+			b.Until = &until.Time
+		}
+		bans = append(bans, b)
+	}
+	return bans, rows.Err()
+}
+
+// SaveIPBan inserts a ban or replaces the one on the same IP, dropping
+// expired bans.
+func (d *DB) SaveIPBan(b IPBan) error {
+	if _, err := d.conn.Exec("DELETE FROM ip_bans WHERE until IS NOT NULL AND until <= ?", time.Now().UTC()); err != nil {
+		return err
+	}
+	var until interface{}
+	if b.Until != nil {
+		until = b.Until.UTC()
+	}
+	_, err := d.conn.Exec(`INSERT INTO ip_bans (ip, scope, reason, created_at, until) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(ip) DO UPDATE SET scope = excluded.scope, reason = excluded.reason,
+			created_at = excluded.created_at, until = excluded.until`,
+		b.IP, b.Scope, b.Reason, b.CreatedAt.UTC(), until)
+	return err
+}
+
+// DeleteIPBan lifts the ban on an IP, reporting whether there was one.
+func (d *DB) DeleteIPBan(ip string) (bool, error) {
+	res, err := d.conn.Exec("DELETE FROM ip_bans WHERE ip = ?", ip)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
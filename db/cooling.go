@@ -0,0 +1,57 @@
+package db
+
+import "strings"
+
+// CoolingLoop is a liquid cooling circuit (immersion tank or water loop) with its
+// alert thresholds. A zero threshold disables that alert.
+type CoolingLoop struct {
+	ID             int64    `json:"id"`
+	Name           string   `json:"name"`
+	MachineIPs     []string `json:"machineIps"`
+	MinFlowLPM     float64  `json:"minFlowLpm"`
+	MaxCoolantTemp float64  `json:"maxCoolantTemp"`
+}
+
+func (d *DB) FetchCoolingLoops() ([]CoolingLoop, error) {
+	rows, err := d.conn.Query("SELECT id, name, machine_ips, min_flow_lpm, max_coolant_temp FROM cooling_loops ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var loops []CoolingLoop
+	for rows.Next() {
+		var l CoolingLoop
+		var ips string
+		if err := rows.Scan(&l.ID, &l.Name, &ips, &l.MinFlowLPM, &l.MaxCoolantTemp); err != nil {
+			return nil, err
+		}
+		l.MachineIPs = splitList(ips)
+		loops = append(loops, l)
+	}
+	return loops, rows.Err()
+}
+
+// SaveCoolingLoop inserts a loop or, if one with the same name exists, updates it.
+func (d *DB) SaveCoolingLoop(l CoolingLoop) error {
+	_, err := d.conn.Exec(`INSERT INTO cooling_loops (name, machine_ips, min_flow_lpm, max_coolant_temp) VALUES (?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET machine_ips = excluded.machine_ips, min_flow_lpm = excluded.min_flow_lpm, max_coolant_temp = excluded.max_coolant_temp`,
+		l.Name, strings.Join(l.MachineIPs, ","), l.MinFlowLPM, l.MaxCoolantTemp)
+	return err
+}
+
+func (d *DB) DeleteCoolingLoop(name string) error {
+	_, err := d.conn.Exec("DELETE FROM cooling_loops WHERE name = ?", name)
+	return err
+}
+
+// splitList splits a comma-separated column value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
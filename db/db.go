@@ -2,6 +2,9 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -10,6 +13,39 @@ type Machine struct {
 	Name     string
 	IP       string
 	ShellyIP string
+	// Outlet is the URL of a non-Shelly outlet controller powering the
+	// machine, e.g. snmp://private@10.0.0.50/3; empty uses ShellyIP.
+	Outlet   string
+	Firmware string // selects the miner driver, e.g. "kaonsu" or "braiins"
+	// MAC is the stable identity of the miner; IP follows it under DHCP.
+	// Empty until captured from the ARP table.
+	MAC string
+	// Owner is the hosting customer the machine is billed to; empty for the
+	// room's own machines.
+	Owner string
+
+	// Model, FirmwareVersion and NominalHashrate (GH/s) are probed from the
+	// miner when it is added; empty until it answered.
+	Model           string
+	FirmwareVersion string
+	NominalHashrate float64
+
+	// Maintenance is set while the miner is being worked on; MaintenanceUntil
+	// is nil when it lasts until cleared.
+	Maintenance       bool
+	MaintenanceReason string
+	MaintenanceSince  time.Time
+	MaintenanceUntil  *time.Time
+
+	// Rack, Row and Slot place the machine in the room for the layout
+	// heatmap; Rack is empty while unplaced. Row and Slot count from 1.
+	Rack string
+	Row  int
+	Slot int
+
+	// WarrantyUntil is the end of the manufacturer's warranty, nil when
+	// unknown.
+	WarrantyUntil *time.Time
 }
 
 type DB struct {
@@ -28,26 +64,314 @@ func (d *DB) Close() error {
 	return d.conn.Close()
 }
 
+// Ping checks that the database file is reachable.
+func (d *DB) Ping() error {
+	return d.conn.Ping()
+}
+
+// schema lists the CREATE statements applied on startup, in order.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS machines (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		ip TEXT NOT NULL,
+		shelly_ip TEXT NOT NULL DEFAULT '',
+		firmware TEXT NOT NULL DEFAULT 'kaonsu'
+	)`,
+	`CREATE TABLE IF NOT EXISTS cooling_loops (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		machine_ips TEXT NOT NULL DEFAULT '',
+		min_flow_lpm REAL NOT NULL DEFAULT 0,
+		max_coolant_temp REAL NOT NULL DEFAULT 0
+	)`,
+	`CREATE TABLE IF NOT EXISTS actuators (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		kind TEXT NOT NULL DEFAULT '',
+		shelly_ip TEXT NOT NULL,
+		location TEXT NOT NULL DEFAULT '',
+		metric TEXT NOT NULL DEFAULT '',
+		on_threshold REAL NOT NULL DEFAULT 0,
+		off_threshold REAL NOT NULL DEFAULT 0,
+		auto INTEGER NOT NULL DEFAULT 0
+	)`,
+	`CREATE TABLE IF NOT EXISTS events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		source TEXT NOT NULL,
+		message TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS miner_groups (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE
+	)`,
+	`CREATE TABLE IF NOT EXISTS miner_group_members (
+		group_id INTEGER NOT NULL,
+		machine_ip TEXT NOT NULL,
+		PRIMARY KEY (group_id, machine_ip)
+	)`,
+	`CREATE TABLE IF NOT EXISTS desired_states (
+		machine_ip TEXT PRIMARY KEY,
+		work_mode TEXT NOT NULL,
+		power_target INTEGER NOT NULL DEFAULT 0,
+		freq REAL NOT NULL DEFAULT 0,
+		volt REAL NOT NULL DEFAULT 0,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS totp_secret (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		secret TEXT NOT NULL,
+		confirmed INTEGER NOT NULL DEFAULT 0
+	)`,
+	`CREATE TABLE IF NOT EXISTS totp_recovery_codes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		code_hash TEXT NOT NULL,
+		used_at DATETIME
+	)`,
+	`CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at TEXT NOT NULL,
+		actor TEXT NOT NULL,
+		method TEXT NOT NULL,
+		path TEXT NOT NULL,
+		body_sha256 TEXT NOT NULL,
+		status INTEGER NOT NULL,
+		prev_hash TEXT NOT NULL,
+		hash TEXT NOT NULL
+	)`,
+	`CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+		BEGIN SELECT RAISE(ABORT, 'audit log is append-only'); END`,
+	`CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+		BEGIN SELECT RAISE(ABORT, 'audit log is append-only'); END`,
+	`CREATE TABLE IF NOT EXISTS audit_key (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		seed BLOB NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS report_runs (
+		period TEXT PRIMARY KEY,
+		last_sent DATETIME NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS machine_ssh (
+		machine_ip TEXT PRIMARY KEY,
+		user TEXT NOT NULL DEFAULT '',
+		port INTEGER NOT NULL DEFAULT 0,
+		password TEXT NOT NULL DEFAULT '',
+		private_key TEXT NOT NULL DEFAULT '',
+		host_key TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE TABLE IF NOT EXISTS jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		kind TEXT NOT NULL,
+		params TEXT NOT NULL,
+		status TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		started_at DATETIME,
+		finished_at DATETIME
+	)`,
+	`CREATE TABLE IF NOT EXISTS job_targets (
+		job_id INTEGER NOT NULL REFERENCES jobs(id),
+		ip TEXT NOT NULL,
+		status TEXT NOT NULL,
+		error TEXT NOT NULL DEFAULT '',
+		finished_at DATETIME,
+		PRIMARY KEY (job_id, ip)
+	)`,
+	`CREATE TABLE IF NOT EXISTS emergency_lockout (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		active INTEGER NOT NULL DEFAULT 0,
+		source TEXT NOT NULL DEFAULT '',
+		reason TEXT NOT NULL DEFAULT '',
+		triggered_at DATETIME NOT NULL,
+		cleared_at DATETIME,
+		cleared_by TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE TABLE IF NOT EXISTS notify_channels (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		kind TEXT NOT NULL,
+		target TEXT NOT NULL,
+		token TEXT NOT NULL DEFAULT '',
+		min_severity TEXT NOT NULL DEFAULT 'warning',
+		sources TEXT NOT NULL DEFAULT '',
+		send_resolved INTEGER NOT NULL DEFAULT 0,
+		enabled INTEGER NOT NULL DEFAULT 1
+	)`,
+	`CREATE TABLE IF NOT EXISTS inrush_ramps (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ip TEXT NOT NULL,
+		started_at DATETIME NOT NULL,
+		peak_w REAL NOT NULL,
+		samples TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS gauges (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		position INTEGER NOT NULL DEFAULT 0,
+		metric TEXT NOT NULL,
+		label TEXT NOT NULL DEFAULT '',
+		unit TEXT NOT NULL DEFAULT '',
+		decimals INTEGER NOT NULL DEFAULT 0,
+		color TEXT NOT NULL DEFAULT 'primary',
+		warn_at REAL NOT NULL DEFAULT 0,
+		critical_at REAL NOT NULL DEFAULT 0
+	)`,
+	`CREATE TABLE IF NOT EXISTS sensor_locations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		label TEXT NOT NULL DEFAULT '',
+		outdoor INTEGER NOT NULL DEFAULT 0,
+		report_interval_seconds INTEGER NOT NULL DEFAULT 0
+	)`,
+	`CREATE TABLE IF NOT EXISTS hosting_owners (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		elec_price REAL NOT NULL DEFAULT 0,
+		hosting_fee_eur REAL NOT NULL DEFAULT 0,
+		email TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE TABLE IF NOT EXISTS share_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		token TEXT NOT NULL UNIQUE,
+		label TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		last_used_at DATETIME
+	)`,
+	`CREATE TABLE IF NOT EXISTS ip_bans (
+		ip TEXT PRIMARY KEY,
+		scope TEXT NOT NULL DEFAULT 'all',
+		reason TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		until DATETIME
+	)`,
+	`CREATE TABLE IF NOT EXISTS owner_sessions (
+		token_hash TEXT PRIMARY KEY,
+		owner TEXT NOT NULL,
+		expires_at DATETIME NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS utility_intervals (
+		start DATETIME PRIMARY KEY,
+		kwh REAL NOT NULL,
+		imported_at DATETIME NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS snapshots (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		label TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		document TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS config_templates (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		work_mode TEXT NOT NULL DEFAULT '',
+		power_target INTEGER NOT NULL DEFAULT 0,
+		freq REAL NOT NULL DEFAULT 0,
+		volt REAL NOT NULL DEFAULT 0,
+		pools TEXT NOT NULL DEFAULT '',
+		fan TEXT NOT NULL DEFAULT '',
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS model_profiles (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		model TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		min_freq REAL NOT NULL DEFAULT 0,
+		max_freq REAL NOT NULL DEFAULT 0,
+		min_volt REAL NOT NULL DEFAULT 0,
+		max_volt REAL NOT NULL DEFAULT 0,
+		min_power INTEGER NOT NULL DEFAULT 0,
+		max_power INTEGER NOT NULL DEFAULT 0
+	)`,
+	`CREATE TABLE IF NOT EXISTS tuning_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		machine_ip TEXT NOT NULL,
+		job_id INTEGER NOT NULL DEFAULT 0,
+		status TEXT NOT NULL,
+		params TEXT NOT NULL,
+		points TEXT NOT NULL DEFAULT '[]',
+		best TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		finished_at DATETIME
+	)`,
+	`CREATE TABLE IF NOT EXISTS machine_baselines (
+		machine_ip TEXT PRIMARY KEY,
+		freq REAL NOT NULL,
+		volt REAL NOT NULL,
+		hashrate REAL NOT NULL,
+		power REAL NOT NULL DEFAULT 0,
+		efficiency REAL NOT NULL DEFAULT 0,
+		run_id INTEGER NOT NULL,
+		measured_at DATETIME NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS scenarios (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		power_percent INTEGER NOT NULL DEFAULT 0,
+		template TEXT NOT NULL DEFAULT '',
+		actuators TEXT NOT NULL DEFAULT '{}',
+		quiet_hours TEXT NOT NULL DEFAULT '',
+		alert_severity TEXT NOT NULL DEFAULT '',
+		away INTEGER NOT NULL DEFAULT 0,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS maintenance_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ip TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		note TEXT NOT NULL DEFAULT '',
+		performed_at DATETIME NOT NULL,
+		remind_after_hours REAL NOT NULL DEFAULT 0
+	)`,
+	`CREATE TABLE IF NOT EXISTS active_scenario (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		name TEXT NOT NULL,
+		activated_by TEXT NOT NULL DEFAULT '',
+		activated_at DATETIME NOT NULL
+	)`,
+}
+
+// migrations lists column additions for existing databases. Errors are ignored
+// because SQLite has no ADD COLUMN IF NOT EXISTS.
+var migrations = []string{
+	"ALTER TABLE machines ADD COLUMN shelly_ip TEXT NOT NULL DEFAULT ''",
+	"ALTER TABLE machines ADD COLUMN firmware TEXT NOT NULL DEFAULT 'kaonsu'",
+	"ALTER TABLE machines ADD COLUMN maintenance INTEGER NOT NULL DEFAULT 0",
+	"ALTER TABLE machines ADD COLUMN maintenance_reason TEXT NOT NULL DEFAULT ''",
+	"ALTER TABLE machines ADD COLUMN maintenance_since DATETIME",
+	"ALTER TABLE machines ADD COLUMN maintenance_until DATETIME",
+	"ALTER TABLE job_targets ADD COLUMN previous TEXT NOT NULL DEFAULT ''",
+	"ALTER TABLE jobs ADD COLUMN undone_by INTEGER NOT NULL DEFAULT 0",
+	"ALTER TABLE machines ADD COLUMN mac TEXT NOT NULL DEFAULT ''",
+	"ALTER TABLE machines ADD COLUMN outlet TEXT NOT NULL DEFAULT ''",
+	"ALTER TABLE machines ADD COLUMN owner TEXT NOT NULL DEFAULT ''",
+	"ALTER TABLE hosting_owners ADD COLUMN password_hash TEXT NOT NULL DEFAULT ''",
+	"ALTER TABLE machines ADD COLUMN model TEXT NOT NULL DEFAULT ''",
+	"ALTER TABLE machines ADD COLUMN firmware_version TEXT NOT NULL DEFAULT ''",
+	"ALTER TABLE machines ADD COLUMN nominal_hashrate REAL NOT NULL DEFAULT 0",
+	"ALTER TABLE scenarios ADD COLUMN away INTEGER NOT NULL DEFAULT 0",
+	"ALTER TABLE machines ADD COLUMN rack TEXT NOT NULL DEFAULT ''",
+	"ALTER TABLE machines ADD COLUMN rack_row INTEGER NOT NULL DEFAULT 0",
+	"ALTER TABLE machines ADD COLUMN rack_slot INTEGER NOT NULL DEFAULT 0",
+	"ALTER TABLE sensor_locations ADD COLUMN airflow TEXT NOT NULL DEFAULT ''",
+	"ALTER TABLE machines ADD COLUMN warranty_until DATETIME",
+	"ALTER TABLE totp_secret ADD COLUMN pending TEXT NOT NULL DEFAULT ''",
+}
+
 func (d *DB) EnsureSchema() error {
-	_, err := d.conn.Exec(`
-		CREATE TABLE IF NOT EXISTS machines (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			ip TEXT NOT NULL,
-			shelly_ip TEXT NOT NULL DEFAULT ''
-		)
-	`)
-	if err != nil {
-		return err
+	for _, stmt := range schema {
+		if _, err := d.conn.Exec(stmt); err != nil {
+			return err
+		}
 	}
 
-	// Migration: add shelly_ip column if it doesn't exist (for existing databases)
-	d.conn.Exec("ALTER TABLE machines ADD COLUMN shelly_ip TEXT NOT NULL DEFAULT ''")
+	for _, stmt := range migrations {
+		d.conn.Exec(stmt)
+	}
 	return nil
 }
 
 func (d *DB) FetchMachines() ([]Machine, error) {
-	rows, err := d.conn.Query("SELECT name, ip, shelly_ip FROM machines ORDER BY name")
+	rows, err := d.conn.Query("SELECT name, ip, mac, shelly_ip, outlet, firmware, owner, model, firmware_version, nominal_hashrate, maintenance, maintenance_reason, maintenance_since, maintenance_until, rack, rack_row, rack_slot, warranty_until FROM machines ORDER BY name")
 	if err != nil {
 		return nil, err
 	}
@@ -56,16 +380,24 @@ func (d *DB) FetchMachines() ([]Machine, error) {
 	var machines []Machine
 	for rows.Next() {
 		var m Machine
-		if err := rows.Scan(&m.Name, &m.IP, &m.ShellyIP); err != nil {
+		var since, until, warranty sql.NullTime
+		if err := rows.Scan(&m.Name, &m.IP, &m.MAC, &m.ShellyIP, &m.Outlet, &m.Firmware, &m.Owner, &m.Model, &m.FirmwareVersion, &m.NominalHashrate, &m.Maintenance, &m.MaintenanceReason, &since, &until, &m.Rack, &m.Row, &m.Slot, &warranty); err != nil {
 			return nil, err
 		}
+		m.MaintenanceSince = since.Time
+		if until.Valid {
+			m.MaintenanceUntil = &until.Time
+		}
+		if warranty.Valid {
+			m.WarrantyUntil = &warranty.Time
+		}
 		machines = append(machines, m)
 	}
 	return machines, rows.Err()
 }
 
-func (d *DB) AddMachine(name, ip, shellyIP string) error {
-	_, err := d.conn.Exec("INSERT INTO machines (name, ip, shelly_ip) VALUES (?, ?, ?)", name, ip, shellyIP)
+func (d *DB) AddMachine(name, ip, shellyIP, firmware string) error {
+	_, err := d.conn.Exec("INSERT INTO machines (name, ip, shelly_ip, firmware) VALUES (?, ?, ?, ?)", name, ip, shellyIP, firmware)
 	return err
 }
 
@@ -74,7 +406,142 @@ func (d *DB) UpdateMachineShellyIP(ip, shellyIP string) error {
 	return err
 }
 
-func (d *DB) DeleteMachine(ip string) error {
-	_, err := d.conn.Exec("DELETE FROM machines WHERE ip = ?", ip)
+func (d *DB) SetMachineMAC(ip, mac string) error {
+	_, err := d.conn.Exec("UPDATE machines SET mac = ? WHERE ip = ?", mac, ip)
 	return err
 }
+
+// SetMachineIdentity stores what a miner reported about itself.
+func (d *DB) SetMachineIdentity(ip, model, firmwareVersion string, nominalHashrate float64) error {
+	_, err := d.conn.Exec("UPDATE machines SET model = ?, firmware_version = ?, nominal_hashrate = ? WHERE ip = ?", model, firmwareVersion, nominalHashrate, ip)
+	return err
+}
+
+// MoveMachineIP changes a machine's IP and every setting stored under the old
+// IP: group memberships, cooling loops, desired state and SSH credentials.
+func (d *DB) MoveMachineIP(oldIP, newIP string) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var taken int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM machines WHERE ip = ?", newIP).Scan(&taken); err != nil {
+		return err
+	}
+	if taken > 0 {
+		return fmt.Errorf("another machine already uses %s", newIP)
+	}
+
+	for _, stmt := range []string{
+		"UPDATE machines SET ip = ? WHERE ip = ?",
+		"UPDATE miner_group_members SET machine_ip = ? WHERE machine_ip = ?",
+		"UPDATE desired_states SET machine_ip = ? WHERE machine_ip = ?",
+		"UPDATE machine_ssh SET machine_ip = ? WHERE machine_ip = ?",
+		"UPDATE machine_baselines SET machine_ip = ? WHERE machine_ip = ?",
+		"UPDATE tuning_runs SET machine_ip = ? WHERE machine_ip = ?",
+		"UPDATE maintenance_log SET ip = ? WHERE ip = ?",
+	} {
+		if _, err := tx.Exec(stmt, newIP, oldIP); err != nil {
+			return err
+		}
+	}
+
+	rows, err := tx.Query("SELECT id, machine_ips FROM cooling_loops")
+	if err != nil {
+		return err
+	}
+	loops := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var ips string
+		if err := rows.Scan(&id, &ips); err != nil {
+			rows.Close()
+			return err
+		}
+		list := splitList(ips)
+		moved := false
+		for i, ip := range list {
+			if ip == oldIP {
+				list[i], moved = newIP, true
+			}
+		}
+		if moved {
+			loops[id] = strings.Join(list, ",")
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, ips := range loops {
+		if _, err := tx.Exec("UPDATE cooling_loops SET machine_ips = ? WHERE id = ?", ips, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (d *DB) UpdateMachineOutlet(ip, outlet string) error {
+	_, err := d.conn.Exec("UPDATE machines SET outlet = ? WHERE ip = ?", outlet, ip)
+	return err
+}
+
+func (d *DB) UpdateMachineOwner(ip, owner string) error {
+	_, err := d.conn.Exec("UPDATE machines SET owner = ? WHERE ip = ?", owner, ip)
+	return err
+}
+
+// SetMachinePosition places a machine in a rack slot; an empty rack unplaces
+// it.
+func (d *DB) SetMachinePosition(ip, rack string, row, slot int) error {
+	_, err := d.conn.Exec("UPDATE machines SET rack = ?, rack_row = ?, rack_slot = ? WHERE ip = ?", rack, row, slot, ip)
+	return err
+}
+
+func (d *DB) UpdateMachineFirmware(ip, firmware string) error {
+	_, err := d.conn.Exec("UPDATE machines SET firmware = ? WHERE ip = ?", firmware, ip)
+	return err
+}
+
+// SetMachineMaintenance puts a machine into maintenance until the given time,
+// or until cleared if until is nil.
+func (d *DB) SetMachineMaintenance(ip, reason string, until *time.Time) error {
+	var u interface{}
+	if until != nil {
+		u = until.UTC()
+	}
+	_, err := d.conn.Exec("UPDATE machines SET maintenance = 1, maintenance_reason = ?, maintenance_since = ?, maintenance_until = ? WHERE ip = ?",
+		reason, time.Now().UTC(), u, ip)
+	return err
+}
+
+func (d *DB) ClearMachineMaintenance(ip string) error {
+	_, err := d.conn.Exec("UPDATE machines SET maintenance = 0, maintenance_reason = '', maintenance_since = NULL, maintenance_until = NULL WHERE ip = ?", ip)
+	return err
+}
+
+// DeleteMachine removes a machine with the settings stored under its IP:
+// group memberships, desired state, SSH credentials and power baseline. Its
+// maintenance log and tuning runs are kept as history.
+func (d *DB) DeleteMachine(ip string) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		"DELETE FROM machines WHERE ip = ?",
+		"DELETE FROM miner_group_members WHERE machine_ip = ?",
+		"DELETE FROM desired_states WHERE machine_ip = ?",
+		"DELETE FROM machine_ssh WHERE machine_ip = ?",
+		"DELETE FROM machine_baselines WHERE machine_ip = ?",
+	} {
+		if _, err := tx.Exec(stmt, ip); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,48 @@
+package db
+
+import "testing"
+
+func TestDeleteMachineRemovesItsSettings(t *testing.T) {
+	d := openTestDB(t)
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		if err := d.AddMachine("m-"+ip, ip, "", "kaonsu"); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.SaveDesiredState(DesiredState{MachineIP: ip, WorkMode: "Sleep"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.SaveSSHCredentials(SSHCredentials{MachineIP: ip, User: "root"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	group, err := d.AddGroup("rack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		if err := d.AddGroupMember(group, ip); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := d.DeleteMachine("10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	machines, err := d.FetchMachines()
+	if err != nil || len(machines) != 1 || machines[0].IP != "10.0.0.2" {
+		t.Errorf("machines = %v (%v), want only 10.0.0.2", machines, err)
+	}
+	states, err := d.FetchDesiredStates()
+	if err != nil || len(states) != 1 || states[0].MachineIP != "10.0.0.2" {
+		t.Errorf("desired states = %v (%v), want only 10.0.0.2", states, err)
+	}
+	creds, err := d.FetchSSHCredentials()
+	if err != nil || len(creds) != 1 || creds[0].MachineIP != "10.0.0.2" {
+		t.Errorf("SSH credentials = %v (%v), want only 10.0.0.2", creds, err)
+	}
+	g, err := d.FetchGroup(group)
+	if err != nil || len(g.MachineIPs) != 1 || g.MachineIPs[0] != "10.0.0.2" {
+		t.Errorf("group members = %v (%v), want only 10.0.0.2", g, err)
+	}
+}
@@ -0,0 +1,45 @@
+package db
+
+import "time"
+
+// DesiredState is the target miner configuration the reconciler converges a
+// machine to. WorkMode is "Auto" (PowerTarget W), "Fixed" (Freq/Volt) or "Sleep".
+type DesiredState struct {
+	MachineIP   string    `json:"machineIp"`
+	WorkMode    string    `json:"workMode"`
+	PowerTarget int       `json:"powerTarget"`
+	Freq        float64   `json:"freq"`
+	Volt        float64   `json:"volt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+func (d *DB) FetchDesiredStates() ([]DesiredState, error) {
+	rows, err := d.conn.Query("SELECT machine_ip, work_mode, power_target, freq, volt, updated_at FROM desired_states ORDER BY machine_ip")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var states []DesiredState
+	for rows.Next() {
+		var s DesiredState
+		if err := rows.Scan(&s.MachineIP, &s.WorkMode, &s.PowerTarget, &s.Freq, &s.Volt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		states = append(states, s)
+	}
+	return states, rows.Err()
+}
+
+// SaveDesiredState inserts or replaces the desired state of a machine.
+func (d *DB) SaveDesiredState(s DesiredState) error {
+	_, err := d.conn.Exec(`INSERT INTO desired_states (machine_ip, work_mode, power_target, freq, volt, updated_at) VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(machine_ip) DO UPDATE SET work_mode = excluded.work_mode, power_target = excluded.power_target, freq = excluded.freq, volt = excluded.volt, updated_at = excluded.updated_at`,
+		s.MachineIP, s.WorkMode, s.PowerTarget, s.Freq, s.Volt)
+	return err
+}
+
+func (d *DB) DeleteDesiredState(machineIP string) error {
+	_, err := d.conn.Exec("DELETE FROM desired_states WHERE machine_ip = ?", machineIP)
+	return err
+}
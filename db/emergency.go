@@ -0,0 +1,50 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Lockout is the emergency lockout. While Active, miner starts are refused; it
+// survives restarts and is only lifted by an explicit reset.
+type Lockout struct {
+	Active      bool       `json:"active"`
+	Source      string     `json:"source"` // e.g. "smoke:hallway" or "manual"
+	Reason      string     `json:"reason"`
+	TriggeredAt time.Time  `json:"triggeredAt"`
+	ClearedAt   *time.Time `json:"clearedAt,omitempty"`
+	ClearedBy   string     `json:"clearedBy,omitempty"`
+}
+
+// FetchLockout returns the last lockout, or nil if none was ever triggered.
+func (d *DB) FetchLockout() (*Lockout, error) {
+	var l Lockout
+	var clearedAt sql.NullTime
+	err := d.conn.QueryRow("SELECT active, source, reason, triggered_at, cleared_at, cleared_by FROM emergency_lockout WHERE id = 1").
+		Scan(&l.Active, &l.Source, &l.Reason, &l.TriggeredAt, &clearedAt, &l.ClearedBy)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if clearedAt.Valid {
+		l.ClearedAt = &clearedAt.Time
+	}
+	return &l, nil
+}
+
+// SaveLockout activates the lockout, replacing any previous one.
+func (d *DB) SaveLockout(source, reason string, at time.Time) error {
+	_, err := d.conn.Exec(`INSERT INTO emergency_lockout (id, active, source, reason, triggered_at, cleared_at, cleared_by) VALUES (1, 1, ?, ?, ?, NULL, '')
+		ON CONFLICT(id) DO UPDATE SET active = 1, source = excluded.source, reason = excluded.reason, triggered_at = excluded.triggered_at, cleared_at = NULL, cleared_by = ''`,
+		source, reason, at.UTC())
+	return err
+}
+
+// ClearLockout deactivates the lockout and records who cleared it.
+func (d *DB) ClearLockout(by string) error {
+	_, err := d.conn.Exec("UPDATE emergency_lockout SET active = 0, cleared_at = ?, cleared_by = ? WHERE id = 1", time.Now().UTC(), by)
+	return err
+}
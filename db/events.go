@@ -0,0 +1,54 @@
+package db
+
+import "time"
+
+// Event is an entry in the event log (alerts, automatic actions, overrides).
+type Event struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	Source    string    `json:"source"`
+	Message   string    `json:"message"`
+}
+
+func (d *DB) AddEvent(source, message string) error {
+	_, err := d.conn.Exec("INSERT INTO events (created_at, source, message) VALUES (?, ?, ?)", time.Now().UTC(), source, message)
+	return err
+}
+
+// FetchEvents returns the most recent events, newest first.
+func (d *DB) FetchEvents(limit int) ([]Event, error) {
+	rows, err := d.conn.Query("SELECT id, created_at, source, message FROM events ORDER BY id DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.ID, &e.CreatedAt, &e.Source, &e.Message); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// FetchEventsBetween returns events created in [from, to), oldest first.
+func (d *DB) FetchEventsBetween(from, to time.Time) ([]Event, error) {
+	rows, err := d.conn.Query("SELECT id, created_at, source, message FROM events WHERE created_at >= ? AND created_at < ? ORDER BY id", from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.ID, &e.CreatedAt, &e.Source, &e.Message); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,52 @@
+package db
+
+// Gauge is a dashboard gauge showing one metric. Gauges are shown ordered by
+// Position; an empty Label or Unit falls back to the metric's own.
+//
+// WarnAt and CriticalAt color the gauge; a zero threshold is disabled. If
+// CriticalAt < WarnAt lower values are worse (e.g. hashrate), otherwise higher
+// values are (e.g. temperature).
+type Gauge struct {
+	ID         int64   `json:"id"`
+	Name       string  `json:"name"`
+	Position   int     `json:"position"`
+	Metric     string  `json:"metric"`
+	Label      string  `json:"label"`
+	Unit       string  `json:"unit"`
+	Decimals   int     `json:"decimals"`
+	Color      string  `json:"color"` // Bootstrap color while within thresholds
+	WarnAt     float64 `json:"warnAt"`
+	CriticalAt float64 `json:"criticalAt"`
+}
+
+func (d *DB) FetchGauges() ([]Gauge, error) {
+	rows, err := d.conn.Query("SELECT id, name, position, metric, label, unit, decimals, color, warn_at, critical_at FROM gauges ORDER BY position, name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var gauges []Gauge
+	for rows.Next() {
+		var g Gauge
+		if err := rows.Scan(&g.ID, &g.Name, &g.Position, &g.Metric, &g.Label, &g.Unit, &g.Decimals, &g.Color, &g.WarnAt, &g.CriticalAt); err != nil {
+			return nil, err
+		}
+		gauges = append(gauges, g)
+	}
+	return gauges, rows.Err()
+}
+
+// SaveGauge inserts a gauge or, if one with the same name exists, updates it.
+func (d *DB) SaveGauge(g Gauge) error {
+	_, err := d.conn.Exec(`INSERT INTO gauges (name, position, metric, label, unit, decimals, color, warn_at, critical_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET position = excluded.position, metric = excluded.metric, label = excluded.label, unit = excluded.unit,
+			decimals = excluded.decimals, color = excluded.color, warn_at = excluded.warn_at, critical_at = excluded.critical_at`,
+		g.Name, g.Position, g.Metric, g.Label, g.Unit, g.Decimals, g.Color, g.WarnAt, g.CriticalAt)
+	return err
+}
+
+func (d *DB) DeleteGauge(name string) error {
+	_, err := d.conn.Exec("DELETE FROM gauges WHERE name = ?", name)
+	return err
+}
@@ -0,0 +1,81 @@
+package db
+
+// Group is a named set of machines (e.g. "rack A", "immersion") used for
+// aggregates and bulk actions.
+type Group struct {
+	ID         int64    `json:"id"`
+	Name       string   `json:"name"`
+	MachineIPs []string `json:"machineIps"`
+}
+
+func (d *DB) FetchGroups() ([]Group, error) {
+	rows, err := d.conn.Query(`SELECT g.id, g.name, COALESCE(GROUP_CONCAT(m.machine_ip), '')
+		FROM miner_groups g LEFT JOIN miner_group_members m ON m.group_id = g.id
+		GROUP BY g.id ORDER BY g.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []Group
+	for rows.Next() {
+		var g Group
+		var ips string
+		if err := rows.Scan(&g.ID, &g.Name, &ips); err != nil {
+			return nil, err
+		}
+		g.MachineIPs = splitList(ips)
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// FetchGroup returns a single group with its members, or sql.ErrNoRows.
+func (d *DB) FetchGroup(id int64) (*Group, error) {
+	g := Group{ID: id}
+	if err := d.conn.QueryRow("SELECT name FROM miner_groups WHERE id = ?", id).Scan(&g.Name); err != nil {
+		return nil, err
+	}
+
+	rows, err := d.conn.Query("SELECT machine_ip FROM miner_group_members WHERE group_id = ? ORDER BY machine_ip", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ip string
+		if err := rows.Scan(&ip); err != nil {
+			return nil, err
+		}
+		g.MachineIPs = append(g.MachineIPs, ip)
+	}
+	return &g, rows.Err()
+}
+
+// AddGroup creates a group and returns its ID.
+func (d *DB) AddGroup(name string) (int64, error) {
+	res, err := d.conn.Exec("INSERT INTO miner_groups (name) VALUES (?)", name)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (d *DB) DeleteGroup(id int64) error {
+	if _, err := d.conn.Exec("DELETE FROM miner_group_members WHERE group_id = ?", id); err != nil {
+		return err
+	}
+	_, err := d.conn.Exec("DELETE FROM miner_groups WHERE id = ?", id)
+	return err
+}
+
+func (d *DB) AddGroupMember(id int64, machineIP string) error {
+	_, err := d.conn.Exec("INSERT OR IGNORE INTO miner_group_members (group_id, machine_ip) VALUES (?, ?)", id, machineIP)
+	return err
+}
+
+func (d *DB) RemoveGroupMember(id int64, machineIP string) error {
+	_, err := d.conn.Exec("DELETE FROM miner_group_members WHERE group_id = ? AND machine_ip = ?", id, machineIP)
+	return err
+}
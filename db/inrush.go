@@ -0,0 +1,40 @@
+package db
+
+import "time"
+
+// InrushRamp is the outlet power measured while a miner powers up. Samples
+// holds the readings as JSON.
+type InrushRamp struct {
+	ID        int64     `json:"id"`
+	IP        string    `json:"ip"`
+	StartedAt time.Time `json:"startedAt"` // when the relay was switched on
+	PeakW     float64   `json:"peakW"`
+	Samples   string    `json:"samples"`
+}
+
+func (d *DB) AddInrushRamp(r InrushRamp) error {
+	_, err := d.conn.Exec("INSERT INTO inrush_ramps (ip, started_at, peak_w, samples) VALUES (?, ?, ?, ?)",
+		r.IP, r.StartedAt.UTC(), r.PeakW, r.Samples)
+	return err
+}
+
+// FetchInrushRamps returns the ramps of relays switched on in [from, to),
+// oldest first.
+func (d *DB) FetchInrushRamps(from, to time.Time) ([]InrushRamp, error) {
+	rows, err := d.conn.Query("SELECT id, ip, started_at, peak_w, samples FROM inrush_ramps WHERE started_at >= ? AND started_at < ? ORDER BY started_at",
+		from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ramps []InrushRamp
+	for rows.Next() {
+		var r InrushRamp
+		if err := rows.Scan(&r.ID, &r.IP, &r.StartedAt, &r.PeakW, &r.Samples); err != nil {
+			return nil, err
+		}
+		ramps = append(ramps, r)
+	}
+	return ramps, rows.Err()
+}
@@ -0,0 +1,213 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Job statuses. A job is queued until a worker claims it, then running until
+// every target has finished or the job is cancelled.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobFailed    = "failed" // finished with at least one failed target
+	JobCancelled = "cancelled"
+)
+
+// Job target statuses.
+const (
+	TargetPending   = "pending"
+	TargetDone      = "done"
+	TargetFailed    = "failed"
+	TargetCancelled = "cancelled"
+)
+
+// Job is a durable long-running operation over a set of machines. Params holds
+// the kind-specific request as JSON.
+type Job struct {
+	ID         int64       `json:"id"`
+	Kind       string      `json:"kind"`
+	Params     string      `json:"params"`
+	Status     string      `json:"status"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	StartedAt  *time.Time  `json:"startedAt,omitempty"`
+	FinishedAt *time.Time  `json:"finishedAt,omitempty"`
+	UndoneBy   int64       `json:"undoneBy,omitempty"` // ID of the job that undid this one
+	Targets    []JobTarget `json:"targets"`
+}
+
+// JobTarget is the per-machine progress of a job.
+type JobTarget struct {
+	IP         string     `json:"ip"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Previous   string     `json:"-"` // JSON state before the job changed the machine, for undo
+}
+
+// CreateJob stores a queued job with a pending target per IP and returns its ID.
+func (d *DB) CreateJob(kind, params string, ips []string) (int64, error) {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("INSERT INTO jobs (kind, params, status, created_at) VALUES (?, ?, ?, ?)", kind, params, JobQueued, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	for _, ip := range ips {
+		if _, err := tx.Exec("INSERT INTO job_targets (job_id, ip, status) VALUES (?, ?, ?)", id, ip, TargetPending); err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
+}
+
+// FetchJobs returns the most recent jobs, newest first.
+func (d *DB) FetchJobs(limit int) ([]Job, error) {
+	rows, err := d.conn.Query("SELECT id, kind, params, status, created_at, started_at, finished_at, undone_by FROM jobs ORDER BY id DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range jobs {
+		if jobs[i].Targets, err = d.fetchJobTargets(jobs[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return jobs, nil
+}
+
+// FetchJob returns a job with its targets, or sql.ErrNoRows.
+func (d *DB) FetchJob(id int64) (*Job, error) {
+	row := d.conn.QueryRow("SELECT id, kind, params, status, created_at, started_at, finished_at, undone_by FROM jobs WHERE id = ?", id)
+	j, err := scanJob(row)
+	if err != nil {
+		return nil, err
+	}
+	if j.Targets, err = d.fetchJobTargets(id); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanJob(s scanner) (*Job, error) {
+	var j Job
+	var started, finished sql.NullTime
+	if err := s.Scan(&j.ID, &j.Kind, &j.Params, &j.Status, &j.CreatedAt, &started, &finished, &j.UndoneBy); err != nil {
+		return nil, err
+	}
+	if started.Valid {
+		j.StartedAt = &started.Time
+	}
+	if finished.Valid {
+		j.FinishedAt = &finished.Time
+	}
+	return &j, nil
+}
+
+func (d *DB) fetchJobTargets(jobID int64) ([]JobTarget, error) {
+	rows, err := d.conn.Query("SELECT ip, status, error, finished_at, previous FROM job_targets WHERE job_id = ? ORDER BY ip", jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets := []JobTarget{}
+	for rows.Next() {
+		var t JobTarget
+		var finished sql.NullTime
+		if err := rows.Scan(&t.IP, &t.Status, &t.Error, &finished, &t.Previous); err != nil {
+			return nil, err
+		}
+		if finished.Valid {
+			t.FinishedAt = &finished.Time
+		}
+		targets = append(targets, t)
+	}
+	return targets, rows.Err()
+}
+
+// ClaimNextJob marks the oldest queued job as running and returns it, or nil if
+// the queue is empty. Callers must serialize claims.
+func (d *DB) ClaimNextJob() (*Job, error) {
+	var id int64
+	err := d.conn.QueryRow("SELECT id FROM jobs WHERE status = ? ORDER BY id LIMIT 1", JobQueued).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := d.conn.Exec("UPDATE jobs SET status = ?, started_at = COALESCE(started_at, ?) WHERE id = ?", JobRunning, time.Now().UTC(), id); err != nil {
+		return nil, err
+	}
+	return d.FetchJob(id)
+}
+
+// RequeueRunningJobs puts jobs interrupted by a restart back in the queue. Their
+// finished targets are kept, so only pending ones run again.
+func (d *DB) RequeueRunningJobs() (int64, error) {
+	res, err := d.conn.Exec("UPDATE jobs SET status = ? WHERE status = ?", JobQueued, JobRunning)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// FinishJobTarget records the outcome for one machine of a job.
+func (d *DB) FinishJobTarget(jobID int64, ip, status, errMsg string) error {
+	_, err := d.conn.Exec("UPDATE job_targets SET status = ?, error = ?, finished_at = ? WHERE job_id = ? AND ip = ?",
+		status, errMsg, time.Now().UTC(), jobID, ip)
+	return err
+}
+
+// SetJobTargetPrevious stores the state of a machine before the job changed it.
+func (d *DB) SetJobTargetPrevious(jobID int64, ip, previous string) error {
+	_, err := d.conn.Exec("UPDATE job_targets SET previous = ? WHERE job_id = ? AND ip = ?", previous, jobID, ip)
+	return err
+}
+
+// SetJobUndone records the job that undid a job.
+func (d *DB) SetJobUndone(id, undoJobID int64) error {
+	_, err := d.conn.Exec("UPDATE jobs SET undone_by = ? WHERE id = ?", undoJobID, id)
+	return err
+}
+
+// FinishJob sets the final status of a job. Targets still pending are marked
+// cancelled.
+func (d *DB) FinishJob(id int64, status string) error {
+	now := time.Now().UTC()
+	if _, err := d.conn.Exec("UPDATE job_targets SET status = ?, finished_at = ? WHERE job_id = ? AND status = ?",
+		TargetCancelled, now, id, TargetPending); err != nil {
+		return err
+	}
+	_, err := d.conn.Exec("UPDATE jobs SET status = ?, finished_at = ? WHERE id = ?", status, now, id)
+	return err
+}
@@ -0,0 +1,48 @@
+package db
+
+// SensorLocation is a value of the location tag environment sensors report
+// with. Outdoor locations are the outside side of the thermal insulation and
+// condensation calculations, the others the room. A location without readings
+// for ReportIntervalSeconds is stale; zero disables the check. Airflow marks
+// the sensors at the air intake and exhaust ("intake", "exhaust" or empty).
+type SensorLocation struct {
+	ID                    int64  `json:"id"`
+	Name                  string `json:"name"`
+	Label                 string `json:"label"` // display name, empty uses Name
+	Outdoor               bool   `json:"outdoor"`
+	ReportIntervalSeconds int    `json:"reportIntervalSeconds"`
+	Airflow               string `json:"airflow"`
+}
+
+func (d *DB) FetchSensorLocations() ([]SensorLocation, error) {
+	rows, err := d.conn.Query("SELECT id, name, label, outdoor, report_interval_seconds, airflow FROM sensor_locations ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var locations []SensorLocation
+	for rows.Next() {
+		var l SensorLocation
+		if err := rows.Scan(&l.ID, &l.Name, &l.Label, &l.Outdoor, &l.ReportIntervalSeconds, &l.Airflow); err != nil {
+			return nil, err
+		}
+		locations = append(locations, l)
+	}
+	return locations, rows.Err()
+}
+
+// SaveSensorLocation inserts a location or, if one with the same name exists,
+// updates it.
+func (d *DB) SaveSensorLocation(l SensorLocation) error {
+	_, err := d.conn.Exec(`INSERT INTO sensor_locations (name, label, outdoor, report_interval_seconds, airflow) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET label = excluded.label, outdoor = excluded.outdoor,
+			report_interval_seconds = excluded.report_interval_seconds, airflow = excluded.airflow`,
+		l.Name, l.Label, l.Outdoor, l.ReportIntervalSeconds, l.Airflow)
+	return err
+}
+
+func (d *DB) DeleteSensorLocation(name string) error {
+	_, err := d.conn.Exec("DELETE FROM sensor_locations WHERE name = ?", name)
+	return err
+}
@@ -0,0 +1,66 @@
+package db
+
+import "time"
+
+// MaintenanceEntry is a piece of work done on a machine: a fan replaced, a
+// repaste, a board sent in for RMA. RemindAfterHours asks for a reminder once
+// the machine has hashed that many hours since; 0 sets no reminder.
+type MaintenanceEntry struct {
+	ID               int64     `json:"id"`
+	IP               string    `json:"ip"`
+	Kind             string    `json:"kind"`
+	Note             string    `json:"note"`
+	PerformedAt      time.Time `json:"performedAt"`
+	RemindAfterHours float64   `json:"remindAfterHours"`
+}
+
+func (d *DB) AddMaintenanceEntry(e MaintenanceEntry) (int64, error) {
+	res, err := d.conn.Exec("INSERT INTO maintenance_log (ip, kind, note, performed_at, remind_after_hours) VALUES (?, ?, ?, ?, ?)",
+		e.IP, e.Kind, e.Note, e.PerformedAt.UTC(), e.RemindAfterHours)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// FetchMaintenanceEntries returns the log of a machine, or of all machines
+// when ip is empty, newest first.
+func (d *DB) FetchMaintenanceEntries(ip string) ([]MaintenanceEntry, error) {
+	rows, err := d.conn.Query("SELECT id, ip, kind, note, performed_at, remind_after_hours FROM maintenance_log WHERE ? = '' OR ip = ? ORDER BY performed_at DESC, id DESC",
+		ip, ip)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []MaintenanceEntry
+	for rows.Next() {
+		var e MaintenanceEntry
+		if err := rows.Scan(&e.ID, &e.IP, &e.Kind, &e.Note, &e.PerformedAt, &e.RemindAfterHours); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// DeleteMaintenanceEntry removes an entry of a machine's log, reporting
+// whether it existed.
+func (d *DB) DeleteMaintenanceEntry(ip string, id int64) (bool, error) {
+	res, err := d.conn.Exec("DELETE FROM maintenance_log WHERE id = ? AND ip = ?", id, ip)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// SetMachineWarranty sets the end of a machine's warranty; nil clears it.
+func (d *DB) SetMachineWarranty(ip string, until *time.Time) error {
+	var u interface{}
+	if until != nil {
+		u = until.UTC()
+	}
+	_, err := d.conn.Exec("UPDATE machines SET warranty_until = ? WHERE ip = ?", u, ip)
+	return err
+}
@@ -0,0 +1,54 @@
+package db
+
+import "strings"
+
+// NotifyChannel is a destination for alert notifications. Alerts are routed to
+// every enabled channel whose MinSeverity they reach and, if Sources is set,
+// whose Sources list their source.
+type NotifyChannel struct {
+	ID           int64    `json:"id"`
+	Name         string   `json:"name"`
+	Kind         string   `json:"kind"`   // "discord", "pushover", "ntfy", "webhook", "email" or "telegram"
+	Target       string   `json:"target"` // webhook URL, ntfy topic URL, Pushover user key, email addresses or Telegram chat ID
+	Token        string   `json:"-"`      // Pushover app token, ntfy access token or Telegram bot token
+	MinSeverity  string   `json:"minSeverity"`
+	Sources      []string `json:"sources"` // alert sources, e.g. "cooling"; empty routes all
+	SendResolved bool     `json:"sendResolved"`
+	Enabled      bool     `json:"enabled"`
+}
+
+func (d *DB) FetchNotifyChannels() ([]NotifyChannel, error) {
+	rows, err := d.conn.Query("SELECT id, name, kind, target, token, min_severity, sources, send_resolved, enabled FROM notify_channels ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var channels []NotifyChannel
+	for rows.Next() {
+		var ch NotifyChannel
+		var sources string
+		if err := rows.Scan(&ch.ID, &ch.Name, &ch.Kind, &ch.Target, &ch.Token, &ch.MinSeverity, &sources, &ch.SendResolved, &ch.Enabled); err != nil {
+			return nil, err
+		}
+		ch.Sources = splitList(sources)
+		channels = append(channels, ch)
+	}
+	return channels, rows.Err()
+}
+
+// SaveNotifyChannel inserts a channel or, if one with the same name exists,
+// updates it. An empty token keeps the stored one.
+func (d *DB) SaveNotifyChannel(ch NotifyChannel) error {
+	_, err := d.conn.Exec(`INSERT INTO notify_channels (name, kind, target, token, min_severity, sources, send_resolved, enabled) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET kind = excluded.kind, target = excluded.target,
+			token = CASE WHEN excluded.token = '' THEN notify_channels.token ELSE excluded.token END,
+			min_severity = excluded.min_severity, sources = excluded.sources, send_resolved = excluded.send_resolved, enabled = excluded.enabled`,
+		ch.Name, ch.Kind, ch.Target, ch.Token, ch.MinSeverity, strings.Join(ch.Sources, ","), ch.SendResolved, ch.Enabled)
+	return err
+}
+
+func (d *DB) DeleteNotifyChannel(name string) error {
+	_, err := d.conn.Exec("DELETE FROM notify_channels WHERE name = ?", name)
+	return err
+}
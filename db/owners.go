@@ -0,0 +1,120 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// HostingOwner holds the billing terms of a hosting customer, matched to
+// machines by Machine.Owner.
+type HostingOwner struct {
+	ID            int64   `json:"id"`
+	Name          string  `json:"name"`
+	ElecPrice     float64 `json:"elecPrice"`     // EUR/kWh, 0 uses --elec-price
+	HostingFeeEUR float64 `json:"hostingFeeEur"` // per machine and month
+	Email         string  `json:"email"`
+	HasLogin      bool    `json:"hasLogin"` // a password is set
+}
+
+func (d *DB) FetchHostingOwners() ([]HostingOwner, error) {
+	rows, err := d.conn.Query("SELECT id, name, elec_price, hosting_fee_eur, email, password_hash != '' FROM hosting_owners ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var owners []HostingOwner
+	for rows.Next() {
+		var o HostingOwner
+		if err := rows.Scan(&o.ID, &o.Name, &o.ElecPrice, &o.HostingFeeEUR, &o.Email, &o.HasLogin); err != nil {
+			return nil, err
+		}
+		owners = append(owners, o)
+	}
+	return owners, rows.Err()
+}
+
+// SaveHostingOwner inserts an owner or, if one with the same name exists,
+// updates it.
+func (d *DB) SaveHostingOwner(o HostingOwner) error {
+	_, err := d.conn.Exec(`INSERT INTO hosting_owners (name, elec_price, hosting_fee_eur, email) VALUES (?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET elec_price = excluded.elec_price, hosting_fee_eur = excluded.hosting_fee_eur,
+			email = excluded.email`,
+		o.Name, o.ElecPrice, o.HostingFeeEUR, o.Email)
+	return err
+}
+
+// DeleteHostingOwner deletes an owner and logs out its sessions.
+func (d *DB) DeleteHostingOwner(name string) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM hosting_owners WHERE name = ?", name); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM owner_sessions WHERE owner = ?", name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FetchOwnerPasswordHash returns the password hash of an owner, empty if it
+// has no login, or sql.ErrNoRows.
+func (d *DB) FetchOwnerPasswordHash(name string) (string, error) {
+	var hash string
+	err := d.conn.QueryRow("SELECT password_hash FROM hosting_owners WHERE name = ?", name).Scan(&hash)
+	return hash, err
+}
+
+// SetOwnerPasswordHash replaces the password hash of an owner, an empty hash
+// disabling its login, and logs out its sessions.
+func (d *DB) SetOwnerPasswordHash(name, hash string) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE hosting_owners SET password_hash = ? WHERE name = ?", hash, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec("DELETE FROM owner_sessions WHERE owner = ?", name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SaveOwnerSession stores a login session, dropping expired ones.
+func (d *DB) SaveOwnerSession(tokenHash, owner string, expires time.Time) error {
+	if _, err := d.conn.Exec("DELETE FROM owner_sessions WHERE expires_at < ?", time.Now().UTC()); err != nil {
+		return err
+	}
+	_, err := d.conn.Exec("INSERT INTO owner_sessions (token_hash, owner, expires_at) VALUES (?, ?, ?)",
+		tokenHash, owner, expires.UTC())
+	return err
+}
+
+// FetchOwnerSession returns the owner of an unexpired session, or "" if
+// there is none.
+func (d *DB) FetchOwnerSession(tokenHash string) (string, error) {
+	var owner string
+	err := d.conn.QueryRow("SELECT owner FROM owner_sessions WHERE token_hash = ? AND expires_at >= ?",
+		tokenHash, time.Now().UTC()).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return owner, err
+}
+
+func (d *DB) DeleteOwnerSession(tokenHash string) error {
+	_, err := d.conn.Exec("DELETE FROM owner_sessions WHERE token_hash = ?", tokenHash)
+	return err
+}
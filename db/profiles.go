@@ -0,0 +1,52 @@
+package db
+
+// ModelProfile is the safe operating range of a miner model. Model matches the
+// model probed from the miner, ignoring case. A zero bound is not enforced.
+type ModelProfile struct {
+	ID       int64   `json:"id"`
+	Model    string  `json:"model"`
+	MinFreq  float64 `json:"minFreq"` // MHz
+	MaxFreq  float64 `json:"maxFreq"`
+	MinVolt  float64 `json:"minVolt"` // V
+	MaxVolt  float64 `json:"maxVolt"`
+	MinPower int     `json:"minPower"` // W
+	MaxPower int     `json:"maxPower"`
+}
+
+func (d *DB) FetchModelProfiles() ([]ModelProfile, error) {
+	rows, err := d.conn.Query("SELECT id, model, min_freq, max_freq, min_volt, max_volt, min_power, max_power FROM model_profiles ORDER BY model")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []ModelProfile
+	for rows.Next() {
+		var p ModelProfile
+		if err := rows.Scan(&p.ID, &p.Model, &p.MinFreq, &p.MaxFreq, &p.MinVolt, &p.MaxVolt, &p.MinPower, &p.MaxPower); err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, rows.Err()
+}
+
+// SaveModelProfile inserts a profile or, if one for the same model exists,
+// replaces its limits.
+func (d *DB) SaveModelProfile(p ModelProfile) error {
+	_, err := d.conn.Exec(`INSERT INTO model_profiles (model, min_freq, max_freq, min_volt, max_volt, min_power, max_power) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(model) DO UPDATE SET min_freq = excluded.min_freq, max_freq = excluded.max_freq, min_volt = excluded.min_volt, max_volt = excluded.max_volt,
+			min_power = excluded.min_power, max_power = excluded.max_power`,
+		p.Model, p.MinFreq, p.MaxFreq, p.MinVolt, p.MaxVolt, p.MinPower, p.MaxPower)
+	return err
+}
+
+// DeleteModelProfile deletes a model's profile and reports whether it existed.
+func (d *DB) DeleteModelProfile(model string) (bool, error) {
+	res, err := d.conn.Exec("DELETE FROM model_profiles WHERE model = ?", model)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
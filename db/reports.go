@@ -0,0 +1,24 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// LastReportRun returns when the given scheduled report was last sent, or the
+// zero time if never.
+func (d *DB) LastReportRun(period string) (time.Time, error) {
+	var t time.Time
+	err := d.conn.QueryRow("SELECT last_sent FROM report_runs WHERE period = ?", period).Scan(&t)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return t, err
+}
+
+func (d *DB) SetReportRun(period string, sent time.Time) error {
+	_, err := d.conn.Exec(`INSERT INTO report_runs (period, last_sent) VALUES (?, ?)
+		ON CONFLICT(period) DO UPDATE SET last_sent = excluded.last_sent`, period, sent.UTC())
+	return err
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Scenario is a named bundle of room settings switched in one step. While it
+// is active, desired Auto power targets are scaled to PowerPercent (0 leaves
+// them alone), QuietHours replaces --quiet-hours and AlertSeverity replaces
+// the minimum severity of every notification channel; empty leaves them as
+// configured. Template is a config template applied to the miners and
+// Actuators sets actuators to "auto", "on" or "off" on activation. Away
+// scenarios turn on away mode.
+type Scenario struct {
+	ID            int64             `json:"id"`
+	Name          string            `json:"name"`
+	PowerPercent  int               `json:"powerPercent"`
+	Template      string            `json:"template"`
+	Actuators     map[string]string `json:"actuators"`
+	QuietHours    string            `json:"quietHours"`
+	AlertSeverity string            `json:"alertSeverity"`
+	Away          bool              `json:"away"`
+	UpdatedAt     time.Time         `json:"updatedAt"`
+}
+
+// ActiveScenario records the scenario in effect.
+type ActiveScenario struct {
+	Name        string    `json:"name"`
+	ActivatedBy string    `json:"activatedBy"`
+	ActivatedAt time.Time `json:"activatedAt"`
+}
+
+const scenarioColumns = "id, name, power_percent, template, actuators, quiet_hours, alert_severity, away, updated_at"
+
+func scanScenario(row scanner) (Scenario, error) {
+	var s Scenario
+	var actuators string
+	if err := row.Scan(&s.ID, &s.Name, &s.PowerPercent, &s.Template, &actuators, &s.QuietHours, &s.AlertSeverity, &s.Away, &s.UpdatedAt); err != nil {
+		return s, err
+	}
+	err := json.Unmarshal([]byte(actuators), &s.Actuators)
+	return s, err
+}
+
+func (d *DB) FetchScenarios() ([]Scenario, error) {
+	rows, err := d.conn.Query("SELECT " + scenarioColumns + " FROM scenarios ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scenarios []Scenario
+	for rows.Next() {
+		s, err := scanScenario(rows)
+		if err != nil {
+			return nil, err
+		}
+		scenarios = append(scenarios, s)
+	}
+	return scenarios, rows.Err()
+}
+
+// FetchScenario returns a scenario by name, or nil if it does not exist.
+func (d *DB) FetchScenario(name string) (*Scenario, error) {
+	s, err := scanScenario(d.conn.QueryRow("SELECT "+scenarioColumns+" FROM scenarios WHERE name = ?", name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SaveScenario inserts a scenario or, if one with the same name exists,
+// replaces its settings.
+func (d *DB) SaveScenario(s Scenario) error {
+	actuators, err := json.Marshal(s.Actuators)
+	if err != nil {
+		return err
+	}
+	if s.Actuators == nil {
+		actuators = []byte("{}")
+	}
+	_, err = d.conn.Exec(`INSERT INTO scenarios (name, power_percent, template, actuators, quiet_hours, alert_severity, away, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(name) DO UPDATE SET power_percent = excluded.power_percent, template = excluded.template, actuators = excluded.actuators,
+			quiet_hours = excluded.quiet_hours, alert_severity = excluded.alert_severity, away = excluded.away, updated_at = excluded.updated_at`,
+		s.Name, s.PowerPercent, s.Template, string(actuators), s.QuietHours, s.AlertSeverity, s.Away)
+	return err
+}
+
+// DeleteScenario deletes a scenario and reports whether it existed.
+func (d *DB) DeleteScenario(name string) (bool, error) {
+	res, err := d.conn.Exec("DELETE FROM scenarios WHERE name = ?", name)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// FetchActiveScenario returns the scenario in effect, or nil if none is.
+func (d *DB) FetchActiveScenario() (*ActiveScenario, error) {
+	var a ActiveScenario
+	err := d.conn.QueryRow("SELECT name, activated_by, activated_at FROM active_scenario WHERE id = 1").Scan(&a.Name, &a.ActivatedBy, &a.ActivatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// SetActiveScenario records the scenario in effect, replacing the previous one.
+func (d *DB) SetActiveScenario(name, by string, at time.Time) error {
+	_, err := d.conn.Exec(`INSERT INTO active_scenario (id, name, activated_by, activated_at) VALUES (1, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET name = excluded.name, activated_by = excluded.activated_by, activated_at = excluded.activated_at`,
+		name, by, at.UTC())
+	return err
+}
+
+// ClearActiveScenario records that no scenario is in effect.
+func (d *DB) ClearActiveScenario() error {
+	_, err := d.conn.Exec("DELETE FROM active_scenario WHERE id = 1")
+	return err
+}
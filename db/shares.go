@@ -0,0 +1,83 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ShareToken grants read access to the public status page at
+// /public/<token>. Deleting it revokes the link.
+type ShareToken struct {
+	ID         int64      `json:"id"`
+	Token      string     `json:"token"`
+	Label      string     `json:"label"` // where the link is shared, e.g. "blog"
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+func (d *DB) FetchShareTokens() ([]ShareToken, error) {
+	rows, err := d.conn.Query("SELECT id, token, label, created_at, last_used_at FROM share_tokens ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []ShareToken
+	for rows.Next() {
+		var t ShareToken
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&t.ID, &t.Token, &t.Label, &t.CreatedAt, &lastUsed); err != nil {
+			return nil, err
+		}
+		if lastUsed.Valid {
+			t.LastUsedAt = &lastUsed.Time
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// FetchShareToken returns the share token with the given value, or nil if it
+// does not exist.
+func (d *DB) FetchShareToken(token string) (*ShareToken, error) {
+	var t ShareToken
+	var lastUsed sql.NullTime
+	err := d.conn.QueryRow("SELECT id, token, label, created_at, last_used_at FROM share_tokens WHERE token = ?", token).
+		Scan(&t.ID, &t.Token, &t.Label, &t.CreatedAt, &lastUsed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if lastUsed.Valid {
+		t.LastUsedAt = &lastUsed.Time
+	}
+	return &t, nil
+}
+
+func (d *DB) AddShareToken(token, label string) (ShareToken, error) {
+	t := ShareToken{Token: token, Label: label, CreatedAt: time.Now().UTC()}
+	res, err := d.conn.Exec("INSERT INTO share_tokens (token, label, created_at) VALUES (?, ?, ?)", t.Token, t.Label, t.CreatedAt)
+	if err != nil {
+		return t, err
+	}
+	t.ID, err = res.LastInsertId()
+	return t, err
+}
+
+func (d *DB) TouchShareToken(id int64, at time.Time) error {
+	_, err := d.conn.Exec("UPDATE share_tokens SET last_used_at = ? WHERE id = ?", at.UTC(), id)
+	return err
+}
+
+// DeleteShareToken revokes a share token, reporting whether it existed.
+func (d *DB) DeleteShareToken(id int64) (bool, error) {
+	res, err := d.conn.Exec("DELETE FROM share_tokens WHERE id = ?", id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
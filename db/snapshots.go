@@ -0,0 +1,72 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Snapshot is an archived JSON document of the whole room's state. Listings
+// leave Document empty.
+type Snapshot struct {
+	ID        int64     `json:"id"`
+	Label     string    `json:"label"` // why it was taken, e.g. "before immersion test"
+	CreatedAt time.Time `json:"createdAt"`
+	Size      int       `json:"size"` // bytes of the document
+	Document  []byte    `json:"-"`
+}
+
+// FetchSnapshots lists the snapshots without their documents, newest first.
+func (d *DB) FetchSnapshots() ([]Snapshot, error) {
+	rows, err := d.conn.Query("SELECT id, label, created_at, length(CAST(document AS BLOB)) FROM snapshots ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []Snapshot
+	for rows.Next() {
+		var s Snapshot
+		if err := rows.Scan(&s.ID, &s.Label, &s.CreatedAt, &s.Size); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, rows.Err()
+}
+
+// FetchSnapshot returns a snapshot with its document, or nil if it does not
+// exist.
+func (d *DB) FetchSnapshot(id int64) (*Snapshot, error) {
+	var s Snapshot
+	err := d.conn.QueryRow("SELECT id, label, created_at, document FROM snapshots WHERE id = ?", id).
+		Scan(&s.ID, &s.Label, &s.CreatedAt, &s.Document)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.Size = len(s.Document)
+	return &s, nil
+}
+
+func (d *DB) AddSnapshot(label string, createdAt time.Time, document []byte) (Snapshot, error) {
+	s := Snapshot{Label: label, CreatedAt: createdAt.UTC(), Size: len(document)}
+	res, err := d.conn.Exec("INSERT INTO snapshots (label, created_at, document) VALUES (?, ?, ?)", s.Label, s.CreatedAt, string(document))
+	if err != nil {
+		return s, err
+	}
+	s.ID, err = res.LastInsertId()
+	return s, err
+}
+
+// DeleteSnapshot removes a snapshot, reporting whether it existed.
+func (d *DB) DeleteSnapshot(id int64) (bool, error) {
+	res, err := d.conn.Exec("DELETE FROM snapshots WHERE id = ?", id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// SSHCredentials overrides the default SSH login for one machine. HostKey is
+// the SHA-256 fingerprint pinned on first connect.
+type SSHCredentials struct {
+	MachineIP  string `json:"machineIp"`
+	User       string `json:"user"`
+	Port       int    `json:"port"`
+	Password   string `json:"-"`
+	PrivateKey string `json:"-"` // PEM
+	HostKey    string `json:"hostKey"`
+}
+
+func (d *DB) FetchSSHCredentials() ([]SSHCredentials, error) {
+	rows, err := d.conn.Query("SELECT machine_ip, user, port, password, private_key, host_key FROM machine_ssh ORDER BY machine_ip")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var creds []SSHCredentials
+	for rows.Next() {
+		var c SSHCredentials
+		if err := rows.Scan(&c.MachineIP, &c.User, &c.Port, &c.Password, &c.PrivateKey, &c.HostKey); err != nil {
+			return nil, err
+		}
+		creds = append(creds, c)
+	}
+	return creds, rows.Err()
+}
+
+// FetchSSHCredential returns the credentials for a machine, or nil if none are
+// stored.
+func (d *DB) FetchSSHCredential(ip string) (*SSHCredentials, error) {
+	var c SSHCredentials
+	err := d.conn.QueryRow("SELECT machine_ip, user, port, password, private_key, host_key FROM machine_ssh WHERE machine_ip = ?", ip).
+		Scan(&c.MachineIP, &c.User, &c.Port, &c.Password, &c.PrivateKey, &c.HostKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// SaveSSHCredentials inserts or replaces the credentials for a machine. The
+// pinned host key is kept.
+func (d *DB) SaveSSHCredentials(c SSHCredentials) error {
+	_, err := d.conn.Exec(`INSERT INTO machine_ssh (machine_ip, user, port, password, private_key) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(machine_ip) DO UPDATE SET user = excluded.user, port = excluded.port,
+			password = excluded.password, private_key = excluded.private_key`,
+		c.MachineIP, c.User, c.Port, c.Password, c.PrivateKey)
+	return err
+}
+
+// DeleteSSHCredentials removes the credentials and the pinned host key.
+func (d *DB) DeleteSSHCredentials(ip string) error {
+	_, err := d.conn.Exec("DELETE FROM machine_ssh WHERE machine_ip = ?", ip)
+	return err
+}
+
+// PinSSHHostKey stores the host key fingerprint seen on first connect.
+func (d *DB) PinSSHHostKey(ip, fingerprint string) error {
+	_, err := d.conn.Exec(`INSERT INTO machine_ssh (machine_ip, host_key) VALUES (?, ?)
+		ON CONFLICT(machine_ip) DO UPDATE SET host_key = excluded.host_key`, ip, fingerprint)
+	return err
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ConfigTemplate is a named miner configuration applied to machines in one
+// step. WorkMode is "Auto" (PowerTarget W), "Fixed" (Freq/Volt), "Sleep" or
+// empty to leave the mode alone. Pools and Fan are kaonsu miner_config
+// sections written as given; empty leaves them unchanged.
+type ConfigTemplate struct {
+	ID          int64           `json:"id"`
+	Name        string          `json:"name"`
+	WorkMode    string          `json:"workMode"`
+	PowerTarget int             `json:"powerTarget"`
+	Freq        float64         `json:"freq"`
+	Volt        float64         `json:"volt"`
+	Pools       json.RawMessage `json:"pools,omitempty"`
+	Fan         json.RawMessage `json:"fan,omitempty"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+const templateColumns = "id, name, work_mode, power_target, freq, volt, pools, fan, updated_at"
+
+func scanTemplate(row scanner) (ConfigTemplate, error) {
+	var t ConfigTemplate
+	var pools, fan string
+	err := row.Scan(&t.ID, &t.Name, &t.WorkMode, &t.PowerTarget, &t.Freq, &t.Volt, &pools, &fan, &t.UpdatedAt)
+	if pools != "" {
+		t.Pools = json.RawMessage(pools)
+	}
+	if fan != "" {
+		t.Fan = json.RawMessage(fan)
+	}
+	return t, err
+}
+
+func (d *DB) FetchConfigTemplates() ([]ConfigTemplate, error) {
+	rows, err := d.conn.Query("SELECT " + templateColumns + " FROM config_templates ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []ConfigTemplate
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// FetchConfigTemplate returns a template by name, or nil if it does not exist.
+func (d *DB) FetchConfigTemplate(name string) (*ConfigTemplate, error) {
+	t, err := scanTemplate(d.conn.QueryRow("SELECT "+templateColumns+" FROM config_templates WHERE name = ?", name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// SaveConfigTemplate inserts a template or, if one with the same name exists,
+// replaces its settings.
+func (d *DB) SaveConfigTemplate(t ConfigTemplate) error {
+	_, err := d.conn.Exec(`INSERT INTO config_templates (name, work_mode, power_target, freq, volt, pools, fan, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(name) DO UPDATE SET work_mode = excluded.work_mode, power_target = excluded.power_target, freq = excluded.freq, volt = excluded.volt,
+			pools = excluded.pools, fan = excluded.fan, updated_at = excluded.updated_at`,
+		t.Name, t.WorkMode, t.PowerTarget, t.Freq, t.Volt, string(t.Pools), string(t.Fan))
+	return err
+}
+
+// DeleteConfigTemplate deletes a template and reports whether it existed.
+func (d *DB) DeleteConfigTemplate(name string) (bool, error) {
+	res, err := d.conn.Exec("DELETE FROM config_templates WHERE name = ?", name)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// TOTPSecret is the management TOTP secret. There is a single operator, so at
+// most one secret exists; it only protects actions once Confirmed. Pending is
+// a secret being enrolled: it replaces Secret when confirmed, so re-enrolling
+// keeps the confirmed secret in force until then.
+type TOTPSecret struct {
+	Secret    string
+	Confirmed bool
+	Pending   string
+}
+
+// PendingSecret returns the secret awaiting confirmation, or "". Secrets
+// enrolled before the pending column existed are pending while unconfirmed.
+func (s *TOTPSecret) PendingSecret() string {
+	if s.Pending == "" && !s.Confirmed {
+		return s.Secret
+	}
+	return s.Pending
+}
+
+// FetchTOTPSecret returns the stored secret, or nil if none was enrolled.
+func (d *DB) FetchTOTPSecret() (*TOTPSecret, error) {
+	var s TOTPSecret
+	err := d.conn.QueryRow("SELECT secret, confirmed, pending FROM totp_secret WHERE id = 1").Scan(&s.Secret, &s.Confirmed, &s.Pending)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SaveTOTPSecret stores a new secret pending confirmation, replacing any
+// earlier pending one. A confirmed secret stays in force.
+func (d *DB) SaveTOTPSecret(secret string) error {
+	_, err := d.conn.Exec(`INSERT INTO totp_secret (id, secret, confirmed, pending) VALUES (1, '', 0, ?)
+		ON CONFLICT(id) DO UPDATE SET pending = excluded.pending`, secret)
+	return err
+}
+
+// ConfirmTOTPSecret activates the pending secret and replaces the recovery
+// codes.
+func (d *DB) ConfirmTOTPSecret(recoveryCodeHashes []string) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE totp_secret SET secret = CASE WHEN pending != '' THEN pending ELSE secret END,
+		pending = '', confirmed = 1 WHERE id = 1`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM totp_recovery_codes"); err != nil {
+		return err
+	}
+	for _, h := range recoveryCodeHashes {
+		if _, err := tx.Exec("INSERT INTO totp_recovery_codes (code_hash) VALUES (?)", h); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteTOTPSecret removes the secret and all recovery codes.
+func (d *DB) DeleteTOTPSecret() error {
+	if _, err := d.conn.Exec("DELETE FROM totp_recovery_codes"); err != nil {
+		return err
+	}
+	_, err := d.conn.Exec("DELETE FROM totp_secret")
+	return err
+}
+
+// UseRecoveryCode marks an unused recovery code with the given hash as used and
+// reports whether one was found.
+func (d *DB) UseRecoveryCode(codeHash string) (bool, error) {
+	res, err := d.conn.Exec("UPDATE totp_recovery_codes SET used_at = ? WHERE code_hash = ? AND used_at IS NULL", time.Now().UTC(), codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// CountRecoveryCodes returns the number of unused recovery codes.
+func (d *DB) CountRecoveryCodes() (int, error) {
+	var n int
+	err := d.conn.QueryRow("SELECT COUNT(*) FROM totp_recovery_codes WHERE used_at IS NULL").Scan(&n)
+	return n, err
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	d, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	if err := d.EnsureSchema(); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestReenrollKeepsConfirmedSecret(t *testing.T) {
+	d := openTestDB(t)
+
+	if err := d.SaveTOTPSecret("FIRST"); err != nil {
+		t.Fatal(err)
+	}
+	s, err := d.FetchTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Confirmed || s.PendingSecret() != "FIRST" {
+		t.Fatalf("after enrolling: %+v, want FIRST pending", s)
+	}
+	if err := d.ConfirmTOTPSecret(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.SaveTOTPSecret("SECOND"); err != nil {
+		t.Fatal(err)
+	}
+	s, err = d.FetchTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Confirmed || s.Secret != "FIRST" || s.PendingSecret() != "SECOND" {
+		t.Fatalf("after re-enrolling: %+v, want FIRST in force and SECOND pending", s)
+	}
+
+	if err := d.ConfirmTOTPSecret(nil); err != nil {
+		t.Fatal(err)
+	}
+	s, err = d.FetchTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Confirmed || s.Secret != "SECOND" || s.PendingSecret() != "" {
+		t.Fatalf("after confirming: %+v, want SECOND in force and nothing pending", s)
+	}
+}
@@ -0,0 +1,182 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Tuning run statuses.
+const (
+	TuningQueued    = "queued"
+	TuningRunning   = "running"
+	TuningDone      = "done"
+	TuningAborted   = "aborted" // a point crossed an error threshold and the miner was rolled back
+	TuningFailed    = "failed"
+	TuningCancelled = "cancelled"
+)
+
+// TuningPoint is one freq/volt setting measured by a tuning run.
+type TuningPoint struct {
+	Freq           float64   `json:"freq"`
+	Volt           float64   `json:"volt"`
+	Hashrate       float64   `json:"hashrate"`       // GH/s, average over the dwell
+	HashrateCV     float64   `json:"hashrateCv"`     // standard deviation over mean of the samples
+	HWErrorsPerMin float64   `json:"hwErrorsPerMin"` // hardware errors per minute
+	Power          float64   `json:"power"`          // W, 0 when not reported
+	Efficiency     float64   `json:"efficiency"`     // J/TH, 0 without power
+	Stable         bool      `json:"stable"`
+	Reason         string    `json:"reason,omitempty"` // why the point is unstable or was skipped
+	MeasuredAt     time.Time `json:"measuredAt"`
+}
+
+// TuningRun is a guided freq/volt sweep of one miner. Params holds the
+// request it was started with.
+type TuningRun struct {
+	ID         int64           `json:"id"`
+	MachineIP  string          `json:"machineIp"`
+	JobID      int64           `json:"jobId"`
+	Status     string          `json:"status"`
+	Params     json.RawMessage `json:"params"`
+	Points     []TuningPoint   `json:"points"`
+	Best       *TuningPoint    `json:"best,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+}
+
+// Baseline is the freq/volt a tuning run found best for a machine, with what
+// it measured there.
+type Baseline struct {
+	MachineIP  string    `json:"machineIp"`
+	Freq       float64   `json:"freq"`
+	Volt       float64   `json:"volt"`
+	Hashrate   float64   `json:"hashrate"`   // GH/s
+	Power      float64   `json:"power"`      // W
+	Efficiency float64   `json:"efficiency"` // J/TH
+	RunID      int64     `json:"runId"`
+	MeasuredAt time.Time `json:"measuredAt"`
+}
+
+// CreateTuningRun stores a queued run and returns its ID.
+func (d *DB) CreateTuningRun(machineIP, params string) (int64, error) {
+	res, err := d.conn.Exec("INSERT INTO tuning_runs (machine_ip, status, params, created_at) VALUES (?, ?, ?, ?)", machineIP, TuningQueued, params, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (d *DB) SetTuningRunJob(id, jobID int64) error {
+	_, err := d.conn.Exec("UPDATE tuning_runs SET job_id = ? WHERE id = ?", jobID, id)
+	return err
+}
+
+// UpdateTuningRun records a run's progress. Runs leaving the running status
+// get their finish time.
+func (d *DB) UpdateTuningRun(id int64, status string, points []TuningPoint, best *TuningPoint, errMsg string) error {
+	if points == nil {
+		points = []TuningPoint{}
+	}
+	pointsJSON, err := json.Marshal(points)
+	if err != nil {
+		return err
+	}
+	bestJSON := ""
+	if best != nil {
+		data, err := json.Marshal(best)
+		if err != nil {
+			return err
+		}
+		bestJSON = string(data)
+	}
+	var finishedAt interface{}
+	if status != TuningQueued && status != TuningRunning {
+		finishedAt = time.Now().UTC()
+	}
+	_, err = d.conn.Exec("UPDATE tuning_runs SET status = ?, points = ?, best = ?, error = ?, finished_at = ? WHERE id = ?",
+		status, string(pointsJSON), bestJSON, errMsg, finishedAt, id)
+	return err
+}
+
+const tuningRunColumns = "id, machine_ip, job_id, status, params, points, best, error, created_at, finished_at"
+
+func scanTuningRun(s scanner) (*TuningRun, error) {
+	var r TuningRun
+	var params, points, best string
+	var finished sql.NullTime
+	if err := s.Scan(&r.ID, &r.MachineIP, &r.JobID, &r.Status, &params, &points, &best, &r.Error, &r.CreatedAt, &finished); err != nil {
+		return nil, err
+	}
+	r.Params = json.RawMessage(params)
+	if err := json.Unmarshal([]byte(points), &r.Points); err != nil {
+		return nil, err
+	}
+	if best != "" {
+		r.Best = &TuningPoint{}
+		if err := json.Unmarshal([]byte(best), r.Best); err != nil {
+			return nil, err
+		}
+	}
+	if finished.Valid {
+		r.FinishedAt = &finished.Time
+	}
+	return &r, nil
+}
+
+// FetchTuningRuns returns the most recent runs, newest first; machineIP
+// limits them to one machine when set.
+func (d *DB) FetchTuningRuns(machineIP string, limit int) ([]TuningRun, error) {
+	rows, err := d.conn.Query("SELECT "+tuningRunColumns+" FROM tuning_runs WHERE ? = '' OR machine_ip = ? ORDER BY id DESC LIMIT ?", machineIP, machineIP, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []TuningRun
+	for rows.Next() {
+		r, err := scanTuningRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *r)
+	}
+	return runs, rows.Err()
+}
+
+// FetchTuningRun returns a run, or nil if it does not exist.
+func (d *DB) FetchTuningRun(id int64) (*TuningRun, error) {
+	r, err := scanTuningRun(d.conn.QueryRow("SELECT "+tuningRunColumns+" FROM tuning_runs WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return r, err
+}
+
+func (d *DB) FetchBaselines() ([]Baseline, error) {
+	rows, err := d.conn.Query("SELECT machine_ip, freq, volt, hashrate, power, efficiency, run_id, measured_at FROM machine_baselines ORDER BY machine_ip")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var baselines []Baseline
+	for rows.Next() {
+		var b Baseline
+		if err := rows.Scan(&b.MachineIP, &b.Freq, &b.Volt, &b.Hashrate, &b.Power, &b.Efficiency, &b.RunID, &b.MeasuredAt); err != nil {
+			return nil, err
+		}
+		baselines = append(baselines, b)
+	}
+	return baselines, rows.Err()
+}
+
+// SaveBaseline inserts or replaces the baseline of a machine.
+func (d *DB) SaveBaseline(b Baseline) error {
+	_, err := d.conn.Exec(`INSERT INTO machine_baselines (machine_ip, freq, volt, hashrate, power, efficiency, run_id, measured_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(machine_ip) DO UPDATE SET freq = excluded.freq, volt = excluded.volt, hashrate = excluded.hashrate, power = excluded.power,
+			efficiency = excluded.efficiency, run_id = excluded.run_id, measured_at = excluded.measured_at`,
+		b.MachineIP, b.Freq, b.Volt, b.Hashrate, b.Power, b.Efficiency, b.RunID, b.MeasuredAt.UTC())
+	return err
+}
@@ -0,0 +1,51 @@
+package db
+
+import "time"
+
+// UtilityInterval is the consumption of one 15 minute interval as billed by
+// the utility, imported from its smart meter export.
+type UtilityInterval struct {
+	Start time.Time `json:"start"`
+	KWh   float64   `json:"kwh"`
+}
+
+// SaveUtilityIntervals stores imported intervals, replacing earlier imports of
+// the same intervals.
+func (d *DB) SaveUtilityIntervals(intervals []UtilityInterval) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	for _, in := range intervals {
+		if _, err := tx.Exec(`INSERT INTO utility_intervals (start, kwh, imported_at) VALUES (?, ?, ?)
+			ON CONFLICT(start) DO UPDATE SET kwh = excluded.kwh, imported_at = excluded.imported_at`,
+			in.Start.UTC(), in.KWh, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// FetchUtilityIntervals returns the imported intervals starting in [from, to),
+// oldest first.
+func (d *DB) FetchUtilityIntervals(from, to time.Time) ([]UtilityInterval, error) {
+	rows, err := d.conn.Query("SELECT start, kwh FROM utility_intervals WHERE start >= ? AND start < ? ORDER BY start",
+		from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var intervals []UtilityInterval
+	for rows.Next() {
+		var in UtilityInterval
+		if err := rows.Scan(&in.Start, &in.KWh); err != nil {
+			return nil, err
+		}
+		intervals = append(intervals, in)
+	}
+	return intervals, rows.Err()
+}
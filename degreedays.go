@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// hddBaseTemp is the default base temperature (°C) of heating degree
+	// days: a day below it needs heat in proportion to the difference.
+	hddBaseTemp = 15.5
+	// degreeDayDefaultRange is the window of the report without ?range= or
+	// ?from=; degreeDayMaxRange is the longest one accepted.
+	degreeDayDefaultRange = 8 * 7 * 24 * time.Hour
+	degreeDayMaxRange     = 366 * 24 * time.Hour
+	// minFitDays is the number of days with energy and temperature the
+	// energy/degree day fit needs.
+	minFitDays = 7
+	// minWeekDegreeDays is the degree day total below which a week's kWh per
+	// degree day is left out, since mild weeks would divide by almost nothing.
+	minWeekDegreeDays = 5.0
+)
+
+// DegreeDayRow is one day of the degree day report. Days without an outdoor
+// reading have no OutsideTemp and are left out of the weekly figures and fit.
+type DegreeDayRow struct {
+	Date        string   `json:"date"`
+	EnergyKWh   float64  `json:"energyKwh"`
+	OutsideTemp *float64 `json:"outsideTemp"`
+	DegreeDays  float64  `json:"degreeDays"`
+}
+
+// DegreeDayWeek sums the days of an ISO week that have both energy and
+// outside temperature.
+type DegreeDayWeek struct {
+	Week            string   `json:"week"` // e.g. "2026-W06"
+	Days            int      `json:"days"`
+	EnergyKWh       float64  `json:"energyKwh"`
+	DegreeDays      float64  `json:"degreeDays"`
+	KWhPerDegreeDay *float64 `json:"kwhPerDegreeDay"` // nil below minWeekDegreeDays
+}
+
+// DegreeDayFit is the least squares fit of daily energy = BaseloadKWh +
+// KWhPerDegreeDay x degree days. The mining load ends up in the baseload; the
+// slope is the weather-dependent part, which drops as insulation improves.
+type DegreeDayFit struct {
+	Days            int     `json:"days"`
+	BaseloadKWh     float64 `json:"baseloadKwh"`
+	KWhPerDegreeDay float64 `json:"kwhPerDegreeDay"`
+	R2              float64 `json:"r2"`
+}
+
+// DegreeDayReport is daily energy normalized by heating degree days of the
+// outdoor locations. Temperatures and degree days are in TempUnit.
+type DegreeDayReport struct {
+	From      time.Time       `json:"from"`
+	To        time.Time       `json:"to"`
+	BaseTemp  float64         `json:"baseTemp"`
+	TempUnit  string          `json:"tempUnit"`
+	Locations []string        `json:"locations"`
+	Days      []DegreeDayRow  `json:"days"`
+	Weeks     []DegreeDayWeek `json:"weeks"`
+	Fit       *DegreeDayFit   `json:"fit"` // nil with too few days or no spread in degree days
+	HasData   bool            `json:"hasData"`
+}
+
+// buildDegreeDayReport combines daily energy with the daily mean temperature
+// of the outdoor locations between from and to (whole UTC days). temp
+// converts °C to the unit of base.
+func buildDegreeDayReport(qdb *questdb.Client, from, to time.Time, base float64, temp func(float64) float64) (*DegreeDayReport, error) {
+	locations := outdoorLocations()
+	energy, err := qdb.GetDailyEnergy(from, to)
+	if err != nil {
+		return nil, err
+	}
+	means, err := qdb.GetDailyMeanTemperatures(locations, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DegreeDayReport{
+		From:      from,
+		To:        to,
+		BaseTemp:  base,
+		Locations: locations,
+		Days:      make([]DegreeDayRow, 0, len(energy)),
+		Weeks:     []DegreeDayWeek{},
+	}
+
+	var xs, ys []float64
+	weeks := make(map[string]*DegreeDayWeek)
+	for _, e := range energy {
+		row := DegreeDayRow{Date: e.Date, EnergyKWh: math.Round(e.EnergyKWh*100) / 100}
+		mean, ok := means[e.Date]
+		if ok {
+			t := math.Round(temp(mean)*10) / 10
+			row.OutsideTemp = &t
+			row.DegreeDays = math.Round(math.Max(0, base-temp(mean))*10) / 10
+		}
+		report.Days = append(report.Days, row)
+		if !ok {
+			continue
+		}
+
+		xs = append(xs, row.DegreeDays)
+		ys = append(ys, row.EnergyKWh)
+		day, err := time.Parse("2006-01-02", e.Date)
+		if err != nil {
+			continue
+		}
+		year, week := day.ISOWeek()
+		key := fmt.Sprintf("%d-W%02d", year, week)
+		w := weeks[key]
+		if w == nil {
+			w = &DegreeDayWeek{Week: key}
+			weeks[key] = w
+		}
+		w.Days++
+		w.EnergyKWh += row.EnergyKWh
+		w.DegreeDays += row.DegreeDays
+	}
+
+	for _, w := range weeks {
+		w.EnergyKWh = math.Round(w.EnergyKWh*100) / 100
+		w.DegreeDays = math.Round(w.DegreeDays*10) / 10
+		if w.DegreeDays >= minWeekDegreeDays {
+			v := math.Round(w.EnergyKWh/w.DegreeDays*100) / 100
+			w.KWhPerDegreeDay = &v
+		}
+		report.Weeks = append(report.Weeks, *w)
+	}
+	sort.Slice(report.Weeks, func(i, j int) bool { return report.Weeks[i].Week < report.Weeks[j].Week })
+
+	report.Fit = fitDegreeDays(xs, ys)
+	report.HasData = len(report.Days) > 0
+	return report, nil
+}
+
+// fitDegreeDays fits energy ys against degree days xs by least squares.
+func fitDegreeDays(xs, ys []float64) *DegreeDayFit {
+	n := float64(len(xs))
+	if len(xs) < minFitDays {
+		return nil
+	}
+	var sx, sy float64
+	for i := range xs {
+		sx += xs[i]
+		sy += ys[i]
+	}
+	mx, my := sx/n, sy/n
+	var sxx, sxy, syy float64
+	for i := range xs {
+		sxx += (xs[i] - mx) * (xs[i] - mx)
+		sxy += (xs[i] - mx) * (ys[i] - my)
+		syy += (ys[i] - my) * (ys[i] - my)
+	}
+	if sxx == 0 {
+		return nil
+	}
+	slope := sxy / sxx
+	fit := &DegreeDayFit{
+		Days:            len(xs),
+		BaseloadKWh:     math.Round((my-slope*mx)*100) / 100,
+		KWhPerDegreeDay: math.Round(slope*100) / 100,
+	}
+	if syy > 0 {
+		fit.R2 = math.Round(sxy*sxy/(sxx*syy)*1000) / 1000
+	}
+	return fit
+}
+
+// getDegreeDayReportHandler reports daily energy with heating degree days over
+// the window of ?range= or ?from=&to= (default 8 weeks), cut to whole UTC
+// days. ?base= is the base temperature in the locale's unit (default 15.5 °C).
+func getDegreeDayReportHandler(c *gin.Context) {
+	loc := localeFor(c)
+	now := requestNow(c)
+	from, to, err := parseTimeWindow(c, now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if c.Query("range") == "" && c.Query("from") == "" {
+		from = to.Add(-degreeDayDefaultRange)
+	}
+	from, to = roomMidnight(from), roomMidnight(to)
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "window must contain a whole day"})
+		return
+	}
+	if to.Sub(from) > degreeDayMaxRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "range is limited to 366 days"})
+		return
+	}
+
+	base := math.Round(loc.Temp(hddBaseTemp)*10) / 10
+	if s := c.Query("base"); s != "" {
+		if base, err = strconv.ParseFloat(s, 64); err != nil || math.IsNaN(base) || math.IsInf(base, 0) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid base"})
+			return
+		}
+	}
+
+	report, err := buildDegreeDayReport(questdbFor(c), from, to, base, loc.Temp)
+	if err != nil {
+		log.Printf("Failed to build degree day report: %v", err)
+		c.JSON(http.StatusOK, gin.H{
+			"days":    []interface{}{},
+			"weeks":   []interface{}{},
+			"hasData": false,
+		})
+		return
+	}
+	report.TempUnit = loc.TempUnit()
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"miningRoom/db"
+	"miningRoom/internal/testsupport"
+	"miningRoom/questdb"
+	"miningRoom/questdb/schema"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useMinerCredentials sets the digest credentials sent to miners for the test.
+func useMinerCredentials(t *testing.T, user, pass string) {
+	prevUser, prevPass := minerUser, minerPass
+	minerUser, minerPass = user, pass
+	t.Cleanup(func() { minerUser, minerPass = prevUser, prevPass })
+}
+
+// useMachines replaces the machine list for the test.
+func useMachines(t *testing.T, ms ...db.Machine) {
+	prev := currentMachines()
+	setMachines(ms)
+	t.Cleanup(func() { setMachines(prev) })
+}
+
+func TestSetMinerPowerTarget(t *testing.T) {
+	miner := testsupport.NewMiner("root", "secret")
+	defer miner.Close()
+	ctx := context.Background()
+
+	useMinerCredentials(t, "root", "wrong")
+	if err := setMinerPowerTarget(ctx, miner.Addr(), 2500); err == nil {
+		t.Fatal("power target accepted with wrong credentials")
+	}
+	if len(miner.Posts()) != 0 {
+		t.Fatalf("config changed with wrong credentials: %v", miner.Posts())
+	}
+
+	useMinerCredentials(t, "root", "secret")
+	if err := setMinerPowerTarget(ctx, miner.Addr(), 2500); err != nil {
+		t.Fatal(err)
+	}
+	concorde, _ := miner.Mode()["concorde"].(map[string]interface{})
+	if miner.WorkMode() != "Auto" || concorde["mode-select"] != "PowerTarget" || concorde["power-target"] != 2500.0 {
+		t.Errorf("mode = %v, want Auto with a 2500 W power target", miner.Mode())
+	}
+	// The rest of the config is posted back unchanged
+	if fixed, _ := miner.Mode()["fixed"].(map[string]interface{}); fixed["freq"] != 500.0 {
+		t.Errorf("fixed section = %v, want it kept", fixed)
+	}
+
+	miner.Fail(http.StatusServiceUnavailable)
+	if err := setMinerPowerTarget(ctx, miner.Addr(), 2000); err == nil {
+		t.Error("power target reported set on a failing miner")
+	}
+}
+
+func TestControlShelly(t *testing.T) {
+	shelly := testsupport.NewShelly("shellypro1pm-test")
+	defer shelly.Close()
+	ctx := context.Background()
+
+	steps := []struct {
+		on          bool
+		wantToggles int
+	}{
+		{true, 1},
+		{true, 1}, // already on: no toggle
+		{false, 2},
+		{false, 2},
+	}
+	for _, s := range steps {
+		if err := controlShelly(ctx, shelly.Addr(), s.on); err != nil {
+			t.Fatal(err)
+		}
+		if shelly.Output() != s.on || shelly.Toggles() != s.wantToggles {
+			t.Fatalf("after switching %s: output %v with %d toggles, want %v with %d",
+				onOff(s.on), shelly.Output(), shelly.Toggles(), s.on, s.wantToggles)
+		}
+	}
+	if on, ok := shellyWatch.requestedState(shelly.Addr()); !ok || on {
+		t.Errorf("requested state = %v, %v; want off recorded", on, ok)
+	}
+
+	shelly.Fail(http.StatusInternalServerError)
+	if err := controlShelly(ctx, shelly.Addr(), true); err == nil {
+		t.Error("switch reported done on a failing Shelly")
+	}
+}
+
+// bulkRequest posts body as JSON to a bulk handler and decodes the response.
+func bulkRequest(t *testing.T, handler gin.HandlerFunc, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/", handler)
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(data)))
+	var resp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response %q: %v", w.Body.String(), err)
+	}
+	return w.Code, resp
+}
+
+// runQueuedJob runs the next queued job to completion and returns it.
+func runQueuedJob(t *testing.T) *db.Job {
+	t.Helper()
+	defer func(n int) { jobConcurrency = n }(jobConcurrency)
+	jobConcurrency = 2
+	job, err := database.ClaimNextJob()
+	if err != nil || job == nil {
+		t.Fatalf("no job to run: %v", err)
+	}
+	jobs.execute(job)
+	done, err := database.FetchJob(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return done
+}
+
+func TestBulkPowerHandler(t *testing.T) {
+	useTestDatabase(t)
+	useMinerCredentials(t, "root", "secret")
+
+	a, b := testsupport.NewMiner("root", "secret"), testsupport.NewMiner("root", "secret")
+	defer a.Close()
+	defer b.Close()
+	useMachines(t, db.Machine{Name: "a", IP: a.Addr()}, db.Machine{Name: "b", IP: b.Addr()})
+	ips := []string{a.Addr(), b.Addr()}
+
+	code, resp := bulkRequest(t, setAllMinersPowerHandler, gin.H{"ips": ips, "power": 3000, "dryRun": true})
+	if code != http.StatusOK || resp["changing"] != 0.0 || len(resp["unreachable"].([]interface{})) != 0 {
+		t.Fatalf("dry run at the current target = %d %v, want no changes", code, resp)
+	}
+	b.Fail(http.StatusBadGateway)
+	code, resp = bulkRequest(t, setAllMinersPowerHandler, gin.H{"ips": ips, "power": 2500, "dryRun": true})
+	if code != http.StatusOK || resp["changing"] != 1.0 || len(resp["unreachable"].([]interface{})) != 1 {
+		t.Fatalf("dry run = %d %v, want one change and one unreachable miner", code, resp)
+	}
+	if len(a.Posts()) != 0 {
+		t.Fatalf("dry run changed a miner: %v", a.Posts())
+	}
+	b.Fail(0)
+
+	code, resp = bulkRequest(t, setAllMinersPowerHandler, gin.H{"ips": ips, "power": 2500})
+	if code != http.StatusAccepted || resp["count"] != 2.0 {
+		t.Fatalf("bulk power = %d %v, want a queued job for 2 miners", code, resp)
+	}
+	job := runQueuedJob(t)
+	if job.Status != db.JobDone {
+		t.Fatalf("job = %+v, want done", job)
+	}
+	for _, m := range []*testsupport.Miner{a, b} {
+		concorde, _ := m.Mode()["concorde"].(map[string]interface{})
+		if concorde["power-target"] != 2500.0 {
+			t.Errorf("miner %s power target = %v, want 2500", m.Addr(), concorde["power-target"])
+		}
+	}
+
+	code, _ = bulkRequest(t, setAllMinersPowerHandler, gin.H{"power": 2500})
+	if code != http.StatusBadRequest {
+		t.Errorf("bulk power without targets = %d, want 400", code)
+	}
+}
+
+func TestBulkShutdownHandler(t *testing.T) {
+	useTestDatabase(t)
+	on, failing := testsupport.NewShelly("shelly-on"), testsupport.NewShelly("shelly-failing")
+	defer on.Close()
+	defer failing.Close()
+	on.SetOutput(true)
+	failing.SetOutput(true)
+	failing.Fail(http.StatusServiceUnavailable)
+	useMachines(t,
+		db.Machine{Name: "a", IP: "192.0.2.10", ShellyIP: on.Addr()},
+		db.Machine{Name: "b", IP: "192.0.2.11", ShellyIP: failing.Addr()})
+
+	code, resp := bulkRequest(t, shutdownAllMinersHandler, gin.H{"ips": []string{"192.0.2.10", "192.0.2.11"}})
+	if code != http.StatusAccepted {
+		t.Fatalf("bulk shutdown = %d %v, want a queued job", code, resp)
+	}
+	job := runQueuedJob(t)
+	if on.Output() {
+		t.Error("relay of a left on")
+	}
+	if job.Status != db.JobFailed {
+		t.Errorf("job status = %s, want failed for the unreachable Shelly", job.Status)
+	}
+	for _, target := range job.Targets {
+		want := db.TargetDone
+		if target.IP == "192.0.2.11" {
+			want = db.TargetFailed
+		}
+		if target.Status != want {
+			t.Errorf("target %s = %s, want %s", target.IP, target.Status, want)
+		}
+	}
+}
+
+func TestStatusHandlerReadsQuestDB(t *testing.T) {
+	useTestDatabase(t)
+	qdb := testsupport.NewQuestDB()
+	defer qdb.Close()
+	prev := questdbClient
+	questdbClient = questdb.NewClient(qdb.HostPort())
+	defer func() { questdbClient = prev }()
+
+	now := schema.FormatTime(time.Now())
+	qdb.Respond("FROM pools", []string{"timestamp", "value"}, []interface{}{now, 200000.0})
+	qdb.Respond("FROM shellies", []string{"timestamp", "value"}, []interface{}{now, 6000.0})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", getStatusHandler)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var resp struct {
+		Online     bool    `json:"online"`
+		Hashrate   float64 `json:"hashrate"`
+		Power      float64 `json:"power"`
+		Efficiency float64 `json:"efficiency"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Online || resp.Hashrate != 200000 || resp.Power != 6000 || resp.Efficiency != 30 {
+		t.Errorf("status = %+v, want online at 200 TH/s, 6000 W and 30 J/TH", resp)
+	}
+	if len(qdb.Queries()) == 0 {
+		t.Error("no queries reached QuestDB")
+	}
+}
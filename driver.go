@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// MinerCapabilities flags the controls a firmware supports so the manage UI
+// renders only valid ones.
+type MinerCapabilities struct {
+	SupportsPowerTarget bool `json:"supportsPowerTarget"`
+	SupportsFreqVolt    bool `json:"supportsFreqVolt"`
+	SupportsSleep       bool `json:"supportsSleep"`
+	SupportsReboot      bool `json:"supportsReboot"`
+	SupportsRestart     bool `json:"supportsRestart"`
+	SupportsFanControl  bool `json:"supportsFanControl"`
+	SupportsPools       bool `json:"supportsPools"`
+	GPU                 bool `json:"gpu"` // GPU rig: metrics in gpu_status, no ASIC controls
+}
+
+// MinerIdentity is what a miner reports about itself when probed.
+type MinerIdentity struct {
+	Model           string  `json:"model"`
+	FirmwareVersion string  `json:"firmwareVersion"`
+	MAC             string  `json:"mac,omitempty"`   // empty when the firmware doesn't report it
+	NominalHashrate float64 `json:"nominalHashrate"` // GH/s at stock settings, 0 when unknown
+}
+
+// minerDriver controls miners of one firmware family. Handlers and background
+// loops go through driverFor instead of calling a firmware API directly.
+// Calls give up when ctx is done; handlers pass the request context.
+type minerDriver interface {
+	// Config reads the current work mode and targets.
+	Config(ctx context.Context, ip string) (*MinerManageInfo, error)
+	SetPowerTarget(ctx context.Context, ip string, power int) error
+	SetFreqVolt(ctx context.Context, ip string, freq, volt float64) error
+	SetSleep(ctx context.Context, ip string) error
+	// RestartMining restarts the mining process; the control board stays up.
+	RestartMining(ctx context.Context, ip string) error
+	// Reboot reboots the control board.
+	Reboot(ctx context.Context, ip string) error
+	// Pools reads the connection state and share counters of the configured pools.
+	Pools(ctx context.Context, ip string) ([]PoolInfo, error)
+	// SSHCommands lists the shell commands that may be run over SSH.
+	SSHCommands() map[string]sshCommand
+	// Capabilities reports which controls the firmware supports.
+	Capabilities() MinerCapabilities
+	// Identify reads the model, firmware version and nominal hashrate.
+	Identify(ctx context.Context, ip string) (*MinerIdentity, error)
+}
+
+// kaonsuDriver talks to the kaonsu HTTP API of stock firmware units.
+type kaonsuDriver struct{}
+
+func (kaonsuDriver) Config(ctx context.Context, ip string) (*MinerManageInfo, error) {
+	return fetchMinerConfig(ctx, ip)
+}
+
+func (kaonsuDriver) SetPowerTarget(ctx context.Context, ip string, power int) error {
+	return setMinerPowerTarget(ctx, ip, power)
+}
+
+func (kaonsuDriver) SetFreqVolt(ctx context.Context, ip string, freq, volt float64) error {
+	return setMinerFreqVolt(ctx, ip, freq, volt)
+}
+
+func (kaonsuDriver) SetSleep(ctx context.Context, ip string) error { return setMinerSleepMode(ctx, ip) }
+
+// RestartMining is not offered by the kaonsu API; a hung unit needs a reboot.
+func (kaonsuDriver) RestartMining(ctx context.Context, ip string) error {
+	return errors.New("restarting the miner process is not supported on stock firmware; reboot instead")
+}
+
+func (d kaonsuDriver) Reboot(ctx context.Context, ip string) error {
+	return runDriverCommand(ctx, ip, d, "reboot", nil)
+}
+
+func (kaonsuDriver) Pools(ctx context.Context, ip string) ([]PoolInfo, error) {
+	return kaonsuPools(ctx, ip)
+}
+
+func (kaonsuDriver) SSHCommands() map[string]sshCommand {
+	return map[string]sshCommand{
+		"reboot": {Command: "reboot", Description: "Reboot the control board", Destructive: true},
+		"logs":   {Command: "tail -n 200 /var/log/messages", Description: "Last 200 lines of the system log"},
+		"dmesg":  {Command: "dmesg | tail -n 200", Description: "Last 200 kernel messages"},
+	}
+}
+
+func (kaonsuDriver) Capabilities() MinerCapabilities {
+	return MinerCapabilities{SupportsPowerTarget: true, SupportsFreqVolt: true, SupportsSleep: true, SupportsReboot: true, SupportsPools: true}
+}
+
+// Identify reads the stock cgminer API; the kaonsu API has no device info.
+func (kaonsuDriver) Identify(ctx context.Context, ip string) (*MinerIdentity, error) {
+	return cgminerIdentity(ctx, ip)
+}
+
+// drivers maps the machine firmware field to its driver.
+var drivers = map[string]minerDriver{
+	"kaonsu":   kaonsuDriver{},
+	"braiins":  braiinsDriver{},
+	"luxos":    luxosDriver{},
+	"vnish":    vnishDriver{},
+	"avalon":   avalonDriver{},
+	"iceriver": iceriverDriver{},
+	"rigel":    gpuDriver{software: "Rigel", stats: rigelStats},
+	"trex":     gpuDriver{software: "T-Rex", stats: trexStats},
+}
+
+// driverFor returns the driver for a miner's configured firmware, defaulting to
+// kaonsu for unknown machines.
+func driverFor(ip string) minerDriver {
+	for _, m := range currentMachines() {
+		if m.IP == ip {
+			if d, ok := drivers[m.Firmware]; ok {
+				return d
+			}
+		}
+	}
+	return kaonsuDriver{}
+}
+
+// powerProfile is a firmware preset with its rated power draw.
+type powerProfile struct {
+	Name  string
+	Watts float64
+}
+
+// pickProfile returns the highest-power profile that stays within the power
+// target, or the lowest profile if none does.
+func pickProfile(profiles []powerProfile, power int) (powerProfile, error) {
+	if len(profiles) == 0 {
+		return powerProfile{}, errors.New("no power profiles available")
+	}
+	best, lowest := -1, 0
+	for i, p := range profiles {
+		if p.Watts < profiles[lowest].Watts {
+			lowest = i
+		}
+		if p.Watts <= float64(power) && (best < 0 || p.Watts > profiles[best].Watts) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return profiles[lowest], nil
+	}
+	return profiles[best], nil
+}
+
+// profileWatts returns the rated power of the named profile, or 0.
+func profileWatts(profiles []powerProfile, name string) float64 {
+	for _, p := range profiles {
+		if p.Name == name {
+			return p.Watts
+		}
+	}
+	return 0
+}
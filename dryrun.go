@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PlannedChange is the dry-run result for one miner: its current settings, the
+// settings the operation would apply and whether anything would change.
+type PlannedChange struct {
+	IP        string                 `json:"ip"`
+	Name      string                 `json:"name"`
+	Reachable bool                   `json:"reachable"`
+	Error     string                 `json:"error,omitempty"`
+	Current   map[string]interface{} `json:"current,omitempty"`
+	Requested map[string]interface{} `json:"requested"`
+	Changes   bool                   `json:"changes"`
+}
+
+// dryRunRoutes lists the POST routes, relative to /api, whose handlers honor
+// dryRun and so change nothing when it is set.
+var dryRunRoutes = map[string]bool{
+	"/miners/power":          true,
+	"/miners/freq":           true,
+	"/miners/sleep":          true,
+	"/miners/start":          true,
+	"/miners/shutdown":       true,
+	"/templates/:name/apply": true,
+}
+
+// isDryRun reports whether the request is a dry run of a route that honors
+// one, restoring the body for the handler. The body is read the way the
+// handlers bind it, so a request counted as a dry run here is one there.
+func isDryRun(c *gin.Context) bool {
+	if c.Request.Method != http.MethodPost || !dryRunRoutes[apiRoute(c.FullPath())] {
+		return false
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	var req struct {
+		DryRun bool `json:"dryRun"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false
+	}
+	return req.DryRun
+}
+
+// minerName returns the configured name for a miner IP, or the IP itself.
+func minerName(ip string) string {
+	for _, m := range currentMachines() {
+		if m.IP == ip {
+			return m.Name
+		}
+	}
+	return ip
+}
+
+// planConfigChange reads the miner's current mode config and compares it to the
+// requested values. Keys of requested must match the keys built here.
+func planConfigChange(ctx context.Context, ip string, requested map[string]interface{}) PlannedChange {
+	p := PlannedChange{IP: ip, Name: minerName(ip), Requested: requested}
+
+	info, err := driverFor(ip).Config(ctx, ip)
+	if err != nil {
+		p.Error = err.Error()
+		return p
+	}
+	p.Reachable = true
+	p.Current = map[string]interface{}{"workMode": info.WorkMode}
+	switch info.WorkMode {
+	case "Auto":
+		p.Current["modeSelect"] = info.ModeSelect
+		p.Current["targetValue"] = info.TargetValue
+	case "Fixed":
+		p.Current["freq"] = info.TargetFreq
+		p.Current["volt"] = info.TargetVolt
+	}
+
+	for k, v := range requested {
+		if cur, ok := p.Current[k]; !ok || !sameValue(cur, v) {
+			p.Changes = true
+			break
+		}
+	}
+	return p
+}
+
+// sameValue compares config values, treating all numbers as float64.
+func sameValue(a, b interface{}) bool {
+	toFloat := func(v interface{}) (float64, bool) {
+		switch n := v.(type) {
+		case float64:
+			return n, true
+		case int:
+			return float64(n), true
+		}
+		return 0, false
+	}
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return a == b
+}
+
+// planRelayChange checks the miner's outlet relay against the requested state.
+func planRelayChange(ctx context.Context, ip string, on bool) PlannedChange {
+	p := PlannedChange{IP: ip, Name: minerName(ip), Requested: map[string]interface{}{"relayOn": on}}
+
+	outlet, err := outletForMiner(ip)
+	if err != nil {
+		p.Error = err.Error()
+		return p
+	}
+	current, err := outlet.Status(ctx)
+	if err != nil {
+		p.Error = err.Error()
+		return p
+	}
+	p.Reachable = true
+	p.Current = map[string]interface{}{"relayOn": current.On}
+	p.Changes = current.On != on
+	return p
+}
+
+// respondDryRun plans the operation for every IP in parallel and writes the plan
+// without applying anything. warnings lists conditions that would block or alter
+// the real operation.
+func respondDryRun(c *gin.Context, ips []string, plan func(ip string) PlannedChange, warnings ...string) {
+	changes := make([]PlannedChange, len(ips))
+	var wg sync.WaitGroup
+	for i, ip := range ips {
+		wg.Add(1)
+		go func(i int, ip string) {
+			defer wg.Done()
+			changes[i] = plan(ip)
+		}(i, ip)
+	}
+	wg.Wait()
+
+	unreachable := []string{}
+	changing := 0
+	for _, p := range changes {
+		if !p.Reachable {
+			unreachable = append(unreachable, p.IP)
+		}
+		if p.Changes {
+			changing++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dryRun":      true,
+		"ips":         ips,
+		"count":       len(ips),
+		"changing":    changing,
+		"unreachable": unreachable,
+		"warnings":    warnings,
+		"plan":        changes,
+	})
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// poolEarningsStore is a store with the pool's actual earnings; only QuestDB
+// has them, from the nicehash-telegraf poller.
+type poolEarningsStore interface {
+	GetNiceHashEarnings() (*questdb.PoolEarnings, error)
+}
+
+// fetchPoolRevenue returns what the pool credited over the last 24 hours and
+// its value in EUR at the current BTC price; nil earnings when no pool
+// integration has them.
+func fetchPoolRevenue(store TimeseriesStore) (*questdb.PoolEarnings, float64, error) {
+	s, ok := store.(poolEarningsStore)
+	if !ok {
+		return nil, 0, nil
+	}
+	earnings, err := s.GetNiceHashEarnings()
+	if err != nil || earnings == nil {
+		return nil, 0, err
+	}
+	price, err := fetchBTCPriceEUR()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch BTC price: %w", err)
+	}
+	return earnings, math.Round(earnings.BTC*price*100) / 100, nil
+}
+
+// dailyRevenueEUR is the pool's actual revenue over the last 24 hours where a
+// pool integration has it, and the estimate for hashrateTH (TH/s) otherwise.
+func dailyRevenueEUR(store TimeseriesStore, hashrateTH float64) float64 {
+	if earnings, eur, err := fetchPoolRevenue(store); err != nil {
+		log.Printf("Failed to get pool earnings: %v", err)
+	} else if earnings != nil {
+		return eur
+	}
+	return calculateDailyRevenueEUR(hashrateTH)
+}
+
+// BreakEvenInfo describes the profitability thresholds for a miner or the whole fleet.
+type BreakEvenInfo struct {
+	Name               string  `json:"name"`
+	IP                 string  `json:"ip,omitempty"`
+	HashrateTH         float64 `json:"hashrateTh"`
+	PowerW             float64 `json:"powerW"`
+	Efficiency         float64 `json:"efficiency"`         // J/TH
+	DailyRevenueEUR    float64 `json:"dailyRevenueEur"`    // at current BTC price and network hashrate
+	DailyCostEUR       float64 `json:"dailyCostEur"`       // at the configured electricity price
+	DailyMarginEUR     float64 `json:"dailyMarginEur"`     // revenue - cost
+	BreakEvenElecPrice float64 `json:"breakEvenElecPrice"` // €/kWh at which margin is zero
+	BreakEvenBTCPrice  float64 `json:"breakEvenBtcPrice"`  // € per BTC at which margin is zero
+	ShouldRun          bool    `json:"shouldRun"`
+	HasData            bool    `json:"hasData"`
+}
+
+// computeBreakEven calculates break-even thresholds for the given hashrate (TH/s) and
+// power (W) at the current market conditions and configured electricity price.
+func computeBreakEven(market *miningMarket, hashrateTH, powerW float64) BreakEvenInfo {
+	info := BreakEvenInfo{
+		HashrateTH: hashrateTH,
+		PowerW:     powerW,
+	}
+	if hashrateTH <= 0 || powerW <= 0 {
+		return info
+	}
+
+	dailyBTC := hashrateTH * market.dailyBTCPerTH()
+	dailyKWh := powerW / 1000 * 24
+
+	info.HasData = true
+	info.Efficiency = math.Round(powerW/hashrateTH*10) / 10
+	info.DailyRevenueEUR = math.Round(dailyBTC*market.BTCPriceEUR*100) / 100
+	info.DailyCostEUR = math.Round(dailyKWh*elecPrice*100) / 100
+	info.DailyMarginEUR = math.Round((dailyBTC*market.BTCPriceEUR-dailyKWh*elecPrice)*100) / 100
+	info.BreakEvenElecPrice = math.Round(dailyBTC*market.BTCPriceEUR/dailyKWh*10000) / 10000
+	info.BreakEvenBTCPrice = math.Round(dailyKWh * elecPrice / dailyBTC)
+	info.ShouldRun = shouldMinerRun(market, hashrateTH, powerW)
+	return info
+}
+
+// shouldMinerRun reports whether a miner with the given hashrate (TH/s) and power (W)
+// currently earns more than its electricity cost.
+func shouldMinerRun(market *miningMarket, hashrateTH, powerW float64) bool {
+	if market == nil || hashrateTH <= 0 || powerW <= 0 {
+		return false
+	}
+	revenue := hashrateTH * market.dailyBTCPerTH() * market.BTCPriceEUR
+	cost := powerW / 1000 * 24 * elecPrice
+	return revenue > cost
+}
+
+func getBreakEvenHandler(c *gin.Context) {
+	market, err := fetchMiningMarket()
+	if err != nil {
+		log.Printf("Failed to fetch mining market data: %v", err)
+		c.JSON(http.StatusOK, gin.H{
+			"miners":  []interface{}{},
+			"hasData": false,
+		})
+		return
+	}
+
+	statuses, err := storeFor(c).GetMinerStatuses()
+	if err != nil {
+		log.Printf("Failed to get miner statuses from QuestDB: %v", err)
+	}
+
+	ms := machinesFor(c)
+	miners := make([]BreakEvenInfo, 0, len(ms))
+	for _, m := range ms {
+		hashrateTH, powerW := 0.0, 0.0
+		if statuses != nil {
+			for _, s := range statuses.Miners {
+				if s.MinerIP == m.IP {
+					hashrateTH = s.Hashrate / 1000 // GH/s to TH/s
+					powerW = s.Power
+					break
+				}
+			}
+		}
+		info := computeBreakEven(market, hashrateTH, powerW)
+		info.Name = m.Name
+		info.IP = m.IP
+		miners = append(miners, info)
+	}
+
+	sort.Slice(miners, func(i, j int) bool {
+		return miners[i].Name < miners[j].Name
+	})
+
+	// Fleet-wide figures use the same totals as the gauges
+	fleetHashrate, fleetPower := 0.0, 0.0
+	if result, err := storeFor(c).GetTotalHashrate(); err != nil {
+		log.Printf("Failed to get hashrate from QuestDB: %v", err)
+	} else if result.HasData {
+		fleetHashrate = result.TotalHashrate / 1000
+	}
+	if result, err := storeFor(c).GetTotalPower(); err != nil {
+		log.Printf("Failed to get power from QuestDB: %v", err)
+	} else if result.HasData {
+		fleetPower = result.TotalPower
+	}
+	fleet := computeBreakEven(market, fleetHashrate, fleetPower)
+	fleet.Name = "fleet"
+
+	c.JSON(http.StatusOK, gin.H{
+		"miners":      miners,
+		"fleet":       fleet,
+		"elecPrice":   elecPrice,
+		"btcPriceEur": market.BTCPriceEUR,
+		"assumptions": market.assumptions(),
+		"hasData":     true,
+	})
+}
+
+// MinerEconomics attributes electricity cost and revenue to a single machine.
+type MinerEconomics struct {
+	Name            string  `json:"name"`
+	IP              string  `json:"ip"`
+	ShellyIP        string  `json:"shellyIp"`
+	PowerSource     string  `json:"powerSource"` // "shelly" or "outlet" (24h average) or "miner" (reported power)
+	PowerW          float64 `json:"powerW"`
+	DailyEnergyKWh  float64 `json:"dailyEnergyKwh"`
+	DailyCostEUR    float64 `json:"dailyCostEur"`
+	HashrateTH      float64 `json:"hashrateTh"`
+	HashrateShare   float64 `json:"hashrateShare"` // fraction of fleet hashrate
+	DailyRevenueEUR float64 `json:"dailyRevenueEur"`
+	DailyMarginEUR  float64 `json:"dailyMarginEur"`
+	Profitable      bool    `json:"profitable"`
+	HasData         bool    `json:"hasData"`
+}
+
+// minerEconomicsSorts are the supported ?sort= keys for /api/economics/per-miner.
+var minerEconomicsSorts = map[string]func(a, b MinerEconomics) bool{
+	"margin":  func(a, b MinerEconomics) bool { return a.DailyMarginEUR < b.DailyMarginEUR },
+	"cost":    func(a, b MinerEconomics) bool { return a.DailyCostEUR > b.DailyCostEUR },
+	"revenue": func(a, b MinerEconomics) bool { return a.DailyRevenueEUR > b.DailyRevenueEUR },
+	"name":    func(a, b MinerEconomics) bool { return a.Name < b.Name },
+}
+
+// getPerMinerEconomicsHandler attributes cost to each machine from its mapped
+// Shelly's 24h average power (falling back to miner-reported power) and splits
+// fleet revenue by hashrate share. Sorted by ?sort= (default "margin", worst first).
+func getPerMinerEconomicsHandler(c *gin.Context) {
+	sortKey := c.DefaultQuery("sort", "margin")
+	less, ok := minerEconomicsSorts[sortKey]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be one of margin, cost, revenue, name"})
+		return
+	}
+
+	market, err := fetchMiningMarket()
+	if err != nil {
+		log.Printf("Failed to fetch mining market data: %v", err)
+		c.JSON(http.StatusOK, gin.H{
+			"miners":  []interface{}{},
+			"hasData": false,
+		})
+		return
+	}
+
+	statuses, err := storeFor(c).GetMinerStatuses()
+	if err != nil {
+		log.Printf("Failed to get miner statuses from QuestDB: %v", err)
+	}
+	devicePower, err := questdbFor(c).GetDeviceAveragePower(24)
+	if err != nil {
+		log.Printf("Failed to get device power from QuestDB: %v", err)
+	}
+
+	ms := machinesFor(c)
+	deviceIDs := outletDeviceIDs(ms)
+
+	miners := make([]MinerEconomics, 0, len(ms))
+	fleetHashrate := 0.0
+	for i, m := range ms {
+		info := MinerEconomics{Name: m.Name, IP: m.IP, ShellyIP: m.ShellyIP}
+		if statuses != nil {
+			for _, s := range statuses.Miners {
+				if s.MinerIP == m.IP {
+					info.HashrateTH = s.Hashrate / 1000 // GH/s to TH/s
+					info.PowerW = s.Power
+					info.PowerSource = "miner"
+					break
+				}
+			}
+		}
+		if w, ok := devicePower[deviceIDs[i]]; ok && deviceIDs[i] != "" {
+			info.PowerW = w
+			info.PowerSource = "shelly"
+			if m.Outlet != "" {
+				info.PowerSource = "outlet"
+			}
+		}
+		fleetHashrate += info.HashrateTH
+		miners = append(miners, info)
+	}
+
+	fleetRevenue := fleetHashrate * market.dailyBTCPerTH() * market.BTCPriceEUR
+	for i := range miners {
+		m := &miners[i]
+		m.DailyEnergyKWh = math.Round(m.PowerW/1000*24*100) / 100
+		cost := m.PowerW / 1000 * 24 * elecPrice
+		revenue := 0.0
+		if fleetHashrate > 0 {
+			m.HashrateShare = math.Round(m.HashrateTH/fleetHashrate*1000) / 1000
+			revenue = fleetRevenue * m.HashrateTH / fleetHashrate
+		}
+		m.DailyCostEUR = math.Round(cost*100) / 100
+		m.DailyRevenueEUR = math.Round(revenue*100) / 100
+		m.DailyMarginEUR = math.Round((revenue-cost)*100) / 100
+		m.Profitable = revenue > cost
+		m.HasData = m.PowerW > 0 || m.HashrateTH > 0
+		m.PowerW = math.Round(m.PowerW)
+		m.HashrateTH = math.Round(m.HashrateTH*10) / 10
+	}
+
+	sort.SliceStable(miners, func(i, j int) bool {
+		return less(miners[i], miners[j])
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"miners":      miners,
+		"sort":        sortKey,
+		"elecPrice":   elecPrice,
+		"btcPriceEur": market.BTCPriceEUR,
+		"assumptions": market.assumptions(),
+		"hasData":     true,
+	})
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"miningRoom/db"
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Emergency settings. Smoke and CO detectors report through the MQTT sensor
+// bridge (Zigbee2MQTT smoke/carbon_monoxide), Shelly Plus Smoke notifications,
+// Shelly inputs wired to a detector's alarm relay, or nodes pushing
+// smoke_alarms measurements (e.g. an ESP32 reading a GPIO).
+var (
+	smokeInputs     string // comma-separated <device id>/input:<n>
+	emergencyEmails string // comma-separated; empty uses --report-to
+	emergencySecret string // signs the emergency stop button token; empty disables GET /api/emergency/stop
+)
+
+// emergencyRelayAttempts is how often a relay is switched off and read back
+// before it is reported as failed.
+const emergencyRelayAttempts = 3
+
+// emergencySleepTimeout bounds the sleep command sent to each miner before
+// the relays are cut, so a hung miner cannot delay the cut.
+const emergencySleepTimeout = 2 * time.Second
+
+// EmergencyResult is the outcome of the shutdown sequence for one Shelly
+// relay. Kind is "miner" or the actuator kind.
+type EmergencyResult struct {
+	Name       string `json:"name"`
+	Kind       string `json:"kind"`
+	IP         string `json:"ip,omitempty"` // miner IP
+	ShellyIP   string `json:"shellyIp"`
+	Outlet     string `json:"outlet,omitempty"` // PDU or plug outlet instead of a Shelly
+	Slept      bool   `json:"slept"`
+	RelayOff   bool   `json:"relayOff"` // read back as off
+	Attempts   int    `json:"attempts"`
+	SleepError string `json:"sleepError,omitempty"`
+	RelayError string `json:"relayError,omitempty"`
+
+	outlet outletController
+}
+
+// emergencyState caches the persisted lockout and the detectors currently in
+// alarm.
+type emergencyState struct {
+	runMu    sync.Mutex // serializes shutdown sequences
+	mu       sync.Mutex
+	lockout  *db.Lockout
+	alarming map[string]string // sensor -> kind
+	results  []EmergencyResult // last shutdown sequence
+}
+
+var emergency = &emergencyState{alarming: make(map[string]string)}
+
+// load restores the lockout from the database on startup.
+func (e *emergencyState) load() error {
+	lockout, err := database.FetchLockout()
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.lockout = lockout
+	e.mu.Unlock()
+
+	if lockout != nil && lockout.Active {
+		alerts.raise("emergency", severityCritical, "emergency", fmt.Sprintf(
+			"Emergency lockout active since %s: %s", lockout.TriggeredAt.Local().Format("2006-01-02 15:04"), lockout.Reason))
+	}
+	return nil
+}
+
+// locked reports whether miner starts are refused.
+func (e *emergencyState) locked() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.lockout != nil && e.lockout.Active
+}
+
+// trigger shuts the fleet down unless a lockout is already active, so repeated
+// detector reports don't rerun the sequence.
+func (e *emergencyState) trigger(source, reason string) {
+	e.stop(source, reason, false)
+}
+
+// stop locks out miner starts, notifies and runs the shutdown sequence. While
+// locked out the sequence only runs again if rerun is set (manual stops); nil
+// is returned otherwise.
+func (e *emergencyState) stop(source, reason string, rerun bool) []EmergencyResult {
+	e.runMu.Lock()
+	defer e.runMu.Unlock()
+
+	now := time.Now()
+	e.mu.Lock()
+	wasLocked := e.lockout != nil && e.lockout.Active
+	if !wasLocked {
+		e.lockout = &db.Lockout{Active: true, Source: source, Reason: reason, TriggeredAt: now}
+	}
+	e.mu.Unlock()
+
+	if wasLocked {
+		if !rerun {
+			return nil
+		}
+		recordEvent("emergency", "shutdown sequence repeated (%s): %s", source, reason)
+	} else {
+		if err := database.SaveLockout(source, reason, now); err != nil {
+			log.Printf("Emergency: failed to persist lockout: %v", err)
+		}
+		message := fmt.Sprintf("Emergency shutdown (%s): %s", source, reason)
+		alerts.raise("emergency", severityCritical, "emergency", message)
+		go notifyEmergency(message)
+	}
+
+	results := shutdownFleet()
+	e.mu.Lock()
+	e.results = results
+	e.mu.Unlock()
+
+	var failed []string
+	for _, r := range results {
+		if !r.RelayOff {
+			failed = append(failed, r.Name)
+		}
+	}
+	if len(failed) > 0 {
+		recordEvent("emergency", "shutdown finished; relays of %s could not be verified off", strings.Join(failed, ", "))
+		go notifyEmergency(fmt.Sprintf("Emergency shutdown incomplete: relays of %s could not be verified off", strings.Join(failed, ", ")))
+	} else {
+		recordEvent("emergency", "shutdown finished; all %d relays verified off", len(results))
+	}
+	return results
+}
+
+// shutdownFleet puts every miner to sleep so the hashboards stop before power
+// is cut, then switches off the miner relays followed by the actuator relays.
+// Relays are cut even if the sleep command failed or did not answer within
+// emergencySleepTimeout, and each one is read back to verify it is off.
+func shutdownFleet() []EmergencyResult {
+	var miners []EmergencyResult
+	for _, m := range currentMachines() {
+		r := EmergencyResult{Name: m.Name, Kind: "miner", IP: m.IP, ShellyIP: m.ShellyIP}
+		if outlet, err := outletFor(m); err == nil {
+			r.outlet = outlet
+			if !isShellyOutlet(outlet) {
+				r.Outlet = outlet.Key()
+			}
+		} else if !errors.Is(err, errNoOutlet) {
+			r.RelayError = err.Error()
+		}
+		miners = append(miners, r)
+	}
+	var wg sync.WaitGroup
+	for i := range miners {
+		wg.Add(1)
+		go func(r *EmergencyResult) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), emergencySleepTimeout)
+			defer cancel()
+			if err := driverFor(r.IP).SetSleep(ctx, r.IP); err != nil {
+				r.SleepError = err.Error()
+			} else {
+				r.Slept = true
+			}
+		}(&miners[i])
+	}
+	wg.Wait()
+	cutRelays(miners)
+
+	var others []EmergencyResult
+	actuators, err := database.FetchActuators()
+	if err != nil {
+		log.Printf("Emergency: failed to fetch actuators: %v", err)
+	}
+	for _, a := range actuators {
+		r := EmergencyResult{Name: a.Name, Kind: a.Kind, ShellyIP: a.ShellyIP}
+		if a.ShellyIP != "" {
+			r.outlet = shellyOutlet{ip: a.ShellyIP}
+		}
+		others = append(others, r)
+	}
+	cutRelays(others)
+
+	return append(miners, others...)
+}
+
+// cutRelays switches the relays off in parallel and reads each back, retrying
+// up to emergencyRelayAttempts times.
+func cutRelays(results []EmergencyResult) {
+	var wg sync.WaitGroup
+	for i := range results {
+		if results[i].outlet == nil {
+			if results[i].RelayError == "" {
+				results[i].RelayError = errNoOutlet.Error()
+			}
+			continue
+		}
+		wg.Add(1)
+		go func(r *EmergencyResult) {
+			defer wg.Done()
+			for r.Attempts < emergencyRelayAttempts {
+				r.Attempts++
+				if err := r.outlet.Set(context.Background(), false); err != nil {
+					r.RelayError = err.Error()
+				} else if status, err := r.outlet.Status(context.Background()); err != nil {
+					r.RelayError = err.Error()
+				} else if status.On {
+					r.RelayError = "relay still on"
+				} else {
+					r.RelayOff = true
+					r.RelayError = ""
+					return
+				}
+				if r.Attempts < emergencyRelayAttempts {
+					time.Sleep(time.Second)
+				}
+			}
+			log.Printf("Emergency: failed to switch off %s (outlet %s): %s", r.Name, r.outlet.Key(), r.RelayError)
+		}(&results[i])
+	}
+	wg.Wait()
+}
+
+// notifyEmergency emails a high-priority notification.
+func notifyEmergency(message string) {
+	to := splitList(emergencyEmails)
+	if len(to) == 0 {
+		to = splitList(reportRecipients)
+	}
+	if !mailEnabled() || len(to) == 0 {
+		log.Printf("Emergency: no SMTP or recipients configured, notification not sent")
+		return
+	}
+	body := fmt.Sprintf("<p><strong>%s</strong></p><p>%s</p><p>Miner starts stay locked out until the lockout is reset.</p>",
+		html.EscapeString(message), time.Now().Format("2006-01-02 15:04:05"))
+	if err := sendUrgentMail(to, "EMERGENCY: "+message, body); err != nil {
+		log.Printf("Emergency: failed to send notification: %v", err)
+	}
+}
+
+// isSmokeInput reports whether a Shelly input is wired to a detector's alarm
+// relay rather than a door contact.
+func isSmokeInput(sensor string) bool {
+	for _, s := range splitList(smokeInputs) {
+		if strings.EqualFold(s, sensor) {
+			return true
+		}
+	}
+	return false
+}
+
+// smokePoint is a smoke_alarms reading; kind is "smoke" or "co".
+func smokePoint(sensorID, location, kind string, alarm bool) questdb.Point {
+	return questdb.Point{
+		Table:   "smoke_alarms",
+		Symbols: map[string]string{"sensor_id": sensorID, "location": location, "kind": kind},
+		Fields:  map[string]interface{}{"alarm": alarm},
+	}
+}
+
+// checkSmokeAlarms triggers the emergency shutdown for any smoke_alarms point
+// in alarm. It runs before the points are written so a QuestDB outage can't
+// delay the shutdown.
+func checkSmokeAlarms(points []questdb.Point) {
+	for _, p := range points {
+		if p.Table != "smoke_alarms" {
+			continue
+		}
+		alarm, _ := p.Fields["alarm"].(bool)
+		emergency.setAlarm(p.Symbols["sensor_id"], p.Symbols["location"], p.Symbols["kind"], alarm)
+	}
+}
+
+// setAlarm records a detector's state and triggers on a new alarm.
+func (e *emergencyState) setAlarm(sensor, location, kind string, alarm bool) {
+	if sensor == "" {
+		return
+	}
+	if kind == "" {
+		kind = "smoke"
+	}
+	e.mu.Lock()
+	_, was := e.alarming[sensor]
+	if alarm {
+		e.alarming[sensor] = kind
+	} else {
+		delete(e.alarming, sensor)
+	}
+	e.mu.Unlock()
+
+	if alarm && !was {
+		what := "Smoke"
+		if kind == "co" {
+			what = "Carbon monoxide"
+		}
+		reason := fmt.Sprintf("%s detected by %s", what, sensor)
+		if location != "" && location != sensor {
+			reason += " in " + location
+		}
+		go e.trigger(kind+":"+sensor, reason)
+	} else if !alarm && was {
+		recordEvent("emergency", "%s detector %s no longer in alarm", kind, sensor)
+	}
+}
+
+// activeAlarms returns the detectors currently in alarm.
+func (e *emergencyState) activeAlarms() map[string]string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	result := make(map[string]string, len(e.alarming))
+	for k, v := range e.alarming {
+		result[k] = v
+	}
+	return result
+}
+
+// lineSmokeAlarm extracts the sensor_id tag and alarm field of a smoke_alarms
+// ILP line.
+func lineSmokeAlarm(line string) (sensor, location, kind string, alarm bool) {
+	parts := splitUnescaped(line, ' ')
+	if len(parts) < 2 {
+		return "", "", "", false
+	}
+	tags := splitUnescaped(parts[0], ',')
+	for _, tag := range tags[1:] {
+		k, v, _ := strings.Cut(tag, "=")
+		switch k {
+		case "sensor_id":
+			sensor = v
+		case "location":
+			location = v
+		case "kind":
+			kind = v
+		}
+	}
+	for _, field := range splitUnescaped(parts[1], ',') {
+		if k, v, _ := strings.Cut(field, "="); k == "alarm" {
+			switch v {
+			case "t", "T", "true", "True", "TRUE":
+				alarm = true
+			}
+		}
+	}
+	return sensor, location, kind, alarm
+}
+
+func getEmergencyHandler(c *gin.Context) {
+	emergency.mu.Lock()
+	lockout := emergency.lockout
+	results := emergency.results
+	emergency.mu.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"locked":  lockout != nil && lockout.Active,
+		"lockout": lockout,
+		"alarms":  emergency.activeAlarms(),
+		"results": results,
+	})
+}
+
+// resetEmergencyHandler lifts the lockout. It is refused while a detector is
+// still in alarm.
+func resetEmergencyHandler(c *gin.Context) {
+	if !emergency.locked() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no emergency lockout active"})
+		return
+	}
+	if active := emergency.activeAlarms(); len(active) > 0 {
+		sensors := make([]string, 0, len(active))
+		for s := range active {
+			sensors = append(sensors, s)
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "detectors still in alarm: " + strings.Join(sensors, ", ")})
+		return
+	}
+
+	by := auditActor(c)
+	if err := database.ClearLockout(by); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := emergency.load(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	alerts.clear("emergency")
+	recordEvent("emergency", "lockout reset by %s", by)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// emergencyStopToken is the token of GET /api/emergency/stop, an HMAC of a
+// fixed message so it can be baked into a Shelly Button action URL.
+func emergencyStopToken() string {
+	mac := hmac.New(sha256.New, []byte(emergencySecret))
+	mac.Write([]byte("emergency-stop"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type EmergencyStopRequest struct {
+	Reason string `json:"reason"`
+}
+
+// emergencyStopHandler runs the shutdown sequence and sets the lockout.
+func emergencyStopHandler(c *gin.Context) {
+	var req EmergencyStopRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Reason == "" {
+		req.Reason = "emergency stop requested by " + c.ClientIP()
+	}
+	respondEmergencyStop(c, emergency.stop("manual", req.Reason, true))
+}
+
+// emergencyButtonHandler is the GET variant for physical buttons, which can
+// only call a fixed URL: /api/emergency/stop?token=<token>.
+func emergencyButtonHandler(c *gin.Context) {
+	if emergencySecret == "" {
+		render404(c)
+		return
+	}
+	if !hmac.Equal([]byte(c.Query("token")), []byte(emergencyStopToken())) {
+		recordEvent("emergency", "rejected emergency stop from %s: invalid token", c.ClientIP())
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid token"})
+		return
+	}
+	respondEmergencyStop(c, emergency.stop("button", "emergency stop button pressed ("+c.ClientIP()+")", true))
+}
+
+func respondEmergencyStop(c *gin.Context, results []EmergencyResult) {
+	verified := true
+	for _, r := range results {
+		verified = verified && r.RelayOff
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"verified": verified,
+		"results":  results,
+	})
+}
+
+// getEmergencyButtonHandler returns the stop URL to configure on a button.
+func getEmergencyButtonHandler(c *gin.Context) {
+	if emergencySecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "--emergency-secret is not configured"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"path": "/api/emergency/stop?token=" + emergencyStopToken(),
+	})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recordEvent logs a message and appends it to the event log in SQLite.
+func recordEvent(source, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	log.Printf("Event [%s] %s", source, message)
+	if err := database.AddEvent(source, message); err != nil {
+		log.Printf("Failed to record event: %v", err)
+	}
+}
+
+func getEventsHandler(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+
+	events, err := database.FetchEvents(limit)
+	if err != nil {
+		log.Printf("Failed to fetch events: %v", err)
+		c.JSON(http.StatusOK, gin.H{
+			"events":  []interface{}{},
+			"hasData": false,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events":  events,
+		"hasData": len(events) > 0,
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	feedTables        string // --feeds: comma-separated tables, optionally table=minutes
+	feedMaxAgeMinutes int    // --feed-max-age-minutes
+)
+
+// feedTableName restricts --feeds entries to plain QuestDB table names.
+var feedTableName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// FeedState is the last checked insert recency of one ingested table.
+type FeedState struct {
+	Table         string     `json:"table"`
+	LastInsert    *time.Time `json:"lastInsert,omitempty"` // nil: no rows yet
+	AgeSeconds    float64    `json:"ageSeconds"`
+	MaxAgeMinutes int        `json:"maxAgeMinutes"`
+	Stale         bool       `json:"stale"`
+	Error         string     `json:"error,omitempty"` // QuestDB query failed
+	CheckedAt     time.Time  `json:"checkedAt"`
+}
+
+// feedWatchdog notices ingestion feeds (Telegraf, MQTT bridges, pushed
+// sensors) that stopped delivering, from the newest row of their table.
+type feedWatchdog struct {
+	mu     sync.Mutex
+	limits map[string]int // max age in minutes by table
+	states map[string]FeedState
+}
+
+var feeds = &feedWatchdog{limits: make(map[string]int), states: make(map[string]FeedState)}
+
+// parseFeeds reads --feeds entries: `table` uses defaultMinutes as its max
+// age, `table=minutes` overrides it.
+func parseFeeds(s string, defaultMinutes int) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, entry := range splitList(s) {
+		table, minutes := entry, defaultMinutes
+		if t, m, ok := strings.Cut(entry, "="); ok {
+			n, err := strconv.Atoi(strings.TrimSpace(m))
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid max age %q for %s", m, t)
+			}
+			table, minutes = strings.TrimSpace(t), n
+		}
+		if !feedTableName.MatchString(table) {
+			return nil, fmt.Errorf("invalid table name %q", table)
+		}
+		limits[table] = minutes
+	}
+	return limits, nil
+}
+
+// runFeedWatchdog checks the feeds at the given interval.
+func runFeedWatchdog(limits map[string]int, interval time.Duration) {
+	feeds.mu.Lock()
+	feeds.limits = limits
+	feeds.mu.Unlock()
+
+	feeds.check()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		feeds.check()
+	}
+}
+
+// check queries the last insert of every feed and raises an alert for each
+// one older than its max age. Query errors are recorded but don't raise feed
+// alerts, since an unreachable QuestDB is reported by /api/health.
+func (f *feedWatchdog) check() {
+	f.mu.Lock()
+	limits := f.limits
+	f.mu.Unlock()
+
+	for table, maxAge := range limits {
+		now := time.Now()
+		state := FeedState{Table: table, MaxAgeMinutes: maxAge, CheckedAt: now}
+		last, ok, err := questdbClient.GetLastInsert(table)
+		key := "feed:" + table
+		switch {
+		case err != nil:
+			log.Printf("Feed watchdog: %v", err)
+			state.Error = err.Error()
+		case !ok:
+			state.Stale = true
+			alerts.raise(key, severityWarning, "feeds", fmt.Sprintf("Feed %s has no data", table))
+		default:
+			state.LastInsert = &last
+			state.AgeSeconds = now.Sub(last).Round(time.Second).Seconds()
+			if now.Sub(last) > time.Duration(maxAge)*time.Minute {
+				state.Stale = true
+				alerts.raise(key, severityWarning, "feeds",
+					fmt.Sprintf("Feed %s stopped: last row %s ago (limit %d min)", table, now.Sub(last).Round(time.Minute), maxAge))
+			} else {
+				alerts.clear(key)
+			}
+		}
+
+		f.mu.Lock()
+		f.states[table] = state
+		f.mu.Unlock()
+	}
+}
+
+// list returns the last checked state of every feed, by table name.
+func (f *feedWatchdog) list() []FeedState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	result := make([]FeedState, 0, len(f.states))
+	for _, s := range f.states {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Table < result[j].Table
+	})
+	return result
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConfigDifference is a config field of a miner that differs from the golden
+// config. Paths name nested keys with dots and list items by index, e.g.
+// "pools[0].url".
+type ConfigDifference struct {
+	Path   string      `json:"path"`
+	Golden interface{} `json:"golden"` // null when the golden config lacks the field
+	Value  interface{} `json:"value"`  // null when the miner lacks it
+}
+
+// MinerConfigDiff is how a miner's kaonsu config differs from the golden one.
+type MinerConfigDiff struct {
+	Name        string             `json:"name"`
+	IP          string             `json:"ip"`
+	Online      bool               `json:"online"`
+	Error       string             `json:"error,omitempty"` // why the config couldn't be read
+	Differences []ConfigDifference `json:"differences"`
+}
+
+// ConfigDiffRequest compares the fleet to a template config instead of a
+// golden machine.
+type ConfigDiffRequest struct {
+	Template map[string]interface{} `json:"template" binding:"required"` // a kaonsu miner_config document
+	Sections []string               `json:"sections"`                    // top-level sections to compare; empty compares all
+	Ignore   []string               `json:"ignore"`                      // path patterns to skip, * matching any text
+}
+
+// flattenConfig collects the leaf values of a decoded JSON document by path.
+func flattenConfig(prefix string, v interface{}, out map[string]interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			out[prefix] = v
+		}
+		for k, child := range v {
+			path := k
+			if prefix != "" {
+				path = prefix + "." + k
+			}
+			flattenConfig(path, child, out)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			out[prefix] = v
+		}
+		for i, child := range v {
+			flattenConfig(fmt.Sprintf("%s[%d]", prefix, i), child, out)
+		}
+	default:
+		out[prefix] = v
+	}
+}
+
+// configPatterns compiles path patterns in which * matches any text.
+func configPatterns(patterns []string) []*regexp.Regexp {
+	var res []*regexp.Regexp
+	for _, p := range patterns {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		parts := strings.Split(p, "*")
+		for i := range parts {
+			parts[i] = regexp.QuoteMeta(parts[i])
+		}
+		res = append(res, regexp.MustCompile("^"+strings.Join(parts, ".*")+"$"))
+	}
+	return res
+}
+
+// compareConfigs returns the fields of config that differ from golden, in
+// the given top-level sections (all if empty) and outside ignored paths.
+func compareConfigs(golden, config map[string]interface{}, sections []string, ignore []*regexp.Regexp) []ConfigDifference {
+	pick := func(doc map[string]interface{}) map[string]interface{} {
+		if len(sections) == 0 {
+			return doc
+		}
+		picked := make(map[string]interface{}, len(sections))
+		for _, s := range sections {
+			if v, ok := doc[s]; ok {
+				picked[s] = v
+			}
+		}
+		return picked
+	}
+	want := make(map[string]interface{})
+	have := make(map[string]interface{})
+	flattenConfig("", pick(golden), want)
+	flattenConfig("", pick(config), have)
+
+	paths := make(map[string]bool, len(want))
+	for p := range want {
+		paths[p] = true
+	}
+	for p := range have {
+		paths[p] = true
+	}
+
+	diffs := []ConfigDifference{}
+outer:
+	for p := range paths {
+		for _, re := range ignore {
+			if re.MatchString(p) {
+				continue outer
+			}
+		}
+		g, inGolden := want[p]
+		v, inConfig := have[p]
+		if inGolden && inConfig && reflect.DeepEqual(g, v) {
+			continue
+		}
+		diffs = append(diffs, ConfigDifference{Path: p, Golden: g, Value: v})
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Path < diffs[j].Path })
+	return diffs
+}
+
+// kaonsuMachines splits the fleet into stock firmware miners, whose configs
+// can be compared, and the rest.
+func kaonsuMachines() (kaonsu, others []db.Machine) {
+	for _, m := range currentMachines() {
+		if _, ok := driverFor(m.IP).(kaonsuDriver); ok {
+			kaonsu = append(kaonsu, m)
+		} else {
+			others = append(others, m)
+		}
+	}
+	return kaonsu, others
+}
+
+// fetchKaonsuConfigs reads the configs of the given miners concurrently.
+// Failed reads leave a nil config and their error.
+func fetchKaonsuConfigs(ctx context.Context, fleet []db.Machine) ([]map[string]interface{}, []error) {
+	configs := make([]map[string]interface{}, len(fleet))
+	errs := make([]error, len(fleet))
+	var wg sync.WaitGroup
+	for i, m := range fleet {
+		wg.Add(1)
+		go func(i int, ip string) {
+			defer wg.Done()
+			minerCtx, cancel := context.WithTimeout(ctx, manageMinerTimeout)
+			defer cancel()
+			configs[i], errs[i] = fetchKaonsuConfig(minerCtx, ip)
+		}(i, m.IP)
+	}
+	wg.Wait()
+	return configs, errs
+}
+
+// configDiff compares every stock firmware miner's config to golden. A golden
+// machine is fetched along with the fleet and left out of the comparison;
+// with a template, goldenIP is empty.
+func configDiff(ctx context.Context, golden map[string]interface{}, goldenIP string, sections, ignore []string) (gin.H, error) {
+	fleet, others := kaonsuMachines()
+	configs, errs := fetchKaonsuConfigs(ctx, fleet)
+
+	var goldenInfo gin.H
+	if goldenIP != "" {
+		for i, m := range fleet {
+			if m.IP != goldenIP {
+				continue
+			}
+			if errs[i] != nil {
+				return nil, fmt.Errorf("golden machine %s: %w", m.Name, errs[i])
+			}
+			golden = configs[i]
+			goldenInfo = gin.H{"name": m.Name, "ip": m.IP}
+		}
+		if golden == nil {
+			return nil, fmt.Errorf("golden machine %s is not a stock firmware miner", goldenIP)
+		}
+	}
+
+	patterns := configPatterns(ignore)
+	miners := []MinerConfigDiff{}
+	fieldCounts := make(map[string]int)
+	differing := 0
+	for i, m := range fleet {
+		if m.IP == goldenIP {
+			continue
+		}
+		d := MinerConfigDiff{Name: m.Name, IP: m.IP, Differences: []ConfigDifference{}}
+		if errs[i] != nil {
+			log.Printf("Config diff: failed to read config of %s (%s): %v", m.Name, m.IP, errs[i])
+			d.Error = errs[i].Error()
+		} else {
+			d.Online = true
+			d.Differences = compareConfigs(golden, configs[i], sections, patterns)
+			for _, diff := range d.Differences {
+				fieldCounts[diff.Path]++
+			}
+			if len(d.Differences) > 0 {
+				differing++
+			}
+		}
+		miners = append(miners, d)
+	}
+
+	skipped := []gin.H{}
+	for _, m := range others {
+		skipped = append(skipped, gin.H{"name": m.Name, "ip": m.IP, "firmware": m.Firmware})
+	}
+
+	result := gin.H{
+		"golden":      goldenInfo, // null when comparing to a template
+		"sections":    sections,
+		"miners":      miners,
+		"differing":   differing,
+		"fieldCounts": fieldCounts, // miners differing per field
+		"skipped":     skipped,     // other firmwares
+	}
+	return result, nil
+}
+
+// getConfigDiffHandler compares the stock firmware fleet's configs to a
+// golden machine (?golden= name or IP). ?sections= limits the comparison to
+// comma-separated top-level sections, e.g. pools,fan,mode; ?ignore= skips
+// comma-separated path patterns, e.g. pools[*].user for per-unit workers.
+func getConfigDiffHandler(c *gin.Context) {
+	goldenIP := ""
+	ref := c.Query("golden")
+	for _, m := range currentMachines() {
+		if m.IP == ref || m.Name == ref {
+			goldenIP = m.IP
+		}
+	}
+	if goldenIP == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "golden must name a machine by name or IP"})
+		return
+	}
+
+	result, err := configDiff(c.Request.Context(), nil, goldenIP, splitList(c.Query("sections")), splitList(c.Query("ignore")))
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// postConfigDiffHandler compares the stock firmware fleet's configs to a
+// template config.
+func postConfigDiffHandler(c *gin.Context) {
+	var req ConfigDiffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	result, err := configDiff(c.Request.Context(), req.Template, "", req.Sections, req.Ignore)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"miningRoom/internal/control"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Forecast-aware power planning. The planner looks at the outdoor temperature
+// forecast over the next forecastHorizon hours and scales the power target of
+// miners with an Auto desired state: down ahead of hot periods so the room does
+// not overheat, and up ahead of cold nights when the waste heat warms the house.
+var (
+	forecastLatitude  float64
+	forecastLongitude float64
+	forecastHorizon   int     // hours
+	precoolTemp       float64 // °C forecast maximum that triggers pre-cooling
+	precoolReduction  float64 // % power target reduction while pre-cooling
+	preheatTemp       float64 // °C forecast minimum that triggers pre-heating
+	preheatBoost      float64 // % power target increase while pre-heating (0 disables)
+	precoolRoomTemp   float64 // °C room temperature held while pre-cooling (0: fixed reduction)
+)
+
+// While --precool-room-temp is set, pre-cooling regulates the factor instead
+// of applying the full reduction: a PID holds the room at the temperature,
+// between full power and --precool-reduction, starting from full power.
+const (
+	precoolRegulateInterval = 5 * time.Minute // matches the reconciler
+	precoolKp               = 0.05            // factor per °C off the room temperature
+	precoolKi               = 0.05 / 1800     // per °C and second: 0.05 per °C every 30 minutes
+	precoolMaxRate          = 0.01 / 60       // 1% of the power target per minute
+)
+
+// HourlyForecast is the outdoor temperature forecast for one hour.
+type HourlyForecast struct {
+	Time        time.Time `json:"time"`
+	Temperature float64   `json:"temperature"`
+}
+
+// ForecastPlan is the current planning decision.
+type ForecastPlan struct {
+	Mode         string           `json:"mode"`   // "normal", "precool" or "preheat"
+	Factor       float64          `json:"factor"` // multiplier applied to Auto power targets
+	Reason       string           `json:"reason,omitempty"`
+	HorizonHours int              `json:"horizonHours"`
+	MinTemp      float64          `json:"minTemp"`
+	MaxTemp      float64          `json:"maxTemp"`
+	Forecast     []HourlyForecast `json:"forecast"`
+	UpdatedAt    time.Time        `json:"updatedAt"`
+	HasData      bool             `json:"hasData"`
+}
+
+// forecastPlanner caches the latest plan.
+type forecastPlanner struct {
+	mu   sync.Mutex
+	plan ForecastPlan
+	pid  control.PID // pre-cooling regulator, see precoolRoomTemp
+}
+
+var forecastPlan = &forecastPlanner{plan: ForecastPlan{Mode: "normal", Factor: 1, Forecast: []HourlyForecast{}}}
+
+// forecastEnabled reports whether a location for the forecast is configured.
+func forecastEnabled() bool {
+	return forecastLatitude != 0 || forecastLongitude != 0
+}
+
+// fetchHourlyForecast returns the hourly outdoor temperature forecast from
+// Open-Meteo for the next two days.
+func fetchHourlyForecast(lat, lon float64) ([]HourlyForecast, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	url := fmt.Sprintf("https://api.open-meteo.com/v1/forecast?latitude=%.4f&longitude=%.4f&hourly=temperature_2m&forecast_days=2&timezone=UTC", lat, lon)
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("forecast API returned status %d", resp.StatusCode)
+	}
+
+	var data struct {
+		Hourly struct {
+			Time        []string  `json:"time"`
+			Temperature []float64 `json:"temperature_2m"`
+		} `json:"hourly"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	var forecast []HourlyForecast
+	for i, s := range data.Hourly.Time {
+		if i >= len(data.Hourly.Temperature) {
+			break
+		}
+		t, err := time.Parse("2006-01-02T15:04", s)
+		if err != nil {
+			continue
+		}
+		forecast = append(forecast, HourlyForecast{Time: t, Temperature: data.Hourly.Temperature[i]})
+	}
+	return forecast, nil
+}
+
+// runForecastPlanner refreshes the forecast and plan at the given interval.
+func runForecastPlanner(interval time.Duration) {
+	if !forecastEnabled() {
+		return
+	}
+	forecastPlan.update()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	regulate := time.NewTicker(precoolRegulateInterval)
+	defer regulate.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			forecastPlan.update()
+		case <-regulate.C:
+			if precoolRoomTemp == 0 || forecastPlan.current().Mode != "precool" {
+				continue
+			}
+			room, err := tsStore.GetRoomTemperature(indoorLocations())
+			if err != nil {
+				log.Printf("Forecast planner: failed to get room temperature: %v", err)
+				continue
+			}
+			if room.HasData {
+				forecastPlan.regulate(room.Temperature, time.Now())
+			}
+		}
+	}
+}
+
+func (p *forecastPlanner) update() {
+	forecast, err := fetchHourlyForecast(forecastLatitude, forecastLongitude)
+	if err != nil {
+		log.Printf("Forecast planner: failed to fetch forecast: %v", err)
+		return
+	}
+
+	plan := planForecast(forecast, time.Now(), time.Duration(forecastHorizon)*time.Hour)
+
+	p.mu.Lock()
+	previous := p.plan.Mode
+	if plan.Mode == "precool" && precoolRoomTemp != 0 {
+		if previous != "precool" {
+			p.seedPrecool(plan.Factor, time.Now())
+		}
+		plan.Factor = p.pid.Output()
+		plan.Reason += fmt.Sprintf(", holding the room at %.1f°C", precoolRoomTemp)
+	}
+	p.plan = plan
+	p.mu.Unlock()
+
+	if plan.Mode != previous {
+		recordEvent("forecast", "power plan changed from %s to %s: %s", previous, plan.Mode, plan.Reason)
+		// Apply the new power targets now instead of on the next reconciler pass
+		go reconcile.run()
+	}
+}
+
+// planForecast decides the mode from the forecast hours within the horizon.
+// Pre-cooling takes precedence over pre-heating.
+func planForecast(forecast []HourlyForecast, now time.Time, horizon time.Duration) ForecastPlan {
+	plan := ForecastPlan{
+		Mode:         "normal",
+		Factor:       1,
+		HorizonHours: int(horizon.Hours()),
+		Forecast:     []HourlyForecast{},
+		UpdatedAt:    now,
+	}
+
+	start := now.Truncate(time.Hour)
+	for _, f := range forecast {
+		if f.Time.Before(start) || f.Time.After(now.Add(horizon)) {
+			continue
+		}
+		if !plan.HasData || f.Temperature < plan.MinTemp {
+			plan.MinTemp = f.Temperature
+		}
+		if !plan.HasData || f.Temperature > plan.MaxTemp {
+			plan.MaxTemp = f.Temperature
+		}
+		plan.HasData = true
+		plan.Forecast = append(plan.Forecast, f)
+	}
+	if !plan.HasData {
+		return plan
+	}
+
+	switch {
+	case plan.MaxTemp >= precoolTemp && precoolReduction > 0:
+		plan.Mode = "precool"
+		plan.Factor = 1 - precoolReduction/100
+		plan.Reason = fmt.Sprintf("forecast max %.1f°C within %dh", plan.MaxTemp, plan.HorizonHours)
+	case plan.MinTemp <= preheatTemp && preheatBoost > 0:
+		plan.Mode = "preheat"
+		plan.Factor = 1 + preheatBoost/100
+		plan.Reason = fmt.Sprintf("forecast min %.1f°C within %dh", plan.MinTemp, plan.HorizonHours)
+	}
+	return plan
+}
+
+// current returns the latest plan.
+func (p *forecastPlanner) current() ForecastPlan {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.plan
+}
+
+// seedPrecool starts the pre-cooling regulator at full power, bounded by the
+// plan's reduced factor. Callers hold p.mu.
+func (p *forecastPlanner) seedPrecool(factor float64, now time.Time) {
+	p.pid = control.PID{Kp: precoolKp, Ki: precoolKi, Min: factor, Max: 1, MaxRate: precoolMaxRate}
+	p.pid.Seed(1, precoolRoomTemp, now)
+}
+
+// regulate feeds a room temperature to the pre-cooling regulator and updates
+// the plan factor from it.
+func (p *forecastPlanner) regulate(room float64, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.plan.Mode != "precool" {
+		return
+	}
+	p.plan.Factor = p.pid.Update(precoolRoomTemp, room, now)
+}
+
+// adjustPower scales an Auto power target by the current plan factor.
+func (p *forecastPlanner) adjustPower(power int) int {
+	return int(math.Round(float64(power) * p.current().Factor))
+}
+
+func getForecastHandler(c *gin.Context) {
+	if !forecastEnabled() {
+		c.JSON(http.StatusOK, gin.H{
+			"enabled": false,
+			"hasData": false,
+		})
+		return
+	}
+
+	plan := forecastPlan.current()
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": true,
+		"hasData": plan.HasData,
+		"plan":    plan,
+	})
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPrecoolRegulation(t *testing.T) {
+	defer func(temp float64) { precoolRoomTemp = temp }(precoolRoomTemp)
+	precoolRoomTemp = 25
+
+	p := &forecastPlanner{plan: ForecastPlan{Mode: "precool", Factor: 0.8}}
+	start := time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)
+	p.seedPrecool(0.8, start)
+
+	// A hot room lowers the factor by at most the rate limit per step, down
+	// to the full reduction
+	last := 1.0
+	for i := 1; i <= 24; i++ {
+		p.regulate(30, start.Add(time.Duration(i)*precoolRegulateInterval))
+		f := p.current().Factor
+		if f > last || last-f > precoolMaxRate*precoolRegulateInterval.Seconds()+1e-9 {
+			t.Fatalf("step %d: factor %v after %v", i, f, last)
+		}
+		last = f
+	}
+	if last != 0.8 {
+		t.Errorf("factor after two hours of a hot room = %v, want the full reduction 0.8", last)
+	}
+
+	// A cool room brings power back up within the hour
+	for i := 25; i <= 36; i++ {
+		p.regulate(22, start.Add(time.Duration(i)*precoolRegulateInterval))
+	}
+	if f := p.current().Factor; f <= 0.8 {
+		t.Errorf("factor after an hour of a cool room = %v, want above 0.8", f)
+	}
+
+	p.plan.Mode = "normal"
+	p.plan.Factor = 1
+	p.regulate(30, start.Add(37*precoolRegulateInterval))
+	if f := p.current().Factor; f != 1 {
+		t.Errorf("factor outside pre-cooling = %v, want the plan's 1", f)
+	}
+}
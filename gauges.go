@@ -0,0 +1,661 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"miningRoom/db"
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gaugeMetric is a value a dashboard gauge can show, with its defaults.
+type gaugeMetric struct {
+	Label       string
+	Unit        string
+	Decimals    int
+	Money       bool // shown as € (per day with a €/day unit)
+	Temperature bool // °C, converted to the locale's unit
+	value       func(s *gaugeSource) float64
+	// label, when set, replaces Label depending on the source's data
+	label func(s *gaugeSource) string
+}
+
+// gaugeMetrics are the metric sources gauge definitions can use, by key.
+var gaugeMetrics = map[string]gaugeMetric{
+	"power":      {Label: "Power", Unit: "W", value: (*gaugeSource).power},
+	"hashrate":   {Label: "Hashrate", Unit: "TH/s", value: (*gaugeSource).hashrate},
+	"efficiency": {Label: "Efficiency", Unit: "J/TH", Decimals: 1, value: (*gaugeSource).efficiency},
+	"elec_cost":  {Label: "Elec. Cost", Unit: "€/day", Money: true, value: (*gaugeSource).elecCost},
+	// Measured cost since midnight, and that plus the rest of the day at the
+	// current power
+	"elec_cost_today":     {Label: "Cost Today", Unit: "€", Money: true, value: (*gaugeSource).costToday},
+	"elec_cost_projected": {Label: "Cost Projected", Unit: "€/day", Money: true, value: (*gaugeSource).costProjected},
+	"revenue":             {Label: "Revenue", Unit: "€/day", Money: true, value: (*gaugeSource).revenue, label: (*gaugeSource).revenueLabel},
+	"revenue_estimate":    {Label: "Revenue (estimate)", Unit: "€/day", Money: true, value: (*gaugeSource).estimatedRevenue},
+	"profit": {Label: "Profit", Unit: "€/day", Money: true, value: func(s *gaugeSource) float64 {
+		return s.revenue() - s.elecCost()
+	}},
+	"room_temp":      {Label: "Room Temp", Unit: "°C", Decimals: 1, Temperature: true, value: (*gaugeSource).roomTemp},
+	"miner_temp_max": {Label: "Max Temperature", Unit: "°C", Decimals: 1, Temperature: true, value: (*gaugeSource).maxTemp},
+	"miner_temp_avg": {Label: "Avg Temperature", Unit: "°C", Decimals: 1, Temperature: true, value: (*gaugeSource).avgTemp},
+	"active_miners":  {Label: "Active Miners", Unit: "online", value: (*gaugeSource).activeMiners},
+}
+
+// gaugeColors are the Bootstrap colors a gauge can be shown in.
+var gaugeColors = map[string]bool{
+	"primary": true, "secondary": true, "success": true, "info": true,
+	"warning": true, "danger": true, "dark": true,
+}
+
+// defaultGauges are shown while no gauges are configured.
+var defaultGauges = []db.Gauge{
+	{Name: "power", Position: 1, Metric: "power", Color: "primary"},
+	{Name: "hashrate", Position: 2, Metric: "hashrate", Color: "primary"},
+	{Name: "efficiency", Position: 3, Metric: "efficiency", Decimals: 1, Color: "primary"},
+	{Name: "elec_cost", Position: 4, Metric: "elec_cost", Color: "primary"},
+	{Name: "revenue", Position: 5, Metric: "revenue", Color: "primary"},
+}
+
+// Gauge status levels, from the gauge's thresholds.
+const (
+	gaugeOK       = "ok"
+	gaugeWarn     = "warn"
+	gaugeCritical = "critical"
+)
+
+// gaugeAlertSeconds is the interval of the gauge alert check (0 disables it).
+var gaugeAlertSeconds int
+
+// gaugeSource queries what the configured gauges need, each value at most
+// once per request.
+type gaugeSource struct {
+	store TimeseriesStore
+	now   time.Time
+
+	cache    map[string]float64
+	failed   map[string]bool       // keys whose query failed
+	earnings *questdb.PoolEarnings // set by actualRevenue when the pool has them
+	// missing is set when a value read since it was last reset failed.
+	missing bool
+}
+
+func newGaugeSource(store TimeseriesStore, now time.Time) *gaugeSource {
+	return &gaugeSource{store: store, now: now, cache: make(map[string]float64), failed: make(map[string]bool)}
+}
+
+// memo returns the cached value of key or computes it with f, which reports
+// whether its query succeeded.
+func (s *gaugeSource) memo(key string, f func() (float64, bool)) float64 {
+	v, ok := s.cache[key]
+	if !ok {
+		var good bool
+		v, good = f()
+		s.cache[key] = v
+		s.failed[key] = !good
+	}
+	if s.failed[key] {
+		s.missing = true
+	}
+	return v
+}
+
+func (s *gaugeSource) power() float64 {
+	return s.memo("power", func() (float64, bool) {
+		result, err := s.store.GetTotalPower()
+		if err != nil {
+			log.Printf("Failed to get power from QuestDB: %v", err)
+			return 0, false
+		}
+		return result.TotalPower, true
+	})
+}
+
+// hashrate is in TH/s.
+func (s *gaugeSource) hashrate() float64 {
+	return s.memo("hashrate", func() (float64, bool) {
+		result, err := s.store.GetTotalHashrate()
+		if err != nil {
+			log.Printf("Failed to get hashrate from QuestDB: %v", err)
+			return 0, false
+		}
+		return result.TotalHashrate / 1000, true // GH/s to TH/s
+	})
+}
+
+func (s *gaugeSource) efficiency() float64 {
+	if s.hashrate() <= 0 {
+		return 0
+	}
+	return s.power() / s.hashrate() // J/TH
+}
+
+func (s *gaugeSource) elecCost() float64 {
+	return math.Round(s.power()/1000*24*elecPrice*100) / 100
+}
+
+// hourlyEnergyStore is a store that integrates energy per hour; only QuestDB
+// does.
+type hourlyEnergyStore interface {
+	GetHourlyEnergy(from, to time.Time) ([]questdb.HourlyEnergyRow, error)
+}
+
+// costToday is the cost of the energy measured since midnight, each hour at
+// its --elec-tariff price.
+func (s *gaugeSource) costToday() float64 {
+	return s.memo("elec_cost_today", func() (float64, bool) {
+		store, ok := s.store.(hourlyEnergyStore)
+		if !ok {
+			return 0, false
+		}
+		hours, err := store.GetHourlyEnergy(roomMidnight(s.now), s.now)
+		if err != nil {
+			log.Printf("Failed to get today's energy from QuestDB: %v", err)
+			return 0, false
+		}
+		cost := 0.0
+		for _, h := range hours {
+			cost += h.EnergyKWh * tariffPrice(h.Hour)
+		}
+		return cost, true
+	})
+}
+
+// costProjected is costToday plus the rest of the day at the current power,
+// each remaining hour at its tariff price.
+func (s *gaugeSource) costProjected() float64 {
+	cost := s.costToday()
+	kw := s.power() / 1000
+	end := roomMidnight(s.now).AddDate(0, 0, 1)
+	for t := s.now.In(roomLocation); t.Before(end); {
+		next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, roomLocation)
+		cost += kw * next.Sub(t).Hours() * tariffPrice(t)
+		t = next
+	}
+	return cost
+}
+
+// revenue is the pool's actual revenue over the last 24 hours where a pool
+// integration has it, the estimate otherwise.
+func (s *gaugeSource) revenue() float64 {
+	if actual, ok := s.actualRevenue(); ok {
+		return actual
+	}
+	return s.estimatedRevenue()
+}
+
+func (s *gaugeSource) revenueLabel() string {
+	if _, ok := s.actualRevenue(); ok {
+		return "Revenue (pool, 24h)"
+	}
+	return "Revenue (estimate)"
+}
+
+// estimatedRevenue is the theoretical revenue of the current hashrate.
+func (s *gaugeSource) estimatedRevenue() float64 {
+	return s.memo("revenue_estimate", func() (float64, bool) {
+		return calculateDailyRevenueEUR(s.hashrate()), true
+	})
+}
+
+// actualRevenue is the pool's revenue over the last 24 hours, false without
+// pool earnings.
+func (s *gaugeSource) actualRevenue() (float64, bool) {
+	v := s.memo("revenue_actual", func() (float64, bool) {
+		earnings, eur, err := fetchPoolRevenue(s.store)
+		if err != nil {
+			log.Printf("Failed to get pool earnings: %v", err)
+			return 0, false
+		}
+		s.earnings = earnings
+		return eur, true
+	})
+	return v, s.earnings != nil
+}
+
+func (s *gaugeSource) roomTemp() float64 {
+	return s.memo("room_temp", func() (float64, bool) {
+		result, err := s.store.GetRoomTemperature(indoorLocations())
+		if err != nil {
+			log.Printf("Failed to get room temperature from QuestDB: %v", err)
+			return 0, false
+		}
+		return result.Temperature, true
+	})
+}
+
+func (s *gaugeSource) maxTemp() float64 {
+	return s.memo("miner_temp_max", func() (float64, bool) {
+		result, err := s.store.GetMaxTemperature()
+		if err != nil {
+			log.Printf("Failed to get temperature from QuestDB: %v", err)
+			return 0, false
+		}
+		return result.MaxTemperature, true
+	})
+}
+
+func (s *gaugeSource) avgTemp() float64 {
+	return s.memo("miner_temp_avg", func() (float64, bool) {
+		result, err := s.store.GetAvgMaxTemperature()
+		if err != nil {
+			log.Printf("Failed to get avg max temperature from QuestDB: %v", err)
+			return 0, false
+		}
+		return result.AvgTemperature, true
+	})
+}
+
+// activeMiners counts miners with a miner_status record in the last 2 minutes.
+func (s *gaugeSource) activeMiners() float64 {
+	return s.memo("active_miners", func() (float64, bool) {
+		result, err := s.store.GetMinerStatuses()
+		if err != nil {
+			log.Printf("Failed to get miner statuses: %v", err)
+			return 0, false
+		}
+		active := 0
+		for _, m := range result.Miners {
+			if isTimestampRecentAt(m.Timestamp, 2*time.Minute, s.now) {
+				active++
+			}
+		}
+		return float64(active), true
+	})
+}
+
+// gaugeDefinitions returns the configured gauges, or the defaults if none are.
+func gaugeDefinitions() []db.Gauge {
+	gauges, err := database.FetchGauges()
+	if err != nil {
+		log.Printf("Failed to fetch gauges: %v", err)
+		return defaultGauges
+	}
+	if len(gauges) == 0 {
+		return defaultGauges
+	}
+	return gauges
+}
+
+// gaugeReading is a gauge definition resolved to its current value.
+type gaugeReading struct {
+	db.Gauge
+	metric gaugeMetric
+	Value  float64 // rounded to Decimals, °C for temperatures
+	// Missing is set when a query behind the value failed and Value is 0.
+	Missing bool
+}
+
+// resolveGauges evaluates every configured gauge for a request.
+func resolveGauges(c *gin.Context) []gaugeReading {
+	return resolveGaugesAt(storeFor(c), requestNow(c))
+}
+
+// resolveGaugesAt evaluates every configured gauge.
+func resolveGaugesAt(store TimeseriesStore, now time.Time) []gaugeReading {
+	return resolveGaugesFrom(newGaugeSource(store, now))
+}
+
+// resolveGaugesFrom evaluates every configured gauge from src. Gauges of
+// unknown metrics, e.g. from a newer version, are skipped.
+func resolveGaugesFrom(src *gaugeSource) []gaugeReading {
+	var readings []gaugeReading
+	for _, g := range gaugeDefinitions() {
+		m, ok := gaugeMetrics[g.Metric]
+		if !ok {
+			log.Printf("Gauge %s: unknown metric %q", g.Name, g.Metric)
+			continue
+		}
+		if g.Label == "" {
+			g.Label = m.Label
+			if m.label != nil {
+				g.Label = m.label(src)
+			}
+		}
+		if g.Unit == "" {
+			g.Unit = m.Unit
+		}
+		decimals := g.Decimals
+		if m.Money {
+			decimals = 2
+		}
+		scale := math.Pow(10, float64(decimals))
+		src.missing = false
+		value := m.value(src)
+		readings = append(readings, gaugeReading{
+			Gauge:   g,
+			metric:  m,
+			Value:   math.Round(value*scale) / scale,
+			Missing: src.missing,
+		})
+	}
+	return readings
+}
+
+// lowerIsWorse reports whether the thresholds are crossed from above.
+func (r gaugeReading) lowerIsWorse() bool {
+	return r.CriticalAt != 0 && r.WarnAt != 0 && r.CriticalAt < r.WarnAt
+}
+
+// reached reports whether the value is at or past a threshold; 0 disables it.
+func (r gaugeReading) reached(threshold float64) bool {
+	if threshold == 0 {
+		return false
+	}
+	if r.lowerIsWorse() {
+		return r.Value <= threshold
+	}
+	return r.Value >= threshold
+}
+
+// status is the gauge's level from its thresholds. The dashboard colors and
+// the gauge alerts both follow it.
+func (r gaugeReading) status() string {
+	switch {
+	case r.reached(r.CriticalAt):
+		return gaugeCritical
+	case r.reached(r.WarnAt):
+		return gaugeWarn
+	}
+	return gaugeOK
+}
+
+// color is the gauge's color, or warning/danger past its thresholds.
+func (r gaugeReading) color() string {
+	switch r.status() {
+	case gaugeCritical:
+		return "danger"
+	case gaugeWarn:
+		return "warning"
+	}
+	if r.Color == "" {
+		return "primary"
+	}
+	return r.Color
+}
+
+// renderData is the reading as gauge render data for localizeGauges.
+func (r gaugeReading) renderData() gin.H {
+	return gin.H{
+		"Label":       r.Label,
+		"Value":       r.Value,
+		"Unit":        r.Unit,
+		"Decimals":    r.Decimals,
+		"Money":       r.metric.Money,
+		"Temperature": r.metric.Temperature,
+		"Color":       r.color(),
+		"Status":      r.status(),
+	}
+}
+
+// gaugeRenderData resolves the configured gauges as template render data.
+func gaugeRenderData(c *gin.Context, loc Locale) []gin.H {
+	readings := resolveGauges(c)
+	gauges := make([]gin.H, len(readings))
+	for i, r := range readings {
+		gauges[i] = r.renderData()
+	}
+	return localizeGauges(loc, gauges)
+}
+
+func getGaugesHandler(c *gin.Context) {
+	loc := localeFor(c)
+	src := newGaugeSource(storeFor(c), requestNow(c))
+
+	gauges := []gin.H{}
+	for _, r := range resolveGaugesFrom(src) {
+		rendered := localizeGauges(loc, []gin.H{r.renderData()})[0]
+		value, unit := r.Value, loc.T(r.Unit)
+		warnAt, criticalAt := r.WarnAt, r.CriticalAt
+		if r.metric.Temperature {
+			value, unit = loc.Temp(r.Value), loc.TempUnit()
+			if warnAt != 0 {
+				warnAt = loc.Temp(warnAt)
+			}
+			if criticalAt != 0 {
+				criticalAt = loc.Temp(criticalAt)
+			}
+		}
+		gauges = append(gauges, gin.H{
+			"name":       r.Name,
+			"metric":     r.Metric,
+			"label":      rendered["Label"],
+			"value":      value,
+			"unit":       unit,
+			"display":    rendered["Value"],
+			"status":     r.status(),
+			"warnAt":     warnAt,
+			"criticalAt": criticalAt,
+			"color":      rendered["Color"],
+			"missing":    r.Missing,
+		})
+	}
+
+	// Today's electricity cost whether or not a gauge shows it; the values
+	// are cached if one does
+	src.missing = false
+	costToday := gin.H{
+		"soFar":     math.Round(src.costToday()*100) / 100,
+		"projected": math.Round(src.costProjected()*100) / 100,
+		"price":     tariffPrice(src.now),
+		"missing":   src.missing,
+	}
+
+	// Actual and estimated revenue side by side, whichever the revenue gauge
+	// shows
+	src.missing = false
+	actual, hasActual := src.actualRevenue()
+	revenue := gin.H{
+		"estimate": src.estimatedRevenue(),
+		"actual":   nil,
+		"source":   "estimate",
+		"missing":  src.missing,
+	}
+	if hasActual {
+		revenue["actual"] = actual
+		revenue["source"] = src.earnings.Source
+		revenue["earnings"] = src.earnings
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"gauges":    gauges,
+		"costToday": costToday,
+		"revenue":   revenue,
+		"locale":    loc,
+	})
+}
+
+// gaugeAlerter raises an alert for every gauge at warn or critical level.
+type gaugeAlerter struct {
+	mu     sync.Mutex
+	raised map[string]bool // alert keys, so removed gauges get cleared
+}
+
+var gaugeAlerts = &gaugeAlerter{raised: make(map[string]bool)}
+
+// runGaugeAlerts evaluates the gauges at the given interval.
+func runGaugeAlerts(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		gaugeAlerts.check(resolveGaugesAt(tsStore, time.Now()))
+	}
+}
+
+// check raises or clears the alert of each gauge from its status. Readings
+// whose query failed keep their alert as is, since an unreachable QuestDB is
+// reported by /api/health.
+func (a *gaugeAlerter) check(readings []gaugeReading) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	seen := make(map[string]bool)
+	for _, r := range readings {
+		key := "gauge:" + r.Name
+		seen[key] = true
+		if r.Missing {
+			continue
+		}
+
+		status := r.status()
+		if status == gaugeOK {
+			alerts.clear(key)
+			delete(a.raised, key)
+			continue
+		}
+		severity, threshold := severityWarning, r.WarnAt
+		if status == gaugeCritical {
+			severity, threshold = severityCritical, r.CriticalAt
+		}
+		alerts.raise(key, severity, "gauges", fmt.Sprintf("%s is %s %s (%s threshold %s)",
+			r.Label, strconv.FormatFloat(r.Value, 'f', -1, 64), r.Unit, status, strconv.FormatFloat(threshold, 'f', -1, 64)))
+		a.raised[key] = true
+	}
+	for key := range a.raised {
+		if !seen[key] {
+			alerts.clear(key)
+			delete(a.raised, key)
+		}
+	}
+}
+
+// getGaugeDefinitionsHandler lists the gauge definitions and the metrics they
+// can use. Custom is false while the defaults are shown.
+func getGaugeDefinitionsHandler(c *gin.Context) {
+	stored, err := database.FetchGauges()
+	if err != nil {
+		log.Printf("Failed to fetch gauges: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch gauges"})
+		return
+	}
+	gauges := stored
+	if len(gauges) == 0 {
+		gauges = defaultGauges
+	}
+
+	type metricInfo struct {
+		Key         string `json:"key"`
+		Label       string `json:"label"`
+		Unit        string `json:"unit"`
+		Decimals    int    `json:"decimals"`
+		Money       bool   `json:"money"`
+		Temperature bool   `json:"temperature"`
+	}
+	metrics := make([]metricInfo, 0, len(gaugeMetrics))
+	for key, m := range gaugeMetrics {
+		metrics = append(metrics, metricInfo{key, m.Label, m.Unit, m.Decimals, m.Money, m.Temperature})
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Key < metrics[j].Key })
+
+	c.JSON(http.StatusOK, gin.H{
+		"gauges":  gauges,
+		"custom":  len(stored) > 0,
+		"metrics": metrics,
+	})
+}
+
+type GaugeRequest struct {
+	Name       string  `json:"name" binding:"required"`
+	Position   int     `json:"position"`
+	Metric     string  `json:"metric" binding:"required"`
+	Label      string  `json:"label"`
+	Unit       string  `json:"unit"`
+	Decimals   *int    `json:"decimals"` // nil: the metric's default
+	Color      string  `json:"color"`
+	WarnAt     float64 `json:"warnAt"`
+	CriticalAt float64 `json:"criticalAt"`
+}
+
+// saveGaugeHandler creates or updates a gauge. The first saved gauge stores
+// the defaults along with it, so customizing one gauge keeps the others.
+func saveGaugeHandler(c *gin.Context) {
+	var req GaugeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	m, ok := gaugeMetrics[req.Metric]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown metric %q", req.Metric)})
+		return
+	}
+	if req.Color == "" {
+		req.Color = "primary"
+	}
+	if !gaugeColors[req.Color] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown color %q", req.Color)})
+		return
+	}
+	decimals := m.Decimals
+	if req.Decimals != nil {
+		decimals = *req.Decimals
+	}
+	if decimals < 0 || decimals > 4 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "decimals must be between 0 and 4"})
+		return
+	}
+
+	stored, err := database.FetchGauges()
+	if err != nil {
+		log.Printf("Failed to fetch gauges: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save gauge"})
+		return
+	}
+	if len(stored) == 0 {
+		for _, g := range defaultGauges {
+			if err := database.SaveGauge(g); err != nil {
+				log.Printf("Failed to save default gauge %s: %v", g.Name, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save gauge"})
+				return
+			}
+		}
+	}
+
+	gauge := db.Gauge{
+		Name:       req.Name,
+		Position:   req.Position,
+		Metric:     req.Metric,
+		Label:      req.Label,
+		Unit:       req.Unit,
+		Decimals:   decimals,
+		Color:      req.Color,
+		WarnAt:     req.WarnAt,
+		CriticalAt: req.CriticalAt,
+	}
+	if err := database.SaveGauge(gauge); err != nil {
+		log.Printf("Failed to save gauge %s: %v", req.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save gauge"})
+		return
+	}
+
+	log.Printf("Saved gauge %s (%s)", req.Name, req.Metric)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"name":    req.Name,
+	})
+}
+
+// deleteGaugeHandler removes a gauge; removing the last one restores the
+// defaults.
+func deleteGaugeHandler(c *gin.Context) {
+	name := c.Param("name")
+	if err := database.DeleteGauge(name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete gauge"})
+		return
+	}
+
+	log.Printf("Deleted gauge %s", name)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"name":    name,
+	})
+}
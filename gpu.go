@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gpuPollSeconds is the interval of the GPU rig poller.
+var gpuPollSeconds int
+
+// API ports of the GPU mining software; machines are configured by IP only.
+const (
+	rigelPort = 5000
+	trexPort  = 4067
+)
+
+// GPUStat is the state of one GPU in a rig.
+type GPUStat struct {
+	Index          int
+	Name           string
+	Temperature    float64 // core, °C
+	MemTemperature float64 // 0 when not reported
+	FanSpeed       float64 // %
+	PowerW         float64
+	Hashrate       float64 // H/s
+}
+
+// RigStats is what a GPU rig reports: the algorithm it mines, the version of
+// the mining software and its GPUs.
+type RigStats struct {
+	Algorithm string
+	Version   string
+	GPUs      []GPUStat
+}
+
+// gpuDriver is the driver of GPU rigs running mining software with an HTTP
+// API. Rigs are read-only: power is controlled through their outlet, and
+// ASIC controls are not supported.
+type gpuDriver struct {
+	software string
+	stats    func(ctx context.Context, ip string) (*RigStats, error)
+}
+
+func (d gpuDriver) Config(ctx context.Context, ip string) (*MinerManageInfo, error) {
+	stats, err := d.stats(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &MinerManageInfo{
+		Online:   true,
+		WorkMode: "Auto",
+		Profile:  stats.Algorithm,
+	}
+	for _, g := range stats.GPUs {
+		status := "Alive"
+		if g.Hashrate <= 0 {
+			status = "Idle"
+		}
+		info.Chains = append(info.Chains, ChainInfo{
+			Index:      g.Index,
+			Status:     status,
+			HashrateGH: g.Hashrate / 1e9,
+			BoardTemp:  g.Temperature,
+			ChipTemp:   g.MemTemperature,
+			PowerW:     g.PowerW,
+		})
+	}
+	return info, nil
+}
+
+func (d gpuDriver) SetPowerTarget(ctx context.Context, ip string, power int) error {
+	return fmt.Errorf("power targets are not supported on %s; set GPU power limits in the rig's OS", d.software)
+}
+
+func (d gpuDriver) SetFreqVolt(ctx context.Context, ip string, freq, volt float64) error {
+	return fmt.Errorf("fixed frequency/voltage is not supported on %s", d.software)
+}
+
+func (d gpuDriver) SetSleep(ctx context.Context, ip string) error {
+	return fmt.Errorf("sleep is not supported on %s; use shutdown", d.software)
+}
+
+func (d gpuDriver) RestartMining(ctx context.Context, ip string) error {
+	return fmt.Errorf("restarting the miner process is not supported on %s", d.software)
+}
+
+func (d gpuDriver) Reboot(ctx context.Context, ip string) error {
+	return fmt.Errorf("reboot is not supported on %s; power cycle instead", d.software)
+}
+
+func (d gpuDriver) Pools(ctx context.Context, ip string) ([]PoolInfo, error) {
+	return nil, fmt.Errorf("pool statistics are not supported on %s", d.software)
+}
+
+// SSHCommands is empty: rigs run a general-purpose OS not managed from here.
+func (gpuDriver) SSHCommands() map[string]sshCommand {
+	return map[string]sshCommand{}
+}
+
+func (gpuDriver) Capabilities() MinerCapabilities {
+	return MinerCapabilities{GPU: true}
+}
+
+// Identify describes a rig by its GPUs, e.g. "6x NVIDIA GeForce RTX 3070".
+// Rig hashrates depend on the algorithm, so there is no nominal hashrate.
+func (d gpuDriver) Identify(ctx context.Context, ip string) (*MinerIdentity, error) {
+	stats, err := d.stats(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	var names []string
+	for _, g := range stats.GPUs {
+		if counts[g.Name] == 0 {
+			names = append(names, g.Name)
+		}
+		counts[g.Name]++
+	}
+	var models []string
+	for _, name := range names {
+		models = append(models, fmt.Sprintf("%dx %s", counts[name], name))
+	}
+	return &MinerIdentity{
+		Model:           strings.Join(models, ", "),
+		FirmwareVersion: strings.TrimSpace(d.software + " " + stats.Version),
+	}, nil
+}
+
+// getRigJSON decodes the JSON answer of a rig's HTTP API.
+func getRigJSON(ctx context.Context, url string, out interface{}) error {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := httpGet(ctx, client, url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// rigelStats reads Rigel's summary from GET / on its API port. Hashrates are
+// reported per algorithm; a rig mining one algorithm has one entry.
+func rigelStats(ctx context.Context, ip string) (*RigStats, error) {
+	var resp struct {
+		Algorithm string `json:"algorithm"`
+		Version   string `json:"version"`
+		Devices   []struct {
+			ID             int                `json:"id"`
+			Name           string             `json:"name"`
+			Selected       bool               `json:"selected"`
+			Hashrate       map[string]float64 `json:"hashrate"`
+			PowerUsage     float64            `json:"power_usage"`
+			MonitoringInfo struct {
+				CoreTemperature   float64 `json:"core_temperature"`
+				MemoryTemperature float64 `json:"memory_temperature"`
+				FanSpeed          float64 `json:"fan_speed"`
+				PowerUsage        float64 `json:"power_usage"`
+			} `json:"monitoring_info"`
+		} `json:"devices"`
+	}
+	if err := getRigJSON(ctx, fmt.Sprintf("http://%s:%d/", urlHost(ip), rigelPort), &resp); err != nil {
+		return nil, err
+	}
+
+	stats := &RigStats{Algorithm: resp.Algorithm, Version: resp.Version}
+	for _, d := range resp.Devices {
+		if !d.Selected {
+			continue
+		}
+		g := GPUStat{
+			Index:          d.ID,
+			Name:           d.Name,
+			Temperature:    d.MonitoringInfo.CoreTemperature,
+			MemTemperature: d.MonitoringInfo.MemoryTemperature,
+			FanSpeed:       d.MonitoringInfo.FanSpeed,
+			PowerW:         d.PowerUsage,
+		}
+		if g.PowerW == 0 {
+			g.PowerW = d.MonitoringInfo.PowerUsage
+		}
+		for _, h := range d.Hashrate {
+			g.Hashrate += h
+		}
+		stats.GPUs = append(stats.GPUs, g)
+	}
+	return stats, nil
+}
+
+// trexStats reads T-Rex's GET /summary. Without an API password set on the
+// rig, summary needs no login.
+func trexStats(ctx context.Context, ip string) (*RigStats, error) {
+	var resp struct {
+		Algorithm string `json:"algorithm"`
+		Version   string `json:"version"`
+		GPUs      []struct {
+			DeviceID          int     `json:"device_id"`
+			Name              string  `json:"name"`
+			Temperature       float64 `json:"temperature"`
+			MemoryTemperature float64 `json:"memory_temperature"`
+			FanSpeed          float64 `json:"fan_speed"`
+			Power             float64 `json:"power"`
+			Hashrate          float64 `json:"hashrate"`
+		} `json:"gpus"`
+	}
+	if err := getRigJSON(ctx, fmt.Sprintf("http://%s:%d/summary", urlHost(ip), trexPort), &resp); err != nil {
+		return nil, err
+	}
+
+	stats := &RigStats{Algorithm: resp.Algorithm, Version: resp.Version}
+	for _, g := range resp.GPUs {
+		stats.GPUs = append(stats.GPUs, GPUStat{
+			Index:          g.DeviceID,
+			Name:           g.Name,
+			Temperature:    g.Temperature,
+			MemTemperature: g.MemoryTemperature,
+			FanSpeed:       g.FanSpeed,
+			PowerW:         g.Power,
+			Hashrate:       g.Hashrate,
+		})
+	}
+	return stats, nil
+}
+
+// gpuPoller keeps the last hashrate of each rig so loops judging miners by
+// their miner_status hashrate (the idle cutter) can tell a mining rig from an
+// idle one.
+type gpuPoller struct {
+	mu     sync.Mutex
+	mining map[string]time.Time // last poll with hashrate, by rig IP
+}
+
+var gpuRigs = &gpuPoller{mining: make(map[string]time.Time)}
+
+// runGPUPoller reads every GPU rig at the given interval and writes its GPUs
+// to gpu_status.
+func runGPUPoller(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		gpuRigs.poll()
+	}
+}
+
+func (p *gpuPoller) poll() {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var points []questdb.Point
+	now := time.Now()
+	for _, m := range currentMachines() {
+		d, ok := driverFor(m.IP).(gpuDriver)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(name, ip string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+			stats, err := d.stats(ctx, ip)
+			if err != nil {
+				// Unreachable rigs are reported by the Shelly watcher
+				log.Printf("GPU poller: %s (%s): %v", name, ip, err)
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			hashing := false
+			for _, g := range stats.GPUs {
+				hashing = hashing || g.Hashrate > 0
+				points = append(points, questdb.Point{
+					Table: "gpu_status",
+					Symbols: map[string]string{
+						"rig_ip":    ip,
+						"gpu":       strconv.Itoa(g.Index),
+						"name":      g.Name,
+						"algorithm": stats.Algorithm,
+					},
+					Fields: map[string]interface{}{
+						"temperature":        g.Temperature,
+						"memory_temperature": g.MemTemperature,
+						"fan_speed":          g.FanSpeed,
+						"power":              g.PowerW,
+						"hashrate":           g.Hashrate,
+					},
+					Time: now,
+				})
+			}
+			if hashing {
+				p.mu.Lock()
+				p.mining[ip] = now
+				p.mu.Unlock()
+			}
+		}(m.Name, m.IP)
+	}
+	wg.Wait()
+
+	if len(points) == 0 {
+		return
+	}
+	if err := tsStore.Write(points); err != nil {
+		log.Printf("GPU poller: failed to write GPU stats: %v", err)
+	}
+}
+
+// hashing reports whether a rig had hashrate within maxAge.
+func (p *gpuPoller) hashing(ip string, maxAge time.Duration) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t, ok := p.mining[ip]
+	return ok && time.Since(t) <= maxAge
+}
+
+// getGPULatestHandler returns the latest reading of every GPU with its rig's
+// machine name.
+func getGPULatestHandler(c *gin.Context) {
+	result, err := questdbFor(c).GetLatestGPUs()
+	if err != nil {
+		log.Printf("Failed to get latest GPUs from QuestDB: %v", err)
+		c.JSON(http.StatusOK, gin.H{
+			"rigs":    []interface{}{},
+			"hasData": false,
+		})
+		return
+	}
+
+	type rig struct {
+		Name     string               `json:"name"`
+		IP       string               `json:"ip"`
+		Power    float64              `json:"power"`    // W, sum of the GPUs
+		Hashrate float64              `json:"hashrate"` // H/s
+		GPUs     []questdb.GPUReading `json:"gpus"`
+	}
+	byIP := make(map[string]*rig)
+	var rigs []*rig
+	for _, g := range result.GPUs {
+		r, ok := byIP[g.RigIP]
+		if !ok {
+			r = &rig{Name: minerName(g.RigIP), IP: g.RigIP}
+			byIP[g.RigIP] = r
+			rigs = append(rigs, r)
+		}
+		r.Power += g.Power
+		r.Hashrate += g.Hashrate
+		r.GPUs = append(r.GPUs, g)
+	}
+	sort.Slice(rigs, func(i, j int) bool { return rigs[i].Name < rigs[j].Name })
+
+	c.JSON(http.StatusOK, gin.H{
+		"rigs":    rigs,
+		"hasData": len(rigs) > 0,
+	})
+}
+
+func getGPUChartHandler(c *gin.Context) {
+	result, err := questdbFor(c).GetGPUTimeSeries()
+	if err != nil {
+		log.Printf("Failed to get GPU time series from QuestDB: %v", err)
+		c.JSON(http.StatusOK, gin.H{
+			"gpus":    map[string][]interface{}{},
+			"hasData": false,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
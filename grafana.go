@@ -0,0 +1,203 @@
+package main
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Grafana SimpleJSON/JSON datasource endpoints. Besides the history metrics,
+// derived metrics are computed with the same helpers as the dashboard.
+var grafanaDerivedMetrics = map[string]func(from, to time.Time) (map[string][]questdb.TimeSeriesPoint, error){
+	"efficiency":          grafanaEfficiency,
+	"cost":                grafanaCost,
+	"thermal_conductance": grafanaThermalConductance,
+}
+
+// GrafanaQueryRequest is the body of a SimpleJSON /query call.
+type GrafanaQueryRequest struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	Targets []struct {
+		Target string `json:"target"`
+		Type   string `json:"type"` // "timeserie" (default) or "table"
+	} `json:"targets"`
+}
+
+// grafanaMetricNames lists the metrics offered by /search.
+func grafanaMetricNames() []string {
+	names := make([]string, 0, len(historyMetrics)+len(grafanaDerivedMetrics))
+	for name := range historyMetrics {
+		names = append(names, name)
+	}
+	for name := range grafanaDerivedMetrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// grafanaSeries returns the series of a metric between from and to, keyed by
+// device/location or "total".
+func grafanaSeries(metric string, from, to time.Time) (map[string][]questdb.TimeSeriesPoint, error) {
+	if derive, ok := grafanaDerivedMetrics[metric]; ok {
+		return derive(from, to)
+	}
+	return historySeries(metric, from, to)
+}
+
+// historySeries returns the series of a history metric.
+func historySeries(metric string, from, to time.Time) (map[string][]questdb.TimeSeriesPoint, error) {
+	m := historyMetrics[metric]
+	result, err := questdbClient.GetHistory(m.Rollup, m.Field, from, to, m.Sum)
+	if err != nil {
+		return nil, err
+	}
+	return result.Series, nil
+}
+
+// grafanaEfficiency is fleet J/TH from total power and hashrate.
+func grafanaEfficiency(from, to time.Time) (map[string][]questdb.TimeSeriesPoint, error) {
+	power, err := historySeries("power", from, to)
+	if err != nil {
+		return nil, err
+	}
+	hashrate, err := historySeries("hashrate", from, to)
+	if err != nil {
+		return nil, err
+	}
+	hashrateAt := make(map[string]float64, len(hashrate["total"]))
+	for _, p := range hashrate["total"] {
+		hashrateAt[p.Timestamp] = p.Value
+	}
+
+	var points []questdb.TimeSeriesPoint
+	for _, p := range power["total"] {
+		if h := hashrateAt[p.Timestamp]; h > 0 {
+			points = append(points, questdb.TimeSeriesPoint{Timestamp: p.Timestamp, Value: efficiency(p.Value, h)})
+		}
+	}
+	return map[string][]questdb.TimeSeriesPoint{"total": points}, nil
+}
+
+// grafanaCost is the electricity cost rate in EUR/h from total power.
+func grafanaCost(from, to time.Time) (map[string][]questdb.TimeSeriesPoint, error) {
+	power, err := historySeries("power", from, to)
+	if err != nil {
+		return nil, err
+	}
+	points := make([]questdb.TimeSeriesPoint, 0, len(power["total"]))
+	for _, p := range power["total"] {
+		points = append(points, questdb.TimeSeriesPoint{Timestamp: p.Timestamp, Value: math.Round(p.Value/1000*elecPrice*1000) / 1000})
+	}
+	return map[string][]questdb.TimeSeriesPoint{"total": points}, nil
+}
+
+// grafanaThermalConductance is the room's rolling UA estimate in W/K,
+// available for the last 7 days.
+func grafanaThermalConductance(from, to time.Time) (map[string][]questdb.TimeSeriesPoint, error) {
+	data, err := questdbClient.GetThermalInsulationData(indoorLocations(), outdoorLocations())
+	if err != nil {
+		return nil, err
+	}
+	var points []questdb.TimeSeriesPoint
+	for _, p := range data.Rolling {
+		t, err := time.Parse(time.RFC3339Nano, p.Timestamp)
+		if err != nil || t.Before(from) || !t.Before(to) {
+			continue
+		}
+		points = append(points, questdb.TimeSeriesPoint{Timestamp: p.Timestamp, Value: p.UA})
+	}
+	return map[string][]questdb.TimeSeriesPoint{"total": points}, nil
+}
+
+// grafanaTestHandler answers the datasource connection test.
+func grafanaTestHandler(c *gin.Context) {
+	c.String(http.StatusOK, "OK")
+}
+
+func grafanaSearchHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, grafanaMetricNames())
+}
+
+// grafanaQueryHandler returns each target as one response entry per series.
+// Series other than "total" are named "<metric> <key>".
+func grafanaQueryHandler(c *gin.Context) {
+	var req GrafanaQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	from, to := req.Range.From, req.Range.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-24 * time.Hour)
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	response := []gin.H{}
+	for _, target := range req.Targets {
+		if _, ok := historyMetrics[target.Target]; !ok && grafanaDerivedMetrics[target.Target] == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown metric " + target.Target})
+			return
+		}
+		series, err := grafanaSeries(target.Target, from, to)
+		if err != nil {
+			log.Printf("Failed to get %s for Grafana: %v", target.Target, err)
+			continue
+		}
+
+		keys := make([]string, 0, len(series))
+		for key := range series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			name := target.Target
+			if key != "total" {
+				name += " " + key
+			}
+			rows := make([][]interface{}, 0, len(series[key]))
+			for _, p := range series[key] {
+				t, err := time.Parse(time.RFC3339Nano, p.Timestamp)
+				if err != nil {
+					continue
+				}
+				rows = append(rows, []interface{}{p.Value, t.UnixMilli()})
+			}
+
+			if target.Type == "table" {
+				for _, row := range rows {
+					row[0], row[1] = row[1], row[0]
+				}
+				response = append(response, gin.H{
+					"type": "table",
+					"columns": []gin.H{
+						{"text": "Time", "type": "time"},
+						{"text": name, "type": "number"},
+					},
+					"rows": rows,
+				})
+				continue
+			}
+			response = append(response, gin.H{
+				"target":     name,
+				"datapoints": rows,
+			})
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
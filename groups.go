@@ -0,0 +1,213 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GroupInfo is a group with aggregates over its members' latest miner status.
+type GroupInfo struct {
+	db.Group
+	Online     int     `json:"online"`
+	PowerW     float64 `json:"powerW"`
+	HashrateTH float64 `json:"hashrateTh"`
+	Efficiency float64 `json:"efficiency"` // J/TH
+}
+
+func getGroupsHandler(c *gin.Context) {
+	groups, err := database.FetchGroups()
+	if err != nil {
+		log.Printf("Failed to fetch groups: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch groups"})
+		return
+	}
+
+	statuses, err := storeFor(c).GetMinerStatuses()
+	if err != nil {
+		log.Printf("Failed to get miner statuses from QuestDB: %v", err)
+	}
+
+	infos := make([]GroupInfo, 0, len(groups))
+	for _, g := range groups {
+		info := GroupInfo{Group: g}
+		if statuses != nil {
+			for _, ip := range g.MachineIPs {
+				for _, s := range statuses.Miners {
+					if s.MinerIP != ip || !isTimestampRecent(s.Timestamp, 2*time.Minute) {
+						continue
+					}
+					info.Online++
+					info.PowerW += s.Power
+					info.HashrateTH += s.Hashrate / 1000 // GH/s to TH/s
+				}
+			}
+		}
+		if info.HashrateTH > 0 {
+			info.Efficiency = math.Round(info.PowerW/info.HashrateTH*10) / 10
+		}
+		info.PowerW = math.Round(info.PowerW)
+		info.HashrateTH = math.Round(info.HashrateTH*10) / 10
+		infos = append(infos, info)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"groups": infos})
+}
+
+type GroupRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+func addGroupHandler(c *gin.Context) {
+	var req GroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id, err := database.AddGroup(req.Name)
+	if err != nil {
+		log.Printf("Failed to add group %s: %v", req.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add group"})
+		return
+	}
+
+	log.Printf("Added group %s (%d)", req.Name, id)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"id":      id,
+		"name":    req.Name,
+	})
+}
+
+// groupIDParam parses the :id route parameter, writing a 400 response if invalid.
+func groupIDParam(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid group id"})
+		return 0, false
+	}
+	return id, true
+}
+
+func deleteGroupHandler(c *gin.Context) {
+	id, ok := groupIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DeleteGroup(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete group"})
+		return
+	}
+
+	log.Printf("Deleted group %d", id)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"id":      id,
+	})
+}
+
+type GroupMemberRequest struct {
+	IP string `json:"ip" binding:"required"`
+}
+
+func addGroupMemberHandler(c *gin.Context) {
+	id, ok := groupIDParam(c)
+	if !ok {
+		return
+	}
+	var req GroupMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := database.FetchGroup(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "group not found"})
+		return
+	}
+	if err := database.AddGroupMember(id, req.IP); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add group member"})
+		return
+	}
+
+	log.Printf("Added %s to group %d", req.IP, id)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"id":      id,
+		"ip":      req.IP,
+	})
+}
+
+func removeGroupMemberHandler(c *gin.Context) {
+	id, ok := groupIDParam(c)
+	if !ok {
+		return
+	}
+	ip := ipParam(c)
+
+	if err := database.RemoveGroupMember(id, ip); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove group member"})
+		return
+	}
+
+	log.Printf("Removed %s from group %d", ip, id)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"id":      id,
+		"ip":      ip,
+	})
+}
+
+// resolveBulkIPs returns the target IPs of a bulk request: the explicit list, or
+// the members of groupID when it is set. Miners in maintenance are left out
+// unless includeMaintenance is set.
+func resolveBulkIPs(ips []string, groupID int64, includeMaintenance bool) ([]string, error) {
+	targets, err := bulkTargets(ips, groupID)
+	if err != nil || includeMaintenance {
+		return targets, err
+	}
+	targets, skipped := excludeMaintenance(targets)
+	if len(targets) == 0 {
+		return nil, errors.New("all targeted miners are in maintenance (set includeMaintenance to target them)")
+	}
+	if len(skipped) > 0 {
+		log.Printf("Bulk action skips miners in maintenance: %s", strings.Join(skipped, ", "))
+	}
+	return targets, nil
+}
+
+func bulkTargets(ips []string, groupID int64) ([]string, error) {
+	if groupID == 0 {
+		if len(ips) == 0 {
+			return nil, errors.New("ips or groupId required")
+		}
+		return ips, nil
+	}
+	if len(ips) > 0 {
+		return nil, errors.New("ips and groupId are mutually exclusive")
+	}
+
+	g, err := database.FetchGroup(groupID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("group %d not found", groupID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(g.MachineIPs) == 0 {
+		return nil, fmt.Errorf("group %s has no members", g.Name)
+	}
+	return g.MachineIPs, nil
+}
@@ -0,0 +1,520 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"miningRoom/db"
+	"miningRoom/grpcapi"
+	"miningRoom/questdb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcAddr is the listen address of the gRPC API; empty disables it.
+var grpcAddr string
+
+// grpcControlMethods are the methods limited to the inner network and recorded
+// in the audit log, like the manage route group of the REST API.
+var grpcControlMethods = map[string]bool{
+	grpcapi.MiningRoom_StartMiners_FullMethodName:    true,
+	grpcapi.MiningRoom_ShutdownMiners_FullMethodName: true,
+	grpcapi.MiningRoom_SleepMiners_FullMethodName:    true,
+	grpcapi.MiningRoom_SetPowerTarget_FullMethodName: true,
+	grpcapi.MiningRoom_GetJob_FullMethodName:         true,
+}
+
+// grpcTOTPMethods require a TOTP code (x-totp-code metadata) once 2FA is
+// enrolled, like requireTOTP on the REST routes.
+var grpcTOTPMethods = map[string]bool{
+	grpcapi.MiningRoom_ShutdownMiners_FullMethodName: true,
+	grpcapi.MiningRoom_SleepMiners_FullMethodName:    true,
+	grpcapi.MiningRoom_SetPowerTarget_FullMethodName: true,
+}
+
+// grpcAwayMethods are refused while away mode is on: the gRPC API has no
+// confirmation round trip like awayGuard, so they go through REST instead.
+var grpcAwayMethods = map[string]bool{
+	grpcapi.MiningRoom_ShutdownMiners_FullMethodName: true,
+	grpcapi.MiningRoom_SleepMiners_FullMethodName:    true,
+}
+
+// grpcOwnerMethods are the methods a hosting owner may call, with the
+// owner's reads scoped to their machines like ownerAPIRoutes.
+var grpcOwnerMethods = map[string]bool{
+	grpcapi.MiningRoom_GetStatus_FullMethodName:        true,
+	grpcapi.MiningRoom_ListMachines_FullMethodName:     true,
+	grpcapi.MiningRoom_GetMinerStatuses_FullMethodName: true,
+	grpcapi.MiningRoom_StreamMetrics_FullMethodName:    true,
+}
+
+// grpcOwnerHeader is the metadata key of an owner's session token, the value
+// of the owner_session cookie set by /api/login.
+const grpcOwnerHeader = "x-owner-session"
+
+type grpcOwnerKey struct{}
+
+// grpcOwner returns the owner a call was authorized for, or "" for the admin.
+func grpcOwner(ctx context.Context) string {
+	owner, _ := ctx.Value(grpcOwnerKey{}).(string)
+	return owner
+}
+
+// grpcMachines returns the machines visible to a call, like machinesFor.
+func grpcMachines(ctx context.Context) []db.Machine {
+	return ownerMachines(grpcOwner(ctx))
+}
+
+// grpcStore returns the timeseries store of a call; an owner's reads are
+// scoped to their miners and outlets, like questdbFor.
+func grpcStore(ctx context.Context) TimeseriesStore {
+	if owner := grpcOwner(ctx); owner != "" {
+		return questdbClient.WithContext(ctx).WithScope(ownerScope(ownerMachines(owner)))
+	}
+	return storeWith(ctx)
+}
+
+// runGRPCServer serves the gRPC API on addr.
+func runGRPCServer(addr string) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("Failed to listen for gRPC on %s: %v", addr, err)
+	}
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(grpcUnaryInterceptor),
+		grpc.StreamInterceptor(grpcStreamInterceptor),
+	)
+	grpcapi.RegisterMiningRoomServer(srv, &grpcServer{})
+	log.Printf("gRPC API listening on %s", addr)
+	if err := srv.Serve(lis); err != nil {
+		log.Printf("gRPC server stopped: %v", err)
+	}
+}
+
+// grpcClientIP returns the IP of the calling client.
+func grpcClientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// grpcTOTPCode returns the TOTP code sent in the call metadata.
+func grpcTOTPCode(ctx context.Context) string {
+	return grpcMetadata(ctx, strings.ToLower(totpHeader))
+}
+
+// grpcMetadata returns the first value of a metadata key sent with the call.
+func grpcMetadata(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// grpcAuthorize applies the owner, network and 2FA restrictions of a method
+// and returns the context to call it with. Owners only reach
+// grpcOwnerMethods; with --require-owner-login, callers outside the inner
+// network must send an owner session.
+func grpcAuthorize(ctx context.Context, method string) (context.Context, error) {
+	ip := grpcClientIP(ctx)
+	if token := grpcMetadata(ctx, grpcOwnerHeader); token != "" {
+		owner, err := database.FetchOwnerSession(hashSessionToken(token))
+		if err != nil {
+			log.Printf("Failed to fetch owner session: %v", err)
+			return nil, status.Error(codes.Internal, "failed to verify owner session")
+		}
+		if owner == "" {
+			return nil, status.Error(codes.Unauthenticated, "invalid or expired owner session")
+		}
+		if !grpcOwnerMethods[method] {
+			return nil, status.Error(codes.PermissionDenied, "method not available to owners")
+		}
+		return context.WithValue(ctx, grpcOwnerKey{}, owner), nil
+	}
+	if requireOwnerLogin && !isInnerNetwork(ip) {
+		return nil, status.Error(codes.Unauthenticated, "login required")
+	}
+	if grpcControlMethods[method] && !isInnerNetwork(ip) {
+		return nil, status.Error(codes.PermissionDenied, "control methods are limited to the inner network")
+	}
+	if grpcTOTPMethods[method] {
+		active, ok, lockout, err := checkTOTP(ip, grpcTOTPCode(ctx))
+		if err != nil {
+			log.Printf("Failed to verify TOTP: %v", err)
+			return nil, status.Error(codes.Internal, "failed to verify TOTP")
+		}
+		if lockout > 0 {
+			return nil, status.Errorf(codes.ResourceExhausted, "too many failed codes, try again in %s", lockout.Round(time.Second))
+		}
+		if active && !ok {
+			recordEvent("2fa", "rejected gRPC %s from %s: missing or invalid code", method, ip)
+			return nil, status.Error(codes.Unauthenticated, "TOTP code required")
+		}
+	}
+	if grpcAwayMethods[method] && awayActive() {
+		recordEvent("away", "refused gRPC %s from %s: away mode is on", method, ip)
+		return nil, status.Error(codes.FailedPrecondition, "away mode is on: confirm the request through the REST API")
+	}
+	return ctx, nil
+}
+
+func grpcUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	authCtx, err := grpcAuthorize(ctx, info.FullMethod)
+	var resp interface{}
+	if err == nil {
+		resp, err = handler(authCtx, req)
+	}
+	// Refused control calls are audited too, like a REST call rejected by
+	// requireTOTP or awayGuard
+	if grpcControlMethods[info.FullMethod] {
+		auditGRPC(ctx, info.FullMethod, req, err)
+	}
+	return resp, err
+}
+
+func grpcStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := grpcAuthorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, grpcContextStream{ss, ctx})
+}
+
+// grpcContextStream is a server stream with the context grpcAuthorize
+// returned.
+type grpcContextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s grpcContextStream) Context() context.Context {
+	return s.ctx
+}
+
+// auditGRPC appends a control call to the audit log. The status is the HTTP
+// status equivalent of the gRPC code, so exports read like REST calls.
+func auditGRPC(ctx context.Context, method string, req interface{}, callErr error) {
+	var body []byte
+	if m, ok := req.(proto.Message); ok {
+		body, _ = proto.Marshal(m)
+	}
+	sum := sha256.Sum256(body)
+	actor := grpcClientIP(ctx)
+	if grpcTOTPCode(ctx) != "" {
+		actor += " (2fa)"
+	}
+	entry := db.AuditEntry{
+		CreatedAt:  time.Now(),
+		Actor:      actor,
+		Method:     "GRPC",
+		Path:       method,
+		BodySHA256: hex.EncodeToString(sum[:]),
+		Status:     grpcHTTPStatus(status.Code(callErr)),
+	}
+	auditMu.Lock()
+	err := database.AppendAudit(entry)
+	auditMu.Unlock()
+	if err != nil {
+		log.Printf("Failed to write audit entry for gRPC %s: %v", method, err)
+	}
+}
+
+func grpcHTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return 200
+	case codes.InvalidArgument:
+		return 400
+	case codes.Unauthenticated:
+		return 401
+	case codes.PermissionDenied:
+		return 403
+	case codes.NotFound:
+		return 404
+	case codes.FailedPrecondition:
+		return 409
+	default:
+		return 500
+	}
+}
+
+// grpcServer implements the MiningRoom service on top of the same helpers as
+// the REST handlers.
+type grpcServer struct {
+	grpcapi.UnimplementedMiningRoomServer
+}
+
+// questdbTime converts a QuestDB timestamp, returning nil if it can't be parsed.
+func questdbTime(ts string) *timestamppb.Timestamp {
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func (s *grpcServer) GetStatus(ctx context.Context, _ *grpcapi.GetStatusRequest) (*grpcapi.Status, error) {
+	return fleetStatus(ctx), nil
+}
+
+// fleetStatus gathers the totals of /api/status with the emergency state and
+// active alerts; owners get their own machines' totals and no alerts. Values
+// QuestDB can't provide are left zero.
+func fleetStatus(ctx context.Context) *grpcapi.Status {
+	store := grpcStore(ctx)
+	st := &grpcapi.Status{
+		Time:             timestamppb.Now(),
+		EmergencyLockout: emergency.locked(),
+	}
+	if result, err := store.GetTotalHashrate(); err != nil {
+		log.Printf("Failed to get hashrate from QuestDB: %v", err)
+	} else if result.HasData {
+		st.Online = isTimestampRecent(result.Timestamp, 5*time.Minute)
+		st.HashrateGhs = result.TotalHashrate
+	}
+	if result, err := store.GetTotalPower(); err != nil {
+		log.Printf("Failed to get power from QuestDB: %v", err)
+	} else if result.HasData {
+		st.PowerW = result.TotalPower
+		st.PowerSource = result.Source
+	}
+	if hashrateTH := st.HashrateGhs / 1000; hashrateTH > 0 {
+		st.EfficiencyJPerTh = st.PowerW / hashrateTH
+	}
+	if result, err := store.GetMaxTemperature(); err != nil {
+		log.Printf("Failed to get temperature from QuestDB: %v", err)
+	} else if result.HasData {
+		st.MaxTemperatureC = result.MaxTemperature
+	}
+	if result, err := store.GetRoomTemperature(indoorLocations()); err != nil {
+		log.Printf("Failed to get room temperature from QuestDB: %v", err)
+	} else if result.HasData {
+		st.RoomTemperatureC = result.Temperature
+	}
+	if grpcOwner(ctx) != "" {
+		return st
+	}
+	for _, a := range alerts.list() {
+		st.Alerts = append(st.Alerts, &grpcapi.Alert{
+			Key:      a.Key,
+			Severity: a.Severity,
+			Source:   a.Source,
+			Message:  a.Message,
+			Since:    timestamppb.New(a.Since),
+		})
+	}
+	return st
+}
+
+func (s *grpcServer) ListMachines(ctx context.Context, _ *grpcapi.ListMachinesRequest) (*grpcapi.ListMachinesResponse, error) {
+	resp := &grpcapi.ListMachinesResponse{}
+	for _, m := range grpcMachines(ctx) {
+		resp.Machines = append(resp.Machines, &grpcapi.Machine{
+			Name:              m.Name,
+			Ip:                m.IP,
+			Mac:               m.MAC,
+			ShellyIp:          m.ShellyIP,
+			Outlet:            m.Outlet,
+			Firmware:          m.Firmware,
+			Maintenance:       maintenanceActive(m),
+			MaintenanceReason: m.MaintenanceReason,
+		})
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) GetMinerStatuses(ctx context.Context, _ *grpcapi.GetMinerStatusesRequest) (*grpcapi.MinerStatuses, error) {
+	miners, err := minerStatuses(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &grpcapi.MinerStatuses{Miners: miners}, nil
+}
+
+// minerStatuses converts the latest miner_status rows, sorted by name.
+func minerStatuses(ctx context.Context) ([]*grpcapi.MinerStatus, error) {
+	result, err := grpcStore(ctx).GetMinerStatuses()
+	if err != nil {
+		log.Printf("Failed to get miner statuses from QuestDB: %v", err)
+		return nil, errors.New("miner statuses unavailable")
+	}
+	machines := grpcMachines(ctx)
+	byIP := make(map[string]db.Machine, len(machines))
+	for _, m := range machines {
+		byIP[m.IP] = m
+	}
+
+	miners := make([]*grpcapi.MinerStatus, 0, len(result.Miners))
+	for _, row := range result.Miners {
+		miners = append(miners, grpcMinerStatus(row, byIP))
+	}
+	sort.Slice(miners, func(i, j int) bool { return miners[i].Name < miners[j].Name })
+	return miners, nil
+}
+
+func grpcMinerStatus(row questdb.MinerStatusRow, byIP map[string]db.Machine) *grpcapi.MinerStatus {
+	ms := &grpcapi.MinerStatus{
+		Ip:              row.MinerIP,
+		Name:            row.MinerIP,
+		Time:            questdbTime(row.Timestamp),
+		Status:          row.Status,
+		WorkMode:        row.WorkMode,
+		HashrateGhs:     row.Hashrate,
+		PowerW:          row.Power,
+		Efficiency:      row.Efficiency,
+		TemperatureMaxC: row.TemperatureMax,
+	}
+	if m, ok := byIP[row.MinerIP]; ok {
+		ms.Name = m.Name
+		ms.Maintenance = maintenanceActive(m)
+	}
+	if p, ok := presence.state(row.MinerIP); ok {
+		ms.Network = p.State
+	}
+	return ms
+}
+
+// grpcTargets resolves the target IPs of a control request.
+func grpcTargets(req *grpcapi.MinersRequest) ([]string, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "miners required")
+	}
+	ips, err := resolveBulkIPs(req.Ips, req.GroupId, req.IncludeMaintenance)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return ips, nil
+}
+
+// grpcEnqueue queues a job like respondJobQueued.
+func grpcEnqueue(kind string, params interface{}, ips []string) (*grpcapi.JobRef, error) {
+	id, err := jobs.enqueue(kind, params, ips)
+	if err != nil {
+		log.Printf("Failed to queue %s job: %v", kind, err)
+		return nil, status.Error(codes.Internal, "failed to queue job")
+	}
+	return &grpcapi.JobRef{JobId: id, Ips: ips}, nil
+}
+
+func (s *grpcServer) StartMiners(ctx context.Context, req *grpcapi.StartMinersRequest) (*grpcapi.JobRef, error) {
+	ips, err := grpcTargets(req.Miners)
+	if err != nil {
+		return nil, err
+	}
+	params := BulkMinerRequest{IPs: ips}
+	if req.GapSeconds != nil {
+		gap := int(*req.GapSeconds)
+		if gap < 0 || gap > 600 {
+			return nil, status.Error(codes.InvalidArgument, "gap_seconds must be between 0 and 600")
+		}
+		params.GapSeconds = &gap
+	}
+	if emergency.locked() {
+		recordEvent("emergency", "blocked start of miners %s", strings.Join(ips, ", "))
+		return nil, status.Error(codes.FailedPrecondition, "emergency lockout active")
+	}
+	if condensation.blocksColdStart() {
+		recordEvent("condensation", "blocked start of miners %s", strings.Join(ips, ", "))
+		return nil, status.Error(codes.FailedPrecondition, "condensation risk: miner start paused")
+	}
+	return grpcEnqueue("start", params, ips)
+}
+
+func (s *grpcServer) ShutdownMiners(ctx context.Context, req *grpcapi.MinersRequest) (*grpcapi.JobRef, error) {
+	ips, err := grpcTargets(req)
+	if err != nil {
+		return nil, err
+	}
+	return grpcEnqueue("shutdown", BulkMinerRequest{IPs: ips}, ips)
+}
+
+func (s *grpcServer) SleepMiners(ctx context.Context, req *grpcapi.MinersRequest) (*grpcapi.JobRef, error) {
+	ips, err := grpcTargets(req)
+	if err != nil {
+		return nil, err
+	}
+	return grpcEnqueue("sleep", BulkMinerRequest{IPs: ips}, ips)
+}
+
+func (s *grpcServer) SetPowerTarget(ctx context.Context, req *grpcapi.SetPowerTargetRequest) (*grpcapi.JobRef, error) {
+	ips, err := grpcTargets(req.Miners)
+	if err != nil {
+		return nil, err
+	}
+	if req.PowerW <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "power_w must be positive")
+	}
+	return grpcEnqueue("power", BulkPowerRequest{IPs: ips, Power: int(req.PowerW)}, ips)
+}
+
+func (s *grpcServer) GetJob(ctx context.Context, req *grpcapi.GetJobRequest) (*grpcapi.Job, error) {
+	job, err := database.FetchJob(req.JobId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, "job not found")
+	}
+	if err != nil {
+		log.Printf("Failed to fetch job %d: %v", req.JobId, err)
+		return nil, status.Error(codes.Internal, "failed to fetch job")
+	}
+
+	resp := &grpcapi.Job{
+		Id:        job.ID,
+		Kind:      job.Kind,
+		Status:    job.Status,
+		CreatedAt: timestamppb.New(job.CreatedAt),
+	}
+	if job.FinishedAt != nil {
+		resp.FinishedAt = timestamppb.New(*job.FinishedAt)
+	}
+	for _, t := range job.Targets {
+		resp.Targets = append(resp.Targets, &grpcapi.JobTarget{Ip: t.IP, Status: t.Status, Error: t.Error})
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) StreamMetrics(req *grpcapi.StreamMetricsRequest, stream grpcapi.MiningRoom_StreamMetricsServer) error {
+	interval := 10 * time.Second
+	if req.IntervalSeconds != 0 {
+		if req.IntervalSeconds < 5 {
+			return status.Error(codes.InvalidArgument, "interval_seconds must be at least 5")
+		}
+		interval = time.Duration(req.IntervalSeconds) * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		metrics := &grpcapi.Metrics{Status: fleetStatus(stream.Context())}
+		// A QuestDB outage leaves the miners out of this snapshot only
+		metrics.Miners, _ = minerStatuses(stream.Context())
+		if err := stream.Send(metrics); err != nil {
+			return err
+		}
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
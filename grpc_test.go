@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"miningRoom/db"
+	"miningRoom/grpcapi"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// grpcTestContext returns the context of a call from ip with the given
+// metadata key/value pairs.
+func grpcTestContext(ip string, kv ...string) context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000}})
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(kv...))
+}
+
+// useTestDatabase points database at a fresh SQLite file for the test.
+func useTestDatabase(t *testing.T) *db.DB {
+	d, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.EnsureSchema(); err != nil {
+		t.Fatal(err)
+	}
+	prev := database
+	database = d
+	t.Cleanup(func() {
+		database = prev
+		d.Close()
+	})
+	return d
+}
+
+func TestGRPCOwnerScoping(t *testing.T) {
+	d := useTestDatabase(t)
+	if err := d.SaveOwnerSession(hashSessionToken("alice-token"), "alice", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(ms []db.Machine, require bool, inner []*net.IPNet) {
+		requireOwnerLogin, innerNetworks = require, inner
+		setMachines(ms)
+	}(currentMachines(), requireOwnerLogin, innerNetworks)
+	requireOwnerLogin = true
+	_, lan, _ := net.ParseCIDR("192.168.1.0/24")
+	innerNetworks = []*net.IPNet{lan}
+	setMachines([]db.Machine{
+		{Name: "a1", IP: "192.168.1.10", Owner: "alice"},
+		{Name: "b1", IP: "192.168.1.11", Owner: "bob"},
+		{Name: "own", IP: "192.168.1.12"},
+	})
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		method   string
+		wantCode codes.Code
+		wantIPs  []string
+	}{
+		{"owner lists own machines", grpcTestContext("203.0.113.5", grpcOwnerHeader, "alice-token"),
+			grpcapi.MiningRoom_ListMachines_FullMethodName, codes.OK, []string{"192.168.1.10"}},
+		{"owner may not control", grpcTestContext("192.168.1.50", grpcOwnerHeader, "alice-token"),
+			grpcapi.MiningRoom_ShutdownMiners_FullMethodName, codes.PermissionDenied, nil},
+		{"invalid session", grpcTestContext("203.0.113.5", grpcOwnerHeader, "stolen"),
+			grpcapi.MiningRoom_ListMachines_FullMethodName, codes.Unauthenticated, nil},
+		{"anonymous outside needs login", grpcTestContext("203.0.113.5"),
+			grpcapi.MiningRoom_ListMachines_FullMethodName, codes.Unauthenticated, nil},
+		{"inner network sees all", grpcTestContext("192.168.1.50"),
+			grpcapi.MiningRoom_ListMachines_FullMethodName, codes.OK, []string{"192.168.1.10", "192.168.1.11", "192.168.1.12"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, err := grpcAuthorize(tt.ctx, tt.method)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("grpcAuthorize code = %v, want %v (%v)", status.Code(err), tt.wantCode, err)
+			}
+			if err != nil || tt.wantIPs == nil {
+				return
+			}
+			resp, err := (&grpcServer{}).ListMachines(ctx, &grpcapi.ListMachinesRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ips []string
+			for _, m := range resp.Machines {
+				ips = append(ips, m.Ip)
+			}
+			if len(ips) != len(tt.wantIPs) {
+				t.Fatalf("machines = %v, want %v", ips, tt.wantIPs)
+			}
+			for i := range ips {
+				if ips[i] != tt.wantIPs[i] {
+					t.Fatalf("machines = %v, want %v", ips, tt.wantIPs)
+				}
+			}
+		})
+	}
+}
+
+func TestGRPCControlGates(t *testing.T) {
+	d := useTestDatabase(t)
+	if err := d.SaveTOTPSecret("JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatal(err)
+	}
+	// Every call with a code redeems its own recovery code, as a TOTP code is
+	// only accepted once per time step
+	var hashes []string
+	for i := 0; i < 10; i++ {
+		hashes = append(hashes, hashRecoveryCode(fmt.Sprintf("recovery-%d", i)))
+	}
+	if err := d.ConfirmTOTPSecret(hashes); err != nil {
+		t.Fatal(err)
+	}
+	defer func(active *db.Scenario) { scenarios.active = active }(scenarios.active)
+
+	tests := []struct {
+		name     string
+		method   string
+		withCode bool
+		away     bool
+		wantCode codes.Code
+	}{
+		{"sleep needs TOTP", grpcapi.MiningRoom_SleepMiners_FullMethodName, false, false, codes.Unauthenticated},
+		{"power target needs TOTP", grpcapi.MiningRoom_SetPowerTarget_FullMethodName, false, false, codes.Unauthenticated},
+		{"sleep with TOTP", grpcapi.MiningRoom_SleepMiners_FullMethodName, true, false, codes.OK},
+		{"start needs no TOTP", grpcapi.MiningRoom_StartMiners_FullMethodName, false, false, codes.OK},
+		{"shutdown refused while away", grpcapi.MiningRoom_ShutdownMiners_FullMethodName, true, true, codes.FailedPrecondition},
+		{"sleep refused while away", grpcapi.MiningRoom_SleepMiners_FullMethodName, true, true, codes.FailedPrecondition},
+		{"power target allowed while away", grpcapi.MiningRoom_SetPowerTarget_FullMethodName, true, true, codes.OK},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scenarios.active = nil
+			if tt.away {
+				scenarios.active = &db.Scenario{Name: "holiday", Away: true}
+			}
+			ctx := grpcTestContext("127.0.0.1")
+			if tt.withCode {
+				ctx = grpcTestContext("127.0.0.1", "x-totp-code", fmt.Sprintf("recovery-%d", i))
+			}
+			if _, err := grpcAuthorize(ctx, tt.method); status.Code(err) != tt.wantCode {
+				t.Errorf("grpcAuthorize code = %v, want %v (%v)", status.Code(err), tt.wantCode, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,1344 @@
+// gRPC API of the mining room dashboard, served on --grpc-addr next to the
+// REST API. Regenerate the Go code after changing this file with:
+//
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//     --go-grpc_out=. --go-grpc_opt=paths=source_relative grpcapi/miningroom.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: grpcapi/miningroom.proto
+
+package grpcapi
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatusRequest) Reset() {
+	*x = GetStatusRequest{}
+	mi := &file_grpcapi_miningroom_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatusRequest) ProtoMessage() {}
+
+func (x *GetStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_miningroom_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatusRequest.ProtoReflect.Descriptor instead.
+func (*GetStatusRequest) Descriptor() ([]byte, []int) {
+	return file_grpcapi_miningroom_proto_rawDescGZIP(), []int{0}
+}
+
+type Status struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Time             *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	Online           bool                   `protobuf:"varint,2,opt,name=online,proto3" json:"online,omitempty"` // hashrate data is recent
+	HashrateGhs      float64                `protobuf:"fixed64,3,opt,name=hashrate_ghs,json=hashrateGhs,proto3" json:"hashrate_ghs,omitempty"`
+	PowerW           float64                `protobuf:"fixed64,4,opt,name=power_w,json=powerW,proto3" json:"power_w,omitempty"`
+	PowerSource      string                 `protobuf:"bytes,5,opt,name=power_source,json=powerSource,proto3" json:"power_source,omitempty"` // "meter" or "plugs"
+	EfficiencyJPerTh float64                `protobuf:"fixed64,6,opt,name=efficiency_j_per_th,json=efficiencyJPerTh,proto3" json:"efficiency_j_per_th,omitempty"`
+	MaxTemperatureC  float64                `protobuf:"fixed64,7,opt,name=max_temperature_c,json=maxTemperatureC,proto3" json:"max_temperature_c,omitempty"`
+	RoomTemperatureC float64                `protobuf:"fixed64,8,opt,name=room_temperature_c,json=roomTemperatureC,proto3" json:"room_temperature_c,omitempty"`
+	EmergencyLockout bool                   `protobuf:"varint,9,opt,name=emergency_lockout,json=emergencyLockout,proto3" json:"emergency_lockout,omitempty"`
+	Alerts           []*Alert               `protobuf:"bytes,10,rep,name=alerts,proto3" json:"alerts,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *Status) Reset() {
+	*x = Status{}
+	mi := &file_grpcapi_miningroom_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Status) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Status) ProtoMessage() {}
+
+func (x *Status) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_miningroom_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Status.ProtoReflect.Descriptor instead.
+func (*Status) Descriptor() ([]byte, []int) {
+	return file_grpcapi_miningroom_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Status) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *Status) GetOnline() bool {
+	if x != nil {
+		return x.Online
+	}
+	return false
+}
+
+func (x *Status) GetHashrateGhs() float64 {
+	if x != nil {
+		return x.HashrateGhs
+	}
+	return 0
+}
+
+func (x *Status) GetPowerW() float64 {
+	if x != nil {
+		return x.PowerW
+	}
+	return 0
+}
+
+func (x *Status) GetPowerSource() string {
+	if x != nil {
+		return x.PowerSource
+	}
+	return ""
+}
+
+func (x *Status) GetEfficiencyJPerTh() float64 {
+	if x != nil {
+		return x.EfficiencyJPerTh
+	}
+	return 0
+}
+
+func (x *Status) GetMaxTemperatureC() float64 {
+	if x != nil {
+		return x.MaxTemperatureC
+	}
+	return 0
+}
+
+func (x *Status) GetRoomTemperatureC() float64 {
+	if x != nil {
+		return x.RoomTemperatureC
+	}
+	return 0
+}
+
+func (x *Status) GetEmergencyLockout() bool {
+	if x != nil {
+		return x.EmergencyLockout
+	}
+	return false
+}
+
+func (x *Status) GetAlerts() []*Alert {
+	if x != nil {
+		return x.Alerts
+	}
+	return nil
+}
+
+type Alert struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Severity      string                 `protobuf:"bytes,2,opt,name=severity,proto3" json:"severity,omitempty"` // "warning" or "critical"
+	Source        string                 `protobuf:"bytes,3,opt,name=source,proto3" json:"source,omitempty"`
+	Message       string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	Since         *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=since,proto3" json:"since,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Alert) Reset() {
+	*x = Alert{}
+	mi := &file_grpcapi_miningroom_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Alert) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Alert) ProtoMessage() {}
+
+func (x *Alert) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_miningroom_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Alert.ProtoReflect.Descriptor instead.
+func (*Alert) Descriptor() ([]byte, []int) {
+	return file_grpcapi_miningroom_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Alert) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *Alert) GetSeverity() string {
+	if x != nil {
+		return x.Severity
+	}
+	return ""
+}
+
+func (x *Alert) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Alert) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Alert) GetSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Since
+	}
+	return nil
+}
+
+type ListMachinesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListMachinesRequest) Reset() {
+	*x = ListMachinesRequest{}
+	mi := &file_grpcapi_miningroom_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListMachinesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMachinesRequest) ProtoMessage() {}
+
+func (x *ListMachinesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_miningroom_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMachinesRequest.ProtoReflect.Descriptor instead.
+func (*ListMachinesRequest) Descriptor() ([]byte, []int) {
+	return file_grpcapi_miningroom_proto_rawDescGZIP(), []int{3}
+}
+
+type ListMachinesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Machines      []*Machine             `protobuf:"bytes,1,rep,name=machines,proto3" json:"machines,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListMachinesResponse) Reset() {
+	*x = ListMachinesResponse{}
+	mi := &file_grpcapi_miningroom_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListMachinesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMachinesResponse) ProtoMessage() {}
+
+func (x *ListMachinesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_miningroom_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMachinesResponse.ProtoReflect.Descriptor instead.
+func (*ListMachinesResponse) Descriptor() ([]byte, []int) {
+	return file_grpcapi_miningroom_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListMachinesResponse) GetMachines() []*Machine {
+	if x != nil {
+		return x.Machines
+	}
+	return nil
+}
+
+type Machine struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Name              string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Ip                string                 `protobuf:"bytes,2,opt,name=ip,proto3" json:"ip,omitempty"`
+	Mac               string                 `protobuf:"bytes,3,opt,name=mac,proto3" json:"mac,omitempty"`
+	ShellyIp          string                 `protobuf:"bytes,4,opt,name=shelly_ip,json=shellyIp,proto3" json:"shelly_ip,omitempty"`
+	Outlet            string                 `protobuf:"bytes,5,opt,name=outlet,proto3" json:"outlet,omitempty"`
+	Firmware          string                 `protobuf:"bytes,6,opt,name=firmware,proto3" json:"firmware,omitempty"`
+	Maintenance       bool                   `protobuf:"varint,7,opt,name=maintenance,proto3" json:"maintenance,omitempty"`
+	MaintenanceReason string                 `protobuf:"bytes,8,opt,name=maintenance_reason,json=maintenanceReason,proto3" json:"maintenance_reason,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *Machine) Reset() {
+	*x = Machine{}
+	mi := &file_grpcapi_miningroom_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Machine) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Machine) ProtoMessage() {}
+
+func (x *Machine) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_miningroom_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Machine.ProtoReflect.Descriptor instead.
+func (*Machine) Descriptor() ([]byte, []int) {
+	return file_grpcapi_miningroom_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Machine) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Machine) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+func (x *Machine) GetMac() string {
+	if x != nil {
+		return x.Mac
+	}
+	return ""
+}
+
+func (x *Machine) GetShellyIp() string {
+	if x != nil {
+		return x.ShellyIp
+	}
+	return ""
+}
+
+func (x *Machine) GetOutlet() string {
+	if x != nil {
+		return x.Outlet
+	}
+	return ""
+}
+
+func (x *Machine) GetFirmware() string {
+	if x != nil {
+		return x.Firmware
+	}
+	return ""
+}
+
+func (x *Machine) GetMaintenance() bool {
+	if x != nil {
+		return x.Maintenance
+	}
+	return false
+}
+
+func (x *Machine) GetMaintenanceReason() string {
+	if x != nil {
+		return x.MaintenanceReason
+	}
+	return ""
+}
+
+type GetMinerStatusesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMinerStatusesRequest) Reset() {
+	*x = GetMinerStatusesRequest{}
+	mi := &file_grpcapi_miningroom_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMinerStatusesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMinerStatusesRequest) ProtoMessage() {}
+
+func (x *GetMinerStatusesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_miningroom_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMinerStatusesRequest.ProtoReflect.Descriptor instead.
+func (*GetMinerStatusesRequest) Descriptor() ([]byte, []int) {
+	return file_grpcapi_miningroom_proto_rawDescGZIP(), []int{6}
+}
+
+type MinerStatuses struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Miners        []*MinerStatus         `protobuf:"bytes,1,rep,name=miners,proto3" json:"miners,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MinerStatuses) Reset() {
+	*x = MinerStatuses{}
+	mi := &file_grpcapi_miningroom_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MinerStatuses) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MinerStatuses) ProtoMessage() {}
+
+func (x *MinerStatuses) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_miningroom_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MinerStatuses.ProtoReflect.Descriptor instead.
+func (*MinerStatuses) Descriptor() ([]byte, []int) {
+	return file_grpcapi_miningroom_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *MinerStatuses) GetMiners() []*MinerStatus {
+	if x != nil {
+		return x.Miners
+	}
+	return nil
+}
+
+type MinerStatus struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Ip              string                 `protobuf:"bytes,1,opt,name=ip,proto3" json:"ip,omitempty"`
+	Name            string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Time            *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=time,proto3" json:"time,omitempty"`
+	Status          string                 `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+	WorkMode        string                 `protobuf:"bytes,5,opt,name=work_mode,json=workMode,proto3" json:"work_mode,omitempty"`
+	HashrateGhs     float64                `protobuf:"fixed64,6,opt,name=hashrate_ghs,json=hashrateGhs,proto3" json:"hashrate_ghs,omitempty"`
+	PowerW          float64                `protobuf:"fixed64,7,opt,name=power_w,json=powerW,proto3" json:"power_w,omitempty"`
+	Efficiency      float64                `protobuf:"fixed64,8,opt,name=efficiency,proto3" json:"efficiency,omitempty"`
+	TemperatureMaxC float64                `protobuf:"fixed64,9,opt,name=temperature_max_c,json=temperatureMaxC,proto3" json:"temperature_max_c,omitempty"`
+	Network         string                 `protobuf:"bytes,10,opt,name=network,proto3" json:"network,omitempty"` // "online", "hung" or "off" from the presence checker
+	Maintenance     bool                   `protobuf:"varint,11,opt,name=maintenance,proto3" json:"maintenance,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *MinerStatus) Reset() {
+	*x = MinerStatus{}
+	mi := &file_grpcapi_miningroom_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MinerStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MinerStatus) ProtoMessage() {}
+
+func (x *MinerStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_miningroom_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MinerStatus.ProtoReflect.Descriptor instead.
+func (*MinerStatus) Descriptor() ([]byte, []int) {
+	return file_grpcapi_miningroom_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *MinerStatus) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+func (x *MinerStatus) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *MinerStatus) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *MinerStatus) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *MinerStatus) GetWorkMode() string {
+	if x != nil {
+		return x.WorkMode
+	}
+	return ""
+}
+
+func (x *MinerStatus) GetHashrateGhs() float64 {
+	if x != nil {
+		return x.HashrateGhs
+	}
+	return 0
+}
+
+func (x *MinerStatus) GetPowerW() float64 {
+	if x != nil {
+		return x.PowerW
+	}
+	return 0
+}
+
+func (x *MinerStatus) GetEfficiency() float64 {
+	if x != nil {
+		return x.Efficiency
+	}
+	return 0
+}
+
+func (x *MinerStatus) GetTemperatureMaxC() float64 {
+	if x != nil {
+		return x.TemperatureMaxC
+	}
+	return 0
+}
+
+func (x *MinerStatus) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *MinerStatus) GetMaintenance() bool {
+	if x != nil {
+		return x.Maintenance
+	}
+	return false
+}
+
+// Targets are the listed IPs, or the members of group_id when it is set.
+// Miners in maintenance are skipped unless include_maintenance is set.
+type MinersRequest struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Ips                []string               `protobuf:"bytes,1,rep,name=ips,proto3" json:"ips,omitempty"`
+	GroupId            int64                  `protobuf:"varint,2,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	IncludeMaintenance bool                   `protobuf:"varint,3,opt,name=include_maintenance,json=includeMaintenance,proto3" json:"include_maintenance,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *MinersRequest) Reset() {
+	*x = MinersRequest{}
+	mi := &file_grpcapi_miningroom_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MinersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MinersRequest) ProtoMessage() {}
+
+func (x *MinersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_miningroom_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MinersRequest.ProtoReflect.Descriptor instead.
+func (*MinersRequest) Descriptor() ([]byte, []int) {
+	return file_grpcapi_miningroom_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *MinersRequest) GetIps() []string {
+	if x != nil {
+		return x.Ips
+	}
+	return nil
+}
+
+func (x *MinersRequest) GetGroupId() int64 {
+	if x != nil {
+		return x.GroupId
+	}
+	return 0
+}
+
+func (x *MinersRequest) GetIncludeMaintenance() bool {
+	if x != nil {
+		return x.IncludeMaintenance
+	}
+	return false
+}
+
+type StartMinersRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Miners *MinersRequest         `protobuf:"bytes,1,opt,name=miners,proto3" json:"miners,omitempty"`
+	// Seconds between relay switches; unset uses --start-gap-seconds.
+	GapSeconds    *int32 `protobuf:"varint,2,opt,name=gap_seconds,json=gapSeconds,proto3,oneof" json:"gap_seconds,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartMinersRequest) Reset() {
+	*x = StartMinersRequest{}
+	mi := &file_grpcapi_miningroom_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartMinersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartMinersRequest) ProtoMessage() {}
+
+func (x *StartMinersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_miningroom_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartMinersRequest.ProtoReflect.Descriptor instead.
+func (*StartMinersRequest) Descriptor() ([]byte, []int) {
+	return file_grpcapi_miningroom_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *StartMinersRequest) GetMiners() *MinersRequest {
+	if x != nil {
+		return x.Miners
+	}
+	return nil
+}
+
+func (x *StartMinersRequest) GetGapSeconds() int32 {
+	if x != nil && x.GapSeconds != nil {
+		return *x.GapSeconds
+	}
+	return 0
+}
+
+type SetPowerTargetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Miners        *MinersRequest         `protobuf:"bytes,1,opt,name=miners,proto3" json:"miners,omitempty"`
+	PowerW        int32                  `protobuf:"varint,2,opt,name=power_w,json=powerW,proto3" json:"power_w,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetPowerTargetRequest) Reset() {
+	*x = SetPowerTargetRequest{}
+	mi := &file_grpcapi_miningroom_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetPowerTargetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetPowerTargetRequest) ProtoMessage() {}
+
+func (x *SetPowerTargetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_miningroom_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetPowerTargetRequest.ProtoReflect.Descriptor instead.
+func (*SetPowerTargetRequest) Descriptor() ([]byte, []int) {
+	return file_grpcapi_miningroom_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *SetPowerTargetRequest) GetMiners() *MinersRequest {
+	if x != nil {
+		return x.Miners
+	}
+	return nil
+}
+
+func (x *SetPowerTargetRequest) GetPowerW() int32 {
+	if x != nil {
+		return x.PowerW
+	}
+	return 0
+}
+
+type JobRef struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         int64                  `protobuf:"varint,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	Ips           []string               `protobuf:"bytes,2,rep,name=ips,proto3" json:"ips,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobRef) Reset() {
+	*x = JobRef{}
+	mi := &file_grpcapi_miningroom_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobRef) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobRef) ProtoMessage() {}
+
+func (x *JobRef) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_miningroom_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobRef.ProtoReflect.Descriptor instead.
+func (*JobRef) Descriptor() ([]byte, []int) {
+	return file_grpcapi_miningroom_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *JobRef) GetJobId() int64 {
+	if x != nil {
+		return x.JobId
+	}
+	return 0
+}
+
+func (x *JobRef) GetIps() []string {
+	if x != nil {
+		return x.Ips
+	}
+	return nil
+}
+
+type GetJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         int64                  `protobuf:"varint,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetJobRequest) Reset() {
+	*x = GetJobRequest{}
+	mi := &file_grpcapi_miningroom_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetJobRequest) ProtoMessage() {}
+
+func (x *GetJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_miningroom_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetJobRequest.ProtoReflect.Descriptor instead.
+func (*GetJobRequest) Descriptor() ([]byte, []int) {
+	return file_grpcapi_miningroom_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *GetJobRequest) GetJobId() int64 {
+	if x != nil {
+		return x.JobId
+	}
+	return 0
+}
+
+type Job struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Kind          string                 `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`
+	Status        string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"` // "queued", "running", "done", "failed" or "cancelled"
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	FinishedAt    *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=finished_at,json=finishedAt,proto3" json:"finished_at,omitempty"`
+	Targets       []*JobTarget           `protobuf:"bytes,6,rep,name=targets,proto3" json:"targets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Job) Reset() {
+	*x = Job{}
+	mi := &file_grpcapi_miningroom_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Job) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Job) ProtoMessage() {}
+
+func (x *Job) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_miningroom_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Job.ProtoReflect.Descriptor instead.
+func (*Job) Descriptor() ([]byte, []int) {
+	return file_grpcapi_miningroom_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *Job) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Job) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Job) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Job) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Job) GetFinishedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FinishedAt
+	}
+	return nil
+}
+
+func (x *Job) GetTargets() []*JobTarget {
+	if x != nil {
+		return x.Targets
+	}
+	return nil
+}
+
+type JobTarget struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ip            string                 `protobuf:"bytes,1,opt,name=ip,proto3" json:"ip,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobTarget) Reset() {
+	*x = JobTarget{}
+	mi := &file_grpcapi_miningroom_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobTarget) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobTarget) ProtoMessage() {}
+
+func (x *JobTarget) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_miningroom_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobTarget.ProtoReflect.Descriptor instead.
+func (*JobTarget) Descriptor() ([]byte, []int) {
+	return file_grpcapi_miningroom_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *JobTarget) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+func (x *JobTarget) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *JobTarget) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type StreamMetricsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Seconds between snapshots, at least 5 (default 10).
+	IntervalSeconds int32 `protobuf:"varint,1,opt,name=interval_seconds,json=intervalSeconds,proto3" json:"interval_seconds,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *StreamMetricsRequest) Reset() {
+	*x = StreamMetricsRequest{}
+	mi := &file_grpcapi_miningroom_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamMetricsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamMetricsRequest) ProtoMessage() {}
+
+func (x *StreamMetricsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_miningroom_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamMetricsRequest.ProtoReflect.Descriptor instead.
+func (*StreamMetricsRequest) Descriptor() ([]byte, []int) {
+	return file_grpcapi_miningroom_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *StreamMetricsRequest) GetIntervalSeconds() int32 {
+	if x != nil {
+		return x.IntervalSeconds
+	}
+	return 0
+}
+
+type Metrics struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        *Status                `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Miners        []*MinerStatus         `protobuf:"bytes,2,rep,name=miners,proto3" json:"miners,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Metrics) Reset() {
+	*x = Metrics{}
+	mi := &file_grpcapi_miningroom_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Metrics) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Metrics) ProtoMessage() {}
+
+func (x *Metrics) ProtoReflect() protoreflect.Message {
+	mi := &file_grpcapi_miningroom_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Metrics.ProtoReflect.Descriptor instead.
+func (*Metrics) Descriptor() ([]byte, []int) {
+	return file_grpcapi_miningroom_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *Metrics) GetStatus() *Status {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+func (x *Metrics) GetMiners() []*MinerStatus {
+	if x != nil {
+		return x.Miners
+	}
+	return nil
+}
+
+var File_grpcapi_miningroom_proto protoreflect.FileDescriptor
+
+const file_grpcapi_miningroom_proto_rawDesc = "" +
+	"\n" +
+	"\x18grpcapi/miningroom.proto\x12\rminingroom.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x12\n" +
+	"\x10GetStatusRequest\"\x93\x03\n" +
+	"\x06Status\x12.\n" +
+	"\x04time\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x16\n" +
+	"\x06online\x18\x02 \x01(\bR\x06online\x12!\n" +
+	"\fhashrate_ghs\x18\x03 \x01(\x01R\vhashrateGhs\x12\x17\n" +
+	"\apower_w\x18\x04 \x01(\x01R\x06powerW\x12!\n" +
+	"\fpower_source\x18\x05 \x01(\tR\vpowerSource\x12-\n" +
+	"\x13efficiency_j_per_th\x18\x06 \x01(\x01R\x10efficiencyJPerTh\x12*\n" +
+	"\x11max_temperature_c\x18\a \x01(\x01R\x0fmaxTemperatureC\x12,\n" +
+	"\x12room_temperature_c\x18\b \x01(\x01R\x10roomTemperatureC\x12+\n" +
+	"\x11emergency_lockout\x18\t \x01(\bR\x10emergencyLockout\x12,\n" +
+	"\x06alerts\x18\n" +
+	" \x03(\v2\x14.miningroom.v1.AlertR\x06alerts\"\x99\x01\n" +
+	"\x05Alert\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x1a\n" +
+	"\bseverity\x18\x02 \x01(\tR\bseverity\x12\x16\n" +
+	"\x06source\x18\x03 \x01(\tR\x06source\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\tR\amessage\x120\n" +
+	"\x05since\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\x05since\"\x15\n" +
+	"\x13ListMachinesRequest\"J\n" +
+	"\x14ListMachinesResponse\x122\n" +
+	"\bmachines\x18\x01 \x03(\v2\x16.miningroom.v1.MachineR\bmachines\"\xe1\x01\n" +
+	"\aMachine\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x0e\n" +
+	"\x02ip\x18\x02 \x01(\tR\x02ip\x12\x10\n" +
+	"\x03mac\x18\x03 \x01(\tR\x03mac\x12\x1b\n" +
+	"\tshelly_ip\x18\x04 \x01(\tR\bshellyIp\x12\x16\n" +
+	"\x06outlet\x18\x05 \x01(\tR\x06outlet\x12\x1a\n" +
+	"\bfirmware\x18\x06 \x01(\tR\bfirmware\x12 \n" +
+	"\vmaintenance\x18\a \x01(\bR\vmaintenance\x12-\n" +
+	"\x12maintenance_reason\x18\b \x01(\tR\x11maintenanceReason\"\x19\n" +
+	"\x17GetMinerStatusesRequest\"C\n" +
+	"\rMinerStatuses\x122\n" +
+	"\x06miners\x18\x01 \x03(\v2\x1a.miningroom.v1.MinerStatusR\x06miners\"\xda\x02\n" +
+	"\vMinerStatus\x12\x0e\n" +
+	"\x02ip\x18\x01 \x01(\tR\x02ip\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12.\n" +
+	"\x04time\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x16\n" +
+	"\x06status\x18\x04 \x01(\tR\x06status\x12\x1b\n" +
+	"\twork_mode\x18\x05 \x01(\tR\bworkMode\x12!\n" +
+	"\fhashrate_ghs\x18\x06 \x01(\x01R\vhashrateGhs\x12\x17\n" +
+	"\apower_w\x18\a \x01(\x01R\x06powerW\x12\x1e\n" +
+	"\n" +
+	"efficiency\x18\b \x01(\x01R\n" +
+	"efficiency\x12*\n" +
+	"\x11temperature_max_c\x18\t \x01(\x01R\x0ftemperatureMaxC\x12\x18\n" +
+	"\anetwork\x18\n" +
+	" \x01(\tR\anetwork\x12 \n" +
+	"\vmaintenance\x18\v \x01(\bR\vmaintenance\"m\n" +
+	"\rMinersRequest\x12\x10\n" +
+	"\x03ips\x18\x01 \x03(\tR\x03ips\x12\x19\n" +
+	"\bgroup_id\x18\x02 \x01(\x03R\agroupId\x12/\n" +
+	"\x13include_maintenance\x18\x03 \x01(\bR\x12includeMaintenance\"\x80\x01\n" +
+	"\x12StartMinersRequest\x124\n" +
+	"\x06miners\x18\x01 \x01(\v2\x1c.miningroom.v1.MinersRequestR\x06miners\x12$\n" +
+	"\vgap_seconds\x18\x02 \x01(\x05H\x00R\n" +
+	"gapSeconds\x88\x01\x01B\x0e\n" +
+	"\f_gap_seconds\"f\n" +
+	"\x15SetPowerTargetRequest\x124\n" +
+	"\x06miners\x18\x01 \x01(\v2\x1c.miningroom.v1.MinersRequestR\x06miners\x12\x17\n" +
+	"\apower_w\x18\x02 \x01(\x05R\x06powerW\"1\n" +
+	"\x06JobRef\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\x03R\x05jobId\x12\x10\n" +
+	"\x03ips\x18\x02 \x03(\tR\x03ips\"&\n" +
+	"\rGetJobRequest\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\x03R\x05jobId\"\xed\x01\n" +
+	"\x03Job\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
+	"\x04kind\x18\x02 \x01(\tR\x04kind\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x129\n" +
+	"\n" +
+	"created_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12;\n" +
+	"\vfinished_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"finishedAt\x122\n" +
+	"\atargets\x18\x06 \x03(\v2\x18.miningroom.v1.JobTargetR\atargets\"I\n" +
+	"\tJobTarget\x12\x0e\n" +
+	"\x02ip\x18\x01 \x01(\tR\x02ip\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\"A\n" +
+	"\x14StreamMetricsRequest\x12)\n" +
+	"\x10interval_seconds\x18\x01 \x01(\x05R\x0fintervalSeconds\"l\n" +
+	"\aMetrics\x12-\n" +
+	"\x06status\x18\x01 \x01(\v2\x15.miningroom.v1.StatusR\x06status\x122\n" +
+	"\x06miners\x18\x02 \x03(\v2\x1a.miningroom.v1.MinerStatusR\x06miners2\xb3\x05\n" +
+	"\n" +
+	"MiningRoom\x12C\n" +
+	"\tGetStatus\x12\x1f.miningroom.v1.GetStatusRequest\x1a\x15.miningroom.v1.Status\x12W\n" +
+	"\fListMachines\x12\".miningroom.v1.ListMachinesRequest\x1a#.miningroom.v1.ListMachinesResponse\x12X\n" +
+	"\x10GetMinerStatuses\x12&.miningroom.v1.GetMinerStatusesRequest\x1a\x1c.miningroom.v1.MinerStatuses\x12G\n" +
+	"\vStartMiners\x12!.miningroom.v1.StartMinersRequest\x1a\x15.miningroom.v1.JobRef\x12E\n" +
+	"\x0eShutdownMiners\x12\x1c.miningroom.v1.MinersRequest\x1a\x15.miningroom.v1.JobRef\x12B\n" +
+	"\vSleepMiners\x12\x1c.miningroom.v1.MinersRequest\x1a\x15.miningroom.v1.JobRef\x12M\n" +
+	"\x0eSetPowerTarget\x12$.miningroom.v1.SetPowerTargetRequest\x1a\x15.miningroom.v1.JobRef\x12:\n" +
+	"\x06GetJob\x12\x1c.miningroom.v1.GetJobRequest\x1a\x12.miningroom.v1.Job\x12N\n" +
+	"\rStreamMetrics\x12#.miningroom.v1.StreamMetricsRequest\x1a\x16.miningroom.v1.Metrics0\x01B\x14Z\x12miningRoom/grpcapib\x06proto3"
+
+var (
+	file_grpcapi_miningroom_proto_rawDescOnce sync.Once
+	file_grpcapi_miningroom_proto_rawDescData []byte
+)
+
+func file_grpcapi_miningroom_proto_rawDescGZIP() []byte {
+	file_grpcapi_miningroom_proto_rawDescOnce.Do(func() {
+		file_grpcapi_miningroom_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_grpcapi_miningroom_proto_rawDesc), len(file_grpcapi_miningroom_proto_rawDesc)))
+	})
+	return file_grpcapi_miningroom_proto_rawDescData
+}
+
+var file_grpcapi_miningroom_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
+var file_grpcapi_miningroom_proto_goTypes = []any{
+	(*GetStatusRequest)(nil),        // 0: miningroom.v1.GetStatusRequest
+	(*Status)(nil),                  // 1: miningroom.v1.Status
+	(*Alert)(nil),                   // 2: miningroom.v1.Alert
+	(*ListMachinesRequest)(nil),     // 3: miningroom.v1.ListMachinesRequest
+	(*ListMachinesResponse)(nil),    // 4: miningroom.v1.ListMachinesResponse
+	(*Machine)(nil),                 // 5: miningroom.v1.Machine
+	(*GetMinerStatusesRequest)(nil), // 6: miningroom.v1.GetMinerStatusesRequest
+	(*MinerStatuses)(nil),           // 7: miningroom.v1.MinerStatuses
+	(*MinerStatus)(nil),             // 8: miningroom.v1.MinerStatus
+	(*MinersRequest)(nil),           // 9: miningroom.v1.MinersRequest
+	(*StartMinersRequest)(nil),      // 10: miningroom.v1.StartMinersRequest
+	(*SetPowerTargetRequest)(nil),   // 11: miningroom.v1.SetPowerTargetRequest
+	(*JobRef)(nil),                  // 12: miningroom.v1.JobRef
+	(*GetJobRequest)(nil),           // 13: miningroom.v1.GetJobRequest
+	(*Job)(nil),                     // 14: miningroom.v1.Job
+	(*JobTarget)(nil),               // 15: miningroom.v1.JobTarget
+	(*StreamMetricsRequest)(nil),    // 16: miningroom.v1.StreamMetricsRequest
+	(*Metrics)(nil),                 // 17: miningroom.v1.Metrics
+	(*timestamppb.Timestamp)(nil),   // 18: google.protobuf.Timestamp
+}
+var file_grpcapi_miningroom_proto_depIdxs = []int32{
+	18, // 0: miningroom.v1.Status.time:type_name -> google.protobuf.Timestamp
+	2,  // 1: miningroom.v1.Status.alerts:type_name -> miningroom.v1.Alert
+	18, // 2: miningroom.v1.Alert.since:type_name -> google.protobuf.Timestamp
+	5,  // 3: miningroom.v1.ListMachinesResponse.machines:type_name -> miningroom.v1.Machine
+	8,  // 4: miningroom.v1.MinerStatuses.miners:type_name -> miningroom.v1.MinerStatus
+	18, // 5: miningroom.v1.MinerStatus.time:type_name -> google.protobuf.Timestamp
+	9,  // 6: miningroom.v1.StartMinersRequest.miners:type_name -> miningroom.v1.MinersRequest
+	9,  // 7: miningroom.v1.SetPowerTargetRequest.miners:type_name -> miningroom.v1.MinersRequest
+	18, // 8: miningroom.v1.Job.created_at:type_name -> google.protobuf.Timestamp
+	18, // 9: miningroom.v1.Job.finished_at:type_name -> google.protobuf.Timestamp
+	15, // 10: miningroom.v1.Job.targets:type_name -> miningroom.v1.JobTarget
+	1,  // 11: miningroom.v1.Metrics.status:type_name -> miningroom.v1.Status
+	8,  // 12: miningroom.v1.Metrics.miners:type_name -> miningroom.v1.MinerStatus
+	0,  // 13: miningroom.v1.MiningRoom.GetStatus:input_type -> miningroom.v1.GetStatusRequest
+	3,  // 14: miningroom.v1.MiningRoom.ListMachines:input_type -> miningroom.v1.ListMachinesRequest
+	6,  // 15: miningroom.v1.MiningRoom.GetMinerStatuses:input_type -> miningroom.v1.GetMinerStatusesRequest
+	10, // 16: miningroom.v1.MiningRoom.StartMiners:input_type -> miningroom.v1.StartMinersRequest
+	9,  // 17: miningroom.v1.MiningRoom.ShutdownMiners:input_type -> miningroom.v1.MinersRequest
+	9,  // 18: miningroom.v1.MiningRoom.SleepMiners:input_type -> miningroom.v1.MinersRequest
+	11, // 19: miningroom.v1.MiningRoom.SetPowerTarget:input_type -> miningroom.v1.SetPowerTargetRequest
+	13, // 20: miningroom.v1.MiningRoom.GetJob:input_type -> miningroom.v1.GetJobRequest
+	16, // 21: miningroom.v1.MiningRoom.StreamMetrics:input_type -> miningroom.v1.StreamMetricsRequest
+	1,  // 22: miningroom.v1.MiningRoom.GetStatus:output_type -> miningroom.v1.Status
+	4,  // 23: miningroom.v1.MiningRoom.ListMachines:output_type -> miningroom.v1.ListMachinesResponse
+	7,  // 24: miningroom.v1.MiningRoom.GetMinerStatuses:output_type -> miningroom.v1.MinerStatuses
+	12, // 25: miningroom.v1.MiningRoom.StartMiners:output_type -> miningroom.v1.JobRef
+	12, // 26: miningroom.v1.MiningRoom.ShutdownMiners:output_type -> miningroom.v1.JobRef
+	12, // 27: miningroom.v1.MiningRoom.SleepMiners:output_type -> miningroom.v1.JobRef
+	12, // 28: miningroom.v1.MiningRoom.SetPowerTarget:output_type -> miningroom.v1.JobRef
+	14, // 29: miningroom.v1.MiningRoom.GetJob:output_type -> miningroom.v1.Job
+	17, // 30: miningroom.v1.MiningRoom.StreamMetrics:output_type -> miningroom.v1.Metrics
+	22, // [22:31] is the sub-list for method output_type
+	13, // [13:22] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
+}
+
+func init() { file_grpcapi_miningroom_proto_init() }
+func file_grpcapi_miningroom_proto_init() {
+	if File_grpcapi_miningroom_proto != nil {
+		return
+	}
+	file_grpcapi_miningroom_proto_msgTypes[10].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_grpcapi_miningroom_proto_rawDesc), len(file_grpcapi_miningroom_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   18,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_grpcapi_miningroom_proto_goTypes,
+		DependencyIndexes: file_grpcapi_miningroom_proto_depIdxs,
+		MessageInfos:      file_grpcapi_miningroom_proto_msgTypes,
+	}.Build()
+	File_grpcapi_miningroom_proto = out.File
+	file_grpcapi_miningroom_proto_goTypes = nil
+	file_grpcapi_miningroom_proto_depIdxs = nil
+}
@@ -0,0 +1,449 @@
+// gRPC API of the mining room dashboard, served on --grpc-addr next to the
+// REST API. Regenerate the Go code after changing this file with:
+//
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//     --go-grpc_out=. --go-grpc_opt=paths=source_relative grpcapi/miningroom.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: grpcapi/miningroom.proto
+
+package grpcapi
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	MiningRoom_GetStatus_FullMethodName        = "/miningroom.v1.MiningRoom/GetStatus"
+	MiningRoom_ListMachines_FullMethodName     = "/miningroom.v1.MiningRoom/ListMachines"
+	MiningRoom_GetMinerStatuses_FullMethodName = "/miningroom.v1.MiningRoom/GetMinerStatuses"
+	MiningRoom_StartMiners_FullMethodName      = "/miningroom.v1.MiningRoom/StartMiners"
+	MiningRoom_ShutdownMiners_FullMethodName   = "/miningroom.v1.MiningRoom/ShutdownMiners"
+	MiningRoom_SleepMiners_FullMethodName      = "/miningroom.v1.MiningRoom/SleepMiners"
+	MiningRoom_SetPowerTarget_FullMethodName   = "/miningroom.v1.MiningRoom/SetPowerTarget"
+	MiningRoom_GetJob_FullMethodName           = "/miningroom.v1.MiningRoom/GetJob"
+	MiningRoom_StreamMetrics_FullMethodName    = "/miningroom.v1.MiningRoom/StreamMetrics"
+)
+
+// MiningRoomClient is the client API for MiningRoom service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type MiningRoomClient interface {
+	// Fleet totals, emergency lockout and active alerts.
+	GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*Status, error)
+	// Configured machines.
+	ListMachines(ctx context.Context, in *ListMachinesRequest, opts ...grpc.CallOption) (*ListMachinesResponse, error)
+	// Latest reported status of every miner.
+	GetMinerStatuses(ctx context.Context, in *GetMinerStatusesRequest, opts ...grpc.CallOption) (*MinerStatuses, error)
+	// Control (inner network only). Each call queues a job like the bulk REST
+	// endpoints and returns its ID; follow it with GetJob.
+	StartMiners(ctx context.Context, in *StartMinersRequest, opts ...grpc.CallOption) (*JobRef, error)
+	// Requires the TOTP code in the x-totp-code metadata once 2FA is enrolled.
+	ShutdownMiners(ctx context.Context, in *MinersRequest, opts ...grpc.CallOption) (*JobRef, error)
+	SleepMiners(ctx context.Context, in *MinersRequest, opts ...grpc.CallOption) (*JobRef, error)
+	SetPowerTarget(ctx context.Context, in *SetPowerTargetRequest, opts ...grpc.CallOption) (*JobRef, error)
+	GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error)
+	// Sends a metrics snapshot every interval until the client cancels.
+	StreamMetrics(ctx context.Context, in *StreamMetricsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Metrics], error)
+}
+
+type miningRoomClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMiningRoomClient(cc grpc.ClientConnInterface) MiningRoomClient {
+	return &miningRoomClient{cc}
+}
+
+func (c *miningRoomClient) GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*Status, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Status)
+	err := c.cc.Invoke(ctx, MiningRoom_GetStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *miningRoomClient) ListMachines(ctx context.Context, in *ListMachinesRequest, opts ...grpc.CallOption) (*ListMachinesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListMachinesResponse)
+	err := c.cc.Invoke(ctx, MiningRoom_ListMachines_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *miningRoomClient) GetMinerStatuses(ctx context.Context, in *GetMinerStatusesRequest, opts ...grpc.CallOption) (*MinerStatuses, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MinerStatuses)
+	err := c.cc.Invoke(ctx, MiningRoom_GetMinerStatuses_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *miningRoomClient) StartMiners(ctx context.Context, in *StartMinersRequest, opts ...grpc.CallOption) (*JobRef, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(JobRef)
+	err := c.cc.Invoke(ctx, MiningRoom_StartMiners_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *miningRoomClient) ShutdownMiners(ctx context.Context, in *MinersRequest, opts ...grpc.CallOption) (*JobRef, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(JobRef)
+	err := c.cc.Invoke(ctx, MiningRoom_ShutdownMiners_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *miningRoomClient) SleepMiners(ctx context.Context, in *MinersRequest, opts ...grpc.CallOption) (*JobRef, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(JobRef)
+	err := c.cc.Invoke(ctx, MiningRoom_SleepMiners_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *miningRoomClient) SetPowerTarget(ctx context.Context, in *SetPowerTargetRequest, opts ...grpc.CallOption) (*JobRef, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(JobRef)
+	err := c.cc.Invoke(ctx, MiningRoom_SetPowerTarget_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *miningRoomClient) GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, MiningRoom_GetJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *miningRoomClient) StreamMetrics(ctx context.Context, in *StreamMetricsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Metrics], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MiningRoom_ServiceDesc.Streams[0], MiningRoom_StreamMetrics_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamMetricsRequest, Metrics]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MiningRoom_StreamMetricsClient = grpc.ServerStreamingClient[Metrics]
+
+// MiningRoomServer is the server API for MiningRoom service.
+// All implementations must embed UnimplementedMiningRoomServer
+// for forward compatibility.
+type MiningRoomServer interface {
+	// Fleet totals, emergency lockout and active alerts.
+	GetStatus(context.Context, *GetStatusRequest) (*Status, error)
+	// Configured machines.
+	ListMachines(context.Context, *ListMachinesRequest) (*ListMachinesResponse, error)
+	// Latest reported status of every miner.
+	GetMinerStatuses(context.Context, *GetMinerStatusesRequest) (*MinerStatuses, error)
+	// Control (inner network only). Each call queues a job like the bulk REST
+	// endpoints and returns its ID; follow it with GetJob.
+	StartMiners(context.Context, *StartMinersRequest) (*JobRef, error)
+	// Requires the TOTP code in the x-totp-code metadata once 2FA is enrolled.
+	ShutdownMiners(context.Context, *MinersRequest) (*JobRef, error)
+	SleepMiners(context.Context, *MinersRequest) (*JobRef, error)
+	SetPowerTarget(context.Context, *SetPowerTargetRequest) (*JobRef, error)
+	GetJob(context.Context, *GetJobRequest) (*Job, error)
+	// Sends a metrics snapshot every interval until the client cancels.
+	StreamMetrics(*StreamMetricsRequest, grpc.ServerStreamingServer[Metrics]) error
+	mustEmbedUnimplementedMiningRoomServer()
+}
+
+// UnimplementedMiningRoomServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMiningRoomServer struct{}
+
+func (UnimplementedMiningRoomServer) GetStatus(context.Context, *GetStatusRequest) (*Status, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedMiningRoomServer) ListMachines(context.Context, *ListMachinesRequest) (*ListMachinesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMachines not implemented")
+}
+func (UnimplementedMiningRoomServer) GetMinerStatuses(context.Context, *GetMinerStatusesRequest) (*MinerStatuses, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMinerStatuses not implemented")
+}
+func (UnimplementedMiningRoomServer) StartMiners(context.Context, *StartMinersRequest) (*JobRef, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartMiners not implemented")
+}
+func (UnimplementedMiningRoomServer) ShutdownMiners(context.Context, *MinersRequest) (*JobRef, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ShutdownMiners not implemented")
+}
+func (UnimplementedMiningRoomServer) SleepMiners(context.Context, *MinersRequest) (*JobRef, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SleepMiners not implemented")
+}
+func (UnimplementedMiningRoomServer) SetPowerTarget(context.Context, *SetPowerTargetRequest) (*JobRef, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetPowerTarget not implemented")
+}
+func (UnimplementedMiningRoomServer) GetJob(context.Context, *GetJobRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetJob not implemented")
+}
+func (UnimplementedMiningRoomServer) StreamMetrics(*StreamMetricsRequest, grpc.ServerStreamingServer[Metrics]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamMetrics not implemented")
+}
+func (UnimplementedMiningRoomServer) mustEmbedUnimplementedMiningRoomServer() {}
+func (UnimplementedMiningRoomServer) testEmbeddedByValue()                    {}
+
+// UnsafeMiningRoomServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MiningRoomServer will
+// result in compilation errors.
+type UnsafeMiningRoomServer interface {
+	mustEmbedUnimplementedMiningRoomServer()
+}
+
+func RegisterMiningRoomServer(s grpc.ServiceRegistrar, srv MiningRoomServer) {
+	// If the following call pancis, it indicates UnimplementedMiningRoomServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&MiningRoom_ServiceDesc, srv)
+}
+
+func _MiningRoom_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MiningRoomServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MiningRoom_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MiningRoomServer).GetStatus(ctx, req.(*GetStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MiningRoom_ListMachines_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMachinesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MiningRoomServer).ListMachines(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MiningRoom_ListMachines_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MiningRoomServer).ListMachines(ctx, req.(*ListMachinesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MiningRoom_GetMinerStatuses_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMinerStatusesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MiningRoomServer).GetMinerStatuses(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MiningRoom_GetMinerStatuses_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MiningRoomServer).GetMinerStatuses(ctx, req.(*GetMinerStatusesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MiningRoom_StartMiners_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartMinersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MiningRoomServer).StartMiners(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MiningRoom_StartMiners_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MiningRoomServer).StartMiners(ctx, req.(*StartMinersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MiningRoom_ShutdownMiners_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MinersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MiningRoomServer).ShutdownMiners(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MiningRoom_ShutdownMiners_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MiningRoomServer).ShutdownMiners(ctx, req.(*MinersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MiningRoom_SleepMiners_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MinersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MiningRoomServer).SleepMiners(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MiningRoom_SleepMiners_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MiningRoomServer).SleepMiners(ctx, req.(*MinersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MiningRoom_SetPowerTarget_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetPowerTargetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MiningRoomServer).SetPowerTarget(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MiningRoom_SetPowerTarget_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MiningRoomServer).SetPowerTarget(ctx, req.(*SetPowerTargetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MiningRoom_GetJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MiningRoomServer).GetJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MiningRoom_GetJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MiningRoomServer).GetJob(ctx, req.(*GetJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MiningRoom_StreamMetrics_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamMetricsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MiningRoomServer).StreamMetrics(m, &grpc.GenericServerStream[StreamMetricsRequest, Metrics]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MiningRoom_StreamMetricsServer = grpc.ServerStreamingServer[Metrics]
+
+// MiningRoom_ServiceDesc is the grpc.ServiceDesc for MiningRoom service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MiningRoom_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "miningroom.v1.MiningRoom",
+	HandlerType: (*MiningRoomServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetStatus",
+			Handler:    _MiningRoom_GetStatus_Handler,
+		},
+		{
+			MethodName: "ListMachines",
+			Handler:    _MiningRoom_ListMachines_Handler,
+		},
+		{
+			MethodName: "GetMinerStatuses",
+			Handler:    _MiningRoom_GetMinerStatuses_Handler,
+		},
+		{
+			MethodName: "StartMiners",
+			Handler:    _MiningRoom_StartMiners_Handler,
+		},
+		{
+			MethodName: "ShutdownMiners",
+			Handler:    _MiningRoom_ShutdownMiners_Handler,
+		},
+		{
+			MethodName: "SleepMiners",
+			Handler:    _MiningRoom_SleepMiners_Handler,
+		},
+		{
+			MethodName: "SetPowerTarget",
+			Handler:    _MiningRoom_SetPowerTarget_Handler,
+		},
+		{
+			MethodName: "GetJob",
+			Handler:    _MiningRoom_GetJob_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamMetrics",
+			Handler:       _MiningRoom_StreamMetrics_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "grpcapi/miningroom.proto",
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// logSchemaReport logs tables created on startup and any remaining schema drift.
+func logSchemaReport(report *questdb.SchemaReport) {
+	for _, t := range report.Tables {
+		switch {
+		case t.Created:
+			log.Printf("QuestDB schema: created table %s", t.Name)
+		case t.Error != "":
+			log.Printf("QuestDB schema: table %s: %s", t.Name, t.Error)
+		case !t.Exists:
+			log.Printf("QuestDB schema: table %s is missing", t.Name)
+		case len(t.MissingColumns) > 0:
+			log.Printf("QuestDB schema: table %s is missing columns %v", t.Name, t.MissingColumns)
+		}
+	}
+}
+
+// runQuestDBHealthCheck pings the primary QuestDB so reads fail over to the
+// secondary while it is down and return once it is back.
+func runQuestDBHealthCheck(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		questdbClient.CheckPrimary()
+	}
+}
+
+// onQuestDBFailover records reads moving between the QuestDB instances.
+func onQuestDBFailover(up bool, err error) {
+	if up {
+		recordEvent("questdb", "primary is back, reads served by the primary again")
+		return
+	}
+	recordEvent("questdb", "primary unreachable (%v), reads served by the secondary", err)
+}
+
+func questdbInstance(failover *questdb.FailoverStatus) string {
+	if failover == nil {
+		return questdb.InstancePrimary
+	}
+	return failover.Serving
+}
+
+// getHealthHandler reports SQLite, QuestDB and (with --tsdb influxdb)
+// InfluxDB availability, QuestDB schema drift, the QuestDB instance serving
+// reads and the last insert of each watched feed. It returns 503 when a
+// backend the dashboard reads from is unreachable; drift, a stopped feed or
+// reads failed over to the secondary QuestDB only mark the service as
+// degraded.
+func getHealthHandler(c *gin.Context) {
+	status := "ok"
+	code := http.StatusOK
+
+	sqliteOK := true
+	sqliteErr := ""
+	if err := database.Ping(); err != nil {
+		sqliteOK, sqliteErr = false, err.Error()
+		status, code = "down", http.StatusServiceUnavailable
+	}
+
+	questdbOK := true
+	questdbErr := ""
+	report, err := questdbFor(c).CheckSchema()
+	if err != nil {
+		questdbOK, questdbErr = false, err.Error()
+		if influxClient == nil {
+			status, code = "down", http.StatusServiceUnavailable
+		} else if code == http.StatusOK {
+			// Only the views InfluxDB can't serve are affected
+			status = "degraded"
+		}
+	} else if report.Drift && code == http.StatusOK {
+		status = "degraded"
+	}
+
+	var influx gin.H
+	if influxClient != nil {
+		influx = gin.H{"ok": true, "error": ""}
+		if err := influxClient.WithContext(c.Request.Context()).Ping(); err != nil {
+			influx = gin.H{"ok": false, "error": err.Error()}
+			status, code = "down", http.StatusServiceUnavailable
+		}
+	}
+
+	failover := questdbClient.Failover()
+	if failover != nil && !failover.PrimaryUp && code == http.StatusOK {
+		status = "degraded"
+	}
+
+	feedStates := feeds.list()
+	for _, f := range feedStates {
+		if f.Stale && code == http.StatusOK {
+			status = "degraded"
+		}
+	}
+
+	c.JSON(code, gin.H{
+		"status": status,
+		"sqlite": gin.H{
+			"ok":    sqliteOK,
+			"error": sqliteErr,
+		},
+		"questdb": gin.H{
+			"ok":     questdbOK,
+			"error":  questdbErr,
+			"schema": report,
+			// Instance reads are served by; details with a secondary
+			"instance": questdbInstance(failover),
+			"failover": failover,
+		},
+		"influxdb": influx, // null unless --tsdb influxdb
+		"feeds":    feedStates,
+	})
+}
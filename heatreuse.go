@@ -0,0 +1,163 @@
+package main
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	heatCapacity float64 // --heat-capacity: kJ/(L·K) of the recovery circuit's fluid
+	heatPrice    float64 // --heat-price: EUR per kWh of heat the recovery replaces
+)
+
+// heatRecoveryMaxRange is the longest window of the heat recovery chart.
+const heatRecoveryMaxRange = 31 * 24 * time.Hour
+
+// heatValuePrice is the price of a kWh of recovered heat: --heat-price, or
+// the electricity price for heat that would otherwise come from a resistive
+// heater.
+func heatValuePrice() float64 {
+	if heatPrice > 0 {
+		return heatPrice
+	}
+	return elecPrice
+}
+
+// HeatRecoveryDay is one day of the heat recovery summary.
+type HeatRecoveryDay struct {
+	Date         string  `json:"date"`
+	RecoveredKWh float64 `json:"recoveredKwh"`
+	EnergyKWh    float64 `json:"energyKwh"`
+	NetCostEUR   float64 `json:"netCostEur"`
+}
+
+// HeatRecoverySummary compares the heat recovered into the tank with the
+// electricity used over a period. NetCostEUR is the electricity cost minus
+// the value of the recovered heat.
+type HeatRecoverySummary struct {
+	From         time.Time         `json:"from"`
+	To           time.Time         `json:"to"`
+	RecoveredKWh float64           `json:"recoveredKwh"`
+	EnergyKWh    float64           `json:"energyKwh"`
+	RecoveryPct  float64           `json:"recoveryPct"` // recovered heat / electricity used
+	CostEUR      float64           `json:"costEur"`
+	HeatPrice    float64           `json:"heatPrice"` // EUR/kWh of heat
+	HeatValueEUR float64           `json:"heatValueEur"`
+	NetCostEUR   float64           `json:"netCostEur"`
+	Days         []HeatRecoveryDay `json:"days"`
+	HasData      bool              `json:"hasData"`
+}
+
+// buildHeatRecoverySummary sums recovered heat and electricity per day
+// between from and to. HasData is false without heat recovery readings.
+func buildHeatRecoverySummary(qdb *questdb.Client, from, to time.Time) (*HeatRecoverySummary, error) {
+	points, err := qdb.GetHeatRecovery(from, to, heatCapacity)
+	if err != nil {
+		return nil, err
+	}
+	energy, err := qdb.GetDailyEnergy(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	price := heatValuePrice()
+	summary := &HeatRecoverySummary{
+		From:      from,
+		To:        to,
+		HeatPrice: price,
+		Days:      []HeatRecoveryDay{},
+		HasData:   len(points) > 0,
+	}
+
+	recovered := make(map[string][]questdb.HeatRecoveryPoint)
+	for _, p := range points {
+		if len(p.Timestamp) >= 10 {
+			recovered[p.Timestamp[:10]] = append(recovered[p.Timestamp[:10]], p)
+		}
+	}
+	for _, e := range energy {
+		kwh := questdb.HeatRecoveryKWh(recovered[e.Date])
+		summary.RecoveredKWh += kwh
+		summary.EnergyKWh += e.EnergyKWh
+		summary.Days = append(summary.Days, HeatRecoveryDay{
+			Date:         e.Date,
+			RecoveredKWh: math.Round(kwh*100) / 100,
+			EnergyKWh:    math.Round(e.EnergyKWh*100) / 100,
+			NetCostEUR:   math.Round((e.EnergyKWh*elecPrice-kwh*price)*100) / 100,
+		})
+	}
+
+	if summary.EnergyKWh > 0 {
+		summary.RecoveryPct = math.Round(summary.RecoveredKWh/summary.EnergyKWh*1000) / 10
+	}
+	summary.CostEUR = math.Round(summary.EnergyKWh*elecPrice*100) / 100
+	summary.HeatValueEUR = math.Round(summary.RecoveredKWh*price*100) / 100
+	summary.NetCostEUR = math.Round((summary.CostEUR-summary.HeatValueEUR)*100) / 100
+	summary.RecoveredKWh = math.Round(summary.RecoveredKWh*10) / 10
+	summary.EnergyKWh = math.Round(summary.EnergyKWh*10) / 10
+	return summary, nil
+}
+
+// getHeatRecoveryChartHandler returns tank temperatures, flow and recovered
+// heat power per circuit over the window of ?range= or ?from=&to= (default
+// 24 hours).
+func getHeatRecoveryChartHandler(c *gin.Context) {
+	from, to, err := parseTimeWindow(c, requestNow(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if to.Sub(from) > heatRecoveryMaxRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "range is limited to 31 days"})
+		return
+	}
+
+	points, err := questdbFor(c).GetHeatRecovery(from, to, heatCapacity)
+	if err != nil {
+		log.Printf("Failed to get heat recovery from QuestDB: %v", err)
+		c.JSON(http.StatusOK, gin.H{
+			"circuits": map[string][]interface{}{},
+			"hasData":  false,
+		})
+		return
+	}
+
+	circuits := make(map[string][]questdb.HeatRecoveryPoint)
+	for _, p := range points {
+		circuits[p.Circuit] = append(circuits[p.Circuit], p)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"circuits":     circuits,
+		"recoveredKwh": math.Round(questdb.HeatRecoveryKWh(points)*100) / 100,
+		"hasData":      len(points) > 0,
+	})
+}
+
+// getHeatRecoveryReportHandler summarizes a calendar month (?month=2026-01,
+// default the current month so far).
+func getHeatRecoveryReportHandler(c *gin.Context) {
+	now := requestNow(c)
+	from, to, err := parseMonthWindow(c, now, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	summary, err := buildHeatRecoverySummary(questdbFor(c), from, to)
+	if err != nil {
+		log.Printf("Failed to build heat recovery summary: %v", err)
+		c.JSON(http.StatusOK, gin.H{
+			"days":    []interface{}{},
+			"hasData": false,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
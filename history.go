@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rawRetentionDays is how long raw QuestDB partitions are kept once rolled up.
+// Zero keeps raw data forever.
+var rawRetentionDays int
+
+// historyMetric maps an API metric name to a rollup field.
+type historyMetric struct {
+	Rollup questdb.Rollup
+	Field  string
+	Sum    bool // add up devices/miners into a single total series
+}
+
+var historyMetrics = map[string]historyMetric{
+	"power":       {Rollup: questdb.Rollups[0], Field: "power", Sum: true},
+	"hashrate":    {Rollup: questdb.Rollups[1], Field: "hashrate_average", Sum: true},
+	"temperature": {Rollup: questdb.Rollups[2], Field: "temperature"},
+	"humidity":    {Rollup: questdb.Rollups[2], Field: "humidity"},
+	"pressure":    {Rollup: questdb.Rollups[2], Field: "pressure"},
+}
+
+// runDownsampler rolls raw tables up into hourly and daily tables at the given
+// interval and drops expired raw partitions.
+func runDownsampler(interval time.Duration) {
+	downsample()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		downsample()
+	}
+}
+
+func downsample() {
+	for _, r := range questdb.Rollups {
+		hourlyUntil, err := questdbClient.Downsample(r, questdb.ResolutionHourly)
+		if err != nil {
+			log.Printf("Downsampler: %v", err)
+			continue
+		}
+		if _, err := questdbClient.Downsample(r, questdb.ResolutionDaily); err != nil {
+			log.Printf("Downsampler: %v", err)
+			continue
+		}
+
+		if rawRetentionDays <= 0 || hourlyUntil.IsZero() {
+			continue
+		}
+		// Never drop raw data that has not been rolled up yet
+		cutoff := time.Now().AddDate(0, 0, -rawRetentionDays)
+		if hourlyUntil.Before(cutoff) {
+			cutoff = hourlyUntil
+		}
+		if err := questdbClient.DropPartitionsBefore(r.Source, cutoff); err != nil {
+			log.Printf("Downsampler: %v", err)
+		}
+	}
+}
+
+// parseHistoryRange parses a range such as "24h" or "30d".
+func parseHistoryRange(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// parseTimeWindow reads the time window of a request, given either as
+// ?range=30d ending at now or as ?from=&to= RFC3339 timestamps. It defaults to
+// the last 24 hours.
+func parseTimeWindow(c *gin.Context, now time.Time) (from, to time.Time, err error) {
+	to = now
+	from = to.Add(-24 * time.Hour)
+	if s := c.Query("range"); s != "" {
+		d, err := parseHistoryRange(s)
+		if err != nil || d <= 0 {
+			return from, to, fmt.Errorf("invalid range")
+		}
+		from = to.Add(-d)
+	}
+	if s := c.Query("from"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid from: %w", err)
+		}
+		from = t
+	}
+	if s := c.Query("to"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid to: %w", err)
+		}
+		to = t
+	}
+	if !from.Before(to) {
+		return from, to, fmt.Errorf("from must be before to")
+	}
+	return from, to, nil
+}
+
+// getHistoryHandler returns long-term history for a metric. The time window is
+// given either as ?range=30d (ending now) or as ?from=&to= RFC3339 timestamps;
+// raw or rollup data is picked based on the window length. With
+// ?compare=previous_period the window before, as long and moved forward to
+// overlay, comes as "previous", e.g. this week against last week for
+// ?range=7d.
+func getHistoryHandler(c *gin.Context) {
+	metric, ok := historyMetrics[c.Param("metric")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown metric"})
+		return
+	}
+	compare, ok := wantsComparison(c)
+	if !ok {
+		return
+	}
+
+	from, to, err := parseTimeWindow(c, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := questdbFor(c).GetHistory(metric.Rollup, metric.Field, from, to, metric.Sum)
+	if err != nil {
+		log.Printf("Failed to get %s history from QuestDB: %v", c.Param("metric"), err)
+		c.JSON(http.StatusOK, gin.H{
+			"resolution": questdb.PickResolution(from, to).Name,
+			"series":     gin.H{},
+			"hasData":    false,
+		})
+		return
+	}
+	if !compare {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	// Whole-day windows move by calendar days so daily buckets stay aligned
+	// across DST changes
+	d, days := to.Sub(from), 0
+	prevFrom := from.Add(-d)
+	if d%(24*time.Hour) == 0 {
+		days = int(d / (24 * time.Hour))
+		prevFrom = from.In(roomLocation).AddDate(0, 0, -days)
+	}
+	previous := map[string][]questdb.TimeSeriesPoint{}
+	if prev, err := questdbFor(c).GetHistory(metric.Rollup, metric.Field, prevFrom, from, metric.Sum); err != nil {
+		log.Printf("Failed to get previous %s history from QuestDB: %v", c.Param("metric"), err)
+	} else {
+		for series, points := range prev.Series {
+			previous[series] = shiftPoints(points, days, d)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"resolution": result.Resolution,
+		"series":     result.Series,
+		"hasData":    result.HasData,
+		"previous":   previous,
+	})
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Locale controls translated strings, number formatting and temperature units.
+type Locale struct {
+	Lang           string `json:"lang"`
+	DecimalComma   bool   `json:"decimalComma"`
+	CurrencyPrefix bool   `json:"currencyPrefix"` // "€1.23" instead of "1,23 €"
+	Imperial       bool   `json:"imperial"`       // temperatures in °F
+}
+
+// locales lists the supported languages.
+var locales = map[string]Locale{
+	"en": {Lang: "en", CurrencyPrefix: true},
+	"de": {Lang: "de", DecimalComma: true},
+	"sl": {Lang: "sl", DecimalComma: true},
+}
+
+const defaultLang = "en"
+
+// translations maps English UI strings to other languages. Missing entries fall
+// back to English.
+var translations = map[string]map[string]string{
+	"de": {
+		"Overview":            "Übersicht",
+		"Miners":              "Miner",
+		"Power & Mining":      "Leistung & Mining",
+		"Environment":         "Umgebung",
+		"Manage":              "Verwalten",
+		"Settings":            "Einstellungen",
+		"Power":               "Leistung",
+		"Total Power":         "Gesamtleistung",
+		"Hashrate":            "Hashrate",
+		"Total Hashrate":      "Gesamt-Hashrate",
+		"Efficiency":          "Effizienz",
+		"Elec. Cost":          "Stromkosten",
+		"Cost Today":          "Kosten heute",
+		"Cost Projected":      "Kosten Prognose",
+		"Revenue":             "Ertrag",
+		"Revenue (pool, 24h)": "Ertrag (Pool, 24 h)",
+		"Revenue (estimate)":  "Ertrag (Schätzung)",
+		"Active Miners":       "Aktive Miner",
+		"Avg Temperature":     "Ø Temperatur",
+		"Uptime":              "Verfügbarkeit",
+		"online":              "online",
+		"day":                 "Tag",
+		"Online":              "Online",
+		"Mining":              "Mining",
+		"Stale Data":          "Veraltete Daten",
+		"No Data":             "Keine Daten",
+		"Replay":              "Wiedergabe",
+		"GPU Power":           "GPU-Leistung",
+		"Pool Hashrate":       "Pool-Hashrate",
+		"Profit":              "Gewinn",
+		"Room Temp":           "Raumtemperatur",
+		"Max Temperature":     "Max. Temperatur",
+		"Live":                "Live",
+	},
+	"sl": {
+		"Overview":            "Pregled",
+		"Miners":              "Rudarji",
+		"Power & Mining":      "Moč in rudarjenje",
+		"Environment":         "Okolje",
+		"Manage":              "Upravljanje",
+		"Settings":            "Nastavitve",
+		"Power":               "Moč",
+		"Total Power":         "Skupna moč",
+		"Hashrate":            "Hashrate",
+		"Total Hashrate":      "Skupni hashrate",
+		"Efficiency":          "Učinkovitost",
+		"Elec. Cost":          "Strošek elektrike",
+		"Cost Today":          "Strošek danes",
+		"Cost Projected":      "Predviden strošek",
+		"Revenue":             "Prihodek",
+		"Revenue (pool, 24h)": "Prihodek (bazen, 24 h)",
+		"Revenue (estimate)":  "Prihodek (ocena)",
+		"Active Miners":       "Aktivni rudarji",
+		"Avg Temperature":     "Povp. temperatura",
+		"Uptime":              "Razpoložljivost",
+		"online":              "na voljo",
+		"day":                 "dan",
+		"Online":              "Povezano",
+		"Mining":              "Rudari",
+		"Stale Data":          "Zastareli podatki",
+		"No Data":             "Ni podatkov",
+		"Replay":              "Predvajanje",
+		"GPU Power":           "Moč GPU",
+		"Pool Hashrate":       "Hashrate v bazenu",
+		"Profit":              "Dobiček",
+		"Room Temp":           "Temperatura prostora",
+		"Max Temperature":     "Najvišja temperatura",
+		"Live":                "V živo",
+	},
+}
+
+var (
+	forcedLang string // --locale; empty means negotiate from Accept-Language
+	imperial   bool   // --imperial
+)
+
+// translate returns the translation of s for lang, or s itself.
+func translate(lang, s string) string {
+	if t, ok := translations[lang][s]; ok {
+		return t
+	}
+	return s
+}
+
+// T translates a UI string.
+func (l Locale) T(s string) string {
+	return translate(l.Lang, s)
+}
+
+// Number formats a value with the given number of decimals and the locale's
+// decimal separator.
+func (l Locale) Number(v float64, decimals int) string {
+	s := strconv.FormatFloat(v, 'f', decimals, 64)
+	if l.DecimalComma {
+		s = strings.Replace(s, ".", ",", 1)
+	}
+	return s
+}
+
+// Money formats a EUR amount with the currency symbol in the locale's position.
+func (l Locale) Money(v float64) string {
+	if l.CurrencyPrefix {
+		return "€" + l.Number(v, 2)
+	}
+	return l.Number(v, 2) + " €"
+}
+
+// Temp converts a Celsius temperature to the locale's unit.
+func (l Locale) Temp(c float64) float64 {
+	if l.Imperial {
+		return math.Round((c*9/5+32)*10) / 10
+	}
+	return c
+}
+
+// TempUnit returns the temperature unit symbol.
+func (l Locale) TempUnit() string {
+	if l.Imperial {
+		return "°F"
+	}
+	return "°C"
+}
+
+// parseAcceptLanguage returns the first supported language from an
+// Accept-Language header, honoring q-values.
+func parseAcceptLanguage(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if i := strings.Index(tag, "-"); i > 0 {
+			tag = tag[:i]
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(f), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if _, ok := locales[tag]; ok && q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+	return best
+}
+
+// localeMiddleware resolves the request locale from ?lang=, the --locale flag or
+// the Accept-Language header and stores it in the gin context.
+func localeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lang := c.Query("lang")
+		if _, ok := locales[lang]; !ok {
+			lang = forcedLang
+		}
+		if _, ok := locales[lang]; !ok {
+			lang = parseAcceptLanguage(c.GetHeader("Accept-Language"))
+		}
+		if lang == "" {
+			lang = defaultLang
+		}
+
+		loc := locales[lang]
+		loc.Imperial = imperial || c.Query("units") == "imperial"
+		c.Set("Locale", loc)
+		c.Header("Content-Language", loc.Lang)
+		c.Next()
+	}
+}
+
+// localeFor returns the locale resolved by localeMiddleware.
+func localeFor(c *gin.Context) Locale {
+	if loc, ok := c.Get("Locale"); ok {
+		return loc.(Locale)
+	}
+	return locales[defaultLang]
+}
+
+// localizeGauges translates labels and units and formats values of gauge render data.
+// Entries may carry "Decimals" (default 0), "Money" or "Temperature" hints.
+func localizeGauges(loc Locale, gauges []gin.H) []gin.H {
+	for _, g := range gauges {
+		if label, ok := g["Label"].(string); ok {
+			g["Label"] = loc.T(label)
+		}
+		value, ok := g["Value"].(float64)
+		if !ok {
+			if unit, ok := g["Unit"].(string); ok {
+				g["Unit"] = loc.T(unit)
+			}
+			continue
+		}
+		decimals, _ := g["Decimals"].(int)
+		switch {
+		case g["Money"] == true:
+			g["Value"] = loc.Money(value)
+			if g["Unit"] == "€/day" {
+				g["Unit"] = "/" + loc.T("day")
+			} else {
+				g["Unit"] = "" // the amount carries the currency
+			}
+		case g["Temperature"] == true:
+			g["Value"] = loc.Number(loc.Temp(value), decimals)
+			g["Unit"] = loc.TempUnit()
+		default:
+			g["Value"] = loc.Number(value, decimals)
+		}
+	}
+	return gauges
+}
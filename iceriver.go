@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// iceriverPass is the web password of Iceriver miners (user admin).
+var iceriverPass string
+
+// iceriverDriver reads Iceriver miners through their web panel API. The panel
+// offers no power, frequency or sleep controls, so the driver is status only.
+type iceriverDriver struct{}
+
+// iceriverPanel logs in to the web panel and posts one userpanel request.
+func iceriverPanel(ctx context.Context, ip string, form url.Values, out interface{}) error {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 10 * time.Second, Jar: jar}
+	base := fmt.Sprintf("http://%s/user", urlHost(ip))
+
+	login, err := iceriverPost(ctx, client, base+"/loginpost", url.Values{
+		"post": {"6"},
+		"user": {"admin"},
+		"pwd":  {iceriverPass},
+	})
+	if err != nil {
+		return err
+	}
+	login.Body.Close()
+	if login.StatusCode != http.StatusOK {
+		return fmt.Errorf("login returned status %d", login.StatusCode)
+	}
+
+	resp, err := iceriverPost(ctx, client, base+"/userpanel", form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("userpanel returned status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// iceriverPost posts a form like http.Client.PostForm, canceled with ctx.
+func iceriverPost(ctx context.Context, client *http.Client, url string, form url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return client.Do(req)
+}
+
+// parseIceriverHashrate converts readings like "12.34T" or "850G" to GH/s.
+func parseIceriverHashrate(s string) float64 {
+	s = strings.TrimSpace(s)
+	scale := 1.0
+	switch {
+	case strings.HasSuffix(s, "T"):
+		scale = 1000
+	case strings.HasSuffix(s, "M"):
+		scale = 0.001
+	}
+	v, _ := strconv.ParseFloat(strings.TrimRight(s, "TGMH"), 64)
+	return v * scale
+}
+
+func (iceriverDriver) Config(ctx context.Context, ip string) (*MinerManageInfo, error) {
+	var resp struct {
+		Code int `json:"code"`
+		Data struct {
+			Online bool `json:"online"`
+			Boards []struct {
+				No     int     `json:"no"`
+				State  bool    `json:"state"`
+				RTPow  string  `json:"rtpow"`
+				InTmp  float64 `json:"intmp"`
+				OutTmp float64 `json:"outtmp"`
+			} `json:"boards"`
+		} `json:"data"`
+	}
+	if err := iceriverPanel(ctx, ip, url.Values{"post": {"4"}}, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != 0 {
+		return nil, fmt.Errorf("userpanel returned code %d", resp.Code)
+	}
+
+	info := &MinerManageInfo{
+		Online:   true,
+		WorkMode: "Auto",
+	}
+	for _, b := range resp.Data.Boards {
+		status := "Dead"
+		if b.State {
+			status = "Alive"
+		}
+		info.Chains = append(info.Chains, ChainInfo{
+			Index:      b.No,
+			Status:     status,
+			HashrateGH: parseIceriverHashrate(b.RTPow),
+			BoardTemp:  b.InTmp,
+			ChipTemp:   b.OutTmp,
+		})
+	}
+	return info, nil
+}
+
+func (iceriverDriver) SetPowerTarget(ctx context.Context, ip string, power int) error {
+	return errors.New("power targets are not supported on Iceriver")
+}
+
+func (iceriverDriver) SetFreqVolt(ctx context.Context, ip string, freq, volt float64) error {
+	return errors.New("fixed frequency/voltage is not supported on Iceriver")
+}
+
+func (iceriverDriver) SetSleep(ctx context.Context, ip string) error {
+	return errors.New("sleep is not supported on Iceriver; use shutdown")
+}
+
+func (iceriverDriver) RestartMining(ctx context.Context, ip string) error {
+	return errors.New("restarting the miner process is not supported on Iceriver")
+}
+
+func (iceriverDriver) Reboot(ctx context.Context, ip string) error {
+	return errors.New("reboot is not supported on Iceriver; power cycle instead")
+}
+
+func (iceriverDriver) Pools(ctx context.Context, ip string) ([]PoolInfo, error) {
+	return nil, errors.New("pool statistics are not supported on Iceriver")
+}
+
+// SSHCommands is empty: Iceriver firmware does not expose SSH.
+func (iceriverDriver) SSHCommands() map[string]sshCommand {
+	return map[string]sshCommand{}
+}
+
+func (iceriverDriver) Identify(ctx context.Context, ip string) (*MinerIdentity, error) {
+	return nil, errors.New("identification is not supported on Iceriver")
+}
+
+func (iceriverDriver) Capabilities() MinerCapabilities {
+	return MinerCapabilities{}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Machine inventory: a newly added miner is probed through its driver for its
+// model, firmware version and nominal hashrate, which are stored with the
+// machine and shown on the manage page.
+
+const identifyTimeout = 15 * time.Second
+
+// identifyMachine probes a miner and stores its identity. A MAC reported by
+// the firmware is stored when none was captured from the ARP table yet.
+func identifyMachine(ctx context.Context, ip string) (*MinerIdentity, error) {
+	id, err := driverFor(ip).Identify(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.SetMachineIdentity(ip, id.Model, id.FirmwareVersion, id.NominalHashrate); err != nil {
+		return nil, err
+	}
+	if id.MAC != "" {
+		if mac, err := normalizeMAC(id.MAC); err == nil {
+			id.MAC = mac
+			for _, m := range currentMachines() {
+				if m.IP == ip && m.MAC == "" {
+					if err := database.SetMachineMAC(ip, mac); err != nil {
+						log.Printf("Failed to store MAC of %s: %v", ip, err)
+					}
+				}
+			}
+		}
+	}
+	refreshMachines()
+	log.Printf("Identified %s as %q (firmware %q, nominal %.0f GH/s)", ip, id.Model, id.FirmwareVersion, id.NominalHashrate)
+	return id, nil
+}
+
+// identifyNewMachine identifies a just added machine in the background.
+func identifyNewMachine(ip string) {
+	ctx, cancel := context.WithTimeout(context.Background(), identifyTimeout)
+	defer cancel()
+	if _, err := identifyMachine(ctx, ip); err != nil {
+		log.Printf("Failed to identify %s: %v; identify it again from the manage API once it is reachable", ip, err)
+	}
+}
+
+// identifyMachineHandler probes a machine again, e.g. after a firmware update.
+func identifyMachineHandler(c *gin.Context) {
+	ip := ipParam(c)
+	found := false
+	for _, m := range currentMachines() {
+		if m.IP == ip {
+			found = true
+		}
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown machine " + ip})
+		return
+	}
+	id, err := identifyMachine(c.Request.Context(), ip)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "ip": ip, "identity": id})
+}
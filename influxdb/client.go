@@ -0,0 +1,213 @@
+// Package influxdb reads and writes the miningRoom metrics in an InfluxDB 2.x
+// bucket, for setups that already run InfluxDB instead of QuestDB. Tables
+// become measurements, SYMBOL columns tags and the other columns fields, so
+// the ILP written for QuestDB (including Telegraf's) lands unchanged. Queries
+// are Flux and return the questdb package's result types.
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"miningRoom/questdb"
+)
+
+// Client talks to the HTTP API of an InfluxDB 2.x server.
+type Client struct {
+	baseURL     string
+	org         string
+	bucket      string
+	token       string
+	httpClient  *http.Client
+	ctx         context.Context      // nil: context.Background()
+	asOf        time.Time            // zero: live data
+	location    *time.Location       // nil: calendar days in UTC
+	validRanges []questdb.ValidRange // nil: all values
+}
+
+func NewClient(baseURL, org, bucket, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		org:     org,
+		bucket:  bucket,
+		token:   token,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// WithContext returns a copy of the client whose queries and writes are
+// canceled when ctx is done.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	c2 := *c
+	c2.ctx = ctx
+	return &c2
+}
+
+// WithAsOf returns a copy of the client that answers as of t: Flux's now is
+// set to t, so every relative range ends there.
+func (c *Client) WithAsOf(t time.Time) *Client {
+	c2 := *c
+	c2.asOf = t
+	return &c2
+}
+
+// WithLocation returns a copy of the client whose calendar days start at
+// midnight in loc instead of UTC.
+func (c *Client) WithLocation(loc *time.Location) *Client {
+	c2 := *c
+	c2.location = loc
+	return &c2
+}
+
+// WithValidRanges returns a copy of the client that skips field values outside
+// ranges, the tables and columns of which name measurements and fields.
+func (c *Client) WithValidRanges(ranges []questdb.ValidRange) *Client {
+	c2 := *c
+	c2.validRanges = ranges
+	return &c2
+}
+
+func (c *Client) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+func (c *Client) do(method, path string, query url.Values, contentType string, body []byte) ([]byte, error) {
+	endpoint := c.baseURL + path + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(c.context(), method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Token "+c.token)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Message != "" {
+			return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, apiErr.Message)
+		}
+		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(data))
+	}
+	return data, nil
+}
+
+// Write writes points to the bucket.
+func (c *Client) Write(points []questdb.Point) error {
+	lines, err := questdb.Lines(points)
+	if err != nil {
+		return err
+	}
+	return c.WriteLines(lines)
+}
+
+// WriteLines writes raw line protocol to the bucket. Lines without a
+// timestamp get the server time.
+func (c *Client) WriteLines(lines string) error {
+	if strings.TrimSpace(lines) == "" {
+		return nil
+	}
+	q := url.Values{}
+	q.Set("org", c.org)
+	q.Set("bucket", c.bucket)
+	q.Set("precision", "ns")
+	_, err := c.do(http.MethodPost, "/api/v2/write", q, "text/plain; charset=utf-8", []byte(lines+"\n"))
+	return err
+}
+
+// Ping checks that the server is up and the token may read the bucket.
+func (c *Client) Ping() error {
+	_, err := c.Query(`buckets() |> filter(fn: (r) => r.name == ` + fluxString(c.bucket) + `) |> limit(n: 1)`)
+	return err
+}
+
+// Query runs a Flux script and returns the rows of all its result tables,
+// each as column name to raw value. The bucket is available to the script as
+// the variable bucket.
+func (c *Client) Query(flux string) ([]map[string]string, error) {
+	script := "bucket = " + fluxString(c.bucket) + "\n" + flux
+	if !c.asOf.IsZero() {
+		script = "option now = () => " + c.asOf.UTC().Format(time.RFC3339Nano) + "\n" + script
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"query":   script,
+		"type":    "flux",
+		"dialect": map[string]interface{}{"header": true, "annotations": []string{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	q := url.Values{}
+	q.Set("org", c.org)
+	data, err := c.do(http.MethodPost, "/api/v2/query", q, "application/json", body)
+	if err != nil {
+		return nil, err
+	}
+	return parseCSV(data)
+}
+
+// parseCSV reads the CSV of a Flux response: tables separated by blank lines,
+// each starting with a header row. A table with an error column reports a
+// failure during the query.
+func parseCSV(data []byte) ([]map[string]string, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	var (
+		header []string
+		rows   []map[string]string
+	)
+	for _, rec := range records {
+		if len(rec) > 1 && (rec[1] == "result" || rec[1] == "error" || rec[0] == "error") {
+			header = rec
+			continue
+		}
+		if header == nil {
+			continue
+		}
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(rec) && name != "" {
+				row[name] = rec[i]
+			}
+		}
+		if msg, ok := row["error"]; ok {
+			return nil, fmt.Errorf("query failed: %s", msg)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// fluxString quotes s as a Flux string literal.
+func fluxString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "${", `\${`).Replace(s) + `"`
+}
@@ -0,0 +1,510 @@
+package influxdb
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"miningRoom/questdb"
+	"miningRoom/questdb/schema"
+)
+
+// latestRange bounds the search for the latest row of a series; QuestDB's
+// LATEST ON has no bound, but Flux needs one.
+const latestRange = "-7d"
+
+// roomMeterFresh matches questdb: an older room meter reading no longer
+// replaces the sum of the plugs.
+const roomMeterFresh = 5 * time.Minute
+
+// fieldFilter is a Flux predicate matching the measurement and fields, without
+// the values outside the client's valid ranges.
+func (c *Client) fieldFilter(measurement string, fields ...string) string {
+	conds := make([]string, len(fields))
+	for i, f := range fields {
+		conds[i] = "r._field == " + fluxString(f)
+	}
+	filter := "r._measurement == " + fluxString(measurement) + " and (" + strings.Join(conds, " or ") + ")"
+	for _, r := range c.validRanges {
+		if r.Table != measurement || (r.Min == nil && r.Max == nil) {
+			continue
+		}
+		var bounds []string
+		if r.Min != nil {
+			bounds = append(bounds, "r._value >= "+fluxFloat(*r.Min))
+		}
+		if r.Max != nil {
+			bounds = append(bounds, "r._value <= "+fluxFloat(*r.Max))
+		}
+		filter += " and (r._field != " + fluxString(r.Column) + " or (" + strings.Join(bounds, " and ") + "))"
+	}
+	return filter
+}
+
+// fluxFloat renders v as a Flux float literal; Flux does not compare floats
+// with integers.
+func fluxFloat(v float64) string {
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// fluxArray renders names as a Flux array of strings.
+func fluxArray(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = fluxString(n)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// latestRows returns the latest row of each partition of a measurement, the
+// fields pivoted into columns next to the tags, like QuestDB's LATEST ON
+// timestamp PARTITION BY.
+func (c *Client) latestRows(measurement string, fields []string, partition ...string) ([]map[string]string, error) {
+	return c.Query(`from(bucket: bucket)
+  |> range(start: ` + latestRange + `)
+  |> filter(fn: (r) => ` + c.fieldFilter(measurement, fields...) + `)
+  |> last()
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+  |> group(columns: ` + fluxArray(partition) + `)
+  |> sort(columns: ["_time"])
+  |> last(column: "_time")
+  |> group()`)
+}
+
+// windowed returns a measurement's field over the last 24 hours, grouped by
+// the columns in by and aggregated with fn in windows of every, stamped with
+// the window start like QuestDB's SAMPLE BY ... ALIGN TO CALENDAR.
+func (c *Client) windowed(measurement, field, every, fn string, by ...string) ([]map[string]string, error) {
+	return c.Query(`from(bucket: bucket)
+  |> range(start: -24h)
+  |> filter(fn: (r) => ` + c.fieldFilter(measurement, field) + `)
+  |> group(columns: ` + fluxArray(by) + `)
+  |> aggregateWindow(every: ` + every + `, fn: ` + fn + `, createEmpty: false, timeSrc: "_start")`)
+}
+
+func num(row map[string]string, column string) float64 {
+	v, _ := strconv.ParseFloat(row[column], 64)
+	return v
+}
+
+// timestamp renders a row's _time the way QuestDB does.
+func timestamp(row map[string]string) string {
+	t, err := time.Parse(time.RFC3339Nano, row["_time"])
+	if err != nil {
+		return row["_time"]
+	}
+	return schema.FormatTime(t)
+}
+
+// latestTotal sums a field over the latest row of each partition, with the
+// newest timestamp among them.
+func (c *Client) latestTotal(measurement, field string, partition ...string) (ts string, total float64, ok bool, err error) {
+	rows, err := c.latestRows(measurement, []string{field}, partition...)
+	if err != nil {
+		return "", 0, false, err
+	}
+	for _, r := range rows {
+		total += num(r, field)
+		if t := timestamp(r); t > ts {
+			ts = t
+		}
+	}
+	return ts, total, len(rows) > 0, nil
+}
+
+func (c *Client) GetTotalHashrate() (*questdb.TotalHashrateResult, error) {
+	ts, total, ok, err := c.latestTotal(schema.TablePools, schema.ColHashrateAverage, schema.ColMinerIP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query total hashrate: %w", err)
+	}
+	return &questdb.TotalHashrateResult{Timestamp: ts, TotalHashrate: total, HasData: ok}, nil
+}
+
+// boardTemperatures returns the hotter sensor of the latest reading of each
+// hashboard, per miner.
+func (c *Client) boardTemperatures() (map[string][]float64, string, error) {
+	rows, err := c.latestRows(schema.TableHashboards, []string{schema.ColTemperatureRaw0, schema.ColTemperatureRaw1}, schema.ColMinerIP, schema.ColIdx)
+	if err != nil {
+		return nil, "", err
+	}
+	miners := make(map[string][]float64)
+	latest := ""
+	for _, r := range rows {
+		t := num(r, schema.ColTemperatureRaw0)
+		if t1 := num(r, schema.ColTemperatureRaw1); t1 >= t {
+			t = t1
+		}
+		miners[r[schema.ColMinerIP]] = append(miners[r[schema.ColMinerIP]], t)
+		if ts := timestamp(r); ts > latest {
+			latest = ts
+		}
+	}
+	return miners, latest, nil
+}
+
+func (c *Client) GetMaxTemperature() (*questdb.MaxTemperatureResult, error) {
+	miners, ts, err := c.boardTemperatures()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query max temperature: %w", err)
+	}
+	if len(miners) == 0 {
+		return &questdb.MaxTemperatureResult{HasData: false}, nil
+	}
+	result := &questdb.MaxTemperatureResult{Timestamp: ts, HasData: true}
+	first := true
+	for _, temps := range miners {
+		for _, t := range temps {
+			if first || t > result.MaxTemperature {
+				result.MaxTemperature, first = t, false
+			}
+		}
+	}
+	return result, nil
+}
+
+func (c *Client) GetAvgMaxTemperature() (*questdb.AvgTemperatureResult, error) {
+	miners, _, err := c.boardTemperatures()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query avg max temperature: %w", err)
+	}
+	if len(miners) == 0 {
+		return &questdb.AvgTemperatureResult{HasData: false}, nil
+	}
+	sum := 0.0
+	for _, temps := range miners {
+		max := temps[0]
+		for _, t := range temps[1:] {
+			if t > max {
+				max = t
+			}
+		}
+		sum += max
+	}
+	return &questdb.AvgTemperatureResult{AvgTemperature: sum / float64(len(miners)), HasData: true}, nil
+}
+
+// GetTotalPower returns the power of the room meter when it has a recent
+// reading, and the sum of the plugs otherwise.
+func (c *Client) GetTotalPower() (*questdb.TotalPowerResult, error) {
+	rows, err := c.latestRows("room_meter", []string{schema.ColPower}, schema.ColDeviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query room meter: %w", err)
+	}
+	var meter map[string]string
+	for _, r := range rows {
+		if meter == nil || timestamp(r) > timestamp(meter) {
+			meter = r
+		}
+	}
+	if meter != nil {
+		if t, err := time.Parse(time.RFC3339Nano, meter["_time"]); err == nil && c.now().Sub(t) <= roomMeterFresh {
+			return &questdb.TotalPowerResult{Timestamp: timestamp(meter), TotalPower: num(meter, schema.ColPower), HasData: true, Source: "meter"}, nil
+		}
+	}
+
+	ts, total, ok, err := c.latestTotal(schema.TableShellies, schema.ColPower, schema.ColDeviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query total power: %w", err)
+	}
+	if !ok {
+		return &questdb.TotalPowerResult{HasData: false}, nil
+	}
+	return &questdb.TotalPowerResult{Timestamp: ts, TotalPower: total, HasData: true, Source: "plugs"}, nil
+}
+
+// now is the time queries are answered at.
+func (c *Client) now() time.Time {
+	if !c.asOf.IsZero() {
+		return c.asOf
+	}
+	return time.Now()
+}
+
+// GetRoomTemperature returns the latest BME280 reading at any of the given
+// indoor locations.
+func (c *Client) GetRoomTemperature(locations []string) (*questdb.RoomTemperatureResult, error) {
+	if len(locations) == 0 {
+		return &questdb.RoomTemperatureResult{HasData: false}, nil
+	}
+	rows, err := c.latestRows(schema.TableBME280Readings, []string{schema.ColTemperature}, schema.ColLocation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query room temperature: %w", err)
+	}
+	indoor := make(map[string]bool, len(locations))
+	for _, l := range locations {
+		indoor[l] = true
+	}
+	var latest map[string]string
+	for _, r := range rows {
+		if indoor[r[schema.ColLocation]] && (latest == nil || timestamp(r) > timestamp(latest)) {
+			latest = r
+		}
+	}
+	if latest == nil {
+		return &questdb.RoomTemperatureResult{HasData: false}, nil
+	}
+	return &questdb.RoomTemperatureResult{Timestamp: timestamp(latest), Temperature: num(latest, schema.ColTemperature), HasData: true}, nil
+}
+
+func (c *Client) GetMinerStatuses() (*questdb.MinerStatusData, error) {
+	rows, err := c.latestRows(schema.TableMinerStatus,
+		[]string{schema.ColHashrate, schema.ColPower, schema.ColEfficiency, schema.ColTemperatureMax}, schema.ColMinerIP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query miner statuses: %w", err)
+	}
+	miners := make([]questdb.MinerStatusRow, 0, len(rows))
+	for _, r := range rows {
+		miners = append(miners, questdb.MinerStatusRow{
+			Timestamp:      timestamp(r),
+			MinerIP:        r[schema.ColMinerIP],
+			Status:         r[schema.ColStatus],
+			WorkMode:       r[schema.ColWorkMode],
+			Hashrate:       num(r, schema.ColHashrate),
+			Power:          num(r, schema.ColPower),
+			Efficiency:     num(r, schema.ColEfficiency),
+			TemperatureMax: num(r, schema.ColTemperatureMax),
+		})
+	}
+	return &questdb.MinerStatusData{Miners: miners, HasData: len(miners) > 0}, nil
+}
+
+func (c *Client) GetShelliesPower() (*questdb.ShelliesPowerData, error) {
+	rows, err := c.latestRows(schema.TableShellies, []string{schema.ColPower}, schema.ColDeviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shellies power: %w", err)
+	}
+	devices := make([]questdb.ShellyPowerReading, 0, len(rows))
+	for _, r := range rows {
+		devices = append(devices, questdb.ShellyPowerReading{
+			Timestamp: timestamp(r),
+			DeviceID:  r[schema.ColDeviceID],
+			Power:     num(r, schema.ColPower),
+		})
+	}
+	return &questdb.ShelliesPowerData{Devices: devices, HasData: len(devices) > 0}, nil
+}
+
+func (c *Client) GetHashboardsDetailed() (*questdb.HashboardDetailedData, error) {
+	rows, err := c.latestRows("hashboards_detailed", []string{"voltage", "frequency_avg"}, schema.ColMinerIP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query hashboards detailed: %w", err)
+	}
+	miners := make([]questdb.HashboardDetailedRow, 0, len(rows))
+	for _, r := range rows {
+		miners = append(miners, questdb.HashboardDetailedRow{
+			Timestamp:    timestamp(r),
+			MinerIP:      r[schema.ColMinerIP],
+			AvgVoltage:   num(r, "voltage"),
+			AvgFrequency: num(r, "frequency_avg"),
+		})
+	}
+	return &questdb.HashboardDetailedData{Miners: miners, HasData: len(miners) > 0}, nil
+}
+
+// GetMinerTemperatures returns each miner's sensors averaged across its
+// hashboards per reading over the last 24 hours.
+func (c *Client) GetMinerTemperatures() (*questdb.MinerTemperatureChartData, error) {
+	rows, err := c.Query(`from(bucket: bucket)
+  |> range(start: -24h)
+  |> filter(fn: (r) => ` + c.fieldFilter(schema.TableHashboards, schema.ColTemperatureRaw0, schema.ColTemperatureRaw1) + `)
+  |> group(columns: ["miner_ip", "_field", "_time"])
+  |> mean()
+  |> group(columns: ["miner_ip", "_field"])
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+  |> group()
+  |> sort(columns: ["_time"])`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query miner temperatures: %w", err)
+	}
+	miners := make(map[string][]questdb.MinerTemperatureReading)
+	for _, r := range rows {
+		ip := r[schema.ColMinerIP]
+		miners[ip] = append(miners[ip], questdb.MinerTemperatureReading{
+			Timestamp: timestamp(r),
+			MinerIP:   ip,
+			Temp0:     num(r, schema.ColTemperatureRaw0),
+			Temp1:     num(r, schema.ColTemperatureRaw1),
+		})
+	}
+	return &questdb.MinerTemperatureChartData{Miners: miners, HasData: len(miners) > 0}, nil
+}
+
+// midnight returns the start of the current calendar day in the client's
+// location.
+func (c *Client) midnight() time.Time {
+	loc := c.location
+	if loc == nil {
+		loc = time.UTC
+	}
+	now := c.now().In(loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+}
+
+// environmentToday returns today's readings of a BME280 quantity per
+// location, each averaged with the readings of the 10 minutes before it.
+func (c *Client) environmentToday(field string) (map[string][]questdb.TimeSeriesPoint, error) {
+	rows, err := c.Query(`from(bucket: bucket)
+  |> range(start: ` + c.midnight().UTC().Format(time.RFC3339) + `)
+  |> filter(fn: (r) => ` + c.fieldFilter(schema.TableBME280Readings, field) + `)
+  |> group(columns: ["location"])
+  |> sort(columns: ["_time"])`)
+	if err != nil {
+		return nil, err
+	}
+
+	type reading struct {
+		t time.Time
+		v float64
+	}
+	byLocation := make(map[string][]reading)
+	for _, r := range rows {
+		t, err := time.Parse(time.RFC3339Nano, r["_time"])
+		if err != nil {
+			continue
+		}
+		byLocation[r[schema.ColLocation]] = append(byLocation[r[schema.ColLocation]], reading{t, num(r, "_value")})
+	}
+
+	locations := make(map[string][]questdb.TimeSeriesPoint, len(byLocation))
+	for location, readings := range byLocation {
+		sort.Slice(readings, func(i, j int) bool { return readings[i].t.Before(readings[j].t) })
+		points := make([]questdb.TimeSeriesPoint, len(readings))
+		start, sum := 0, 0.0
+		for i, r := range readings {
+			sum += r.v
+			for readings[start].t.Before(r.t.Add(-10 * time.Minute)) {
+				sum -= readings[start].v
+				start++
+			}
+			points[i] = questdb.TimeSeriesPoint{Timestamp: schema.FormatTime(r.t), Value: sum / float64(i-start+1)}
+		}
+		locations[location] = points
+	}
+	return locations, nil
+}
+
+func (c *Client) GetEnvironmentTemperatures() (*questdb.EnvironmentChartData, error) {
+	series, err := c.environmentToday(schema.ColTemperature)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query environment temperatures: %w", err)
+	}
+	locations := make(map[string][]questdb.EnvironmentReading, len(series))
+	for location, points := range series {
+		for _, p := range points {
+			locations[location] = append(locations[location], questdb.EnvironmentReading{Timestamp: p.Timestamp, Location: location, Temperature: p.Value})
+		}
+	}
+	return &questdb.EnvironmentChartData{Locations: locations, HasData: len(locations) > 0}, nil
+}
+
+func (c *Client) GetEnvironmentHumidity() (*questdb.HumidityChartData, error) {
+	series, err := c.environmentToday(schema.ColHumidity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query environment humidity: %w", err)
+	}
+	locations := make(map[string][]questdb.HumidityReading, len(series))
+	for location, points := range series {
+		for _, p := range points {
+			locations[location] = append(locations[location], questdb.HumidityReading{Timestamp: p.Timestamp, Location: location, Humidity: p.Value})
+		}
+	}
+	return &questdb.HumidityChartData{Locations: locations, HasData: len(locations) > 0}, nil
+}
+
+func (c *Client) GetEnvironmentPressure() (*questdb.PressureChartData, error) {
+	series, err := c.environmentToday(schema.ColPressure)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query environment pressure: %w", err)
+	}
+	locations := make(map[string][]questdb.PressureReading, len(series))
+	for location, points := range series {
+		for _, p := range points {
+			locations[location] = append(locations[location], questdb.PressureReading{Timestamp: p.Timestamp, Location: location, Pressure: p.Value})
+		}
+	}
+	return &questdb.PressureChartData{Locations: locations, HasData: len(locations) > 0}, nil
+}
+
+// GetPowerTimeSeries returns total power per 10 minutes over the last 24
+// hours, from the room meter where it has data and the sum of the plugs
+// elsewhere.
+func (c *Client) GetPowerTimeSeries() (*questdb.TimeSeriesData, error) {
+	plugs, err := c.windowed(schema.TableShellies, schema.ColPower, "10m", "sum")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query power time series: %w", err)
+	}
+	byTimestamp := make(map[string]float64, len(plugs))
+	for _, r := range plugs {
+		byTimestamp[timestamp(r)] = num(r, "_value")
+	}
+	meter, err := c.windowed("room_meter", schema.ColPower, "10m", "mean")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query power time series: %w", err)
+	}
+	for _, r := range meter {
+		byTimestamp[timestamp(r)] = num(r, "_value")
+	}
+
+	points := make([]questdb.TimeSeriesPoint, 0, len(byTimestamp))
+	for ts, v := range byTimestamp {
+		points = append(points, questdb.TimeSeriesPoint{Timestamp: ts, Value: v})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Timestamp < points[j].Timestamp })
+	return &questdb.TimeSeriesData{Points: points, HasData: len(points) > 0}, nil
+}
+
+// GetHashrateTimeSeries returns total hashrate per 10 minutes over the last
+// 24 hours.
+func (c *Client) GetHashrateTimeSeries() (*questdb.TimeSeriesData, error) {
+	rows, err := c.windowed(schema.TablePools, schema.ColHashrateAverage, "10m", "sum")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query hashrate time series: %w", err)
+	}
+	points := make([]questdb.TimeSeriesPoint, 0, len(rows))
+	for _, r := range rows {
+		points = append(points, questdb.TimeSeriesPoint{Timestamp: timestamp(r), Value: num(r, "_value")})
+	}
+	return &questdb.TimeSeriesData{Points: points, HasData: len(points) > 0}, nil
+}
+
+// GetPerMinerHashrateTimeSeries returns each miner's hashrate, summed over
+// its pools per reading, over the last 24 hours.
+func (c *Client) GetPerMinerHashrateTimeSeries() (*questdb.MinerHashrateChartData, error) {
+	rows, err := c.Query(`from(bucket: bucket)
+  |> range(start: -24h)
+  |> filter(fn: (r) => ` + c.fieldFilter(schema.TablePools, schema.ColHashrateAverage) + `)
+  |> group(columns: ["miner_ip", "_time"])
+  |> sum()
+  |> group()
+  |> sort(columns: ["_time"])`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query per-miner hashrate time series: %w", err)
+	}
+	miners := make(map[string][]questdb.MinerHashrateReading)
+	for _, r := range rows {
+		ip := r[schema.ColMinerIP]
+		miners[ip] = append(miners[ip], questdb.MinerHashrateReading{Timestamp: timestamp(r), MinerIP: ip, Hashrate: num(r, "_value")})
+	}
+	return &questdb.MinerHashrateChartData{Miners: miners, HasData: len(miners) > 0}, nil
+}
+
+// GetPerDevicePowerTimeSeries returns each plug's average power per 10
+// minutes over the last 24 hours.
+func (c *Client) GetPerDevicePowerTimeSeries() (*questdb.DevicePowerChartData, error) {
+	rows, err := c.windowed(schema.TableShellies, schema.ColPower, "10m", "mean", schema.ColDeviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query per-device power time series: %w", err)
+	}
+	devices := make(map[string][]questdb.DevicePowerReading)
+	for _, r := range rows {
+		id := r[schema.ColDeviceID]
+		devices[id] = append(devices[id], questdb.DevicePowerReading{Timestamp: timestamp(r), DeviceID: id, Power: num(r, "_value")})
+	}
+	return &questdb.DevicePowerChartData{Devices: devices, HasData: len(devices) > 0}, nil
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"miningRoom/questdb"
+	"miningRoom/questdb/schema"
+
+	"github.com/gin-gonic/gin"
+)
+
+// shellySafetyEvents are Shelly switch events that mean the relay protected
+// itself and cut power.
+var shellySafetyEvents = map[string]bool{
+	"overtemp":     true,
+	"overpower":    true,
+	"overvoltage":  true,
+	"undervoltage": true,
+	"overcurrent":  true,
+}
+
+// shellyNotification is a Gen2 RPC notification (NotifyStatus/NotifyEvent) as
+// sent by Shelly scripts or outbound websockets.
+type shellyNotification struct {
+	Src    string                     `json:"src"`
+	Method string                     `json:"method"`
+	Params map[string]json.RawMessage `json:"params"`
+}
+
+// shellySwitchNotify is the switch component status; fields are only present
+// when they changed.
+type shellySwitchNotify struct {
+	Output      *bool    `json:"output"`
+	APower      *float64 `json:"apower"`
+	Voltage     *float64 `json:"voltage"`
+	Current     *float64 `json:"current"`
+	Temperature *struct {
+		TC *float64 `json:"tC"`
+	} `json:"temperature"`
+}
+
+type shellyEventNotify struct {
+	Component string  `json:"component"`
+	Event     string  `json:"event"`
+	TS        float64 `json:"ts"`
+}
+
+// shellyOutputs remembers the last pushed relay state per device so trips are
+// reported once.
+var (
+	shellyOutputsMu sync.Mutex
+	shellyOutputs   = make(map[string]bool)
+)
+
+// shellyIPForDevice returns the IP of a miner Shelly whose device ID has been
+// looked up before, or "".
+func shellyIPForDevice(deviceID string) string {
+	shellyDeviceIDsMu.Lock()
+	defer shellyDeviceIDsMu.Unlock()
+	for ip, id := range shellyDeviceIDs {
+		if id == deviceID {
+			return ip
+		}
+	}
+	return ""
+}
+
+// unixFloatTime converts a unix timestamp with fractional seconds; 0 gives the zero time.
+func unixFloatTime(ts float64) time.Time {
+	if ts <= 0 {
+		return time.Time{}
+	}
+	sec, frac := math.Modf(ts)
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
+
+// parseShellyNotification converts a notification to QuestDB points: switch
+// status into shellies, input state into contact_sensors (or smoke_alarms for
+// --smoke-inputs), smoke detector state into smoke_alarms and events into
+// shelly_events.
+func parseShellyNotification(n shellyNotification) ([]questdb.Point, []shellyEventNotify, *bool) {
+	var ts float64
+	if raw, ok := n.Params["ts"]; ok {
+		json.Unmarshal(raw, &ts)
+	}
+	at := unixFloatTime(ts)
+
+	var points []questdb.Point
+	var output *bool
+	for key, raw := range n.Params {
+		if strings.HasPrefix(key, "input:") {
+			// Door/window reed contacts on a Shelly input: the input is on while
+			// the magnet closes the contact, i.e. while the door is shut
+			var in struct {
+				State *bool `json:"state"`
+			}
+			if err := json.Unmarshal(raw, &in); err == nil && in.State != nil {
+				sensor := n.Src + "/" + key
+				point := contactPoint(sensor, sensorLocation(sensor), !*in.State)
+				if isSmokeInput(sensor) {
+					// Detector alarm relay: the input is on while in alarm
+					point = smokePoint(sensor, sensorLocation(sensor), "smoke", *in.State)
+				}
+				point.Time = at
+				points = append(points, point)
+			}
+			continue
+		}
+		if strings.HasPrefix(key, "smoke:") {
+			// Shelly Plus Smoke
+			var sm struct {
+				Alarm *bool `json:"alarm"`
+			}
+			if err := json.Unmarshal(raw, &sm); err == nil && sm.Alarm != nil {
+				sensor := n.Src + "/" + key
+				point := smokePoint(sensor, sensorLocation(sensor), "smoke", *sm.Alarm)
+				point.Time = at
+				points = append(points, point)
+			}
+			continue
+		}
+		if !strings.HasPrefix(key, "switch:") {
+			continue
+		}
+		var sw shellySwitchNotify
+		if err := json.Unmarshal(raw, &sw); err != nil {
+			continue
+		}
+		row := schema.Shelly{
+			Timestamp: at,
+			DeviceID:  n.Src,
+			Power:     sw.APower,
+			Voltage:   sw.Voltage,
+			Current:   sw.Current,
+			Output:    sw.Output,
+		}
+		if sw.Output != nil {
+			output = sw.Output
+		}
+		if sw.Temperature != nil {
+			row.Temperature = sw.Temperature.TC
+		}
+		if point, err := questdb.PointOf(row); err == nil && len(point.Fields) > 0 {
+			points = append(points, point)
+		}
+	}
+
+	var events []shellyEventNotify
+	if raw, ok := n.Params["events"]; ok {
+		json.Unmarshal(raw, &events)
+	}
+	for _, e := range events {
+		if e.Event == "" {
+			continue
+		}
+		points = append(points, questdb.Point{
+			Table:   "shelly_events",
+			Symbols: map[string]string{"device_id": n.Src, "component": e.Component, "event": e.Event},
+			Fields:  map[string]interface{}{"count": int64(1)},
+			Time:    unixFloatTime(e.TS),
+		})
+	}
+	return points, events, output
+}
+
+// shellyNotificationFromQuery builds a notification from webhook URL parameters,
+// e.g. ?src=${config.sys.device.name}&event=overtemp or &apower=${status["switch:0"].apower}.
+func shellyNotificationFromQuery(c *gin.Context) shellyNotification {
+	n := shellyNotification{Src: c.Query("src"), Params: map[string]json.RawMessage{}}
+	sw := map[string]interface{}{}
+	for _, key := range []string{"apower", "voltage", "current"} {
+		if v, err := strconv.ParseFloat(c.Query(key), 64); err == nil {
+			sw[key] = v
+		}
+	}
+	if v, err := strconv.ParseBool(c.Query("output")); err == nil {
+		sw["output"] = v
+	}
+	if len(sw) > 0 {
+		n.Params["switch:0"], _ = json.Marshal(sw)
+	}
+	if event := c.Query("event"); event != "" {
+		n.Params["events"], _ = json.Marshal([]shellyEventNotify{{
+			Component: c.DefaultQuery("component", "switch:0"),
+			Event:     event,
+		}})
+	}
+	return n
+}
+
+// ingestShellyHandler accepts pushed Shelly notifications so relay trips and
+// power changes reach QuestDB without waiting for the next poll.
+func ingestShellyHandler(c *gin.Context) {
+	var n shellyNotification
+	if c.Request.ContentLength != 0 && strings.HasPrefix(c.ContentType(), "application/json") {
+		if err := c.ShouldBindJSON(&n); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		n = shellyNotificationFromQuery(c)
+	}
+	if n.Src == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "src (device id) required"})
+		return
+	}
+
+	points, events, output := parseShellyNotification(n)
+	if len(points) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no switch or input status or events in payload"})
+		return
+	}
+	checkSmokeAlarms(points)
+	if err := storeFor(c).Write(points); err != nil {
+		log.Printf("Failed to write Shelly notification from %s: %v", n.Src, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to write to QuestDB"})
+		return
+	}
+
+	name := n.Src
+	shellyIP := shellyIPForDevice(n.Src)
+	for _, m := range currentMachines() {
+		if shellyIP != "" && m.ShellyIP == shellyIP {
+			name = m.Name
+		}
+	}
+	for _, e := range events {
+		if shellySafetyEvents[e.Event] {
+			recordEvent("shelly", "%s reported %s on %s", name, e.Event, e.Component)
+		}
+	}
+	if output != nil {
+		shellyOutputsMu.Lock()
+		prev, seen := shellyOutputs[n.Src]
+		shellyOutputs[n.Src] = *output
+		shellyOutputsMu.Unlock()
+
+		requested, ok := shellyWatch.requestedState(shellyIP)
+		if seen && prev && !*output && ok && requested {
+			recordEvent("shelly", "relay of %s switched off although it should be on", name)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"points":  len(points),
+	})
+}
+
+// Push ingest settings for DIY sensor nodes
+var (
+	ingestTokens       string // comma-separated
+	ingestMeasurements string // comma-separated allowlist of QuestDB tables
+)
+
+const ingestMaxBody = 1 << 20
+
+// requireIngestToken checks the token sent as "Authorization: Bearer <token>"
+// or ?token= against --ingest-tokens. With no tokens configured, push ingest
+// is disabled.
+func requireIngestToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokens := splitList(ingestTokens)
+		if len(tokens) == 0 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "ingest disabled: no --ingest-tokens configured"})
+			return
+		}
+		sent := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if sent == "" {
+			sent = c.Query("token")
+		}
+		for _, t := range tokens {
+			if sent != "" && subtle.ConstantTimeCompare([]byte(sent), []byte(t)) == 1 {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid ingest token"})
+	}
+}
+
+// ingestAllowed reports whether pushed data may be written to a measurement.
+func ingestAllowed(measurement string) bool {
+	for _, m := range splitList(ingestMeasurements) {
+		if m == measurement {
+			return true
+		}
+	}
+	return false
+}
+
+// lineMeasurement returns the measurement name of an ILP line, which ends at
+// the first unescaped comma or space.
+func lineMeasurement(line string) string {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case ',', ' ':
+			return strings.ReplaceAll(line[:i], "\\", "")
+		}
+	}
+	return ""
+}
+
+// splitUnescaped splits an ILP section at every sep not preceded by a backslash.
+func splitUnescaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func readIngestBody(c *gin.Context) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, ingestMaxBody))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "body too large"})
+		return nil, false
+	}
+	return body, true
+}
+
+// ingestLineProtocolHandler forwards InfluxDB line protocol to QuestDB after
+// checking every line's measurement against the allowlist. Timestamps must be
+// in nanoseconds or omitted.
+func ingestLineProtocolHandler(c *gin.Context) {
+	body, ok := readIngestBody(c)
+	if !ok {
+		return
+	}
+
+	var lines []string
+	for i, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		measurement := lineMeasurement(line)
+		if measurement == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("line %d: missing fields", i+1)})
+			return
+		}
+		if !ingestAllowed(measurement) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("line %d: measurement %s not allowed", i+1, measurement)})
+			return
+		}
+		if measurement == "smoke_alarms" {
+			sensor, location, kind, alarm := lineSmokeAlarm(line)
+			emergency.setAlarm(sensor, location, kind, alarm)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no lines"})
+		return
+	}
+
+	if err := storeFor(c).WriteLines(strings.Join(lines, "\n")); err != nil {
+		log.Printf("Failed to forward %d pushed lines from %s: %v", len(lines), c.ClientIP(), err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to write to QuestDB"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"points":  len(lines),
+	})
+}
+
+// IngestMeasurement is one pushed JSON measurement. Timestamp is unix seconds
+// (fractional allowed); omitted uses the server time.
+type IngestMeasurement struct {
+	Measurement string                 `json:"measurement"`
+	Tags        map[string]string      `json:"tags"`
+	Fields      map[string]interface{} `json:"fields"`
+	Timestamp   float64                `json:"timestamp"`
+}
+
+// ingestJSONHandler accepts a measurement object or an array of them.
+func ingestJSONHandler(c *gin.Context) {
+	body, ok := readIngestBody(c)
+	if !ok {
+		return
+	}
+
+	var measurements []IngestMeasurement
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		if err := json.Unmarshal(body, &measurements); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		var m IngestMeasurement
+		if err := json.Unmarshal(body, &m); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		measurements = append(measurements, m)
+	}
+
+	points := make([]questdb.Point, 0, len(measurements))
+	for i, m := range measurements {
+		if !ingestAllowed(m.Measurement) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("item %d: measurement %q not allowed", i, m.Measurement)})
+			return
+		}
+		if len(m.Fields) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("item %d: fields required", i)})
+			return
+		}
+		for k, v := range m.Fields {
+			switch v.(type) {
+			case float64, bool, string:
+			default:
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("item %d: field %s must be a number, bool or string", i, k)})
+				return
+			}
+		}
+		points = append(points, questdb.Point{
+			Table:   m.Measurement,
+			Symbols: m.Tags,
+			Fields:  m.Fields,
+			Time:    unixFloatTime(m.Timestamp),
+		})
+	}
+
+	checkSmokeAlarms(points)
+	if err := storeFor(c).Write(points); err != nil {
+		log.Printf("Failed to write %d pushed measurements from %s: %v", len(points), c.ClientIP(), err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to write to QuestDB"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"points":  len(points),
+	})
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Start staggering and inrush measurement settings.
+var (
+	startGapSeconds int
+	inrushSeconds   int
+	breakerAmps     float64
+	mainsVoltage    float64
+)
+
+// inrushPoll is how often a powering-up outlet is read. Outlets report
+// averaged active power, so the ramp shows the power-up curve rather than the
+// millisecond current spike of the PSU capacitors.
+const inrushPoll = time.Second
+
+// InrushSample is one outlet reading after the relay was switched on.
+type InrushSample struct {
+	OffsetMs int64   `json:"offsetMs"`
+	Power    float64 `json:"power"` // W
+}
+
+// startStagger spaces relay-on switching across all start jobs so that miners
+// don't power up at the same time.
+type startStagger struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+var stagger = &startStagger{}
+
+// wait blocks until gap has passed since the previous staggered start, or
+// until ctx is done.
+func (s *startStagger) wait(ctx context.Context, gap time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d := time.Until(s.last.Add(gap)); d > 0 {
+		if err := sleepCtx(ctx, d); err != nil {
+			return err
+		}
+	}
+	s.last = time.Now()
+	return nil
+}
+
+// startGap returns the gap between relay switches of a bulk start request.
+func startGap(req BulkMinerRequest) time.Duration {
+	if req.GapSeconds != nil {
+		return time.Duration(*req.GapSeconds) * time.Second
+	}
+	return time.Duration(startGapSeconds) * time.Second
+}
+
+// startMiner switches a miner's outlet on after the stagger gap and records
+// its power ramp in the background.
+func startMiner(ctx context.Context, ip string, gap time.Duration) error {
+	outlet, err := outletForMiner(ip)
+	if err != nil {
+		return err
+	}
+	if err := stagger.wait(ctx, gap); err != nil {
+		return err
+	}
+	if err := outlet.Set(ctx, true); err != nil {
+		return err
+	}
+	log.Printf("Switched miner at %s on (outlet %s)", ip, outlet.Key())
+	go measureInrush(ip, outlet, time.Now())
+	return nil
+}
+
+// measureInrush reads a metered outlet every inrushPoll for --inrush-seconds
+// after it was switched on and stores the ramp.
+func measureInrush(ip string, outlet outletController, startedAt time.Time) {
+	if inrushSeconds <= 0 {
+		return
+	}
+	ticker := time.NewTicker(inrushPoll)
+	defer ticker.Stop()
+
+	deadline := startedAt.Add(time.Duration(inrushSeconds) * time.Second)
+	var samples []InrushSample
+	peak := 0.0
+	for now := range ticker.C {
+		if now.After(deadline) {
+			break
+		}
+		status, err := outlet.Status(context.Background())
+		if err != nil {
+			continue
+		}
+		if !status.Metered {
+			return
+		}
+		samples = append(samples, InrushSample{OffsetMs: now.Sub(startedAt).Milliseconds(), Power: status.Power})
+		peak = math.Max(peak, status.Power)
+	}
+	if len(samples) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(samples)
+	if err != nil {
+		return
+	}
+	err = database.AddInrushRamp(db.InrushRamp{IP: ip, StartedAt: startedAt, PeakW: peak, Samples: string(data)})
+	if err != nil {
+		log.Printf("Failed to store power ramp of %s: %v", ip, err)
+	}
+}
+
+// InrushMachine is the measured power ramp of one miner.
+type InrushMachine struct {
+	IP        string         `json:"ip"`
+	Name      string         `json:"name"`
+	StartedAt time.Time      `json:"startedAt"`
+	PeakW     float64        `json:"peakW"`
+	SettledW  float64        `json:"settledW"` // last reading of the ramp
+	Samples   []InrushSample `json:"samples,omitempty"`
+}
+
+// InrushReport is the combined power ramp of a start job.
+type InrushReport struct {
+	JobID         int64           `json:"jobId"`
+	Status        string          `json:"status"`
+	StartedAt     *time.Time      `json:"startedAt,omitempty"`
+	GapSeconds    int             `json:"gapSeconds"`
+	Machines      []InrushMachine `json:"machines"`
+	Unmeasured    []string        `json:"unmeasured"` // started without a metered outlet ramp
+	PeakCombinedW float64         `json:"peakCombinedW"`
+	PeakAt        *time.Time      `json:"peakAt,omitempty"`
+	PeakAmps      float64         `json:"peakAmps"`
+	BreakerPct    float64         `json:"breakerPct,omitempty"` // of --breaker-amps
+	Combined      []InrushSample  `json:"combined,omitempty"`   // offsets from the job start
+}
+
+// inrushReport combines the ramps of the machines a start job switched on.
+// Each machine counts with its latest reading from its switch-on time, so the
+// combined curve shows how the staggered ramps add up.
+func inrushReport(job *db.Job, withSamples bool) (*InrushReport, error) {
+	report := &InrushReport{JobID: job.ID, Status: job.Status, StartedAt: job.StartedAt, Machines: []InrushMachine{}, Unmeasured: []string{}}
+	var req BulkMinerRequest
+	if err := json.Unmarshal([]byte(job.Params), &req); err == nil {
+		report.GapSeconds = int(startGap(req).Seconds())
+	}
+	if job.StartedAt == nil {
+		return report, nil
+	}
+	to := time.Now()
+	if job.FinishedAt != nil {
+		to = *job.FinishedAt
+	}
+	ramps, err := database.FetchInrushRamps(*job.StartedAt, to.Add(time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make(map[string]bool, len(job.Targets))
+	for _, t := range job.Targets {
+		targets[t.IP] = true
+	}
+	// Readings per second since the job started, per machine
+	perSecond := make(map[string]map[int64]float64)
+	last := int64(0)
+	for _, r := range ramps {
+		if !targets[r.IP] {
+			continue
+		}
+		var samples []InrushSample
+		if err := json.Unmarshal([]byte(r.Samples), &samples); err != nil || len(samples) == 0 {
+			continue
+		}
+		delete(targets, r.IP) // a retried target keeps its first ramp
+		m := InrushMachine{IP: r.IP, Name: minerName(r.IP), StartedAt: r.StartedAt, PeakW: r.PeakW, SettledW: samples[len(samples)-1].Power}
+		if withSamples {
+			m.Samples = samples
+		}
+		report.Machines = append(report.Machines, m)
+
+		seconds := make(map[int64]float64, len(samples))
+		base := r.StartedAt.Sub(*job.StartedAt).Milliseconds()
+		for _, s := range samples {
+			sec := (base + s.OffsetMs) / 1000
+			seconds[sec] = math.Max(seconds[sec], s.Power)
+			if sec > last {
+				last = sec
+			}
+		}
+		perSecond[r.IP] = seconds
+	}
+	for _, t := range job.Targets {
+		if targets[t.IP] && t.Status == db.TargetDone {
+			report.Unmeasured = append(report.Unmeasured, t.IP)
+		}
+	}
+	sort.Slice(report.Machines, func(i, j int) bool { return report.Machines[i].StartedAt.Before(report.Machines[j].StartedAt) })
+
+	current := make(map[string]float64)
+	for sec := int64(0); sec <= last; sec++ {
+		total := 0.0
+		for ip, seconds := range perSecond {
+			if w, ok := seconds[sec]; ok {
+				current[ip] = w
+			}
+			total += current[ip]
+		}
+		if withSamples {
+			report.Combined = append(report.Combined, InrushSample{OffsetMs: sec * 1000, Power: math.Round(total)})
+		}
+		if total > report.PeakCombinedW {
+			report.PeakCombinedW = math.Round(total)
+			at := job.StartedAt.Add(time.Duration(sec) * time.Second)
+			report.PeakAt = &at
+		}
+	}
+	if mainsVoltage > 0 {
+		report.PeakAmps = math.Round(report.PeakCombinedW/mainsVoltage*10) / 10
+	}
+	if breakerAmps > 0 {
+		report.BreakerPct = math.Round(report.PeakAmps/breakerAmps*1000) / 10
+	}
+	return report, nil
+}
+
+// getInrushReportsHandler lists the combined inrush peaks of recent start jobs.
+func getInrushReportsHandler(c *gin.Context) {
+	list, err := database.FetchJobs(100)
+	if err != nil {
+		log.Printf("Failed to fetch jobs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch jobs"})
+		return
+	}
+
+	reports := []InrushReport{}
+	for i := range list {
+		if list[i].Kind != "start" {
+			continue
+		}
+		report, err := inrushReport(&list[i], false)
+		if err != nil {
+			log.Printf("Failed to build inrush report of job %d: %v", list[i].ID, err)
+			continue
+		}
+		reports = append(reports, *report)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"reports":      reports,
+		"breakerAmps":  breakerAmps,
+		"mainsVoltage": mainsVoltage,
+		"hasData":      len(reports) > 0,
+	})
+}
+
+// getInrushReportHandler returns the per-machine and combined power ramps of a
+// start job.
+func getInrushReportHandler(c *gin.Context) {
+	id, ok := jobIDParam(c)
+	if !ok {
+		return
+	}
+
+	job, err := database.FetchJob(id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && job.Kind != "start") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "start job not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to fetch job %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch job"})
+		return
+	}
+
+	report, err := inrushReport(job, true)
+	if err != nil {
+		log.Printf("Failed to build inrush report of job %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build report"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,125 @@
+// Package control provides the feedback controllers shared by the room's
+// policies: a two-threshold hysteresis switch for on/off devices (heaters,
+// fans, dampers) and a PID controller with output limits, anti-windup and a
+// rate limit for continuous outputs such as power targets.
+package control
+
+import (
+	"math"
+	"time"
+)
+
+// Hysteresis switches on at On and off at Off. On above Off switches on when
+// the value is high (cooling, ventilation), On below Off when it is low
+// (heating); between the two the state is kept.
+type Hysteresis struct {
+	On  float64
+	Off float64
+}
+
+// Next returns the state for a value and whether a threshold was reached;
+// when it wasn't, the current state is returned.
+func (h Hysteresis) Next(value float64, current bool) (state, crossed bool) {
+	if h.On > h.Off {
+		switch {
+		case value >= h.On:
+			return true, true
+		case value <= h.Off:
+			return false, true
+		}
+	} else {
+		switch {
+		case value <= h.On:
+			return true, true
+		case value >= h.Off:
+			return false, true
+		}
+	}
+	return current, false
+}
+
+// PID is a PID controller. The error is setpoint - measured, so a positive
+// Kp raises the output while the measurement is below the setpoint, as
+// miner power does with room temperature; use negative gains when a higher
+// output lowers the measurement, as fan speed does. A PID only needs its
+// gains and limits set; it must not be copied after its first Update.
+type PID struct {
+	Kp, Ki, Kd float64
+
+	// Output bounds; both 0 leaves the output unbounded. The integral term
+	// stops growing while the output is held at a bound (anti-windup).
+	Min, Max float64
+	// MaxRate is the largest output change per second, 0 for no limit.
+	MaxRate float64
+
+	integral     float64
+	lastMeasured float64
+	lastOutput   float64
+	lastAt       time.Time
+	started      bool
+}
+
+// Update feeds a measurement taken at now and returns the new output. The
+// first update, and the first after Reset, have no derivative and no rate
+// limit as there is no previous sample; the derivative acts on the
+// measurement so a setpoint change doesn't kick the output.
+func (p *PID) Update(setpoint, measured float64, now time.Time) float64 {
+	err := setpoint - measured
+	var dt float64
+	if p.started {
+		dt = now.Sub(p.lastAt).Seconds()
+		if dt <= 0 {
+			return p.lastOutput
+		}
+	}
+
+	integral := p.integral + p.Ki*err*dt
+	var derivative float64
+	if dt > 0 {
+		derivative = -p.Kd * (measured - p.lastMeasured) / dt
+	}
+	out := p.Kp*err + integral + derivative
+
+	bounded := p.clamp(out)
+	if bounded == out || (out > bounded) != (p.Ki*err > 0) {
+		// Integrate only while unsaturated, or while the integral term moves
+		// the output back inside the bounds; with negative gains that is the
+		// opposite sign of the error
+		p.integral = integral
+	}
+	out = bounded
+
+	if p.started && p.MaxRate > 0 {
+		step := p.MaxRate * dt
+		out = math.Max(p.lastOutput-step, math.Min(p.lastOutput+step, out))
+	}
+
+	p.lastMeasured, p.lastOutput, p.lastAt, p.started = measured, out, now, true
+	return out
+}
+
+func (p *PID) clamp(v float64) float64 {
+	if p.Min == 0 && p.Max == 0 {
+		return v
+	}
+	return math.Max(p.Min, math.Min(p.Max, v))
+}
+
+// Reset forgets the controller's history; the next Update starts afresh.
+func (p *PID) Reset() {
+	p.integral, p.lastMeasured, p.lastOutput, p.lastAt, p.started = 0, 0, 0, time.Time{}, false
+}
+
+// Seed starts the controller from an output already in effect, e.g. the
+// current power target, so the first updates move from it instead of
+// jumping to Kp*err: the integral takes the output's value and the rate
+// limit applies from the first Update on.
+func (p *PID) Seed(output, measured float64, now time.Time) {
+	p.integral = p.clamp(output)
+	p.lastMeasured, p.lastOutput, p.lastAt, p.started = measured, p.integral, now, true
+}
+
+// Output returns the last output, 0 before the first Update.
+func (p *PID) Output() float64 {
+	return p.lastOutput
+}
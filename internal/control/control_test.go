@@ -0,0 +1,113 @@
+package control
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHysteresisNext(t *testing.T) {
+	cooling := Hysteresis{On: 30, Off: 25}
+	heating := Hysteresis{On: 15, Off: 18}
+
+	tests := []struct {
+		name        string
+		h           Hysteresis
+		value       float64
+		current     bool
+		wantState   bool
+		wantCrossed bool
+	}{
+		{"cooling at on threshold", cooling, 30, false, true, true},
+		{"cooling above on", cooling, 35, true, true, true},
+		{"cooling at off threshold", cooling, 25, true, false, true},
+		{"cooling below off", cooling, 20, true, false, true},
+		{"cooling between keeps on", cooling, 27, true, true, false},
+		{"cooling between keeps off", cooling, 27, false, false, false},
+		{"heating at on threshold", heating, 15, false, true, true},
+		{"heating below on", heating, 10, false, true, true},
+		{"heating at off threshold", heating, 18, true, false, true},
+		{"heating above off", heating, 22, true, false, true},
+		{"heating between keeps on", heating, 16.5, true, true, false},
+		{"heating between keeps off", heating, 16.5, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, crossed := tt.h.Next(tt.value, tt.current)
+			if state != tt.wantState || crossed != tt.wantCrossed {
+				t.Errorf("Next(%v, %v) = %v, %v; want %v, %v", tt.value, tt.current, state, crossed, tt.wantState, tt.wantCrossed)
+			}
+		})
+	}
+}
+
+func TestPIDProportional(t *testing.T) {
+	p := &PID{Kp: 2}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got := p.Update(20, 18, now); got != 4 {
+		t.Errorf("Update = %v, want 4", got)
+	}
+	if got := p.Update(20, 21, now.Add(time.Second)); got != -2 {
+		t.Errorf("Update = %v, want -2", got)
+	}
+}
+
+func TestPIDAntiWindup(t *testing.T) {
+	tests := []struct {
+		name     string
+		pid      PID
+		setpoint float64
+		low      float64 // measurement saturating the output
+		high     float64 // measurement moving it back inside
+	}{
+		// Positive gains: a low measurement saturates at Max
+		{"positive gains", PID{Ki: 1, Min: 0, Max: 10}, 20, 0, 30},
+		// Negative gains (fan speed): a high measurement saturates at Max
+		{"negative gains", PID{Ki: -1, Min: 0, Max: 10}, 20, 40, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.pid
+			now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+			p.Update(tt.setpoint, tt.low, now)
+			for i := 1; i <= 100; i++ {
+				if got := p.Update(tt.setpoint, tt.low, now.Add(time.Duration(i)*time.Second)); got != p.Max {
+					t.Fatalf("saturated output = %v, want %v", got, p.Max)
+				}
+			}
+			if p.integral > p.Max {
+				t.Fatalf("integral wound up to %v beyond Max %v", p.integral, p.Max)
+			}
+			// Without windup the output leaves the bound on the first step
+			// the error reverses
+			if got := p.Update(tt.setpoint, tt.high, now.Add(101*time.Second)); got >= p.Max {
+				t.Errorf("output after the error reversed = %v, want below %v", got, p.Max)
+			}
+		})
+	}
+}
+
+func TestPIDRateLimit(t *testing.T) {
+	p := &PID{Kp: 1, MaxRate: 0.5}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	p.Seed(1, 0, now)
+	if got := p.Update(0, 10, now.Add(2*time.Second)); got != 0 {
+		t.Errorf("Update = %v, want 0 (1 - 0.5/s × 2s)", got)
+	}
+	if got := p.Update(0, 10, now.Add(3*time.Second)); math.Abs(got-(-0.5)) > 1e-9 {
+		t.Errorf("Update = %v, want -0.5", got)
+	}
+}
+
+func TestPIDIgnoresStaleSamples(t *testing.T) {
+	p := &PID{Kp: 1, Ki: 1}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	first := p.Update(10, 5, now)
+	if got := p.Update(10, 0, now); got != first {
+		t.Errorf("Update at the same time = %v, want the last output %v", got, first)
+	}
+	p.Reset()
+	if got := p.Output(); got != 0 {
+		t.Errorf("Output after Reset = %v, want 0", got)
+	}
+}
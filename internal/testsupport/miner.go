@@ -0,0 +1,189 @@
+// Package testsupport provides httptest emulators of the devices and services
+// the dashboard talks to (kaonsu miners, Shelly Gen2 relays, QuestDB), so
+// drivers and control handlers can be exercised without real hardware. Point
+// a machine's IP or Shelly IP at an emulator's Addr().
+package testsupport
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// minerRealm is the digest realm announced by the fake miner.
+const minerRealm = "kaonsu"
+
+// Miner emulates the kaonsu HTTP API of a stock firmware miner: GET
+// /kaonsu/v1/miner_config returns the config, and a POST with valid digest
+// credentials replaces it.
+type Miner struct {
+	Server *httptest.Server
+
+	user, pass string
+
+	mu     sync.Mutex
+	config map[string]interface{}
+	posts  []map[string]interface{}
+	status int // non-zero: every request fails with this status
+}
+
+// NewMiner starts a fake miner accepting user/pass for config changes. It
+// starts in Auto mode with a 3000 W power target.
+func NewMiner(user, pass string) *Miner {
+	m := &Miner{
+		user: user,
+		pass: pass,
+		config: map[string]interface{}{
+			"mode": map[string]interface{}{
+				"work-mode-selector": "Auto",
+				"concorde": map[string]interface{}{
+					"mode-select":           "PowerTarget",
+					"mode-select-available": []interface{}{"PowerTarget", "HashrateTarget"},
+					"power-target":          3000.0,
+				},
+				"fixed": map[string]interface{}{
+					"freq": 500.0,
+					"volt": 13.5,
+				},
+			},
+		},
+	}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.serve))
+	return m
+}
+
+// Addr returns the host:port to use as the miner's IP.
+func (m *Miner) Addr() string {
+	return strings.TrimPrefix(m.Server.URL, "http://")
+}
+
+// Close shuts the emulator down.
+func (m *Miner) Close() {
+	m.Server.Close()
+}
+
+// Fail makes every following request answer with status; 0 restores normal
+// operation.
+func (m *Miner) Fail(status int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.status = status
+}
+
+// Mode returns the current "mode" section of the config.
+func (m *Miner) Mode() map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mode, _ := m.config["mode"].(map[string]interface{})
+	return mode
+}
+
+// WorkMode returns the current work-mode-selector.
+func (m *Miner) WorkMode() string {
+	mode, _ := m.Mode()["work-mode-selector"].(string)
+	return mode
+}
+
+// Posts returns the configs accepted so far, oldest first.
+func (m *Miner) Posts() []map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]map[string]interface{}(nil), m.posts...)
+}
+
+func (m *Miner) serve(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	status := m.status
+	m.mu.Unlock()
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	if r.URL.Path != "/kaonsu/v1/miner_config" {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		m.mu.Lock()
+		body, err := json.Marshal(m.config)
+		m.mu.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	case http.MethodPost:
+		if !m.authorized(r) {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="%s", nonce="%s", qop="auth"`, minerRealm, randomHex()))
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var config map[string]interface{}
+		if err := json.Unmarshal(body, &config); err != nil {
+			http.Error(w, "invalid config: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		m.mu.Lock()
+		m.config = config
+		m.posts = append(m.posts, config)
+		m.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"result":"ok"}`))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authorized checks an HTTP Digest (MD5, qop=auth or none) Authorization
+// header against the miner's credentials.
+func (m *Miner) authorized(r *http.Request) bool {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Digest ") {
+		return false
+	}
+	p := parseDigestParams(strings.TrimPrefix(header, "Digest "))
+	if p["username"] != m.user || p["realm"] != minerRealm {
+		return false
+	}
+
+	ha1 := md5Hex(m.user + ":" + minerRealm + ":" + m.pass)
+	ha2 := md5Hex(r.Method + ":" + p["uri"])
+	want := md5Hex(ha1 + ":" + p["nonce"] + ":" + ha2)
+	if p["qop"] == "auth" {
+		want = md5Hex(ha1 + ":" + p["nonce"] + ":" + p["nc"] + ":" + p["cnonce"] + ":auth:" + ha2)
+	}
+	return p["response"] == want
+}
+
+// parseDigestParams splits `key="value", key=value` pairs of a Digest header.
+func parseDigestParams(s string) map[string]string {
+	params := make(map[string]string)
+	for _, part := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		params[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return params
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
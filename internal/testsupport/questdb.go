@@ -0,0 +1,140 @@
+package testsupport
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// QuestDB emulates the QuestDB REST endpoints used by the questdb package:
+// /exec answers queries from canned results and /write records ILP lines.
+type QuestDB struct {
+	Server *httptest.Server
+
+	mu      sync.Mutex
+	results []cannedResult
+	queries []string
+	lines   []string
+}
+
+// cannedResult answers queries containing match.
+type cannedResult struct {
+	match   string
+	columns []string
+	rows    [][]interface{}
+}
+
+// NewQuestDB starts a fake QuestDB. Queries without a matching result get an
+// empty dataset.
+func NewQuestDB() *QuestDB {
+	q := &QuestDB{}
+	q.Server = httptest.NewServer(http.HandlerFunc(q.serve))
+	return q
+}
+
+// HostPort returns the host and port for questdb.NewClient.
+func (q *QuestDB) HostPort() (string, int) {
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(q.Server.URL, "http://"))
+	p, _ := strconv.Atoi(port)
+	return host, p
+}
+
+// Close shuts the emulator down.
+func (q *QuestDB) Close() {
+	q.Server.Close()
+}
+
+// Respond answers queries containing match with rows under columns. Results
+// registered later take precedence.
+func (q *QuestDB) Respond(match string, columns []string, rows ...[]interface{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.results = append([]cannedResult{{match, columns, rows}}, q.results...)
+}
+
+// Queries returns the queries received so far, oldest first.
+func (q *QuestDB) Queries() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]string(nil), q.queries...)
+}
+
+// Lines returns the ILP lines written so far, oldest first.
+func (q *QuestDB) Lines() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]string(nil), q.lines...)
+}
+
+func (q *QuestDB) serve(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/exec":
+		query := r.URL.Query().Get("query")
+		q.mu.Lock()
+		q.queries = append(q.queries, query)
+		var res cannedResult
+		for _, c := range q.results {
+			if strings.Contains(query, c.match) {
+				res = c
+				break
+			}
+		}
+		q.mu.Unlock()
+
+		columns := make([]map[string]string, len(res.columns))
+		for i, name := range res.columns {
+			columns[i] = map[string]string{"name": name, "type": columnType(res.rows, i)}
+		}
+		dataset := res.rows
+		if dataset == nil {
+			dataset = [][]interface{}{}
+		}
+		writeJSON(w, map[string]interface{}{
+			"query":   query,
+			"columns": columns,
+			"dataset": dataset,
+			"count":   len(dataset),
+		})
+	case "/write":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		q.mu.Lock()
+		for _, line := range strings.Split(string(body), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				q.lines = append(q.lines, line)
+			}
+		}
+		q.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// columnType guesses the QuestDB type of column i from the first row.
+func columnType(rows [][]interface{}, i int) string {
+	if len(rows) == 0 || i >= len(rows[0]) {
+		return "STRING"
+	}
+	switch rows[0][i].(type) {
+	case float64, float32:
+		return "DOUBLE"
+	case int, int64:
+		return "LONG"
+	case bool:
+		return "BOOLEAN"
+	default:
+		return "STRING"
+	}
+}
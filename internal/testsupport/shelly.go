@@ -0,0 +1,147 @@
+package testsupport
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Shelly emulates the Gen2 RPC API of a Shelly Pro 1PM: Switch.GetStatus,
+// Switch.Toggle, Switch.Set and Shelly.GetDeviceInfo on switch id 0. While
+// the relay is on it reports Power as its active power.
+type Shelly struct {
+	Server *httptest.Server
+	ID     string
+
+	mu      sync.Mutex
+	output  bool
+	power   float64
+	toggles int
+	status  int // non-zero: every request fails with this status
+}
+
+// NewShelly starts a fake Shelly with device ID id and the relay off.
+func NewShelly(id string) *Shelly {
+	s := &Shelly{ID: id}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Addr returns the host:port to use as the Shelly IP.
+func (s *Shelly) Addr() string {
+	return strings.TrimPrefix(s.Server.URL, "http://")
+}
+
+// Close shuts the emulator down.
+func (s *Shelly) Close() {
+	s.Server.Close()
+}
+
+// Fail makes every following request answer with status; 0 restores normal
+// operation.
+func (s *Shelly) Fail(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+// SetOutput sets the relay state without counting a toggle.
+func (s *Shelly) SetOutput(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.output = on
+}
+
+// SetPower sets the active power (W) reported while the relay is on.
+func (s *Shelly) SetPower(watts float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.power = watts
+}
+
+// Output reports whether the relay is on.
+func (s *Shelly) Output() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.output
+}
+
+// Toggles returns how many times the relay was switched over RPC.
+func (s *Shelly) Toggles() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.toggles
+}
+
+func (s *Shelly) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.status != 0 {
+		http.Error(w, http.StatusText(s.status), s.status)
+		return
+	}
+
+	method := strings.TrimPrefix(r.URL.Path, "/rpc/")
+	if strings.HasPrefix(method, "Switch.") && r.URL.Query().Get("id") != "0" {
+		http.Error(w, `{"code":-105,"message":"Argument 'id', value 0 not found!"}`, http.StatusNotFound)
+		return
+	}
+
+	switch method {
+	case "Shelly.GetDeviceInfo":
+		writeJSON(w, map[string]interface{}{"id": s.ID, "model": "SPSW-201XE16EU", "gen": 2})
+	case "Switch.GetStatus":
+		writeJSON(w, s.switchStatus())
+	case "Switch.Toggle":
+		was := s.output
+		s.output = !s.output
+		s.toggles++
+		writeJSON(w, map[string]interface{}{"was_on": was})
+	case "Switch.Set":
+		on := r.URL.Query().Get("on")
+		if on != "true" && on != "false" {
+			http.Error(w, `{"code":-103,"message":"Invalid argument 'on'"}`, http.StatusBadRequest)
+			return
+		}
+		was := s.output
+		s.output = on == "true"
+		if was != s.output {
+			s.toggles++
+		}
+		writeJSON(w, map[string]interface{}{"was_on": was})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// switchStatus is the Switch.GetStatus body; callers hold s.mu.
+func (s *Shelly) switchStatus() map[string]interface{} {
+	power := 0.0
+	if s.output {
+		power = s.power
+	}
+	return map[string]interface{}{
+		"id":      0,
+		"source":  "HTTP_in",
+		"output":  s.output,
+		"apower":  power,
+		"voltage": 230.0,
+		"current": power / 230.0,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomHex() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
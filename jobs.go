@@ -0,0 +1,423 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Job queue limits.
+var (
+	jobWorkers     int // jobs run at the same time
+	jobConcurrency int // machines handled in parallel within a job
+)
+
+// jobKind performs a job's operation on one machine. params is the request the
+// job was created from. ctx is canceled when the job is cancelled; kinds that
+// wait between steps stop on it, quick ones finish the machine.
+type jobKind func(ctx context.Context, params json.RawMessage, ip string) error
+
+// jobKinds maps job kinds to their per-machine operation. Long-running
+// operations register here and are queued with jobs.enqueue.
+var jobKinds = map[string]jobKind{
+	"power": func(ctx context.Context, params json.RawMessage, ip string) error {
+		var req BulkPowerRequest
+		if err := json.Unmarshal(params, &req); err != nil {
+			return err
+		}
+		power, err := guardPower(ip, req.Power)
+		if err != nil {
+			return err
+		}
+		if err := rampPowerTarget(ctx, ip, power, req.PowerRamp); err != nil {
+			return err
+		}
+		log.Printf("Set power to %d W for miner at %s", power, ip)
+		return nil
+	},
+	"freqvolt": func(ctx context.Context, params json.RawMessage, ip string) error {
+		var req BulkFreqVoltRequest
+		if err := json.Unmarshal(params, &req); err != nil {
+			return err
+		}
+		freq, volt, err := guardFreqVolt(ip, req.Freq, req.Volt)
+		if err != nil {
+			return err
+		}
+		if err := driverFor(ip).SetFreqVolt(ctx, ip, freq, volt); err != nil {
+			return err
+		}
+		log.Printf("Set freq=%.0f MHz volt=%.1f V for miner at %s", freq, volt, ip)
+		return nil
+	},
+	"sleep": func(ctx context.Context, params json.RawMessage, ip string) error {
+		if err := driverFor(ip).SetSleep(ctx, ip); err != nil {
+			return err
+		}
+		log.Printf("Set sleep mode for miner at %s", ip)
+		return nil
+	},
+	"start": func(ctx context.Context, params json.RawMessage, ip string) error {
+		// Conditions may have changed while the job was queued
+		if emergency.locked() {
+			return errors.New("emergency lockout active")
+		}
+		if condensation.blocksColdStart() {
+			return errors.New("condensation risk: miner start paused")
+		}
+		var req BulkMinerRequest
+		if err := json.Unmarshal(params, &req); err != nil {
+			return err
+		}
+		return startMiner(ctx, ip, startGap(req))
+	},
+	"shutdown": func(ctx context.Context, params json.RawMessage, ip string) error {
+		return switchMinerRelay(ctx, ip, false)
+	},
+	"restore":  restoreMiner,
+	"template": applyTemplate,
+	"tune":     runTuning,
+}
+
+// switchMinerRelay powers a miner on or off through its Shelly.
+func switchMinerRelay(ctx context.Context, ip string, on bool) error {
+	outlet, err := outletForMiner(ip)
+	if err != nil {
+		return err
+	}
+	if err := outlet.Set(ctx, on); err != nil {
+		return err
+	}
+	log.Printf("Switched miner at %s %s (outlet %s)", ip, onOff(on), outlet.Key())
+	return nil
+}
+
+// jobQueue runs jobs persisted in SQLite. Jobs interrupted by a restart are
+// resumed with their remaining targets.
+type jobQueue struct {
+	claimMu   sync.Mutex    // serializes claiming and cancelling queued jobs
+	wake      chan struct{} // signals idle workers that a job was queued
+	mu        sync.Mutex
+	cancelled map[int64]bool               // running jobs asked to stop
+	stops     map[int64]context.CancelFunc // cancel the contexts of running jobs
+}
+
+var jobs = &jobQueue{
+	wake:      make(chan struct{}, 1),
+	cancelled: make(map[int64]bool),
+	stops:     make(map[int64]context.CancelFunc),
+}
+
+// enqueue stores a job for the given machines and wakes a worker.
+func (q *jobQueue) enqueue(kind string, params interface{}, ips []string) (int64, error) {
+	if _, ok := jobKinds[kind]; !ok {
+		return 0, fmt.Errorf("unknown job kind %q", kind)
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return 0, err
+	}
+	id, err := database.CreateJob(kind, string(data), ips)
+	if err != nil {
+		return 0, err
+	}
+	q.signal()
+	return id, nil
+}
+
+func (q *jobQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// runJobWorkers requeues jobs interrupted by a restart and starts n workers.
+func runJobWorkers(n int) {
+	if jobConcurrency < 1 {
+		jobConcurrency = 1
+	}
+
+	requeued, err := database.RequeueRunningJobs()
+	if err != nil {
+		log.Printf("Job queue: failed to requeue interrupted jobs: %v", err)
+	} else if requeued > 0 {
+		log.Printf("Job queue: resuming %d interrupted job(s)", requeued)
+	}
+
+	for i := 0; i < n; i++ {
+		go jobs.worker()
+	}
+}
+
+func (q *jobQueue) worker() {
+	for {
+		q.claimMu.Lock()
+		job, err := database.ClaimNextJob()
+		q.claimMu.Unlock()
+		if err != nil {
+			log.Printf("Job queue: failed to claim job: %v", err)
+		}
+		if job == nil {
+			select {
+			case <-q.wake:
+			case <-time.After(10 * time.Second):
+			}
+			continue
+		}
+
+		// More jobs may be waiting for another idle worker
+		q.signal()
+		q.execute(job)
+	}
+}
+
+func (q *jobQueue) execute(job *db.Job) {
+	run, ok := jobKinds[job.Kind]
+	if !ok {
+		log.Printf("Job queue: job %d has unknown kind %q", job.ID, job.Kind)
+		if err := database.FinishJob(job.ID, db.JobFailed); err != nil {
+			log.Printf("Job queue: failed to finish job %d: %v", job.ID, err)
+		}
+		return
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	q.mu.Lock()
+	q.stops[job.ID] = stop
+	q.mu.Unlock()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := 0
+	sem := make(chan struct{}, jobConcurrency)
+
+	// Targets that failed before the job was (re)started count too; they are
+	// counted before any goroutine can touch failed
+	for _, t := range job.Targets {
+		if t.Status == db.TargetFailed {
+			failed++
+		}
+	}
+	for _, t := range job.Targets {
+		if t.Status != db.TargetPending {
+			continue
+		}
+		if q.isCancelled(job.ID) {
+			break
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			recordPrevious(ctx, job, ip)
+			status, errMsg := db.TargetDone, ""
+			if err := run(ctx, json.RawMessage(job.Params), ip); err != nil {
+				log.Printf("Job %d (%s) failed for %s: %v", job.ID, job.Kind, ip, err)
+				status, errMsg = db.TargetFailed, err.Error()
+				mu.Lock()
+				failed++
+				mu.Unlock()
+			}
+			if err := database.FinishJobTarget(job.ID, ip, status, errMsg); err != nil {
+				log.Printf("Job queue: failed to record %s for job %d: %v", ip, job.ID, err)
+			}
+		}(t.IP)
+	}
+	wg.Wait()
+
+	status := db.JobDone
+	switch {
+	case q.isCancelled(job.ID):
+		status = db.JobCancelled
+	case failed > 0:
+		status = db.JobFailed
+	}
+	if err := database.FinishJob(job.ID, status); err != nil {
+		log.Printf("Job queue: failed to finish job %d: %v", job.ID, err)
+	}
+
+	q.mu.Lock()
+	delete(q.cancelled, job.ID)
+	delete(q.stops, job.ID)
+	q.mu.Unlock()
+
+	log.Printf("Job %d (%s) %s: %d of %d machine(s) failed", job.ID, job.Kind, status, failed, len(job.Targets))
+}
+
+func (q *jobQueue) isCancelled(id int64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.cancelled[id]
+}
+
+// cancel stops a job. Queued jobs are cancelled immediately; running jobs finish
+// the machines already in progress, unless their kind stops on the job's
+// context, and skip the rest.
+func (q *jobQueue) cancel(id int64) (*db.Job, error) {
+	q.claimMu.Lock()
+	defer q.claimMu.Unlock()
+
+	job, err := database.FetchJob(id)
+	if err != nil {
+		return nil, err
+	}
+	switch job.Status {
+	case db.JobQueued:
+		if err := database.FinishJob(id, db.JobCancelled); err != nil {
+			return nil, err
+		}
+	case db.JobRunning:
+		q.mu.Lock()
+		q.cancelled[id] = true
+		if stop, ok := q.stops[id]; ok {
+			stop()
+		}
+		q.mu.Unlock()
+	}
+	return job, nil
+}
+
+// JobInfo is a job with progress counts.
+type JobInfo struct {
+	db.Job
+	Params    json.RawMessage `json:"params"`
+	Total     int             `json:"total"`
+	Pending   int             `json:"pending"`
+	Succeeded int             `json:"succeeded"`
+	Failed    []string        `json:"failed"`
+	UndoUntil *time.Time      `json:"undoUntil,omitempty"` // set while the job can be undone
+}
+
+func newJobInfo(j db.Job) JobInfo {
+	info := JobInfo{Job: j, Params: json.RawMessage(j.Params), Total: len(j.Targets), Failed: []string{}}
+	for _, t := range j.Targets {
+		switch t.Status {
+		case db.TargetPending:
+			info.Pending++
+		case db.TargetDone:
+			info.Succeeded++
+		case db.TargetFailed:
+			info.Failed = append(info.Failed, t.IP)
+		}
+	}
+	if deadline := undoDeadline(j); deadline != nil && time.Now().Before(*deadline) {
+		info.UndoUntil = deadline
+	}
+	return info
+}
+
+// respondJobQueued queues a bulk operation and writes the job reference.
+func respondJobQueued(c *gin.Context, kind string, params interface{}, ips []string) {
+	id, err := jobs.enqueue(kind, params, ips)
+	if err != nil {
+		log.Printf("Failed to queue %s job: %v", kind, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue job"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"jobId":   id,
+		"ips":     ips,
+		"count":   len(ips),
+	})
+}
+
+func getJobsHandler(c *gin.Context) {
+	limit := 50
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = n
+	}
+
+	list, err := database.FetchJobs(limit)
+	if err != nil {
+		log.Printf("Failed to fetch jobs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch jobs"})
+		return
+	}
+
+	infos := make([]JobInfo, 0, len(list))
+	for _, j := range list {
+		infos = append(infos, newJobInfo(j))
+	}
+	c.JSON(http.StatusOK, gin.H{"jobs": infos})
+}
+
+// jobIDParam parses the :id route parameter, writing a 400 response if invalid.
+func jobIDParam(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return 0, false
+	}
+	return id, true
+}
+
+func getJobHandler(c *gin.Context) {
+	id, ok := jobIDParam(c)
+	if !ok {
+		return
+	}
+
+	job, err := database.FetchJob(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to fetch job %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch job"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newJobInfo(*job))
+}
+
+func cancelJobHandler(c *gin.Context) {
+	id, ok := jobIDParam(c)
+	if !ok {
+		return
+	}
+
+	job, err := jobs.cancel(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to cancel job %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel job"})
+		return
+	}
+	if job.Status != db.JobQueued && job.Status != db.JobRunning {
+		c.JSON(http.StatusConflict, gin.H{"error": "job already " + job.Status})
+		return
+	}
+
+	recordEvent("jobs", "job %d (%s) cancelled from %s", id, job.Kind, c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"id":      id,
+	})
+}
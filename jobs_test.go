@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"miningRoom/db"
+)
+
+func TestJobCountsTargetsFailedBeforeARestart(t *testing.T) {
+	d := useTestDatabase(t)
+	jobKinds["test"] = func(ctx context.Context, params json.RawMessage, ip string) error { return nil }
+	defer delete(jobKinds, "test")
+
+	id, err := d.CreateJob("test", "{}", []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first target failed before an interruption; the others still run
+	if err := d.FinishJobTarget(id, "10.0.0.1", db.TargetFailed, "unreachable"); err != nil {
+		t.Fatal(err)
+	}
+
+	job := runQueuedJob(t)
+	if job.Status != db.JobFailed {
+		t.Errorf("job status = %s, want %s for the earlier failure", job.Status, db.JobFailed)
+	}
+	for _, target := range job.Targets[1:] {
+		if target.Status != db.TargetDone {
+			t.Errorf("target %s = %s, want %s", target.IP, target.Status, db.TargetDone)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// layoutHotspotDelta is how far above the median of the placed miners a
+// miner's temperature (°C) makes its slot a hotspot.
+const layoutHotspotDelta = 5.0
+
+// LayoutSlot is an occupied rack slot with the miner's live state.
+type LayoutSlot struct {
+	Row         int     `json:"row"`
+	Slot        int     `json:"slot"`
+	Name        string  `json:"name"`
+	IP          string  `json:"ip"`
+	Status      string  `json:"status"`
+	Online      bool    `json:"online"`
+	Maintenance bool    `json:"maintenance"`
+	Temperature float64 `json:"temperature"` // hottest chip, in the locale's unit
+	Power       float64 `json:"power"`       // W
+	Hashrate    float64 `json:"hashrate"`    // TH/s
+	Heat        float64 `json:"heat"`        // 0 coolest to 1 hottest online miner
+	Hotspot     bool    `json:"hotspot"`
+}
+
+// LayoutRack is one rack as a grid of Rows x Slots; Grid[r][s] is nil for an
+// empty slot.
+type LayoutRack struct {
+	Name  string          `json:"name"`
+	Rows  int             `json:"rows"`
+	Slots int             `json:"slots"`
+	Grid  [][]*LayoutSlot `json:"grid"`
+}
+
+// getLayoutHandler returns the racks with the live temperature, power and
+// status of each placed miner, for a heatmap of the room.
+func getLayoutHandler(c *gin.Context) {
+	loc := localeFor(c)
+	now := requestNow(c)
+
+	statuses, err := storeFor(c).GetMinerStatuses()
+	if err != nil {
+		log.Printf("Failed to get miner statuses from QuestDB: %v", err)
+	}
+
+	racks := map[string]*LayoutRack{}
+	var slots []*LayoutSlot
+	slotRacks := map[*LayoutSlot]*LayoutRack{}
+	var temps []float64 // °C of the online placed miners
+	unplaced := []string{}
+	for _, m := range machinesFor(c) {
+		if m.Rack == "" || m.Row < 1 || m.Slot < 1 {
+			unplaced = append(unplaced, m.Name)
+			continue
+		}
+		r, ok := racks[m.Rack]
+		if !ok {
+			r = &LayoutRack{Name: m.Rack}
+			racks[m.Rack] = r
+		}
+		r.Rows = max(r.Rows, m.Row)
+		r.Slots = max(r.Slots, m.Slot)
+
+		s := &LayoutSlot{Row: m.Row, Slot: m.Slot, Name: m.Name, IP: m.IP, Status: "No Data", Maintenance: m.Maintenance}
+		if statuses != nil {
+			for _, st := range statuses.Miners {
+				if st.MinerIP != m.IP {
+					continue
+				}
+				s.Online = isTimestampRecentAt(st.Timestamp, 2*time.Minute, now)
+				s.Status = st.Status
+				if !s.Online {
+					s.Status = "Offline"
+					break
+				}
+				s.Temperature = st.TemperatureMax
+				s.Power = math.Round(st.Power)
+				s.Hashrate = math.Round(st.Hashrate/100) / 10 // GH/s to TH/s
+				temps = append(temps, st.TemperatureMax)
+				break
+			}
+		}
+		s.Status = loc.T(s.Status)
+		slots = append(slots, s)
+		slotRacks[s] = r
+	}
+
+	// Heat spans the online miners' temperatures; hotspots stand out from
+	// the median so a uniformly warm room has none.
+	if len(temps) > 0 {
+		sort.Float64s(temps)
+		coolest, hottest := temps[0], temps[len(temps)-1]
+		median := temps[len(temps)/2]
+		if len(temps)%2 == 0 {
+			median = (temps[len(temps)/2-1] + median) / 2
+		}
+		for _, s := range slots {
+			if !s.Online {
+				continue
+			}
+			if hottest > coolest {
+				s.Heat = math.Round((s.Temperature-coolest)/(hottest-coolest)*100) / 100
+			}
+			s.Hotspot = s.Temperature >= median+layoutHotspotDelta
+		}
+	}
+
+	for _, r := range racks {
+		r.Grid = make([][]*LayoutSlot, r.Rows)
+		for i := range r.Grid {
+			r.Grid[i] = make([]*LayoutSlot, r.Slots)
+		}
+	}
+	hotspots := []string{}
+	for _, s := range slots {
+		r := slotRacks[s]
+		// A slot shared by two machines keeps the first by name
+		if r.Grid[s.Row-1][s.Slot-1] == nil {
+			r.Grid[s.Row-1][s.Slot-1] = s
+		}
+		if s.Hotspot {
+			hotspots = append(hotspots, s.Name)
+		}
+		s.Temperature = loc.Temp(math.Round(s.Temperature*10) / 10)
+	}
+
+	list := make([]*LayoutRack, 0, len(racks))
+	for _, r := range racks {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"racks":     list,
+		"unplaced":  unplaced,
+		"hotspots":  hotspots,
+		"tempUnit":  loc.TempUnit(),
+		"updatedAt": now.UTC().Format(time.RFC3339),
+	})
+}
+
+type MachinePositionRequest struct {
+	Rack string `json:"rack"` // empty unplaces the machine
+	Row  int    `json:"row"`
+	Slot int    `json:"slot"`
+}
+
+// setMachinePositionHandler places a machine in a rack slot.
+func setMachinePositionHandler(c *gin.Context) {
+	ip := ipParam(c)
+	var req MachinePositionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Rack = strings.TrimSpace(req.Rack)
+	if req.Rack == "" {
+		req.Row, req.Slot = 0, 0
+	} else if req.Row < 1 || req.Slot < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "row and slot must be at least 1"})
+		return
+	}
+	for _, m := range currentMachines() {
+		if req.Rack != "" && m.IP != ip && m.Rack == req.Rack && m.Row == req.Row && m.Slot == req.Slot {
+			c.JSON(http.StatusConflict, gin.H{"error": "slot is taken by " + m.Name})
+			return
+		}
+	}
+
+	if err := database.SetMachinePosition(ip, req.Rack, req.Row, req.Slot); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update machine"})
+		return
+	}
+	refreshMachines()
+
+	log.Printf("Set position of machine %s to %q row %d slot %d", ip, req.Rack, req.Row, req.Slot)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"ip":      ip,
+		"rack":    req.Rack,
+		"row":     req.Row,
+		"slot":    req.Slot,
+	})
+}
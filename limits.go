@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limit modes: what happens to a request outside a miner's model profile.
+const (
+	limitRefuse = "refuse"
+	limitClamp  = "clamp"
+)
+
+var limitMode string // --limit-mode
+
+// operatingLimits are the model profiles by lower-cased model.
+type operatingLimits map[string]db.ModelProfile
+
+func loadLimits() (operatingLimits, error) {
+	profiles, err := database.FetchModelProfiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load model profiles: %w", err)
+	}
+	return limitsOf(profiles), nil
+}
+
+func limitsOf(profiles []db.ModelProfile) operatingLimits {
+	limits := make(operatingLimits, len(profiles))
+	for _, p := range profiles {
+		limits[strings.ToLower(p.Model)] = p
+	}
+	return limits
+}
+
+// profile returns the profile of a miner's model, or nil when the model is
+// unknown or has none.
+func (l operatingLimits) profile(ip string) *db.ModelProfile {
+	for _, m := range currentMachines() {
+		if m.IP != ip || m.Model == "" {
+			continue
+		}
+		if p, ok := l[strings.ToLower(strings.TrimSpace(m.Model))]; ok {
+			return &p
+		}
+	}
+	return nil
+}
+
+// bound clamps v to [min, max], a zero bound being open, and describes the
+// violation if it had to.
+func bound(name, unit string, v, min, max float64) (float64, string) {
+	switch {
+	case min > 0 && v < min:
+		return min, fmt.Sprintf("%s %g %s is below %g %s", name, v, unit, min, unit)
+	case max > 0 && v > max:
+		return max, fmt.Sprintf("%s %g %s is above %g %s", name, v, unit, max, unit)
+	}
+	return v, ""
+}
+
+// freqVolt returns freq/volt clamped to the miner's profile and the
+// violations, empty within limits.
+func (l operatingLimits) freqVolt(ip string, freq, volt float64) (float64, float64, string) {
+	p := l.profile(ip)
+	if p == nil {
+		return freq, volt, ""
+	}
+	f, freqViolation := bound("freq", "MHz", freq, p.MinFreq, p.MaxFreq)
+	v, voltViolation := bound("volt", "V", volt, p.MinVolt, p.MaxVolt)
+	var violations []string
+	for _, s := range []string{freqViolation, voltViolation} {
+		if s != "" {
+			violations = append(violations, s)
+		}
+	}
+	if len(violations) == 0 {
+		return freq, volt, ""
+	}
+	return f, v, fmt.Sprintf("%s (%s limits)", strings.Join(violations, ", "), p.Model)
+}
+
+// power returns a power target clamped to the miner's profile and the
+// violation, empty within limits.
+func (l operatingLimits) power(ip string, power int) (int, string) {
+	p := l.profile(ip)
+	if p == nil {
+		return power, ""
+	}
+	w, violation := bound("power", "W", float64(power), float64(p.MinPower), float64(p.MaxPower))
+	if violation == "" {
+		return power, ""
+	}
+	return int(w), fmt.Sprintf("%s (%s limits)", violation, p.Model)
+}
+
+// enforceLimits refuses a violation, or logs it when limits clamp.
+func enforceLimits(ip, violation string) error {
+	if violation == "" {
+		return nil
+	}
+	if limitMode == limitClamp {
+		log.Printf("Clamped settings for miner at %s: %s", ip, violation)
+		return nil
+	}
+	return fmt.Errorf("outside safe operating limits: %s", violation)
+}
+
+// guardFreqVolt returns the freq/volt that may be applied to a miner, or an
+// error when its profile refuses them.
+func guardFreqVolt(ip string, freq, volt float64) (float64, float64, error) {
+	limits, err := loadLimits()
+	if err != nil {
+		return 0, 0, err
+	}
+	f, v, violation := limits.freqVolt(ip, freq, volt)
+	if err := enforceLimits(ip, violation); err != nil {
+		return 0, 0, err
+	}
+	return f, v, nil
+}
+
+// guardPower returns the power target that may be applied to a miner, or an
+// error when its profile refuses it.
+func guardPower(ip string, power int) (int, error) {
+	limits, err := loadLimits()
+	if err != nil {
+		return 0, err
+	}
+	w, violation := limits.power(ip, power)
+	if err := enforceLimits(ip, violation); err != nil {
+		return 0, err
+	}
+	return w, nil
+}
+
+// checkLimits checks a bulk request against the targeted miners' profiles
+// before it is queued. Outside limits it writes a 400 listing the miners when
+// limits refuse; dry runs and clamping go on, with the violations returned as
+// warnings. ok is false when a response was written.
+func checkLimits(c *gin.Context, ips []string, dryRun bool, check func(l operatingLimits, ip string) string) (warnings []string, ok bool) {
+	limits, err := loadLimits()
+	if err != nil {
+		log.Printf("Failed to check operating limits: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load model profiles"})
+		return nil, false
+	}
+
+	var violations []string
+	for _, ip := range ips {
+		if v := check(limits, ip); v != "" {
+			violations = append(violations, fmt.Sprintf("%s: %s", minerName(ip), v))
+		}
+	}
+	if len(violations) == 0 {
+		return nil, true
+	}
+	if limitMode == limitClamp {
+		for _, v := range violations {
+			warnings = append(warnings, v+" (will be clamped)")
+		}
+		return warnings, true
+	}
+	if dryRun {
+		for _, v := range violations {
+			warnings = append(warnings, v+" (will be refused)")
+		}
+		return warnings, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":      "outside safe operating limits",
+		"violations": violations,
+	})
+	return nil, false
+}
+
+// ModelProfileRequest saves the limits of a miner model.
+type ModelProfileRequest struct {
+	Model    string  `json:"model" binding:"required"`
+	MinFreq  float64 `json:"minFreq"`
+	MaxFreq  float64 `json:"maxFreq"`
+	MinVolt  float64 `json:"minVolt"`
+	MaxVolt  float64 `json:"maxVolt"`
+	MinPower int     `json:"minPower"`
+	MaxPower int     `json:"maxPower"`
+}
+
+// getModelProfilesHandler lists the profiles and the machines whose model has
+// none (or is not known yet), which are not limited.
+func getModelProfilesHandler(c *gin.Context) {
+	profiles, err := database.FetchModelProfiles()
+	if err != nil {
+		log.Printf("Failed to fetch model profiles: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch model profiles"})
+		return
+	}
+	if profiles == nil {
+		profiles = []db.ModelProfile{}
+	}
+
+	limits := limitsOf(profiles)
+	unlimited := []gin.H{}
+	for _, m := range currentMachines() {
+		if limits.profile(m.IP) == nil {
+			unlimited = append(unlimited, gin.H{"name": m.Name, "ip": m.IP, "model": m.Model})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mode":      limitMode,
+		"profiles":  profiles,
+		"unlimited": unlimited,
+	})
+}
+
+func saveModelProfileHandler(c *gin.Context) {
+	var req ModelProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch {
+	case req.MinFreq < 0 || req.MaxFreq < 0 || req.MinVolt < 0 || req.MaxVolt < 0 || req.MinPower < 0 || req.MaxPower < 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limits must not be negative"})
+		return
+	case req.MaxFreq > 0 && req.MinFreq > req.MaxFreq,
+		req.MaxVolt > 0 && req.MinVolt > req.MaxVolt,
+		req.MaxPower > 0 && req.MinPower > req.MaxPower:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a minimum is above its maximum"})
+		return
+	}
+
+	p := db.ModelProfile{
+		Model:    strings.TrimSpace(req.Model),
+		MinFreq:  req.MinFreq,
+		MaxFreq:  req.MaxFreq,
+		MinVolt:  req.MinVolt,
+		MaxVolt:  req.MaxVolt,
+		MinPower: req.MinPower,
+		MaxPower: req.MaxPower,
+	}
+	if err := database.SaveModelProfile(p); err != nil {
+		log.Printf("Failed to save model profile %s: %v", p.Model, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save model profile"})
+		return
+	}
+
+	recordEvent("limits", "operating limits of %s set from %s", p.Model, c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"profile": p,
+	})
+}
+
+func deleteModelProfileHandler(c *gin.Context) {
+	model := c.Param("model")
+	found, err := database.DeleteModelProfile(model)
+	if err != nil {
+		log.Printf("Failed to delete model profile %s: %v", model, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete model profile"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "model profile not found"})
+		return
+	}
+
+	recordEvent("limits", "operating limits of %s removed from %s", model, c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"model":   model,
+	})
+}
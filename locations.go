@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"miningRoom/db"
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// locationPollSeconds is the interval of the location staleness check (0
+// disables it).
+var locationPollSeconds int
+
+// defaultSensorLocations are used while no locations are configured: the
+// room sensor and the outside sensor of the original setup.
+var defaultSensorLocations = []db.SensorLocation{
+	{Name: "miningroom", Label: "Mining Room"},
+	{Name: "outside", Label: "Outside", Outdoor: true},
+}
+
+// configuredLocations returns the configured locations, or the defaults.
+func configuredLocations() []db.SensorLocation {
+	locations, err := database.FetchSensorLocations()
+	if err != nil {
+		log.Printf("Failed to fetch sensor locations: %v", err)
+		return defaultSensorLocations
+	}
+	if len(locations) == 0 {
+		return defaultSensorLocations
+	}
+	return locations
+}
+
+// indoorLocations and outdoorLocations return the location names on either
+// side of the room's walls, without the suspect ones.
+func indoorLocations() []string  { return locationNames(false) }
+func outdoorLocations() []string { return locationNames(true) }
+
+// airflowLocations returns the location names marked as the air intake or
+// exhaust, without the suspect ones.
+func airflowLocations(role string) []string {
+	var names []string
+	for _, l := range configuredLocations() {
+		if l.Airflow == role && !sensorAnomalies.suspect(l.Name) {
+			names = append(names, l.Name)
+		}
+	}
+	return names
+}
+
+func locationNames(outdoor bool) []string {
+	var names []string
+	for _, l := range configuredLocations() {
+		if l.Outdoor == outdoor && !sensorAnomalies.suspect(l.Name) {
+			names = append(names, l.Name)
+		}
+	}
+	return names
+}
+
+// locationLabels maps location names to their display labels, for grouping
+// charts.
+func locationLabels() map[string]string {
+	labels := make(map[string]string)
+	for _, l := range configuredLocations() {
+		labels[l.Name] = locationLabel(l)
+	}
+	return labels
+}
+
+func locationLabel(l db.SensorLocation) string {
+	if l.Label != "" {
+		return l.Label
+	}
+	return l.Name
+}
+
+// SensorLocationInfo is a location with the time of its latest reading.
+type SensorLocationInfo struct {
+	db.SensorLocation
+	LastReading string         `json:"lastReading,omitempty"`
+	Stale       bool           `json:"stale"`
+	Suspect     *SensorSuspect `json:"suspect,omitempty"` // stuck or implausible readings
+}
+
+// locationMonitor raises an alert for each location that stopped reporting
+// within its expected interval.
+type locationMonitor struct {
+	mu     sync.Mutex
+	raised map[string]bool // alert keys, so removed locations get cleared
+}
+
+var locationAlerts = &locationMonitor{raised: make(map[string]bool)}
+
+// runLocationMonitor checks the locations at the given interval, for stale
+// and for suspect readings.
+func runLocationMonitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		checkSensorAnomalies(time.Now())
+		env, err := questdbClient.GetLatestEnvironmentTemperatures()
+		if err != nil {
+			log.Printf("Location monitor: failed to get environment readings: %v", err)
+			continue
+		}
+		locationAlerts.check(configuredLocations(), env, time.Now())
+	}
+}
+
+// locationStale reports whether a location with a report interval has no
+// reading within twice that interval, allowing for one missed report.
+func locationStale(l db.SensorLocation, reading *questdb.LatestEnvironmentReading, now time.Time) bool {
+	if l.ReportIntervalSeconds <= 0 {
+		return false
+	}
+	return reading == nil || !isTimestampRecentAt(reading.Timestamp, 2*time.Duration(l.ReportIntervalSeconds)*time.Second, now)
+}
+
+func (m *locationMonitor) check(locations []db.SensorLocation, env *questdb.LatestEnvironmentData, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[string]bool)
+	for _, l := range locations {
+		key := "location:" + l.Name
+		seen[key] = true
+		reading := findEnvironmentReading(env, l.Name)
+		if !locationStale(l, reading, now) {
+			alerts.clear(key)
+			delete(m.raised, key)
+			continue
+		}
+		msg := fmt.Sprintf("Sensor location %s has no readings", locationLabel(l))
+		if reading != nil {
+			msg = fmt.Sprintf("Sensor location %s stopped reporting: last reading at %s (expected every %ds)",
+				locationLabel(l), reading.Timestamp, l.ReportIntervalSeconds)
+		}
+		alerts.raise(key, severityWarning, "locations", msg)
+		m.raised[key] = true
+	}
+	for key := range m.raised {
+		if !seen[key] {
+			alerts.clear(key)
+			delete(m.raised, key)
+		}
+	}
+}
+
+// getSensorLocationsHandler lists the locations with their latest reading.
+// Custom is false while the defaults are shown.
+func getSensorLocationsHandler(c *gin.Context) {
+	stored, err := database.FetchSensorLocations()
+	if err != nil {
+		log.Printf("Failed to fetch sensor locations: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sensor locations"})
+		return
+	}
+	locations := stored
+	if len(locations) == 0 {
+		locations = defaultSensorLocations
+	}
+
+	env, err := questdbFor(c).GetLatestEnvironmentTemperatures()
+	if err != nil {
+		log.Printf("Failed to get environment readings from QuestDB: %v", err)
+		env = &questdb.LatestEnvironmentData{}
+	}
+	now := requestNow(c)
+	results := make([]SensorLocationInfo, len(locations))
+	for i, l := range locations {
+		results[i] = SensorLocationInfo{SensorLocation: l}
+		reading := findEnvironmentReading(env, l.Name)
+		if reading != nil {
+			results[i].LastReading = reading.Timestamp
+		}
+		results[i].Stale = err == nil && locationStale(l, reading, now)
+		if !replaying(c) {
+			results[i].Suspect = sensorAnomalies.get(l.Name)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"locations": results,
+		"custom":    len(stored) > 0,
+	})
+}
+
+type SensorLocationRequest struct {
+	Name                  string `json:"name" binding:"required"`
+	Label                 string `json:"label"`
+	Outdoor               bool   `json:"outdoor"`
+	ReportIntervalSeconds int    `json:"reportIntervalSeconds"`
+	Airflow               string `json:"airflow"` // "intake", "exhaust" or empty
+}
+
+// saveSensorLocationHandler creates or updates a location. The first save
+// stores the defaults along with it, so adding a location keeps the room and
+// outside sensors.
+func saveSensorLocationHandler(c *gin.Context) {
+	var req SensorLocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ReportIntervalSeconds < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reportIntervalSeconds must not be negative"})
+		return
+	}
+	if req.Airflow != "" && req.Airflow != airflowIntake && req.Airflow != airflowExhaust {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "airflow must be intake, exhaust or empty"})
+		return
+	}
+
+	stored, err := database.FetchSensorLocations()
+	if err != nil {
+		log.Printf("Failed to fetch sensor locations: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save sensor location"})
+		return
+	}
+	if len(stored) == 0 {
+		for _, l := range defaultSensorLocations {
+			if err := database.SaveSensorLocation(l); err != nil {
+				log.Printf("Failed to save default sensor location %s: %v", l.Name, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save sensor location"})
+				return
+			}
+		}
+	}
+
+	location := db.SensorLocation{
+		Name:                  req.Name,
+		Label:                 req.Label,
+		Outdoor:               req.Outdoor,
+		ReportIntervalSeconds: req.ReportIntervalSeconds,
+		Airflow:               req.Airflow,
+	}
+	if err := database.SaveSensorLocation(location); err != nil {
+		log.Printf("Failed to save sensor location %s: %v", req.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save sensor location"})
+		return
+	}
+
+	log.Printf("Saved sensor location %s (outdoor %v, interval %ds)", req.Name, req.Outdoor, req.ReportIntervalSeconds)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"name":    req.Name,
+	})
+}
+
+func deleteSensorLocationHandler(c *gin.Context) {
+	name := c.Param("name")
+	if err := database.DeleteSensorLocation(name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete sensor location"})
+		return
+	}
+
+	log.Printf("Deleted sensor location %s", name)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"name":    name,
+	})
+}
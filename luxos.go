@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+)
+
+// luxosDriver controls Antminers running LuxOS through its cgminer-compatible
+// API. Power is set by switching to the closest profile within the target;
+// write commands need a session from logon.
+type luxosDriver struct{}
+
+// luxosSession runs fn with a LuxOS API session and logs off afterwards.
+func luxosSession(ctx context.Context, ip string, fn func(session string) error) error {
+	resp, err := cgminerCommand(ctx, ip, "logon", "")
+	if err != nil {
+		return err
+	}
+	s, ok := firstObject(resp, "SESSION")
+	if !ok {
+		return errors.New("logon returned no session")
+	}
+	session, _ := s["SessionID"].(string)
+	if session == "" {
+		return errors.New("logon returned no session")
+	}
+	defer func() {
+		if _, err := cgminerCommand(ctx, ip, "logoff", session); err != nil {
+			log.Printf("LuxOS: failed to log off %s: %v", ip, err)
+		}
+	}()
+	return fn(session)
+}
+
+// luxosProfiles lists the power profiles a LuxOS miner offers.
+func luxosProfiles(ctx context.Context, ip string) ([]powerProfile, error) {
+	resp, err := cgminerCommand(ctx, ip, "profiles", "")
+	if err != nil {
+		return nil, err
+	}
+	var profiles []powerProfile
+	for _, p := range cgminerList(resp, "PROFILES") {
+		name, _ := p["Profile Name"].(string)
+		if name == "" {
+			continue
+		}
+		profiles = append(profiles, powerProfile{Name: name, Watts: numberField(p, "Watts")})
+	}
+	return profiles, nil
+}
+
+func (luxosDriver) Config(ctx context.Context, ip string) (*MinerManageInfo, error) {
+	resp, err := cgminerCommand(ctx, ip, "config", "")
+	if err != nil {
+		return nil, err
+	}
+
+	info := &MinerManageInfo{
+		Online:              true,
+		WorkMode:            "Auto",
+		ModeSelect:          "PowerTarget",
+		ModeSelectAvailable: []string{"PowerTarget"},
+	}
+	if config, ok := firstObject(resp, "CONFIG"); ok {
+		info.Profile, _ = config["Profile"].(string)
+		if sleeping, _ := config["IsSleeping"].(bool); sleeping {
+			info.WorkMode = "Sleep"
+		}
+	}
+	if profiles, err := luxosProfiles(ctx, ip); err == nil {
+		info.TargetValue = profileWatts(profiles, info.Profile)
+	}
+	if chains, err := cgminerChains(ctx, ip); err == nil {
+		info.Chains = chains
+	}
+	return info, nil
+}
+
+func (luxosDriver) SetPowerTarget(ctx context.Context, ip string, power int) error {
+	profiles, err := luxosProfiles(ctx, ip)
+	if err != nil {
+		return err
+	}
+	profile, err := pickProfile(profiles, power)
+	if err != nil {
+		return err
+	}
+
+	return luxosSession(ctx, ip, func(session string) error {
+		if _, err := cgminerCommand(ctx, ip, "profileset", session+","+profile.Name); err != nil {
+			return err
+		}
+		// Wake the miner in case it was put to sleep; already awake is not an error here
+		if _, err := cgminerCommand(ctx, ip, "curtail", session+",wakeup"); err != nil {
+			log.Printf("LuxOS: wakeup of %s after profile change: %v", ip, err)
+		}
+		log.Printf("LuxOS: set profile %s (%.0f W) on %s for target %d W", profile.Name, profile.Watts, ip, power)
+		return nil
+	})
+}
+
+func (luxosDriver) SetFreqVolt(ctx context.Context, ip string, freq, volt float64) error {
+	return errors.New("fixed frequency/voltage is not supported on LuxOS; use a power target")
+}
+
+func (luxosDriver) SetSleep(ctx context.Context, ip string) error {
+	return luxosSession(ctx, ip, func(session string) error {
+		_, err := cgminerCommand(ctx, ip, "curtail", session+",sleep")
+		return err
+	})
+}
+
+// RestartMining restarts luxminer through the API.
+func (luxosDriver) RestartMining(ctx context.Context, ip string) error {
+	return luxosSession(ctx, ip, func(session string) error {
+		_, err := cgminerCommand(ctx, ip, "resetminer", session)
+		return err
+	})
+}
+
+func (d luxosDriver) Reboot(ctx context.Context, ip string) error {
+	return runDriverCommand(ctx, ip, d, "reboot", nil)
+}
+
+func (luxosDriver) Pools(ctx context.Context, ip string) ([]PoolInfo, error) {
+	return cgminerPools(ctx, ip)
+}
+
+func (luxosDriver) SSHCommands() map[string]sshCommand {
+	return map[string]sshCommand{
+		"reboot": {Command: "reboot", Description: "Reboot the control board", Destructive: true},
+		"logs":   {Command: "tail -n 200 /var/log/luxminer/luxminer.log 2>/dev/null || dmesg | tail -n 200", Description: "Last 200 lines of the LuxOS miner log"},
+	}
+}
+
+func (luxosDriver) Identify(ctx context.Context, ip string) (*MinerIdentity, error) {
+	return cgminerIdentity(ctx, ip)
+}
+
+func (luxosDriver) Capabilities() MinerCapabilities {
+	return MinerCapabilities{SupportsPowerTarget: true, SupportsSleep: true, SupportsReboot: true, SupportsRestart: true, SupportsPools: true}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log"
+	"sync"
+
+	"miningRoom/db"
+)
+
+// The machine list is read by handlers and background loops alike; it is
+// only ever replaced as a whole, so a snapshot from currentMachines stays
+// valid and must not be modified.
+var (
+	machinesMu     sync.RWMutex
+	loadedMachines []db.Machine
+)
+
+// currentMachines returns the current machine list.
+func currentMachines() []db.Machine {
+	machinesMu.RLock()
+	defer machinesMu.RUnlock()
+	return loadedMachines
+}
+
+// setMachines replaces the machine list.
+func setMachines(ms []db.Machine) {
+	machinesMu.Lock()
+	defer machinesMu.Unlock()
+	loadedMachines = ms
+}
+
+// refreshMachines reloads the machine list after a change.
+func refreshMachines() {
+	updated, err := database.FetchMachines()
+	if err != nil {
+		log.Printf("Failed to refresh machines: %v", err)
+		return
+	}
+	setMachines(updated)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"mime"
+	"net/smtp"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SMTP settings for outgoing email (reports, notifications).
+var (
+	smtpHost string // empty disables email
+	smtpPort int
+	smtpUser string
+	smtpPass string
+	smtpFrom string
+)
+
+// mailEnabled reports whether SMTP is configured.
+func mailEnabled() bool {
+	return smtpHost != "" && smtpFrom != ""
+}
+
+// sendMail sends an HTML email. STARTTLS is used when the server offers it.
+func sendMail(to []string, subject, html string) error {
+	return deliverMail(to, subject, html, false)
+}
+
+// sendUrgentMail sends an HTML email flagged as high priority.
+func sendUrgentMail(to []string, subject, html string) error {
+	return deliverMail(to, subject, html, true)
+}
+
+func deliverMail(to []string, subject, html string, urgent bool) error {
+	if !mailEnabled() {
+		return errors.New("SMTP is not configured")
+	}
+	if len(to) == 0 {
+		return errors.New("no recipients")
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", smtpFrom)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	if urgent {
+		msg.WriteString("X-Priority: 1\r\nImportance: high\r\n")
+	}
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=utf-8\r\n\r\n")
+	msg.WriteString(html)
+
+	var auth smtp.Auth
+	if smtpUser != "" {
+		auth = smtp.PlainAuth("", smtpUser, smtpPass, smtpHost)
+	}
+	addr := fmt.Sprintf("%s:%d", smtpHost, smtpPort)
+	if err := smtp.SendMail(addr, auth, smtpFrom, to, msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send mail via %s: %w", addr, err)
+	}
+	return nil
+}
+
+// renderTemplate renders a template from templates/ to a string, for content
+// that is sent outside of an HTTP response (e.g. email bodies).
+func renderTemplate(name string, data interface{}) (string, error) {
+	tmpl, err := template.New(name).Funcs(template.FuncMap{"T": translate}).ParseFiles(filepath.Join("templates", name))
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
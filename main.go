@@ -2,12 +2,15 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"html/template"
 	"io"
 	"log"
 	"math"
@@ -19,24 +22,25 @@ import (
 	"time"
 
 	"miningRoom/db"
+	"miningRoom/influxdb"
 	"miningRoom/questdb"
 
 	"github.com/gin-gonic/gin"
 )
 
 var (
-	machines      []db.Machine
 	database      *db.DB
 	questdbClient *questdb.Client
 	minerUser     string
 	minerPass     string
-	innerNetwork  *net.IPNet
+	innerNetworks []*net.IPNet
+	elecPrice     float64
 )
 
 // isInnerNetwork returns true if network filtering is disabled or the client IP
-// is on the inner network or localhost.
+// is on one of the inner networks or localhost.
 func isInnerNetwork(clientIP string) bool {
-	if innerNetwork == nil {
+	if len(innerNetworks) == 0 {
 		return true
 	}
 	ip := net.ParseIP(clientIP)
@@ -46,7 +50,12 @@ func isInnerNetwork(clientIP string) bool {
 	if ip.IsLoopback() {
 		return true
 	}
-	return innerNetwork.Contains(ip)
+	for _, network := range innerNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
 }
 
 // networkContextMiddleware sets ShowManage in the gin context based on client IP.
@@ -57,10 +66,11 @@ func networkContextMiddleware() gin.HandlerFunc {
 	}
 }
 
-// requireInnerNetwork returns 404 for clients not on the inner network.
+// requireInnerNetwork returns 404 for clients not on the inner network and
+// for logged in owners.
 func requireInnerNetwork() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !isInnerNetwork(c.ClientIP()) {
+		if !isInnerNetwork(c.ClientIP()) || ownerFor(c) != "" {
 			render404(c)
 			return
 		}
@@ -77,6 +87,7 @@ func render404(c *gin.Context) {
 	c.HTML(http.StatusNotFound, "404.html", gin.H{
 		"Title":      "Mining Dashboard",
 		"ShowManage": c.GetBool("ShowManage"),
+		"Lang":       localeFor(c).Lang,
 	})
 	c.Abort()
 }
@@ -85,24 +96,286 @@ func main() {
 	dbPath := flag.String("db-path", "miningroom.db", "SQLite database path")
 	questdbHost := flag.String("questdb-host", "localhost", "QuestDB host for metrics")
 	questdbPort := flag.Int("questdb-port", 9001, "QuestDB port")
-	innerNet := flag.String("inner-network", "", "CIDR of the inner network that may access manage/settings (e.g. 10.0.0.0/24). If empty, all clients have full access")
+	tsdb := flag.String("tsdb", tsdbQuestDB, "Timeseries database of the live dashboard and the metric writers: questdb or influxdb (reports, billing and history still need QuestDB)")
+	influxURL := flag.String("influx-url", "http://localhost:8086", "InfluxDB 2.x URL, with --tsdb influxdb")
+	influxOrg := flag.String("influx-org", "", "InfluxDB organization, with --tsdb influxdb")
+	influxBucket := flag.String("influx-bucket", "miningroom", "InfluxDB bucket of the metrics, with --tsdb influxdb")
+	influxToken := flag.String("influx-token", "", "InfluxDB API token with read and write access to the bucket")
+	questdbSecondaryHost := flag.String("questdb-secondary-host", "", "Host of a replica or backup QuestDB that serves reads while the primary is down (empty disables failover)")
+	questdbSecondaryPort := flag.Int("questdb-secondary-port", 9001, "Port of the secondary QuestDB")
+	questdbCheckSeconds := flag.Int("questdb-check-seconds", 10, "Seconds between health checks of the primary QuestDB when a secondary is configured")
+	innerNet := flag.String("inner-network", "", "Comma separated IPv4/IPv6 CIDRs of the inner networks that may access manage/settings (e.g. 10.0.0.0/24,fd00::/64). If empty, all clients have full access")
 	flag.StringVar(&minerUser, "miner-user", "root", "Miner HTTP digest auth username")
 	flag.StringVar(&minerPass, "miner-pass", "root", "Miner HTTP digest auth password")
+	flag.Float64Var(&elecPrice, "elec-price", 0.23, "Electricity price in EUR/kWh")
+	flag.StringVar(&payoutAddresses, "payout-address", "", "Comma-separated BTC payout addresses watched on mempool.space; payments are announced and recorded in QuestDB (empty disables)")
+	flag.IntVar(&payoutPollSeconds, "payout-poll", 300, "Seconds between checks of the payout addresses")
+	flag.StringVar(&summaryWebhookURL, "summary-webhook", "", "URL the daily summary is POSTed to from 8:00 every day, e.g. a Nostr or Lightning bridge (empty disables)")
+	flag.StringVar(&summaryTemplatePath, "summary-template", "", "text/template file rendering the summary webhook body (default: the summary as JSON)")
+	flag.StringVar(&summaryContentType, "summary-content-type", "application/json", "Content-Type of the summary webhook body")
+	flag.StringVar(&summaryWebhookHeader, "summary-webhook-header", "", "Extra header of the summary webhook, e.g. \"Authorization: Bearer <token>\"")
+	flag.IntVar(&btcPricePollMinutes, "btc-price-poll", 15, "Minutes between BTC prices stored in QuestDB for valuing past periods (0 disables)")
+	flag.StringVar(&backfillBTCPrices, "backfill-btc-prices", "", "Import daily BTC prices since this date (YYYY-MM-DD) into QuestDB and exit")
+	flag.StringVar(&elecTariff, "elec-tariff", "", "Time-of-use electricity prices for the cost-today gauges, e.g. 22-6=0.15,6-22=0.25 (hours in --timezone; others use --elec-price)")
+	flag.StringVar(&forcedLang, "locale", "", "UI language (en, de, sl). If empty, negotiated from Accept-Language")
+	flag.BoolVar(&imperial, "imperial", false, "Show temperatures in °F")
+	flag.Float64Var(&dewPointMargin, "dewpoint-margin", 2.0, "Minimum air temperature to dew point spread (°C) before miner cold starts are paused")
+	flag.IntVar(&rawRetentionDays, "raw-retention-days", 0, "Drop raw QuestDB partitions older than this many days once rolled up (0 keeps raw data forever)")
+	flag.StringVar(&contentSecurityPolicy, "csp", defaultCSP, "Content-Security-Policy header value (empty to disable)")
+	flag.StringVar(&frameOptions, "frame-options", "DENY", "X-Frame-Options header value, e.g. DENY or SAMEORIGIN (empty to disable)")
+	flag.StringVar(&smtpHost, "smtp-host", "", "SMTP server for report emails (empty disables email)")
+	flag.IntVar(&smtpPort, "smtp-port", 587, "SMTP server port")
+	flag.StringVar(&smtpUser, "smtp-user", "", "SMTP username (empty for no auth)")
+	flag.StringVar(&smtpPass, "smtp-pass", "", "SMTP password")
+	flag.StringVar(&smtpFrom, "smtp-from", "", "Sender address for report emails")
+	flag.StringVar(&reportRecipients, "report-to", "", "Comma-separated report email recipients")
+	flag.StringVar(&reportSchedule, "report-schedule", "", "Comma-separated scheduled reports to email: weekly, monthly (empty disables)")
+	flag.Float64Var(&forecastLatitude, "latitude", 0, "Latitude for the weather forecast (0 with --longitude 0 disables forecast planning)")
+	flag.Float64Var(&forecastLongitude, "longitude", 0, "Longitude for the weather forecast")
+	flag.IntVar(&forecastHorizon, "forecast-horizon", 6, "Hours of forecast considered when planning power targets")
+	flag.Float64Var(&precoolTemp, "precool-temp", 28, "Forecast outdoor temperature (°C) at or above which power targets are reduced")
+	flag.Float64Var(&precoolReduction, "precool-reduction", 20, "Power target reduction (%) while pre-cooling (0 disables)")
+	flag.Float64Var(&precoolRoomTemp, "precool-room-temp", 0, "Room temperature (°C) held while pre-cooling by regulating power targets between full power and --precool-reduction (0 applies the full reduction)")
+	flag.Float64Var(&preheatTemp, "preheat-temp", 5, "Forecast outdoor temperature (°C) at or below which power targets are raised")
+	flag.Float64Var(&preheatBoost, "preheat-boost", 0, "Power target increase (%) while pre-heating, for rooms that heat the house (0 disables)")
+	flag.StringVar(&vnishPass, "vnish-pass", "admin", "Web password of miners running Vnish firmware")
+	flag.StringVar(&iceriverPass, "iceriver-pass", "12345678", "Web password of Iceriver miners")
+	flag.IntVar(&shellyPollSeconds, "shelly-poll", 30, "Seconds between background polls of miner Shelly relays")
+	flag.IntVar(&macResolveMinutes, "mac-resolve-minutes", 5, "Minutes between checks that follow miners to a new IP by MAC address (0 disables)")
+	flag.IntVar(&resolveMinutes, "resolve-minutes", 5, "Minutes between re-resolutions of machines and Shellies registered by hostname (0 disables)")
+	flag.StringVar(&arpSubnets, "arp-subnets", "", "Comma-separated IPv4 subnets (at most /22) swept for miners that changed IP (default: the /24 of each miner)")
+	flag.IntVar(&presencePollSeconds, "presence-poll", 60, "Seconds between ARP presence checks telling powered-off miners from hung firmware (0 disables)")
+	flag.StringVar(&roomMeterURL, "room-meter", "", "Whole-room 3-phase meter as shellyem://<host> or modbus://<host>[:port]/<unit>?profile=sdm630 (empty disables polling)")
+	flag.IntVar(&roomMeterPollSeconds, "room-meter-poll", 10, "Seconds between room meter polls")
+	flag.Float64Var(&unmeteredWatts, "unmetered-watts", 300, "Watts the room meter may read above the sum of the miner plugs before an alert is raised (0 disables)")
+	flag.Float64Var(&idleWatts, "idle-watts", 20, "Outlet power (W) above which a miner without hashrate counts as wasting idle power")
+	flag.IntVar(&idleMinutes, "idle-minutes", 15, "Minutes a miner must draw idle power before the period counts as waste")
+	flag.IntVar(&idleCutMinutes, "idle-cut-minutes", 0, "Minutes of idle power after which the miner's outlet is switched off (0 disables)")
+	flag.IntVar(&unreachableMinutes, "unreachable-minutes", 10, "Minutes a miner may be unreachable with its relay on before an alert is raised")
+	flag.StringVar(&ingestTokens, "ingest-tokens", "", "Comma-separated tokens accepted by /api/ingest/lineprotocol and /api/ingest/json (empty disables them)")
+	flag.StringVar(&ingestMeasurements, "ingest-measurements", "bme280_readings,coolant_temperatures,coolant_flow,sound_levels,smoke_alarms,room_meter,heat_recovery", "Comma-separated QuestDB tables pushed measurements may be written to")
+	flag.Float64Var(&thermalMaxCell, "thermal-max-cell", 60, "Hottest cell in °C of a pushed thermal camera frame that raises an alert (0 disables)")
+	flag.Float64Var(&exhaustAirflow, "exhaust-airflow", 0, "Rated airflow of the exhaust fan in m³/h, for the heat removed by the air (0: estimate the airflow from power instead)")
+	flag.StringVar(&mqttBroker, "mqtt-broker", "", "MQTT broker (host:port) for bridged BLE/Zigbee sensors (empty disables the sensor bridge)")
+	flag.StringVar(&mqttUser, "mqtt-user", "", "MQTT username")
+	flag.StringVar(&mqttPass, "mqtt-pass", "", "MQTT password")
+	flag.StringVar(&sensorTopics, "sensor-topics", "zigbee2mqtt/+,home/+/BTtoMQTT/+", "Comma-separated MQTT topic filters of bridged thermometers")
+	flag.StringVar(&sensorLocations, "sensor-locations", "", "Comma-separated device=location pairs for bridged thermometers, e.g. A4:C1:38:00:11:22=attic (unmapped devices use their ID)")
+	flag.IntVar(&doorOpenMinutes, "door-open-minutes", 10, "Minutes a door/window may stay open while miners run at full power before an alert is raised")
+	flag.StringVar(&quietHours, "quiet-hours", "", "Quiet hours in local time as start-end, e.g. 22-7 (empty disables the noise limit)")
+	flag.Float64Var(&noiseLimit, "noise-limit", 55, "Sound level (dB) that caps power during quiet hours")
+	flag.Float64Var(&quietReduction, "quiet-reduction", 30, "Power target reduction (%) while the noise cap is active")
+	flag.StringVar(&smokeInputs, "smoke-inputs", "", "Comma-separated Shelly inputs wired to smoke/CO detector alarm relays, as <device id>/input:<n>")
+	flag.StringVar(&emergencySecret, "emergency-secret", "", "Secret that signs the token of GET /api/emergency/stop for physical buttons (empty disables it)")
+	flag.StringVar(&scenarioSecret, "scenario-secret", "", "Secret that signs the tokens of GET /api/scenarios/:name/activate for physical buttons (empty disables them)")
+	flag.StringVar(&emergencyEmails, "emergency-to", "", "Comma-separated recipients of emergency notifications (empty uses --report-to)")
+	flag.IntVar(&jobWorkers, "job-workers", 2, "Number of queued jobs run at the same time")
+	flag.IntVar(&jobConcurrency, "job-concurrency", 8, "Machines handled in parallel within a job")
+	flag.IntVar(&rampStepW, "ramp-step-w", 0, "Default step in W for power target changes, which then ramp from the current target instead of jumping (0 jumps)")
+	flag.IntVar(&rampStepSeconds, "ramp-step-seconds", 30, "Default seconds between power ramp steps")
+	flag.IntVar(&startGapSeconds, "start-gap-seconds", 5, "Default seconds between relay switches of a bulk start, to spread the inrush current")
+	flag.IntVar(&inrushSeconds, "inrush-seconds", 30, "Seconds the outlet power of a started miner is recorded for the inrush report (0 disables)")
+	flag.Float64Var(&breakerAmps, "breaker-amps", 0, "Rating (A) of the breaker feeding the miners, compared with the combined inrush peak (0: unknown)")
+	flag.Float64Var(&mainsVoltage, "mains-voltage", 230, "Mains voltage used to convert inrush power to current")
+	flag.StringVar(&legacyAPISunset, "legacy-api-sunset", "", "Announced removal date (YYYY-MM-DD) of the unversioned /api paths, sent as Deprecation/Sunset headers (empty: not deprecated)")
+	flag.StringVar(&feedTables, "feeds", "pools,shellies,bme280_readings,miner_status", "Comma-separated QuestDB tables watched for stopped ingestion, optionally as table=minutes to override --feed-max-age-minutes")
+	flag.IntVar(&feedMaxAgeMinutes, "feed-max-age-minutes", 5, "Minutes without new rows after which a watched feed is reported as stopped (0 disables the watchdog)")
+	flag.IntVar(&poolPollSeconds, "pool-poll", 60, "Seconds between reads of miner pool connections and share counters (0 disables)")
+	flag.Float64Var(&rejectRatePct, "reject-rate-pct", 5, "Share reject rate (%) over --reject-window-minutes that raises an alert (0 disables)")
+	flag.IntVar(&rejectWindowMinutes, "reject-window-minutes", 15, "Minutes of shares the reject rate is computed over")
+	flag.IntVar(&gpuPollSeconds, "gpu-poll", 30, "Seconds between reads of GPU rigs (Rigel, T-Rex) into gpu_status (0 disables)")
+	flag.StringVar(&altCoin, "alt-coin", "", "Ticker of the altcoin mined by the GPU rigs, e.g. RVN (empty disables the altcoin section)")
+	flag.StringVar(&altAlgorithm, "alt-algorithm", "", "Rig algorithm counted as --alt-coin mining, e.g. kawpow (empty counts every GPU rig)")
+	flag.StringVar(&altHashrateUnit, "alt-hashrate-unit", "MH/s", "Unit altcoin hashrates are shown in: H/s, kH/s, MH/s, GH/s or TH/s")
+	flag.StringVar(&altCoinAPI, "alt-coin-api", "", "WhatToMine coin JSON URL for the --alt-coin network hashrate, block reward and price (empty: no revenue)")
+	flag.StringVar(&altPoolAPI, "alt-pool-api", "", "Pool account URL in open-ethereum-pool format for the hashrate the pool credits (empty: no pool hashrate)")
+	flag.IntVar(&altPollSeconds, "alt-poll", 300, "Seconds between reads of the altcoin network and pool APIs")
+	flag.IntVar(&recoverMinutes, "recover-minutes", 0, "Minutes a powered, reachable miner may report zero hashrate before the recovery steps run (0 disables)")
+	flag.StringVar(&recoverSteps, "recover-steps", "restart,reboot,powercycle", "Comma-separated recovery steps tried in order: restart (mining process), reboot (control board), powercycle (outlet off and on)")
+	flag.IntVar(&recoverStepMinutes, "recover-step-minutes", 10, "Minutes a recovery step gets to bring the hashrate back before the next one runs")
+	flag.IntVar(&recoverCooldownMinutes, "recover-cooldown-minutes", 120, "Minutes after a recovery sequence before the same miner is recovered again")
+	flag.IntVar(&gaugeAlertSeconds, "gauge-alert-poll", 60, "Seconds between checks of the dashboard gauges against their warn/critical thresholds (0 disables gauge alerts)")
+	flag.IntVar(&locationPollSeconds, "location-poll", 60, "Seconds between checks of sensor locations against their expected reporting interval (0 disables location alerts)")
+	flag.StringVar(&outlierRulesFlag, "outlier-rules", "", "Valid ranges overriding the defaults, as metric=min:max pairs separated by commas (either bound may be empty, off disables a metric), e.g. hashboard_temp=5:110,plug_power=off; readings outside are left out of charts and alerts")
+	flag.IntVar(&sensorStuckMinutes, "sensor-stuck-minutes", 120, "Minutes of environment readings checked for stuck, impossible or jumping values; suspect sensors are left out of room averages (0 disables)")
+	flag.Float64Var(&heatCapacity, "heat-capacity", 4.186, "Volumetric heat capacity of the heat recovery circuit's fluid in kJ/(L·K) (water 4.186, 30% glycol about 3.9)")
+	flag.Float64Var(&heatPrice, "heat-price", 0, "Value of a kWh of recovered heat in EUR, e.g. the gas or heat pump cost it replaces (0 uses --elec-price)")
+	flag.StringVar(&utilityCSVDelimiter, "utility-csv-delimiter", ";", "Field delimiter of the utility's interval CSV (\\t for tab)")
+	flag.StringVar(&utilityCSVDecimal, "utility-csv-decimal", ",", "Decimal separator of kWh values in the utility's interval CSV (. or ,)")
+	flag.StringVar(&utilityCSVTimeFormat, "utility-csv-time-format", "2006-01-02 15:04", "Go time layout of the utility's interval CSV timestamps")
+	flag.StringVar(&utilityCSVColumns, "utility-csv-columns", "Timestamp,Energy A+ [kWh]", "Timestamp and kWh column headers of the utility's interval CSV, comma-separated")
+	flag.BoolVar(&utilityCSVIntervalEnd, "utility-csv-interval-end", true, "Utility CSV timestamps mark the end of each 15 minute interval (false: the start)")
+	flag.StringVar(&roomTimezone, "timezone", "Local", "Time zone of calendar days, hours of day and schedules (today's charts, daily energy, quiet hours, reports), e.g. Europe/Ljubljana")
+	flag.StringVar(&utilityTimezone, "utility-timezone", "", "Time zone of the utility CSV timestamps and reconciliation days (empty: --timezone)")
+	flag.BoolVar(&requireOwnerLogin, "require-owner-login", false, "Require clients outside the inner network to log in as a hosting owner, who then only sees their own machines")
+	flag.IntVar(&rateLimitPerMinute, "rate-limit", 600, "API requests per minute allowed from one client IP (0 disables)")
+	flag.IntVar(&rateLimitBurst, "rate-limit-burst", 120, "API requests a client IP may make at once before --rate-limit applies")
+	flag.IntVar(&loginMaxFailures, "login-max-failures", 5, "Failed owner logins in a row before the client IP is locked out of logging in")
+	flag.IntVar(&loginLockoutSeconds, "login-lockout-seconds", 60, "First login lockout in seconds; each further lockout doubles it, up to 24h")
+	flag.StringVar(&trustedProxies, "trusted-proxies", "", "Comma-separated IPs or CIDRs of reverse proxies whose X-Forwarded-For is trusted for the client IP (empty trusts none)")
+	flag.BoolVar(&queryStats, "query-stats", false, "Record timing, row counts and errors of each QuestDB query for /api/admin/query-stats")
+	flag.IntVar(&slowQueryMs, "slow-query-ms", 0, "Log QuestDB queries taking at least this many milliseconds, with their SQL (0 disables)")
+	flag.StringVar(&limitMode, "limit-mode", limitRefuse, "What to do with power, freq/volt and template requests outside a miner's model profile: refuse or clamp")
+	flag.IntVar(&requestTimeoutSeconds, "request-timeout-seconds", 30, "Seconds a request may wait on miners, outlets and QuestDB before its calls are canceled (0 disables)")
+	flag.StringVar(&grpcAddr, "grpc-addr", "", "Listen address of the gRPC API for automation clients, e.g. :9090 (empty disables it)")
+	flag.IntVar(&undoMinutes, "undo-minutes", 15, "Minutes after a bulk power, freq/volt or sleep job finishes during which it can be undone")
+	flag.StringVar(&sshUser, "ssh-user", "root", "Default SSH user for miners without stored credentials")
+	flag.StringVar(&sshPass, "ssh-pass", "", "Default SSH password for miners without stored credentials")
+	flag.StringVar(&sshKeyPath, "ssh-key", "", "Default SSH private key file for miners without stored credentials")
 	flag.Parse()
 
+	if loc, err := time.LoadLocation(roomTimezone); err != nil {
+		log.Fatalf("Invalid --timezone %q: %v", roomTimezone, err)
+	} else {
+		roomLocation = loc
+	}
+	if prices, err := parseTariff(elecTariff, elecPrice); err != nil {
+		log.Fatalf("Invalid --elec-tariff %q: %v", elecTariff, err)
+	} else {
+		tariffPrices = prices
+	}
+	if utilityTimezone != "" {
+		if _, err := time.LoadLocation(utilityTimezone); err != nil {
+			log.Fatalf("Invalid --utility-timezone %q: %v", utilityTimezone, err)
+		}
+	}
+
+	if quietHours != "" {
+		if _, _, err := parseQuietHours(quietHours); err != nil {
+			log.Fatalf("Invalid --quiet-hours %q: %v", quietHours, err)
+		}
+	}
+
+	var meter roomMeter
+	if roomMeterURL != "" {
+		var err error
+		if meter, err = parseRoomMeter(roomMeterURL); err != nil {
+			log.Fatalf("Invalid --room-meter %q: %v", roomMeterURL, err)
+		}
+		if roomMeterPollSeconds <= 0 {
+			log.Fatalf("Invalid --room-meter-poll %d: must be positive", roomMeterPollSeconds)
+		}
+	}
+
+	if legacyAPISunset != "" {
+		if _, err := time.Parse("2006-01-02", legacyAPISunset); err != nil {
+			log.Fatalf("Invalid --legacy-api-sunset %q: must be YYYY-MM-DD", legacyAPISunset)
+		}
+	}
+
+	var feedLimits map[string]int
+	if feedMaxAgeMinutes > 0 {
+		var err error
+		if feedLimits, err = parseFeeds(feedTables, feedMaxAgeMinutes); err != nil {
+			log.Fatalf("Invalid --feeds %q: %v", feedTables, err)
+		}
+	}
+	if rejectWindowMinutes <= 0 {
+		log.Fatalf("Invalid --reject-window-minutes %d: must be positive", rejectWindowMinutes)
+	}
+	if altCoin != "" {
+		if err := validateAltCoin(); err != nil {
+			log.Fatalf("Invalid --alt-* settings: %v", err)
+		}
+		if altPollSeconds <= 0 {
+			log.Fatalf("Invalid --alt-poll %d: must be positive", altPollSeconds)
+		}
+	}
+	var recoverySteps []string
+	if recoverMinutes > 0 {
+		var err error
+		if recoverySteps, err = parseRecoverySteps(recoverSteps); err != nil {
+			log.Fatalf("Invalid --recover-steps %q: %v", recoverSteps, err)
+		}
+		if recoverStepMinutes <= 0 {
+			log.Fatalf("Invalid --recover-step-minutes %d: must be positive", recoverStepMinutes)
+		}
+	}
+
+	if shellyPollSeconds <= 0 {
+		log.Fatalf("Invalid --shelly-poll %d: must be positive", shellyPollSeconds)
+	}
+	if layout, err := parseUtilityCSVLayout(); err != nil {
+		log.Fatalf("Invalid --utility-* settings: %v", err)
+	} else {
+		utilityLayout = layout
+	}
+
+	if rateLimitPerMinute < 0 || rateLimitPerMinute > 0 && rateLimitBurst < 1 {
+		log.Fatalf("Invalid --rate-limit %d / --rate-limit-burst %d: the limit must not be negative and the burst must be positive", rateLimitPerMinute, rateLimitBurst)
+	}
+	if loginMaxFailures <= 0 || loginLockoutSeconds <= 0 {
+		log.Fatalf("Invalid --login-max-failures %d / --login-lockout-seconds %d: must be positive", loginMaxFailures, loginLockoutSeconds)
+	}
+	proxies, err := parseTrustedProxies(trustedProxies)
+	if err != nil {
+		log.Fatalf("Invalid --trusted-proxies %q: %v", trustedProxies, err)
+	}
+
 	if *innerNet != "" {
-		_, cidr, err := net.ParseCIDR(*innerNet)
+		innerNetworks, err = parseNetworks(*innerNet)
 		if err != nil {
-			log.Fatalf("Invalid --inner-network CIDR %q: %v", *innerNet, err)
+			log.Fatalf("Invalid --inner-network %q: %v", *innerNet, err)
 		}
-		innerNetwork = cidr
 		log.Printf("Network access control enabled: manage/settings restricted to %s", *innerNet)
 	}
 
+	if rampStepW < 0 || rampStepSeconds < 0 {
+		log.Fatalf("--ramp-step-w and --ramp-step-seconds must not be negative")
+	}
+	if limitMode != limitRefuse && limitMode != limitClamp {
+		log.Fatalf("--limit-mode must be %s or %s, got %q", limitRefuse, limitClamp, limitMode)
+	}
+
+	outlierRanges, outlierRules, err := parseOutlierRules(outlierRulesFlag)
+	if err != nil {
+		log.Fatalf("Invalid --outlier-rules: %v", err)
+	}
+	log.Printf("Outlier rules: %s", describeOutlierRules(outlierRules))
+
 	log.Printf("Using QuestDB at %s:%d", *questdbHost, *questdbPort)
-	questdbClient = questdb.NewClient(*questdbHost, *questdbPort)
+	questdbClient = setupQueryTracing(questdb.NewClient(*questdbHost, *questdbPort)).WithLocation(roomLocation).WithValidRanges(outlierRanges)
+	if *questdbSecondaryHost != "" {
+		if *questdbCheckSeconds <= 0 {
+			log.Fatalf("--questdb-check-seconds must be positive, got %d", *questdbCheckSeconds)
+		}
+		log.Printf("QuestDB failover: reads move to %s:%d while the primary is down", *questdbSecondaryHost, *questdbSecondaryPort)
+		questdbClient = questdbClient.WithSecondary(*questdbSecondaryHost, *questdbSecondaryPort, onQuestDBFailover)
+	}
+
+	if backfillBTCPrices != "" {
+		since, err := time.Parse("2006-01-02", backfillBTCPrices)
+		if err != nil {
+			log.Fatalf("Invalid --backfill-btc-prices %q: must be YYYY-MM-DD", backfillBTCPrices)
+		}
+		days, err := backfillBTCPriceHistory(since)
+		if err != nil {
+			log.Fatalf("BTC price backfill failed: %v", err)
+		}
+		log.Printf("Imported BTC prices of %d days since %s", days, backfillBTCPrices)
+		return
+	}
+
+	switch *tsdb {
+	case tsdbQuestDB:
+		tsStore = questdbClient
+	case tsdbInfluxDB:
+		if *influxOrg == "" {
+			log.Fatalf("--influx-org is required with --tsdb influxdb")
+		}
+		if requireOwnerLogin {
+			log.Fatalf("--require-owner-login needs --tsdb questdb: owner scopes are only applied to QuestDB queries")
+		}
+		log.Printf("Using InfluxDB bucket %s at %s for the dashboard and metric writes", *influxBucket, *influxURL)
+		influxClient = influxdb.NewClient(*influxURL, *influxOrg, *influxBucket, *influxToken).WithLocation(roomLocation).WithValidRanges(outlierRanges)
+		if err := influxClient.Ping(); err != nil {
+			log.Printf("InfluxDB is not reachable yet: %v", err)
+		}
+		tsStore = influxClient
+	default:
+		log.Fatalf("Invalid --tsdb %q: must be questdb or influxdb", *tsdb)
+	}
 
-	var err error
 	database, err = db.Open(*dbPath)
 	if err != nil {
 		log.Fatalf("Failed to open database: %v", err)
@@ -113,73 +386,146 @@ func main() {
 		log.Fatalf("Failed to ensure database schema: %v", err)
 	}
 
-	machines, err = database.FetchMachines()
+	if influxClient == nil {
+		if report, err := questdbClient.EnsureSchema(); err != nil {
+			log.Printf("Failed to check QuestDB schema: %v", err)
+		} else {
+			logSchemaReport(report)
+		}
+	}
+
+	if err := emergency.load(); err != nil {
+		log.Fatalf("Failed to load emergency lockout: %v", err)
+	}
+	if err := scenarios.load(); err != nil {
+		log.Fatalf("Failed to load active scenario: %v", err)
+	}
+	if err := bans.load(); err != nil {
+		log.Fatalf("Failed to load IP bans: %v", err)
+	}
+
+	loaded, err := database.FetchMachines()
 	if err != nil {
 		log.Fatalf("Failed to fetch machines: %v", err)
 	}
-	log.Printf("Loaded %d mining machines from database", len(machines))
+	setMachines(loaded)
+	log.Printf("Loaded %d mining machines from database", len(loaded))
+
+	if *questdbSecondaryHost != "" {
+		go runQuestDBHealthCheck(time.Duration(*questdbCheckSeconds) * time.Second)
+	}
+	go runCoolingMonitor(time.Minute)
+	go runThermalController(time.Minute)
+	go runCondensationMonitor(time.Minute)
+	if influxClient == nil {
+		// InfluxDB downsamples and expires data with tasks and bucket retention
+		go runDownsampler(time.Hour)
+	}
+	go runReconciler(5 * time.Minute)
+	runJobWorkers(jobWorkers)
+	go runForecastPlanner(30 * time.Minute)
+	go runReportScheduler(time.Hour)
+	go runShellyWatcher(time.Duration(shellyPollSeconds) * time.Second)
+	if presencePollSeconds > 0 {
+		go runPresenceChecker(time.Duration(presencePollSeconds) * time.Second)
+	}
+	if idleCutMinutes > 0 {
+		go runIdleCutter(time.Minute)
+	}
+	if meter != nil {
+		go runRoomMeter(meter, time.Duration(roomMeterPollSeconds)*time.Second)
+	}
+	if macResolveMinutes > 0 {
+		go runMACResolver(time.Duration(macResolveMinutes) * time.Minute)
+	}
+	useDeviceResolver()
+	if resolveMinutes > 0 {
+		go runHostResolver(time.Duration(resolveMinutes) * time.Minute)
+	}
+	go runSensorBridge()
+	go runDoorMonitor(time.Minute)
+	go runNoisePolicy(time.Minute)
+	go runAwaySummary(time.Hour)
+	go runMaintenanceReminders(time.Hour)
+	if btcPricePollMinutes > 0 {
+		go runBTCPriceRecorder(time.Duration(btcPricePollMinutes) * time.Minute)
+	}
+	if err := loadSummaryTemplate(summaryTemplatePath); err != nil {
+		log.Fatalf("Invalid --summary-template: %v", err)
+	}
+	if summaryWebhookURL != "" {
+		go runSummaryWebhook(time.Hour)
+	}
+	if addresses := parsePayoutAddresses(payoutAddresses); len(addresses) > 0 {
+		if payoutPollSeconds <= 0 {
+			log.Fatalf("--payout-poll must be positive, got %d", payoutPollSeconds)
+		}
+		go runPayoutWatcher(addresses, time.Duration(payoutPollSeconds)*time.Second)
+	}
+	if len(feedLimits) > 0 && influxClient == nil {
+		go runFeedWatchdog(feedLimits, time.Minute)
+	}
+	if gpuPollSeconds > 0 {
+		go runGPUPoller(time.Duration(gpuPollSeconds) * time.Second)
+	}
+	if altCoin != "" && (altCoinAPI != "" || altPoolAPI != "") {
+		go runAltPoller(time.Duration(altPollSeconds) * time.Second)
+	}
+	if recoverMinutes > 0 {
+		go runRecoveryPolicy(recoverySteps, time.Minute)
+	}
+	if poolPollSeconds > 0 {
+		go runPoolMonitor(time.Duration(poolPollSeconds) * time.Second)
+	}
+	if gaugeAlertSeconds > 0 {
+		go runGaugeAlerts(time.Duration(gaugeAlertSeconds) * time.Second)
+	}
+	if locationPollSeconds > 0 {
+		go runLocationMonitor(time.Duration(locationPollSeconds) * time.Second)
+	}
+	if grpcAddr != "" {
+		go runGRPCServer(grpcAddr)
+	}
 
 	r := gin.Default()
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		log.Fatalf("Invalid --trusted-proxies %q: %v", trustedProxies, err)
+	}
 
-	// Check client network on every request
+	// Check client network, refuse banned and too frequent clients, resolve
+	// locale, apply security headers, owner sessions and CSRF and bound the
+	// request's calls on every request
 	r.Use(networkContextMiddleware())
+	r.Use(rateLimitMiddleware())
+	r.Use(localeMiddleware())
+	r.Use(securityHeadersMiddleware())
+	r.Use(ownerSessionMiddleware())
+	r.Use(csrfMiddleware())
+	r.Use(requestTimeoutMiddleware())
 
 	// Load HTML templates
+	r.SetFuncMap(template.FuncMap{"T": translate})
 	r.LoadHTMLGlob("templates/*")
 
 	// Serve static files
 	r.Static("/static", "./static")
 
 	// Dashboard route
-	r.GET("/", dashboardHandler)
+	r.GET("/", replayMiddleware(), dashboardHandler)
 	r.GET("/miners", minersHandler)
 	r.GET("/power-mining", powerMiningHandler)
 	r.GET("/environment", environmentHandler)
 	r.GET("/manage", requireInnerNetwork(), manageHandler)
 	r.GET("/settings", requireInnerNetwork(), settingsHandler)
+	r.GET("/kiosk", kioskHandler)
+	r.GET("/reports/efficiency", efficiencyReportPageHandler)
+	r.GET("/login", loginPageHandler)
+	r.GET("/public/:token", publicStatusPageHandler)
 
-	// API routes for dashboard data
-	api := r.Group("/api")
-	{
-		api.GET("/status", getStatusHandler)
-		api.GET("/gauges", getGaugesHandler)
-		api.GET("/charts", getChartsHandler)
-		api.GET("/charts/environment", getEnvironmentChartHandler)
-		api.GET("/charts/miner-temperatures", getMinerTemperatureChartHandler)
-		api.GET("/charts/humidity", getHumidityChartHandler)
-		api.GET("/charts/pressure", getPressureChartHandler)
-		api.GET("/charts/hourly-temp", getHourlyTempChartHandler)
-		api.GET("/charts/thermal-insulation", getThermalInsulationChartHandler)
-		api.GET("/charts/daily-energy", getDailyEnergyChartHandler)
-		api.GET("/charts/power-total", getPowerTimeSeriesHandler)
-		api.GET("/charts/hashrate-total", getHashrateTimeSeriesHandler)
-		api.GET("/charts/miner-hashrates", getMinerHashrateChartHandler)
-		api.GET("/charts/device-power", getDevicePowerChartHandler)
-		api.GET("/miners/status", getMinerStatusHandler)
-		api.GET("/environment/latest", getEnvironmentLatestHandler)
-
-		// Manage APIs - inner network only
-		manage := api.Group("/", requireInnerNetwork())
-		{
-			manage.GET("/manage/miners", getManageMinersHandler)
-
-			// Individual miner control
-			manage.POST("/miner/power", setMinerPowerHandler)
-			manage.POST("/miner/start", startMinerHandler)
-			manage.POST("/miner/shutdown", shutdownMinerHandler)
-
-			// Bulk miner control
-			manage.POST("/miners/power", setAllMinersPowerHandler)
-			manage.POST("/miners/freq", setAllMinersFreqVoltHandler)
-			manage.POST("/miners/sleep", setAllMinersSleepHandler)
-			manage.POST("/miners/start", startAllMinersHandler)
-			manage.POST("/miners/shutdown", shutdownAllMinersHandler)
-
-			// Machine management
-			manage.POST("/machines", addMachineHandler)
-			manage.DELETE("/machines/:ip", deleteMachineHandler)
-		}
-	}
+	// API routes: /api/v1 is the canonical prefix; the unversioned /api paths
+	// stay as aliases of v1 for existing clients
+	registerAPIRoutes(r.Group("/api/v1", apiVersionMiddleware(1)))
+	registerAPIRoutes(r.Group("/api", apiVersionMiddleware(0)))
 
 	// Catch-all 404 handler
 	r.NoRoute(func(c *gin.Context) {
@@ -189,15 +535,282 @@ func main() {
 	r.Run(":8080")
 }
 
+// registerAPIRoutes registers the dashboard data and management API on api.
+func registerAPIRoutes(api *gin.RouterGroup) {
+	api.GET("/health", getHealthHandler)
+	api.GET("/versions", getAPIVersionsHandler)
+	api.GET("/status", replayMiddleware(), getStatusHandler)
+	api.GET("/history/:metric", getHistoryHandler)
+	api.GET("/gauges", replayMiddleware(), getGaugesHandler)
+	api.GET("/summary", replayMiddleware(), getSummaryHandler)
+	api.GET("/charts", getChartsHandler)
+	api.GET("/charts/environment", replayMiddleware(), getEnvironmentChartHandler)
+	api.GET("/charts/miner-temperatures", replayMiddleware(), getMinerTemperatureChartHandler)
+	api.GET("/charts/humidity", replayMiddleware(), getHumidityChartHandler)
+	api.GET("/charts/pressure", replayMiddleware(), getPressureChartHandler)
+	api.GET("/charts/hourly-temp", replayMiddleware(), getHourlyTempChartHandler)
+	api.GET("/charts/thermal-insulation", replayMiddleware(), getThermalInsulationChartHandler)
+	api.GET("/charts/daily-energy", replayMiddleware(), getDailyEnergyChartHandler)
+	api.GET("/charts/power-total", replayMiddleware(), getPowerTimeSeriesHandler)
+	api.GET("/charts/hashrate-total", replayMiddleware(), getHashrateTimeSeriesHandler)
+	api.GET("/charts/miner-hashrates", replayMiddleware(), getMinerHashrateChartHandler)
+	api.GET("/charts/device-power", replayMiddleware(), getDevicePowerChartHandler)
+	api.GET("/charts/pool-rejects", replayMiddleware(), getPoolRejectChartHandler)
+	api.GET("/charts/gpus", replayMiddleware(), getGPUChartHandler)
+	api.GET("/charts/custom", replayMiddleware(), getCustomChartHandler)
+	api.GET("/charts/custom/metrics", getChartMetricsHandler)
+	api.GET("/gpus/latest", replayMiddleware(), getGPULatestHandler)
+	api.GET("/charts/alt-hashrate", replayMiddleware(), getAltHashrateChartHandler)
+	api.GET("/miners/status", replayMiddleware(), getMinerStatusHandler)
+	api.GET("/environment/latest", replayMiddleware(), getEnvironmentLatestHandler)
+	api.GET("/economics/break-even", getBreakEvenHandler)
+	api.GET("/economics/per-miner", getPerMinerEconomicsHandler)
+	api.GET("/payouts", getPayoutsHandler)
+	api.GET("/layout", getLayoutHandler)
+	api.GET("/machines/:ip/maintenance", getMachineMaintenanceHandler)
+	api.GET("/thermal/frames", getThermalFramesHandler)
+	api.GET("/airflow", getAirflowHandler)
+	api.GET("/charts/coolant-temperature", replayMiddleware(), getCoolantTemperatureChartHandler)
+	api.GET("/charts/coolant-flow", replayMiddleware(), getCoolantFlowChartHandler)
+	api.GET("/charts/heat-recovery", replayMiddleware(), getHeatRecoveryChartHandler)
+	api.GET("/cooling/latest", replayMiddleware(), getCoolingLatestHandler)
+	api.GET("/alerts", getAlertsHandler)
+	api.GET("/shellies/state", getShellyStatesHandler)
+	api.GET("/presence", getPresenceHandler)
+	api.GET("/recovery", getRecoveryHandler)
+	api.GET("/power/phases", replayMiddleware(), getPhasesHandler)
+	api.GET("/power/waste", getWasteHandler)
+	api.GET("/contacts", replayMiddleware(), getContactsHandler)
+	api.GET("/noise", getNoiseHandler)
+	api.GET("/charts/noise", replayMiddleware(), getNoiseChartHandler)
+	api.GET("/events", getEventsHandler)
+	api.GET("/condensation", getCondensationHandler)
+	api.GET("/emergency", getEmergencyHandler)
+	api.GET("/emergency/stop", auditMiddleware(), emergencyButtonHandler)
+	api.GET("/scenarios/:name/activate", auditMiddleware(), scenarioButtonHandler)
+	api.GET("/forecast", getForecastHandler)
+	api.GET("/jobs", getJobsHandler)
+	api.GET("/inrush", getInrushReportsHandler)
+	api.GET("/inrush/:id", getInrushReportHandler)
+	api.GET("/jobs/:id", getJobHandler)
+	api.GET("/groups", getGroupsHandler)
+	api.GET("/drift", getDriftHandler)
+	api.GET("/reports/efficiency", getEfficiencyReportHandler)
+	api.GET("/reports/preview", previewReportHandler)
+	api.GET("/reports/degree-days", getDegreeDayReportHandler)
+	api.GET("/reports/heat-recovery", getHeatRecoveryReportHandler)
+	api.GET("/reports/utility-reconciliation", getUtilityReconciliationHandler)
+	api.GET("/export/utility-csv", exportUtilityCSVHandler)
+	api.GET("/public/:token", getPublicStatusHandler)
+	api.POST("/login", loginHandler)
+	api.POST("/logout", logoutHandler)
+	api.GET("/account", getAccountHandler)
+	api.GET("/account/statements", getAccountStatementsHandler)
+
+	// Grafana SimpleJSON datasource
+	grafana := api.Group("/grafana")
+	{
+		grafana.GET("/", grafanaTestHandler)
+		grafana.POST("/search", grafanaSearchHandler)
+		grafana.POST("/query", grafanaQueryHandler)
+	}
+
+	// Pushed measurements - inner network only
+	ingest := api.Group("/ingest", requireInnerNetwork())
+	{
+		ingest.POST("/shelly", ingestShellyHandler)
+	}
+
+	// Pushed measurements from sensor nodes - token auth
+	sensorIngest := api.Group("/ingest", requireIngestToken())
+	{
+		sensorIngest.POST("/lineprotocol", ingestLineProtocolHandler)
+		sensorIngest.POST("/json", ingestJSONHandler)
+		sensorIngest.POST("/thermal", ingestThermalHandler)
+	}
+
+	// Manage APIs - inner network only
+	manage := api.Group("/", requireInnerNetwork(), auditMiddleware(), awayGuard())
+	{
+		manage.GET("/manage/miners", getManageMinersHandler)
+
+		// Individual miner control
+		manage.POST("/miner/power", setMinerPowerHandler)
+		manage.POST("/miner/start", startMinerHandler)
+		manage.POST("/miner/shutdown", requireTOTP(), shutdownMinerHandler)
+
+		// Bulk miner control
+		manage.POST("/miners/power", setAllMinersPowerHandler)
+		manage.POST("/miners/freq", requireTOTP(), setAllMinersFreqVoltHandler)
+		manage.POST("/miners/sleep", setAllMinersSleepHandler)
+		manage.POST("/miners/start", startAllMinersHandler)
+		manage.POST("/miners/shutdown", requireTOTP(), shutdownAllMinersHandler)
+
+		// Machine management
+		manage.POST("/machines", addMachineHandler)
+		manage.POST("/machines/bulk-add", bulkAddMachinesHandler)
+		manage.DELETE("/machines/:ip", deleteMachineHandler)
+		manage.POST("/machines/:ip/firmware", setMachineFirmwareHandler)
+		manage.POST("/machines/:ip/mac", setMachineMACHandler)
+		manage.POST("/machines/:ip/outlet", setMachineOutletHandler)
+		manage.GET("/machines/hosts", getResolvedHostsHandler)
+		manage.POST("/machines/:ip/identify", identifyMachineHandler)
+		manage.POST("/machines/:ip/owner", setMachineOwnerHandler)
+		manage.POST("/machines/:ip/position", setMachinePositionHandler)
+		manage.POST("/machines/:ip/maintenance", setMaintenanceHandler)
+		manage.DELETE("/machines/:ip/maintenance", clearMaintenanceHandler)
+		manage.POST("/machines/:ip/maintenance/log", addMaintenanceEntryHandler)
+		manage.DELETE("/machines/:ip/maintenance/log/:id", deleteMaintenanceEntryHandler)
+		manage.POST("/machines/:ip/warranty", setMachineWarrantyHandler)
+
+		// Cooling loops
+		manage.GET("/cooling/loops", getCoolingLoopsHandler)
+		manage.POST("/cooling/loops", saveCoolingLoopHandler)
+		manage.DELETE("/cooling/loops/:name", deleteCoolingLoopHandler)
+
+		// Dashboard gauges
+		manage.GET("/gauges/definitions", getGaugeDefinitionsHandler)
+		manage.POST("/gauges/definitions", saveGaugeHandler)
+		manage.DELETE("/gauges/definitions/:name", deleteGaugeHandler)
+		manage.GET("/locations", getSensorLocationsHandler)
+		manage.POST("/locations", saveSensorLocationHandler)
+		manage.DELETE("/locations/:name", deleteSensorLocationHandler)
+
+		// Actuators
+		manage.GET("/actuators", getActuatorsHandler)
+		manage.POST("/actuators", saveActuatorHandler)
+		manage.DELETE("/actuators/:name", deleteActuatorHandler)
+		manage.POST("/actuators/:name/state", setActuatorStateHandler)
+		manage.POST("/actuators/:name/auto", setActuatorAutoHandler)
+
+		// Condensation protection
+		manage.POST("/condensation/override", setCondensationOverrideHandler)
+		manage.DELETE("/condensation/override", clearCondensationOverrideHandler)
+
+		// Notification channels
+		manage.GET("/notify/channels", getNotifyChannelsHandler)
+		manage.POST("/notify/channels", saveNotifyChannelHandler)
+		manage.DELETE("/notify/channels/:name", deleteNotifyChannelHandler)
+		manage.POST("/notify/channels/:name/test", testNotifyChannelHandler)
+
+		// Emergency stop and lockout
+		manage.POST("/emergency/stop", emergencyStopHandler)
+		manage.POST("/emergency/reset", requireTOTP(), resetEmergencyHandler)
+		manage.GET("/emergency/button", requireTOTP(), getEmergencyButtonHandler)
+
+		// Miner groups
+		manage.POST("/groups", addGroupHandler)
+		manage.DELETE("/groups/:id", deleteGroupHandler)
+		manage.POST("/groups/:id/members", addGroupMemberHandler)
+		manage.DELETE("/groups/:id/members/:ip", removeGroupMemberHandler)
+
+		// Desired miner state
+		manage.GET("/desired", getDesiredStatesHandler)
+		manage.POST("/desired", saveDesiredStateHandler)
+		manage.DELETE("/desired/:ip", deleteDesiredStateHandler)
+
+		// Two-factor authentication for destructive actions
+		manage.GET("/2fa", getTOTPStatusHandler)
+		manage.POST("/2fa/enroll", enrollTOTPHandler)
+		manage.POST("/2fa/confirm", confirmTOTPHandler)
+		manage.POST("/2fa/disable", disableTOTPHandler)
+
+		// Audit log
+		manage.GET("/audit/export", exportAuditHandler)
+		manage.GET("/audit/public-key", getAuditPublicKeyHandler)
+
+		// SSH command execution
+		manage.GET("/ssh/commands", getSSHCommandsHandler)
+		manage.POST("/ssh/exec", execSSHHandler)
+		manage.GET("/ssh/credentials", getSSHCredentialsHandler)
+		manage.POST("/ssh/credentials", saveSSHCredentialsHandler)
+		manage.DELETE("/ssh/credentials/:ip", deleteSSHCredentialsHandler)
+
+		// Job queue
+		manage.POST("/jobs/:id/cancel", cancelJobHandler)
+		manage.POST("/jobs/:id/undo", requireTOTP(), undoJobHandler)
+
+		// Email reports
+		manage.POST("/reports/send", sendReportHandler)
+		manage.GET("/summary-webhook/preview", previewSummaryWebhookHandler)
+		manage.POST("/summary-webhook/test", testSummaryWebhookHandler)
+
+		// Hosting billing
+		manage.GET("/billing/owners", getHostingOwnersHandler)
+		manage.POST("/billing/owners", saveHostingOwnerHandler)
+		manage.DELETE("/billing/owners/:name", deleteHostingOwnerHandler)
+		manage.POST("/billing/owners/:name/password", setOwnerPasswordHandler)
+
+		// IP bans
+		manage.GET("/bans", getBansHandler)
+		manage.POST("/bans", addBanHandler)
+		manage.DELETE("/bans/:ip", deleteBanHandler)
+
+		// Public status share links
+		manage.GET("/share-tokens", getShareTokensHandler)
+		manage.POST("/share-tokens", createShareTokenHandler)
+		manage.DELETE("/share-tokens/:id", deleteShareTokenHandler)
+		manage.GET("/billing/statements", getBillingStatementsHandler)
+
+		// Utility smart meter data
+		manage.POST("/utility/import", importUtilityCSVHandler)
+
+		// Config templates; applying may set freq/volt
+		manage.GET("/templates", getConfigTemplatesHandler)
+		manage.POST("/templates", saveConfigTemplateHandler)
+		manage.DELETE("/templates/:name", deleteConfigTemplateHandler)
+		manage.POST("/templates/:name/apply", requireTOTP(), applyConfigTemplateHandler)
+
+		// Safe operating limits per miner model
+		manage.GET("/model-profiles", getModelProfilesHandler)
+		manage.POST("/model-profiles", saveModelProfileHandler)
+		manage.DELETE("/model-profiles/:model", deleteModelProfileHandler)
+
+		// Scenario presets
+		manage.GET("/scenarios", getScenariosHandler)
+		manage.POST("/scenarios", saveScenarioHandler)
+		manage.DELETE("/scenarios/:name", deleteScenarioHandler)
+		manage.POST("/scenarios/:name/activate", activateScenarioHandler)
+		manage.DELETE("/scenarios/active", deactivateScenarioHandler)
+		manage.GET("/scenarios/:name/button", requireTOTP(), getScenarioButtonHandler)
+
+		// Freq/volt tuning runs and the baselines they find
+		manage.GET("/tuning", getTuningRunsHandler)
+		manage.GET("/tuning/:id", getTuningRunHandler)
+		manage.POST("/tuning", requireTOTP(), startTuningHandler)
+		manage.GET("/baselines", getBaselinesHandler)
+
+		// Fleet config comparison
+		manage.GET("/fleet/config-diff", getConfigDiffHandler)
+		manage.POST("/fleet/config-diff", postConfigDiffHandler)
+
+		// Room state snapshots
+		manage.POST("/snapshot", createSnapshotHandler)
+		manage.GET("/snapshots", getSnapshotsHandler)
+		manage.GET("/snapshots/:id", downloadSnapshotHandler)
+		manage.DELETE("/snapshots/:id", deleteSnapshotHandler)
+
+		// QuestDB query statistics
+		manage.GET("/admin/query-stats", getQueryStatsHandler)
+		manage.DELETE("/admin/query-stats", resetQueryStatsHandler)
+	}
+}
+
 // isTimestampRecent checks if the given ISO 8601 timestamp is within the specified duration from now
 func isTimestampRecent(timestamp string, maxAge time.Duration) bool {
+	return isTimestampRecentAt(timestamp, maxAge, time.Now())
+}
+
+// isTimestampRecentAt checks if the given ISO 8601 timestamp is within maxAge
+// before now; replayed requests pass their replay time.
+func isTimestampRecentAt(timestamp string, maxAge time.Duration, now time.Time) bool {
 	// Parse the timestamp (QuestDB returns ISO 8601 format with microseconds)
 	t, err := time.Parse("2006-01-02T15:04:05.000000Z", timestamp)
 	if err != nil {
 		log.Printf("Failed to parse timestamp %q: %v", timestamp, err)
 		return false
 	}
-	return time.Since(t) <= maxAge
+	return now.Sub(t) <= maxAge
 }
 
 // fetchNetworkHashrate returns the current Bitcoin network hashrate in H/s.
@@ -236,20 +849,22 @@ func fetchBTCPriceEUR() (float64, error) {
 	return data.EUR, nil
 }
 
-// calculateDailyRevenueEUR estimates daily mining revenue in EUR.
-// myHashrateTH is the miner's hashrate in TH/s.
-func calculateDailyRevenueEUR(myHashrateTH float64) float64 {
-	if myHashrateTH <= 0 {
-		return 0
-	}
-
-	const blockRewardBTC = 3.15
+// miningMarket holds the network-wide inputs for revenue estimation.
+type miningMarket struct {
+	NetworkHashrate float64 // H/s
+	BTCPriceEUR     float64
+	Reward          blockReward
+}
 
+// fetchMiningMarket fetches the network hashrate, BTC price and block reward
+// concurrently.
+func fetchMiningMarket() (*miningMarket, error) {
 	var networkHashrate, btcPriceEUR float64
+	var reward blockReward
 	var err1, err2 error
 	var wg sync.WaitGroup
 
-	wg.Add(2)
+	wg.Add(3)
 	go func() {
 		defer wg.Done()
 		networkHashrate, err1 = fetchNetworkHashrate()
@@ -258,94 +873,94 @@ func calculateDailyRevenueEUR(myHashrateTH float64) float64 {
 		defer wg.Done()
 		btcPriceEUR, err2 = fetchBTCPriceEUR()
 	}()
+	go func() {
+		defer wg.Done()
+		reward = blockRewards.get()
+	}()
 	wg.Wait()
 
 	if err1 != nil {
-		log.Printf("Failed to fetch network hashrate: %v", err1)
-		return 0
+		return nil, fmt.Errorf("failed to fetch network hashrate: %w", err1)
 	}
 	if err2 != nil {
-		log.Printf("Failed to fetch BTC price: %v", err2)
+		return nil, fmt.Errorf("failed to fetch BTC price: %w", err2)
+	}
+	if networkHashrate <= 0 {
+		return nil, fmt.Errorf("invalid network hashrate %v", networkHashrate)
+	}
+
+	return &miningMarket{NetworkHashrate: networkHashrate, BTCPriceEUR: btcPriceEUR, Reward: reward}, nil
+}
+
+// dailyBTCPerTH returns the expected BTC mined per day by 1 TH/s of hashrate.
+func (m *miningMarket) dailyBTCPerTH() float64 {
+	return 1e12 / m.NetworkHashrate * 144 * m.Reward.TotalBTC
+}
+
+// assumptions lists the market inputs behind a revenue estimate, for API
+// responses.
+func (m *miningMarket) assumptions() gin.H {
+	return gin.H{
+		"networkHashrateEh": math.Round(m.NetworkHashrate/1e18*10) / 10,
+		"btcPriceEur":       m.BTCPriceEUR,
+		"blocksPerDay":      144,
+		"blockReward":       m.Reward,
+	}
+}
+
+// calculateDailyRevenueEUR estimates daily mining revenue in EUR.
+// myHashrateTH is the miner's hashrate in TH/s.
+func calculateDailyRevenueEUR(myHashrateTH float64) float64 {
+	if myHashrateTH <= 0 {
 		return 0
 	}
 
-	if networkHashrate <= 0 {
+	market, err := fetchMiningMarket()
+	if err != nil {
+		log.Printf("Failed to fetch mining market data: %v", err)
 		return 0
 	}
 
-	myHashrateHS := myHashrateTH * 1e12
-	myShare := myHashrateHS / networkHashrate
-	dailyBTC := myShare * 144 * blockRewardBTC
-	return math.Round(dailyBTC*btcPriceEUR*100) / 100
+	dailyBTC := myHashrateTH * market.dailyBTCPerTH()
+	return math.Round(dailyBTC*market.BTCPriceEUR*100) / 100
 }
 
 func dashboardHandler(c *gin.Context) {
-	// Get hashrate and status from QuestDB
+	// Get status from QuestDB
 	online := false
 	statusLabel := "No Data"
-	hashrate := 0.0
-	power := 0.0
 
-	result, err := questdbClient.GetTotalHashrate()
+	result, err := storeFor(c).GetTotalHashrate()
 	if err != nil {
 		log.Printf("Failed to get hashrate from QuestDB: %v", err)
 	} else if result.HasData {
-		online = isTimestampRecent(result.Timestamp, 5*time.Minute)
+		online = isTimestampRecentAt(result.Timestamp, 5*time.Minute, requestNow(c))
 		if online {
 			statusLabel = "Online"
 		} else {
 			statusLabel = "Stale Data"
 		}
-		hashrate = result.TotalHashrate / 1000 // Convert GH/s to TH/s
-	}
-
-	// Get total power from QuestDB
-	powerResult, err := questdbClient.GetTotalPower()
-	if err != nil {
-		log.Printf("Failed to get power from QuestDB: %v", err)
-	} else if powerResult.HasData {
-		power = powerResult.TotalPower
 	}
 
-	// Calculate efficiency (J/TH) - Joules per Terahash
-	efficiency := 0.0
-	if hashrate > 0 {
-		efficiency = power / hashrate // W / (TH/s) = J/TH
-	}
-
-	// Calculate daily revenue in EUR
-	revenue := calculateDailyRevenueEUR(hashrate)
-
-	// Calculate daily electricity cost: power(W) / 1000 * 24h * €0.23/kWh
-	elecCost := math.Round(power/1000*24*0.23*100) / 100
-
-	// Round values for display
-	hashrate = math.Round(hashrate)
-	efficiency = math.Round(efficiency*10) / 10 // 1 decimal
-	power = math.Round(power)
-
+	loc := localeFor(c)
 	data := gin.H{
 		"Title":      "Mining Dashboard",
-		"Machines":   machines,
+		"Machines":   currentMachines(),
 		"ShowManage": c.GetBool("ShowManage"),
+		"Lang":       loc.Lang,
+		"AsOf":       c.Query("asOf"),
 		"Status": gin.H{
 			"Online": online,
-			"Label":  statusLabel,
-		},
-		"Gauges": []gin.H{
-			{"Label": "Power", "Value": power, "Unit": "W"},
-			{"Label": "Hashrate", "Value": hashrate, "Unit": "TH/s"},
-			{"Label": "Efficiency", "Value": efficiency, "Unit": "J/TH"},
-			{"Label": "Elec. Cost", "Value": elecCost, "Unit": "€/day"},
-			{"Label": "Revenue", "Value": revenue, "Unit": "€/day"},
+			"Label":  loc.T(statusLabel),
 		},
+		"Gauges": gaugeRenderData(c, loc),
 	}
 
 	c.HTML(http.StatusOK, "dashboard.html", data)
 }
 
 func getStatusHandler(c *gin.Context) {
-	result, err := questdbClient.GetTotalHashrate()
+	result, err := storeFor(c).GetTotalHashrate()
 	if err != nil {
 		log.Printf("Failed to get hashrate from QuestDB: %v", err)
 		c.JSON(http.StatusOK, gin.H{
@@ -356,6 +971,7 @@ func getStatusHandler(c *gin.Context) {
 			"roomTemp":    0,
 			"power":       0,
 			"efficiency":  0,
+			"scenario":    scenarios.name(),
 		})
 		return
 	}
@@ -369,12 +985,13 @@ func getStatusHandler(c *gin.Context) {
 			"roomTemp":    0,
 			"power":       0,
 			"efficiency":  0,
+			"scenario":    scenarios.name(),
 		})
 		return
 	}
 
 	// Check if the timestamp is recent (within last 5 minutes)
-	online := isTimestampRecent(result.Timestamp, 5*time.Minute)
+	online := isTimestampRecentAt(result.Timestamp, 5*time.Minute, requestNow(c))
 	label := "Online"
 	if !online {
 		label = "Stale Data"
@@ -382,7 +999,7 @@ func getStatusHandler(c *gin.Context) {
 
 	// Get max temperature
 	temperature := 0.0
-	tempResult, err := questdbClient.GetMaxTemperature()
+	tempResult, err := storeFor(c).GetMaxTemperature()
 	if err != nil {
 		log.Printf("Failed to get temperature from QuestDB: %v", err)
 	} else if tempResult.HasData {
@@ -391,7 +1008,7 @@ func getStatusHandler(c *gin.Context) {
 
 	// Get room temperature
 	roomTemp := 0.0
-	roomTempResult, err := questdbClient.GetRoomTemperature()
+	roomTempResult, err := storeFor(c).GetRoomTemperature(indoorLocations())
 	if err != nil {
 		log.Printf("Failed to get room temperature from QuestDB: %v", err)
 	} else if roomTempResult.HasData {
@@ -400,7 +1017,7 @@ func getStatusHandler(c *gin.Context) {
 
 	// Get total power
 	power := 0.0
-	powerResult, err := questdbClient.GetTotalPower()
+	powerResult, err := storeFor(c).GetTotalPower()
 	if err != nil {
 		log.Printf("Failed to get power from QuestDB: %v", err)
 	} else if powerResult.HasData {
@@ -414,30 +1031,43 @@ func getStatusHandler(c *gin.Context) {
 		efficiency = power / hashrateTH // W / (TH/s) = J/TH
 	}
 
+	loc := localeFor(c)
 	c.JSON(http.StatusOK, gin.H{
 		"online":      online,
-		"label":       label,
+		"label":       loc.T(label),
 		"hashrate":    result.TotalHashrate,
-		"temperature": temperature,
-		"roomTemp":    roomTemp,
+		"temperature": loc.Temp(temperature),
+		"roomTemp":    loc.Temp(roomTemp),
+		"tempUnit":    loc.TempUnit(),
 		"power":       power,
 		"efficiency":  efficiency,
 		"timestamp":   result.Timestamp,
+		"scenario":    scenarios.name(),
 	})
 }
 
-func getGaugesHandler(c *gin.Context) {
+// gaugeValues holds the rounded values shown in the dashboard gauges.
+type gaugeValues struct {
+	Power      float64 // W
+	Hashrate   float64 // TH/s
+	Efficiency float64 // J/TH
+	ElecCost   float64 // €/day
+	Revenue    float64 // €/day
+}
+
+// fetchGaugeValues queries total hashrate and power and derives the gauge values.
+func fetchGaugeValues(store TimeseriesStore) gaugeValues {
 	hashrate := 0.0
 	power := 0.0
 
-	result, err := questdbClient.GetTotalHashrate()
+	result, err := store.GetTotalHashrate()
 	if err != nil {
 		log.Printf("Failed to get hashrate from QuestDB: %v", err)
 	} else if result.HasData {
 		hashrate = result.TotalHashrate / 1000 // Convert GH/s to TH/s
 	}
 
-	powerResult, err := questdbClient.GetTotalPower()
+	powerResult, err := store.GetTotalPower()
 	if err != nil {
 		log.Printf("Failed to get power from QuestDB: %v", err)
 	} else if powerResult.HasData {
@@ -450,26 +1080,20 @@ func getGaugesHandler(c *gin.Context) {
 		efficiency = power / hashrate // W / (TH/s) = J/TH
 	}
 
-	// Calculate daily revenue in EUR
-	revenue := calculateDailyRevenueEUR(hashrate)
+	// Daily revenue in EUR, actual pool earnings where available
+	revenue := dailyRevenueEUR(store, hashrate)
 
-	// Calculate daily electricity cost: power(W) / 1000 * 24h * €0.23/kWh
-	elecCost := math.Round(power/1000*24*0.23*100) / 100
+	// Calculate daily electricity cost: power(W) / 1000 * 24h * €/kWh
+	elecCost := math.Round(power/1000*24*elecPrice*100) / 100
 
 	// Round values for display
-	hashrate = math.Round(hashrate)
-	efficiency = math.Round(efficiency*10) / 10 // 1 decimal
-	power = math.Round(power)
-
-	c.JSON(http.StatusOK, gin.H{
-		"gauges": []gin.H{
-			{"label": "Power", "value": power, "unit": "W"},
-			{"label": "Hashrate", "value": hashrate, "unit": "TH/s"},
-			{"label": "Efficiency", "value": efficiency, "unit": "J/TH"},
-			{"label": "Elec. Cost", "value": elecCost, "unit": "€/day"},
-			{"label": "Revenue", "value": revenue, "unit": "€/day"},
-		},
-	})
+	return gaugeValues{
+		Power:      math.Round(power),
+		Hashrate:   math.Round(hashrate),
+		Efficiency: math.Round(efficiency*10) / 10, // 1 decimal
+		ElecCost:   elecCost,
+		Revenue:    revenue,
+	}
 }
 
 func getChartsHandler(c *gin.Context) {
@@ -479,22 +1103,50 @@ func getChartsHandler(c *gin.Context) {
 	})
 }
 
+// getEnvironmentChartHandler returns today's temperatures per location; with
+// ?compare=previous_period also yesterday's, moved forward a day.
 func getEnvironmentChartHandler(c *gin.Context) {
-	result, err := questdbClient.GetEnvironmentTemperatures()
+	compare, ok := wantsComparison(c)
+	if !ok {
+		return
+	}
+	result, err := storeFor(c).GetEnvironmentTemperatures()
 	if err != nil {
 		log.Printf("Failed to get environment temperatures from QuestDB: %v", err)
 		c.JSON(http.StatusOK, gin.H{
 			"locations": map[string][]interface{}{},
+			"labels":    map[string]string{},
 			"hasData":   false,
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, result)
+	response := gin.H{
+		"locations": result.Locations,
+		"labels":    locationLabels(),
+		"hasData":   result.HasData,
+	}
+	if compare {
+		previous := map[string][]questdb.EnvironmentReading{}
+		if prev, err := storeAsOf(c, previousDays(c, 1)).GetEnvironmentTemperatures(); err != nil {
+			log.Printf("Failed to get previous environment temperatures from QuestDB: %v", err)
+		} else {
+			for location, readings := range prev.Locations {
+				shifted := make([]questdb.EnvironmentReading, len(readings))
+				for i, r := range readings {
+					r.Timestamp = shiftTimestamp(r.Timestamp, 1, 0)
+					shifted[i] = r
+				}
+				previous[location] = shifted
+			}
+		}
+		response["previous"] = previous
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 func getMinerTemperatureChartHandler(c *gin.Context) {
-	result, err := questdbClient.GetMinerTemperatures()
+	result, err := storeFor(c).GetMinerTemperatures()
 	if err != nil {
 		log.Printf("Failed to get miner temperatures from QuestDB: %v", err)
 		c.JSON(http.StatusOK, gin.H{
@@ -508,35 +1160,45 @@ func getMinerTemperatureChartHandler(c *gin.Context) {
 }
 
 func getHumidityChartHandler(c *gin.Context) {
-	result, err := questdbClient.GetEnvironmentHumidity()
+	result, err := storeFor(c).GetEnvironmentHumidity()
 	if err != nil {
 		log.Printf("Failed to get humidity from QuestDB: %v", err)
 		c.JSON(http.StatusOK, gin.H{
 			"locations": map[string][]interface{}{},
+			"labels":    map[string]string{},
 			"hasData":   false,
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, result)
+	c.JSON(http.StatusOK, gin.H{
+		"locations": result.Locations,
+		"labels":    locationLabels(),
+		"hasData":   result.HasData,
+	})
 }
 
 func getPressureChartHandler(c *gin.Context) {
-	result, err := questdbClient.GetEnvironmentPressure()
+	result, err := storeFor(c).GetEnvironmentPressure()
 	if err != nil {
 		log.Printf("Failed to get pressure from QuestDB: %v", err)
 		c.JSON(http.StatusOK, gin.H{
 			"locations": map[string][]interface{}{},
+			"labels":    map[string]string{},
 			"hasData":   false,
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, result)
+	c.JSON(http.StatusOK, gin.H{
+		"locations": result.Locations,
+		"labels":    locationLabels(),
+		"hasData":   result.HasData,
+	})
 }
 
 func getHourlyTempChartHandler(c *gin.Context) {
-	result, err := questdbClient.GetHourlyAvgTemperature()
+	result, err := questdbFor(c).GetHourlyAvgTemperature(indoorLocations())
 	if err != nil {
 		log.Printf("Failed to get hourly avg temperature from QuestDB: %v", err)
 		c.JSON(http.StatusOK, gin.H{
@@ -550,11 +1212,12 @@ func getHourlyTempChartHandler(c *gin.Context) {
 }
 
 func getThermalInsulationChartHandler(c *gin.Context) {
-	result, err := questdbClient.GetThermalInsulationData()
+	result, err := questdbFor(c).GetThermalInsulationData(indoorLocations(), outdoorLocations())
 	if err != nil {
 		log.Printf("Failed to get thermal insulation data from QuestDB: %v", err)
 		c.JSON(http.StatusOK, gin.H{
 			"dataPoints": []interface{}{},
+			"rolling":    []interface{}{},
 			"hasData":    false,
 		})
 		return
@@ -563,8 +1226,15 @@ func getThermalInsulationChartHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
+// getDailyEnergyChartHandler returns the energy of the last 7 days; with
+// ?compare=previous_period also the 7 days before, dated a week later so the
+// same weekdays line up.
 func getDailyEnergyChartHandler(c *gin.Context) {
-	result, err := questdbClient.GetDailyEnergyUsage()
+	compare, ok := wantsComparison(c)
+	if !ok {
+		return
+	}
+	result, err := questdbFor(c).GetDailyEnergyUsage()
 	if err != nil {
 		log.Printf("Failed to get daily energy usage from QuestDB: %v", err)
 		c.JSON(http.StatusOK, gin.H{
@@ -573,12 +1243,37 @@ func getDailyEnergyChartHandler(c *gin.Context) {
 		})
 		return
 	}
+	if !compare {
+		c.JSON(http.StatusOK, result)
+		return
+	}
 
-	c.JSON(http.StatusOK, result)
+	previous := []questdb.DailyEnergyRow{}
+	if prev, err := questdbFor(c).WithAsOf(previousDays(c, 7)).GetDailyEnergyUsage(); err != nil {
+		log.Printf("Failed to get previous daily energy usage from QuestDB: %v", err)
+	} else {
+		for _, d := range prev.Days {
+			if t, err := time.Parse("2006-01-02", d.Date); err == nil {
+				d.Date = t.AddDate(0, 0, 7).Format("2006-01-02")
+			}
+			previous = append(previous, d)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"days":     result.Days,
+		"hasData":  result.HasData,
+		"previous": previous,
+	})
 }
 
+// getPowerTimeSeriesHandler returns the last 24 hours; with ?compare=previous_period
+// also the 24 hours before, moved forward a day.
 func getPowerTimeSeriesHandler(c *gin.Context) {
-	result, err := questdbClient.GetPowerTimeSeries()
+	compare, ok := wantsComparison(c)
+	if !ok {
+		return
+	}
+	result, err := storeFor(c).GetPowerTimeSeries()
 	if err != nil {
 		log.Printf("Failed to get power time series from QuestDB: %v", err)
 		c.JSON(http.StatusOK, gin.H{
@@ -587,12 +1282,32 @@ func getPowerTimeSeriesHandler(c *gin.Context) {
 		})
 		return
 	}
+	if !compare {
+		c.JSON(http.StatusOK, result)
+		return
+	}
 
-	c.JSON(http.StatusOK, result)
+	previous := []questdb.TimeSeriesPoint{}
+	if prev, err := storeAsOf(c, previousDays(c, 1)).GetPowerTimeSeries(); err != nil {
+		log.Printf("Failed to get previous power time series from QuestDB: %v", err)
+	} else {
+		previous = shiftPoints(prev.Points, 1, 0)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"points":   result.Points,
+		"hasData":  result.HasData,
+		"previous": previous,
+	})
 }
 
+// getHashrateTimeSeriesHandler returns the last 24 hours; with ?compare=previous_period
+// also the 24 hours before, moved forward a day.
 func getHashrateTimeSeriesHandler(c *gin.Context) {
-	result, err := questdbClient.GetHashrateTimeSeries()
+	compare, ok := wantsComparison(c)
+	if !ok {
+		return
+	}
+	result, err := storeFor(c).GetHashrateTimeSeries()
 	if err != nil {
 		log.Printf("Failed to get hashrate time series from QuestDB: %v", err)
 		c.JSON(http.StatusOK, gin.H{
@@ -601,12 +1316,26 @@ func getHashrateTimeSeriesHandler(c *gin.Context) {
 		})
 		return
 	}
+	if !compare {
+		c.JSON(http.StatusOK, result)
+		return
+	}
 
-	c.JSON(http.StatusOK, result)
+	previous := []questdb.TimeSeriesPoint{}
+	if prev, err := storeAsOf(c, previousDays(c, 1)).GetHashrateTimeSeries(); err != nil {
+		log.Printf("Failed to get previous hashrate time series from QuestDB: %v", err)
+	} else {
+		previous = shiftPoints(prev.Points, 1, 0)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"points":   result.Points,
+		"hasData":  result.HasData,
+		"previous": previous,
+	})
 }
 
 func getMinerHashrateChartHandler(c *gin.Context) {
-	result, err := questdbClient.GetPerMinerHashrateTimeSeries()
+	result, err := storeFor(c).GetPerMinerHashrateTimeSeries()
 	if err != nil {
 		log.Printf("Failed to get per-miner hashrate from QuestDB: %v", err)
 		c.JSON(http.StatusOK, gin.H{
@@ -620,7 +1349,7 @@ func getMinerHashrateChartHandler(c *gin.Context) {
 }
 
 func getDevicePowerChartHandler(c *gin.Context) {
-	result, err := questdbClient.GetPerDevicePowerTimeSeries()
+	result, err := storeFor(c).GetPerDevicePowerTimeSeries()
 	if err != nil {
 		log.Printf("Failed to get per-device power from QuestDB: %v", err)
 		c.JSON(http.StatusOK, gin.H{
@@ -634,7 +1363,7 @@ func getDevicePowerChartHandler(c *gin.Context) {
 }
 
 func getEnvironmentLatestHandler(c *gin.Context) {
-	result, err := questdbClient.GetLatestEnvironmentTemperatures()
+	result, err := questdbFor(c).GetLatestEnvironmentTemperatures()
 	if err != nil {
 		log.Printf("Failed to get latest environment temperatures from QuestDB: %v", err)
 		c.JSON(http.StatusOK, gin.H{
@@ -644,11 +1373,17 @@ func getEnvironmentLatestHandler(c *gin.Context) {
 		return
 	}
 
+	loc := localeFor(c)
+	for i := range result.Readings {
+		result.Readings[i].Temperature = loc.Temp(result.Readings[i].Temperature)
+	}
+	c.Header("X-Temperature-Unit", loc.TempUnit())
+
 	c.JSON(http.StatusOK, result)
 }
 
 func getMinerStatusHandler(c *gin.Context) {
-	result, err := questdbClient.GetMinerStatuses()
+	result, err := storeFor(c).GetMinerStatuses()
 	if err != nil {
 		log.Printf("Failed to get miner statuses from QuestDB: %v", err)
 		c.JSON(http.StatusOK, gin.H{
@@ -658,19 +1393,41 @@ func getMinerStatusHandler(c *gin.Context) {
 		return
 	}
 
-	// Build IP to name map from machines
-	ipToName := make(map[string]string)
-	for _, m := range machines {
-		ipToName[m.IP] = m.Name
+	// Pool state is optional: miners without pool support or history show none
+	var pools map[string]*questdb.MinerPoolSummary
+	if stats, err := questdbFor(c).GetLatestPoolStats(); err != nil {
+		log.Printf("Failed to get pool stats from QuestDB: %v", err)
+	} else {
+		pools = questdb.SummarizePools(stats, poolAlive)
+	}
+
+	// Build IP to machine map
+	ipToMachine := make(map[string]db.Machine)
+	for _, m := range machinesFor(c) {
+		ipToMachine[m.IP] = m
 	}
 
-	// Add names to miner status rows
+	// Add names and maintenance state to miner status rows
 	for i := range result.Miners {
-		if name, ok := ipToName[result.Miners[i].MinerIP]; ok {
-			result.Miners[i].Name = name
-		} else {
+		result.Miners[i].Pool = pools[result.Miners[i].MinerIP]
+		m, ok := ipToMachine[result.Miners[i].MinerIP]
+		if !ok {
 			result.Miners[i].Name = result.Miners[i].MinerIP // fallback to IP
+			continue
+		}
+		result.Miners[i].Name = m.Name
+		if replaying(c) {
+			continue
+		}
+		if p, ok := presence.state(m.IP); ok {
+			result.Miners[i].Network = p.State
 		}
+		if maintenanceActive(m) {
+			result.Miners[i].Maintenance = true
+			result.Miners[i].MaintenanceReason = m.MaintenanceReason
+			result.Miners[i].MaintenanceUntil = m.MaintenanceUntil
+		}
+		result.Miners[i].MaintenanceDue = maintenanceReminders.dueKinds(m.IP)
 	}
 
 	// Sort by name
@@ -683,16 +1440,26 @@ func getMinerStatusHandler(c *gin.Context) {
 
 // MinerManageInfo represents the parsed config and status for a miner on the manage page.
 type MinerManageInfo struct {
-	Name                string   `json:"name"`
-	IP                  string   `json:"ip"`
-	ShellyIP            string   `json:"shellyIp"`
-	Online              bool     `json:"online"`
-	WorkMode            string   `json:"workMode"`
-	ModeSelect          string   `json:"modeSelect"`
-	TargetValue         float64  `json:"targetValue"`
-	TargetFreq          float64  `json:"targetFreq"`
-	TargetVolt          float64  `json:"targetVolt"`
-	ModeSelectAvailable []string `json:"modeSelectAvailable"`
+	Name                string            `json:"name"`
+	IP                  string            `json:"ip"`
+	ShellyIP            string            `json:"shellyIp"`
+	Online              bool              `json:"online"`
+	WorkMode            string            `json:"workMode"`
+	ModeSelect          string            `json:"modeSelect"`
+	TargetValue         float64           `json:"targetValue"`
+	TargetFreq          float64           `json:"targetFreq"`
+	TargetVolt          float64           `json:"targetVolt"`
+	ModeSelectAvailable []string          `json:"modeSelectAvailable"`
+	Firmware            string            `json:"firmware"`
+	FirmwareVersion     string            `json:"firmwareVersion"`
+	Model               string            `json:"model"`
+	MAC                 string            `json:"mac"`
+	NominalHashrate     float64           `json:"nominalHashrate"`   // GH/s, 0 when unknown
+	Profile             string            `json:"profile,omitempty"` // active preset on profile-based firmwares
+	Tuner               *TunerStatus      `json:"tuner,omitempty"`
+	Chains              []ChainInfo       `json:"chains,omitempty"`
+	Capabilities        MinerCapabilities `json:"capabilities"`
+	Error               string            `json:"error,omitempty"` // why the config couldn't be read
 }
 
 // camelToKebab converts PascalCase to kebab-case, e.g. "PowerTarget" -> "power-target".
@@ -711,10 +1478,10 @@ func camelToKebab(s string) string {
 	return string(result)
 }
 
-// fetchMinerConfig calls a miner's kaonsu API and parses the mode section.
-func fetchMinerConfig(ip string) (*MinerManageInfo, error) {
+// fetchKaonsuConfig reads the whole config of a stock firmware miner.
+func fetchKaonsuConfig(ctx context.Context, ip string) (map[string]interface{}, error) {
 	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get(fmt.Sprintf("http://%s/kaonsu/v1/miner_config", ip))
+	resp, err := httpGet(ctx, client, fmt.Sprintf("http://%s/kaonsu/v1/miner_config", urlHost(ip)))
 	if err != nil {
 		return nil, err
 	}
@@ -733,6 +1500,15 @@ func fetchMinerConfig(ip string) (*MinerManageInfo, error) {
 	if err := json.Unmarshal(body, &config); err != nil {
 		return nil, err
 	}
+	return config, nil
+}
+
+// fetchMinerConfig calls a miner's kaonsu API and parses the mode section.
+func fetchMinerConfig(ctx context.Context, ip string) (*MinerManageInfo, error) {
+	config, err := fetchKaonsuConfig(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
 
 	info := &MinerManageInfo{Online: true}
 
@@ -781,7 +1557,82 @@ func fetchMinerConfig(ip string) (*MinerManageInfo, error) {
 	return info, nil
 }
 
-func getManageMinersHandler(c *gin.Context) {
+// Per-source timeouts of the manage page. A source that doesn't answer in
+// time is reported in the response's errors instead of delaying the others.
+const (
+	manageMinerTimeout  = 8 * time.Second // per miner config call
+	manageSourceTimeout = 5 * time.Second // per QuestDB query
+)
+
+// manageSource is one data section of /api/manage/miners.
+type manageSource struct {
+	name    string
+	timeout time.Duration
+	fetch   func(ctx context.Context) (interface{}, error)
+}
+
+// fetchManageSources runs all sources concurrently, each with its own timeout
+// on top of ctx, and returns the data of those that answered in time, and per
+// failed section whether it failed or timed out (details are logged).
+func fetchManageSources(ctx context.Context, sources []manageSource) (map[string]interface{}, map[string]string) {
+	data := make(map[string]interface{}, len(sources))
+	errs := make(map[string]string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, src := range sources {
+		wg.Add(1)
+		go func(src manageSource) {
+			defer wg.Done()
+			type result struct {
+				value interface{}
+				err   error
+			}
+			srcCtx, cancel := context.WithTimeout(ctx, src.timeout)
+			defer cancel()
+			// Buffered so a call that ignores its context can still finish
+			done := make(chan result, 1)
+			go func() {
+				v, err := src.fetch(srcCtx)
+				done <- result{v, err}
+			}()
+
+			var res result
+			msg := "failed"
+			select {
+			case res = <-done:
+			case <-srcCtx.Done():
+				res.err = srcCtx.Err()
+			}
+			if res.err != nil && ctx.Err() != nil {
+				res.err = errors.New("request timed out")
+				msg = res.err.Error()
+			} else if res.err != nil && srcCtx.Err() == context.DeadlineExceeded {
+				res.err = fmt.Errorf("timed out after %s", src.timeout)
+				msg = res.err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if res.err != nil {
+				log.Printf("Manage page: failed to get %s: %v", src.name, res.err)
+				errs[src.name] = msg
+				data[src.name] = nil
+				return
+			}
+			data[src.name] = res.value
+		}(src)
+	}
+
+	wg.Wait()
+	return data, errs
+}
+
+// manageMinerConfigs reads the config of every miner in parallel. A miner that
+// fails or doesn't answer within manageMinerTimeout is listed offline with
+// its error.
+func manageMinerConfigs(ctx context.Context) []MinerManageInfo {
+	machines := currentMachines()
 	results := make([]MinerManageInfo, len(machines))
 	var wg sync.WaitGroup
 
@@ -789,55 +1640,68 @@ func getManageMinersHandler(c *gin.Context) {
 		wg.Add(1)
 		go func(idx int, machine db.Machine) {
 			defer wg.Done()
-			info, err := fetchMinerConfig(machine.IP)
+			driver := driverFor(machine.IP)
+			minerCtx, cancel := context.WithTimeout(ctx, manageMinerTimeout)
+			defer cancel()
+			info, err := driver.Config(minerCtx, machine.IP)
+			if err != nil && ctx.Err() != nil {
+				err = errors.New("request timed out")
+			} else if err != nil && minerCtx.Err() == context.DeadlineExceeded {
+				err = fmt.Errorf("timed out after %s", manageMinerTimeout)
+			}
 			if err != nil {
 				log.Printf("Failed to fetch config for %s (%s): %v", machine.Name, machine.IP, err)
-				results[idx] = MinerManageInfo{
-					Name:     machine.Name,
-					IP:       machine.IP,
-					ShellyIP: machine.ShellyIP,
-					Online:   false,
-				}
-				return
+				info = &MinerManageInfo{Online: false, Error: err.Error()}
 			}
 			info.Name = machine.Name
 			info.IP = machine.IP
 			info.ShellyIP = machine.ShellyIP
+			info.Firmware = machine.Firmware
+			info.FirmwareVersion = machine.FirmwareVersion
+			info.Model = machine.Model
+			info.MAC = machine.MAC
+			info.NominalHashrate = machine.NominalHashrate
+			info.Capabilities = driver.Capabilities()
 			results[idx] = *info
 		}(i, m)
 	}
 
 	wg.Wait()
+	return results
+}
 
-	shelliesData, err := questdbClient.GetShelliesPower()
-	if err != nil {
-		log.Printf("Failed to get shellies power: %v", err)
-		shelliesData = &questdb.ShelliesPowerData{HasData: false}
-	}
-
-	minerStatuses, err := questdbClient.GetMinerStatuses()
-	if err != nil {
-		log.Printf("Failed to get miner statuses: %v", err)
-	}
+// getManageMinersHandler returns the miner configs and the QuestDB data of the
+// manage page. All sources are fetched concurrently; a failed or slow source
+// leaves its section null and is listed in errors, so the page can render the
+// rest.
+func getManageMinersHandler(c *gin.Context) {
+	data, errs := fetchManageSources(c.Request.Context(), []manageSource{
+		{name: "miners", timeout: manageMinerTimeout + time.Second, fetch: func(ctx context.Context) (interface{}, error) {
+			return manageMinerConfigs(ctx), nil
+		}},
+		{name: "shellies", timeout: manageSourceTimeout, fetch: func(ctx context.Context) (interface{}, error) {
+			return storeWith(ctx).GetShelliesPower()
+		}},
+		{name: "minerStatuses", timeout: manageSourceTimeout, fetch: func(ctx context.Context) (interface{}, error) {
+			return storeWith(ctx).GetMinerStatuses()
+		}},
+		{name: "hashboardsDetailed", timeout: manageSourceTimeout, fetch: func(ctx context.Context) (interface{}, error) {
+			return storeWith(ctx).GetHashboardsDetailed()
+		}},
+	})
 
-	hashboardsDetailed, err := questdbClient.GetHashboardsDetailed()
-	if err != nil {
-		log.Printf("Failed to get hashboards detailed: %v", err)
-		hashboardsDetailed = &questdb.HashboardDetailedData{HasData: false}
+	response := gin.H{"errors": errs}
+	for name, v := range data {
+		response[name] = v
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"miners":             results,
-		"shellies":           shelliesData,
-		"minerStatuses":      minerStatuses,
-		"hashboardsDetailed": hashboardsDetailed,
-	})
+	c.JSON(http.StatusOK, response)
 }
 
 func environmentHandler(c *gin.Context) {
 	data := gin.H{
 		"Title":      "Mining Dashboard",
 		"ShowManage": c.GetBool("ShowManage"),
+		"Lang":       localeFor(c).Lang,
 	}
 	c.HTML(http.StatusOK, "environment.html", data)
 }
@@ -849,7 +1713,7 @@ func minersHandler(c *gin.Context) {
 	activeMiners := 0
 
 	// Count active miners: those with a miner_status record in the last 2 minutes
-	statusResult, err := questdbClient.GetMinerStatuses()
+	statusResult, err := storeFor(c).GetMinerStatuses()
 	if err != nil {
 		log.Printf("Failed to get miner statuses: %v", err)
 	} else if statusResult.HasData {
@@ -860,21 +1724,21 @@ func minersHandler(c *gin.Context) {
 		}
 	}
 
-	result, err := questdbClient.GetTotalHashrate()
+	result, err := storeFor(c).GetTotalHashrate()
 	if err != nil {
 		log.Printf("Failed to get hashrate from QuestDB: %v", err)
 	} else if result.HasData {
 		hashrate = result.TotalHashrate / 1000 // GH/s to TH/s
 	}
 
-	powerResult, err := questdbClient.GetTotalPower()
+	powerResult, err := storeFor(c).GetTotalPower()
 	if err != nil {
 		log.Printf("Failed to get power from QuestDB: %v", err)
 	} else if powerResult.HasData {
 		power = powerResult.TotalPower
 	}
 
-	avgTempResult, err := questdbClient.GetAvgMaxTemperature()
+	avgTempResult, err := storeFor(c).GetAvgMaxTemperature()
 	if err != nil {
 		log.Printf("Failed to get avg max temperature from QuestDB: %v", err)
 	} else if avgTempResult.HasData {
@@ -891,18 +1755,21 @@ func minersHandler(c *gin.Context) {
 	efficiency = math.Round(efficiency*10) / 10
 	avgTemp = math.Round(avgTemp*10) / 10
 
+	loc := localeFor(c)
 	data := gin.H{
 		"Title":      "Mining Dashboard",
-		"Machines":   machines,
+		"Machines":   machinesFor(c),
 		"ShowManage": c.GetBool("ShowManage"),
-		"Metrics": []gin.H{
+		"Owner":      ownerFor(c),
+		"Lang":       loc.Lang,
+		"Metrics": localizeGauges(loc, []gin.H{
 			{"Label": "Active Miners", "Value": activeMiners, "Unit": "online", "Color": "success"},
 			{"Label": "Total Hashrate", "Value": hashrate, "Unit": "TH/s", "Color": "primary"},
 			{"Label": "Total Power", "Value": power, "Unit": "W", "Color": "warning"},
-			{"Label": "Efficiency", "Value": efficiency, "Unit": "J/TH", "Color": "info"},
-			{"Label": "Avg Temperature", "Value": avgTemp, "Unit": "°C", "Color": "danger"},
+			{"Label": "Efficiency", "Value": efficiency, "Unit": "J/TH", "Color": "info", "Decimals": 1},
+			{"Label": "Avg Temperature", "Value": avgTemp, "Unit": "°C", "Color": "danger", "Decimals": 1, "Temperature": true},
 			{"Label": "Uptime", "Value": "99.8", "Unit": "%", "Color": "secondary"},
-		},
+		}),
 	}
 	c.HTML(http.StatusOK, "miners.html", data)
 }
@@ -910,8 +1777,9 @@ func minersHandler(c *gin.Context) {
 func manageHandler(c *gin.Context) {
 	data := gin.H{
 		"Title":      "Mining Dashboard",
-		"Machines":   machines,
+		"Machines":   currentMachines(),
 		"ShowManage": true,
+		"Lang":       localeFor(c).Lang,
 	}
 	c.HTML(http.StatusOK, "manage.html", data)
 }
@@ -919,8 +1787,9 @@ func manageHandler(c *gin.Context) {
 func settingsHandler(c *gin.Context) {
 	data := gin.H{
 		"Title":      "Mining Dashboard",
-		"Machines":   machines,
+		"Machines":   currentMachines(),
 		"ShowManage": true,
+		"Lang":       localeFor(c).Lang,
 	}
 	c.HTML(http.StatusOK, "settings.html", data)
 }
@@ -931,7 +1800,7 @@ func powerMiningHandler(c *gin.Context) {
 	hashrate := 0.0
 	power := 0.0
 
-	result, err := questdbClient.GetTotalHashrate()
+	result, err := storeFor(c).GetTotalHashrate()
 	if err != nil {
 		log.Printf("Failed to get hashrate from QuestDB: %v", err)
 	} else if result.HasData {
@@ -944,7 +1813,7 @@ func powerMiningHandler(c *gin.Context) {
 		hashrate = result.TotalHashrate / 1000 // GH/s to TH/s
 	}
 
-	powerResult, err := questdbClient.GetTotalPower()
+	powerResult, err := storeFor(c).GetTotalPower()
 	if err != nil {
 		log.Printf("Failed to get power from QuestDB: %v", err)
 	} else if powerResult.HasData {
@@ -957,27 +1826,32 @@ func powerMiningHandler(c *gin.Context) {
 	}
 
 	revenue := calculateDailyRevenueEUR(hashrate)
-	elecCost := math.Round(power/1000*24*0.23*100) / 100
+	elecCost := math.Round(power/1000*24*elecPrice*100) / 100
 
 	hashrate = math.Round(hashrate)
 	efficiency = math.Round(efficiency*10) / 10
 	power = math.Round(power)
 
+	loc := localeFor(c)
 	data := gin.H{
 		"Title":      "Mining Dashboard",
-		"Machines":   machines,
+		"Machines":   currentMachines(),
 		"ShowManage": c.GetBool("ShowManage"),
+		"Lang":       loc.Lang,
 		"Status": gin.H{
 			"Online": online,
-			"Label":  statusLabel,
+			"Label":  loc.T(statusLabel),
 		},
-		"Gauges": []gin.H{
+		"Gauges": localizeGauges(loc, []gin.H{
 			{"Label": "Total Power", "Value": power, "Unit": "W"},
 			{"Label": "Hashrate", "Value": hashrate, "Unit": "TH/s"},
-			{"Label": "Efficiency", "Value": efficiency, "Unit": "J/TH"},
-			{"Label": "Elec. Cost", "Value": elecCost, "Unit": "€/day"},
-			{"Label": "Revenue", "Value": revenue, "Unit": "€/day"},
-		},
+			{"Label": "Efficiency", "Value": efficiency, "Unit": "J/TH", "Decimals": 1},
+			{"Label": "Elec. Cost", "Value": elecCost, "Unit": "€/day", "Money": true},
+			{"Label": "Revenue", "Value": revenue, "Unit": "€/day", "Money": true},
+		}),
+	}
+	if altCoin != "" {
+		data["Alt"] = altOverview(c, loc)
 	}
 	c.HTML(http.StatusOK, "power-mining.html", data)
 }
@@ -987,7 +1861,10 @@ func powerMiningHandler(c *gin.Context) {
 type AddMachineRequest struct {
 	Name     string `json:"name" binding:"required"`
 	IP       string `json:"ip" binding:"required"`
+	MAC      string `json:"mac"` // captured from the ARP table if empty
 	ShellyIP string `json:"shellyIp"`
+	Outlet   string `json:"outlet"` // outlet controller URL instead of a Shelly
+	Firmware string `json:"firmware"`
 }
 
 func addMachineHandler(c *gin.Context) {
@@ -997,17 +1874,62 @@ func addMachineHandler(c *gin.Context) {
 		return
 	}
 
-	if err := database.AddMachine(req.Name, req.IP, req.ShellyIP); err != nil {
+	ip, err := normalizeAddress(req.IP)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.IP = ip
+	if req.ShellyIP != "" {
+		shellyIP, err := normalizeAddress(req.ShellyIP)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "shelly: " + err.Error()})
+			return
+		}
+		req.ShellyIP = shellyIP
+	}
+	if req.Firmware == "" {
+		req.Firmware = "kaonsu"
+	}
+	if _, ok := drivers[req.Firmware]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown firmware: " + req.Firmware})
+		return
+	}
+	if req.MAC != "" {
+		mac, err := normalizeMAC(req.MAC)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		req.MAC = mac
+	}
+	if req.Outlet != "" {
+		if _, err := parseOutlet(req.Outlet); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := database.AddMachine(req.Name, req.IP, req.ShellyIP, req.Firmware); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add machine"})
 		return
 	}
+	if req.Outlet != "" {
+		if err := database.UpdateMachineOutlet(req.IP, req.Outlet); err != nil {
+			log.Printf("Failed to store outlet of %s: %v", req.IP, err)
+		}
+	}
+	if req.MAC != "" {
+		if err := database.SetMachineMAC(req.IP, req.MAC); err != nil {
+			log.Printf("Failed to store MAC of %s: %v", req.IP, err)
+		}
+	} else {
+		go captureMAC(req.IP)
+	}
+	go identifyNewMachine(req.IP)
 
 	// Refresh machines list
-	var err error
-	machines, err = database.FetchMachines()
-	if err != nil {
-		log.Printf("Failed to refresh machines: %v", err)
-	}
+	refreshMachines()
 
 	log.Printf("Added machine %s (%s)", req.Name, req.IP)
 	c.JSON(http.StatusOK, gin.H{
@@ -1018,7 +1940,7 @@ func addMachineHandler(c *gin.Context) {
 }
 
 func deleteMachineHandler(c *gin.Context) {
-	ip := c.Param("ip")
+	ip := ipParam(c)
 	if ip == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "IP address required"})
 		return
@@ -1028,13 +1950,12 @@ func deleteMachineHandler(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete machine"})
 		return
 	}
+	reconcile.mu.Lock()
+	delete(reconcile.drift, ip)
+	reconcile.mu.Unlock()
 
 	// Refresh machines list
-	var err error
-	machines, err = database.FetchMachines()
-	if err != nil {
-		log.Printf("Failed to refresh machines: %v", err)
-	}
+	refreshMachines()
 
 	log.Printf("Deleted machine with IP %s", ip)
 	c.JSON(http.StatusOK, gin.H{
@@ -1043,6 +1964,38 @@ func deleteMachineHandler(c *gin.Context) {
 	})
 }
 
+type MachineFirmwareRequest struct {
+	Firmware string `json:"firmware" binding:"required"`
+}
+
+// setMachineFirmwareHandler switches the driver used for a machine.
+func setMachineFirmwareHandler(c *gin.Context) {
+	ip := ipParam(c)
+	var req MachineFirmwareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, ok := drivers[req.Firmware]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown firmware: " + req.Firmware})
+		return
+	}
+
+	if err := database.UpdateMachineFirmware(ip, req.Firmware); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update machine"})
+		return
+	}
+
+	refreshMachines()
+
+	log.Printf("Set firmware of %s to %s", ip, req.Firmware)
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"ip":       ip,
+		"firmware": req.Firmware,
+	})
+}
+
 // HTTP Digest Authentication helpers
 
 func md5Hash(s string) string {
@@ -1096,11 +2049,11 @@ func parseDigestChallenge(header string) map[string]string {
 // doDigestPost sends a POST request with HTTP Digest Authentication.
 // It first attempts the request unauthenticated, and on a 401 computes the
 // digest response from the server's challenge and retries.
-func doDigestPost(url, username, password string, body []byte) (*http.Response, error) {
+func doDigestPost(ctx context.Context, url, username, password string, body []byte) (*http.Response, error) {
 	client := &http.Client{Timeout: 10 * time.Second}
 
 	// Step 1: send without auth to get the challenge
-	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
@@ -1147,7 +2100,7 @@ func doDigestPost(url, username, password string, body []byte) (*http.Response,
 	)
 
 	// Step 4: retry with Authorization
-	req2, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	req2, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
@@ -1159,12 +2112,12 @@ func doDigestPost(url, username, password string, body []byte) (*http.Response,
 
 // setMinerPowerTarget GETs the current config from a miner, sets the power target,
 // and POSTs it back using HTTP Digest Auth.
-func setMinerPowerTarget(ip string, power int) error {
-	configURL := fmt.Sprintf("http://%s/kaonsu/v1/miner_config", ip)
+func setMinerPowerTarget(ctx context.Context, ip string, power int) error {
+	configURL := fmt.Sprintf("http://%s/kaonsu/v1/miner_config", urlHost(ip))
 
 	// GET current config
 	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(configURL)
+	resp, err := httpGet(ctx, client, configURL)
 	if err != nil {
 		return fmt.Errorf("failed to get config: %w", err)
 	}
@@ -1203,7 +2156,7 @@ func setMinerPowerTarget(ip string, power int) error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	postResp, err := doDigestPost(configURL, minerUser, minerPass, modifiedBody)
+	postResp, err := doDigestPost(ctx, configURL, minerUser, minerPass, modifiedBody)
 	if err != nil {
 		return fmt.Errorf("failed to post config: %w", err)
 	}
@@ -1219,46 +2172,91 @@ func setMinerPowerTarget(ip string, power int) error {
 
 // Shelly Pro 1PM relay control (Gen2 RPC API)
 
-// shellyIPForMiner looks up the Shelly IP associated with a miner IP.
-func shellyIPForMiner(minerIP string) string {
-	for _, m := range machines {
-		if m.IP == minerIP {
-			return m.ShellyIP
-		}
+// shellySwitch is the status of a Shelly Gen2 switch.
+type shellySwitch struct {
+	Output bool    `json:"output"`
+	APower float64 `json:"apower"` // W
+}
+
+// getShellySwitch returns the relay state and active power of a Shelly switch.
+func getShellySwitch(ctx context.Context, shellyIP string) (*shellySwitch, error) {
+	url := fmt.Sprintf("http://%s/rpc/Switch.GetStatus?id=0", urlHost(shellyIP))
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := httpGet(ctx, client, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach shelly at %s: %w", shellyIP, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("shelly %s returned status %d: %s", shellyIP, resp.StatusCode, string(body))
 	}
-	return ""
+
+	var status shellySwitch
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("failed to decode shelly status: %w", err)
+	}
+
+	return &status, nil
 }
 
 // getShellyStatus returns the current on/off state of a Shelly switch.
-func getShellyStatus(shellyIP string) (bool, error) {
-	url := fmt.Sprintf("http://%s/rpc/Switch.GetStatus?id=0", shellyIP)
+func getShellyStatus(ctx context.Context, shellyIP string) (bool, error) {
+	status, err := getShellySwitch(ctx, shellyIP)
+	if err != nil {
+		return false, err
+	}
+	return status.Output, nil
+}
+
+// shellyDeviceIDs caches Shelly IP to device ID lookups. The device ID is the
+// MQTT topic prefix and therefore the device_id tag in the shellies table.
+var (
+	shellyDeviceIDsMu sync.Mutex
+	shellyDeviceIDs   = make(map[string]string)
+)
+
+// shellyDeviceID returns the device ID reported by the Shelly at shellyIP.
+func shellyDeviceID(shellyIP string) (string, error) {
+	shellyDeviceIDsMu.Lock()
+	id, ok := shellyDeviceIDs[shellyIP]
+	shellyDeviceIDsMu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	url := fmt.Sprintf("http://%s/rpc/Shelly.GetDeviceInfo", urlHost(shellyIP))
 	client := &http.Client{Timeout: 5 * time.Second}
 	resp, err := client.Get(url)
 	if err != nil {
-		return false, fmt.Errorf("failed to reach shelly at %s: %w", shellyIP, err)
+		return "", fmt.Errorf("failed to reach shelly at %s: %w", shellyIP, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return false, fmt.Errorf("shelly %s returned status %d: %s", shellyIP, resp.StatusCode, string(body))
+		return "", fmt.Errorf("shelly %s returned status %d: %s", shellyIP, resp.StatusCode, string(body))
 	}
 
-	var status struct {
-		Output bool `json:"output"`
+	var info struct {
+		ID string `json:"id"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
-		return false, fmt.Errorf("failed to decode shelly status: %w", err)
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", fmt.Errorf("failed to decode shelly device info: %w", err)
 	}
 
-	return status.Output, nil
+	shellyDeviceIDsMu.Lock()
+	shellyDeviceIDs[shellyIP] = info.ID
+	shellyDeviceIDsMu.Unlock()
+	return info.ID, nil
 }
 
 // toggleShelly sends a toggle command to a Shelly switch.
-func toggleShelly(shellyIP string) error {
-	url := fmt.Sprintf("http://%s/rpc/Switch.Toggle?id=0", shellyIP)
+func toggleShelly(ctx context.Context, shellyIP string) error {
+	url := fmt.Sprintf("http://%s/rpc/Switch.Toggle?id=0", urlHost(shellyIP))
 	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get(url)
+	resp, err := httpGet(ctx, client, url)
 	if err != nil {
 		return fmt.Errorf("failed to reach shelly at %s: %w", shellyIP, err)
 	}
@@ -1273,8 +2271,10 @@ func toggleShelly(shellyIP string) error {
 
 // controlShelly turns a Shelly Pro 1PM relay on or off via its Gen2 RPC API.
 // It first checks the current state and only toggles if needed.
-func controlShelly(shellyIP string, on bool) error {
-	currentState, err := getShellyStatus(shellyIP)
+func controlShelly(ctx context.Context, shellyIP string, on bool) error {
+	shellyWatch.request(shellyIP, on)
+
+	currentState, err := getShellyStatus(ctx, shellyIP)
 	if err != nil {
 		return err
 	}
@@ -1286,7 +2286,7 @@ func controlShelly(shellyIP string, on bool) error {
 		return nil
 	}
 
-	return toggleShelly(shellyIP)
+	return toggleShelly(ctx, shellyIP)
 }
 
 // Individual miner control handlers
@@ -1294,19 +2294,37 @@ func controlShelly(shellyIP string, on bool) error {
 type MinerPowerRequest struct {
 	IP    string `json:"ip"`
 	Power int    `json:"power"`
+	PowerRamp
 }
 
 type MinerRequest struct {
 	IP string `json:"ip"`
 }
 
+// Bulk requests target either an explicit IP list or all members of a group.
+// With DryRun set, the handlers return the planned changes without applying them.
+
 type BulkPowerRequest struct {
-	IPs   []string `json:"ips"`
-	Power int      `json:"power"`
+	IPs     []string `json:"ips"`
+	GroupID int64    `json:"groupId"`
+	Power   int      `json:"power"`
+	DryRun  bool     `json:"dryRun"`
+	PowerRamp
+
+	// IncludeMaintenance also targets miners in maintenance
+	IncludeMaintenance bool `json:"includeMaintenance"`
 }
 
 type BulkMinerRequest struct {
-	IPs []string `json:"ips"`
+	IPs     []string `json:"ips"`
+	GroupID int64    `json:"groupId"`
+	DryRun  bool     `json:"dryRun"`
+
+	// IncludeMaintenance also targets miners in maintenance
+	IncludeMaintenance bool `json:"includeMaintenance"`
+
+	// GapSeconds spaces relay switching of a start (default --start-gap-seconds)
+	GapSeconds *int `json:"gapSeconds,omitempty"`
 }
 
 func setMinerPowerHandler(c *gin.Context) {
@@ -1316,17 +2334,33 @@ func setMinerPowerHandler(c *gin.Context) {
 		return
 	}
 
-	if err := setMinerPowerTarget(req.IP, req.Power); err != nil {
+	if err := req.PowerRamp.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	power, err := guardPower(req.IP, req.Power)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A ramp outlasts the request, so it runs as a job
+	if step, _ := req.PowerRamp.settings(); step > 0 {
+		respondJobQueued(c, "power", BulkPowerRequest{IPs: []string{req.IP}, Power: req.Power, PowerRamp: req.PowerRamp}, []string{req.IP})
+		return
+	}
+
+	if err := driverFor(req.IP).SetPowerTarget(c.Request.Context(), req.IP, power); err != nil {
 		log.Printf("Failed to set power for %s: %v", req.IP, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	log.Printf("Set power to %d W for miner at %s", req.Power, req.IP)
+	log.Printf("Set power to %d W for miner at %s", power, req.IP)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"ip":      req.IP,
-		"power":   req.Power,
+		"power":   power,
 	})
 }
 
@@ -1337,19 +2371,32 @@ func startMinerHandler(c *gin.Context) {
 		return
 	}
 
-	shellyIP := shellyIPForMiner(req.IP)
-	if shellyIP == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "no shelly configured for " + req.IP})
+	outlet, err := outletForMiner(req.IP)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%v for %s", err, req.IP)})
+		return
+	}
+
+	if emergency.locked() {
+		recordEvent("emergency", "blocked start of miner %s", req.IP)
+		c.JSON(http.StatusConflict, gin.H{"error": "emergency lockout active"})
+		return
+	}
+
+	if condensation.blocksColdStart() {
+		recordEvent("condensation", "blocked start of miner %s", req.IP)
+		c.JSON(http.StatusConflict, gin.H{"error": "condensation risk: miner start paused"})
 		return
 	}
 
-	if err := controlShelly(shellyIP, true); err != nil {
-		log.Printf("Failed to start miner %s via shelly %s: %v", req.IP, shellyIP, err)
+	if err := outlet.Set(c.Request.Context(), true); err != nil {
+		log.Printf("Failed to start miner %s via outlet %s: %v", req.IP, outlet.Key(), err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	log.Printf("Started miner at %s (shelly %s)", req.IP, shellyIP)
+	log.Printf("Started miner at %s (outlet %s)", req.IP, outlet.Key())
+	go measureInrush(req.IP, outlet, time.Now())
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"ip":      req.IP,
@@ -1363,19 +2410,19 @@ func shutdownMinerHandler(c *gin.Context) {
 		return
 	}
 
-	shellyIP := shellyIPForMiner(req.IP)
-	if shellyIP == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "no shelly configured for " + req.IP})
+	outlet, err := outletForMiner(req.IP)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%v for %s", err, req.IP)})
 		return
 	}
 
-	if err := controlShelly(shellyIP, false); err != nil {
-		log.Printf("Failed to shutdown miner %s via shelly %s: %v", req.IP, shellyIP, err)
+	if err := outlet.Set(c.Request.Context(), false); err != nil {
+		log.Printf("Failed to shutdown miner %s via outlet %s: %v", req.IP, outlet.Key(), err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	log.Printf("Shutdown miner at %s (shelly %s)", req.IP, shellyIP)
+	log.Printf("Shutdown miner at %s (outlet %s)", req.IP, outlet.Key())
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"ip":      req.IP,
@@ -1391,43 +2438,47 @@ func setAllMinersPowerHandler(c *gin.Context) {
 		return
 	}
 
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	var failed []string
-
-	for _, ip := range req.IPs {
-		wg.Add(1)
-		go func(minerIP string) {
-			defer wg.Done()
-			if err := setMinerPowerTarget(minerIP, req.Power); err != nil {
-				log.Printf("Failed to set power for %s: %v", minerIP, err)
-				mu.Lock()
-				failed = append(failed, minerIP)
-				mu.Unlock()
-			} else {
-				log.Printf("Set power to %d W for miner at %s", req.Power, minerIP)
-			}
-		}(ip)
+	ips, err := resolveBulkIPs(req.IPs, req.GroupID, req.IncludeMaintenance)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
+	req.IPs = ips
 
-	wg.Wait()
+	if err := req.PowerRamp.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": len(failed) == 0,
-		"power":   req.Power,
-		"ips":     req.IPs,
-		"count":   len(req.IPs),
-		"failed":  failed,
+	warnings, ok := checkLimits(c, req.IPs, req.DryRun, func(l operatingLimits, ip string) string {
+		_, violation := l.power(ip, req.Power)
+		return violation
 	})
+	if !ok {
+		return
+	}
+
+	if req.DryRun {
+		respondDryRun(c, req.IPs, func(ip string) PlannedChange {
+			return planConfigChange(c.Request.Context(), ip, map[string]interface{}{
+				"workMode":    "Auto",
+				"modeSelect":  "PowerTarget",
+				"targetValue": req.Power,
+			})
+		}, warnings...)
+		return
+	}
+
+	respondJobQueued(c, "power", req, req.IPs)
 }
 
 // setMinerFreqVolt GETs the current config, sets work-mode-selector to "Fixed"
 // and writes freq/volt into the fixed section, then POSTs with digest auth.
-func setMinerFreqVolt(ip string, freq float64, volt float64) error {
-	configURL := fmt.Sprintf("http://%s/kaonsu/v1/miner_config", ip)
+func setMinerFreqVolt(ctx context.Context, ip string, freq float64, volt float64) error {
+	configURL := fmt.Sprintf("http://%s/kaonsu/v1/miner_config", urlHost(ip))
 
 	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(configURL)
+	resp, err := httpGet(ctx, client, configURL)
 	if err != nil {
 		return fmt.Errorf("failed to get config: %w", err)
 	}
@@ -1464,7 +2515,7 @@ func setMinerFreqVolt(ip string, freq float64, volt float64) error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	postResp, err := doDigestPost(configURL, minerUser, minerPass, modifiedBody)
+	postResp, err := doDigestPost(ctx, configURL, minerUser, minerPass, modifiedBody)
 	if err != nil {
 		return fmt.Errorf("failed to post config: %w", err)
 	}
@@ -1479,18 +2530,23 @@ func setMinerFreqVolt(ip string, freq float64, volt float64) error {
 }
 
 type BulkFreqVoltRequest struct {
-	IPs  []string `json:"ips"`
-	Freq float64  `json:"freq"`
-	Volt float64  `json:"volt"`
+	IPs     []string `json:"ips"`
+	GroupID int64    `json:"groupId"`
+	Freq    float64  `json:"freq"`
+	Volt    float64  `json:"volt"`
+	DryRun  bool     `json:"dryRun"`
+
+	// IncludeMaintenance also targets miners in maintenance
+	IncludeMaintenance bool `json:"includeMaintenance"`
 }
 
 // setMinerSleepMode GETs the current config, sets work-mode-selector to "Sleep",
 // then POSTs with digest auth.
-func setMinerSleepMode(ip string) error {
-	configURL := fmt.Sprintf("http://%s/kaonsu/v1/miner_config", ip)
+func setMinerSleepMode(ctx context.Context, ip string) error {
+	configURL := fmt.Sprintf("http://%s/kaonsu/v1/miner_config", urlHost(ip))
 
 	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(configURL)
+	resp, err := httpGet(ctx, client, configURL)
 	if err != nil {
 		return fmt.Errorf("failed to get config: %w", err)
 	}
@@ -1518,7 +2574,7 @@ func setMinerSleepMode(ip string) error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	postResp, err := doDigestPost(configURL, minerUser, minerPass, modifiedBody)
+	postResp, err := doDigestPost(ctx, configURL, minerUser, minerPass, modifiedBody)
 	if err != nil {
 		return fmt.Errorf("failed to post config: %w", err)
 	}
@@ -1539,35 +2595,33 @@ func setAllMinersFreqVoltHandler(c *gin.Context) {
 		return
 	}
 
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	var failed []string
+	ips, err := resolveBulkIPs(req.IPs, req.GroupID, req.IncludeMaintenance)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.IPs = ips
 
-	for _, ip := range req.IPs {
-		wg.Add(1)
-		go func(minerIP string) {
-			defer wg.Done()
-			if err := setMinerFreqVolt(minerIP, req.Freq, req.Volt); err != nil {
-				log.Printf("Failed to set freq/volt for %s: %v", minerIP, err)
-				mu.Lock()
-				failed = append(failed, minerIP)
-				mu.Unlock()
-			} else {
-				log.Printf("Set freq=%.0f MHz volt=%.1f V for miner at %s", req.Freq, req.Volt, minerIP)
-			}
-		}(ip)
+	warnings, ok := checkLimits(c, req.IPs, req.DryRun, func(l operatingLimits, ip string) string {
+		_, _, violation := l.freqVolt(ip, req.Freq, req.Volt)
+		return violation
+	})
+	if !ok {
+		return
 	}
 
-	wg.Wait()
+	if req.DryRun {
+		respondDryRun(c, req.IPs, func(ip string) PlannedChange {
+			return planConfigChange(c.Request.Context(), ip, map[string]interface{}{
+				"workMode": "Fixed",
+				"freq":     req.Freq,
+				"volt":     req.Volt,
+			})
+		}, warnings...)
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": len(failed) == 0,
-		"freq":    req.Freq,
-		"volt":    req.Volt,
-		"ips":     req.IPs,
-		"count":   len(req.IPs),
-		"failed":  failed,
-	})
+	respondJobQueued(c, "freqvolt", req, req.IPs)
 }
 
 func setAllMinersSleepHandler(c *gin.Context) {
@@ -1577,33 +2631,21 @@ func setAllMinersSleepHandler(c *gin.Context) {
 		return
 	}
 
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	var failed []string
-
-	for _, ip := range req.IPs {
-		wg.Add(1)
-		go func(minerIP string) {
-			defer wg.Done()
-			if err := setMinerSleepMode(minerIP); err != nil {
-				log.Printf("Failed to set sleep mode for %s: %v", minerIP, err)
-				mu.Lock()
-				failed = append(failed, minerIP)
-				mu.Unlock()
-			} else {
-				log.Printf("Set sleep mode for miner at %s", minerIP)
-			}
-		}(ip)
+	ips, err := resolveBulkIPs(req.IPs, req.GroupID, req.IncludeMaintenance)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
+	req.IPs = ips
 
-	wg.Wait()
+	if req.DryRun {
+		respondDryRun(c, req.IPs, func(ip string) PlannedChange {
+			return planConfigChange(c.Request.Context(), ip, map[string]interface{}{"workMode": "Sleep"})
+		})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": len(failed) == 0,
-		"ips":     req.IPs,
-		"count":   len(req.IPs),
-		"failed":  failed,
-	})
+	respondJobQueued(c, "sleep", req, req.IPs)
 }
 
 func startAllMinersHandler(c *gin.Context) {
@@ -1613,41 +2655,45 @@ func startAllMinersHandler(c *gin.Context) {
 		return
 	}
 
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	var failed []string
+	ips, err := resolveBulkIPs(req.IPs, req.GroupID, req.IncludeMaintenance)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.IPs = ips
 
-	for _, ip := range req.IPs {
-		wg.Add(1)
-		go func(minerIP string) {
-			defer wg.Done()
-			shellyIP := shellyIPForMiner(minerIP)
-			if shellyIP == "" {
-				log.Printf("No shelly configured for %s", minerIP)
-				mu.Lock()
-				failed = append(failed, minerIP)
-				mu.Unlock()
-				return
-			}
-			if err := controlShelly(shellyIP, true); err != nil {
-				log.Printf("Failed to start miner %s via shelly %s: %v", minerIP, shellyIP, err)
-				mu.Lock()
-				failed = append(failed, minerIP)
-				mu.Unlock()
-			} else {
-				log.Printf("Started miner at %s (shelly %s)", minerIP, shellyIP)
-			}
-		}(ip)
+	if req.GapSeconds != nil && (*req.GapSeconds < 0 || *req.GapSeconds > 600) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "gapSeconds must be between 0 and 600"})
+		return
 	}
 
-	wg.Wait()
+	if req.DryRun {
+		var warnings []string
+		if emergency.locked() {
+			warnings = append(warnings, "emergency lockout active")
+		}
+		if condensation.blocksColdStart() {
+			warnings = append(warnings, "condensation risk: miner start paused")
+		}
+		respondDryRun(c, req.IPs, func(ip string) PlannedChange {
+			return planRelayChange(c.Request.Context(), ip, true)
+		}, warnings...)
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": len(failed) == 0,
-		"ips":     req.IPs,
-		"count":   len(req.IPs),
-		"failed":  failed,
-	})
+	if emergency.locked() {
+		recordEvent("emergency", "blocked start of miners %s", strings.Join(req.IPs, ", "))
+		c.JSON(http.StatusConflict, gin.H{"error": "emergency lockout active"})
+		return
+	}
+
+	if condensation.blocksColdStart() {
+		recordEvent("condensation", "blocked start of miners %s", strings.Join(req.IPs, ", "))
+		c.JSON(http.StatusConflict, gin.H{"error": "condensation risk: miner start paused"})
+		return
+	}
+
+	respondJobQueued(c, "start", req, req.IPs)
 }
 
 func shutdownAllMinersHandler(c *gin.Context) {
@@ -1657,39 +2703,19 @@ func shutdownAllMinersHandler(c *gin.Context) {
 		return
 	}
 
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	var failed []string
-
-	for _, ip := range req.IPs {
-		wg.Add(1)
-		go func(minerIP string) {
-			defer wg.Done()
-			shellyIP := shellyIPForMiner(minerIP)
-			if shellyIP == "" {
-				log.Printf("No shelly configured for %s", minerIP)
-				mu.Lock()
-				failed = append(failed, minerIP)
-				mu.Unlock()
-				return
-			}
-			if err := controlShelly(shellyIP, false); err != nil {
-				log.Printf("Failed to shutdown miner %s via shelly %s: %v", minerIP, shellyIP, err)
-				mu.Lock()
-				failed = append(failed, minerIP)
-				mu.Unlock()
-			} else {
-				log.Printf("Shutdown miner at %s (shelly %s)", minerIP, shellyIP)
-			}
-		}(ip)
+	ips, err := resolveBulkIPs(req.IPs, req.GroupID, req.IncludeMaintenance)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
+	req.IPs = ips
 
-	wg.Wait()
+	if req.DryRun {
+		respondDryRun(c, req.IPs, func(ip string) PlannedChange {
+			return planRelayChange(c.Request.Context(), ip, false)
+		})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": len(failed) == 0,
-		"ips":     req.IPs,
-		"count":   len(req.IPs),
-		"failed":  failed,
-	})
+	respondJobQueued(c, "shutdown", req, req.IPs)
 }
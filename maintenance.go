@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Miners in maintenance don't raise offline or temperature alerts, count
+// towards uptime or take part in bulk actions unless includeMaintenance is set.
+
+// maintenanceActive reports whether a machine is in maintenance now. An
+// expired end time ends maintenance without a database update.
+func maintenanceActive(m db.Machine) bool {
+	return m.Maintenance && (m.MaintenanceUntil == nil || time.Now().Before(*m.MaintenanceUntil))
+}
+
+// inMaintenance reports whether the miner with the given IP is in maintenance.
+func inMaintenance(ip string) bool {
+	for _, m := range currentMachines() {
+		if m.IP == ip {
+			return maintenanceActive(m)
+		}
+	}
+	return false
+}
+
+// maintenanceIPs returns the IPs of all miners currently in maintenance.
+func maintenanceIPs() []string {
+	var ips []string
+	for _, m := range currentMachines() {
+		if maintenanceActive(m) {
+			ips = append(ips, m.IP)
+		}
+	}
+	return ips
+}
+
+// allInMaintenance reports whether every given miner is in maintenance.
+func allInMaintenance(ips []string) bool {
+	if len(ips) == 0 {
+		return false
+	}
+	for _, ip := range ips {
+		if !inMaintenance(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// excludeMaintenance drops miners in maintenance from bulk targets.
+func excludeMaintenance(ips []string) (targets, skipped []string) {
+	for _, ip := range ips {
+		if inMaintenance(ip) {
+			skipped = append(skipped, ip)
+		} else {
+			targets = append(targets, ip)
+		}
+	}
+	return targets, skipped
+}
+
+type MaintenanceRequest struct {
+	Reason string     `json:"reason"`
+	Until  *time.Time `json:"until"` // RFC3339; omitted lasts until cleared
+}
+
+func setMaintenanceHandler(c *gin.Context) {
+	ip := ipParam(c)
+	var req MaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Until != nil && !req.Until.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "until must be in the future"})
+		return
+	}
+	known := false
+	for _, m := range currentMachines() {
+		known = known || m.IP == ip
+	}
+	if !known {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown machine " + ip})
+		return
+	}
+	name := minerName(ip)
+
+	if err := database.SetMachineMaintenance(ip, req.Reason, req.Until); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update machine"})
+		return
+	}
+	refreshMachines()
+
+	// Alerts already raised for the miner are cleared right away
+	alerts.clear("shelly-idle:" + ip)
+	alerts.clear("shelly-off:" + ip)
+
+	if req.Until != nil {
+		recordEvent("maintenance", "%s in maintenance until %s: %s", name, req.Until.Local().Format("2006-01-02 15:04"), req.Reason)
+	} else {
+		recordEvent("maintenance", "%s in maintenance: %s", name, req.Reason)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"ip":      ip,
+	})
+}
+
+func clearMaintenanceHandler(c *gin.Context) {
+	ip := ipParam(c)
+	if err := database.ClearMachineMaintenance(ip); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update machine"})
+		return
+	}
+	refreshMachines()
+
+	recordEvent("maintenance", "%s out of maintenance", minerName(ip))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"ip":      ip,
+	})
+}
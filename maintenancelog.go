@@ -0,0 +1,331 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The maintenance log records the work done on each machine (fan replaced,
+// repaste, board RMA) and its warranty. An entry with a reminder falls due
+// once the miner has hashed that many hours since, counted from the pools
+// data; only the newest entry of each kind reminds, so logging the work again
+// restarts the count. Due reminders raise maintenance-due:<ip>:<kind> alerts
+// and are listed on the miner status rows.
+
+// MaintenanceReminder is the state of the newest entry of a kind with a
+// reminder.
+type MaintenanceReminder struct {
+	EntryID          int64     `json:"entryId"`
+	Kind             string    `json:"kind"`
+	PerformedAt      time.Time `json:"performedAt"`
+	RemindAfterHours float64   `json:"remindAfterHours"`
+	RuntimeHours     float64   `json:"runtimeHours"`   // hashing hours since PerformedAt
+	RemainingHours   float64   `json:"remainingHours"` // negative when overdue
+	Due              bool      `json:"due"`
+}
+
+// latestReminders returns the newest entry of each kind that has a reminder,
+// from entries ordered newest first.
+func latestReminders(entries []db.MaintenanceEntry) []db.MaintenanceEntry {
+	seen := make(map[string]bool)
+	var latest []db.MaintenanceEntry
+	for _, e := range entries {
+		key := e.IP + "\x00" + e.Kind
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if e.RemindAfterHours > 0 {
+			latest = append(latest, e)
+		}
+	}
+	return latest
+}
+
+// maintenanceReminder counts the runtime of an entry's machine since the
+// entry was logged.
+func maintenanceReminder(runtime func(ip string, since time.Time) (float64, error), e db.MaintenanceEntry) (MaintenanceReminder, error) {
+	hours, err := runtime(e.IP, e.PerformedAt)
+	if err != nil {
+		return MaintenanceReminder{}, err
+	}
+	return MaintenanceReminder{
+		EntryID:          e.ID,
+		Kind:             e.Kind,
+		PerformedAt:      e.PerformedAt,
+		RemindAfterHours: e.RemindAfterHours,
+		RuntimeHours:     roundTo(hours, 1),
+		RemainingHours:   roundTo(e.RemindAfterHours-hours, 1),
+		Due:              hours >= e.RemindAfterHours,
+	}, nil
+}
+
+// maintenanceReminderMonitor keeps the due kinds per miner between checks.
+type maintenanceReminderMonitor struct {
+	mu  sync.Mutex
+	due map[string][]string // miner IP to due kinds
+}
+
+var maintenanceReminders = &maintenanceReminderMonitor{due: make(map[string][]string)}
+
+// dueKinds returns the kinds of work due on a miner.
+func (m *maintenanceReminderMonitor) dueKinds(ip string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.due[ip]...)
+}
+
+// runMaintenanceReminders checks the reminders at startup and then at the
+// given interval.
+func runMaintenanceReminders(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		maintenanceReminders.check()
+		<-ticker.C
+	}
+}
+
+// check counts the runtime of every reminder and raises or clears its alert.
+func (m *maintenanceReminderMonitor) check() {
+	entries, err := database.FetchMaintenanceEntries("")
+	if err != nil {
+		log.Printf("Maintenance reminders: failed to fetch the maintenance log: %v", err)
+		return
+	}
+	known := make(map[string]bool)
+	for _, mc := range currentMachines() {
+		known[mc.IP] = true
+	}
+
+	due := make(map[string][]string)
+	raised := make(map[string]bool)
+	for _, e := range latestReminders(entries) {
+		if !known[e.IP] {
+			continue
+		}
+		r, err := maintenanceReminder(questdbClient.GetRuntimeHours, e)
+		if err != nil {
+			log.Printf("Maintenance reminders: failed to get the runtime of %s: %v", e.IP, err)
+			m.mu.Lock()
+			for _, kind := range m.due[e.IP] {
+				if kind == e.Kind {
+					due[e.IP] = append(due[e.IP], kind)
+					raised["maintenance-due:"+e.IP+":"+kind] = true
+				}
+			}
+			m.mu.Unlock()
+			continue
+		}
+		if !r.Due {
+			continue
+		}
+		key := "maintenance-due:" + e.IP + ":" + e.Kind
+		due[e.IP] = append(due[e.IP], e.Kind)
+		raised[key] = true
+		alerts.raise(key, severityWarning, "maintenance", fmt.Sprintf(
+			"%s is due for %s: %.0f of %.0f runtime hours since %s", minerName(e.IP), e.Kind,
+			r.RuntimeHours, r.RemindAfterHours, e.PerformedAt.In(roomLocation).Format("2006-01-02")))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for ip, kinds := range m.due {
+		for _, kind := range kinds {
+			if key := "maintenance-due:" + ip + ":" + kind; !raised[key] {
+				alerts.clear(key)
+			}
+		}
+	}
+	m.due = due
+}
+
+// visibleMachine returns the machine with the given IP if the request may see
+// it.
+func visibleMachine(c *gin.Context, ip string) (db.Machine, bool) {
+	for _, m := range machinesFor(c) {
+		if m.IP == ip {
+			return m, true
+		}
+	}
+	return db.Machine{}, false
+}
+
+// getMachineMaintenanceHandler returns a machine's maintenance log, its
+// reminders with the runtime since each, its total runtime and warranty.
+func getMachineMaintenanceHandler(c *gin.Context) {
+	ip := ipParam(c)
+	m, ok := visibleMachine(c, ip)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown machine " + ip})
+		return
+	}
+	entries, err := database.FetchMaintenanceEntries(ip)
+	if err != nil {
+		log.Printf("Failed to fetch maintenance log of %s: %v", ip, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch maintenance log"})
+		return
+	}
+	if entries == nil {
+		entries = []db.MaintenanceEntry{}
+	}
+
+	qdb := questdbFor(c)
+	response := gin.H{
+		"ip":            ip,
+		"name":          m.Name,
+		"warrantyUntil": m.WarrantyUntil,
+		"underWarranty": m.WarrantyUntil != nil && requestNow(c).Before(*m.WarrantyUntil),
+		"entries":       entries,
+		"reminders":     []MaintenanceReminder{},
+		"runtimeHours":  nil,
+	}
+	if hours, err := qdb.GetRuntimeHours(ip, time.Unix(0, 0)); err != nil {
+		log.Printf("Failed to get runtime of %s from QuestDB: %v", ip, err)
+	} else {
+		response["runtimeHours"] = roundTo(hours, 1)
+	}
+	reminders := []MaintenanceReminder{}
+	for _, e := range latestReminders(entries) {
+		r, err := maintenanceReminder(qdb.GetRuntimeHours, e)
+		if err != nil {
+			log.Printf("Failed to get runtime of %s from QuestDB: %v", ip, err)
+			continue
+		}
+		reminders = append(reminders, r)
+	}
+	response["reminders"] = reminders
+	c.JSON(http.StatusOK, response)
+}
+
+type MaintenanceEntryRequest struct {
+	Kind             string     `json:"kind" binding:"required"` // e.g. fan, repaste, rma
+	Note             string     `json:"note"`
+	PerformedAt      *time.Time `json:"performedAt"`      // RFC3339; omitted is now
+	RemindAfterHours float64    `json:"remindAfterHours"` // runtime hours until the next reminder, 0 for none
+}
+
+// addMaintenanceEntryHandler logs work done on a machine.
+func addMaintenanceEntryHandler(c *gin.Context) {
+	ip := ipParam(c)
+	var req MaintenanceEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Kind = strings.ToLower(strings.TrimSpace(req.Kind))
+	if req.Kind == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind is required"})
+		return
+	}
+	if req.RemindAfterHours < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "remindAfterHours must not be negative"})
+		return
+	}
+	performed := time.Now()
+	if req.PerformedAt != nil {
+		if req.PerformedAt.After(performed) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "performedAt must not be in the future"})
+			return
+		}
+		performed = *req.PerformedAt
+	}
+	if _, ok := visibleMachine(c, ip); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown machine " + ip})
+		return
+	}
+
+	id, err := database.AddMaintenanceEntry(db.MaintenanceEntry{
+		IP:               ip,
+		Kind:             req.Kind,
+		Note:             req.Note,
+		PerformedAt:      performed,
+		RemindAfterHours: req.RemindAfterHours,
+	})
+	if err != nil {
+		log.Printf("Failed to add maintenance entry for %s: %v", ip, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add maintenance entry"})
+		return
+	}
+	// A new entry restarts the count of its kind
+	go maintenanceReminders.check()
+
+	if req.Note != "" {
+		recordEvent("maintenance", "%s: logged %s (%s)", minerName(ip), req.Kind, req.Note)
+	} else {
+		recordEvent("maintenance", "%s: logged %s", minerName(ip), req.Kind)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"ip":      ip,
+		"id":      id,
+	})
+}
+
+func deleteMaintenanceEntryHandler(c *gin.Context) {
+	ip := ipParam(c)
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid entry id"})
+		return
+	}
+	if _, ok := visibleMachine(c, ip); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown machine " + ip})
+		return
+	}
+	found, err := database.DeleteMaintenanceEntry(ip, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete maintenance entry"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown maintenance entry"})
+		return
+	}
+	go maintenanceReminders.check()
+
+	log.Printf("Deleted maintenance entry %d of %s", id, ip)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"ip":      ip,
+		"id":      id,
+	})
+}
+
+type WarrantyRequest struct {
+	Until *time.Time `json:"until"` // RFC3339; omitted clears the warranty
+}
+
+func setMachineWarrantyHandler(c *gin.Context) {
+	ip := ipParam(c)
+	var req WarrantyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, ok := visibleMachine(c, ip); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown machine " + ip})
+		return
+	}
+	if err := database.SetMachineWarranty(ip, req.Until); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update machine"})
+		return
+	}
+	refreshMachines()
+
+	log.Printf("Set warranty of machine %s until %v", ip, req.Until)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"ip":      ip,
+	})
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MQTT broker used by the sensor collectors. Only what the collectors need is
+// implemented: MQTT 3.1.1, QoS 0 subscriptions, keepalive pings.
+var (
+	mqttBroker string
+	mqttUser   string
+	mqttPass   string
+)
+
+const mqttKeepAlive = 60 * time.Second
+
+// mqttHandler receives the messages of a subscription.
+type mqttHandler func(topic string, payload []byte)
+
+// runMQTTSubscriber keeps a subscription to the given topic filters open,
+// reconnecting with backoff. It returns immediately when no broker is set.
+func runMQTTSubscriber(name string, topics []string, handle mqttHandler) {
+	if mqttBroker == "" || len(topics) == 0 {
+		return
+	}
+
+	backoff := 5 * time.Second
+	for {
+		start := time.Now()
+		err := mqttSubscribe(name, topics, handle)
+		log.Printf("MQTT %s: disconnected from %s: %v", name, mqttBroker, err)
+		if time.Since(start) > time.Minute {
+			backoff = 5 * time.Second
+		}
+		time.Sleep(backoff)
+		if backoff < 5*time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+// mqttSubscribe connects, subscribes and dispatches messages until the
+// connection fails.
+func mqttSubscribe(name string, topics []string, handle mqttHandler) error {
+	addr := strings.TrimPrefix(mqttBroker, "tcp://")
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "1883")
+	}
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	clientID := fmt.Sprintf("miningroom-%s-%s", name, hex.EncodeToString(suffix))
+
+	var mu sync.Mutex // serializes writes from the read loop and the pinger
+	write := func(packet []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		_, err := conn.Write(packet)
+		return err
+	}
+
+	if err := write(mqttConnectPacket(clientID)); err != nil {
+		return err
+	}
+	r := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	kind, body, err := mqttReadPacket(r)
+	if err != nil {
+		return err
+	}
+	if kind != 2 || len(body) < 2 {
+		return fmt.Errorf("expected CONNACK, got packet type %d", kind)
+	}
+	if body[1] != 0 {
+		return fmt.Errorf("connection refused (code %d)", body[1])
+	}
+
+	if err := write(mqttSubscribePacket(1, topics)); err != nil {
+		return err
+	}
+	log.Printf("MQTT %s: subscribed to %s on %s", name, strings.Join(topics, ", "), mqttBroker)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(mqttKeepAlive / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := write([]byte{0xC0, 0}); err != nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(mqttKeepAlive * 3 / 2))
+		kind, body, err := mqttReadPacket(r)
+		if err != nil {
+			return err
+		}
+		if kind != 3 {
+			continue // SUBACK, PINGRESP
+		}
+		if len(body) < 2 {
+			return errors.New("malformed PUBLISH")
+		}
+		n := int(binary.BigEndian.Uint16(body))
+		if len(body) < 2+n {
+			return errors.New("malformed PUBLISH")
+		}
+		handle(string(body[2:2+n]), body[2+n:])
+	}
+}
+
+func mqttString(b *bytes.Buffer, s string) {
+	binary.Write(b, binary.BigEndian, uint16(len(s)))
+	b.WriteString(s)
+}
+
+// mqttPacket prefixes a body with the fixed header and remaining length.
+func mqttPacket(header byte, body []byte) []byte {
+	out := []byte{header}
+	n := len(body)
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		out = append(out, digit)
+		if n == 0 {
+			break
+		}
+	}
+	return append(out, body...)
+}
+
+func mqttConnectPacket(clientID string) []byte {
+	var b bytes.Buffer
+	mqttString(&b, "MQTT")
+	b.WriteByte(4) // protocol level 3.1.1
+
+	flags := byte(0x02) // clean session
+	if mqttUser != "" {
+		flags |= 0x80
+		if mqttPass != "" {
+			flags |= 0x40
+		}
+	}
+	b.WriteByte(flags)
+	binary.Write(&b, binary.BigEndian, uint16(mqttKeepAlive/time.Second))
+	mqttString(&b, clientID)
+	if mqttUser != "" {
+		mqttString(&b, mqttUser)
+		if mqttPass != "" {
+			mqttString(&b, mqttPass)
+		}
+	}
+	return mqttPacket(0x10, b.Bytes())
+}
+
+func mqttSubscribePacket(id uint16, topics []string) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, id)
+	for _, t := range topics {
+		mqttString(&b, t)
+		b.WriteByte(0) // QoS 0
+	}
+	return mqttPacket(0x82, b.Bytes())
+}
+
+// mqttReadPacket reads one packet and returns its type and body. PUBLISH
+// packets received on QoS 0 subscriptions carry no packet identifier.
+func mqttReadPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(digit&0x7F) * multiplier
+		if digit&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header >> 4, body, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Noise-limit policy. During quiet hours, once the measured sound level exceeds
+// noiseLimit the power target of miners with an Auto desired state is reduced
+// by quietReduction for the rest of the quiet period. The cap is latched
+// because lower power makes the room quieter and would release it again.
+var (
+	quietHours     string  // "22-7", local time; empty disables the policy
+	noiseLimit     float64 // dB(A)
+	quietReduction float64 // % power target reduction while capped
+)
+
+// NoiseStatus is the current noise policy state.
+type NoiseStatus struct {
+	QuietHours bool      `json:"quietHours"` // now is within quiet hours
+	Capped     bool      `json:"capped"`
+	Noise      float64   `json:"noise"` // dB(A), loudest sensor over the last 5 minutes
+	HasNoise   bool      `json:"hasNoise"`
+	Limit      float64   `json:"limit"`
+	Reduction  float64   `json:"reduction"`
+	Since      time.Time `json:"since,omitempty"` // when the cap was applied
+	CheckedAt  time.Time `json:"checkedAt"`
+}
+
+// noisePolicy caches the policy state.
+type noisePolicy struct {
+	mu     sync.Mutex
+	status NoiseStatus
+}
+
+var noise = &noisePolicy{}
+
+// parseQuietHours parses "start-end" in whole hours; the period may wrap
+// around midnight.
+func parseQuietHours(s string) (start, end int, err error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("expected start-end, e.g. 22-7")
+	}
+	if start, err = strconv.Atoi(strings.TrimSpace(from)); err != nil || start < 0 || start > 23 {
+		return 0, 0, fmt.Errorf("invalid start hour %q", from)
+	}
+	if end, err = strconv.Atoi(strings.TrimSpace(to)); err != nil || end < 0 || end > 23 {
+		return 0, 0, fmt.Errorf("invalid end hour %q", to)
+	}
+	return start, end, nil
+}
+
+// inQuietHours reports whether t falls within the quiet hours in effect.
+func inQuietHours(t time.Time) bool {
+	start, end, err := parseQuietHours(scenarios.quietHours())
+	if err != nil {
+		return false
+	}
+	h := t.In(roomLocation).Hour()
+	if start <= end {
+		return h >= start && h < end
+	}
+	return h >= start || h < end
+}
+
+// runNoisePolicy evaluates the policy at the given interval. Without
+// --quiet-hours it idles until a scenario sets quiet hours.
+func runNoisePolicy(interval time.Duration) {
+	noise.update()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		noise.update()
+	}
+}
+
+func (p *noisePolicy) update() {
+	if scenarios.quietHours() == "" && !p.current().Capped {
+		return
+	}
+	now := time.Now()
+	level, hasNoise, err := questdbClient.GetRecentNoise(5)
+	if err != nil {
+		log.Printf("Noise policy: %v", err)
+	}
+
+	p.mu.Lock()
+	status := p.status
+	status.QuietHours = inQuietHours(now)
+	status.Noise, status.HasNoise = level, hasNoise
+	status.Limit, status.Reduction = noiseLimit, quietReduction
+	status.CheckedAt = now
+
+	wasCapped := status.Capped
+	switch {
+	case !status.QuietHours:
+		status.Capped = false
+		status.Since = time.Time{}
+	case !status.Capped && hasNoise && level > noiseLimit:
+		status.Capped = true
+		status.Since = now
+	}
+	p.status = status
+	p.mu.Unlock()
+
+	if status.Capped != wasCapped {
+		if status.Capped {
+			recordEvent("noise", "%.1f dB exceeds the %.1f dB quiet-hours limit; reducing power targets by %.0f%%", level, noiseLimit, quietReduction)
+		} else {
+			recordEvent("noise", "quiet hours ended; power targets restored")
+		}
+		go reconcile.run()
+	}
+}
+
+func (p *noisePolicy) current() NoiseStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
+
+// adjustPower reduces an Auto power target while the noise cap is active.
+func (p *noisePolicy) adjustPower(power int) int {
+	if !p.current().Capped {
+		return power
+	}
+	return int(math.Round(float64(power) * (1 - quietReduction/100)))
+}
+
+func getNoiseHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": quietHours != "",
+		"status":  noise.current(),
+	})
+}
+
+func getNoiseChartHandler(c *gin.Context) {
+	result, err := questdbFor(c).GetNoisePowerTimeSeries()
+	if err != nil {
+		log.Printf("Failed to get noise data from QuestDB: %v", err)
+		c.JSON(http.StatusOK, gin.H{
+			"points":  []interface{}{},
+			"hasData": false,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Notification is an alert sent to notification channels.
+type Notification struct {
+	Title    string    `json:"title"`
+	Message  string    `json:"message"`
+	Severity string    `json:"severity"`
+	Source   string    `json:"source"`
+	Key      string    `json:"key"`
+	Resolved bool      `json:"resolved"`
+	Time     time.Time `json:"time"`
+}
+
+// notifier delivers a notification to one channel.
+type notifier interface {
+	Notify(n Notification) error
+}
+
+var notifyClient = &http.Client{Timeout: 10 * time.Second}
+
+// severityRank orders severities for routing.
+var severityRank = map[string]int{
+	severityWarning:  1,
+	severityCritical: 2,
+}
+
+// notifierFor builds the notifier of a stored channel.
+func notifierFor(ch db.NotifyChannel) (notifier, error) {
+	switch ch.Kind {
+	case "discord":
+		return discordNotifier{webhookURL: ch.Target}, nil
+	case "pushover":
+		return pushoverNotifier{userKey: ch.Target, appToken: ch.Token}, nil
+	case "ntfy":
+		return ntfyNotifier{topicURL: ch.Target, token: ch.Token}, nil
+	case "webhook":
+		return webhookNotifier{url: ch.Target}, nil
+	case "email":
+		return emailNotifier{to: splitList(ch.Target)}, nil
+	case "telegram":
+		if ch.Token == "" {
+			return nil, fmt.Errorf("telegram channels need the bot token")
+		}
+		return telegramNotifier{chatID: ch.Target, botToken: ch.Token}, nil
+	}
+	return nil, fmt.Errorf("unknown channel kind %q", ch.Kind)
+}
+
+// routes reports whether a notification should go to the channel.
+func routes(ch db.NotifyChannel, n Notification) bool {
+	if !ch.Enabled || (n.Resolved && !ch.SendResolved) {
+		return false
+	}
+	minSeverity := ch.MinSeverity
+	if s := scenarios.alertSeverity(); s != "" {
+		minSeverity = s
+	}
+	if severityRank[n.Severity] < severityRank[minSeverity] {
+		return false
+	}
+	if len(ch.Sources) == 0 {
+		return true
+	}
+	for _, s := range ch.Sources {
+		if s == n.Source {
+			return true
+		}
+	}
+	return false
+}
+
+// dispatchNotification sends a notification to every matching channel.
+func dispatchNotification(n Notification) {
+	channels, err := database.FetchNotifyChannels()
+	if err != nil {
+		log.Printf("Notifications: failed to fetch channels: %v", err)
+		return
+	}
+	for _, ch := range channels {
+		if !routes(ch, n) {
+			continue
+		}
+		nf, err := notifierFor(ch)
+		if err == nil {
+			err = nf.Notify(n)
+		}
+		if err != nil {
+			log.Printf("Notifications: failed to notify %s: %v", ch.Name, err)
+		}
+	}
+}
+
+// alertNotification converts an alert to a notification.
+func alertNotification(a Alert, resolved bool) Notification {
+	title := fmt.Sprintf("[%s] %s alert", strings.ToUpper(a.Severity), a.Source)
+	if resolved {
+		title = fmt.Sprintf("[RESOLVED] %s alert", a.Source)
+	}
+	return Notification{
+		Title:    title,
+		Message:  a.Message,
+		Severity: a.Severity,
+		Source:   a.Source,
+		Key:      a.Key,
+		Resolved: resolved,
+		Time:     time.Now(),
+	}
+}
+
+// postNotification sends a request and fails on non-2xx responses.
+func postNotification(req *http.Request) error {
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned status %d: %s", req.URL.Host, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func postJSON(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return postNotification(req)
+}
+
+// discordNotifier posts to a Discord channel webhook.
+type discordNotifier struct {
+	webhookURL string
+}
+
+func (d discordNotifier) Notify(n Notification) error {
+	return postJSON(d.webhookURL, map[string]string{
+		"content": fmt.Sprintf("**%s**\n%s", n.Title, n.Message),
+	})
+}
+
+// pushoverNotifier sends a Pushover message; critical alerts use high priority.
+type pushoverNotifier struct {
+	userKey  string
+	appToken string
+}
+
+func (p pushoverNotifier) Notify(n Notification) error {
+	priority := "0"
+	switch {
+	case n.Resolved:
+		priority = "-1"
+	case n.Severity == severityCritical:
+		priority = "1"
+	}
+	form := url.Values{
+		"token":    {p.appToken},
+		"user":     {p.userKey},
+		"title":    {n.Title},
+		"message":  {n.Message},
+		"priority": {priority},
+	}
+	req, err := http.NewRequest(http.MethodPost, "https://api.pushover.net/1/messages.json", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return postNotification(req)
+}
+
+// ntfyNotifier publishes to an ntfy topic URL such as https://ntfy.sh/<topic>.
+type ntfyNotifier struct {
+	topicURL string
+	token    string
+}
+
+func (t ntfyNotifier) Notify(n Notification) error {
+	priority, tags := "4", "warning"
+	switch {
+	case n.Resolved:
+		priority, tags = "3", "white_check_mark"
+	case n.Severity == severityCritical:
+		priority, tags = "5", "rotating_light"
+	}
+	req, err := http.NewRequest(http.MethodPost, t.topicURL, strings.NewReader(n.Message))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", n.Title)
+	req.Header.Set("Priority", priority)
+	req.Header.Set("Tags", tags)
+	if t.token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+	return postNotification(req)
+}
+
+// webhookNotifier posts the notification as JSON.
+type webhookNotifier struct {
+	url string
+}
+
+func (w webhookNotifier) Notify(n Notification) error {
+	return postJSON(w.url, n)
+}
+
+// emailNotifier mails the notification; critical alerts are flagged urgent.
+type emailNotifier struct {
+	to []string
+}
+
+func (e emailNotifier) Notify(n Notification) error {
+	body := fmt.Sprintf("<p><strong>%s</strong></p><p>%s</p>", html.EscapeString(n.Title), html.EscapeString(n.Message))
+	if n.Severity == severityCritical && !n.Resolved {
+		return sendUrgentMail(e.to, n.Title, body)
+	}
+	return sendMail(e.to, n.Title, body)
+}
+
+// telegramNotifier sends a message to a Telegram chat through a bot.
+type telegramNotifier struct {
+	chatID   string
+	botToken string
+}
+
+func (t telegramNotifier) Notify(n Notification) error {
+	return postJSON("https://api.telegram.org/bot"+t.botToken+"/sendMessage", map[string]interface{}{
+		"chat_id":              t.chatID,
+		"text":                 n.Title + "\n" + n.Message,
+		"disable_notification": n.Resolved,
+	})
+}
+
+func getNotifyChannelsHandler(c *gin.Context) {
+	channels, err := database.FetchNotifyChannels()
+	if err != nil {
+		log.Printf("Failed to fetch notification channels: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notification channels"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"channels": channels})
+}
+
+type NotifyChannelRequest struct {
+	Name         string   `json:"name" binding:"required"`
+	Kind         string   `json:"kind" binding:"required"`
+	Target       string   `json:"target" binding:"required"`
+	Token        string   `json:"token"`
+	MinSeverity  string   `json:"minSeverity"`
+	Sources      []string `json:"sources"`
+	SendResolved bool     `json:"sendResolved"`
+	Enabled      *bool    `json:"enabled"` // default true
+}
+
+func saveNotifyChannelHandler(c *gin.Context) {
+	var req NotifyChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.MinSeverity == "" {
+		req.MinSeverity = severityWarning
+	}
+	if _, ok := severityRank[req.MinSeverity]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "minSeverity must be warning or critical"})
+		return
+	}
+
+	ch := db.NotifyChannel{
+		Name:         req.Name,
+		Kind:         req.Kind,
+		Target:       req.Target,
+		Token:        req.Token,
+		MinSeverity:  req.MinSeverity,
+		Sources:      req.Sources,
+		SendResolved: req.SendResolved,
+		Enabled:      req.Enabled == nil || *req.Enabled,
+	}
+	if _, err := notifierFor(ch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.SaveNotifyChannel(ch); err != nil {
+		log.Printf("Failed to save notification channel %s: %v", req.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save notification channel"})
+		return
+	}
+
+	log.Printf("Saved notification channel %s (%s)", req.Name, req.Kind)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"name":    req.Name,
+	})
+}
+
+func deleteNotifyChannelHandler(c *gin.Context) {
+	name := c.Param("name")
+	if err := database.DeleteNotifyChannel(name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete notification channel"})
+		return
+	}
+
+	log.Printf("Deleted notification channel %s", name)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"name":    name,
+	})
+}
+
+// testNotifyChannelHandler sends a test notification to one channel,
+// regardless of its routing.
+func testNotifyChannelHandler(c *gin.Context) {
+	name := c.Param("name")
+	channels, err := database.FetchNotifyChannels()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notification channels"})
+		return
+	}
+	for _, ch := range channels {
+		if ch.Name != name {
+			continue
+		}
+		nf, err := notifierFor(ch)
+		if err == nil {
+			err = nf.Notify(Notification{
+				Title:    "Test notification",
+				Message:  "Notifications from the mining dashboard reach this channel.",
+				Severity: severityWarning,
+				Source:   "notify",
+				Time:     time.Now(),
+			})
+		}
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "notification channel not found"})
+}
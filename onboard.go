@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Bulk onboarding: POST /api/machines/bulk-add probes every address of a CIDR
+// or range with the driver of the given firmware, adds the miners that answer
+// under names from a template and pairs each with its Shelly, from an explicit
+// mapping or by matching the miner's reported power to the Shellies' meters.
+
+const (
+	onboardProbeTimeout = 4 * time.Second
+	onboardProbeWorkers = 64
+	onboardMaxHosts     = 1024
+	// onboardPowerTolerance is how far, relative to the miner's own reading,
+	// a Shelly's power may be off and still be paired with it.
+	onboardPowerTolerance = 0.15
+	onboardMinShellyPower = 50 // W; an idle outlet can't be told apart
+)
+
+// nameNumber matches {n} and {n:3} (zero padded to 3 digits) in name
+// templates.
+var nameNumber = regexp.MustCompile(`\{n(?::(\d+))?\}`)
+
+type BulkAddRequest struct {
+	Range        string            `json:"range" binding:"required"` // CIDR, a-b or a.b.c.d-e
+	NameTemplate string            `json:"nameTemplate" binding:"required"`
+	Start        int               `json:"start"` // first {n}, default 1
+	Firmware     string            `json:"firmware"`
+	Shellies     map[string]string `json:"shellies"`    // miner IP -> Shelly IP
+	ShellyRange  string            `json:"shellyRange"` // Shellies to pair by power
+	DryRun       bool              `json:"dryRun"`
+}
+
+// BulkAddedMachine is a miner found by the bulk add.
+type BulkAddedMachine struct {
+	Name     string  `json:"name"`
+	IP       string  `json:"ip"`
+	ShellyIP string  `json:"shellyIp,omitempty"`
+	Pairing  string  `json:"pairing,omitempty"` // mapping or power
+	PowerW   float64 `json:"powerW,omitempty"`  // the miner's reading, when paired by power
+	ShellyW  float64 `json:"shellyW,omitempty"` // the Shelly's reading
+}
+
+type BulkSkippedAddress struct {
+	IP     string `json:"ip"`
+	Reason string `json:"reason"`
+}
+
+// expandRange lists the IPv4 addresses of a CIDR (at most onboardMaxHosts, the
+// network and broadcast addresses excluded), a range "10.0.0.10-10.0.0.40" or
+// a short range "10.0.0.10-40". A single address stands for itself.
+func expandRange(s string) ([]string, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		return subnetHosts(s)
+	}
+	from, to, isRange := strings.Cut(s, "-")
+	if !isRange {
+		to = from
+	}
+	start, err := netip.ParseAddr(strings.TrimSpace(from))
+	if err != nil || !start.Is4() {
+		return nil, fmt.Errorf("invalid range start %q", from)
+	}
+	to = strings.TrimSpace(to)
+	if !strings.Contains(to, ".") {
+		// Short form: only the last octet
+		b := start.As4()
+		to = fmt.Sprintf("%d.%d.%d.%s", b[0], b[1], b[2], to)
+	}
+	end, err := netip.ParseAddr(to)
+	if err != nil || !end.Is4() {
+		return nil, fmt.Errorf("invalid range end %q", to)
+	}
+	if end.Less(start) {
+		return nil, errors.New("range ends before it starts")
+	}
+	var hosts []string
+	for a := start; a.Compare(end) <= 0; a = a.Next() {
+		if len(hosts) == onboardMaxHosts {
+			return nil, fmt.Errorf("larger than %d addresses", onboardMaxHosts)
+		}
+		hosts = append(hosts, a.String())
+	}
+	return hosts, nil
+}
+
+// expandNameTemplate fills a name template: {n} is the sequence number, {n:3}
+// the number zero padded to 3 digits and {last} the last part of the IP.
+func expandNameTemplate(template string, n int, ip string) string {
+	name := strings.ReplaceAll(template, "{last}", ip[strings.LastIndexAny(ip, ".:")+1:])
+	return nameNumber.ReplaceAllStringFunc(name, func(field string) string {
+		width, _ := strconv.Atoi(nameNumber.FindStringSubmatch(field)[1])
+		return fmt.Sprintf("%0*d", width, n)
+	})
+}
+
+// minerPower returns the power a miner reports drawing, or 0: the sum of its
+// chains, else the tuner's estimate.
+func minerPower(info *MinerManageInfo) float64 {
+	var w float64
+	for _, ch := range info.Chains {
+		w += ch.PowerW
+	}
+	if w == 0 && info.Tuner != nil {
+		w = info.Tuner.ApproxPower
+	}
+	return w
+}
+
+// probeAll runs probe on every address with onboardProbeWorkers in parallel.
+func probeAll(ctx context.Context, ips []string, probe func(ctx context.Context, ip string)) {
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < onboardProbeWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ip := range work {
+				probeCtx, cancel := context.WithTimeout(ctx, onboardProbeTimeout)
+				probe(probeCtx, ip)
+				cancel()
+			}
+		}()
+	}
+	for _, ip := range ips {
+		work <- ip
+	}
+	close(work)
+	wg.Wait()
+}
+
+// pairByPower pairs miners with the Shelly whose power reading is closest to
+// the miner's own, within onboardPowerTolerance, closest pairs first.
+func pairByPower(minerW, shellyW map[string]float64) map[string]string {
+	type candidate struct {
+		miner, shelly string
+		diff          float64
+	}
+	var candidates []candidate
+	for m, mw := range minerW {
+		for s, sw := range shellyW {
+			if diff := math.Abs(sw-mw) / mw; diff <= onboardPowerTolerance {
+				candidates = append(candidates, candidate{m, s, diff})
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].diff < candidates[j].diff })
+
+	pairs := map[string]string{}
+	taken := map[string]bool{}
+	for _, c := range candidates {
+		if pairs[c.miner] == "" && !taken[c.shelly] {
+			pairs[c.miner] = c.shelly
+			taken[c.shelly] = true
+		}
+	}
+	return pairs
+}
+
+// bulkAddMachinesHandler scans a range for miners and adds the ones that
+// answer. With dryRun it only reports what it would add.
+func bulkAddMachinesHandler(c *gin.Context) {
+	var req BulkAddRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Firmware == "" {
+		req.Firmware = "kaonsu"
+	}
+	driver, ok := drivers[req.Firmware]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown firmware: " + req.Firmware})
+		return
+	}
+	numbered := nameNumber.MatchString(req.NameTemplate)
+	if !numbered && !strings.Contains(req.NameTemplate, "{last}") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "nameTemplate needs {n} or {last} to give each miner its own name"})
+		return
+	}
+	if req.Start == 0 {
+		req.Start = 1
+	}
+	ips, err := expandRange(req.Range)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "range: " + err.Error()})
+		return
+	}
+	var shellyIPs []string
+	if req.ShellyRange != "" {
+		if shellyIPs, err = expandRange(req.ShellyRange); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "shellyRange: " + err.Error()})
+			return
+		}
+	}
+	mapping := map[string]string{}
+	for minerIP, shellyIP := range req.Shellies {
+		m, err1 := normalizeAddress(minerIP)
+		s, err2 := normalizeAddress(shellyIP)
+		if err1 != nil || err2 != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid Shelly mapping %s -> %s", minerIP, shellyIP)})
+			return
+		}
+		mapping[m] = s
+	}
+
+	names := map[string]bool{}
+	registered := map[string]bool{}
+	for _, m := range currentMachines() {
+		names[m.Name] = true
+		registered[m.IP] = true
+		if m.ShellyIP != "" {
+			registered[m.ShellyIP] = true
+		}
+	}
+
+	// Probe the miners
+	var (
+		mu             sync.Mutex
+		found          = map[string]*MinerManageInfo{}
+		skipped        []BulkSkippedAddress
+		toProbe        []string
+		mappedShellies = map[string]bool{}
+	)
+	for _, s := range mapping {
+		mappedShellies[s] = true
+	}
+	for _, ip := range ips {
+		if registered[ip] {
+			skipped = append(skipped, BulkSkippedAddress{IP: ip, Reason: "already registered"})
+			continue
+		}
+		toProbe = append(toProbe, ip)
+	}
+	probeAll(c.Request.Context(), toProbe, func(ctx context.Context, ip string) {
+		if info, err := driver.Config(ctx, ip); err == nil {
+			mu.Lock()
+			found[ip] = info
+			mu.Unlock()
+		}
+	})
+
+	// Read the candidate Shellies' meters
+	shellyW := map[string]float64{}
+	var candidates []string
+	for _, ip := range shellyIPs {
+		if !registered[ip] && !mappedShellies[ip] && found[ip] == nil {
+			candidates = append(candidates, ip)
+		}
+	}
+	probeAll(c.Request.Context(), candidates, func(ctx context.Context, ip string) {
+		if sw, err := getShellySwitch(ctx, ip); err == nil && sw.Output && sw.APower >= onboardMinShellyPower {
+			mu.Lock()
+			shellyW[ip] = sw.APower
+			mu.Unlock()
+		}
+	})
+
+	minerW := map[string]float64{}
+	for ip, info := range found {
+		if _, mapped := mapping[ip]; !mapped {
+			if w := minerPower(info); w > 0 {
+				minerW[ip] = w
+			}
+		}
+	}
+	paired := pairByPower(minerW, shellyW)
+
+	// Name and add the miners in address order
+	var foundIPs []string
+	for ip := range found {
+		foundIPs = append(foundIPs, ip)
+	}
+	sort.Slice(foundIPs, func(i, j int) bool {
+		return netip.MustParseAddr(foundIPs[i]).Less(netip.MustParseAddr(foundIPs[j]))
+	})
+	added := []BulkAddedMachine{}
+	n := req.Start
+	for _, ip := range foundIPs {
+		name := expandNameTemplate(req.NameTemplate, n, ip)
+		for names[name] && numbered {
+			n++
+			name = expandNameTemplate(req.NameTemplate, n, ip)
+		}
+		if names[name] {
+			skipped = append(skipped, BulkSkippedAddress{IP: ip, Reason: "name " + name + " is taken"})
+			continue
+		}
+		n++
+
+		m := BulkAddedMachine{Name: name, IP: ip}
+		if s, ok := mapping[ip]; ok {
+			m.ShellyIP, m.Pairing = s, "mapping"
+		} else if s, ok := paired[ip]; ok {
+			m.ShellyIP, m.Pairing = s, "power"
+			m.PowerW, m.ShellyW = minerW[ip], shellyW[s]
+		}
+		if !req.DryRun {
+			if err := database.AddMachine(m.Name, m.IP, m.ShellyIP, req.Firmware); err != nil {
+				log.Printf("Bulk add: failed to add %s (%s): %v", m.Name, m.IP, err)
+				skipped = append(skipped, BulkSkippedAddress{IP: ip, Reason: "failed to add"})
+				continue
+			}
+		}
+		names[name] = true
+		added = append(added, m)
+	}
+
+	unpaired := []string{}
+	for ip := range shellyW {
+		used := false
+		for _, m := range added {
+			used = used || m.ShellyIP == ip
+		}
+		if !used {
+			unpaired = append(unpaired, ip)
+		}
+	}
+	sort.Strings(unpaired)
+
+	if !req.DryRun && len(added) > 0 {
+		refreshMachines()
+		for _, m := range added {
+			go captureMAC(m.IP)
+			go identifyNewMachine(m.IP)
+		}
+		log.Printf("Bulk add: added %d of %d addresses in %s", len(added), len(ips), req.Range)
+	}
+	if skipped == nil {
+		skipped = []BulkSkippedAddress{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":          true,
+		"dryRun":           req.DryRun,
+		"scanned":          len(ips),
+		"responded":        len(found),
+		"added":            added,
+		"skipped":          skipped,
+		"unpairedShellies": unpaired,
+	})
+}
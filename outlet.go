@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// outletController switches and meters the outlet powering a machine: its
+// Shelly relay, or an outlet configured by URL in the machine's Outlet.
+type outletController interface {
+	// Key identifies the outlet, e.g. for the relay state last requested.
+	Key() string
+	// DeviceID is the device_id of the outlet's readings in the shellies table.
+	DeviceID() (string, error)
+	Status(ctx context.Context) (*OutletStatus, error)
+	Set(ctx context.Context, on bool) error
+}
+
+// OutletStatus is the relay state and, for metered outlets, active power.
+// Voltage and current are zero when the outlet doesn't report them.
+type OutletStatus struct {
+	On      bool
+	Power   float64 // W
+	Metered bool
+	Voltage float64 // V
+	Current float64 // A
+}
+
+var errNoOutlet = errors.New("no shelly or outlet configured")
+
+// outletKinds maps outlet URL schemes to their constructor.
+var outletKinds = map[string]func(u *url.URL) (outletController, error){
+	"snmp":    newSNMPOutlet,
+	"tasmota": newTasmotaOutlet,
+	"kasa":    newKasaOutlet,
+}
+
+// parseOutlet builds the controller of an outlet URL.
+func parseOutlet(spec string) (outletController, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	newOutlet, ok := outletKinds[u.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("unknown outlet kind %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("outlet URL needs a host")
+	}
+	return newOutlet(u)
+}
+
+// outletFor returns the controller of a machine's outlet.
+func outletFor(m db.Machine) (outletController, error) {
+	if m.Outlet != "" {
+		return parseOutlet(m.Outlet)
+	}
+	if m.ShellyIP != "" {
+		return shellyOutlet{ip: m.ShellyIP}, nil
+	}
+	return nil, errNoOutlet
+}
+
+// outletForMiner returns the controller of the outlet powering a miner.
+func outletForMiner(ip string) (outletController, error) {
+	for _, m := range currentMachines() {
+		if m.IP == ip {
+			return outletFor(m)
+		}
+	}
+	return nil, errNoOutlet
+}
+
+// outletDeviceIDs resolves the outlet device IDs of machines in parallel; the
+// ID is empty for machines without an outlet or whose outlet didn't answer.
+func outletDeviceIDs(ms []db.Machine) []string {
+	deviceIDs := make([]string, len(ms))
+	var wg sync.WaitGroup
+	for i, m := range ms {
+		outlet, err := outletFor(m)
+		if err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, outlet outletController) {
+			defer wg.Done()
+			id, err := outlet.DeviceID()
+			if err != nil {
+				log.Printf("Failed to get device ID of outlet %s: %v", outlet.Key(), err)
+				return
+			}
+			deviceIDs[i] = id
+		}(i, outlet)
+	}
+	wg.Wait()
+	return deviceIDs
+}
+
+// isShellyOutlet reports whether the outlet is a Shelly, whose readings reach
+// QuestDB without the watcher.
+func isShellyOutlet(o outletController) bool {
+	_, ok := o.(shellyOutlet)
+	return ok
+}
+
+// shellyOutlet is a Shelly Gen2 relay.
+type shellyOutlet struct {
+	ip string
+}
+
+func (s shellyOutlet) Key() string               { return s.ip }
+func (s shellyOutlet) DeviceID() (string, error) { return shellyDeviceID(s.ip) }
+func (s shellyOutlet) Set(ctx context.Context, on bool) error {
+	return controlShelly(ctx, s.ip, on)
+}
+func (s shellyOutlet) Status(ctx context.Context) (*OutletStatus, error) {
+	sw, err := getShellySwitch(ctx, s.ip)
+	if err != nil {
+		return nil, err
+	}
+	return &OutletStatus{On: sw.Output, Power: sw.APower, Metered: true}, nil
+}
+
+// snmpProfile holds the OIDs of a PDU family; the outlet number is appended.
+type snmpProfile struct {
+	State   string // reads StateOn while on
+	Control string
+	Power   string // W; empty if the PDU doesn't meter outlets
+	StateOn int64
+	On, Off int64 // values written to Control
+}
+
+var snmpProfiles = map[string]snmpProfile{
+	// APC switched rack PDUs (PowerNet sPDUOutletCtl), with per-outlet power
+	// from rPDU2OutletMeteredStatusActivePower on metered-by-outlet models
+	"apc": {
+		State:   "1.3.6.1.4.1.318.1.1.4.4.2.1.3",
+		Control: "1.3.6.1.4.1.318.1.1.4.4.2.1.3",
+		Power:   "1.3.6.1.4.1.318.1.1.26.9.4.3.1.7",
+		StateOn: 1,
+		On:      1,
+		Off:     2,
+	},
+}
+
+// snmpOutlet is an outlet of a managed PDU, configured as
+// snmp://<community>@<host>[:port]/<outlet>?profile=apc. The state, control
+// and power OIDs and the on/off values can be overridden by query parameters
+// of the same names for other PDUs.
+type snmpOutlet struct {
+	addr      string
+	community string
+	outlet    int
+	profile   snmpProfile
+}
+
+func newSNMPOutlet(u *url.URL) (outletController, error) {
+	o := snmpOutlet{addr: u.Host, community: "private"}
+	if name := u.User.Username(); name != "" {
+		o.community = name
+	}
+	n, err := strconv.Atoi(strings.Trim(u.Path, "/"))
+	if err != nil || n < 1 {
+		return nil, errors.New("outlet URL path must be the outlet number")
+	}
+	o.outlet = n
+
+	q := u.Query()
+	name := q.Get("profile")
+	if name == "" {
+		name = "apc"
+	}
+	p, ok := snmpProfiles[name]
+	if !ok && name != "custom" {
+		return nil, fmt.Errorf("unknown PDU profile %q", name)
+	}
+	for param, oid := range map[string]*string{"state": &p.State, "control": &p.Control, "power": &p.Power} {
+		if v := q.Get(param); v != "" {
+			if _, err := berEncodeOID(v); err != nil {
+				return nil, err
+			}
+			*oid = v
+		}
+	}
+	for param, value := range map[string]*int64{"on": &p.On, "off": &p.Off, "stateOn": &p.StateOn} {
+		if v := q.Get(param); v != "" {
+			if *value, err = strconv.ParseInt(v, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid %s value %q", param, v)
+			}
+		}
+	}
+	if p.State == "" || p.Control == "" {
+		return nil, errors.New("PDU outlet needs state and control OIDs")
+	}
+	o.profile = p
+	return o, nil
+}
+
+func (o snmpOutlet) oid(base string) string {
+	return fmt.Sprintf("%s.%d", base, o.outlet)
+}
+
+func (o snmpOutlet) Key() string {
+	return fmt.Sprintf("snmp://%s/%d", o.addr, o.outlet)
+}
+
+func (o snmpOutlet) DeviceID() (string, error) {
+	return fmt.Sprintf("pdu-%s-%d", strings.NewReplacer(".", "-", ":", "-").Replace(o.addr), o.outlet), nil
+}
+
+func (o snmpOutlet) Status(ctx context.Context) (*OutletStatus, error) {
+	state, err := snmpGet(ctx, o.addr, o.community, o.oid(o.profile.State))
+	if err != nil {
+		return nil, err
+	}
+	status := &OutletStatus{On: state == o.profile.StateOn}
+	if o.profile.Power != "" {
+		power, err := snmpGet(ctx, o.addr, o.community, o.oid(o.profile.Power))
+		switch {
+		case err == nil:
+			status.Power, status.Metered = float64(power), true
+		case !errors.Is(err, errSNMPNoSuchName):
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+func (o snmpOutlet) Set(ctx context.Context, on bool) error {
+	shellyWatch.request(o.Key(), on)
+	value := o.profile.Off
+	if on {
+		value = o.profile.On
+	}
+	return snmpSet(ctx, o.addr, o.community, o.oid(o.profile.Control), value)
+}
+
+type MachineOutletRequest struct {
+	Outlet string `json:"outlet"` // empty switches back to the Shelly
+}
+
+// setMachineOutletHandler sets the outlet controller URL of a machine.
+func setMachineOutletHandler(c *gin.Context) {
+	ip := ipParam(c)
+	var req MachineOutletRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Outlet != "" {
+		if _, err := parseOutlet(req.Outlet); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := database.UpdateMachineOutlet(ip, req.Outlet); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update machine"})
+		return
+	}
+	refreshMachines()
+
+	log.Printf("Set outlet of machine %s to %q", ip, req.Outlet)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"ip":      ip,
+		"outlet":  req.Outlet,
+	})
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"miningRoom/questdb"
+	"miningRoom/questdb/schema"
+)
+
+// Outlier rules reject glitched readings before they reach the charts and
+// the alert checks: a hashboard sensor reporting 0 or 255 °C, a plug
+// reporting a power spike. Each metric has a valid range applied to the
+// columns it is stored in; rows outside it are skipped by every read of the
+// timeseries store, so a dashboard gauge shows the latest valid reading. The
+// sensor anomaly check reads the raw values, so a broken sensor is still
+// flagged.
+
+var outlierRulesFlag string // --outlier-rules: metric=min:max,... overriding the defaults
+
+// outlierColumn is a column a metric is stored in.
+type outlierColumn struct {
+	table, column string
+}
+
+// outlierMetric is a metric --outlier-rules can bound, with its default range
+// ("" for none).
+type outlierMetric struct {
+	name     string
+	columns  []outlierColumn
+	defaults string
+}
+
+// rolledUp adds the hourly and daily rollup tables of a column, which keep its
+// name.
+func rolledUp(table, column string) []outlierColumn {
+	return []outlierColumn{{table, column}, {table + "_1h", column}, {table + "_1d", column}}
+}
+
+var outlierMetrics = []outlierMetric{
+	{"hashboard_temp", []outlierColumn{
+		{schema.TableHashboards, schema.ColTemperatureRaw0},
+		{schema.TableHashboards, schema.ColTemperatureRaw1},
+	}, "1:125"},
+	{"plug_power", rolledUp(schema.TableShellies, schema.ColPower), "0:10000"},
+	{"meter_power", []outlierColumn{{"room_meter", "power"}}, ""},
+	{"room_temp", rolledUp(schema.TableBME280Readings, schema.ColTemperature), "-40:85"},
+	{"room_humidity", rolledUp(schema.TableBME280Readings, "humidity"), "0:100"},
+	{"room_pressure", rolledUp(schema.TableBME280Readings, "pressure"), "300:1100"},
+	{"gpu_temp", []outlierColumn{{"gpu_status", "temperature"}}, ""},
+}
+
+// parseOutlierBound parses one side of a min:max range; empty is open.
+func parseOutlierBound(s string) (*float64, error) {
+	if s = strings.TrimSpace(s); s == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bound %q", s)
+	}
+	return &v, nil
+}
+
+// parseOutlierRange parses min:max, either side optional, or "off".
+func parseOutlierRange(s string) (min, max *float64, err error) {
+	if s = strings.TrimSpace(s); s == "off" || s == "" {
+		return nil, nil, nil
+	}
+	lo, hi, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, nil, fmt.Errorf("range %q must be min:max or off", s)
+	}
+	if min, err = parseOutlierBound(lo); err != nil {
+		return nil, nil, err
+	}
+	if max, err = parseOutlierBound(hi); err != nil {
+		return nil, nil, err
+	}
+	if min != nil && max != nil && *min > *max {
+		return nil, nil, fmt.Errorf("range %q has min above max", s)
+	}
+	return min, max, nil
+}
+
+// parseOutlierRules applies the metric=min:max pairs of spec over the default
+// ranges and returns the valid ranges of the stores' columns, and the ranges
+// per metric for the log.
+func parseOutlierRules(spec string) ([]questdb.ValidRange, map[string]string, error) {
+	ranges := make(map[string]string)
+	for _, m := range outlierMetrics {
+		ranges[m.name] = m.defaults
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, r, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if _, known := ranges[name]; !ok || !known {
+			names := make([]string, len(outlierMetrics))
+			for i, m := range outlierMetrics {
+				names[i] = m.name
+			}
+			return nil, nil, fmt.Errorf("%q must be metric=min:max with a metric of %s", pair, strings.Join(names, ", "))
+		}
+		ranges[name] = strings.TrimSpace(r)
+	}
+
+	var valid []questdb.ValidRange
+	active := make(map[string]string)
+	for _, m := range outlierMetrics {
+		min, max, err := parseOutlierRange(ranges[m.name])
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", m.name, err)
+		}
+		if min == nil && max == nil {
+			continue
+		}
+		for _, col := range m.columns {
+			valid = append(valid, questdb.ValidRange{Table: col.table, Column: col.column, Min: min, Max: max})
+		}
+		active[m.name] = valid[len(valid)-1].String()
+	}
+	return valid, active, nil
+}
+
+// describeOutlierRules lists the active ranges for the startup log.
+func describeOutlierRules(active map[string]string) string {
+	if len(active) == 0 {
+		return "none"
+	}
+	rules := make([]string, 0, len(active))
+	for name, r := range active {
+		rules = append(rules, name+"="+r)
+	}
+	sort.Strings(rules)
+	return strings.Join(rules, ", ")
+}
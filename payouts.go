@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The payout watcher follows --payout-address on mempool.space, independent
+// of any pool API: a payment is announced once when it shows up, in the
+// mempool or already mined, and recorded in btc_payouts when it confirms.
+
+var (
+	payoutAddresses   string // --payout-address, comma-separated
+	payoutPollSeconds int    // --payout-poll
+)
+
+const (
+	payoutTable       = "btc_payouts"
+	payoutsListedDays = 90 // default window of /api/payouts
+)
+
+// addressTx is a transaction of an address as listed by the mempool.space
+// REST API.
+type addressTx struct {
+	TxID string `json:"txid"`
+	Vout []struct {
+		Address string `json:"scriptpubkey_address"`
+		Value   int64  `json:"value"` // sats
+	} `json:"vout"`
+	Status struct {
+		Confirmed   bool  `json:"confirmed"`
+		BlockHeight int64 `json:"block_height"`
+		BlockTime   int64 `json:"block_time"`
+	} `json:"status"`
+}
+
+// receivedSats is what the transaction pays to address.
+func (tx addressTx) receivedSats(address string) int64 {
+	var sats int64
+	for _, out := range tx.Vout {
+		if out.Address == address {
+			sats += out.Value
+		}
+	}
+	return sats
+}
+
+// fetchAddressTxs returns the latest transactions of an address, unconfirmed
+// ones first.
+func fetchAddressTxs(address string) ([]addressTx, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Get("https://mempool.space/api/address/" + address + "/txs")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	var txs []addressTx
+	if err := json.NewDecoder(resp.Body).Decode(&txs); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// payoutWatcher remembers the payouts already announced and recorded.
+type payoutWatcher struct {
+	mu        sync.Mutex
+	announced map[string]bool // txids notified about
+	recorded  map[string]bool // txids in btc_payouts
+}
+
+var payouts = &payoutWatcher{announced: make(map[string]bool), recorded: make(map[string]bool)}
+
+// runPayoutWatcher polls the addresses at the given interval. It starts once
+// the recorded payouts could be read, so a restart doesn't announce them
+// again; payments seen before the first poll are taken as known.
+func runPayoutWatcher(addresses []string, interval time.Duration) {
+	for {
+		recorded, err := questdbClient.GetPayoutTxIDs()
+		if err == nil {
+			payouts.mu.Lock()
+			for id := range recorded {
+				payouts.recorded[id] = true
+				payouts.announced[id] = true
+			}
+			payouts.mu.Unlock()
+			break
+		}
+		log.Printf("Payout watcher: waiting for QuestDB: %v", err)
+		time.Sleep(interval)
+	}
+
+	for _, a := range addresses {
+		payouts.check(a, true)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, a := range addresses {
+			payouts.check(a, false)
+		}
+	}
+}
+
+// check records newly confirmed payments to address and announces new ones.
+// On the first check, unconfirmed payments are only remembered; confirmed
+// ones not recorded yet are recorded without a notification.
+func (w *payoutWatcher) check(address string, first bool) {
+	txs, err := fetchAddressTxs(address)
+	if err != nil {
+		log.Printf("Payout watcher: %s: %v", address, err)
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var points []questdb.Point
+	var recorded, announce []addressTx
+	for _, tx := range txs {
+		if tx.receivedSats(address) <= 0 {
+			continue // a spend from the address
+		}
+		if !w.announced[tx.TxID] {
+			w.announced[tx.TxID] = true
+			if !first {
+				announce = append(announce, tx)
+			}
+		}
+		if tx.Status.Confirmed && !w.recorded[tx.TxID] {
+			points = append(points, questdb.Point{
+				Table:   payoutTable,
+				Symbols: map[string]string{"address": address},
+				Fields: map[string]interface{}{
+					"txid":         tx.TxID,
+					"amount":       float64(tx.receivedSats(address)) / satsPerBTC,
+					"block_height": tx.Status.BlockHeight,
+				},
+				Time: time.Unix(tx.Status.BlockTime, 0),
+			})
+			recorded = append(recorded, tx)
+		}
+	}
+
+	if len(points) > 0 {
+		if err := questdbClient.Write(points); err != nil {
+			log.Printf("Payout watcher: failed to record payouts: %v", err)
+			recorded = nil // retried on the next poll
+		}
+	}
+	for _, tx := range recorded {
+		w.recorded[tx.TxID] = true
+	}
+	for _, tx := range announce {
+		n := payoutNotification(address, tx)
+		recordEvent("payout", "%s", n.Message)
+		go dispatchNotification(n)
+	}
+}
+
+// payoutNotification announces a payment received at address.
+func payoutNotification(address string, tx addressTx) Notification {
+	btc := float64(tx.receivedSats(address)) / satsPerBTC
+	msg := fmt.Sprintf("%.8f BTC received at %s", btc, address)
+	if price, err := fetchBTCPriceEUR(); err == nil && price > 0 {
+		msg += fmt.Sprintf(" (%.2f EUR)", btc*price)
+	}
+	if tx.Status.Confirmed {
+		msg += fmt.Sprintf(", confirmed in block %d", tx.Status.BlockHeight)
+	} else {
+		msg += ", unconfirmed"
+	}
+	return Notification{
+		Title:    "Payout received",
+		Message:  msg + ": " + tx.TxID,
+		Severity: severityWarning,
+		Source:   "payout",
+		Key:      "payout:" + tx.TxID,
+		Time:     time.Now(),
+	}
+}
+
+// parsePayoutAddresses splits --payout-address.
+func parsePayoutAddresses(s string) []string {
+	var addresses []string
+	for _, a := range strings.Split(s, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			addresses = append(addresses, a)
+		}
+	}
+	return addresses
+}
+
+// getPayoutsHandler lists the recorded payouts of the last ?days= (default
+// 90) with their total.
+func getPayoutsHandler(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(payoutsListedDays)))
+	if err != nil || days <= 0 || days > 3650 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 3650"})
+		return
+	}
+	list, err := questdbFor(c).GetPayouts(requestNow(c).AddDate(0, 0, -days))
+	if err != nil {
+		log.Printf("Failed to get payouts: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get payouts"})
+		return
+	}
+	if list == nil {
+		list = []questdb.Payout{}
+	}
+	total := 0.0
+	for _, p := range list {
+		total += p.AmountBTC
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"addresses": parsePayoutAddresses(payoutAddresses),
+		"days":      days,
+		"payouts":   list,
+		"totalBtc":  math.Round(total*satsPerBTC) / satsPerBTC,
+	})
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Smart plug outlet controllers: Tasmota over HTTP and TP-Link Kasa over its
+// local TCP protocol.
+
+var plugClient = &http.Client{Timeout: 5 * time.Second}
+
+// plugIndex parses the optional relay/socket number in an outlet URL path.
+func plugIndex(u *url.URL) (int, error) {
+	path := strings.Trim(u.Path, "/")
+	if path == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(path)
+	if err != nil || n < 1 {
+		return 0, errors.New("outlet URL path must be the relay number")
+	}
+	return n, nil
+}
+
+// tasmotaOutlet is a Tasmota plug or relay, configured as
+// tasmota://[user:password@]<host>[/<relay>].
+type tasmotaOutlet struct {
+	host     string
+	user     string
+	password string
+	relay    int // 0 for single-relay devices
+}
+
+func newTasmotaOutlet(u *url.URL) (outletController, error) {
+	relay, err := plugIndex(u)
+	if err != nil {
+		return nil, err
+	}
+	password, _ := u.User.Password()
+	return tasmotaOutlet{host: u.Host, user: u.User.Username(), password: password, relay: relay}, nil
+}
+
+func (t tasmotaOutlet) Key() string {
+	if t.relay > 0 {
+		return fmt.Sprintf("tasmota://%s/%d", t.host, t.relay)
+	}
+	return "tasmota://" + t.host
+}
+
+func (t tasmotaOutlet) DeviceID() (string, error) {
+	id := "tasmota-" + strings.NewReplacer(".", "-", ":", "-").Replace(t.host)
+	if t.relay > 0 {
+		id += fmt.Sprintf("-%d", t.relay)
+	}
+	return id, nil
+}
+
+func (t tasmotaOutlet) powerCommand() string {
+	if t.relay > 0 {
+		return fmt.Sprintf("Power%d", t.relay)
+	}
+	return "Power"
+}
+
+// command runs a Tasmota console command through /cm and decodes the answer.
+func (t tasmotaOutlet) command(ctx context.Context, cmnd string, v interface{}) error {
+	q := url.Values{"cmnd": {cmnd}}
+	if t.user != "" {
+		q.Set("user", t.user)
+		q.Set("password", t.password)
+	}
+	resp, err := httpGet(ctx, plugClient, fmt.Sprintf("http://%s/cm?%s", t.host, q.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to reach tasmota at %s: %w", t.host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("tasmota %s returned status %d: %s", t.host, resp.StatusCode, string(body))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// relayState reads the POWER/POWERn key of a command answer.
+func (t tasmotaOutlet) relayState(answer map[string]interface{}) (bool, error) {
+	key := strings.ToUpper(t.powerCommand())
+	state, ok := answer[key].(string)
+	if !ok && t.relay == 1 {
+		// Single-relay devices answer POWER to Power1
+		state, ok = answer["POWER"].(string)
+	}
+	if !ok {
+		return false, fmt.Errorf("tasmota %s has no %s", t.host, key)
+	}
+	return state == "ON", nil
+}
+
+func (t tasmotaOutlet) Status(ctx context.Context) (*OutletStatus, error) {
+	var answer map[string]interface{}
+	if err := t.command(ctx, t.powerCommand(), &answer); err != nil {
+		return nil, err
+	}
+	on, err := t.relayState(answer)
+	if err != nil {
+		return nil, err
+	}
+	status := &OutletStatus{On: on}
+
+	// Energy readings of plugs with a power meter (Status 8 = sensors)
+	var sensors struct {
+		StatusSNS struct {
+			Energy *struct {
+				Power   json.RawMessage `json:"Power"` // a number, or an array on multi-channel meters
+				Voltage float64         `json:"Voltage"`
+				Current json.RawMessage `json:"Current"`
+			} `json:"ENERGY"`
+		} `json:"StatusSNS"`
+	}
+	if err := t.command(ctx, "Status 8", &sensors); err == nil && sensors.StatusSNS.Energy != nil {
+		e := sensors.StatusSNS.Energy
+		status.Power, status.Metered = tasmotaChannel(e.Power, t.relay)
+		status.Current, _ = tasmotaChannel(e.Current, t.relay)
+		status.Voltage = e.Voltage
+	}
+	return status, nil
+}
+
+// tasmotaChannel reads a meter value that is an array per relay on
+// multi-channel devices.
+func tasmotaChannel(raw json.RawMessage, relay int) (float64, bool) {
+	var v float64
+	if err := json.Unmarshal(raw, &v); err == nil {
+		return v, true
+	}
+	var values []float64
+	if err := json.Unmarshal(raw, &values); err != nil || len(values) == 0 {
+		return 0, false
+	}
+	if relay > 0 && relay <= len(values) {
+		return values[relay-1], true
+	}
+	return values[0], true
+}
+
+func (t tasmotaOutlet) Set(ctx context.Context, on bool) error {
+	shellyWatch.request(t.Key(), on)
+	state := "Off"
+	if on {
+		state = "On"
+	}
+	var answer map[string]interface{}
+	if err := t.command(ctx, t.powerCommand()+" "+state, &answer); err != nil {
+		return err
+	}
+	if got, err := t.relayState(answer); err != nil {
+		return err
+	} else if got != on {
+		return fmt.Errorf("tasmota %s did not switch %s", t.host, strings.ToLower(state))
+	}
+	return nil
+}
+
+// kasaOutlet is a TP-Link Kasa plug, or a socket of a Kasa power strip,
+// configured as kasa://<host>[/<socket>].
+type kasaOutlet struct {
+	addr   string
+	socket int // 0 for single plugs
+}
+
+func newKasaOutlet(u *url.URL) (outletController, error) {
+	socket, err := plugIndex(u)
+	if err != nil {
+		return nil, err
+	}
+	addr := u.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "9999")
+	}
+	return kasaOutlet{addr: addr, socket: socket}, nil
+}
+
+func (k kasaOutlet) Key() string {
+	if k.socket > 0 {
+		return fmt.Sprintf("kasa://%s/%d", k.addr, k.socket)
+	}
+	return "kasa://" + k.addr
+}
+
+func (k kasaOutlet) DeviceID() (string, error) {
+	host, _, _ := net.SplitHostPort(k.addr)
+	id := "kasa-" + strings.ReplaceAll(host, ".", "-")
+	if k.socket > 0 {
+		id += fmt.Sprintf("-%d", k.socket)
+	}
+	return id, nil
+}
+
+// kasaSysinfo is the part of get_sysinfo used to read the relay state.
+type kasaSysinfo struct {
+	RelayState int    `json:"relay_state"`
+	DeviceID   string `json:"deviceId"`
+	Children   []struct {
+		ID    string `json:"id"`
+		State int    `json:"state"`
+	} `json:"children"`
+	ErrCode int `json:"err_code"`
+}
+
+// kasaEncrypt applies the protocol's autokey XOR cipher (initial key 171).
+func kasaEncrypt(b []byte) []byte {
+	out := make([]byte, len(b))
+	key := byte(171)
+	for i, c := range b {
+		key ^= c
+		out[i] = key
+	}
+	return out
+}
+
+func kasaDecrypt(b []byte) []byte {
+	out := make([]byte, len(b))
+	key := byte(171)
+	for i, c := range b {
+		out[i] = key ^ c
+		key = c
+	}
+	return out
+}
+
+// request sends one JSON command and decodes the answer.
+func (k kasaOutlet) request(ctx context.Context, cmd interface{}, v interface{}) error {
+	payload, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+	conn, err := dialContext(ctx, "tcp", k.addr, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to reach kasa plug at %s: %w", k.addr, err)
+	}
+	defer conn.Close()
+
+	msg := make([]byte, 4, 4+len(payload))
+	binary.BigEndian.PutUint32(msg, uint32(len(payload)))
+	if _, err := conn.Write(append(msg, kasaEncrypt(payload)...)); err != nil {
+		return err
+	}
+	var header [4]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return err
+	}
+	n := binary.BigEndian.Uint32(header[:])
+	if n > 1<<20 {
+		return fmt.Errorf("kasa plug %s sent a %d byte answer", k.addr, n)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return err
+	}
+	return json.Unmarshal(kasaDecrypt(body), v)
+}
+
+func (k kasaOutlet) sysinfo(ctx context.Context) (*kasaSysinfo, error) {
+	var answer struct {
+		System struct {
+			Sysinfo kasaSysinfo `json:"get_sysinfo"`
+		} `json:"system"`
+	}
+	if err := k.request(ctx, map[string]interface{}{"system": map[string]interface{}{"get_sysinfo": nil}}, &answer); err != nil {
+		return nil, err
+	}
+	info := &answer.System.Sysinfo
+	if info.ErrCode != 0 {
+		return nil, fmt.Errorf("kasa plug %s returned error %d", k.addr, info.ErrCode)
+	}
+	if k.socket > len(info.Children) {
+		return nil, fmt.Errorf("kasa plug %s has no socket %d", k.addr, k.socket)
+	}
+	return info, nil
+}
+
+// command builds a command, addressed to the socket on power strips.
+func (k kasaOutlet) command(info *kasaSysinfo, module, method string, args interface{}) map[string]interface{} {
+	cmd := map[string]interface{}{module: map[string]interface{}{method: args}}
+	if k.socket > 0 {
+		id := info.Children[k.socket-1].ID
+		if !strings.HasPrefix(id, info.DeviceID) {
+			// Older firmwares list the child ID without the device ID
+			id = info.DeviceID + id
+		}
+		cmd["context"] = map[string]interface{}{"child_ids": []string{id}}
+	}
+	return cmd
+}
+
+func (k kasaOutlet) Status(ctx context.Context) (*OutletStatus, error) {
+	info, err := k.sysinfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status := &OutletStatus{On: info.RelayState == 1}
+	if k.socket > 0 {
+		status.On = info.Children[k.socket-1].State == 1
+	}
+
+	// Plugs with energy monitoring (HS110, KP115, HS300); the units differ by
+	// hardware version
+	var answer struct {
+		Emeter struct {
+			Realtime struct {
+				Power     *float64 `json:"power"`
+				PowerMW   *float64 `json:"power_mw"`
+				Voltage   *float64 `json:"voltage"`
+				VoltageMV *float64 `json:"voltage_mv"`
+				Current   *float64 `json:"current"`
+				CurrentMA *float64 `json:"current_ma"`
+				ErrCode   int      `json:"err_code"`
+			} `json:"get_realtime"`
+		} `json:"emeter"`
+	}
+	if err := k.request(ctx, k.command(info, "emeter", "get_realtime", nil), &answer); err == nil && answer.Emeter.Realtime.ErrCode == 0 {
+		rt := answer.Emeter.Realtime
+		switch {
+		case rt.Power != nil:
+			status.Power, status.Metered = *rt.Power, true
+		case rt.PowerMW != nil:
+			status.Power, status.Metered = *rt.PowerMW/1000, true
+		}
+		switch {
+		case rt.Voltage != nil:
+			status.Voltage = *rt.Voltage
+		case rt.VoltageMV != nil:
+			status.Voltage = *rt.VoltageMV / 1000
+		}
+		switch {
+		case rt.Current != nil:
+			status.Current = *rt.Current
+		case rt.CurrentMA != nil:
+			status.Current = *rt.CurrentMA / 1000
+		}
+	}
+	return status, nil
+}
+
+func (k kasaOutlet) Set(ctx context.Context, on bool) error {
+	shellyWatch.request(k.Key(), on)
+	var info *kasaSysinfo
+	if k.socket > 0 {
+		var err error
+		if info, err = k.sysinfo(ctx); err != nil {
+			return err
+		}
+	}
+	state := 0
+	if on {
+		state = 1
+	}
+	var answer struct {
+		System struct {
+			SetRelayState struct {
+				ErrCode int `json:"err_code"`
+			} `json:"set_relay_state"`
+		} `json:"system"`
+	}
+	if err := k.request(ctx, k.command(info, "system", "set_relay_state", map[string]int{"state": state}), &answer); err != nil {
+		return err
+	}
+	if code := answer.System.SetRelayState.ErrCode; code != 0 {
+		return fmt.Errorf("kasa plug %s returned error %d", k.addr, code)
+	}
+	return nil
+}
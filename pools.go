@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	poolPollSeconds     int     // --pool-poll
+	rejectRatePct       float64 // --reject-rate-pct
+	rejectWindowMinutes int     // --reject-window-minutes
+)
+
+// rejectMinShares is the number of shares a miner must submit within the
+// window before its reject rate is judged, so a few early rejects after a
+// restart don't raise an alert.
+const rejectMinShares = 20
+
+// PoolInfo is the connection state and share counters of one configured pool.
+type PoolInfo struct {
+	URL       string
+	User      string
+	Status    string // as reported by the firmware, e.g. "Alive" or "Dead"
+	Accepted  int64
+	Rejected  int64
+	Stale     int64
+	LastShare time.Time // zero when no share was submitted yet
+}
+
+// poolAlive reports whether a firmware pool status means the pool is
+// connected.
+func poolAlive(status string) bool {
+	switch strings.ToLower(status) {
+	case "alive", "active", "working", "connected":
+		return true
+	}
+	return false
+}
+
+// kaonsuPools reads the pools of a stock firmware miner. The kaonsu API lists
+// pools with cgminer-style counters (accepted, rejected, stale) and the unix
+// time of the last share.
+func kaonsuPools(ctx context.Context, ip string) ([]PoolInfo, error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := httpGet(ctx, client, fmt.Sprintf("http://%s/kaonsu/v1/pools", urlHost(ip)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	var list []struct {
+		URL           string  `json:"url"`
+		User          string  `json:"user"`
+		Status        string  `json:"status"`
+		Accepted      float64 `json:"accepted"`
+		Rejected      float64 `json:"rejected"`
+		Stale         float64 `json:"stale"`
+		LastShareTime float64 `json:"last_share_time"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode pools: %w", err)
+	}
+
+	pools := make([]PoolInfo, 0, len(list))
+	for _, p := range list {
+		pool := PoolInfo{
+			URL:      p.URL,
+			User:     p.User,
+			Status:   p.Status,
+			Accepted: int64(p.Accepted),
+			Rejected: int64(p.Rejected),
+			Stale:    int64(p.Stale),
+		}
+		if p.LastShareTime > 0 {
+			pool.LastShare = time.Unix(int64(p.LastShareTime), 0)
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+// runPoolMonitor reads the pools of every miner at the given interval, writes
+// them to miner_pools and checks connectivity and reject rates.
+func runPoolMonitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		pollPools()
+		checkRejectRates()
+	}
+}
+
+// pollPools writes the pools of every reachable miner and raises an alert for
+// miners that answer without any connected pool. Miners in maintenance are
+// skipped.
+func pollPools() {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var points []questdb.Point
+	for _, m := range currentMachines() {
+		d := driverFor(m.IP)
+		if !d.Capabilities().SupportsPools || maintenanceActive(m) {
+			alerts.clear("pool-down:" + m.IP)
+			continue
+		}
+		wg.Add(1)
+		go func(name, ip string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+			pools, err := d.Pools(ctx, ip)
+			if err != nil {
+				// Unreachable miners are reported by the Shelly watcher
+				log.Printf("Pool monitor: %s (%s): %v", name, ip, err)
+				return
+			}
+
+			alive := false
+			mu.Lock()
+			for _, p := range pools {
+				alive = alive || poolAlive(p.Status)
+				var lastShare int64
+				if !p.LastShare.IsZero() {
+					lastShare = p.LastShare.Unix()
+				}
+				points = append(points, questdb.Point{
+					Table: "miner_pools",
+					Symbols: map[string]string{
+						"miner_ip":  ip,
+						"pool_url":  p.URL,
+						"pool_user": p.User,
+						"status":    p.Status,
+					},
+					Fields: map[string]interface{}{
+						"accepted":   p.Accepted,
+						"rejected":   p.Rejected,
+						"stale":      p.Stale,
+						"last_share": lastShare,
+					},
+				})
+			}
+			mu.Unlock()
+
+			key := "pool-down:" + ip
+			if len(pools) > 0 && !alive {
+				alerts.raise(key, severityWarning, "pools", fmt.Sprintf("%s has no connected pool", name))
+			} else {
+				alerts.clear(key)
+			}
+		}(m.Name, m.IP)
+	}
+	wg.Wait()
+
+	if len(points) == 0 {
+		return
+	}
+	if err := tsStore.Write(points); err != nil {
+		log.Printf("Pool monitor: failed to write pool stats: %v", err)
+	}
+}
+
+// checkRejectRates raises an alert for miners whose share reject rate over
+// --reject-window-minutes reaches --reject-rate-pct.
+func checkRejectRates() {
+	if rejectRatePct <= 0 {
+		return
+	}
+	deltas, err := questdbClient.GetShareDeltas(rejectWindowMinutes)
+	if err != nil {
+		log.Printf("Pool monitor: %v", err)
+		return
+	}
+
+	for _, m := range currentMachines() {
+		key := "pool-rejects:" + m.IP
+		d, ok := deltas[m.IP]
+		total := d.Accepted + d.Rejected
+		if !ok || total < rejectMinShares {
+			alerts.clear(key)
+			continue
+		}
+		pct := d.Rejected / total * 100
+		if pct >= rejectRatePct {
+			alerts.raise(key, severityWarning, "pools", fmt.Sprintf(
+				"%s rejected %.1f%% of shares in the last %d min (%.0f of %.0f)", m.Name, pct, rejectWindowMinutes, d.Rejected, total))
+		} else {
+			alerts.clear(key)
+		}
+	}
+}
+
+// getPoolRejectChartHandler returns accepted and rejected shares per miner IP
+// in 10-minute buckets over the last 24 hours.
+func getPoolRejectChartHandler(c *gin.Context) {
+	result, err := questdbFor(c).GetRejectTimeSeries()
+	if err != nil {
+		log.Printf("Failed to get reject time series from QuestDB: %v", err)
+		c.JSON(http.StatusOK, gin.H{
+			"miners":  map[string][]interface{}{},
+			"hasData": false,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
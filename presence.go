@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// presencePollSeconds is the interval of the network presence checker.
+var presencePollSeconds int
+
+// Network presence of a miner, from its API and the ARP table.
+const (
+	presenceOnline = "online" // API answers
+	presenceHung   = "hung"   // interface on the network, API doesn't answer
+	presenceOff    = "off"    // interface gone: powered off or unplugged
+)
+
+// arpSettle is how long to wait after probing before reading the ARP table.
+// An entry the kernel can no longer confirm turns incomplete after its
+// delay_first_probe_time (5s) and unicast probes (3 x 1s).
+const arpSettle = 10 * time.Second
+
+// Presence is the last checked network presence of a miner.
+type Presence struct {
+	MinerIP   string    `json:"minerIp"`
+	Name      string    `json:"name"`
+	MAC       string    `json:"mac,omitempty"`
+	State     string    `json:"state"`
+	Since     time.Time `json:"since"` // when State was first seen
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// presenceChecker tells apart miners that are powered off from miners whose
+// firmware hangs by checking for their interface in the ARP table.
+type presenceChecker struct {
+	mu     sync.Mutex
+	states map[string]Presence // by miner IP
+}
+
+var presence = &presenceChecker{states: make(map[string]Presence)}
+
+// runPresenceChecker checks every miner at the given interval.
+func runPresenceChecker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		presence.poll()
+	}
+}
+
+func (p *presenceChecker) poll() {
+	current := currentMachines()
+	online := make(map[string]bool, len(current))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, m := range current {
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			// The API request also has the kernel resolve the miner's MAC
+			_, err := driverFor(ip).Config(context.Background(), ip)
+			if err != nil {
+				probe(ip)
+			}
+			mu.Lock()
+			online[ip] = err == nil
+			mu.Unlock()
+		}(m.IP)
+	}
+	wg.Wait()
+
+	time.Sleep(arpSettle)
+	table, err := readARPTable()
+	if err != nil {
+		log.Printf("Presence checker: %v", err)
+		return
+	}
+
+	now := time.Now()
+	p.mu.Lock()
+	states := make(map[string]Presence, len(current))
+	for _, m := range current {
+		s := Presence{MinerIP: m.IP, Name: m.Name, MAC: m.MAC, CheckedAt: now, Since: now}
+		switch {
+		case online[m.IP]:
+			s.State = presenceOnline
+		case onNetwork(m, table):
+			s.State = presenceHung
+		default:
+			s.State = presenceOff
+		}
+		if prev, ok := p.states[m.IP]; ok && prev.State == s.State {
+			s.Since = prev.Since
+		}
+		states[m.IP] = s
+	}
+	p.states = states
+	p.mu.Unlock()
+
+	for _, s := range states {
+		p.evaluate(s)
+	}
+}
+
+// onNetwork reports whether the miner's interface answers ARP at its IP. With
+// a known MAC, another device holding the IP doesn't count.
+func onNetwork(m db.Machine, table map[string]string) bool {
+	mac, ok := table[m.IP]
+	return ok && (m.MAC == "" || mac == m.MAC)
+}
+
+// evaluate raises an alert for a miner whose firmware has been hung for
+// --unreachable-minutes.
+func (p *presenceChecker) evaluate(s Presence) {
+	key := "presence-hung:" + s.MinerIP
+	limit := time.Duration(unreachableMinutes) * time.Minute
+	if s.State == presenceHung && time.Since(s.Since) >= limit && !inMaintenance(s.MinerIP) {
+		alerts.raise(key, severityWarning, "presence", fmt.Sprintf(
+			"%s: on the network but its API hasn't answered for %s (firmware hung?)", s.Name, time.Since(s.Since).Round(time.Minute)))
+	} else {
+		alerts.clear(key)
+	}
+}
+
+// state returns the last presence of a miner, if checked.
+func (p *presenceChecker) state(ip string) (Presence, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.states[ip]
+	return s, ok
+}
+
+// describe explains why an unreachable miner doesn't answer, for alert texts.
+func (p *presenceChecker) describe(ip string) string {
+	s, ok := p.state(ip)
+	if !ok {
+		return "unreachable"
+	}
+	switch s.State {
+	case presenceHung:
+		return "on the network but not answering (firmware hung?)"
+	case presenceOff:
+		return "gone from the network (powered off or unplugged?)"
+	}
+	return "unreachable"
+}
+
+// list returns the last presence of each miner in machine order.
+func (p *presenceChecker) list() []Presence {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	result := make([]Presence, 0, len(p.states))
+	for _, m := range currentMachines() {
+		if s, ok := p.states[m.IP]; ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func getPresenceHandler(c *gin.Context) {
+	states := presence.list()
+	c.JSON(http.StatusOK, gin.H{
+		"miners":  states,
+		"hasData": len(states) > 0,
+	})
+}
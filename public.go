@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Public status: a share token from the settings page opens a read-only page
+// at /public/<token> (and its JSON at /api/public/<token>) with only room-wide
+// totals, no machine names, IPs or counts, for friends or a blog embed.
+
+const (
+	publicStatusTTL    = time.Minute // cache, so public views don't load QuestDB
+	publicUptimeWindow = 7 * 24 * time.Hour
+	shareTouchInterval = time.Hour // granularity of a token's last use
+)
+
+// PublicStatus is the anonymized room status shown to share token holders.
+type PublicStatus struct {
+	Online     bool      `json:"online"`
+	HashrateTH float64   `json:"hashrateTh"`
+	UptimePct  float64   `json:"uptimePct"` // last 7 days
+	RoomTemp   float64   `json:"roomTemp"`  // °C
+	HasData    bool      `json:"hasData"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+var publicStatusCache = struct {
+	mu     sync.Mutex
+	status *PublicStatus
+}{}
+
+// currentPublicStatus returns the public status, computing it at most once per
+// publicStatusTTL.
+func currentPublicStatus(ctx context.Context) *PublicStatus {
+	publicStatusCache.mu.Lock()
+	defer publicStatusCache.mu.Unlock()
+	if s := publicStatusCache.status; s != nil && time.Since(s.UpdatedAt) < publicStatusTTL {
+		return s
+	}
+
+	qdb := questdbClient.WithContext(ctx)
+	now := time.Now()
+	s := &PublicStatus{UpdatedAt: now}
+	if result, err := storeWith(ctx).GetTotalHashrate(); err != nil {
+		log.Printf("Failed to get hashrate for public status: %v", err)
+	} else if result.HasData {
+		s.HasData = true
+		s.Online = isTimestampRecentAt(result.Timestamp, 5*time.Minute, now)
+		s.HashrateTH = math.Round(result.TotalHashrate/1000*10) / 10 // GH/s to TH/s
+	}
+	if stats, err := qdb.GetPeriodStats(now.Add(-publicUptimeWindow), now, maintenanceIPs()); err != nil {
+		log.Printf("Failed to get uptime for public status: %v", err)
+	} else {
+		s.UptimePct = math.Round(stats.UptimePct*10) / 10
+	}
+	if result, err := storeWith(ctx).GetRoomTemperature(indoorLocations()); err != nil {
+		log.Printf("Failed to get room temperature for public status: %v", err)
+	} else if result.HasData {
+		s.RoomTemp = math.Round(result.Temperature*10) / 10
+	}
+
+	publicStatusCache.status = s
+	return s
+}
+
+// shareTokenFor resolves the :token route parameter, answering 404 for
+// unknown or revoked tokens.
+func shareTokenFor(c *gin.Context) (*db.ShareToken, bool) {
+	t, err := database.FetchShareToken(c.Param("token"))
+	if err != nil {
+		log.Printf("Failed to fetch share token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch share token"})
+		return nil, false
+	}
+	if t == nil {
+		render404(c)
+		return nil, false
+	}
+	if now := time.Now(); t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) > shareTouchInterval {
+		if err := database.TouchShareToken(t.ID, now); err != nil {
+			log.Printf("Failed to update share token %d: %v", t.ID, err)
+		}
+	}
+	return t, true
+}
+
+// publicStatusPageHandler renders the public status page. It may be framed by
+// other sites.
+func publicStatusPageHandler(c *gin.Context) {
+	t, ok := shareTokenFor(c)
+	if !ok {
+		return
+	}
+	c.Writer.Header().Del("X-Frame-Options")
+	loc := localeFor(c)
+	s := currentPublicStatus(c.Request.Context())
+	c.HTML(http.StatusOK, "public.html", gin.H{
+		"Title":    "Mining Room",
+		"Lang":     loc.Lang,
+		"Token":    t.Token,
+		"Status":   s,
+		"RoomTemp": loc.Temp(s.RoomTemp),
+		"TempUnit": loc.TempUnit(),
+	})
+}
+
+// getPublicStatusHandler returns the public status as JSON, readable from any
+// origin for embedding.
+func getPublicStatusHandler(c *gin.Context) {
+	if _, ok := shareTokenFor(c); !ok {
+		return
+	}
+	loc := localeFor(c)
+	s := *currentPublicStatus(c.Request.Context())
+	s.RoomTemp = loc.Temp(s.RoomTemp)
+	c.Header("Access-Control-Allow-Origin", "*")
+	c.JSON(http.StatusOK, gin.H{
+		"online":     s.Online,
+		"hashrateTh": s.HashrateTH,
+		"uptimePct":  s.UptimePct,
+		"roomTemp":   s.RoomTemp,
+		"tempUnit":   loc.TempUnit(),
+		"hasData":    s.HasData,
+		"updatedAt":  s.UpdatedAt,
+	})
+}
+
+func getShareTokensHandler(c *gin.Context) {
+	tokens, err := database.FetchShareTokens()
+	if err != nil {
+		log.Printf("Failed to fetch share tokens: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch share tokens"})
+		return
+	}
+	if tokens == nil {
+		tokens = []db.ShareToken{}
+	}
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+type ShareTokenRequest struct {
+	Label string `json:"label"`
+}
+
+// createShareTokenHandler creates a share token with a random value.
+func createShareTokenHandler(c *gin.Context) {
+	var req ShareTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	t, err := database.AddShareToken(hex.EncodeToString(b), req.Label)
+	if err != nil {
+		log.Printf("Failed to save share token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save share token"})
+		return
+	}
+	log.Printf("Created share token %d (%s)", t.ID, t.Label)
+	c.JSON(http.StatusOK, gin.H{"success": true, "token": t})
+}
+
+func deleteShareTokenHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid share token id"})
+		return
+	}
+	found, err := database.DeleteShareToken(id)
+	if err != nil {
+		log.Printf("Failed to delete share token %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete share token"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "share token not found"})
+		return
+	}
+	log.Printf("Revoked share token %d", id)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
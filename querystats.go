@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// queryStats enables per-query QuestDB statistics and slowQueryMs logs
+// queries that take at least that long (0 disables).
+var (
+	queryStats  bool
+	slowQueryMs int
+)
+
+// queryTracer records QuestDB queries when --query-stats or --slow-query-ms
+// is set; nil otherwise.
+var queryTracer *questdb.Tracer
+
+// setupQueryTracing attaches a tracer to the QuestDB client if enabled.
+func setupQueryTracing(client *questdb.Client) *questdb.Client {
+	if !queryStats && slowQueryMs <= 0 {
+		return client
+	}
+	queryTracer = questdb.NewTracer(time.Duration(slowQueryMs) * time.Millisecond)
+	return client.WithTracer(queryTracer)
+}
+
+// getQueryStatsHandler lists the slowest QuestDB statements. ?sort= orders
+// them by their slowest call (max, the default), average (avg) or total
+// time (total); ?limit= caps the list (default 20).
+func getQueryStatsHandler(c *gin.Context) {
+	if queryTracer == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false, "queries": []questdb.QueryStat{}})
+		return
+	}
+	order := c.DefaultQuery("sort", "max")
+	if order != "max" && order != "avg" && order != "total" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be max, avg or total"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+
+	stats := queryTracer.Stats(order)
+	total := len(stats.Queries)
+	if len(stats.Queries) > limit {
+		stats.Queries = stats.Queries[:limit]
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":     true,
+		"slowQueryMs": slowQueryMs,
+		"since":       stats.Since,
+		"total":       total,
+		"untracked":   stats.Untracked,
+		"queries":     stats.Queries,
+	})
+}
+
+// resetQueryStatsHandler discards the collected statistics.
+func resetQueryStatsHandler(c *gin.Context) {
+	if queryTracer != nil {
+		queryTracer.Reset()
+	}
+	c.Status(http.StatusNoContent)
+}
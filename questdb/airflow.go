@@ -0,0 +1,82 @@
+package questdb
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// AirflowSample is one bucket of intake and exhaust temperature (°C, averaged
+// over their locations) and total power (W). Pressure is the intake's in hPa,
+// nil without a pressure reading.
+type AirflowSample struct {
+	Timestamp string
+	Intake    float64
+	Exhaust   float64
+	Power     float64
+	HasPower  bool
+	Pressure  *float64
+}
+
+// GetAirflowSeries returns the samples between from and to in buckets of
+// bucket (a SAMPLE BY interval such as "10m") where both the intake and the
+// exhaust have a reading, oldest first.
+func (c *Client) GetAirflowSeries(intake, exhaust []string, from, to time.Time, bucket string) ([]AirflowSample, error) {
+	if len(intake) == 0 || len(exhaust) == 0 {
+		return nil, nil
+	}
+	query := `SELECT timestamp, avg(temperature) AS temp, avg(pressure) AS pressure FROM bme280_readings
+  WHERE timestamp >= ? AND timestamp < ? AND location IN (?) SAMPLE BY ` + bucket + ` ALIGN TO CALENDAR;`
+	type row struct {
+		Timestamp string   `qdb:"timestamp"`
+		Temp      *float64 `qdb:"temp"`
+		Pressure  *float64 `qdb:"pressure"`
+	}
+
+	result, err := c.Query(query, from, to, intake)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query intake temperature: %w", err)
+	}
+	var intakeRows []row
+	if err := result.Scan(&intakeRows); err != nil {
+		return nil, fmt.Errorf("failed to parse intake temperature: %w", err)
+	}
+	result, err = c.Query(query, from, to, exhaust)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exhaust temperature: %w", err)
+	}
+	var exhaustRows []row
+	if err := result.Scan(&exhaustRows); err != nil {
+		return nil, fmt.Errorf("failed to parse exhaust temperature: %w", err)
+	}
+	power, err := c.totalPowerSeriesBy(bucket, "timestamp >= ? AND timestamp < ?", from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query power: %w", err)
+	}
+
+	exhaustTemps := make(map[string]float64, len(exhaustRows))
+	for _, r := range exhaustRows {
+		if r.Temp != nil {
+			exhaustTemps[r.Timestamp] = *r.Temp
+		}
+	}
+	powers := make(map[string]float64, len(power))
+	for _, p := range power {
+		powers[p.Timestamp] = p.Value
+	}
+
+	var samples []AirflowSample
+	for _, r := range intakeRows {
+		out, ok := exhaustTemps[r.Timestamp]
+		if r.Temp == nil || !ok {
+			continue
+		}
+		s := AirflowSample{Timestamp: r.Timestamp, Intake: *r.Temp, Exhaust: out, Pressure: r.Pressure}
+		s.Power, s.HasPower = powers[r.Timestamp]
+		samples = append(samples, s)
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].Timestamp < samples[j].Timestamp
+	})
+	return samples, nil
+}
@@ -0,0 +1,127 @@
+package questdb
+
+import (
+	"fmt"
+	"sort"
+)
+
+// AltNetwork is the latest network state of the altcoin mined by GPU rigs, as
+// written by the altcoin poller.
+type AltNetwork struct {
+	Timestamp   string  `qdb:"timestamp"`
+	NetHashrate float64 `qdb:"nethash"`      // H/s
+	BlockTime   float64 `qdb:"block_time"`   // seconds
+	BlockReward float64 `qdb:"block_reward"` // coins
+	PriceEUR    float64 `qdb:"price_eur"`
+}
+
+// AltPool is the latest hashrate the pool credits the wallet with.
+type AltPool struct {
+	Timestamp       string  `qdb:"timestamp"`
+	HashrateCurrent float64 `qdb:"hashrate_current"` // H/s
+	HashrateAverage float64 `qdb:"hashrate_average"` // H/s
+	Workers         int     `qdb:"workers"`
+}
+
+// GetLatestAltNetwork returns the latest network state of a coin, or nil
+// without rows in the last hour.
+func (c *Client) GetLatestAltNetwork(coin string) (*AltNetwork, error) {
+	const query = `SELECT timestamp, nethash, block_time, block_reward, price_eur
+  FROM alt_network WHERE coin = ? AND timestamp > dateadd('h', -1, now()) LATEST ON timestamp PARTITION BY coin;`
+
+	result, err := c.Query(query, coin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s network: %w", coin, err)
+	}
+	if len(result.Dataset) == 0 {
+		return nil, nil
+	}
+	var n AltNetwork
+	if err := result.Scan(&n); err != nil {
+		return nil, fmt.Errorf("failed to parse %s network: %w", coin, err)
+	}
+	return &n, nil
+}
+
+// GetLatestAltPool returns the latest pool stats of a coin, or nil without
+// rows in the last hour.
+func (c *Client) GetLatestAltPool(coin string) (*AltPool, error) {
+	const query = `SELECT timestamp, hashrate_current, hashrate_average, workers
+  FROM alt_pool WHERE coin = ? AND timestamp > dateadd('h', -1, now()) LATEST ON timestamp PARTITION BY coin;`
+
+	result, err := c.Query(query, coin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s pool stats: %w", coin, err)
+	}
+	if len(result.Dataset) == 0 {
+		return nil, nil
+	}
+	var p AltPool
+	if err := result.Scan(&p); err != nil {
+		return nil, fmt.Errorf("failed to parse %s pool stats: %w", coin, err)
+	}
+	return &p, nil
+}
+
+// AltHashrateData holds rig hashrates (sum of their GPUs) and the pool's
+// average hashrate in 5-minute buckets, in H/s.
+type AltHashrateData struct {
+	Rigs    map[string][]TimeSeriesPoint `json:"rigs"` // by rig IP
+	Pool    []TimeSeriesPoint            `json:"pool"`
+	HasData bool                         `json:"hasData"`
+}
+
+// GetAltHashrateSeries returns the hashrate of every rig mining algorithm
+// (all rigs when empty) and the pool hashrate of coin over the last 24 hours.
+func (c *Client) GetAltHashrateSeries(coin, algorithm string) (*AltHashrateData, error) {
+	rigQuery := `SELECT timestamp, rig_ip, gpu, avg(hashrate) AS hashrate
+  FROM gpu_status WHERE timestamp > dateadd('h', -24, now()) SAMPLE BY 5m ALIGN TO CALENDAR;`
+	var args []interface{}
+	if algorithm != "" {
+		rigQuery = `SELECT timestamp, rig_ip, gpu, avg(hashrate) AS hashrate
+  FROM gpu_status WHERE algorithm = ? AND timestamp > dateadd('h', -24, now()) SAMPLE BY 5m ALIGN TO CALENDAR;`
+		args = append(args, algorithm)
+	}
+
+	result, err := c.Query(rigQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rig hashrate: %w", err)
+	}
+
+	var readings []GPUReading
+	if err := result.Scan(&readings); err != nil {
+		return nil, fmt.Errorf("failed to parse rig hashrate: %w", err)
+	}
+	sums := make(map[string]map[string]float64) // rig IP -> timestamp -> H/s
+	for _, r := range readings {
+		if sums[r.RigIP] == nil {
+			sums[r.RigIP] = make(map[string]float64)
+		}
+		sums[r.RigIP][r.Timestamp] += r.Hashrate
+	}
+
+	data := &AltHashrateData{Rigs: make(map[string][]TimeSeriesPoint), Pool: []TimeSeriesPoint{}}
+	for ip, byTime := range sums {
+		points := make([]TimeSeriesPoint, 0, len(byTime))
+		for ts, v := range byTime {
+			points = append(points, TimeSeriesPoint{Timestamp: ts, Value: v})
+		}
+		sort.Slice(points, func(i, j int) bool {
+			return points[i].Timestamp < points[j].Timestamp
+		})
+		data.Rigs[ip] = points
+	}
+
+	const poolQuery = `SELECT timestamp, avg(hashrate_average) AS value
+  FROM alt_pool WHERE coin = ? AND timestamp > dateadd('h', -24, now()) SAMPLE BY 5m ALIGN TO CALENDAR;`
+	result, err = c.Query(poolQuery, coin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pool hashrate: %w", err)
+	}
+	if err := result.Scan(&data.Pool); err != nil {
+		return nil, fmt.Errorf("failed to parse pool hashrate: %w", err)
+	}
+
+	data.HasData = len(data.Rigs) > 0 || len(data.Pool) > 0
+	return data, nil
+}
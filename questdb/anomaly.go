@@ -0,0 +1,35 @@
+package questdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// EnvironmentSample is a raw environment reading; fields the sensor doesn't
+// report are nil.
+type EnvironmentSample struct {
+	Timestamp   time.Time `qdb:"timestamp"`
+	Location    string    `qdb:"location"`
+	Temperature *float64  `qdb:"temperature"`
+	Humidity    *float64  `qdb:"humidity"`
+	Pressure    *float64  `qdb:"pressure"`
+}
+
+// GetEnvironmentSamples returns the raw readings of every location since
+// from, grouped by location, oldest first.
+func (c *Client) GetEnvironmentSamples(from time.Time) (map[string][]EnvironmentSample, error) {
+	const query = `SELECT timestamp, location, temperature, humidity, pressure FROM bme280_readings WHERE timestamp >= ? ORDER BY timestamp;`
+	result, err := c.Query(query, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query environment readings: %w", err)
+	}
+	var rows []EnvironmentSample
+	if err := result.Scan(&rows); err != nil {
+		return nil, fmt.Errorf("failed to parse environment readings: %w", err)
+	}
+	samples := make(map[string][]EnvironmentSample)
+	for _, r := range rows {
+		samples[r.Location] = append(samples[r.Location], r)
+	}
+	return samples, nil
+}
@@ -0,0 +1,115 @@
+package questdb
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// WithAsOf returns a copy of the client whose queries see the database as it
+// was at t: now() and today() refer to t and rows written after t are
+// ignored. The zero time queries the live data.
+func (c *Client) WithAsOf(t time.Time) *Client {
+	c2 := *c
+	c2.asOf = t
+	return &c2
+}
+
+// AsOf returns the replay time of the client, or the zero time for live data.
+func (c *Client) AsOf() time.Time {
+	return c.asOf
+}
+
+var (
+	// asOfTable matches a table read; function reads like
+	// FROM table_columns('x') and subqueries are left alone.
+	asOfTable = regexp.MustCompile(`\bFROM ([a-z_][a-z0-9_]*)\b(\s*\()?`)
+
+	// asOfWhere matches the WHERE keyword following a table read.
+	asOfWhere = regexp.MustCompile(`^\s+WHERE\s`)
+
+	// asOfClauseEnd matches the keywords ending a WHERE condition.
+	asOfClauseEnd = regexp.MustCompile(`^\s+(LATEST ON|SAMPLE BY|GROUP BY|ORDER BY|LIMIT)\b`)
+)
+
+// asOfQuery rewrites query to read the tables as of t. Every table read gets
+// a `timestamp <= t` bound (existing conditions are kept in parentheses),
+// now() becomes t and today() becomes the start of t's day in loc, the same
+// calendar day as Client.today.
+func asOfQuery(query string, t time.Time, loc *time.Location) string {
+	ts := fmt.Sprintf("cast(%d as timestamp)", t.UnixMicro())
+	local := t.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	query = strings.ReplaceAll(query, "now()", ts)
+	query = strings.ReplaceAll(query, "today()", fmt.Sprintf("cast(%d as timestamp)", midnight.UnixMicro()))
+
+	return boundTableReads(query, func(string) string {
+		return "timestamp <= " + ts
+	})
+}
+
+// boundTableReads adds the condition cond(table) to every table read in
+// query, ANDed with the read's existing WHERE condition. Reads for which cond
+// returns "" are left alone.
+func boundTableReads(query string, cond func(table string) string) string {
+	var b strings.Builder
+	for {
+		loc := asOfTable.FindStringSubmatchIndex(query)
+		if loc == nil {
+			b.WriteString(query)
+			return b.String()
+		}
+		if loc[4] >= 0 { // FROM func(...)
+			b.WriteString(query[:loc[1]])
+			query = query[loc[1]:]
+			continue
+		}
+		b.WriteString(query[:loc[1]])
+		rest := query[loc[1]:]
+		c := cond(query[loc[2]:loc[3]])
+		if c == "" {
+			query = rest
+			continue
+		}
+		bound := " WHERE " + c
+		if w := asOfWhere.FindStringIndex(rest); w != nil {
+			after := rest[w[1]:]
+			end := whereEnd(after)
+			b.WriteString(bound + " AND (" + after[:end] + ")")
+			rest = after[end:]
+		} else {
+			b.WriteString(bound)
+		}
+		query = rest
+	}
+}
+
+// whereEnd returns the length of the WHERE condition at the start of s: up to
+// the first closing clause keyword or unmatched ')' outside parentheses and
+// string literals.
+func whereEnd(s string) int {
+	depth := 0
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case ch == '\'':
+			quoted = !quoted
+		case quoted:
+		case ch == ';':
+			if depth == 0 {
+				return i
+			}
+		case ch == '(':
+			depth++
+		case ch == ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		case depth == 0 && asOfClauseEnd.MatchString(s[i:]):
+			return i
+		}
+	}
+	return len(strings.TrimRight(s, " \n\t"))
+}
@@ -0,0 +1,38 @@
+package questdb
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAsOfQueryToday(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Ljubljana")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	tests := []struct {
+		name     string
+		asOf     time.Time
+		loc      *time.Location
+		midnight time.Time
+	}{
+		{"UTC", time.Date(2026, 3, 10, 23, 30, 0, 0, time.UTC), time.UTC, time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)},
+		{"after local midnight", time.Date(2026, 3, 10, 23, 30, 0, 0, time.UTC), loc, time.Date(2026, 3, 11, 0, 0, 0, 0, loc)},
+		{"before UTC midnight in summer", time.Date(2026, 7, 1, 22, 15, 0, 0, time.UTC), loc, time.Date(2026, 7, 2, 0, 0, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := asOfQuery("SELECT * FROM shellies WHERE timestamp >= today();", tt.asOf, tt.loc)
+			want := fmt.Sprintf("timestamp >= cast(%d as timestamp)", tt.midnight.UnixMicro())
+			if !strings.Contains(got, want) {
+				t.Errorf("asOfQuery() = %q, want it to contain %q", got, want)
+			}
+			bound := fmt.Sprintf("timestamp <= cast(%d as timestamp)", tt.asOf.UnixMicro())
+			if !strings.Contains(got, bound) {
+				t.Errorf("asOfQuery() = %q, want it to contain %q", got, bound)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package questdb
+
+import (
+	"fmt"
+	"time"
+
+	"miningRoom/questdb/schema"
+)
+
+// MinerUsage is a miner's reported energy and hashrate integrated over a
+// window. Buckets without a report count as zero.
+type MinerUsage struct {
+	EnergyKWh float64 `json:"energyKwh"` // from the miner's reported power
+	THHours   float64 `json:"thHours"`   // TH/s x hours
+}
+
+// billingBucketHours is the length of the SAMPLE BY 10m buckets integrated by
+// the billing queries.
+const billingBucketHours = 10.0 / 60
+
+// GetDeviceEnergy returns the energy per outlet device ID in kWh between from
+// and to, integrated over 10 minute buckets.
+func (c *Client) GetDeviceEnergy(from, to time.Time) (map[string]float64, error) {
+	const query = `SELECT timestamp, device_id, avg(power) AS power FROM shellies WHERE timestamp >= ? AND timestamp < ? SAMPLE BY 10m ALIGN TO CALENDAR;`
+	result, err := c.Query(query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query device energy: %w", err)
+	}
+	var readings []DevicePowerReading
+	if err := result.Scan(&readings); err != nil {
+		return nil, fmt.Errorf("failed to parse device energy: %w", err)
+	}
+
+	energy := make(map[string]float64)
+	for _, r := range readings {
+		energy[r.DeviceID] += r.Power * billingBucketHours / 1000
+	}
+	return energy, nil
+}
+
+// GetMinerUsage returns the reported energy and hashrate per miner IP between
+// from and to, integrated over 10 minute buckets.
+func (c *Client) GetMinerUsage(from, to time.Time) (map[string]MinerUsage, error) {
+	const query = `SELECT timestamp, miner_ip, avg(power) AS power, avg(hashrate) AS hashrate FROM miner_status WHERE timestamp >= ? AND timestamp < ? SAMPLE BY 10m ALIGN TO CALENDAR;`
+	result, err := c.Query(query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query miner usage: %w", err)
+	}
+	var rows []schema.MinerStatus
+	if err := result.Scan(&rows); err != nil {
+		return nil, fmt.Errorf("failed to parse miner usage: %w", err)
+	}
+
+	usage := make(map[string]MinerUsage)
+	for _, r := range rows {
+		u := usage[r.MinerIP]
+		u.EnergyKWh += r.Power * billingBucketHours / 1000
+		u.THHours += r.Hashrate / 1000 * billingBucketHours // GH/s to TH/s
+		usage[r.MinerIP] = u
+	}
+	return usage, nil
+}
@@ -0,0 +1,31 @@
+package questdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// GetAvgBTCPrice returns the mean of the daily average BTC prices in currency
+// between from and to, and the number of days with a price. Days with live
+// readings and backfilled ones weigh the same.
+func (c *Client) GetAvgBTCPrice(currency string, from, to time.Time) (float64, int, error) {
+	const query = `SELECT timestamp, avg(price) AS price FROM btc_price WHERE currency = ? AND timestamp >= ? AND timestamp < ? SAMPLE BY 1d ALIGN TO CALENDAR;`
+	result, err := c.Query(query, currency, from, to)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query BTC prices: %w", err)
+	}
+	var days []struct {
+		Price float64 `qdb:"price"`
+	}
+	if err := result.Scan(&days); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse BTC prices: %w", err)
+	}
+	if len(days) == 0 {
+		return 0, 0, nil
+	}
+	total := 0.0
+	for _, d := range days {
+		total += d.Price
+	}
+	return total / float64(len(days)), len(days), nil
+}
@@ -0,0 +1,140 @@
+package questdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// ChartQuery is a chart requested through the chart builder: one column of a
+// table aggregated per time bucket, optionally split by a symbol column.
+type ChartQuery struct {
+	Table       string
+	Column      string
+	Aggregation string // one of ChartAggregations
+	GroupBy     string // symbol column, empty for a single series
+	From, To    time.Time
+	Bucket      string // one of ChartBuckets, empty picks one from the range
+}
+
+// ChartAggregations are the aggregate functions a chart may apply per bucket.
+var ChartAggregations = map[string]bool{
+	"avg":   true,
+	"min":   true,
+	"max":   true,
+	"sum":   true,
+	"count": true,
+	"first": true,
+	"last":  true,
+}
+
+// ChartBucket is a SAMPLE BY interval of the chart builder.
+type ChartBucket struct {
+	Name     string
+	Duration time.Duration
+}
+
+// ChartBuckets are the allowed bucket sizes, smallest first.
+var ChartBuckets = []ChartBucket{
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+	{"15m", 15 * time.Minute},
+	{"30m", 30 * time.Minute},
+	{"1h", time.Hour},
+	{"3h", 3 * time.Hour},
+	{"6h", 6 * time.Hour},
+	{"12h", 12 * time.Hour},
+	{"1d", 24 * time.Hour},
+}
+
+const (
+	// chartMaxPoints is the number of buckets per series a picked bucket aims
+	// to stay under.
+	chartMaxPoints = 300
+	// chartRowLimit caps the rows of a chart query across all series.
+	chartRowLimit = 20000
+)
+
+// PickChartBucket returns the smallest bucket keeping a series between from
+// and to under chartMaxPoints points.
+func PickChartBucket(from, to time.Time) string {
+	span := to.Sub(from)
+	for _, b := range ChartBuckets {
+		if span/b.Duration <= chartMaxPoints {
+			return b.Name
+		}
+	}
+	return ChartBuckets[len(ChartBuckets)-1].Name
+}
+
+// ValidChartBucket reports whether name is one of ChartBuckets.
+func ValidChartBucket(name string) bool {
+	for _, b := range ChartBuckets {
+		if b.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ChartData holds chart builder series keyed by group value, or "total"
+// without grouping. Truncated is set when the row limit cut the result short.
+type ChartData struct {
+	Bucket    string                       `json:"bucket"`
+	Series    map[string][]TimeSeriesPoint `json:"series"`
+	Truncated bool                         `json:"truncated"`
+	HasData   bool                         `json:"hasData"`
+}
+
+// GetChart runs a chart builder query. Table and columns are bound as
+// identifiers; callers should still only pass whitelisted ones.
+func (c *Client) GetChart(q ChartQuery) (*ChartData, error) {
+	if !ChartAggregations[q.Aggregation] {
+		return nil, fmt.Errorf("invalid aggregation %q", q.Aggregation)
+	}
+	if !q.From.Before(q.To) {
+		return nil, fmt.Errorf("empty time range")
+	}
+	if q.Bucket == "" {
+		q.Bucket = PickChartBucket(q.From, q.To)
+	}
+	if !ValidChartBucket(q.Bucket) {
+		return nil, fmt.Errorf("invalid bucket %q", q.Bucket)
+	}
+
+	// The aggregation and bucket are checked against their whitelists above
+	group := interface{}("total")
+	if q.GroupBy != "" {
+		group = Ident(q.GroupBy)
+	}
+	query := "SELECT timestamp, ? AS series, " + q.Aggregation + "(?) AS value FROM ? WHERE timestamp >= ? AND timestamp < ? SAMPLE BY " + q.Bucket + " ALIGN TO CALENDAR ORDER BY timestamp LIMIT ?;"
+
+	result, err := c.Query(query, group, Ident(q.Column), Ident(q.Table), q.From, q.To, chartRowLimit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s.%s chart: %w", q.Table, q.Column, err)
+	}
+
+	data := &ChartData{
+		Bucket: q.Bucket,
+		Series: make(map[string][]TimeSeriesPoint),
+	}
+	var rows []struct {
+		Timestamp string   `qdb:"timestamp"`
+		Series    string   `qdb:"series"`
+		Value     *float64 `qdb:"value"`
+	}
+	if err := result.Scan(&rows); err != nil {
+		return nil, fmt.Errorf("failed to parse %s.%s chart: %w", q.Table, q.Column, err)
+	}
+	if len(rows) > chartRowLimit {
+		rows = rows[:chartRowLimit]
+		data.Truncated = true
+	}
+	for _, r := range rows {
+		if r.Value != nil {
+			data.Series[r.Series] = append(data.Series[r.Series], TimeSeriesPoint{Timestamp: r.Timestamp, Value: *r.Value})
+		}
+	}
+
+	data.HasData = len(data.Series) > 0
+	return data, nil
+}
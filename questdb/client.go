@@ -1,18 +1,29 @@
 package questdb
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"reflect"
 	"sort"
 	"time"
+
+	"miningRoom/questdb/schema"
 )
 
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
+	baseURL     string
+	httpClient  *http.Client
+	ctx         context.Context // nil: context.Background()
+	asOf        time.Time       // zero: live data
+	scope       *Scope          // nil: all rows
+	tracer      *Tracer         // nil: queries aren't traced
+	failover    *failover       // nil: no secondary instance
+	location    *time.Location  // nil: calendar days in UTC
+	validRanges []ValidRange    // nil: all values
 }
 
 type Column struct {
@@ -25,6 +36,41 @@ type QueryResult struct {
 	Columns []Column        `json:"columns"`
 	Dataset [][]interface{} `json:"dataset"`
 	Count   int             `json:"count"`
+
+	Instance string `json:"-"` // InstancePrimary or InstanceSecondary
+}
+
+// Scan decodes the result into dest, matching columns to struct fields by
+// their qdb tags (see schema.Scan). A pointer to a slice of structs receives
+// every row; a pointer to a struct receives the first row and is left as is
+// when there are none.
+func (r *QueryResult) Scan(dest interface{}) error {
+	columns := make([]string, len(r.Columns))
+	for i, col := range r.Columns {
+		columns[i] = col.Name
+	}
+
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("scan destination must be a pointer, not %T", dest)
+	}
+	if v.Elem().Kind() == reflect.Struct {
+		if len(r.Dataset) == 0 {
+			return nil
+		}
+		return schema.Scan(columns, r.Dataset[0], dest)
+	}
+	if v.Elem().Kind() != reflect.Slice || v.Elem().Type().Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("scan destination must point to a struct or a slice of structs, not %T", dest)
+	}
+	rows := reflect.MakeSlice(v.Elem().Type(), len(r.Dataset), len(r.Dataset))
+	for i, values := range r.Dataset {
+		if err := schema.Scan(columns, values, rows.Index(i).Addr().Interface()); err != nil {
+			return fmt.Errorf("row %d: %w", i, err)
+		}
+	}
+	v.Elem().Set(rows)
+	return nil
 }
 
 // TotalHashrateResult represents the parsed result of the total hashrate query
@@ -50,8 +96,9 @@ type AvgTemperatureResult struct {
 // TotalPowerResult represents the parsed result of the total power query
 type TotalPowerResult struct {
 	Timestamp  string  // ISO 8601 timestamp of the latest data
-	TotalPower float64 // Sum of power across all Shelly devices
+	TotalPower float64 // Room meter power, or the sum across all Shelly devices
 	HasData    bool    // Whether any data was returned
+	Source     string  // "meter" or "plugs"
 }
 
 // RoomTemperatureResult represents the parsed result of the room temperature query
@@ -70,12 +117,85 @@ func NewClient(host string, port int) *Client {
 	}
 }
 
-func (c *Client) Query(query string) (*QueryResult, error) {
-	endpoint := fmt.Sprintf("%s/exec", c.baseURL)
+// WithContext returns a copy of the client whose queries and writes are
+// canceled when ctx is done, e.g. when the HTTP client that asked for the data
+// goes away.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	c2 := *c
+	c2.ctx = ctx
+	return &c2
+}
+
+func (c *Client) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// Query runs a SQL statement. With args, the ? placeholders in query are
+// replaced by the arguments' literals (see Bind); without, query runs as is.
+func (c *Client) Query(query string, args ...interface{}) (*QueryResult, error) {
+	start := time.Now()
+	result, executed, kind, err := c.exec(query, args...)
+	if c.tracer != nil {
+		rows := 0
+		if result != nil {
+			rows = len(result.Dataset)
+		}
+		c.tracer.record(query, executed, time.Since(start), rows, kind, err)
+	}
+	return result, err
+}
+
+// exec runs a statement for Query, returning the SQL sent to QuestDB and, on
+// failure, the class of the error (see Tracer).
+func (c *Client) exec(query string, args ...interface{}) (*QueryResult, string, string, error) {
+	if len(args) > 0 {
+		bound, err := Bind(query, args...)
+		if err != nil {
+			return nil, query, ErrorBind, fmt.Errorf("failed to bind query: %w", err)
+		}
+		query = bound
+	}
+
+	if !c.asOf.IsZero() {
+		query = asOfQuery(query, c.asOf, c.loc())
+	}
+	if len(c.validRanges) > 0 {
+		query = validQuery(query, c.validRanges)
+	}
+	if c.scope != nil {
+		scoped, err := scopeQuery(query, c.scope)
+		if err != nil {
+			return nil, query, ErrorScope, err
+		}
+		query = scoped
+	}
+
+	instance := c.readInstance(query)
+	result, kind, err := c.execOn(instance, query)
+	if err != nil && instance == InstancePrimary && c.failover.shouldRetry(query, kind, c.context()) {
+		c.failover.set(false, err)
+		instance = c.readInstance(query)
+		result, kind, err = c.execOn(instance, query)
+	}
+	if err != nil {
+		return nil, query, kind, err
+	}
+	result.Instance = instance
+	return result, query, "", nil
+}
 
-	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+// execOn sends a bound statement to the primary or secondary instance.
+func (c *Client) execOn(instance, query string) (*QueryResult, string, error) {
+	baseURL := c.baseURL
+	if instance == InstanceSecondary {
+		baseURL = c.failover.secondaryURL
+	}
+	req, err := http.NewRequestWithContext(c.context(), http.MethodGet, baseURL+"/exec", nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, ErrorNetwork, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	q := url.Values{}
@@ -84,124 +204,86 @@ func (c *Client) Query(query string) (*QueryResult, error) {
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
+		return nil, requestErrorKind(err), fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("query failed with status %d: %s", resp.StatusCode, string(body))
+		kind := ErrorServer
+		if resp.StatusCode == http.StatusBadRequest {
+			kind = ErrorSQL
+		}
+		return nil, kind, fmt.Errorf("query failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, requestErrorKind(err), fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	var result QueryResult
 	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		return nil, ErrorDecode, fmt.Errorf("failed to parse response: %w", err)
 	}
-
-	return &result, nil
+	return &result, "", nil
 }
 
 // GetTotalHashrate queries QuestDB for the latest total hashrate across all miners
 // It uses a LATEST ON query to get the most recent reading from each miner/pool combination
 // and sums them together to get the total hashrate.
 func (c *Client) GetTotalHashrate() (*TotalHashrateResult, error) {
-	const query = "SELECT timestamp, sum(hashrate_average) FROM pools LATEST ON timestamp PARTITION BY miner_ip;"
+	const query = "SELECT timestamp, sum(hashrate_average) AS value FROM pools LATEST ON timestamp PARTITION BY miner_ip;"
 
-	result, err := c.Query(query)
+	p, err := c.latestValue(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query total hashrate: %w", err)
 	}
-
-	// Check if we have any data
-	if result.Count == 0 || len(result.Dataset) == 0 {
-		return &TotalHashrateResult{
-			HasData: false,
-		}, nil
-	}
-
-	// Parse the first row: [timestamp, sum(hashrate_average)]
-	row := result.Dataset[0]
-	if len(row) < 2 {
-		return nil, fmt.Errorf("unexpected result format: expected 2 columns, got %d", len(row))
-	}
-
-	// Parse timestamp (string)
-	timestamp, ok := row[0].(string)
-	if !ok {
-		return nil, fmt.Errorf("unexpected timestamp type: %T", row[0])
-	}
-
-	// Parse hashrate (float64)
-	var hashrate float64
-	switch v := row[1].(type) {
-	case float64:
-		hashrate = v
-	case int:
-		hashrate = float64(v)
-	case int64:
-		hashrate = float64(v)
-	default:
-		return nil, fmt.Errorf("unexpected hashrate type: %T", row[1])
+	if p == nil {
+		return &TotalHashrateResult{HasData: false}, nil
 	}
 
 	return &TotalHashrateResult{
-		Timestamp:     timestamp,
-		TotalHashrate: hashrate,
+		Timestamp:     p.Timestamp,
+		TotalHashrate: p.Value,
 		HasData:       true,
 	}, nil
 }
 
+// latestValue runs a query returning a single timestamp and value row, or no
+// rows (nil).
+func (c *Client) latestValue(query string, args ...interface{}) (*TimeSeriesPoint, error) {
+	result, err := c.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Dataset) == 0 {
+		return nil, nil
+	}
+	var p TimeSeriesPoint
+	if err := result.Scan(&p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
 // GetMaxTemperature queries QuestDB for the maximum temperature across all hashboards.
 // It uses a LATEST ON query to get the most recent reading from each miner/hashboard,
 // takes the higher of the two temperature sensors, and returns the max.
 func (c *Client) GetMaxTemperature() (*MaxTemperatureResult, error) {
-	const query = `SELECT timestamp, max(CASE WHEN temperature_raw_1>=temperature_raw_0 THEN temperature_raw_1 ELSE temperature_raw_0 END) AS max_temp FROM hashboards LATEST ON timestamp PARTITION BY miner_ip, idx;`
+	const query = `SELECT timestamp, max(CASE WHEN temperature_raw_1>=temperature_raw_0 THEN temperature_raw_1 ELSE temperature_raw_0 END) AS value FROM hashboards LATEST ON timestamp PARTITION BY miner_ip, idx;`
 
-	result, err := c.Query(query)
+	p, err := c.latestValue(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query max temperature: %w", err)
 	}
-
-	// Check if we have any data
-	if result.Count == 0 || len(result.Dataset) == 0 {
-		return &MaxTemperatureResult{
-			HasData: false,
-		}, nil
-	}
-
-	// Parse the first row: [timestamp, max_temp]
-	row := result.Dataset[0]
-	if len(row) < 2 {
-		return nil, fmt.Errorf("unexpected result format: expected 2 columns, got %d", len(row))
-	}
-
-	// Parse timestamp (string)
-	timestamp, ok := row[0].(string)
-	if !ok {
-		return nil, fmt.Errorf("unexpected timestamp type: %T", row[0])
-	}
-
-	// Parse temperature (float64 or int)
-	var temperature float64
-	switch v := row[1].(type) {
-	case float64:
-		temperature = v
-	case int:
-		temperature = float64(v)
-	case int64:
-		temperature = float64(v)
-	default:
-		return nil, fmt.Errorf("unexpected temperature type: %T", row[1])
+	if p == nil {
+		return &MaxTemperatureResult{HasData: false}, nil
 	}
 
 	return &MaxTemperatureResult{
-		Timestamp:      timestamp,
-		MaxTemperature: temperature,
+		Timestamp:      p.Timestamp,
+		MaxTemperature: p.Value,
 		HasData:        true,
 	}, nil
 }
@@ -210,116 +292,76 @@ func (c *Client) GetMaxTemperature() (*MaxTemperatureResult, error) {
 // For each miner it takes the max of the two temperature sensors across all hashboards,
 // then averages those maxes across all miners.
 func (c *Client) GetAvgMaxTemperature() (*AvgTemperatureResult, error) {
-	const query = `SELECT avg(max_temp) FROM (SELECT miner_ip, max(CASE WHEN temperature_raw_1>=temperature_raw_0 THEN temperature_raw_1 ELSE temperature_raw_0 END) AS max_temp FROM hashboards LATEST ON timestamp PARTITION BY miner_ip, idx);`
+	const query = `SELECT avg(max_temp) AS value FROM (SELECT miner_ip, max(CASE WHEN temperature_raw_1>=temperature_raw_0 THEN temperature_raw_1 ELSE temperature_raw_0 END) AS max_temp FROM hashboards LATEST ON timestamp PARTITION BY miner_ip, idx);`
 
-	result, err := c.Query(query)
+	p, err := c.latestValue(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query avg max temperature: %w", err)
 	}
-
-	if result.Count == 0 || len(result.Dataset) == 0 || len(result.Dataset[0]) == 0 {
+	if p == nil {
 		return &AvgTemperatureResult{HasData: false}, nil
 	}
 
 	return &AvgTemperatureResult{
-		AvgTemperature: parseFloat(result.Dataset[0][0]),
+		AvgTemperature: p.Value,
 		HasData:        true,
 	}, nil
 }
 
-// GetTotalPower queries QuestDB for the total power consumption across all Shelly devices.
-// It uses a LATEST ON query to get the most recent reading from each device and sums them.
+// GetTotalPower returns the power of the room meter when it has a recent
+// reading, and the sum of the plugs otherwise.
 func (c *Client) GetTotalPower() (*TotalPowerResult, error) {
-	const query = "SELECT timestamp, sum(power) FROM shellies LATEST ON timestamp PARTITION BY device_id;"
-
-	result, err := c.Query(query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query total power: %w", err)
-	}
-
-	// Check if we have any data
-	if result.Count == 0 || len(result.Dataset) == 0 {
+	if m := c.freshRoomMeter(); m != nil {
 		return &TotalPowerResult{
-			HasData: false,
+			Timestamp:  m.Timestamp,
+			TotalPower: m.Power,
+			HasData:    true,
+			Source:     "meter",
 		}, nil
 	}
+	return c.GetPlugsPower()
+}
 
-	// Parse the first row: [timestamp, sum(power)]
-	row := result.Dataset[0]
-	if len(row) < 2 {
-		return nil, fmt.Errorf("unexpected result format: expected 2 columns, got %d", len(row))
-	}
+// GetPlugsPower queries QuestDB for the total power consumption across all Shelly devices.
+// It uses a LATEST ON query to get the most recent reading from each device and sums them.
+func (c *Client) GetPlugsPower() (*TotalPowerResult, error) {
+	const query = "SELECT timestamp, sum(power) AS value FROM shellies LATEST ON timestamp PARTITION BY device_id;"
 
-	// Parse timestamp (string)
-	timestamp, ok := row[0].(string)
-	if !ok {
-		return nil, fmt.Errorf("unexpected timestamp type: %T", row[0])
+	p, err := c.latestValue(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query total power: %w", err)
 	}
-
-	// Parse power (float64 or int)
-	var power float64
-	switch v := row[1].(type) {
-	case float64:
-		power = v
-	case int:
-		power = float64(v)
-	case int64:
-		power = float64(v)
-	default:
-		return nil, fmt.Errorf("unexpected power type: %T", row[1])
+	if p == nil {
+		return &TotalPowerResult{HasData: false}, nil
 	}
 
 	return &TotalPowerResult{
-		Timestamp:  timestamp,
-		TotalPower: power,
+		Timestamp:  p.Timestamp,
+		TotalPower: p.Value,
 		HasData:    true,
+		Source:     "plugs",
 	}, nil
 }
 
-// GetRoomTemperature queries QuestDB for the room temperature from the BME280 sensor.
-func (c *Client) GetRoomTemperature() (*RoomTemperatureResult, error) {
-	const query = "SELECT timestamp, temperature FROM bme280_readings WHERE location='miningroom' ORDER BY timestamp DESC LIMIT 1;"
+// GetRoomTemperature queries QuestDB for the room temperature: the latest
+// BME280 reading at any of the given indoor locations.
+func (c *Client) GetRoomTemperature(locations []string) (*RoomTemperatureResult, error) {
+	if len(locations) == 0 {
+		return &RoomTemperatureResult{HasData: false}, nil
+	}
+	const query = "SELECT timestamp, temperature AS value FROM bme280_readings WHERE location IN (?) ORDER BY timestamp DESC LIMIT 1;"
 
-	result, err := c.Query(query)
+	p, err := c.latestValue(query, locations)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query room temperature: %w", err)
 	}
-
-	// Check if we have any data
-	if result.Count == 0 || len(result.Dataset) == 0 {
-		return &RoomTemperatureResult{
-			HasData: false,
-		}, nil
-	}
-
-	// Parse the first row: [timestamp, temperature]
-	row := result.Dataset[0]
-	if len(row) < 2 {
-		return nil, fmt.Errorf("unexpected result format: expected 2 columns, got %d", len(row))
-	}
-
-	// Parse timestamp (string)
-	timestamp, ok := row[0].(string)
-	if !ok {
-		return nil, fmt.Errorf("unexpected timestamp type: %T", row[0])
-	}
-
-	// Parse temperature (float64 or int)
-	var temperature float64
-	switch v := row[1].(type) {
-	case float64:
-		temperature = v
-	case int:
-		temperature = float64(v)
-	case int64:
-		temperature = float64(v)
-	default:
-		return nil, fmt.Errorf("unexpected temperature type: %T", row[1])
+	if p == nil {
+		return &RoomTemperatureResult{HasData: false}, nil
 	}
 
 	return &RoomTemperatureResult{
-		Timestamp:   timestamp,
-		Temperature: temperature,
+		Timestamp:   p.Timestamp,
+		Temperature: p.Value,
 		HasData:     true,
 	}, nil
 }
@@ -335,6 +377,14 @@ type MinerStatusRow struct {
 	Power          float64 `json:"power"`
 	Efficiency     float64 `json:"efficiency"`
 	TemperatureMax float64 `json:"temperatureMax"`
+	Network        string  `json:"network,omitempty"` // online, hung or off, from the presence checker
+
+	Pool *MinerPoolSummary `json:"pool,omitempty"` // nil without pool stats in the last 10 minutes
+
+	Maintenance       bool       `json:"maintenance"`
+	MaintenanceReason string     `json:"maintenanceReason,omitempty"`
+	MaintenanceUntil  *time.Time `json:"maintenanceUntil,omitempty"`
+	MaintenanceDue    []string   `json:"maintenanceDue,omitempty"` // kinds of logged work whose reminder is due
 }
 
 // MinerStatusData holds the list of per-miner status rows
@@ -345,38 +395,28 @@ type MinerStatusData struct {
 
 // GetMinerStatuses queries QuestDB for the latest status of each miner.
 func (c *Client) GetMinerStatuses() (*MinerStatusData, error) {
-	const query = `SELECT timestamp, miner_ip, status, work_mode, hashrate, power, efficiency, temperature_max
-  FROM miner_status LATEST ON timestamp PARTITION BY miner_ip;`
+	query := "SELECT " + schema.SelectList(schema.MinerStatus{}) + " FROM " + schema.TableMinerStatus + " LATEST ON timestamp PARTITION BY miner_ip;"
 
 	result, err := c.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query miner statuses: %w", err)
 	}
-
-	if result.Count == 0 || len(result.Dataset) == 0 {
-		return &MinerStatusData{HasData: false}, nil
+	var rows []schema.MinerStatus
+	if err := result.Scan(&rows); err != nil {
+		return nil, fmt.Errorf("failed to parse miner statuses: %w", err)
 	}
 
-	miners := make([]MinerStatusRow, 0, len(result.Dataset))
-	for _, row := range result.Dataset {
-		if len(row) < 8 {
-			continue
-		}
-
-		timestamp, _ := row[0].(string)
-		minerIP, _ := row[1].(string)
-		status, _ := row[2].(string)
-		workMode, _ := row[3].(string)
-
+	miners := make([]MinerStatusRow, 0, len(rows))
+	for _, r := range rows {
 		miners = append(miners, MinerStatusRow{
-			Timestamp:      timestamp,
-			MinerIP:        minerIP,
-			Status:         status,
-			WorkMode:       workMode,
-			Hashrate:       parseFloat(row[4]),
-			Power:          parseFloat(row[5]),
-			Efficiency:     parseFloat(row[6]),
-			TemperatureMax: parseFloat(row[7]),
+			Timestamp:      schema.FormatTime(r.Timestamp),
+			MinerIP:        r.MinerIP,
+			Status:         r.Status,
+			WorkMode:       r.WorkMode,
+			Hashrate:       r.Hashrate,
+			Power:          r.Power,
+			Efficiency:     r.Efficiency,
+			TemperatureMax: r.TemperatureMax,
 		})
 	}
 
@@ -386,18 +426,12 @@ func (c *Client) GetMinerStatuses() (*MinerStatusData, error) {
 	}, nil
 }
 
-// parseFloat extracts a float64 from a JSON-decoded interface value.
-func parseFloat(v interface{}) float64 {
-	switch n := v.(type) {
-	case float64:
-		return n
-	case int:
-		return float64(n)
-	case int64:
-		return float64(n)
-	default:
+// valueOf returns the value of a nullable column, 0 for NULL.
+func valueOf(v *float64) float64 {
+	if v == nil {
 		return 0
 	}
+	return *v
 }
 
 // ShellyPowerReading represents the latest power reading from a Shelly device
@@ -415,30 +449,23 @@ type ShelliesPowerData struct {
 
 // GetShelliesPower queries QuestDB for the latest power reading from each Shelly device.
 func (c *Client) GetShelliesPower() (*ShelliesPowerData, error) {
-	const query = `SELECT timestamp, device_id, power FROM shellies LATEST ON timestamp PARTITION BY device_id;`
+	query := "SELECT " + schema.SelectList(schema.Shelly{}) + " FROM " + schema.TableShellies + " LATEST ON timestamp PARTITION BY device_id;"
 
 	result, err := c.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query shellies power: %w", err)
 	}
-
-	if result.Count == 0 || len(result.Dataset) == 0 {
-		return &ShelliesPowerData{HasData: false}, nil
+	var rows []schema.Shelly
+	if err := result.Scan(&rows); err != nil {
+		return nil, fmt.Errorf("failed to parse shellies power: %w", err)
 	}
 
-	devices := make([]ShellyPowerReading, 0, len(result.Dataset))
-	for _, row := range result.Dataset {
-		if len(row) < 3 {
-			continue
-		}
-
-		timestamp, _ := row[0].(string)
-		deviceID, _ := row[1].(string)
-
+	devices := make([]ShellyPowerReading, 0, len(rows))
+	for _, r := range rows {
 		devices = append(devices, ShellyPowerReading{
-			Timestamp: timestamp,
-			DeviceID:  deviceID,
-			Power:     parseFloat(row[2]),
+			Timestamp: schema.FormatTime(r.Timestamp),
+			DeviceID:  r.DeviceID,
+			Power:     valueOf(r.Power),
 		})
 	}
 
@@ -448,11 +475,12 @@ func (c *Client) GetShelliesPower() (*ShelliesPowerData, error) {
 	}, nil
 }
 
-// LatestEnvironmentReading represents the latest temperature reading for a location
+// LatestEnvironmentReading represents the latest temperature and humidity reading for a location
 type LatestEnvironmentReading struct {
 	Timestamp   string  `json:"timestamp"`
 	Location    string  `json:"location"`
 	Temperature float64 `json:"temperature"`
+	Humidity    float64 `json:"humidity"`
 }
 
 // LatestEnvironmentData holds the latest readings per location
@@ -461,32 +489,26 @@ type LatestEnvironmentData struct {
 	HasData  bool                       `json:"hasData"`
 }
 
-// GetLatestEnvironmentTemperatures queries QuestDB for the latest temperature from each location.
+// GetLatestEnvironmentTemperatures queries QuestDB for the latest temperature and humidity from each location.
 func (c *Client) GetLatestEnvironmentTemperatures() (*LatestEnvironmentData, error) {
-	const query = `SELECT timestamp, location, temperature FROM bme280_readings LATEST ON timestamp PARTITION BY location;`
+	const query = `SELECT timestamp, location, temperature, humidity FROM bme280_readings LATEST ON timestamp PARTITION BY location;`
 
 	result, err := c.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query latest environment temperatures: %w", err)
 	}
-
-	if result.Count == 0 || len(result.Dataset) == 0 {
-		return &LatestEnvironmentData{HasData: false}, nil
+	var rows []schema.BME280Reading
+	if err := result.Scan(&rows); err != nil {
+		return nil, fmt.Errorf("failed to parse latest environment temperatures: %w", err)
 	}
 
-	readings := make([]LatestEnvironmentReading, 0, len(result.Dataset))
-	for _, row := range result.Dataset {
-		if len(row) < 3 {
-			continue
-		}
-
-		timestamp, _ := row[0].(string)
-		location, _ := row[1].(string)
-
+	readings := make([]LatestEnvironmentReading, 0, len(rows))
+	for _, r := range rows {
 		readings = append(readings, LatestEnvironmentReading{
-			Timestamp:   timestamp,
-			Location:    location,
-			Temperature: parseFloat(row[2]),
+			Timestamp:   schema.FormatTime(r.Timestamp),
+			Location:    r.Location,
+			Temperature: valueOf(r.Temperature),
+			Humidity:    valueOf(r.Humidity),
 		})
 	}
 
@@ -498,9 +520,9 @@ func (c *Client) GetLatestEnvironmentTemperatures() (*LatestEnvironmentData, err
 
 // EnvironmentReading represents a single temperature reading from a sensor
 type EnvironmentReading struct {
-	Timestamp   string  `json:"timestamp"`
-	Location    string  `json:"location"`
-	Temperature float64 `json:"temperature"`
+	Timestamp   string  `json:"timestamp" qdb:"timestamp"`
+	Location    string  `json:"location" qdb:"location"`
+	Temperature float64 `json:"temperature" qdb:"temperature"`
 }
 
 // EnvironmentChartData represents temperature data grouped by location for charting
@@ -511,10 +533,10 @@ type EnvironmentChartData struct {
 
 // MinerTemperatureReading represents a single temperature reading from a miner
 type MinerTemperatureReading struct {
-	Timestamp string  `json:"timestamp"`
-	MinerIP   string  `json:"minerIp"`
-	Temp0     float64 `json:"temp0"`
-	Temp1     float64 `json:"temp1"`
+	Timestamp string  `json:"timestamp" qdb:"timestamp"`
+	MinerIP   string  `json:"minerIp" qdb:"miner_ip"`
+	Temp0     float64 `json:"temp0" qdb:"avg_temp0"`
+	Temp1     float64 `json:"temp1" qdb:"avg_temp1"`
 }
 
 // MinerTemperatureChartData represents temperature data grouped by miner IP for charting
@@ -531,39 +553,14 @@ func (c *Client) GetMinerTemperatures() (*MinerTemperatureChartData, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to query miner temperatures: %w", err)
 	}
-
-	if result.Count == 0 || len(result.Dataset) == 0 {
-		return &MinerTemperatureChartData{
-			Miners:  make(map[string][]MinerTemperatureReading),
-			HasData: false,
-		}, nil
+	var readings []MinerTemperatureReading
+	if err := result.Scan(&readings); err != nil {
+		return nil, fmt.Errorf("failed to parse miner temperatures: %w", err)
 	}
 
 	miners := make(map[string][]MinerTemperatureReading)
-
-	for _, row := range result.Dataset {
-		if len(row) < 4 {
-			continue
-		}
-
-		timestamp, ok := row[0].(string)
-		if !ok {
-			continue
-		}
-
-		minerIP, ok := row[1].(string)
-		if !ok {
-			continue
-		}
-
-		reading := MinerTemperatureReading{
-			Timestamp: timestamp,
-			MinerIP:   minerIP,
-			Temp0:     parseFloat(row[2]),
-			Temp1:     parseFloat(row[3]),
-		}
-
-		miners[minerIP] = append(miners[minerIP], reading)
+	for _, r := range readings {
+		miners[r.MinerIP] = append(miners[r.MinerIP], r)
 	}
 
 	return &MinerTemperatureChartData{
@@ -574,10 +571,10 @@ func (c *Client) GetMinerTemperatures() (*MinerTemperatureChartData, error) {
 
 // HashboardDetailedRow represents the latest avg voltage and frequency for a single miner
 type HashboardDetailedRow struct {
-	Timestamp    string  `json:"timestamp"`
-	MinerIP      string  `json:"minerIp"`
-	AvgVoltage   float64 `json:"avgVoltage"`
-	AvgFrequency float64 `json:"avgFrequency"`
+	Timestamp    string  `json:"timestamp" qdb:"timestamp"`
+	MinerIP      string  `json:"minerIp" qdb:"miner_ip"`
+	AvgVoltage   float64 `json:"avgVoltage" qdb:"avg_voltage"`
+	AvgFrequency float64 `json:"avgFrequency" qdb:"avg_frequency"`
 }
 
 // HashboardDetailedData holds the latest voltage/frequency readings per miner
@@ -588,32 +585,15 @@ type HashboardDetailedData struct {
 
 // GetHashboardsDetailed queries QuestDB for the latest avg voltage and frequency per miner.
 func (c *Client) GetHashboardsDetailed() (*HashboardDetailedData, error) {
-	const query = `SELECT timestamp, miner_ip, avg(voltage), avg(frequency_avg) FROM hashboards_detailed LATEST ON timestamp PARTITION BY miner_ip;`
+	const query = `SELECT timestamp, miner_ip, avg(voltage) AS avg_voltage, avg(frequency_avg) AS avg_frequency FROM hashboards_detailed LATEST ON timestamp PARTITION BY miner_ip;`
 
 	result, err := c.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query hashboards detailed: %w", err)
 	}
-
-	if result.Count == 0 || len(result.Dataset) == 0 {
-		return &HashboardDetailedData{HasData: false}, nil
-	}
-
-	miners := make([]HashboardDetailedRow, 0, len(result.Dataset))
-	for _, row := range result.Dataset {
-		if len(row) < 4 {
-			continue
-		}
-
-		timestamp, _ := row[0].(string)
-		minerIP, _ := row[1].(string)
-
-		miners = append(miners, HashboardDetailedRow{
-			Timestamp:    timestamp,
-			MinerIP:      minerIP,
-			AvgVoltage:   parseFloat(row[2]),
-			AvgFrequency: parseFloat(row[3]),
-		})
+	var miners []HashboardDetailedRow
+	if err := result.Scan(&miners); err != nil {
+		return nil, fmt.Errorf("failed to parse hashboards detailed: %w", err)
 	}
 
 	return &HashboardDetailedData{
@@ -625,61 +605,22 @@ func (c *Client) GetHashboardsDetailed() (*HashboardDetailedData, error) {
 // GetEnvironmentTemperatures queries QuestDB for environment temperature readings for today,
 // using a 10-minute rolling average window per location.
 func (c *Client) GetEnvironmentTemperatures() (*EnvironmentChartData, error) {
-	const query = `SELECT timestamp, location, avg(temperature) OVER (PARTITION BY location ORDER BY timestamp RANGE BETWEEN '10' MINUTE PRECEDING AND CURRENT ROW) temp FROM bme280_readings WHERE timestamp IN today();`
+	const query = `SELECT timestamp, location, avg(temperature) OVER (PARTITION BY location ORDER BY timestamp RANGE BETWEEN '10' MINUTE PRECEDING AND CURRENT ROW) temperature FROM bme280_readings WHERE timestamp >= ? AND timestamp < ?;`
 
-	result, err := c.Query(query)
+	from, to := c.today()
+	result, err := c.Query(query, from, to)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query environment temperatures: %w", err)
 	}
-
-	// Check if we have any data
-	if result.Count == 0 || len(result.Dataset) == 0 {
-		return &EnvironmentChartData{
-			Locations: make(map[string][]EnvironmentReading),
-			HasData:   false,
-		}, nil
+	var readings []EnvironmentReading
+	if err := result.Scan(&readings); err != nil {
+		return nil, fmt.Errorf("failed to parse environment temperatures: %w", err)
 	}
 
 	// Group readings by location
 	locations := make(map[string][]EnvironmentReading)
-
-	for _, row := range result.Dataset {
-		if len(row) < 3 {
-			continue
-		}
-
-		// Parse timestamp
-		timestamp, ok := row[0].(string)
-		if !ok {
-			continue
-		}
-
-		// Parse location
-		location, ok := row[1].(string)
-		if !ok {
-			continue
-		}
-
-		// Parse temperature
-		var temperature float64
-		switch v := row[2].(type) {
-		case float64:
-			temperature = v
-		case int:
-			temperature = float64(v)
-		case int64:
-			temperature = float64(v)
-		default:
-			continue
-		}
-
-		reading := EnvironmentReading{
-			Timestamp:   timestamp,
-			Location:    location,
-			Temperature: temperature,
-		}
-
-		locations[location] = append(locations[location], reading)
+	for _, r := range readings {
+		locations[r.Location] = append(locations[r.Location], r)
 	}
 
 	return &EnvironmentChartData{
@@ -690,9 +631,9 @@ func (c *Client) GetEnvironmentTemperatures() (*EnvironmentChartData, error) {
 
 // HumidityReading represents a single humidity reading from a sensor
 type HumidityReading struct {
-	Timestamp string  `json:"timestamp"`
-	Location  string  `json:"location"`
-	Humidity  float64 `json:"humidity"`
+	Timestamp string  `json:"timestamp" qdb:"timestamp"`
+	Location  string  `json:"location" qdb:"location"`
+	Humidity  float64 `json:"humidity" qdb:"humidity"`
 }
 
 // HumidityChartData represents humidity data grouped by location for charting
@@ -704,39 +645,21 @@ type HumidityChartData struct {
 // GetEnvironmentHumidity queries QuestDB for humidity readings for today,
 // using a 10-minute rolling average window per location.
 func (c *Client) GetEnvironmentHumidity() (*HumidityChartData, error) {
-	const query = `SELECT timestamp, location, avg(humidity) OVER (PARTITION BY location ORDER BY timestamp RANGE BETWEEN '10' MINUTE PRECEDING AND CURRENT ROW) humidity FROM bme280_readings WHERE timestamp IN today();`
+	const query = `SELECT timestamp, location, avg(humidity) OVER (PARTITION BY location ORDER BY timestamp RANGE BETWEEN '10' MINUTE PRECEDING AND CURRENT ROW) humidity FROM bme280_readings WHERE timestamp >= ? AND timestamp < ?;`
 
-	result, err := c.Query(query)
+	from, to := c.today()
+	result, err := c.Query(query, from, to)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query environment humidity: %w", err)
 	}
-
-	if result.Count == 0 || len(result.Dataset) == 0 {
-		return &HumidityChartData{
-			Locations: make(map[string][]HumidityReading),
-			HasData:   false,
-		}, nil
+	var readings []HumidityReading
+	if err := result.Scan(&readings); err != nil {
+		return nil, fmt.Errorf("failed to parse environment humidity: %w", err)
 	}
 
 	locations := make(map[string][]HumidityReading)
-	for _, row := range result.Dataset {
-		if len(row) < 3 {
-			continue
-		}
-		timestamp, ok := row[0].(string)
-		if !ok {
-			continue
-		}
-		location, ok := row[1].(string)
-		if !ok {
-			continue
-		}
-
-		locations[location] = append(locations[location], HumidityReading{
-			Timestamp: timestamp,
-			Location:  location,
-			Humidity:  parseFloat(row[2]),
-		})
+	for _, r := range readings {
+		locations[r.Location] = append(locations[r.Location], r)
 	}
 
 	return &HumidityChartData{
@@ -747,9 +670,9 @@ func (c *Client) GetEnvironmentHumidity() (*HumidityChartData, error) {
 
 // PressureReading represents a single pressure reading from a sensor
 type PressureReading struct {
-	Timestamp string  `json:"timestamp"`
-	Location  string  `json:"location"`
-	Pressure  float64 `json:"pressure"`
+	Timestamp string  `json:"timestamp" qdb:"timestamp"`
+	Location  string  `json:"location" qdb:"location"`
+	Pressure  float64 `json:"pressure" qdb:"pressure"`
 }
 
 // PressureChartData represents pressure data grouped by location for charting
@@ -761,39 +684,21 @@ type PressureChartData struct {
 // GetEnvironmentPressure queries QuestDB for pressure readings for today,
 // using a 10-minute rolling average window per location.
 func (c *Client) GetEnvironmentPressure() (*PressureChartData, error) {
-	const query = `SELECT timestamp, location, avg(pressure) OVER (PARTITION BY location ORDER BY timestamp RANGE BETWEEN '10' MINUTE PRECEDING AND CURRENT ROW) pressure FROM bme280_readings WHERE timestamp IN today();`
+	const query = `SELECT timestamp, location, avg(pressure) OVER (PARTITION BY location ORDER BY timestamp RANGE BETWEEN '10' MINUTE PRECEDING AND CURRENT ROW) pressure FROM bme280_readings WHERE timestamp >= ? AND timestamp < ?;`
 
-	result, err := c.Query(query)
+	from, to := c.today()
+	result, err := c.Query(query, from, to)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query environment pressure: %w", err)
 	}
-
-	if result.Count == 0 || len(result.Dataset) == 0 {
-		return &PressureChartData{
-			Locations: make(map[string][]PressureReading),
-			HasData:   false,
-		}, nil
+	var readings []PressureReading
+	if err := result.Scan(&readings); err != nil {
+		return nil, fmt.Errorf("failed to parse environment pressure: %w", err)
 	}
 
 	locations := make(map[string][]PressureReading)
-	for _, row := range result.Dataset {
-		if len(row) < 3 {
-			continue
-		}
-		timestamp, ok := row[0].(string)
-		if !ok {
-			continue
-		}
-		location, ok := row[1].(string)
-		if !ok {
-			continue
-		}
-
-		locations[location] = append(locations[location], PressureReading{
-			Timestamp: timestamp,
-			Location:  location,
-			Pressure:  parseFloat(row[2]),
-		})
+	for _, r := range readings {
+		locations[r.Location] = append(locations[r.Location], r)
 	}
 
 	return &PressureChartData{
@@ -802,10 +707,17 @@ func (c *Client) GetEnvironmentPressure() (*PressureChartData, error) {
 	}, nil
 }
 
+// temperatureSums is a bucket of summed temperature readings.
+type temperatureSums struct {
+	Timestamp time.Time `qdb:"timestamp"`
+	Sum       float64   `qdb:"temperature_sum"`
+	Samples   float64   `qdb:"samples"`
+}
+
 // HourlyTempRow represents the average temperature for one hour of the day
 type HourlyTempRow struct {
-	Hour    int     `json:"hour"`
-	AvgTemp float64 `json:"avgTemp"`
+	Hour    int     `json:"hour" qdb:"hour_of_day"`
+	AvgTemp float64 `json:"avgTemp" qdb:"avg_temp"`
 }
 
 // HourlyTempData holds the hourly average temperature data
@@ -814,29 +726,36 @@ type HourlyTempData struct {
 	HasData bool            `json:"hasData"`
 }
 
-// GetHourlyAvgTemperature queries QuestDB for the average miningroom temperature
-// by hour of the day over the past 7 days.
-func (c *Client) GetHourlyAvgTemperature() (*HourlyTempData, error) {
-	const query = `SELECT hour(timestamp) as hour_of_day, AVG(temperature) as avg_temp FROM bme280_readings WHERE timestamp > dateadd('d', -7, now()) AND location='miningroom' GROUP BY hour_of_day ORDER BY hour_of_day;`
+// GetHourlyAvgTemperature queries QuestDB for the average temperature at the
+// given indoor locations by hour of the day over the past 7 days. Readings
+// are summed in 15 minute buckets and the buckets grouped by the hour of day
+// of the client's location, which may be offset from UTC by quarter hours.
+func (c *Client) GetHourlyAvgTemperature(locations []string) (*HourlyTempData, error) {
+	if len(locations) == 0 {
+		return &HourlyTempData{HasData: false}, nil
+	}
+	const query = `SELECT timestamp, sum(temperature) AS temperature_sum, count() AS samples FROM bme280_readings WHERE timestamp > dateadd('d', -7, now()) AND location IN (?) SAMPLE BY 15m ALIGN TO CALENDAR;`
 
-	result, err := c.Query(query)
+	result, err := c.Query(query, locations)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query hourly avg temperature: %w", err)
 	}
-
-	if result.Count == 0 || len(result.Dataset) == 0 {
-		return &HourlyTempData{HasData: false}, nil
+	var buckets []temperatureSums
+	if err := result.Scan(&buckets); err != nil {
+		return nil, fmt.Errorf("failed to parse hourly avg temperature: %w", err)
 	}
 
-	hours := make([]HourlyTempRow, 0, len(result.Dataset))
-	for _, row := range result.Dataset {
-		if len(row) < 2 {
-			continue
+	var sums, samples [24]float64
+	for _, b := range buckets {
+		h := b.Timestamp.In(c.loc()).Hour()
+		sums[h] += b.Sum
+		samples[h] += b.Samples
+	}
+	var hours []HourlyTempRow
+	for h := range sums {
+		if samples[h] > 0 {
+			hours = append(hours, HourlyTempRow{Hour: h, AvgTemp: sums[h] / samples[h]})
 		}
-		hours = append(hours, HourlyTempRow{
-			Hour:    int(parseFloat(row[0])),
-			AvgTemp: parseFloat(row[1]),
-		})
 	}
 
 	return &HourlyTempData{
@@ -845,119 +764,10 @@ func (c *Client) GetHourlyAvgTemperature() (*HourlyTempData, error) {
 	}, nil
 }
 
-// ThermalDataPoint represents a single thermal insulation calculation point
-type ThermalDataPoint struct {
-	Timestamp          string  `json:"timestamp"`
-	Power              float64 `json:"power"`
-	InsideTemp         float64 `json:"insideTemp"`
-	OutsideTemp        float64 `json:"outsideTemp"`
-	DeltaT             float64 `json:"deltaT"`
-	ThermalConductance float64 `json:"thermalConductance"` // W/K - lower is better insulation
-}
-
-// ThermalInsulationData holds the thermal insulation time series
-type ThermalInsulationData struct {
-	DataPoints []ThermalDataPoint `json:"dataPoints"`
-	HasData    bool               `json:"hasData"`
-}
-
-// GetThermalInsulationData queries QuestDB for power and temperature data to calculate
-// thermal insulation coefficient over time. Uses 10-minute sampling.
-func (c *Client) GetThermalInsulationData() (*ThermalInsulationData, error) {
-	// Query power data sampled by 10 minutes
-	const powerQuery = `SELECT timestamp, sum(power) as total_power FROM shellies WHERE timestamp > dateadd('d', -7, now()) SAMPLE BY 10m ALIGN TO CALENDAR;`
-
-	// Query inside (miningroom) temperature
-	const insideQuery = `SELECT timestamp, avg(temperature) as temp FROM bme280_readings WHERE timestamp > dateadd('d', -7, now()) AND location = 'miningroom' SAMPLE BY 10m ALIGN TO CALENDAR;`
-
-	// Query outside temperature
-	const outsideQuery = `SELECT timestamp, avg(temperature) as temp FROM bme280_readings WHERE timestamp > dateadd('d', -7, now()) AND location = 'outside' SAMPLE BY 10m ALIGN TO CALENDAR;`
-
-	// Execute all three queries
-	powerResult, err := c.Query(powerQuery)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query power data: %w", err)
-	}
-
-	insideResult, err := c.Query(insideQuery)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query inside temperature: %w", err)
-	}
-
-	outsideResult, err := c.Query(outsideQuery)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query outside temperature: %w", err)
-	}
-
-	// Build maps by timestamp
-	powerMap := make(map[string]float64)
-	for _, row := range powerResult.Dataset {
-		if len(row) >= 2 {
-			if ts, ok := row[0].(string); ok {
-				powerMap[ts] = parseFloat(row[1])
-			}
-		}
-	}
-
-	insideMap := make(map[string]float64)
-	for _, row := range insideResult.Dataset {
-		if len(row) >= 2 {
-			if ts, ok := row[0].(string); ok {
-				insideMap[ts] = parseFloat(row[1])
-			}
-		}
-	}
-
-	outsideMap := make(map[string]float64)
-	for _, row := range outsideResult.Dataset {
-		if len(row) >= 2 {
-			if ts, ok := row[0].(string); ok {
-				outsideMap[ts] = parseFloat(row[1])
-			}
-		}
-	}
-
-	// Join data points where we have all three values
-	var dataPoints []ThermalDataPoint
-	for ts, power := range powerMap {
-		insideTemp, hasInside := insideMap[ts]
-		outsideTemp, hasOutside := outsideMap[ts]
-
-		if hasInside && hasOutside && power > 100 { // Minimum power threshold
-			deltaT := insideTemp - outsideTemp
-			if deltaT > 1 { // Need meaningful temperature difference
-				conductance := power / deltaT
-				dataPoints = append(dataPoints, ThermalDataPoint{
-					Timestamp:          ts,
-					Power:              power,
-					InsideTemp:         insideTemp,
-					OutsideTemp:        outsideTemp,
-					DeltaT:             deltaT,
-					ThermalConductance: conductance,
-				})
-			}
-		}
-	}
-
-	// Sort by timestamp
-	for i := 0; i < len(dataPoints)-1; i++ {
-		for j := i + 1; j < len(dataPoints); j++ {
-			if dataPoints[i].Timestamp > dataPoints[j].Timestamp {
-				dataPoints[i], dataPoints[j] = dataPoints[j], dataPoints[i]
-			}
-		}
-	}
-
-	return &ThermalInsulationData{
-		DataPoints: dataPoints,
-		HasData:    len(dataPoints) > 0,
-	}, nil
-}
-
 // TimeSeriesPoint represents a single (timestamp, value) data point.
 type TimeSeriesPoint struct {
-	Timestamp string  `json:"timestamp"`
-	Value     float64 `json:"value"`
+	Timestamp string  `json:"timestamp" qdb:"timestamp"`
+	Value     float64 `json:"value" qdb:"value"`
 }
 
 // TimeSeriesData holds a simple time series of values.
@@ -966,34 +776,14 @@ type TimeSeriesData struct {
 	HasData bool              `json:"hasData"`
 }
 
-// GetPowerTimeSeries returns total Shelly power sampled every 10 minutes over the last 24 hours.
+// GetPowerTimeSeries returns total power (room meter, or the sum of the
+// Shellies) sampled every 10 minutes over the last 24 hours.
 func (c *Client) GetPowerTimeSeries() (*TimeSeriesData, error) {
-	const query = `SELECT timestamp, sum(power) as total_power FROM shellies WHERE timestamp > dateadd('h', -24, now()) SAMPLE BY 10m ALIGN TO CALENDAR;`
-
-	result, err := c.Query(query)
+	points, err := c.totalPowerSeries("timestamp > dateadd('h', -24, now())")
 	if err != nil {
 		return nil, fmt.Errorf("failed to query power time series: %w", err)
 	}
 
-	if result.Count == 0 || len(result.Dataset) == 0 {
-		return &TimeSeriesData{HasData: false}, nil
-	}
-
-	points := make([]TimeSeriesPoint, 0, len(result.Dataset))
-	for _, row := range result.Dataset {
-		if len(row) < 2 {
-			continue
-		}
-		ts, ok := row[0].(string)
-		if !ok {
-			continue
-		}
-		points = append(points, TimeSeriesPoint{
-			Timestamp: ts,
-			Value:     parseFloat(row[1]),
-		})
-	}
-
 	return &TimeSeriesData{
 		Points:  points,
 		HasData: len(points) > 0,
@@ -1002,30 +792,15 @@ func (c *Client) GetPowerTimeSeries() (*TimeSeriesData, error) {
 
 // GetHashrateTimeSeries returns total hashrate sampled every 10 minutes over the last 24 hours.
 func (c *Client) GetHashrateTimeSeries() (*TimeSeriesData, error) {
-	const query = `SELECT timestamp, sum(hashrate_average) as total_hashrate FROM pools WHERE timestamp > dateadd('h', -24, now()) SAMPLE BY 10m ALIGN TO CALENDAR;`
+	const query = `SELECT timestamp, sum(hashrate_average) as value FROM pools WHERE timestamp > dateadd('h', -24, now()) SAMPLE BY 10m ALIGN TO CALENDAR;`
 
 	result, err := c.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query hashrate time series: %w", err)
 	}
-
-	if result.Count == 0 || len(result.Dataset) == 0 {
-		return &TimeSeriesData{HasData: false}, nil
-	}
-
-	points := make([]TimeSeriesPoint, 0, len(result.Dataset))
-	for _, row := range result.Dataset {
-		if len(row) < 2 {
-			continue
-		}
-		ts, ok := row[0].(string)
-		if !ok {
-			continue
-		}
-		points = append(points, TimeSeriesPoint{
-			Timestamp: ts,
-			Value:     parseFloat(row[1]),
-		})
+	var points []TimeSeriesPoint
+	if err := result.Scan(&points); err != nil {
+		return nil, fmt.Errorf("failed to parse hashrate time series: %w", err)
 	}
 
 	return &TimeSeriesData{
@@ -1036,9 +811,9 @@ func (c *Client) GetHashrateTimeSeries() (*TimeSeriesData, error) {
 
 // MinerHashrateReading represents a single hashrate reading for a miner.
 type MinerHashrateReading struct {
-	Timestamp string  `json:"timestamp"`
-	MinerIP   string  `json:"minerIp"`
-	Hashrate  float64 `json:"hashrate"`
+	Timestamp string  `json:"timestamp" qdb:"timestamp"`
+	MinerIP   string  `json:"minerIp" qdb:"miner_ip"`
+	Hashrate  float64 `json:"hashrate" qdb:"hashrate"`
 }
 
 // MinerHashrateChartData holds per-miner hashrate time series.
@@ -1055,32 +830,14 @@ func (c *Client) GetPerMinerHashrateTimeSeries() (*MinerHashrateChartData, error
 	if err != nil {
 		return nil, fmt.Errorf("failed to query per-miner hashrate time series: %w", err)
 	}
-
-	if result.Count == 0 || len(result.Dataset) == 0 {
-		return &MinerHashrateChartData{
-			Miners:  make(map[string][]MinerHashrateReading),
-			HasData: false,
-		}, nil
+	var readings []MinerHashrateReading
+	if err := result.Scan(&readings); err != nil {
+		return nil, fmt.Errorf("failed to parse per-miner hashrate time series: %w", err)
 	}
 
 	miners := make(map[string][]MinerHashrateReading)
-	for _, row := range result.Dataset {
-		if len(row) < 3 {
-			continue
-		}
-		ts, ok := row[0].(string)
-		if !ok {
-			continue
-		}
-		minerIP, ok := row[1].(string)
-		if !ok {
-			continue
-		}
-		miners[minerIP] = append(miners[minerIP], MinerHashrateReading{
-			Timestamp: ts,
-			MinerIP:   minerIP,
-			Hashrate:  parseFloat(row[2]),
-		})
+	for _, r := range readings {
+		miners[r.MinerIP] = append(miners[r.MinerIP], r)
 	}
 
 	return &MinerHashrateChartData{
@@ -1091,9 +848,9 @@ func (c *Client) GetPerMinerHashrateTimeSeries() (*MinerHashrateChartData, error
 
 // DevicePowerReading represents a single power reading for a Shelly device.
 type DevicePowerReading struct {
-	Timestamp string  `json:"timestamp"`
-	DeviceID  string  `json:"deviceId"`
-	Power     float64 `json:"power"`
+	Timestamp string  `json:"timestamp" qdb:"timestamp"`
+	DeviceID  string  `json:"deviceId" qdb:"device_id"`
+	Power     float64 `json:"power" qdb:"power"`
 }
 
 // DevicePowerChartData holds per-device power time series.
@@ -1110,36 +867,17 @@ func (c *Client) GetPerDevicePowerTimeSeries() (*DevicePowerChartData, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to query per-device power time series: %w", err)
 	}
-
-	if result.Count == 0 || len(result.Dataset) == 0 {
-		return &DevicePowerChartData{
-			Devices: make(map[string][]DevicePowerReading),
-			HasData: false,
-		}, nil
+	var readings []DevicePowerReading
+	if err := result.Scan(&readings); err != nil {
+		return nil, fmt.Errorf("failed to parse per-device power time series: %w", err)
 	}
 
 	devices := make(map[string][]DevicePowerReading)
-	for _, row := range result.Dataset {
-		if len(row) < 3 {
-			continue
-		}
-		ts, ok := row[0].(string)
-		if !ok {
-			continue
-		}
-		deviceID, ok := row[1].(string)
-		if !ok {
-			continue
-		}
-		power := parseFloat(row[2])
-		if power == 0 {
+	for _, r := range readings {
+		if r.Power == 0 {
 			continue // skip NULL-filled gaps
 		}
-		devices[deviceID] = append(devices[deviceID], DevicePowerReading{
-			Timestamp: ts,
-			DeviceID:  deviceID,
-			Power:     power,
-		})
+		devices[r.DeviceID] = append(devices[r.DeviceID], r)
 	}
 
 	return &DevicePowerChartData{
@@ -1148,11 +886,34 @@ func (c *Client) GetPerDevicePowerTimeSeries() (*DevicePowerChartData, error) {
 	}, nil
 }
 
+// GetDeviceAveragePower returns the average power (W) of each Shelly device over
+// the last given number of hours, keyed by device ID.
+func (c *Client) GetDeviceAveragePower(hours int) (map[string]float64, error) {
+	const query = `SELECT device_id, avg(power) AS power FROM shellies WHERE timestamp > dateadd('h', ?, now());`
+
+	result, err := c.Query(query, -hours)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query device average power: %w", err)
+	}
+	var readings []DevicePowerReading
+	if err := result.Scan(&readings); err != nil {
+		return nil, fmt.Errorf("failed to parse device average power: %w", err)
+	}
+
+	averages := make(map[string]float64)
+	for _, r := range readings {
+		averages[r.DeviceID] = r.Power
+	}
+
+	return averages, nil
+}
+
 // DailyEnergyRow represents energy usage for a single day
 type DailyEnergyRow struct {
-	Date      string  `json:"date"`      // e.g. "2026-02-04"
-	EnergyKWh float64 `json:"energyKwh"` // kWh consumed that day
-	AvgPowerW float64 `json:"avgPowerW"` // average total power (W)
+	Date         string  `json:"date"`         // e.g. "2026-02-04"
+	EnergyKWh    float64 `json:"energyKwh"`    // kWh consumed that day
+	AvgPowerW    float64 `json:"avgPowerW"`    // average total power (W) over the covered hours
+	CoveredHours float64 `json:"coveredHours"` // hours with power samples, below 24 for today and days with gaps
 }
 
 // DailyEnergyData holds the daily energy usage time series
@@ -1161,63 +922,44 @@ type DailyEnergyData struct {
 	HasData bool             `json:"hasData"`
 }
 
-// GetDailyEnergyUsage queries QuestDB for power data over the past 7 days,
-// groups by calendar day, and computes average power and energy (kWh) per day.
+// GetDailyEnergyUsage queries QuestDB for power data over the past 7 days and
+// integrates it per calendar day into energy (kWh) and average power.
+// The room meter is used where it has data.
 func (c *Client) GetDailyEnergyUsage() (*DailyEnergyData, error) {
-	const query = `SELECT timestamp, sum(power) as total_power FROM shellies WHERE timestamp > dateadd('d', -7, now()) SAMPLE BY 10m ALIGN TO CALENDAR;`
-
-	result, err := c.Query(query)
+	points, err := c.totalPowerSeries("timestamp > dateadd('d', -7, now())")
 	if err != nil {
 		return nil, fmt.Errorf("failed to query daily energy usage: %w", err)
 	}
 
-	if result.Count == 0 || len(result.Dataset) == 0 {
+	if len(points) == 0 {
 		return &DailyEnergyData{HasData: false}, nil
 	}
 
-	// Group power readings by date (first 10 chars of timestamp = "YYYY-MM-DD")
-	type dayAccum struct {
-		totalPower float64
-		count      int
-	}
-	dayMap := make(map[string]*dayAccum)
-
-	for _, row := range result.Dataset {
-		if len(row) < 2 {
-			continue
-		}
-		ts, ok := row[0].(string)
-		if !ok || len(ts) < 10 {
-			continue
-		}
-		date := ts[:10]
-		power := parseFloat(row[1])
-
-		if acc, exists := dayMap[date]; exists {
-			acc.totalPower += power
-			acc.count++
-		} else {
-			dayMap[date] = &dayAccum{totalPower: power, count: 1}
-		}
-	}
+	days := dailyEnergyRows(points, c.loc())
+	return &DailyEnergyData{
+		Days:    days,
+		HasData: len(days) > 0,
+	}, nil
+}
 
-	days := make([]DailyEnergyRow, 0, len(dayMap))
-	for date, acc := range dayMap {
-		avgPower := acc.totalPower / float64(acc.count)
-		energyKWh := avgPower * 24 / 1000
+// dailyEnergyRows integrates a total power series per calendar day in loc
+// into energy (kWh), average power over the covered time and the covered
+// hours, oldest first. The current day and days with gaps are covered only
+// partly; their energy is what was measured, not extrapolated to 24 hours.
+func dailyEnergyRows(points []TimeSeriesPoint, loc *time.Location) []DailyEnergyRow {
+	spans := integratePeriods(points, dayIn(loc))
+	days := make([]DailyEnergyRow, 0, len(spans))
+	for start, span := range spans {
 		days = append(days, DailyEnergyRow{
-			Date:      date,
-			EnergyKWh: energyKWh,
-			AvgPowerW: avgPower,
+			Date:         start.Format("2006-01-02"),
+			EnergyKWh:    span.wattHours / 1000,
+			AvgPowerW:    span.avgPower(),
+			CoveredHours: span.covered.Hours(),
 		})
 	}
 
 	sort.Slice(days, func(i, j int) bool {
 		return days[i].Date < days[j].Date
 	})
-
-	return &DailyEnergyData{
-		Days:    days,
-		HasData: len(days) > 0,
-	}, nil
+	return days
 }
@@ -0,0 +1,104 @@
+package questdb
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// queryResult decodes a QuestDB /exec response body.
+func queryResult(t *testing.T, body string) *QueryResult {
+	t.Helper()
+	var r QueryResult
+	if err := json.Unmarshal([]byte(body), &r); err != nil {
+		t.Fatal(err)
+	}
+	return &r
+}
+
+type powerRow struct {
+	Timestamp time.Time `qdb:"timestamp"`
+	DeviceID  string    `qdb:"device_id"`
+	Power     float64   `qdb:"power"`
+	Samples   int       `qdb:"samples"`
+}
+
+const powerResponse = `{
+	"query": "SELECT ...",
+	"columns": [
+		{"name": "timestamp", "type": "TIMESTAMP"},
+		{"name": "device_id", "type": "SYMBOL"},
+		{"name": "power", "type": "DOUBLE"},
+		{"name": "samples", "type": "LONG"}
+	],
+	"dataset": [
+		["2026-03-01T12:00:00.000000Z", "shelly-1", 3250.5, 12],
+		["2026-03-01T12:01:00.000000Z", "shelly-2", null, 0]
+	],
+	"count": 2
+}`
+
+func TestQueryResultScanSlice(t *testing.T) {
+	var rows []powerRow
+	if err := queryResult(t, powerResponse).Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+	want := powerRow{Timestamp: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), DeviceID: "shelly-1", Power: 3250.5, Samples: 12}
+	if rows[0] != want {
+		t.Errorf("row 0 = %+v, want %+v", rows[0], want)
+	}
+	if rows[1].DeviceID != "shelly-2" || rows[1].Power != 0 {
+		t.Errorf("row 1 = %+v, want a NULL power read as 0", rows[1])
+	}
+}
+
+func TestQueryResultScanStruct(t *testing.T) {
+	var row powerRow
+	if err := queryResult(t, powerResponse).Scan(&row); err != nil {
+		t.Fatal(err)
+	}
+	if row.DeviceID != "shelly-1" {
+		t.Errorf("row = %+v, want the first row", row)
+	}
+
+	row = powerRow{DeviceID: "kept"}
+	empty := queryResult(t, `{"columns": [{"name": "device_id", "type": "SYMBOL"}], "dataset": [], "count": 0}`)
+	if err := empty.Scan(&row); err != nil {
+		t.Fatal(err)
+	}
+	if row.DeviceID != "kept" {
+		t.Errorf("row = %+v, want an empty result to leave it as is", row)
+	}
+}
+
+func TestQueryResultScanErrors(t *testing.T) {
+	var rows []powerRow
+	var row powerRow
+	var n int
+	var ints []int
+	tests := []struct {
+		name    string
+		body    string
+		dest    interface{}
+		wantErr string
+	}{
+		{"not a pointer", powerResponse, row, "must be a pointer"},
+		{"nil pointer", powerResponse, (*powerRow)(nil), "must be a pointer"},
+		{"pointer to a scalar", powerResponse, &n, "struct or a slice of structs"},
+		{"slice of scalars", powerResponse, &ints, "struct or a slice of structs"},
+		{"mistyped column names the row", `{"columns": [{"name": "power", "type": "STRING"}], "dataset": [[1.0], ["high"]]}`,
+			&rows, "row 1: column power"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := queryResult(t, tt.body).Scan(tt.dest)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Scan error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package questdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// ContactState is the latest reading of a door/window contact sensor.
+type ContactState struct {
+	Timestamp time.Time `json:"timestamp" qdb:"timestamp"`
+	SensorID  string    `json:"sensorId" qdb:"sensor_id"`
+	Location  string    `json:"location" qdb:"location"`
+	Open      bool      `json:"open" qdb:"open"`
+}
+
+// OpenInterval is a period during which a contact sensor reported open.
+type OpenInterval struct {
+	SensorID string    `json:"sensorId"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"` // now while still open
+}
+
+// overlaps reports whether the interval intersects [from, to).
+func (o OpenInterval) overlaps(from, to time.Time) bool {
+	return o.From.Before(to) && o.To.After(from)
+}
+
+// GetLatestContactStates returns the latest reading of each contact sensor.
+func (c *Client) GetLatestContactStates() ([]ContactState, error) {
+	const query = `SELECT timestamp, sensor_id, location, open FROM contact_sensors LATEST ON timestamp PARTITION BY sensor_id;`
+
+	result, err := c.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contact sensors: %w", err)
+	}
+
+	var states []ContactState
+	if err := result.Scan(&states); err != nil {
+		return nil, fmt.Errorf("failed to parse contact sensors: %w", err)
+	}
+	return states, nil
+}
+
+// GetContactOpenIntervals returns the periods since from during which any
+// contact sensor was open. Sensors report on change and periodically, so an
+// open period lasts until the sensor's next closed reading.
+func (c *Client) GetContactOpenIntervals(from time.Time) ([]OpenInterval, error) {
+	result, err := c.Query("SELECT timestamp, sensor_id, open FROM contact_sensors WHERE timestamp >= ? ORDER BY timestamp;", from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contact sensors: %w", err)
+	}
+
+	var readings []ContactState
+	if err := result.Scan(&readings); err != nil {
+		return nil, fmt.Errorf("failed to parse contact sensors: %w", err)
+	}
+
+	var intervals []OpenInterval
+	openSince := make(map[string]time.Time)
+	for _, r := range readings {
+		since, isOpen := openSince[r.SensorID]
+		switch {
+		case r.Open && !isOpen:
+			openSince[r.SensorID] = r.Timestamp
+		case !r.Open && isOpen:
+			intervals = append(intervals, OpenInterval{SensorID: r.SensorID, From: since, To: r.Timestamp})
+			delete(openSince, r.SensorID)
+		}
+	}
+	now := time.Now()
+	for sensor, since := range openSince {
+		intervals = append(intervals, OpenInterval{SensorID: sensor, From: since, To: now})
+	}
+	return intervals, nil
+}
@@ -0,0 +1,106 @@
+package questdb
+
+import "fmt"
+
+// CoolantReading represents a single reading from a cooling loop sensor.
+// Temperature readings come from DS18B20 probes, flow readings from pulse flow meters.
+type CoolantReading struct {
+	Timestamp string  `json:"timestamp" qdb:"timestamp"`
+	Loop      string  `json:"loop" qdb:"loop"`
+	SensorID  string  `json:"sensorId" qdb:"sensor_id"`
+	Position  string  `json:"position,omitempty" qdb:"position"` // e.g. "inlet", "outlet", "tank"
+	Value     float64 `json:"value" qdb:"value"`
+}
+
+// CoolantLatestData holds the latest temperature and flow readings per sensor.
+type CoolantLatestData struct {
+	Temperatures []CoolantReading `json:"temperatures"`
+	Flows        []CoolantReading `json:"flows"`
+	HasData      bool             `json:"hasData"`
+}
+
+// CoolantChartData holds coolant readings grouped by "loop/sensor" for charting.
+type CoolantChartData struct {
+	Sensors map[string][]CoolantReading `json:"sensors"`
+	HasData bool                        `json:"hasData"`
+}
+
+// GetLatestCoolantReadings queries QuestDB for the latest coolant temperature and
+// flow rate from each sensor.
+func (c *Client) GetLatestCoolantReadings() (*CoolantLatestData, error) {
+	const tempQuery = `SELECT timestamp, loop, sensor_id, position, temperature AS value FROM coolant_temperatures LATEST ON timestamp PARTITION BY loop, sensor_id;`
+	const flowQuery = `SELECT timestamp, loop, sensor_id, flow AS value FROM coolant_flow LATEST ON timestamp PARTITION BY loop, sensor_id;`
+
+	tempResult, err := c.Query(tempQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query coolant temperatures: %w", err)
+	}
+
+	flowResult, err := c.Query(flowQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query coolant flow: %w", err)
+	}
+
+	data := &CoolantLatestData{}
+	if err := tempResult.Scan(&data.Temperatures); err != nil {
+		return nil, fmt.Errorf("failed to parse coolant temperatures: %w", err)
+	}
+	if err := flowResult.Scan(&data.Flows); err != nil {
+		return nil, fmt.Errorf("failed to parse coolant flow: %w", err)
+	}
+
+	data.HasData = len(data.Temperatures) > 0 || len(data.Flows) > 0
+	return data, nil
+}
+
+// GetCoolantTemperatureTimeSeries returns coolant temperatures over the last 24 hours,
+// sampled every 10 minutes per loop and sensor.
+func (c *Client) GetCoolantTemperatureTimeSeries() (*CoolantChartData, error) {
+	const query = `SELECT timestamp, loop, sensor_id, position, avg(temperature) AS value FROM coolant_temperatures WHERE timestamp > dateadd('h', -24, now()) SAMPLE BY 10m ALIGN TO CALENDAR;`
+
+	result, err := c.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query coolant temperature time series: %w", err)
+	}
+	var readings []CoolantReading
+	if err := result.Scan(&readings); err != nil {
+		return nil, fmt.Errorf("failed to parse coolant temperature time series: %w", err)
+	}
+
+	sensors := make(map[string][]CoolantReading)
+	for _, r := range readings {
+		key := r.Loop + "/" + r.SensorID
+		sensors[key] = append(sensors[key], r)
+	}
+
+	return &CoolantChartData{
+		Sensors: sensors,
+		HasData: len(sensors) > 0,
+	}, nil
+}
+
+// GetCoolantFlowTimeSeries returns coolant flow rates over the last 24 hours,
+// sampled every 10 minutes per loop and sensor.
+func (c *Client) GetCoolantFlowTimeSeries() (*CoolantChartData, error) {
+	const query = `SELECT timestamp, loop, sensor_id, avg(flow) AS value FROM coolant_flow WHERE timestamp > dateadd('h', -24, now()) SAMPLE BY 10m ALIGN TO CALENDAR;`
+
+	result, err := c.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query coolant flow time series: %w", err)
+	}
+	var readings []CoolantReading
+	if err := result.Scan(&readings); err != nil {
+		return nil, fmt.Errorf("failed to parse coolant flow time series: %w", err)
+	}
+
+	sensors := make(map[string][]CoolantReading)
+	for _, r := range readings {
+		key := r.Loop + "/" + r.SensorID
+		sensors[key] = append(sensors[key], r)
+	}
+
+	return &CoolantChartData{
+		Sensors: sensors,
+		HasData: len(sensors) > 0,
+	}, nil
+}
@@ -0,0 +1,51 @@
+package questdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// GetDailyEnergy returns energy per calendar day between from and to,
+// from the room meter where it has data and the sum of the plugs elsewhere.
+func (c *Client) GetDailyEnergy(from, to time.Time) ([]DailyEnergyRow, error) {
+	points, err := c.totalPowerSeries("timestamp >= ? AND timestamp < ?", from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily energy: %w", err)
+	}
+	return dailyEnergyRows(points, c.loc()), nil
+}
+
+// GetDailyMeanTemperatures returns the mean temperature per calendar day
+// between from and to, averaged over the given locations, keyed by date
+// ("2026-02-04"). Days without readings are missing. Readings are summed in
+// 15 minute buckets that are grouped by day in the client's location.
+func (c *Client) GetDailyMeanTemperatures(locations []string, from, to time.Time) (map[string]float64, error) {
+	means := make(map[string]float64)
+	if len(locations) == 0 {
+		return means, nil
+	}
+
+	const query = `SELECT timestamp, sum(temperature) AS temperature_sum, count() AS samples FROM bme280_readings WHERE location IN (?) AND timestamp >= ? AND timestamp < ? SAMPLE BY 15m ALIGN TO CALENDAR;`
+	result, err := c.Query(query, locations, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily mean temperatures: %w", err)
+	}
+	var buckets []temperatureSums
+	if err := result.Scan(&buckets); err != nil {
+		return nil, fmt.Errorf("failed to parse daily mean temperatures: %w", err)
+	}
+
+	sums := make(map[string]float64)
+	samples := make(map[string]float64)
+	for _, b := range buckets {
+		day := b.Timestamp.In(c.loc()).Format("2006-01-02")
+		sums[day] += b.Sum
+		samples[day] += b.Samples
+	}
+	for day, n := range samples {
+		if n > 0 {
+			means[day] = sums[day] / n
+		}
+	}
+	return means, nil
+}
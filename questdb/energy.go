@@ -0,0 +1,146 @@
+package questdb
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// energyMaxGap is the longest interval between two power samples that is
+// integrated. Across a longer gap (collector down, QuestDB restart) the power
+// is unknown, so the gap adds no energy and no covered time.
+const energyMaxGap = 30 * time.Minute
+
+// energySpan is the energy integrated over the covered part of a window.
+type energySpan struct {
+	wattHours float64
+	covered   time.Duration
+}
+
+func (s *energySpan) add(p0, p1 float64, d time.Duration) {
+	s.wattHours += (p0 + p1) / 2 * d.Hours()
+	s.covered += d
+}
+
+// avgPower is the time-weighted average power over the covered time.
+func (s energySpan) avgPower() float64 {
+	if s.covered <= 0 {
+		return 0
+	}
+	return s.wattHours / s.covered.Hours()
+}
+
+// sample is a parsed power sample.
+type sample struct {
+	t time.Time
+	w float64
+}
+
+// powerSamples parses a power series, oldest first, skipping bad timestamps.
+func powerSamples(points []TimeSeriesPoint) []sample {
+	samples := make([]sample, 0, len(points))
+	for _, p := range points {
+		t, err := time.Parse(time.RFC3339Nano, p.Timestamp)
+		if err != nil {
+			continue
+		}
+		samples = append(samples, sample{t, p.Value})
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].t.Before(samples[j].t) })
+	return samples
+}
+
+// integrateEnergy integrates a power series (W) with the trapezoidal rule over
+// the intervals between consecutive samples, skipping gaps longer than
+// energyMaxGap. Energy is only counted up to the last sample.
+func integrateEnergy(points []TimeSeriesPoint) energySpan {
+	var span energySpan
+	samples := powerSamples(points)
+	for i := 1; i < len(samples); i++ {
+		a, b := samples[i-1], samples[i]
+		if d := b.t.Sub(a.t); d > 0 && d <= energyMaxGap {
+			span.add(a.w, b.w, d)
+		}
+	}
+	return span
+}
+
+// integratePeriods is integrateEnergy per period, keyed by the period's
+// start; period returns the bounds of the period holding t. Intervals
+// crossing into the next period are split at the boundary, with the power
+// there interpolated linearly.
+func integratePeriods(points []TimeSeriesPoint, period func(t time.Time) (start, end time.Time)) map[time.Time]*energySpan {
+	spans := make(map[time.Time]*energySpan)
+	spanAt := func(start time.Time) *energySpan {
+		if spans[start] == nil {
+			spans[start] = &energySpan{}
+		}
+		return spans[start]
+	}
+
+	samples := powerSamples(points)
+	for i, s := range samples {
+		start, _ := period(s.t)
+		spanAt(start) // a lone sample still marks its period as having data
+		if i == 0 {
+			continue
+		}
+		a, b := samples[i-1], s
+		d := b.t.Sub(a.t)
+		if d <= 0 || d > energyMaxGap {
+			continue
+		}
+		for {
+			start, end := period(a.t)
+			if !end.Before(b.t) {
+				spanAt(start).add(a.w, b.w, b.t.Sub(a.t))
+				break
+			}
+			w := a.w + (b.w-a.w)*float64(end.Sub(a.t))/float64(d)
+			spanAt(start).add(a.w, w, end.Sub(a.t))
+			a = sample{end, w}
+		}
+	}
+	return spans
+}
+
+// dayIn returns the bounds of t's calendar day in loc.
+func dayIn(loc *time.Location) func(t time.Time) (time.Time, time.Time) {
+	return func(t time.Time) (time.Time, time.Time) {
+		t = t.In(loc)
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 0, 1)
+	}
+}
+
+// hourIn returns the bounds of t's hour of the day in loc.
+func hourIn(loc *time.Location) func(t time.Time) (time.Time, time.Time) {
+	return func(t time.Time) (time.Time, time.Time) {
+		t = t.In(loc)
+		start := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		return start, start.Add(time.Hour)
+	}
+}
+
+// HourlyEnergyRow is the energy measured in one hour.
+type HourlyEnergyRow struct {
+	Hour         time.Time `json:"hour"`         // start, in the client's location
+	EnergyKWh    float64   `json:"energyKwh"`    // kWh consumed in the hour
+	CoveredHours float64   `json:"coveredHours"` // part of the hour with power samples
+}
+
+// GetHourlyEnergy returns the energy per hour between from and to, oldest
+// first, integrated like GetDailyEnergy.
+func (c *Client) GetHourlyEnergy(from, to time.Time) ([]HourlyEnergyRow, error) {
+	points, err := c.totalPowerSeries("timestamp >= ? AND timestamp < ?", from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query hourly energy: %w", err)
+	}
+	spans := integratePeriods(points, hourIn(c.loc()))
+	hours := make([]HourlyEnergyRow, 0, len(spans))
+	for start, span := range spans {
+		hours = append(hours, HourlyEnergyRow{Hour: start, EnergyKWh: span.wattHours / 1000, CoveredHours: span.covered.Hours()})
+	}
+	sort.Slice(hours, func(i, j int) bool { return hours[i].Hour.Before(hours[j].Hour) })
+	return hours, nil
+}
@@ -0,0 +1,128 @@
+package questdb
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// powerSeries builds a power series from offsets after base and watts.
+func powerSeries(base time.Time, samples ...interface{}) []TimeSeriesPoint {
+	var points []TimeSeriesPoint
+	for i := 0; i < len(samples); i += 2 {
+		t := base.Add(samples[i].(time.Duration))
+		points = append(points, TimeSeriesPoint{Timestamp: t.Format(time.RFC3339Nano), Value: samples[i+1].(float64)})
+	}
+	return points
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestIntegrateEnergy(t *testing.T) {
+	base := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		points      []TimeSeriesPoint
+		wantWh      float64
+		wantCovered time.Duration
+	}{
+		{"no samples", nil, 0, 0},
+		{"single sample", powerSeries(base, time.Duration(0), 1000.0), 0, 0},
+		{"constant power", powerSeries(base, time.Duration(0), 1000.0, time.Hour/2, 1000.0), 500, time.Hour / 2},
+		{"trapezoid", powerSeries(base, time.Duration(0), 1000.0, 30*time.Minute, 2000.0), 750, 30 * time.Minute},
+		{"out of order", powerSeries(base, 30*time.Minute, 2000.0, time.Duration(0), 1000.0), 750, 30 * time.Minute},
+		{"gap at the limit is integrated", powerSeries(base, time.Duration(0), 600.0, energyMaxGap, 600.0), 300, energyMaxGap},
+		{"gap over the limit is skipped", powerSeries(base,
+			time.Duration(0), 1000.0, 10*time.Minute, 1000.0, // 10 min covered
+			10*time.Minute+energyMaxGap+time.Second, 5000.0, // gap, skipped
+			20*time.Minute+energyMaxGap+time.Second, 5000.0), // 10 min covered
+			1000.0/6 + 5000.0/6, 20 * time.Minute},
+		{"bad timestamp skipped", append(powerSeries(base, time.Duration(0), 1000.0, time.Hour/2, 1000.0),
+			TimeSeriesPoint{Timestamp: "garbage", Value: 1e6}), 500, time.Hour / 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			span := integrateEnergy(tt.points)
+			if !closeTo(span.wattHours, tt.wantWh) || span.covered != tt.wantCovered {
+				t.Errorf("integrateEnergy = %.6f Wh over %v, want %.6f Wh over %v", span.wattHours, span.covered, tt.wantWh, tt.wantCovered)
+			}
+		})
+	}
+}
+
+func TestIntegratePeriods(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*3600)
+	midnight := time.Date(2026, 3, 2, 0, 0, 0, 0, loc)
+	day1, day2 := midnight.AddDate(0, 0, -1), midnight
+
+	type daySpan struct {
+		wh      float64
+		covered time.Duration
+	}
+	tests := []struct {
+		name   string
+		points []TimeSeriesPoint
+		want   map[time.Time]daySpan
+	}{
+		{"single sample marks its day", powerSeries(midnight, -time.Hour, 1000.0),
+			map[time.Time]daySpan{day1: {0, 0}}},
+		{"split at midnight", powerSeries(midnight, -10*time.Minute, 1000.0, 10*time.Minute, 3000.0),
+			// 2000 W at midnight: 10 min at avg 1500 W, then 10 min at avg 2500 W
+			map[time.Time]daySpan{day1: {250, 10 * time.Minute}, day2: {2500.0 / 6, 10 * time.Minute}}},
+		{"sample at midnight", powerSeries(midnight, -15*time.Minute, 1200.0, time.Duration(0), 1200.0, 15*time.Minute, 1200.0),
+			map[time.Time]daySpan{day1: {300, 15 * time.Minute}, day2: {300, 15 * time.Minute}}},
+		{"gap across midnight adds nothing", powerSeries(midnight, -time.Hour, 1000.0, time.Hour, 1000.0),
+			map[time.Time]daySpan{day1: {0, 0}, day2: {0, 0}}},
+		{"partial current day", powerSeries(midnight,
+			8*time.Hour, 500.0, 8*time.Hour+20*time.Minute, 500.0, 8*time.Hour+40*time.Minute, 500.0),
+			map[time.Time]daySpan{day2: {500.0 * 40 / 60, 40 * time.Minute}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spans := integratePeriods(tt.points, dayIn(loc))
+			if len(spans) != len(tt.want) {
+				t.Fatalf("got %d days, want %d", len(spans), len(tt.want))
+			}
+			for start, want := range tt.want {
+				got := spans[start]
+				if got == nil {
+					t.Fatalf("no span for %v", start)
+				}
+				if !closeTo(got.wattHours, want.wh) || got.covered != want.covered {
+					t.Errorf("%v: %.6f Wh over %v, want %.6f Wh over %v", start.Format("2006-01-02"), got.wattHours, got.covered, want.wh, want.covered)
+				}
+			}
+		})
+	}
+}
+
+func TestDailyEnergyRowsPartialDay(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*3600)
+	midnight := time.Date(2026, 3, 2, 0, 0, 0, 0, loc)
+	// The whole of yesterday at 1 kW, then today until 06:00 at 2 kW
+	var samples []interface{}
+	for d := -24 * time.Hour; d <= 6*time.Hour; d += 15 * time.Minute {
+		w := 1000.0
+		if d > 0 {
+			w = 2000.0
+		}
+		samples = append(samples, d, w)
+	}
+	days := dailyEnergyRows(powerSeries(midnight, samples...), loc)
+
+	if len(days) != 2 {
+		t.Fatalf("got %d days, want 2: %+v", len(days), days)
+	}
+	yesterday, today := days[0], days[1]
+	if yesterday.Date != "2026-03-01" || !closeTo(yesterday.CoveredHours, 24) || !closeTo(yesterday.EnergyKWh, 24) {
+		t.Errorf("yesterday = %+v, want 24 kWh over 24 h", yesterday)
+	}
+	// The first 15 minutes ramp from 1 to 2 kW; the rest is 5.75 h at 2 kW,
+	// measured only and not extrapolated to the whole day
+	wantKWh := 0.25*1.5 + 5.75*2
+	if today.Date != "2026-03-02" || !closeTo(today.CoveredHours, 6) || !closeTo(today.EnergyKWh, wantKWh) || !closeTo(today.AvgPowerW, wantKWh*1000/6) {
+		t.Errorf("today = %+v, want %.4f kWh over 6 h", today, wantKWh)
+	}
+}
@@ -0,0 +1,166 @@
+package questdb
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Instances a query can be served by.
+const (
+	InstancePrimary   = "primary"
+	InstanceSecondary = "secondary"
+)
+
+// failover tracks the health of the primary instance of a client with a
+// secondary. It is shared by the copies of the client.
+type failover struct {
+	secondaryURL string
+
+	mu             sync.Mutex
+	primaryUp      bool
+	checkedAt      time.Time
+	lastError      string
+	downSince      time.Time
+	failovers      int
+	onSwitch       func(up bool, err error)
+	secondaryReads int // reads served by the secondary
+}
+
+// readStatement matches the statements a secondary may serve; everything
+// else, like DDL, INSERTs and ILP writes, only goes to the primary.
+var readStatement = regexp.MustCompile(`(?i)^\s*(SELECT|WITH|SHOW|EXPLAIN)\b`)
+
+// WithSecondary returns a copy of the client that sends reads to the QuestDB
+// instance at host:port, e.g. a replica or a backup restored nightly, while
+// the primary is down. Writes and schema changes still only go to the
+// primary. onSwitch, if not nil, is called when reads move to the secondary
+// (up false) or back to the primary.
+func (c *Client) WithSecondary(host string, port int, onSwitch func(up bool, err error)) *Client {
+	c2 := *c
+	c2.failover = &failover{
+		secondaryURL: fmt.Sprintf("http://%s:%d", host, port),
+		primaryUp:    true,
+		onSwitch:     onSwitch,
+	}
+	return &c2
+}
+
+// readInstance picks the instance to run a bound statement on.
+func (c *Client) readInstance(query string) string {
+	f := c.failover
+	if f == nil || !readStatement.MatchString(query) {
+		return InstancePrimary
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.primaryUp {
+		return InstancePrimary
+	}
+	f.secondaryReads++
+	return InstanceSecondary
+}
+
+// shouldRetry reports whether a read that failed on the primary is worth
+// running on the secondary: the primary could not be reached, and not
+// because the caller gave up.
+func (f *failover) shouldRetry(query, kind string, ctx context.Context) bool {
+	if f == nil || !readStatement.MatchString(query) || ctx.Err() != nil {
+		return false
+	}
+	return kind == ErrorNetwork || kind == ErrorTimeout
+}
+
+// set records the outcome of a check of the primary.
+func (f *failover) set(up bool, err error) {
+	f.mu.Lock()
+	now := time.Now()
+	f.checkedAt = now
+	f.lastError = ""
+	if err != nil {
+		f.lastError = err.Error()
+	}
+	switched := f.primaryUp != up
+	f.primaryUp = up
+	if switched && !up {
+		f.downSince = now
+		f.failovers++
+	}
+	if up {
+		f.downSince = time.Time{}
+	}
+	onSwitch := f.onSwitch
+	f.mu.Unlock()
+
+	if switched && onSwitch != nil {
+		onSwitch(up, err)
+	}
+}
+
+// CheckPrimary pings the primary instance and, with a secondary configured,
+// moves reads to the secondary while it is down and back once it answers.
+func (c *Client) CheckPrimary() error {
+	ctx, cancel := context.WithTimeout(c.context(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/exec?query=SELECT%201", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		}
+	}
+	if c.failover != nil {
+		c.failover.set(err == nil, err)
+	}
+	return err
+}
+
+// FailoverStatus describes the instances of a client with a secondary.
+type FailoverStatus struct {
+	Primary        string     `json:"primary"`
+	Secondary      string     `json:"secondary"`
+	Serving        string     `json:"serving"` // instance reads currently go to
+	PrimaryUp      bool       `json:"primaryUp"`
+	CheckedAt      time.Time  `json:"checkedAt"`
+	LastError      string     `json:"lastError,omitempty"`
+	DownSince      *time.Time `json:"downSince,omitempty"`
+	Failovers      int        `json:"failovers"`
+	SecondaryReads int        `json:"secondaryReads"`
+}
+
+// Failover returns the state of the client's instances, or nil without a
+// secondary.
+func (c *Client) Failover() *FailoverStatus {
+	f := c.failover
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	serving := InstancePrimary
+	if !f.primaryUp {
+		serving = InstanceSecondary
+	}
+	status := &FailoverStatus{
+		Primary:        c.baseURL,
+		Secondary:      f.secondaryURL,
+		Serving:        serving,
+		PrimaryUp:      f.primaryUp,
+		CheckedAt:      f.checkedAt,
+		LastError:      f.lastError,
+		Failovers:      f.failovers,
+		SecondaryReads: f.secondaryReads,
+	}
+	if !f.downSince.IsZero() {
+		downSince := f.downSince
+		status.DownSince = &downSince
+	}
+	return status
+}
@@ -0,0 +1,26 @@
+package questdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// GetLastInsert returns the newest row timestamp of a table, or false if the
+// table is empty. Feeds write rows stamped with the time of the reading, so
+// this is when the feed last delivered data.
+func (c *Client) GetLastInsert(table string) (time.Time, bool, error) {
+	result, err := c.Query("SELECT max(timestamp) AS ts FROM ?;", Ident(table))
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to query last insert into %s: %w", table, err)
+	}
+	var r struct {
+		TS *time.Time `qdb:"ts"`
+	}
+	if err := result.Scan(&r); err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to parse last insert into %s: %w", table, err)
+	}
+	if r.TS == nil {
+		return time.Time{}, false, nil
+	}
+	return *r.TS, true, nil
+}
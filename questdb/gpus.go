@@ -0,0 +1,102 @@
+package questdb
+
+import (
+	"fmt"
+	"sort"
+)
+
+// GPUReading is one GPU of a rig as written by the GPU poller. Hashrate is in
+// H/s of the rig's algorithm; GPU rigs don't share the SHA-256 units of
+// miner_status.
+type GPUReading struct {
+	Timestamp      string  `json:"timestamp" qdb:"timestamp"`
+	RigIP          string  `json:"rigIp" qdb:"rig_ip"`
+	GPU            string  `json:"gpu" qdb:"gpu"` // index within the rig
+	Name           string  `json:"name" qdb:"name"`
+	Algorithm      string  `json:"algorithm" qdb:"algorithm"`
+	Temperature    float64 `json:"temperature" qdb:"temperature"`
+	MemTemperature float64 `json:"memTemperature" qdb:"memory_temperature"` // 0 when not reported
+	FanSpeed       float64 `json:"fanSpeed" qdb:"fan_speed"`                // %
+	Power          float64 `json:"power" qdb:"power"`                       // W
+	Hashrate       float64 `json:"hashrate" qdb:"hashrate"`                 // H/s
+}
+
+// GPULatestData holds the latest reading of every GPU.
+type GPULatestData struct {
+	GPUs    []GPUReading `json:"gpus"`
+	HasData bool         `json:"hasData"`
+}
+
+// GetLatestGPUs returns the latest reading of every GPU reported in the last
+// 10 minutes, by rig IP and GPU index.
+func (c *Client) GetLatestGPUs() (*GPULatestData, error) {
+	const query = `SELECT timestamp, rig_ip, gpu, name, algorithm, temperature, memory_temperature, fan_speed, power, hashrate
+  FROM gpu_status WHERE timestamp > dateadd('m', -10, now()) LATEST ON timestamp PARTITION BY rig_ip, gpu;`
+
+	result, err := c.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest GPUs: %w", err)
+	}
+
+	var gpus []GPUReading
+	if err := result.Scan(&gpus); err != nil {
+		return nil, fmt.Errorf("failed to parse latest GPUs: %w", err)
+	}
+	sort.Slice(gpus, func(i, j int) bool {
+		if gpus[i].RigIP != gpus[j].RigIP {
+			return gpus[i].RigIP < gpus[j].RigIP
+		}
+		return gpus[i].GPU < gpus[j].GPU
+	})
+
+	return &GPULatestData{GPUs: gpus, HasData: len(gpus) > 0}, nil
+}
+
+// GPUPoint is the 5-minute average of one GPU.
+type GPUPoint struct {
+	Timestamp   string  `json:"timestamp"`
+	Temperature float64 `json:"temperature"`
+	Power       float64 `json:"power"`
+	Hashrate    float64 `json:"hashrate"`
+}
+
+// GPUChartData holds GPU series keyed by "<rig IP>/<GPU index>".
+type GPUChartData struct {
+	GPUs    map[string][]GPUPoint `json:"gpus"`
+	HasData bool                  `json:"hasData"`
+}
+
+// GetGPUTimeSeries returns temperature, power and hashrate per GPU in
+// 5-minute buckets over the last 24 hours.
+func (c *Client) GetGPUTimeSeries() (*GPUChartData, error) {
+	const query = `SELECT timestamp, rig_ip, gpu, avg(temperature) AS temperature, avg(power) AS power, avg(hashrate) AS hashrate
+  FROM gpu_status WHERE timestamp > dateadd('h', -24, now()) SAMPLE BY 5m ALIGN TO CALENDAR;`
+
+	result, err := c.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query GPU time series: %w", err)
+	}
+
+	var readings []GPUReading
+	if err := result.Scan(&readings); err != nil {
+		return nil, fmt.Errorf("failed to parse GPU time series: %w", err)
+	}
+
+	gpus := make(map[string][]GPUPoint)
+	for _, r := range readings {
+		key := r.RigIP + "/" + r.GPU
+		gpus[key] = append(gpus[key], GPUPoint{
+			Timestamp:   r.Timestamp,
+			Temperature: r.Temperature,
+			Power:       r.Power,
+			Hashrate:    r.Hashrate,
+		})
+	}
+	for _, points := range gpus {
+		sort.Slice(points, func(i, j int) bool {
+			return points[i].Timestamp < points[j].Timestamp
+		})
+	}
+
+	return &GPUChartData{GPUs: gpus, HasData: len(gpus) > 0}, nil
+}
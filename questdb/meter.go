@@ -0,0 +1,127 @@
+package questdb
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// roomMeterFresh is how old the latest room meter reading may be for the meter
+// to be used as the total power source.
+const roomMeterFresh = 5 * time.Minute
+
+// PhaseReading is one phase of a room meter reading.
+type PhaseReading struct {
+	Phase   string  `json:"phase"` // "a", "b" or "c"
+	Power   float64 `json:"power"` // W
+	Voltage float64 `json:"voltage"`
+	Current float64 `json:"current"`
+}
+
+// RoomMeterReading is the latest reading of the whole-room 3-phase meter.
+type RoomMeterReading struct {
+	Timestamp string         `json:"timestamp"`
+	Power     float64        `json:"power"` // W, all phases
+	Phases    []PhaseReading `json:"phases"`
+}
+
+// GetLatestRoomMeter returns the latest room meter reading, or nil if the
+// meter has never reported.
+func (c *Client) GetLatestRoomMeter() (*RoomMeterReading, error) {
+	const query = `SELECT timestamp, power, power_a, power_b, power_c, voltage_a, voltage_b, voltage_c, current_a, current_b, current_c
+  FROM room_meter LATEST ON timestamp PARTITION BY device_id;`
+
+	result, err := c.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query room meter: %w", err)
+	}
+	var rows []struct {
+		Timestamp string  `qdb:"timestamp"`
+		Power     float64 `qdb:"power"`
+		PowerA    float64 `qdb:"power_a"`
+		PowerB    float64 `qdb:"power_b"`
+		PowerC    float64 `qdb:"power_c"`
+		VoltageA  float64 `qdb:"voltage_a"`
+		VoltageB  float64 `qdb:"voltage_b"`
+		VoltageC  float64 `qdb:"voltage_c"`
+		CurrentA  float64 `qdb:"current_a"`
+		CurrentB  float64 `qdb:"current_b"`
+		CurrentC  float64 `qdb:"current_c"`
+	}
+	if err := result.Scan(&rows); err != nil {
+		return nil, fmt.Errorf("failed to parse room meter: %w", err)
+	}
+
+	var latest *RoomMeterReading
+	for _, r := range rows {
+		if latest != nil && r.Timestamp <= latest.Timestamp {
+			continue
+		}
+		latest = &RoomMeterReading{Timestamp: r.Timestamp, Power: r.Power, Phases: []PhaseReading{
+			{Phase: "a", Power: r.PowerA, Voltage: r.VoltageA, Current: r.CurrentA},
+			{Phase: "b", Power: r.PowerB, Voltage: r.VoltageB, Current: r.CurrentB},
+			{Phase: "c", Power: r.PowerC, Voltage: r.VoltageC, Current: r.CurrentC},
+		}}
+	}
+	return latest, nil
+}
+
+// freshRoomMeter returns the latest room meter reading if it is recent enough
+// to replace the sum of the plugs.
+func (c *Client) freshRoomMeter() *RoomMeterReading {
+	m, err := c.GetLatestRoomMeter()
+	if err != nil || m == nil {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, m.Timestamp)
+	if err != nil || time.Since(t) > roomMeterFresh {
+		return nil
+	}
+	return m
+}
+
+// totalPowerSeries returns total power per 10 minute bucket of the window,
+// from the room meter where it has data and from the sum of the plugs
+// elsewhere. window is a constant WHERE condition whose placeholders are
+// bound to args.
+func (c *Client) totalPowerSeries(window string, args ...interface{}) ([]TimeSeriesPoint, error) {
+	return c.totalPowerSeriesBy("10m", window, args...)
+}
+
+// totalPowerSeriesBy is totalPowerSeries with a constant SAMPLE BY bucket.
+func (c *Client) totalPowerSeriesBy(bucket, window string, args ...interface{}) ([]TimeSeriesPoint, error) {
+	result, err := c.Query(`SELECT timestamp, sum(power) AS value FROM shellies WHERE `+window+` SAMPLE BY `+bucket+` ALIGN TO CALENDAR;`, args...)
+	if err != nil {
+		return nil, err
+	}
+	var plugs []TimeSeriesPoint
+	if err := result.Scan(&plugs); err != nil {
+		return nil, err
+	}
+	byTimestamp := make(map[string]float64, len(plugs))
+	for _, p := range plugs {
+		byTimestamp[p.Timestamp] = p.Value
+	}
+	// A missing room_meter table just means there is no meter
+	if meter, err := c.Query(`SELECT timestamp, avg(power) AS power FROM room_meter WHERE `+window+` SAMPLE BY `+bucket+` ALIGN TO CALENDAR;`, args...); err == nil {
+		var readings []struct {
+			Timestamp string   `qdb:"timestamp"`
+			Power     *float64 `qdb:"power"`
+		}
+		if err := meter.Scan(&readings); err != nil {
+			return nil, err
+		}
+		for _, r := range readings {
+			if r.Power != nil {
+				byTimestamp[r.Timestamp] = *r.Power
+			}
+		}
+	}
+
+	points := make([]TimeSeriesPoint, 0, len(byTimestamp))
+	for ts, v := range byTimestamp {
+		points = append(points, TimeSeriesPoint{Timestamp: ts, Value: v})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Timestamp < points[j].Timestamp })
+	return points, nil
+}
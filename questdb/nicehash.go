@@ -0,0 +1,76 @@
+package questdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// poolEarningsMinSpan is the shortest stretch of account readings the
+// earnings of the last 24 hours are scaled up from; with less history there
+// are no actual earnings yet.
+const poolEarningsMinSpan = 20 * time.Hour
+
+// PoolEarnings is what the pool credited the account with over the last 24
+// hours, written by the nicehash-telegraf poller.
+type PoolEarnings struct {
+	Source string    `json:"source"` // "nicehash"
+	BTC    float64   `json:"btc"`    // scaled to 24 hours
+	From   time.Time `json:"from"`   // first account reading used
+	To     time.Time `json:"to"`     // last account reading used
+}
+
+// GetNiceHashEarnings returns the BTC credited over the last 24 hours: the
+// growth of the unpaid balance between the first and last account readings
+// plus the payouts in between, which the balance drops by. Payouts are
+// listed on every poll, so they are counted once per payout ID. It returns
+// nil without NiceHash data spanning poolEarningsMinSpan; a missing
+// nicehash_account table just means the poller isn't set up.
+func (c *Client) GetNiceHashEarnings() (*PoolEarnings, error) {
+	const window = "timestamp > dateadd('h', -24, now())"
+	type reading struct {
+		Timestamp time.Time `qdb:"timestamp"`
+		Unpaid    float64   `qdb:"unpaid_total"`
+	}
+	var first, last []reading
+	for _, q := range []struct {
+		order string
+		dest  *[]reading
+	}{{"ASC", &first}, {"DESC", &last}} {
+		result, err := c.Query(`SELECT timestamp, unpaid_total FROM nicehash_account WHERE ` + window + ` ORDER BY timestamp ` + q.order + ` LIMIT 1;`)
+		if err != nil {
+			return nil, nil
+		}
+		if err := result.Scan(q.dest); err != nil {
+			return nil, fmt.Errorf("failed to parse NiceHash account: %w", err)
+		}
+	}
+	if len(first) == 0 || len(last) == 0 {
+		return nil, nil
+	}
+	span := last[0].Timestamp.Sub(first[0].Timestamp)
+	if span < poolEarningsMinSpan {
+		return nil, nil
+	}
+
+	// The nicehash_payouts table only exists after the first payout
+	var payouts []struct {
+		Amount float64 `qdb:"amount"`
+	}
+	const payoutQuery = `SELECT payout_id, max(amount) AS amount FROM nicehash_payouts WHERE timestamp > ? AND timestamp <= ?;`
+	if result, err := c.Query(payoutQuery, first[0].Timestamp, last[0].Timestamp); err == nil {
+		if err := result.Scan(&payouts); err != nil {
+			return nil, fmt.Errorf("failed to parse NiceHash payouts: %w", err)
+		}
+	}
+
+	earned := last[0].Unpaid - first[0].Unpaid
+	for _, p := range payouts {
+		earned += p.Amount
+	}
+	return &PoolEarnings{
+		Source: "nicehash",
+		BTC:    earned * (24 * time.Hour).Hours() / span.Hours(),
+		From:   first[0].Timestamp,
+		To:     last[0].Timestamp,
+	}, nil
+}
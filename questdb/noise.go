@@ -0,0 +1,76 @@
+package questdb
+
+import "fmt"
+
+// NoisePowerPoint is the average sound level and total power for one
+// 10-minute bucket.
+type NoisePowerPoint struct {
+	Timestamp string  `json:"timestamp"`
+	Noise     float64 `json:"noise"` // dB(A)
+	Power     float64 `json:"power"` // W, 0 when no power data
+}
+
+// NoisePowerData holds noise and power over the last 24 hours for correlation.
+type NoisePowerData struct {
+	Points  []NoisePowerPoint `json:"points"`
+	HasData bool              `json:"hasData"`
+}
+
+// GetRecentNoise returns the loudest sensor's average sound level over the
+// last given minutes.
+func (c *Client) GetRecentNoise(minutes int) (float64, bool, error) {
+	const query = "SELECT max(level) AS level FROM (SELECT sensor_id, avg(db) level FROM sound_levels WHERE timestamp > dateadd('m', ?, now()) GROUP BY sensor_id);"
+
+	result, err := c.Query(query, -minutes)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to query sound levels: %w", err)
+	}
+	var r struct {
+		Level *float64 `qdb:"level"`
+	}
+	if err := result.Scan(&r); err != nil {
+		return 0, false, fmt.Errorf("failed to parse sound levels: %w", err)
+	}
+	if r.Level == nil {
+		return 0, false, nil
+	}
+	return *r.Level, true, nil
+}
+
+// GetNoisePowerTimeSeries returns sound level and total Shelly power sampled
+// every 10 minutes over the last 24 hours.
+func (c *Client) GetNoisePowerTimeSeries() (*NoisePowerData, error) {
+	const noiseQuery = `SELECT timestamp, avg(db) AS value FROM sound_levels WHERE timestamp > dateadd('h', -24, now()) SAMPLE BY 10m ALIGN TO CALENDAR;`
+
+	noiseResult, err := c.Query(noiseQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sound levels: %w", err)
+	}
+	power, err := c.GetPowerTimeSeries()
+	if err != nil {
+		return nil, err
+	}
+
+	powerMap := make(map[string]float64, len(power.Points))
+	for _, p := range power.Points {
+		powerMap[p.Timestamp] = p.Value
+	}
+
+	var noise []TimeSeriesPoint
+	if err := noiseResult.Scan(&noise); err != nil {
+		return nil, fmt.Errorf("failed to parse sound levels: %w", err)
+	}
+	points := make([]NoisePowerPoint, 0, len(noise))
+	for _, n := range noise {
+		points = append(points, NoisePowerPoint{
+			Timestamp: n.Timestamp,
+			Noise:     n.Value,
+			Power:     powerMap[n.Timestamp],
+		})
+	}
+
+	return &NoisePowerData{
+		Points:  points,
+		HasData: len(points) > 0,
+	}, nil
+}
@@ -0,0 +1,48 @@
+package questdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// Payout is a confirmed on-chain payment to a watched payout address.
+type Payout struct {
+	Timestamp   time.Time `json:"time" qdb:"timestamp"` // block time
+	Address     string    `json:"address" qdb:"address"`
+	TxID        string    `json:"txid" qdb:"txid"`
+	AmountBTC   float64   `json:"amountBtc" qdb:"amount"`
+	BlockHeight int64     `json:"blockHeight" qdb:"block_height"`
+}
+
+// GetPayouts returns the payouts received since from, newest first.
+func (c *Client) GetPayouts(from time.Time) ([]Payout, error) {
+	const query = `SELECT timestamp, address, txid, amount, block_height FROM btc_payouts WHERE timestamp >= ? ORDER BY timestamp DESC;`
+	result, err := c.Query(query, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payouts: %w", err)
+	}
+	var payouts []Payout
+	if err := result.Scan(&payouts); err != nil {
+		return nil, fmt.Errorf("failed to parse payouts: %w", err)
+	}
+	return payouts, nil
+}
+
+// GetPayoutTxIDs returns the transaction IDs of every recorded payout.
+func (c *Client) GetPayoutTxIDs() (map[string]bool, error) {
+	result, err := c.Query(`SELECT DISTINCT txid FROM btc_payouts;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payout transactions: %w", err)
+	}
+	var rows []struct {
+		TxID string `qdb:"txid"`
+	}
+	if err := result.Scan(&rows); err != nil {
+		return nil, fmt.Errorf("failed to parse payout transactions: %w", err)
+	}
+	ids := make(map[string]bool, len(rows))
+	for _, r := range rows {
+		ids[r.TxID] = true
+	}
+	return ids, nil
+}
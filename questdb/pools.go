@@ -0,0 +1,217 @@
+package questdb
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// PoolStat is the latest connection state and share counters of one pool of
+// a miner, as written by the pool monitor. Counters are totals since the
+// miner's firmware started.
+type PoolStat struct {
+	Timestamp string  `qdb:"timestamp"`
+	MinerIP   string  `qdb:"miner_ip"`
+	PoolURL   string  `qdb:"pool_url"`
+	User      string  `qdb:"pool_user"`
+	Status    string  `qdb:"status"`
+	Accepted  float64 `qdb:"accepted"`
+	Rejected  float64 `qdb:"rejected"`
+	Stale     float64 `qdb:"stale"`
+	LastShare int64   `qdb:"last_share"` // unix seconds, 0 when no share was submitted yet
+}
+
+// MinerPoolSummary is the pool state of a miner shown in the status table.
+type MinerPoolSummary struct {
+	URL       string  `json:"url"` // pool that submitted the last share
+	Status    string  `json:"status"`
+	Alive     bool    `json:"alive"` // any configured pool is connected
+	LastShare string  `json:"lastShare,omitempty"`
+	Accepted  float64 `json:"accepted"`
+	Rejected  float64 `json:"rejected"`
+	Stale     float64 `json:"stale"`
+	RejectPct float64 `json:"rejectPct"` // of all submitted shares
+	StalePct  float64 `json:"stalePct"`
+}
+
+// ShareDelta holds the shares a miner submitted within a window.
+type ShareDelta struct {
+	Accepted float64
+	Rejected float64
+	Stale    float64
+}
+
+// RejectPoint is the shares a miner submitted in one 10-minute bucket.
+type RejectPoint struct {
+	Timestamp string  `json:"timestamp"`
+	Accepted  float64 `json:"accepted"`
+	Rejected  float64 `json:"rejected"`
+	RejectPct float64 `json:"rejectPct"`
+}
+
+// RejectSeriesData holds rejected shares per miner IP over the last 24 hours.
+type RejectSeriesData struct {
+	Miners  map[string][]RejectPoint `json:"miners"`
+	HasData bool                     `json:"hasData"`
+}
+
+// GetLatestPoolStats returns the latest counters of every pool reported in
+// the last 10 minutes, so pools removed from a miner drop out.
+func (c *Client) GetLatestPoolStats() ([]PoolStat, error) {
+	const query = `SELECT timestamp, miner_ip, pool_url, pool_user, status, accepted, rejected, stale, last_share
+  FROM miner_pools WHERE timestamp > dateadd('m', -10, now()) LATEST ON timestamp PARTITION BY miner_ip, pool_url;`
+
+	result, err := c.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pool stats: %w", err)
+	}
+
+	var stats []PoolStat
+	if err := result.Scan(&stats); err != nil {
+		return nil, fmt.Errorf("failed to parse pool stats: %w", err)
+	}
+	return stats, nil
+}
+
+// SummarizePools combines the pools of each miner, by miner IP. alive reports
+// whether a pool status means the pool is connected.
+func SummarizePools(stats []PoolStat, alive func(status string) bool) map[string]*MinerPoolSummary {
+	summaries := make(map[string]*MinerPoolSummary)
+	lastShare := make(map[string]int64)
+	for _, s := range stats {
+		sum, ok := summaries[s.MinerIP]
+		if !ok {
+			sum = &MinerPoolSummary{URL: s.PoolURL, Status: s.Status}
+			summaries[s.MinerIP] = sum
+		}
+		sum.Accepted += s.Accepted
+		sum.Rejected += s.Rejected
+		sum.Stale += s.Stale
+		if alive(s.Status) {
+			sum.Alive = true
+		}
+		if s.LastShare > lastShare[s.MinerIP] {
+			lastShare[s.MinerIP] = s.LastShare
+			sum.URL, sum.Status = s.PoolURL, s.Status
+			sum.LastShare = time.Unix(s.LastShare, 0).UTC().Format(time.RFC3339)
+		}
+	}
+	for _, sum := range summaries {
+		sum.RejectPct = sharePct(sum.Rejected, sum.Accepted+sum.Rejected+sum.Stale)
+		sum.StalePct = sharePct(sum.Stale, sum.Accepted+sum.Rejected+sum.Stale)
+	}
+	return summaries
+}
+
+// GetShareDeltas returns the shares each miner submitted in the last given
+// minutes, by miner IP. A counter that went down (miner restarted) counts
+// from zero.
+func (c *Client) GetShareDeltas(minutes int) (map[string]ShareDelta, error) {
+	const query = `SELECT miner_ip, pool_url, first(accepted) AS accepted_first, last(accepted) AS accepted_last,
+  first(rejected) AS rejected_first, last(rejected) AS rejected_last, first(stale) AS stale_first, last(stale) AS stale_last
+  FROM miner_pools WHERE timestamp > dateadd('m', ?, now());`
+
+	result, err := c.Query(query, -minutes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query share counters: %w", err)
+	}
+
+	var rows []struct {
+		MinerIP       string  `qdb:"miner_ip"`
+		AcceptedFirst float64 `qdb:"accepted_first"`
+		AcceptedLast  float64 `qdb:"accepted_last"`
+		RejectedFirst float64 `qdb:"rejected_first"`
+		RejectedLast  float64 `qdb:"rejected_last"`
+		StaleFirst    float64 `qdb:"stale_first"`
+		StaleLast     float64 `qdb:"stale_last"`
+	}
+	if err := result.Scan(&rows); err != nil {
+		return nil, fmt.Errorf("failed to parse share counters: %w", err)
+	}
+
+	deltas := make(map[string]ShareDelta)
+	for _, r := range rows {
+		d := deltas[r.MinerIP]
+		d.Accepted += counterDelta(r.AcceptedFirst, r.AcceptedLast)
+		d.Rejected += counterDelta(r.RejectedFirst, r.RejectedLast)
+		d.Stale += counterDelta(r.StaleFirst, r.StaleLast)
+		deltas[r.MinerIP] = d
+	}
+	return deltas, nil
+}
+
+// GetRejectTimeSeries returns accepted and rejected shares per miner in
+// 10-minute buckets over the last 24 hours.
+func (c *Client) GetRejectTimeSeries() (*RejectSeriesData, error) {
+	const query = `SELECT timestamp, miner_ip, pool_url, last(accepted) AS accepted, last(rejected) AS rejected
+  FROM miner_pools WHERE timestamp > dateadd('h', -24, now()) SAMPLE BY 10m ALIGN TO CALENDAR;`
+
+	result, err := c.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reject time series: %w", err)
+	}
+
+	var rows []PoolStat
+	if err := result.Scan(&rows); err != nil {
+		return nil, fmt.Errorf("failed to parse reject time series: %w", err)
+	}
+
+	type counters struct{ accepted, rejected float64 }
+	previous := make(map[string]counters) // by miner IP and pool URL
+	buckets := make(map[string]map[string]*RejectPoint)
+	for _, r := range rows {
+		ts, ip := r.Timestamp, r.MinerIP
+		cur := counters{r.Accepted, r.Rejected}
+
+		key := ip + " " + r.PoolURL
+		prev, seen := previous[key]
+		previous[key] = cur
+		if !seen {
+			continue // the first bucket has no baseline
+		}
+
+		if buckets[ip] == nil {
+			buckets[ip] = make(map[string]*RejectPoint)
+		}
+		p := buckets[ip][ts]
+		if p == nil {
+			p = &RejectPoint{Timestamp: ts}
+			buckets[ip][ts] = p
+		}
+		p.Accepted += counterDelta(prev.accepted, cur.accepted)
+		p.Rejected += counterDelta(prev.rejected, cur.rejected)
+	}
+
+	data := &RejectSeriesData{Miners: make(map[string][]RejectPoint)}
+	for ip, byTime := range buckets {
+		points := make([]RejectPoint, 0, len(byTime))
+		for _, p := range byTime {
+			p.RejectPct = sharePct(p.Rejected, p.Accepted+p.Rejected)
+			points = append(points, *p)
+		}
+		sort.Slice(points, func(i, j int) bool {
+			return points[i].Timestamp < points[j].Timestamp
+		})
+		data.Miners[ip] = points
+		data.HasData = true
+	}
+	return data, nil
+}
+
+// counterDelta is the increase of a share counter from first to last. A
+// counter that went down was reset, so everything since counts.
+func counterDelta(first, last float64) float64 {
+	if last < first {
+		return last
+	}
+	return last - first
+}
+
+// sharePct is part in percent of total, rounded to 0.1.
+func sharePct(part, total float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return math.Round(part/total*1000) / 10
+}
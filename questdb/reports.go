@@ -0,0 +1,124 @@
+package questdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// MinerAverages holds a miner's average reported power and hashrate over a window.
+type MinerAverages struct {
+	MinerIP     string  `json:"minerIp" qdb:"miner_ip"`
+	AvgPower    float64 `json:"avgPower" qdb:"avg_power"`       // W
+	AvgHashrate float64 `json:"avgHashrate" qdb:"avg_hashrate"` // GH/s
+	Samples     int     `json:"samples" qdb:"samples"`
+}
+
+// GetMinerAverages returns per-miner averages from miner_status between from and
+// to. Samples with zero hashrate (sleeping or starting) are excluded so they do
+// not distort efficiency.
+func (c *Client) GetMinerAverages(from, to time.Time) (map[string]MinerAverages, error) {
+	const query = `SELECT miner_ip, avg(power) AS avg_power, avg(hashrate) AS avg_hashrate, count() AS samples FROM miner_status WHERE timestamp >= ? AND timestamp < ? AND hashrate > 0;`
+
+	result, err := c.Query(query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query miner averages: %w", err)
+	}
+
+	var rows []MinerAverages
+	if err := result.Scan(&rows); err != nil {
+		return nil, fmt.Errorf("failed to parse miner averages: %w", err)
+	}
+
+	averages := make(map[string]MinerAverages)
+	for _, r := range rows {
+		averages[r.MinerIP] = r
+	}
+
+	return averages, nil
+}
+
+// TemperatureExtremes holds the lowest and highest temperature at a location.
+type TemperatureExtremes struct {
+	Location string  `json:"location" qdb:"location"`
+	Min      float64 `json:"min" qdb:"min_temp"`
+	Max      float64 `json:"max" qdb:"max_temp"`
+}
+
+// PeriodStats summarizes power, hashrate and temperatures between two times.
+type PeriodStats struct {
+	EnergyKWh    float64               `json:"energyKwh"`
+	AvgPowerW    float64               `json:"avgPowerW"`
+	AvgHashrate  float64               `json:"avgHashrate"` // GH/s, fleet total
+	UptimePct    float64               `json:"uptimePct"`   // share of 10 minute buckets with hashrate
+	Temperatures []TemperatureExtremes `json:"temperatures"`
+	HasData      bool                  `json:"hasData"`
+}
+
+// GetPeriodStats computes energy, average hashrate, uptime and temperature
+// extremes for a period. Power and hashrate are summed across devices per 10
+// minute bucket; power is integrated over time like the daily energy chart
+// and hashrate averaged. The room meter replaces the summed power where it
+// has data. Miners in
+// uptimeExclude (e.g. in maintenance) don't count towards uptime.
+func (c *Client) GetPeriodStats(from, to time.Time, uptimeExclude []string) (*PeriodStats, error) {
+	const window = "timestamp >= ? AND timestamp < ?"
+	hashrateQuery := `SELECT timestamp, sum(hashrate_average) AS total, sum(hashrate_average) AS hashing FROM pools WHERE ` + window + ` SAMPLE BY 10m ALIGN TO CALENDAR;`
+	hashrateArgs := []interface{}{from, to}
+	if len(uptimeExclude) > 0 {
+		hashrateQuery = `SELECT timestamp, sum(hashrate_average) AS total, sum(CASE WHEN miner_ip IN (?) THEN 0 ELSE hashrate_average END) AS hashing FROM pools WHERE ` + window + ` SAMPLE BY 10m ALIGN TO CALENDAR;`
+		hashrateArgs = []interface{}{uptimeExclude, from, to}
+	}
+	const tempQuery = `SELECT location, min(temperature) AS min_temp, max(temperature) AS max_temp FROM bme280_readings WHERE ` + window + `;`
+
+	stats := &PeriodStats{}
+
+	power, err := c.totalPowerSeries(window, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query period power: %w", err)
+	}
+	if len(power) > 0 {
+		span := integrateEnergy(power)
+		stats.EnergyKWh = span.wattHours / 1000
+		stats.AvgPowerW = span.avgPower()
+		stats.HasData = true
+	}
+
+	result, err := c.Query(hashrateQuery, hashrateArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query period hashrate: %w", err)
+	}
+	var samples []struct {
+		Total   float64 `qdb:"total"`
+		Hashing float64 `qdb:"hashing"` // without miners counted as down
+	}
+	if err := result.Scan(&samples); err != nil {
+		return nil, fmt.Errorf("failed to parse period hashrate: %w", err)
+	}
+	total, hashing := 0.0, 0
+	for _, s := range samples {
+		total += s.Total
+		if s.Hashing > 0 {
+			hashing++
+		}
+	}
+	if len(samples) > 0 {
+		stats.AvgHashrate = total / float64(len(samples))
+		stats.HasData = true
+	}
+	if buckets := to.Sub(from) / (10 * time.Minute); buckets > 0 {
+		stats.UptimePct = float64(hashing) / float64(buckets) * 100
+		if stats.UptimePct > 100 {
+			stats.UptimePct = 100
+		}
+	}
+
+	result, err = c.Query(tempQuery, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query period temperatures: %w", err)
+	}
+	if err := result.Scan(&stats.Temperatures); err != nil {
+		return nil, fmt.Errorf("failed to parse period temperatures: %w", err)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,204 @@
+package questdb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Rollup describes how a raw table is downsampled: fields are averaged per key
+// column into hourly and daily tables named <source>_1h and <source>_1d.
+type Rollup struct {
+	Source string
+	Key    string
+	Fields []string
+}
+
+// Rollups lists the raw tables that are downsampled for long-term history.
+var Rollups = []Rollup{
+	{Source: "shellies", Key: "device_id", Fields: []string{"power"}},
+	{Source: "pools", Key: "miner_ip", Fields: []string{"hashrate_average"}},
+	{Source: "bme280_readings", Key: "location", Fields: []string{"temperature", "humidity", "pressure"}},
+}
+
+// Resolution is a sampling interval of history data.
+type Resolution struct {
+	Name   string        // "raw", "1h" or "1d"
+	Step   time.Duration // bucket size
+	Sample string        // SAMPLE BY unit
+}
+
+var (
+	ResolutionRaw    = Resolution{Name: "raw", Step: 10 * time.Minute, Sample: "10m"}
+	ResolutionHourly = Resolution{Name: "1h", Step: time.Hour, Sample: "1h"}
+	ResolutionDaily  = Resolution{Name: "1d", Step: 24 * time.Hour, Sample: "1d"}
+)
+
+// table returns the table holding data of the given resolution.
+func (r Rollup) table(res Resolution) string {
+	if res.Name == ResolutionRaw.Name {
+		return r.Source
+	}
+	return r.Source + "_" + res.Name
+}
+
+// formatTimestamp formats t as a QuestDB timestamp literal.
+func formatTimestamp(t time.Time) string {
+	return "'" + t.UTC().Format("2006-01-02T15:04:05.000000Z") + "'"
+}
+
+// queryTimestamp runs a query for a single timestamp named ts, returning the
+// zero time when the result is empty or null.
+func (c *Client) queryTimestamp(query string, args ...interface{}) (time.Time, error) {
+	result, err := c.Query(query, args...)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var r struct {
+		TS time.Time `qdb:"ts"`
+	}
+	err = result.Scan(&r)
+	return r.TS, err
+}
+
+// RollupCoverage returns the start of the last complete bucket in the rollup
+// table, or the zero time if it has no rows.
+func (c *Client) RollupCoverage(r Rollup, res Resolution) (time.Time, error) {
+	return c.queryTimestamp("SELECT max(timestamp) AS ts FROM ?;", Ident(r.table(res)))
+}
+
+// Downsample appends all complete buckets since the last run to the rollup
+// table, creating it if needed. It returns the start of the first bucket that
+// was not rolled up yet (the current, incomplete one).
+func (c *Client) Downsample(r Rollup, res Resolution) (time.Time, error) {
+	target := r.table(res)
+
+	defs := []string{r.Key + " SYMBOL"}
+	for _, f := range r.Fields {
+		defs = append(defs, f+" DOUBLE")
+	}
+	defs = append(defs, "timestamp TIMESTAMP")
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) TIMESTAMP(timestamp) PARTITION BY MONTH WAL;",
+		target, strings.Join(defs, ", "))
+	if _, err := c.Query(create); err != nil {
+		return time.Time{}, fmt.Errorf("failed to create %s: %w", target, err)
+	}
+
+	last, err := c.RollupCoverage(r, res)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to query %s coverage: %w", target, err)
+	}
+
+	var from time.Time
+	if last.IsZero() {
+		first, err := c.queryTimestamp("SELECT min(timestamp) AS ts FROM ?;", Ident(r.Source))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to query %s start: %w", r.Source, err)
+		}
+		if first.IsZero() {
+			return time.Time{}, nil
+		}
+		from = first.Truncate(res.Step)
+	} else {
+		from = last.Add(res.Step)
+	}
+
+	to := time.Now().UTC().Truncate(res.Step)
+	if !from.Before(to) {
+		return to, nil
+	}
+
+	avgs := make([]string, 0, len(r.Fields))
+	args := []interface{}{Ident(target), Ident(r.Key)}
+	for _, f := range r.Fields {
+		avgs = append(avgs, "avg(?) ?")
+		args = append(args, Ident(f), Ident(f))
+	}
+	args = append(args, Ident(r.Source), from, to)
+	insert := "INSERT INTO ? SELECT ?, " + strings.Join(avgs, ", ") + ", timestamp FROM ? WHERE timestamp >= ? AND timestamp < ? SAMPLE BY " + res.Sample + " ALIGN TO CALENDAR;"
+	if _, err := c.Query(insert, args...); err != nil {
+		return time.Time{}, fmt.Errorf("failed to downsample %s: %w", target, err)
+	}
+
+	return to, nil
+}
+
+// DropPartitionsBefore drops raw partitions whose data is older than cutoff.
+func (c *Client) DropPartitionsBefore(table string, cutoff time.Time) error {
+	if _, err := c.Query("ALTER TABLE ? DROP PARTITION WHERE timestamp < ?;", Ident(table), cutoff); err != nil {
+		return fmt.Errorf("failed to drop partitions of %s: %w", table, err)
+	}
+	return nil
+}
+
+// HistoryData holds a downsampled time series per key (device, miner or location).
+// When the series are summed, the single key is "total".
+type HistoryData struct {
+	Resolution string                       `json:"resolution"`
+	Series     map[string][]TimeSeriesPoint `json:"series"`
+	HasData    bool                         `json:"hasData"`
+}
+
+// PickResolution selects raw data for short ranges and rollups for long ones.
+func PickResolution(from, to time.Time) Resolution {
+	span := to.Sub(from)
+	switch {
+	case span <= 48*time.Hour:
+		return ResolutionRaw
+	case span <= 90*24*time.Hour:
+		return ResolutionHourly
+	default:
+		return ResolutionDaily
+	}
+}
+
+// GetHistory returns a field of a rollup source between from and to, reading raw
+// data or the hourly/daily rollup depending on the range. If sum is true the
+// per-key series are added up into a single "total" series.
+func (c *Client) GetHistory(r Rollup, field string, from, to time.Time, sum bool) (*HistoryData, error) {
+	res := PickResolution(from, to)
+
+	query := "SELECT timestamp, ? AS series, ? AS value FROM ? WHERE timestamp >= ? AND timestamp < ? ORDER BY timestamp;"
+	if res.Name == ResolutionRaw.Name {
+		query = "SELECT timestamp, ? AS series, avg(?) AS value FROM ? WHERE timestamp >= ? AND timestamp < ? SAMPLE BY " + res.Sample + " ALIGN TO CALENDAR ORDER BY timestamp;"
+	}
+
+	result, err := c.Query(query, Ident(r.Key), Ident(field), Ident(r.table(res)), from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s history: %w", field, err)
+	}
+
+	data := &HistoryData{
+		Resolution: res.Name,
+		Series:     make(map[string][]TimeSeriesPoint),
+	}
+	var rows []struct {
+		Timestamp string   `qdb:"timestamp"`
+		Series    string   `qdb:"series"`
+		Value     *float64 `qdb:"value"`
+	}
+	if err := result.Scan(&rows); err != nil {
+		return nil, fmt.Errorf("failed to parse %s history: %w", field, err)
+	}
+	for _, r := range rows {
+		if r.Value == nil {
+			continue
+		}
+		ts, key, value := r.Timestamp, r.Series, *r.Value
+
+		if !sum {
+			data.Series[key] = append(data.Series[key], TimeSeriesPoint{Timestamp: ts, Value: value})
+			continue
+		}
+		// Rows are ordered by timestamp, so equal timestamps are adjacent
+		points := data.Series["total"]
+		if n := len(points); n > 0 && points[n-1].Timestamp == ts {
+			points[n-1].Value += value
+		} else {
+			data.Series["total"] = append(points, TimeSeriesPoint{Timestamp: ts, Value: value})
+		}
+	}
+
+	data.HasData = len(data.Series) > 0
+	return data, nil
+}
@@ -0,0 +1,49 @@
+package questdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// GetRuntimeHours returns the hours a miner hashed since the given time: the
+// hours with hashrate in the hourly pools rollup, then the 10 minute buckets
+// with hashrate in the raw table after the rollup's coverage. Without the
+// rollup the raw table is read from since.
+func (c *Client) GetRuntimeHours(ip string, since time.Time) (float64, error) {
+	hashrate := Rollups[1]
+	rawFrom := since
+	if covered, err := c.RollupCoverage(hashrate, ResolutionHourly); err == nil && !covered.IsZero() {
+		if end := covered.Add(ResolutionHourly.Step); end.After(rawFrom) {
+			rawFrom = end
+		}
+	}
+
+	hours := 0.0
+	if rawFrom.After(since) {
+		result, err := c.Query("SELECT count() AS n FROM pools_1h WHERE miner_ip = ? AND timestamp >= ? AND timestamp < ? AND hashrate_average > 0;",
+			ip, since, rawFrom)
+		if err != nil {
+			return 0, fmt.Errorf("failed to query hourly runtime: %w", err)
+		}
+		var r struct {
+			N int `qdb:"n"`
+		}
+		if err := result.Scan(&r); err != nil {
+			return 0, fmt.Errorf("failed to parse hourly runtime: %w", err)
+		}
+		hours = float64(r.N)
+	}
+
+	result, err := c.Query("SELECT count() AS n FROM (SELECT timestamp, avg(hashrate_average) AS hashrate FROM pools WHERE miner_ip = ? AND timestamp >= ? SAMPLE BY 10m ALIGN TO CALENDAR) WHERE hashrate > 0;",
+		ip, rawFrom)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query raw runtime: %w", err)
+	}
+	var r struct {
+		N int `qdb:"n"`
+	}
+	if err := result.Scan(&r); err != nil {
+		return 0, fmt.Errorf("failed to parse raw runtime: %w", err)
+	}
+	return hours + float64(r.N)/6, nil
+}
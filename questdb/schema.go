@@ -0,0 +1,262 @@
+package questdb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"miningRoom/questdb/schema"
+)
+
+// TableColumn is a column the dashboard queries rely on.
+type TableColumn struct {
+	Name string
+	Type string // QuestDB type used when creating the table
+}
+
+// TableSchema describes a table the dashboard reads from. Tables are normally
+// created by Telegraf through ILP; creating them up front keeps queries from
+// failing on a fresh QuestDB before the first metrics arrive.
+type TableSchema struct {
+	Name    string
+	Columns []TableColumn
+}
+
+// RequiredTables lists the tables and columns queried by this package: the
+// core metrics tables described by package schema, then the others.
+var RequiredTables = append(metricsTables(), []TableSchema{
+	{Name: "coolant_temperatures", Columns: []TableColumn{
+		{"loop", "SYMBOL"},
+		{"sensor_id", "SYMBOL"},
+		{"position", "SYMBOL"},
+		{"temperature", "DOUBLE"},
+	}},
+	{Name: "coolant_flow", Columns: []TableColumn{
+		{"loop", "SYMBOL"},
+		{"sensor_id", "SYMBOL"},
+		{"flow", "DOUBLE"},
+	}},
+	{Name: "sound_levels", Columns: []TableColumn{
+		{"sensor_id", "SYMBOL"},
+		{"location", "SYMBOL"},
+		{"db", "DOUBLE"},
+	}},
+	{Name: "contact_sensors", Columns: []TableColumn{
+		{"sensor_id", "SYMBOL"},
+		{"location", "SYMBOL"},
+		{"open", "BOOLEAN"},
+	}},
+	{Name: "smoke_alarms", Columns: []TableColumn{
+		{"sensor_id", "SYMBOL"},
+		{"location", "SYMBOL"},
+		{"kind", "SYMBOL"},
+		{"alarm", "BOOLEAN"},
+	}},
+	{Name: "room_meter", Columns: []TableColumn{
+		{"device_id", "SYMBOL"},
+		{"power", "DOUBLE"},
+		{"power_a", "DOUBLE"},
+		{"power_b", "DOUBLE"},
+		{"power_c", "DOUBLE"},
+		{"voltage_a", "DOUBLE"},
+		{"voltage_b", "DOUBLE"},
+		{"voltage_c", "DOUBLE"},
+		{"current_a", "DOUBLE"},
+		{"current_b", "DOUBLE"},
+		{"current_c", "DOUBLE"},
+	}},
+	{Name: "miner_pools", Columns: []TableColumn{
+		{"miner_ip", "SYMBOL"},
+		{"pool_url", "SYMBOL"},
+		{"pool_user", "SYMBOL"},
+		{"status", "SYMBOL"},
+		{"accepted", "LONG"},
+		{"rejected", "LONG"},
+		{"stale", "LONG"},
+		{"last_share", "LONG"},
+	}},
+	{Name: "gpu_status", Columns: []TableColumn{
+		{"rig_ip", "SYMBOL"},
+		{"gpu", "SYMBOL"},
+		{"name", "SYMBOL"},
+		{"algorithm", "SYMBOL"},
+		{"temperature", "DOUBLE"},
+		{"memory_temperature", "DOUBLE"},
+		{"fan_speed", "DOUBLE"},
+		{"power", "DOUBLE"},
+		{"hashrate", "DOUBLE"},
+	}},
+	{Name: "alt_network", Columns: []TableColumn{
+		{"coin", "SYMBOL"},
+		{"nethash", "DOUBLE"},
+		{"block_time", "DOUBLE"},
+		{"block_reward", "DOUBLE"},
+		{"price_eur", "DOUBLE"},
+	}},
+	{Name: "heat_recovery", Columns: []TableColumn{
+		{"circuit", "SYMBOL"},
+		{"tank_in", "DOUBLE"},
+		{"tank_out", "DOUBLE"},
+		{"flow", "DOUBLE"},
+	}},
+	{Name: "alt_pool", Columns: []TableColumn{
+		{"coin", "SYMBOL"},
+		{"hashrate_current", "DOUBLE"},
+		{"hashrate_average", "DOUBLE"},
+		{"workers", "LONG"},
+	}},
+	{Name: "btc_payouts", Columns: []TableColumn{
+		{"address", "SYMBOL"},
+		{"txid", "STRING"},
+		{"amount", "DOUBLE"},
+		{"block_height", "LONG"},
+	}},
+	{Name: "btc_price", Columns: []TableColumn{
+		{"currency", "SYMBOL"},
+		{"source", "SYMBOL"},
+		{"price", "DOUBLE"},
+	}},
+	{Name: "thermal_frames", Columns: []TableColumn{
+		{"camera", "SYMBOL"},
+		{"location", "SYMBOL"},
+		{"width", "LONG"},
+		{"height", "LONG"},
+		{"min", "DOUBLE"},
+		{"max", "DOUBLE"},
+		{"mean", "DOUBLE"},
+		{"max_x", "LONG"},
+		{"max_y", "LONG"},
+		{"frame", "STRING"},
+	}},
+}...)
+
+// metricsTables describes the tables of package schema from their rows.
+func metricsTables() []TableSchema {
+	tables := make([]TableSchema, 0, len(schema.Rows))
+	for _, row := range schema.Rows {
+		t := TableSchema{Name: row.TableName()}
+		for _, col := range schema.TableColumns(row) {
+			t.Columns = append(t.Columns, TableColumn{col.Name, col.Type})
+		}
+		tables = append(tables, t)
+	}
+	return tables
+}
+
+// TableStatus is the schema check result for a single table.
+type TableStatus struct {
+	Name           string   `json:"name"`
+	Exists         bool     `json:"exists"`
+	Created        bool     `json:"created,omitempty"`
+	MissingColumns []string `json:"missingColumns,omitempty"`
+	Error          string   `json:"error,omitempty"`
+}
+
+// SchemaReport summarizes differences between RequiredTables and the live database.
+type SchemaReport struct {
+	Tables    []TableStatus `json:"tables"`
+	Drift     bool          `json:"drift"`
+	CheckedAt time.Time     `json:"checkedAt"`
+}
+
+// EnsureSchema creates missing required tables and reports remaining drift.
+// Missing columns are only reported; ILP adds them when data arrives.
+func (c *Client) EnsureSchema() (*SchemaReport, error) {
+	return c.checkSchema(true)
+}
+
+// CheckSchema reports drift without modifying the database.
+func (c *Client) CheckSchema() (*SchemaReport, error) {
+	return c.checkSchema(false)
+}
+
+func (c *Client) checkSchema(create bool) (*SchemaReport, error) {
+	existing, err := c.listTables()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SchemaReport{CheckedAt: time.Now()}
+	for _, t := range RequiredTables {
+		status := TableStatus{Name: t.Name, Exists: existing[t.Name]}
+
+		if !status.Exists && create {
+			if _, err := c.Query(createTableSQL(t)); err != nil {
+				status.Error = err.Error()
+			} else {
+				status.Exists = true
+				status.Created = true
+			}
+		}
+
+		if status.Exists && !status.Created {
+			columns, err := c.listColumns(t.Name)
+			if err != nil {
+				status.Error = err.Error()
+			} else {
+				for _, col := range t.Columns {
+					if !columns[col.Name] {
+						status.MissingColumns = append(status.MissingColumns, col.Name)
+					}
+				}
+			}
+		}
+
+		if !status.Exists || len(status.MissingColumns) > 0 || status.Error != "" {
+			report.Drift = true
+		}
+		report.Tables = append(report.Tables, status)
+	}
+
+	return report, nil
+}
+
+// listTables returns the set of table names in the database.
+func (c *Client) listTables() (map[string]bool, error) {
+	result, err := c.Query("SHOW TABLES;")
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make(map[string]bool)
+	for _, row := range result.Dataset {
+		if len(row) < 1 {
+			continue
+		}
+		if name, ok := row[0].(string); ok {
+			tables[name] = true
+		}
+	}
+	return tables, nil
+}
+
+// listColumns returns the set of column names of a table.
+func (c *Client) listColumns(table string) (map[string]bool, error) {
+	result, err := c.Query("SHOW COLUMNS FROM ?;", table)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]bool)
+	for _, row := range result.Dataset {
+		if len(row) < 1 {
+			continue
+		}
+		if name, ok := row[0].(string); ok {
+			columns[name] = true
+		}
+	}
+	return columns, nil
+}
+
+// createTableSQL builds a CREATE TABLE statement matching what Telegraf would
+// produce: a designated timestamp column and daily partitions.
+func createTableSQL(t TableSchema) string {
+	defs := make([]string, 0, len(t.Columns)+1)
+	for _, col := range t.Columns {
+		defs = append(defs, col.Name+" "+col.Type)
+	}
+	defs = append(defs, "timestamp TIMESTAMP")
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) TIMESTAMP(timestamp) PARTITION BY DAY WAL;",
+		t.Name, strings.Join(defs, ", "))
+}
@@ -0,0 +1,251 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimeFormat is how QuestDB renders timestamps in query results.
+const TimeFormat = "2006-01-02T15:04:05.000000Z"
+
+// FormatTime renders t like QuestDB does, in UTC.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(TimeFormat)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// field is a struct field mapped to a column.
+type field struct {
+	index    int
+	column   string
+	symbol   bool
+	optional bool
+}
+
+var fieldCache sync.Map // reflect.Type -> []field
+
+// fieldsOf returns the tagged fields of a struct type, in declaration order.
+func fieldsOf(t reflect.Type) []field {
+	if cached, ok := fieldCache.Load(t); ok {
+		return cached.([]field)
+	}
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		tag, ok := t.Field(i).Tag.Lookup("qdb")
+		if !ok || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		f := field{index: i, column: name}
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "symbol":
+				f.symbol = true
+			case "optional":
+				f.optional = true
+			}
+		}
+		fields = append(fields, f)
+	}
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+// Record is a row split the way ILP writes it.
+type Record struct {
+	Table   string
+	Symbols map[string]string
+	Fields  map[string]interface{} // float64, int64, bool or string
+	Time    time.Time              // zero uses the server time
+}
+
+// Encode splits a row into its symbols, fields and timestamp. Nil pointer
+// fields are left out.
+func Encode(row Row) (Record, error) {
+	rec := Record{
+		Table:   row.TableName(),
+		Symbols: make(map[string]string),
+		Fields:  make(map[string]interface{}),
+	}
+	v := reflect.ValueOf(row)
+	for _, f := range fieldsOf(v.Type()) {
+		fv := v.Field(f.index)
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		switch {
+		case f.column == ColTimestamp && fv.Type() == timeType:
+			rec.Time = fv.Interface().(time.Time)
+		case f.symbol:
+			rec.Symbols[f.column] = fv.String()
+		default:
+			switch fv.Kind() {
+			case reflect.Float32, reflect.Float64:
+				rec.Fields[f.column] = fv.Float()
+			case reflect.Int, reflect.Int32, reflect.Int64:
+				rec.Fields[f.column] = fv.Int()
+			case reflect.Bool:
+				rec.Fields[f.column] = fv.Bool()
+			case reflect.String:
+				rec.Fields[f.column] = fv.String()
+			default:
+				return Record{}, fmt.Errorf("%s.%s: unsupported type %s", rec.Table, f.column, fv.Type())
+			}
+		}
+	}
+	return rec, nil
+}
+
+// Columns lists the required columns of a row's table, the timestamp first.
+func Columns(row Row) []string {
+	var columns []string
+	for _, f := range fieldsOf(reflect.TypeOf(row)) {
+		if !f.optional {
+			columns = append(columns, f.column)
+		}
+	}
+	return columns
+}
+
+// SelectList is the column list of a SELECT reading whole rows of a table.
+func SelectList(row Row) string {
+	return strings.Join(Columns(row), ", ")
+}
+
+// Column is a table column with the QuestDB type it is created with.
+type Column struct {
+	Name string
+	Type string
+}
+
+// TableColumns lists the required columns of a row's table other than the
+// designated timestamp, with their QuestDB types.
+func TableColumns(row Row) []Column {
+	t := reflect.TypeOf(row)
+	var columns []Column
+	for _, f := range fieldsOf(t) {
+		ft := t.Field(f.index).Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.optional || ft == timeType {
+			continue
+		}
+		columns = append(columns, Column{Name: f.column, Type: columnType(ft, f.symbol)})
+	}
+	return columns
+}
+
+func columnType(t reflect.Type, symbol bool) string {
+	switch {
+	case symbol:
+		return "SYMBOL"
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return "DOUBLE"
+	case t.Kind() == reflect.Int || t.Kind() == reflect.Int32 || t.Kind() == reflect.Int64:
+		return "LONG"
+	case t.Kind() == reflect.Bool:
+		return "BOOLEAN"
+	default:
+		return "STRING"
+	}
+}
+
+// Scan decodes one row of a query result into the struct dst points to,
+// matching columns to the fields' qdb tags by name. Columns without a field
+// are ignored and NULLs leave the field at its zero value; numbers convert
+// between floats and integers.
+func Scan(columns []string, values []interface{}, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("scan destination must be a pointer to a struct, not %T", dst)
+	}
+	v = v.Elem()
+	for _, f := range fieldsOf(v.Type()) {
+		for i, column := range columns {
+			if column != f.column || i >= len(values) {
+				continue
+			}
+			if err := setValue(v.Field(f.index), values[i]); err != nil {
+				return fmt.Errorf("column %s: %w", column, err)
+			}
+		}
+	}
+	return nil
+}
+
+// setValue stores a JSON-decoded value in a field.
+func setValue(v reflect.Value, x interface{}) error {
+	if x == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		p := reflect.New(v.Type().Elem())
+		if err := setValue(p.Elem(), x); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+	if v.Type() == timeType {
+		s, ok := x.(string)
+		if !ok {
+			return fmt.Errorf("cannot use %T as a timestamp", x)
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(x))
+		return nil
+	case reflect.String:
+		if s, ok := x.(string); ok {
+			v.SetString(s)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch n := x.(type) {
+		case float64:
+			v.SetFloat(n)
+			return nil
+		case int:
+			v.SetFloat(float64(n))
+			return nil
+		case int64:
+			v.SetFloat(float64(n))
+			return nil
+		}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		switch n := x.(type) {
+		case float64:
+			v.SetInt(int64(n))
+			return nil
+		case int:
+			v.SetInt(int64(n))
+			return nil
+		case int64:
+			v.SetInt(n)
+			return nil
+		}
+	case reflect.Bool:
+		if b, ok := x.(bool); ok {
+			v.SetBool(b)
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot use %T as %s", x, v.Type())
+}
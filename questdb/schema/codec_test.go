@@ -0,0 +1,99 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type scanRow struct {
+	Timestamp time.Time `qdb:"timestamp"`
+	MinerIP   string    `qdb:"miner_ip,symbol"`
+	Power     float64   `qdb:"power"`
+	Count     int64     `qdb:"n"`
+	Online    bool      `qdb:"online"`
+	Temp      *float64  `qdb:"temperature,optional"`
+	Untagged  string
+}
+
+func TestScan(t *testing.T) {
+	ts := time.Date(2026, 3, 1, 12, 0, 0, 123456000, time.UTC)
+	temp := 61.5
+	tests := []struct {
+		name    string
+		columns []string
+		values  []interface{}
+		want    scanRow
+		wantErr string
+	}{
+		{"all columns",
+			[]string{"timestamp", "miner_ip", "power", "n", "online", "temperature"},
+			[]interface{}{"2026-03-01T12:00:00.123456Z", "10.0.0.1", 3250.5, float64(42), true, 61.5},
+			scanRow{Timestamp: ts, MinerIP: "10.0.0.1", Power: 3250.5, Count: 42, Online: true, Temp: &temp}, ""},
+		{"columns in another order",
+			[]string{"n", "miner_ip", "power"},
+			[]interface{}{float64(7), "10.0.0.2", 100.0},
+			scanRow{MinerIP: "10.0.0.2", Power: 100, Count: 7}, ""},
+		{"unknown columns are ignored",
+			[]string{"miner_ip", "extra", "Untagged"},
+			[]interface{}{"10.0.0.3", "x", "y"},
+			scanRow{MinerIP: "10.0.0.3"}, ""},
+		{"fewer values than columns",
+			[]string{"miner_ip", "power"},
+			[]interface{}{"10.0.0.4"},
+			scanRow{MinerIP: "10.0.0.4"}, ""},
+		{"NULLs leave zero values",
+			[]string{"timestamp", "miner_ip", "power", "n", "online", "temperature"},
+			[]interface{}{nil, nil, nil, nil, nil, nil},
+			scanRow{}, ""},
+		{"integers into floats", []string{"power"}, []interface{}{3000}, scanRow{Power: 3000}, ""},
+		{"int64 into floats", []string{"power"}, []interface{}{int64(3000)}, scanRow{Power: 3000}, ""},
+		{"JSON numbers into integers", []string{"n"}, []interface{}{float64(1e6)}, scanRow{Count: 1000000}, ""},
+		{"timestamp with an offset", []string{"timestamp"}, []interface{}{"2026-03-01T14:00:00.123456+02:00"}, scanRow{Timestamp: ts}, ""},
+		{"bad timestamp", []string{"timestamp"}, []interface{}{"yesterday"}, scanRow{}, "column timestamp"},
+		{"number as timestamp", []string{"timestamp"}, []interface{}{1.7e15}, scanRow{}, "as a timestamp"},
+		{"string into a number", []string{"power"}, []interface{}{"3250"}, scanRow{}, "column power: cannot use string as float64"},
+		{"number into a string", []string{"miner_ip"}, []interface{}{1.0}, scanRow{}, "column miner_ip"},
+		{"number into a bool", []string{"online"}, []interface{}{1.0}, scanRow{}, "column online"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got scanRow
+			err := Scan(tt.columns, tt.values, &got)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Scan error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Timestamp.Equal(tt.want.Timestamp) || got.MinerIP != tt.want.MinerIP || got.Power != tt.want.Power ||
+				got.Count != tt.want.Count || got.Online != tt.want.Online || got.Untagged != "" ||
+				(got.Temp == nil) != (tt.want.Temp == nil) || got.Temp != nil && *got.Temp != *tt.want.Temp {
+				t.Errorf("Scan = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanResetsNullFields(t *testing.T) {
+	temp := 50.0
+	row := scanRow{Power: 1, Temp: &temp}
+	if err := Scan([]string{"power", "temperature"}, []interface{}{nil, nil}, &row); err != nil {
+		t.Fatal(err)
+	}
+	if row.Power != 0 || row.Temp != nil {
+		t.Errorf("Scan = %+v, want NULLs to reset the fields", row)
+	}
+}
+
+func TestScanRejectsNonStruct(t *testing.T) {
+	var n int
+	for _, dst := range []interface{}{scanRow{}, &n, nil} {
+		if err := Scan([]string{"n"}, []interface{}{1.0}, dst); err == nil {
+			t.Errorf("Scan into %T accepted", dst)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Package schema defines the rows of the core metrics tables as Go structs,
+// shared by the code that writes them through ILP and the queries that read
+// them back. A field's qdb tag names its column; the "symbol" option marks a
+// SYMBOL column and "optional" a column that is written when present but not
+// required to exist.
+package schema
+
+import "time"
+
+// Tables
+const (
+	TablePools          = "pools"
+	TableHashboards     = "hashboards"
+	TableShellies       = "shellies"
+	TableBME280Readings = "bme280_readings"
+	TableMinerStatus    = "miner_status"
+)
+
+// Columns
+const (
+	ColTimestamp       = "timestamp"
+	ColMinerIP         = "miner_ip"
+	ColIdx             = "idx"
+	ColDeviceID        = "device_id"
+	ColLocation        = "location"
+	ColHashrateAverage = "hashrate_average"
+	ColTemperatureRaw0 = "temperature_raw_0"
+	ColTemperatureRaw1 = "temperature_raw_1"
+	ColPower           = "power"
+	ColVoltage         = "voltage"
+	ColCurrent         = "current"
+	ColOutput          = "output"
+	ColTemperature     = "temperature"
+	ColHumidity        = "humidity"
+	ColPressure        = "pressure"
+	ColStatus          = "status"
+	ColWorkMode        = "work_mode"
+	ColHashrate        = "hashrate"
+	ColEfficiency      = "efficiency"
+	ColTemperatureMax  = "temperature_max"
+)
+
+// Row is a row of one of the tables.
+type Row interface {
+	TableName() string
+}
+
+// Pool is a pools row: the hashrate a miner reports for one of its pools.
+type Pool struct {
+	Timestamp       time.Time `qdb:"timestamp"`
+	MinerIP         string    `qdb:"miner_ip,symbol"`
+	Idx             string    `qdb:"idx,symbol"`
+	HashrateAverage float64   `qdb:"hashrate_average"`
+}
+
+func (Pool) TableName() string { return TablePools }
+
+// Hashboard is a hashboards row: the two temperature sensors of a hashboard.
+type Hashboard struct {
+	Timestamp       time.Time `qdb:"timestamp"`
+	MinerIP         string    `qdb:"miner_ip,symbol"`
+	Idx             string    `qdb:"idx,symbol"`
+	TemperatureRaw0 float64   `qdb:"temperature_raw_0"`
+	TemperatureRaw1 float64   `qdb:"temperature_raw_1"`
+}
+
+func (Hashboard) TableName() string { return TableHashboards }
+
+// Shelly is a shellies row: a smart plug's meter. Only the power is always
+// reported.
+type Shelly struct {
+	Timestamp   time.Time `qdb:"timestamp"`
+	DeviceID    string    `qdb:"device_id,symbol"`
+	Power       *float64  `qdb:"power"`
+	Voltage     *float64  `qdb:"voltage,optional"`
+	Current     *float64  `qdb:"current,optional"`
+	Output      *bool     `qdb:"output,optional"`
+	Temperature *float64  `qdb:"temperature,optional"`
+}
+
+func (Shelly) TableName() string { return TableShellies }
+
+// BME280Reading is a bme280_readings row from a room or intake sensor.
+type BME280Reading struct {
+	Timestamp   time.Time `qdb:"timestamp"`
+	DeviceID    string    `qdb:"device_id,symbol"`
+	Location    string    `qdb:"location,symbol"`
+	Temperature *float64  `qdb:"temperature"`
+	Humidity    *float64  `qdb:"humidity"`
+	Pressure    *float64  `qdb:"pressure"`
+}
+
+func (BME280Reading) TableName() string { return TableBME280Readings }
+
+// MinerStatus is a miner_status row: a miner's state and totals.
+type MinerStatus struct {
+	Timestamp      time.Time `qdb:"timestamp"`
+	MinerIP        string    `qdb:"miner_ip,symbol"`
+	Status         string    `qdb:"status,symbol"`
+	WorkMode       string    `qdb:"work_mode,symbol"`
+	Hashrate       float64   `qdb:"hashrate"`
+	Power          float64   `qdb:"power"`
+	Efficiency     float64   `qdb:"efficiency"`
+	TemperatureMax float64   `qdb:"temperature_max"`
+}
+
+func (MinerStatus) TableName() string { return TableMinerStatus }
+
+// Rows lists an empty row of each table.
+var Rows = []Row{Pool{}, Hashboard{}, Shelly{}, BME280Reading{}, MinerStatus{}}
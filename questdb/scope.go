@@ -0,0 +1,92 @@
+package questdb
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Scope limits a client to the rows of some machines: miner tables are
+// filtered by miner IP, outlet tables by device ID and every other table
+// reads as empty.
+type Scope struct {
+	MinerIPs  []string
+	DeviceIDs []string
+}
+
+// scopeColumns maps the tables a scoped client may read to the column holding
+// the miner IP or device ID of a row.
+var scopeColumns = map[string]string{
+	"pools":               "miner_ip",
+	"pools_1h":            "miner_ip",
+	"pools_1d":            "miner_ip",
+	"hashboards":          "miner_ip",
+	"hashboards_detailed": "miner_ip",
+	"miner_status":        "miner_ip",
+	"miner_pools":         "miner_ip",
+	"gpu_status":          "rig_ip",
+	"shellies":            "device_id",
+	"shellies_1h":         "device_id",
+	"shellies_1d":         "device_id",
+}
+
+// ErrScoped is returned for statements a scoped client may not run.
+var ErrScoped = errors.New("statement not allowed for a scoped client")
+
+// scopeUnsafe matches the constructs scopeQuery cannot bound: joins, table
+// lists and set operations read tables without a FROM of their own, and
+// quoted names escape asOfTable.
+var scopeUnsafe = regexp.MustCompile(`(?i)\b(JOIN|UNION|EXCEPT|INTERSECT)\b|FROM\s+"|FROM\s+[a-z_0-9]+\s*,`)
+
+// anyCaseFrom and upperFrom match the FROM keyword; asOfTable only matches
+// the upper case spelling the queries use.
+var (
+	anyCaseFrom = regexp.MustCompile(`(?i)\bFROM\b`)
+	upperFrom   = regexp.MustCompile(`\bFROM\b`)
+)
+
+// WithScope returns a copy of the client that only reads the rows of s. A nil
+// scope reads everything.
+func (c *Client) WithScope(s *Scope) *Client {
+	c2 := *c
+	c2.scope = s
+	return &c2
+}
+
+// Scoped reports whether the client is limited by a scope.
+func (c *Client) Scoped() bool {
+	return c.scope != nil
+}
+
+// scopeQuery rewrites query to read only the rows of s. Only plain SELECT
+// statements are accepted.
+func scopeQuery(query string, s *Scope) (string, error) {
+	if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(query)), "SELECT") || scopeUnsafe.MatchString(query) ||
+		len(anyCaseFrom.FindAllStringIndex(query, -1)) != len(upperFrom.FindAllStringIndex(query, -1)) {
+		return "", ErrScoped
+	}
+
+	var bindErr error
+	scoped := boundTableReads(query, func(table string) string {
+		var values []string
+		switch column := scopeColumns[table]; column {
+		case "miner_ip", "rig_ip":
+			values = s.MinerIPs
+		case "device_id":
+			values = s.DeviceIDs
+		}
+		if len(values) == 0 {
+			return "1 = 0"
+		}
+		cond, err := Bind("? IN (?)", Ident(scopeColumns[table]), values)
+		if err != nil {
+			bindErr = err
+			return "1 = 0"
+		}
+		return cond
+	})
+	if bindErr != nil {
+		return "", bindErr
+	}
+	return scoped, nil
+}
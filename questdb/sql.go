@@ -0,0 +1,119 @@
+package questdb
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The QuestDB REST API has no bind parameters, so values are bound into the
+// query text client-side by Bind. Queries build their SQL from constant
+// templates with ? placeholders; never concatenate request input into the
+// template itself.
+
+// Ident is a table or column name bound into a query. Names are emitted
+// unquoted, like the tables Telegraf creates and asOfQuery expects, so only
+// plain lowercase names are accepted.
+type Ident string
+
+// identPattern matches the names an Ident may hold.
+var identPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// Bind replaces each ? placeholder in query, outside string literals and
+// quoted identifiers, with the SQL literal of the matching argument:
+//
+//	string          quoted string literal
+//	[]string        comma-separated string literals, for IN (?); not empty
+//	Ident           validated, unquoted identifier
+//	int, int64      integer
+//	float64         number; NaN and infinities are rejected
+//	bool            true or false
+//	time.Time       UTC timestamp literal
+//
+// The number of placeholders must match the number of arguments.
+func Bind(query string, args ...interface{}) (string, error) {
+	var b strings.Builder
+	n := 0
+	var quote byte // ' or " while inside a literal
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '?':
+			if n >= len(args) {
+				return "", fmt.Errorf("query has more placeholders than the %d arguments", len(args))
+			}
+			lit, err := sqlLiteral(args[n])
+			if err != nil {
+				return "", fmt.Errorf("argument %d: %w", n+1, err)
+			}
+			b.WriteString(lit)
+			n++
+			continue
+		}
+		b.WriteByte(ch)
+	}
+	if quote != 0 {
+		return "", fmt.Errorf("unterminated %c in query", quote)
+	}
+	if n != len(args) {
+		return "", fmt.Errorf("query has %d placeholders for %d arguments", n, len(args))
+	}
+	return b.String(), nil
+}
+
+// sqlLiteral renders a Bind argument.
+func sqlLiteral(arg interface{}) (string, error) {
+	switch v := arg.(type) {
+	case string:
+		return quoteString(v)
+	case []string:
+		if len(v) == 0 {
+			return "", fmt.Errorf("empty list")
+		}
+		quoted := make([]string, len(v))
+		for i, s := range v {
+			q, err := quoteString(s)
+			if err != nil {
+				return "", err
+			}
+			quoted[i] = q
+		}
+		return strings.Join(quoted, ", "), nil
+	case Ident:
+		if !identPattern.MatchString(string(v)) {
+			return "", fmt.Errorf("invalid identifier %q", string(v))
+		}
+		return string(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("invalid number %v", v)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		return formatTimestamp(v), nil
+	}
+	return "", fmt.Errorf("unsupported argument type %T", arg)
+}
+
+// quoteString returns s as a string literal, doubling single quotes.
+func quoteString(s string) (string, error) {
+	if strings.IndexByte(s, 0) >= 0 {
+		return "", fmt.Errorf("string contains a NUL byte")
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'", nil
+}
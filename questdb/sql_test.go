@@ -0,0 +1,72 @@
+package questdb
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBind(t *testing.T) {
+	ts := time.Date(2026, 3, 1, 12, 30, 0, 500000000, time.FixedZone("UTC+2", 2*3600))
+	tests := []struct {
+		name    string
+		query   string
+		args    []interface{}
+		want    string
+		wantErr string
+	}{
+		{"no placeholders", "SELECT 1;", nil, "SELECT 1;", ""},
+		{"string", "WHERE ip = ?", []interface{}{"10.0.0.1"}, "WHERE ip = '10.0.0.1'", ""},
+		{"quote escaping", "WHERE name = ?", []interface{}{"O'Brien's rig"}, "WHERE name = 'O''Brien''s rig'", ""},
+		{"injection stays inside the literal", "WHERE ip = ?", []interface{}{"x' OR '1'='1"}, "WHERE ip = 'x'' OR ''1''=''1'", ""},
+		{"list", "WHERE ip IN (?)", []interface{}{[]string{"a", "b'c"}}, "WHERE ip IN ('a', 'b''c')", ""},
+		{"numbers and bool", "LIMIT ? OFFSET ? WHERE v > ? AND ok = ?", []interface{}{10, int64(20), 1.5, true},
+			"LIMIT 10 OFFSET 20 WHERE v > 1.5 AND ok = true", ""},
+		{"timestamp in UTC", "WHERE timestamp >= ?", []interface{}{ts}, "WHERE timestamp >= '2026-03-01T10:30:00.500000Z'", ""},
+		{"identifier", "SELECT ? FROM ?", []interface{}{Ident("power"), Ident("shellies_1h")}, "SELECT power FROM shellies_1h", ""},
+		{"placeholder inside a string literal", "WHERE note = '?' AND ip = ?", []interface{}{"a"}, "WHERE note = '?' AND ip = 'a'", ""},
+		{"placeholder inside a quoted identifier", `SELECT "what?" FROM t WHERE ip = ?`, []interface{}{"a"}, `SELECT "what?" FROM t WHERE ip = 'a'`, ""},
+		{"escaped quote in a literal", "WHERE note = 'it''s ?' AND ip = ?", []interface{}{"a"}, "WHERE note = 'it''s ?' AND ip = 'a'", ""},
+		{"too many placeholders", "WHERE a = ? AND b = ?", []interface{}{"x"}, "", "more placeholders"},
+		{"too few placeholders", "WHERE a = ?", []interface{}{"x", "y"}, "", "1 placeholders for 2 arguments"},
+		{"placeholder only in a literal", "WHERE a = '?'", []interface{}{"x"}, "", "0 placeholders for 1 arguments"},
+		{"unterminated literal", "WHERE a = 'x AND b = ?", []interface{}{"y"}, "", "unterminated"},
+		{"empty list", "WHERE ip IN (?)", []interface{}{[]string{}}, "", "empty list"},
+		{"NUL byte", "WHERE a = ?", []interface{}{"a\x00b"}, "", "NUL"},
+		{"NaN", "WHERE v > ?", []interface{}{math.NaN()}, "", "invalid number"},
+		{"infinity", "WHERE v > ?", []interface{}{math.Inf(1)}, "", "invalid number"},
+		{"unsupported type", "WHERE v > ?", []interface{}{float32(1)}, "", "unsupported argument type"},
+		{"invalid identifier", "SELECT * FROM ?", []interface{}{Ident("t; DROP TABLE x")}, "", "invalid identifier"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Bind(tt.query, tt.args...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Bind error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Bind = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIdentValidation(t *testing.T) {
+	for _, name := range []string{"power", "shellies_1h", "_tmp", "temperature_raw_0"} {
+		if _, err := sqlLiteral(Ident(name)); err != nil {
+			t.Errorf("Ident(%q) rejected: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "Power", "1table", "power-w", "power w", `"power"`, "power;", "t.col", "naïve"} {
+		if _, err := sqlLiteral(Ident(name)); err == nil {
+			t.Errorf("Ident(%q) accepted", name)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package questdb
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// thermalBucket is the sampling interval of the thermal insulation data.
+	thermalBucket = 10 * time.Minute
+	// thermalSettle is how long power and room temperature must have been
+	// steady before a sample counts: after a change, the heat stored in the
+	// room and hardware distorts the power/ΔT balance.
+	thermalSettle = time.Hour
+	// thermalPowerTolerance is the relative power change within thermalSettle
+	// still considered steady.
+	thermalPowerTolerance = 0.1
+	// thermalTempDrift is the room temperature change (K) within
+	// thermalSettle still considered steady.
+	thermalTempDrift = 0.5
+	// thermalMinPower and thermalMinDeltaT drop samples where the heat flow
+	// is too small to measure.
+	thermalMinPower  = 100.0
+	thermalMinDeltaT = 1.0
+	// thermalRollingWindow is the trailing window of the rolling UA estimate,
+	// and thermalMinSamples the steady samples a regression needs.
+	thermalRollingWindow = 24 * time.Hour
+	thermalMinSamples    = 12
+)
+
+// ThermalDataPoint represents a single thermal insulation calculation point
+type ThermalDataPoint struct {
+	Timestamp          string  `json:"timestamp"`
+	Power              float64 `json:"power"`
+	InsideTemp         float64 `json:"insideTemp"`
+	OutsideTemp        float64 `json:"outsideTemp"`
+	DeltaT             float64 `json:"deltaT"`
+	ThermalConductance float64 `json:"thermalConductance"` // W/K - lower is better insulation
+}
+
+// UAEstimate is the room's heat loss coefficient from a linear regression of
+// power against ΔT: power = UA x ΔT + Intercept. Lower and Upper are the 95%
+// confidence bounds of UA. Consecutive samples are correlated, so the bounds
+// are on the optimistic side.
+type UAEstimate struct {
+	UA        float64 `json:"ua"` // W/K - lower is better insulation
+	Lower     float64 `json:"lower"`
+	Upper     float64 `json:"upper"`
+	Intercept float64 `json:"intercept"` // W not explained by ΔT (solar gain, unmetered loads)
+	R2        float64 `json:"r2"`
+	Samples   int     `json:"samples"`
+}
+
+// RollingUA is the UA estimate over the thermalRollingWindow ending at
+// Timestamp.
+type RollingUA struct {
+	Timestamp string `json:"timestamp"`
+	UAEstimate
+}
+
+// ThermalInsulationData holds the steady-state samples, the regression over
+// all of them and the rolling estimate.
+type ThermalInsulationData struct {
+	DataPoints        []ThermalDataPoint `json:"dataPoints"`
+	Regression        *UAEstimate        `json:"regression"` // nil with too few samples or no ΔT spread
+	Rolling           []RollingUA        `json:"rolling"`
+	ExcludedDoorOpen  int                `json:"excludedDoorOpen"`  // samples dropped because a door/window was open
+	ExcludedTransient int                `json:"excludedTransient"` // samples dropped as not steady
+	HasData           bool               `json:"hasData"`
+}
+
+// thermalSample is one 10 minute bucket of total power and temperatures.
+type thermalSample struct {
+	ts                 string
+	t                  time.Time
+	power              float64
+	inside, outside    float64
+	hasInside, hasTemp bool
+}
+
+// GetThermalInsulationData queries QuestDB for power and temperature data of
+// the last 7 days and estimates the room's thermal conductance. Inside and
+// outside temperatures are averaged over the given indoor and outdoor
+// locations. Samples overlapping a door/window open period, and samples not
+// in steady state (see thermalSettle), are excluded.
+func (c *Client) GetThermalInsulationData(indoor, outdoor []string) (*ThermalInsulationData, error) {
+	if len(indoor) == 0 || len(outdoor) == 0 {
+		return &ThermalInsulationData{HasData: false}, nil
+	}
+
+	power, err := c.totalPowerSeries("timestamp > dateadd('d', -7, now())")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query power data: %w", err)
+	}
+
+	// Query inside and outside temperature
+	const tempQuery = `SELECT timestamp, avg(temperature) as temp FROM bme280_readings WHERE timestamp > dateadd('d', -7, now()) AND location IN (?) SAMPLE BY 10m ALIGN TO CALENDAR;`
+
+	insideResult, err := c.Query(tempQuery, indoor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query inside temperature: %w", err)
+	}
+
+	outsideResult, err := c.Query(tempQuery, outdoor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outside temperature: %w", err)
+	}
+
+	openIntervals, err := c.GetContactOpenIntervals(time.Now().AddDate(0, 0, -7).Add(-time.Hour))
+	if err != nil {
+		return nil, err
+	}
+
+	insideMap := temperatureBuckets(insideResult)
+	outsideMap := temperatureBuckets(outsideResult)
+
+	// power is sorted by timestamp
+	samples := make([]thermalSample, 0, len(power))
+	for _, p := range power {
+		t, err := time.Parse(time.RFC3339Nano, p.Timestamp)
+		if err != nil {
+			continue
+		}
+		s := thermalSample{ts: p.Timestamp, t: t, power: p.Value}
+		s.inside, s.hasInside = insideMap[p.Timestamp]
+		var hasOutside bool
+		s.outside, hasOutside = outsideMap[p.Timestamp]
+		s.hasTemp = s.hasInside && hasOutside
+		samples = append(samples, s)
+	}
+
+	data := &ThermalInsulationData{}
+	var xs, ys []float64
+	for i, s := range samples {
+		if !s.hasTemp || s.power <= thermalMinPower || s.inside-s.outside <= thermalMinDeltaT {
+			continue
+		}
+		if doorOpenDuring(openIntervals, s.t, s.t.Add(thermalBucket)) {
+			data.ExcludedDoorOpen++
+			continue
+		}
+		if !steadyState(samples, i) {
+			data.ExcludedTransient++
+			continue
+		}
+
+		deltaT := s.inside - s.outside
+		data.DataPoints = append(data.DataPoints, ThermalDataPoint{
+			Timestamp:          s.ts,
+			Power:              s.power,
+			InsideTemp:         s.inside,
+			OutsideTemp:        s.outside,
+			DeltaT:             deltaT,
+			ThermalConductance: s.power / deltaT,
+		})
+		xs = append(xs, deltaT)
+		ys = append(ys, s.power)
+	}
+
+	data.Regression = regressUA(newRegressionSums(xs, ys))
+	data.Rolling = rollingUA(data.DataPoints)
+	data.HasData = len(data.DataPoints) > 0
+	return data, nil
+}
+
+// temperatureBuckets maps the timestamps of a SAMPLE BY query to its temp
+// values, leaving out empty buckets.
+func temperatureBuckets(result *QueryResult) map[string]float64 {
+	var rows []struct {
+		Timestamp string   `qdb:"timestamp"`
+		Temp      *float64 `qdb:"temp"`
+	}
+	result.Scan(&rows)
+	m := make(map[string]float64, len(rows))
+	for _, r := range rows {
+		if r.Temp != nil {
+			m[r.Timestamp] = *r.Temp
+		}
+	}
+	return m
+}
+
+// steadyState reports whether every bucket of the thermalSettle before
+// samples[i] exists, with power within thermalPowerTolerance of the sample's
+// and the room temperature within thermalTempDrift of it.
+func steadyState(samples []thermalSample, i int) bool {
+	s := samples[i]
+	want := int(thermalSettle / thermalBucket)
+	if i < want {
+		return false
+	}
+	for j := i - want; j < i; j++ {
+		p := samples[j]
+		if s.t.Sub(p.t) > thermalSettle || math.Abs(p.power-s.power) > thermalPowerTolerance*s.power {
+			return false
+		}
+		if !p.hasInside || math.Abs(p.inside-s.inside) > thermalTempDrift {
+			return false
+		}
+	}
+	return true
+}
+
+// regressionSums are the running sums of a simple linear regression.
+type regressionSums struct {
+	n                     int
+	sx, sy, sxx, sxy, syy float64
+}
+
+func newRegressionSums(xs, ys []float64) regressionSums {
+	var r regressionSums
+	for i := range xs {
+		r.add(xs[i], ys[i], 1)
+	}
+	return r
+}
+
+// add adds (sign 1) or removes (sign -1) a point.
+func (r *regressionSums) add(x, y float64, sign int) {
+	f := float64(sign)
+	r.n += sign
+	r.sx += f * x
+	r.sy += f * y
+	r.sxx += f * x * x
+	r.sxy += f * x * y
+	r.syy += f * y * y
+}
+
+// regressUA fits power = UA x ΔT + intercept, or returns nil with fewer than
+// thermalMinSamples points or no spread in ΔT.
+func regressUA(r regressionSums) *UAEstimate {
+	if r.n < thermalMinSamples {
+		return nil
+	}
+	n := float64(r.n)
+	sxx := r.sxx - r.sx*r.sx/n
+	sxy := r.sxy - r.sx*r.sy/n
+	syy := r.syy - r.sy*r.sy/n
+	if sxx <= 1e-9 {
+		return nil
+	}
+
+	slope := sxy / sxx
+	residual := math.Max(0, syy-slope*sxy)
+	se := math.Sqrt(residual / (n - 2) / sxx)
+	margin := tQuantile975(r.n-2) * se
+
+	est := &UAEstimate{
+		UA:        round1(slope),
+		Lower:     round1(slope - margin),
+		Upper:     round1(slope + margin),
+		Intercept: round1((r.sy - slope*r.sx) / n),
+		Samples:   r.n,
+	}
+	if syy > 0 {
+		est.R2 = math.Round(sxy*sxy/(sxx*syy)*1000) / 1000
+	}
+	return est
+}
+
+// rollingUA estimates UA over the thermalRollingWindow ending at each steady
+// sample, updating the regression sums as the window slides.
+func rollingUA(points []ThermalDataPoint) []RollingUA {
+	rolling := []RollingUA{}
+	var sums regressionSums
+	times := make([]time.Time, len(points))
+	start := 0
+	for i, p := range points {
+		times[i], _ = time.Parse(time.RFC3339Nano, p.Timestamp)
+		sums.add(p.DeltaT, p.Power, 1)
+		for times[i].Sub(times[start]) >= thermalRollingWindow {
+			sums.add(points[start].DeltaT, points[start].Power, -1)
+			start++
+		}
+		if est := regressUA(sums); est != nil {
+			rolling = append(rolling, RollingUA{Timestamp: p.Timestamp, UAEstimate: *est})
+		}
+	}
+	return rolling
+}
+
+// tQuantile975 returns the two-sided 95% quantile of Student's t distribution
+// for df degrees of freedom, from the next smaller tabulated df.
+func tQuantile975(df int) float64 {
+	table := []struct {
+		df int
+		t  float64
+	}{
+		{1, 12.706}, {2, 4.303}, {3, 3.182}, {4, 2.776}, {5, 2.571},
+		{6, 2.447}, {7, 2.365}, {8, 2.306}, {9, 2.262}, {10, 2.228},
+		{12, 2.179}, {15, 2.131}, {20, 2.086}, {30, 2.042}, {60, 2.000}, {120, 1.980},
+	}
+	if df >= 1000 {
+		return 1.960
+	}
+	q := table[0].t
+	for _, e := range table {
+		if e.df > df {
+			break
+		}
+		q = e.t
+	}
+	return q
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+// doorOpenDuring reports whether any open interval overlaps [from, to).
+func doorOpenDuring(intervals []OpenInterval, from, to time.Time) bool {
+	for _, o := range intervals {
+		if o.overlaps(from, to) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package questdb
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ThermalFrame is the latest frame of a thermal array camera such as the
+// MLX90640 (32x24). Cells are °C, row by row from the top left.
+type ThermalFrame struct {
+	Timestamp time.Time `json:"time" qdb:"timestamp"`
+	Camera    string    `json:"camera" qdb:"camera"`
+	Location  string    `json:"location" qdb:"location"`
+	Width     int       `json:"width" qdb:"width"`
+	Height    int       `json:"height" qdb:"height"`
+	Min       float64   `json:"min" qdb:"min"`
+	Max       float64   `json:"max" qdb:"max"`
+	Mean      float64   `json:"mean" qdb:"mean"`
+	MaxX      int       `json:"maxX" qdb:"max_x"` // column of the hottest cell
+	MaxY      int       `json:"maxY" qdb:"max_y"` // row of the hottest cell
+	Frame     string    `json:"-" qdb:"frame"`
+}
+
+// EncodeThermalFrame stores cells as comma-separated values with 0.1 °C
+// resolution, the sensor's accuracy being well below that.
+func EncodeThermalFrame(cells []float64) string {
+	parts := make([]string, len(cells))
+	for i, v := range cells {
+		parts[i] = strconv.FormatFloat(v, 'f', 1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Cells decodes the frame into rows of Width cells.
+func (f ThermalFrame) Cells() ([][]float64, error) {
+	parts := strings.Split(f.Frame, ",")
+	if len(parts) != f.Width*f.Height {
+		return nil, fmt.Errorf("frame of %s has %d cells, expected %dx%d", f.Camera, len(parts), f.Width, f.Height)
+	}
+	rows := make([][]float64, f.Height)
+	for y := range rows {
+		rows[y] = make([]float64, f.Width)
+		for x := range rows[y] {
+			v, err := strconv.ParseFloat(parts[y*f.Width+x], 64)
+			if err != nil {
+				return nil, fmt.Errorf("frame of %s: %w", f.Camera, err)
+			}
+			rows[y][x] = v
+		}
+	}
+	return rows, nil
+}
+
+// GetLatestThermalFrames returns the latest frame of every camera that sent
+// one in the last hour, by camera name.
+func (c *Client) GetLatestThermalFrames() ([]ThermalFrame, error) {
+	const query = `SELECT timestamp, camera, location, width, height, min, max, mean, max_x, max_y, frame
+  FROM thermal_frames WHERE timestamp > dateadd('h', -1, now()) LATEST ON timestamp PARTITION BY camera;`
+	result, err := c.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query thermal frames: %w", err)
+	}
+	var frames []ThermalFrame
+	if err := result.Scan(&frames); err != nil {
+		return nil, fmt.Errorf("failed to parse thermal frames: %w", err)
+	}
+	sort.Slice(frames, func(i, j int) bool {
+		return frames[i].Camera < frames[j].Camera
+	})
+	return frames, nil
+}
@@ -0,0 +1,31 @@
+package questdb
+
+import "time"
+
+// WithLocation returns a copy of the client whose calendar days (today's
+// charts, daily energy and mean temperatures) and hours of day are those of
+// loc instead of UTC.
+func (c *Client) WithLocation(loc *time.Location) *Client {
+	c2 := *c
+	c2.location = loc
+	return &c2
+}
+
+func (c *Client) loc() *time.Location {
+	if c.location == nil {
+		return time.UTC
+	}
+	return c.location
+}
+
+// today returns the bounds of the current calendar day, or of the replay
+// time's day.
+func (c *Client) today() (time.Time, time.Time) {
+	now := time.Now()
+	if !c.asOf.IsZero() {
+		now = c.asOf
+	}
+	now = now.In(c.loc())
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return start, start.AddDate(0, 0, 1)
+}
@@ -0,0 +1,171 @@
+package questdb
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Error classes of traced queries.
+const (
+	ErrorBind     = "bind"     // an argument has no SQL literal
+	ErrorScope    = "scope"    // statement not allowed for a scoped client
+	ErrorTimeout  = "timeout"  // deadline or HTTP client timeout
+	ErrorCanceled = "canceled" // the caller went away
+	ErrorNetwork  = "network"  // QuestDB unreachable
+	ErrorSQL      = "sql"      // rejected by QuestDB (status 400)
+	ErrorServer   = "server"   // any other non-200 status
+	ErrorDecode   = "decode"   // unreadable response
+)
+
+// traceMaxQueries bounds the statements a Tracer keeps statistics of;
+// statements seen after that are only counted in Untracked.
+const traceMaxQueries = 1000
+
+// QueryStat summarizes the runs of one statement. Statements are told apart
+// by their text before binding, so runs of a query with different arguments
+// add up.
+type QueryStat struct {
+	Query      string         `json:"query"`
+	Calls      int            `json:"calls"`
+	Errors     int            `json:"errors"`
+	ErrorKinds map[string]int `json:"errorKinds,omitempty"`
+	LastError  string         `json:"lastError,omitempty"`
+	Rows       int            `json:"rows"` // returned by all calls
+	TotalMs    float64        `json:"totalMs"`
+	AvgMs      float64        `json:"avgMs"`
+	MaxMs      float64        `json:"maxMs"`
+	SlowestSQL string         `json:"slowestSql"` // as sent, of the slowest call
+	SlowestAt  time.Time      `json:"slowestAt"`
+	LastAt     time.Time      `json:"lastAt"`
+}
+
+// Tracer times the queries of a client and its copies, keeping per-statement
+// statistics and logging queries slower than SlowQuery.
+type Tracer struct {
+	SlowQuery time.Duration // 0 logs no slow queries
+
+	mu        sync.Mutex
+	stats     map[string]*QueryStat
+	since     time.Time
+	untracked int
+}
+
+func NewTracer(slowQuery time.Duration) *Tracer {
+	return &Tracer{SlowQuery: slowQuery, stats: make(map[string]*QueryStat), since: time.Now()}
+}
+
+// WithTracer returns a copy of the client whose queries are recorded by t.
+// Clients derived from the copy share t.
+func (c *Client) WithTracer(t *Tracer) *Client {
+	c2 := *c
+	c2.tracer = t
+	return &c2
+}
+
+// requestErrorKind classifies an error of the HTTP round trip.
+func requestErrorKind(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorCanceled
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	default:
+		return ErrorNetwork
+	}
+}
+
+func (t *Tracer) record(query, executed string, d time.Duration, rows int, kind string, err error) {
+	ms := float64(d.Microseconds()) / 1000
+	now := time.Now()
+
+	if t.SlowQuery > 0 && d >= t.SlowQuery {
+		if err != nil {
+			log.Printf("QuestDB slow query (%.0f ms, %s error): %s", ms, kind, executed)
+		} else {
+			log.Printf("QuestDB slow query (%.0f ms, %d rows): %s", ms, rows, executed)
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.stats[query]
+	if !ok {
+		if len(t.stats) >= traceMaxQueries {
+			t.untracked++
+			return
+		}
+		s = &QueryStat{Query: query}
+		t.stats[query] = s
+	}
+	s.Calls++
+	s.Rows += rows
+	s.TotalMs += ms
+	s.AvgMs = s.TotalMs / float64(s.Calls)
+	s.LastAt = now
+	if ms >= s.MaxMs {
+		s.MaxMs, s.SlowestSQL, s.SlowestAt = ms, executed, now
+	}
+	if err != nil {
+		s.Errors++
+		if s.ErrorKinds == nil {
+			s.ErrorKinds = make(map[string]int)
+		}
+		s.ErrorKinds[kind]++
+		s.LastError = err.Error()
+	}
+}
+
+// QueryStats is a snapshot of a Tracer's statistics.
+type QueryStats struct {
+	Since     time.Time   `json:"since"`
+	Queries   []QueryStat `json:"queries"`
+	Untracked int         `json:"untracked"` // calls of statements beyond the tracked limit
+}
+
+// Stats returns the statistics of every statement, slowest first by the
+// given order: "max" (slowest call), "avg" or "total" time.
+func (t *Tracer) Stats(order string) QueryStats {
+	t.mu.Lock()
+	stats := QueryStats{Since: t.since, Queries: make([]QueryStat, 0, len(t.stats)), Untracked: t.untracked}
+	for _, s := range t.stats {
+		copied := *s
+		if s.ErrorKinds != nil {
+			copied.ErrorKinds = make(map[string]int, len(s.ErrorKinds))
+			for k, v := range s.ErrorKinds {
+				copied.ErrorKinds[k] = v
+			}
+		}
+		stats.Queries = append(stats.Queries, copied)
+	}
+	t.mu.Unlock()
+
+	key := func(s QueryStat) float64 {
+		switch order {
+		case "avg":
+			return s.AvgMs
+		case "total":
+			return s.TotalMs
+		default:
+			return s.MaxMs
+		}
+	}
+	sort.Slice(stats.Queries, func(i, j int) bool {
+		return key(stats.Queries[i]) > key(stats.Queries[j])
+	})
+	return stats
+}
+
+// Reset discards the statistics, e.g. after changing the partitioning.
+func (t *Tracer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats = make(map[string]*QueryStat)
+	t.since = time.Now()
+	t.untracked = 0
+}
@@ -0,0 +1,33 @@
+package questdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// IntervalEnergy is the energy measured in one 15 minute interval, the
+// resolution of utility smart meters.
+type IntervalEnergy struct {
+	Start time.Time `json:"start"`
+	KWh   float64   `json:"kwh"`
+}
+
+// GetIntervalEnergy returns the measured energy per 15 minute interval between
+// from and to, oldest first, from the room meter where it has data and the sum
+// of the plugs elsewhere. Intervals without readings are missing.
+func (c *Client) GetIntervalEnergy(from, to time.Time) ([]IntervalEnergy, error) {
+	points, err := c.totalPowerSeriesBy("15m", "timestamp >= ? AND timestamp < ?", from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query interval energy: %w", err)
+	}
+
+	intervals := make([]IntervalEnergy, 0, len(points))
+	for _, p := range points {
+		start, err := time.Parse(time.RFC3339Nano, p.Timestamp)
+		if err != nil {
+			continue
+		}
+		intervals = append(intervals, IntervalEnergy{Start: start, KWh: p.Value * 0.25 / 1000})
+	}
+	return intervals, nil
+}
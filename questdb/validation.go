@@ -0,0 +1,66 @@
+package questdb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ValidRange bounds the values of a column; rows with a value outside it are
+// glitches (a hashboard sensor reading 0 or 255 °C, a plug reporting a power
+// spike) and are left out of every read. A nil bound is open.
+type ValidRange struct {
+	Table  string   `json:"table"`
+	Column string   `json:"column"`
+	Min    *float64 `json:"min,omitempty"`
+	Max    *float64 `json:"max,omitempty"`
+}
+
+// String renders the range as min:max, leaving an open bound empty.
+func (r ValidRange) String() string {
+	var min, max string
+	if r.Min != nil {
+		min = strconv.FormatFloat(*r.Min, 'g', -1, 64)
+	}
+	if r.Max != nil {
+		max = strconv.FormatFloat(*r.Max, 'g', -1, 64)
+	}
+	return min + ":" + max
+}
+
+// WithValidRanges returns a copy of the client that only reads rows whose
+// values lie within ranges. Nil reads every row, so checks looking for broken
+// sensors can still see their readings.
+func (c *Client) WithValidRanges(ranges []ValidRange) *Client {
+	c2 := *c
+	c2.validRanges = ranges
+	return &c2
+}
+
+// validCondition is the condition a row of table must meet under ranges, or
+// "" when no range applies to the table. Missing values are kept.
+func validCondition(table string, ranges []ValidRange) string {
+	var conds []string
+	for _, r := range ranges {
+		if r.Table != table || (r.Min == nil && r.Max == nil) {
+			continue
+		}
+		var bounds []string
+		if r.Min != nil {
+			bounds = append(bounds, fmt.Sprintf("%s >= %s", r.Column, strconv.FormatFloat(*r.Min, 'g', -1, 64)))
+		}
+		if r.Max != nil {
+			bounds = append(bounds, fmt.Sprintf("%s <= %s", r.Column, strconv.FormatFloat(*r.Max, 'g', -1, 64)))
+		}
+		conds = append(conds, fmt.Sprintf("(%s IS NULL OR %s)", r.Column, strings.Join(bounds, " AND ")))
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// validQuery rewrites query to skip the rows outside ranges in every table
+// read, including the reads that fill the rollup tables.
+func validQuery(query string, ranges []ValidRange) string {
+	return boundTableReads(query, func(table string) string {
+		return validCondition(table, ranges)
+	})
+}
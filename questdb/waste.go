@@ -0,0 +1,63 @@
+package questdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// WasteBucket is the size of the buckets idle power is analyzed in.
+const WasteBucket = 5 * time.Minute
+
+// Buckets maps a key (device ID or miner IP) to its average value per bucket
+// start timestamp.
+type Buckets map[string]map[string]float64
+
+// GetDevicePowerBuckets returns the average power of each shellies device per
+// WasteBucket between from and to.
+func (c *Client) GetDevicePowerBuckets(from, to time.Time) (Buckets, error) {
+	const query = `SELECT timestamp, device_id AS key, avg(power) AS value FROM shellies WHERE timestamp >= ? AND timestamp < ? SAMPLE BY 5m ALIGN TO CALENDAR;`
+	buckets, err := c.queryBuckets(query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query device power buckets: %w", err)
+	}
+	return buckets, nil
+}
+
+// GetMinerHashrateBuckets returns the highest pool hashrate (GH/s) reported
+// by each miner per WasteBucket between from and to. Buckets without pool data
+// are missing.
+func (c *Client) GetMinerHashrateBuckets(from, to time.Time) (Buckets, error) {
+	const query = `SELECT timestamp, miner_ip AS key, max(hashrate_average) AS value FROM pools WHERE timestamp >= ? AND timestamp < ? SAMPLE BY 5m ALIGN TO CALENDAR;`
+	buckets, err := c.queryBuckets(query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query miner hashrate buckets: %w", err)
+	}
+	return buckets, nil
+}
+
+// bucketRow is a row of a [timestamp, key, value] query.
+type bucketRow struct {
+	Timestamp string  `qdb:"timestamp"`
+	Key       string  `qdb:"key"`
+	Value     float64 `qdb:"value"`
+}
+
+// queryBuckets runs a [timestamp, key, value] query.
+func (c *Client) queryBuckets(query string, args ...interface{}) (Buckets, error) {
+	result, err := c.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var rows []bucketRow
+	if err := result.Scan(&rows); err != nil {
+		return nil, err
+	}
+	buckets := make(Buckets)
+	for _, r := range rows {
+		if buckets[r.Key] == nil {
+			buckets[r.Key] = make(map[string]float64)
+		}
+		buckets[r.Key][r.Timestamp] = r.Value
+	}
+	return buckets, nil
+}
@@ -0,0 +1,140 @@
+package questdb
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"miningRoom/questdb/schema"
+)
+
+// Point is one row written through InfluxDB line protocol (ILP). Field values
+// may be float64, int64, bool or string.
+type Point struct {
+	Table   string
+	Symbols map[string]string
+	Fields  map[string]interface{}
+	Time    time.Time // zero uses the server time
+}
+
+// PointOf returns the point writing a row of one of the schema tables.
+func PointOf(row schema.Row) (Point, error) {
+	rec, err := schema.Encode(row)
+	if err != nil {
+		return Point{}, err
+	}
+	return Point{Table: rec.Table, Symbols: rec.Symbols, Fields: rec.Fields, Time: rec.Time}, nil
+}
+
+var (
+	ilpNameEscaper  = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`, "\n", "")
+	ilpTableEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", "")
+	ilpStringEscape = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// line renders the point as a single ILP line.
+func (p Point) line() (string, error) {
+	if p.Table == "" || len(p.Fields) == 0 {
+		return "", fmt.Errorf("point needs a table and at least one field")
+	}
+
+	var b strings.Builder
+	b.WriteString(ilpTableEscaper.Replace(p.Table))
+	for _, k := range sortedKeys(p.Symbols) {
+		if p.Symbols[k] == "" {
+			continue
+		}
+		fmt.Fprintf(&b, ",%s=%s", ilpNameEscaper.Replace(k), ilpNameEscaper.Replace(p.Symbols[k]))
+	}
+
+	fields := make([]string, 0, len(p.Fields))
+	for k, v := range p.Fields {
+		var value string
+		switch v := v.(type) {
+		case float64:
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		case int64:
+			value = strconv.FormatInt(v, 10) + "i"
+		case int:
+			value = strconv.Itoa(v) + "i"
+		case bool:
+			value = strconv.FormatBool(v)
+		case string:
+			value = `"` + ilpStringEscape.Replace(v) + `"`
+		default:
+			return "", fmt.Errorf("unsupported type %T for field %s", v, k)
+		}
+		fields = append(fields, ilpNameEscaper.Replace(k)+"="+value)
+	}
+	sort.Strings(fields)
+	b.WriteString(" ")
+	b.WriteString(strings.Join(fields, ","))
+
+	if !p.Time.IsZero() {
+		fmt.Fprintf(&b, " %d", p.Time.UnixNano())
+	}
+	return b.String(), nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Lines renders points as ILP, one line each.
+func Lines(points []Point) (string, error) {
+	lines := make([]string, 0, len(points))
+	for _, p := range points {
+		line, err := p.line()
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Write sends points to QuestDB's ILP-over-HTTP endpoint. Tables and columns
+// are created on first write, as with Telegraf.
+func (c *Client) Write(points []Point) error {
+	lines, err := Lines(points)
+	if err != nil {
+		return err
+	}
+	return c.WriteLines(lines)
+}
+
+// WriteLines sends raw ILP lines to QuestDB.
+func (c *Client) WriteLines(lines string) error {
+	if c.scope != nil {
+		return ErrScoped
+	}
+	if strings.TrimSpace(lines) == "" {
+		return nil
+	}
+	endpoint := fmt.Sprintf("%s/write", c.baseURL)
+	req, err := http.NewRequestWithContext(c.context(), http.MethodPost, endpoint, strings.NewReader(lines+"\n"))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("write failed with status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
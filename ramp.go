@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	rampStepW       int // --ramp-step-w
+	rampStepSeconds int // --ramp-step-seconds
+)
+
+// PowerRamp moves a power target in steps instead of jumping, easing voltage
+// stress on the boards and letting the room temperature follow. Unset fields
+// default to --ramp-step-w and --ramp-step-seconds; a step of 0 jumps.
+type PowerRamp struct {
+	RampStepW       *int `json:"rampStepW,omitempty"`
+	RampStepSeconds *int `json:"rampStepSeconds,omitempty"`
+}
+
+// settings returns the step size and the delay between steps.
+func (r PowerRamp) settings() (int, time.Duration) {
+	step, seconds := rampStepW, rampStepSeconds
+	if r.RampStepW != nil {
+		step = *r.RampStepW
+	}
+	if r.RampStepSeconds != nil {
+		seconds = *r.RampStepSeconds
+	}
+	return step, time.Duration(seconds) * time.Second
+}
+
+func (r PowerRamp) validate() error {
+	if r.RampStepW != nil && *r.RampStepW < 0 {
+		return errors.New("rampStepW must not be negative")
+	}
+	if r.RampStepSeconds != nil && (*r.RampStepSeconds < 0 || *r.RampStepSeconds > 600) {
+		return errors.New("rampStepSeconds must be between 0 and 600")
+	}
+	return nil
+}
+
+// rampSteps returns the targets to set going from one power target to
+// another, at most step W apart, ending with to.
+func rampSteps(from, to, step int) []int {
+	var steps []int
+	for w := from; w != to; {
+		switch {
+		case to > w:
+			w = min(w+step, to)
+		default:
+			w = max(w-step, to)
+		}
+		steps = append(steps, w)
+	}
+	return steps
+}
+
+// rampPowerTarget moves a miner to a power target through the ramp steps,
+// starting from its current target. Miners not running on a power target
+// jump straight to it. An emergency stop or ctx being done ends the ramp.
+func rampPowerTarget(ctx context.Context, ip string, power int, r PowerRamp) error {
+	d := driverFor(ip)
+	step, delay := r.settings()
+	if step <= 0 {
+		return d.SetPowerTarget(ctx, ip, power)
+	}
+
+	cfg, err := d.Config(ctx, ip)
+	if err != nil || cfg.WorkMode != "Auto" || cfg.ModeSelect != "PowerTarget" || cfg.TargetValue <= 0 {
+		return d.SetPowerTarget(ctx, ip, power)
+	}
+
+	steps := rampSteps(int(cfg.TargetValue), power, step)
+	if len(steps) > 1 {
+		log.Printf("Ramping miner at %s from %d W to %d W in %d steps, %s apart", ip, int(cfg.TargetValue), power, len(steps), delay)
+	}
+	for i, w := range steps {
+		if i > 0 {
+			if err := sleepCtx(ctx, delay); err != nil {
+				return fmt.Errorf("ramp stopped at %d W: %w", steps[i-1], err)
+			}
+			if emergency.locked() {
+				return fmt.Errorf("emergency lockout active: ramp stopped at %d W", steps[i-1])
+			}
+		}
+		if err := d.SetPowerTarget(ctx, ip, w); err != nil {
+			return fmt.Errorf("ramp step to %d W: %w", w, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	rateLimitPerMinute  int    // --rate-limit; 0 disables
+	rateLimitBurst      int    // --rate-limit-burst
+	loginMaxFailures    int    // --login-max-failures
+	loginLockoutSeconds int    // --login-lockout-seconds, doubled per lockout
+	trustedProxies      string // --trusted-proxies
+)
+
+const (
+	maxLoginLockout    = 24 * time.Hour
+	loginFailureMemory = 24 * time.Hour // failures older than this are forgotten
+)
+
+// tokenBucket holds a client's request allowance: it refills at the rate limit
+// up to the burst size and each request takes a token.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+var apiLimiter = &rateLimiter{buckets: make(map[string]*tokenBucket)}
+
+// allow takes a token from ip's bucket. When it is empty, allow returns false
+// and the time until the next token.
+func (l *rateLimiter) allow(ip string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	perSecond := float64(rateLimitPerMinute) / 60
+	burst := float64(rateLimitBurst)
+	if now.Sub(l.lastPrune) > time.Minute {
+		// Buckets that refilled completely carry no state
+		for key, b := range l.buckets {
+			if b.tokens+now.Sub(b.last).Seconds()*perSecond >= burst {
+				delete(l.buckets, key)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	b, ok := l.buckets[ip]
+	if !ok {
+		b = &tokenBucket{tokens: burst, last: now}
+		l.buckets[ip] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// banList caches the ip_bans table.
+type banList struct {
+	mu   sync.RWMutex
+	bans map[string]db.IPBan
+}
+
+var bans = &banList{bans: make(map[string]db.IPBan)}
+
+func (b *banList) load() error {
+	stored, err := database.FetchIPBans()
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bans = make(map[string]db.IPBan, len(stored))
+	for _, ban := range stored {
+		b.bans[ban.IP] = ban
+	}
+	return nil
+}
+
+// active returns the ban in force on ip at t, if any.
+func (b *banList) active(ip string, t time.Time) (db.IPBan, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	ban, ok := b.bans[ip]
+	if !ok || !ban.Active(t) {
+		return db.IPBan{}, false
+	}
+	return ban, true
+}
+
+func (b *banList) list(t time.Time) []db.IPBan {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	list := []db.IPBan{}
+	for _, ban := range b.bans {
+		if ban.Active(t) {
+			list = append(list, ban)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+func (b *banList) add(ban db.IPBan) error {
+	if err := database.SaveIPBan(ban); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bans[ban.IP] = ban
+	return nil
+}
+
+func (b *banList) remove(ip string) (bool, error) {
+	found, err := database.DeleteIPBan(ip)
+	if err != nil {
+		return false, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.bans, ip)
+	return found, nil
+}
+
+// retryAfter sets the Retry-After header to d, rounded up to seconds.
+func retryAfter(c *gin.Context, d time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+}
+
+// rateLimitMiddleware refuses banned clients and limits each client IP to
+// --rate-limit API requests per minute.
+func rateLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		now := time.Now()
+		isAPI := strings.HasPrefix(c.Request.URL.Path, "/api/")
+
+		if ban, ok := bans.active(ip, now); ok && ban.Scope == db.BanScopeAll {
+			if ban.Until != nil {
+				retryAfter(c, ban.Until.Sub(now))
+			}
+			if isAPI {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "banned"})
+				return
+			}
+			c.String(http.StatusForbidden, "Forbidden")
+			c.Abort()
+			return
+		}
+
+		if rateLimitPerMinute > 0 && isAPI {
+			if ok, wait := apiLimiter.allow(ip, now); !ok {
+				retryAfter(c, wait)
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// loginFailures counts a client's failed logins since its last lockout.
+type loginFailures struct {
+	count    int
+	lockouts int
+	last     time.Time
+}
+
+// loginGuard locks a client out of logging in after --login-max-failures
+// failures in a row, for --login-lockout-seconds doubled on every further
+// lockout. Lockouts are login-scoped bans, so they survive restarts.
+type loginGuard struct {
+	mu        sync.Mutex
+	failures  map[string]*loginFailures
+	lastPrune time.Time
+}
+
+var logins = &loginGuard{failures: make(map[string]*loginFailures)}
+
+// lockedFor returns how long ip is still locked out of logging in.
+func (g *loginGuard) lockedFor(ip string, now time.Time) time.Duration {
+	ban, ok := bans.active(ip, now)
+	if !ok {
+		return 0
+	}
+	if ban.Until == nil {
+		return maxLoginLockout
+	}
+	return ban.Until.Sub(now)
+}
+
+// fail records a failed login, returning the lockout it caused, if any.
+func (g *loginGuard) fail(ip string, now time.Time) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if now.Sub(g.lastPrune) > time.Minute {
+		// Failures past the memory would be reset anyway, and the lockouts
+		// they caused have expired
+		for key, f := range g.failures {
+			if now.Sub(f.last) > loginFailureMemory {
+				delete(g.failures, key)
+			}
+		}
+		g.lastPrune = now
+	}
+
+	f, ok := g.failures[ip]
+	if !ok || now.Sub(f.last) > loginFailureMemory {
+		f = &loginFailures{}
+		g.failures[ip] = f
+	}
+	f.count++
+	f.last = now
+	if f.count < loginMaxFailures {
+		return 0
+	}
+
+	f.count = 0
+	f.lockouts++
+	lockout := time.Duration(loginLockoutSeconds) * time.Second
+	for i := 1; i < f.lockouts && lockout < maxLoginLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > maxLoginLockout {
+		lockout = maxLoginLockout
+	}
+	until := now.Add(lockout)
+	ban := db.IPBan{
+		IP:        ip,
+		Scope:     db.BanScopeLogin,
+		Reason:    fmt.Sprintf("%d failed logins", loginMaxFailures),
+		CreatedAt: now,
+		Until:     &until,
+	}
+	if err := bans.add(ban); err != nil {
+		log.Printf("Failed to save login lockout of %s: %v", ip, err)
+	}
+	recordEvent("security", "locked %s out of logging in for %s after %d failed logins", ip, lockout, loginMaxFailures)
+	return lockout
+}
+
+// succeed forgets ip's failed logins.
+func (g *loginGuard) succeed(ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.failures, ip)
+}
+
+// parseTrustedProxies splits --trusted-proxies into the IPs and CIDRs gin
+// takes X-Forwarded-For from; none means the client IP is the peer address.
+func parseTrustedProxies(s string) ([]string, error) {
+	var proxies []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if net.ParseIP(p) == nil {
+			if _, _, err := net.ParseCIDR(p); err != nil {
+				return nil, fmt.Errorf("invalid proxy %q", p)
+			}
+		}
+		proxies = append(proxies, p)
+	}
+	return proxies, nil
+}
+
+func getBansHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"bans": bans.list(time.Now())})
+}
+
+type BanRequest struct {
+	IP      string `json:"ip" binding:"required"`
+	Scope   string `json:"scope"` // all (default) or login
+	Reason  string `json:"reason"`
+	Minutes int    `json:"minutes"` // 0 bans for good
+}
+
+func addBanHandler(c *gin.Context) {
+	var req BanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ip := net.ParseIP(req.IP)
+	if ip == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ip"})
+		return
+	}
+	req.IP = ip.String()
+	if req.Scope == "" {
+		req.Scope = db.BanScopeAll
+	}
+	if req.Scope != db.BanScopeAll && req.Scope != db.BanScopeLogin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be all or login"})
+		return
+	}
+	if req.Minutes < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "minutes must not be negative"})
+		return
+	}
+	if req.IP == c.ClientIP() && req.Scope == db.BanScopeAll {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refusing to ban your own IP"})
+		return
+	}
+
+	now := time.Now()
+	ban := db.IPBan{IP: req.IP, Scope: req.Scope, Reason: req.Reason, CreatedAt: now}
+	if req.Minutes > 0 {
+		until := now.Add(time.Duration(req.Minutes) * time.Minute)
+		ban.Until = &until
+	}
+	if err := bans.add(ban); err != nil {
+		log.Printf("Failed to save ban of %s: %v", req.IP, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save ban"})
+		return
+	}
+	recordEvent("security", "banned %s (%s): %s", req.IP, req.Scope, req.Reason)
+	c.JSON(http.StatusOK, gin.H{"success": true, "ban": ban})
+}
+
+func deleteBanHandler(c *gin.Context) {
+	ip := ipParam(c)
+	found, err := bans.remove(ip)
+	if err != nil {
+		log.Printf("Failed to delete ban of %s: %v", ip, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete ban"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ban not found"})
+		return
+	}
+	logins.succeed(ip)
+	recordEvent("security", "lifted ban of %s", ip)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
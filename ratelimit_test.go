@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLoginGuardPrunesOldFailures(t *testing.T) {
+	defer func(max int) { loginMaxFailures = max }(loginMaxFailures)
+	loginMaxFailures = 100
+
+	g := &loginGuard{failures: make(map[string]*loginFailures)}
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 500; i++ {
+		g.fail(fmt.Sprintf("10.0.%d.%d", i/250, i%250), start)
+	}
+	if len(g.failures) != 500 {
+		t.Fatalf("failures = %d, want 500", len(g.failures))
+	}
+
+	g.fail("10.1.0.1", start.Add(time.Hour))
+	if len(g.failures) != 501 {
+		t.Errorf("failures within the memory were pruned: %d left, want 501", len(g.failures))
+	}
+
+	g.fail("10.1.0.2", start.Add(loginFailureMemory+time.Minute))
+	if len(g.failures) != 2 {
+		t.Errorf("failures = %d after the memory passed, want 2", len(g.failures))
+	}
+	if _, ok := g.failures["10.1.0.1"]; !ok {
+		t.Errorf("recent failure of 10.1.0.1 was pruned")
+	}
+}
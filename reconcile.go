@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DriftInfo is the last reconciliation result for a machine with a desired state.
+type DriftInfo struct {
+	PlannedChange
+	Desired     db.DesiredState `json:"desired"`
+	Applied     bool            `json:"applied"` // a correction was sent in the last pass
+	ApplyError  string          `json:"applyError,omitempty"`
+	CheckedAt   time.Time       `json:"checkedAt"`
+	Corrections int             `json:"corrections"` // total corrections since startup
+}
+
+// reconciler converges miner configs to their desired state and keeps the last
+// drift report per machine.
+type reconciler struct {
+	runMu sync.Mutex // one pass at a time
+	mu    sync.Mutex
+	drift map[string]DriftInfo
+}
+
+var reconcile = &reconciler{drift: make(map[string]DriftInfo)}
+
+// autoPowerTarget scales a desired Auto power target by the active scenario,
+// the forecast plan and the noise policy. A scaled target is kept within the
+// miner's model profile.
+func autoPowerTarget(ip string, power int) int {
+	scaled := noise.adjustPower(forecastPlan.adjustPower(scenarios.adjustPower(power)))
+	if scaled == power {
+		return power
+	}
+	limits, err := loadLimits()
+	if err != nil {
+		log.Printf("Failed to check operating limits of %s: %v", ip, err)
+		return min(scaled, power)
+	}
+	scaled, _ = limits.power(ip, scaled)
+	return scaled
+}
+
+// desiredRequest converts a desired state to the keys compared by
+// planConfigChange. Values are compared as applyDesiredState clamps them, so a
+// profile narrowed since the state was saved doesn't show as drift forever.
+func desiredRequest(s db.DesiredState) map[string]interface{} {
+	power, freq, volt := autoPowerTarget(s.MachineIP, s.PowerTarget), s.Freq, s.Volt
+	if limits, err := loadLimits(); err == nil {
+		power, _ = limits.power(s.MachineIP, power)
+		freq, volt, _ = limits.freqVolt(s.MachineIP, freq, volt)
+	}
+	switch s.WorkMode {
+	case "Auto":
+		return map[string]interface{}{"workMode": "Auto", "modeSelect": "PowerTarget", "targetValue": power}
+	case "Fixed":
+		return map[string]interface{}{"workMode": "Fixed", "freq": freq, "volt": volt}
+	default:
+		return map[string]interface{}{"workMode": s.WorkMode}
+	}
+}
+
+// applyDesiredState pushes the desired config to a miner. The values are
+// checked against the miner's profile again, as it may have changed since the
+// state was saved.
+func applyDesiredState(s db.DesiredState) error {
+	switch s.WorkMode {
+	case "Auto":
+		power, err := guardPower(s.MachineIP, autoPowerTarget(s.MachineIP, s.PowerTarget))
+		if err != nil {
+			return err
+		}
+		return driverFor(s.MachineIP).SetPowerTarget(context.Background(), s.MachineIP, power)
+	case "Fixed":
+		freq, volt, err := guardFreqVolt(s.MachineIP, s.Freq, s.Volt)
+		if err != nil {
+			return err
+		}
+		return driverFor(s.MachineIP).SetFreqVolt(context.Background(), s.MachineIP, freq, volt)
+	case "Sleep":
+		return driverFor(s.MachineIP).SetSleep(context.Background(), s.MachineIP)
+	}
+	return fmt.Errorf("unknown work mode %q", s.WorkMode)
+}
+
+// runReconciler compares miners to their desired state at the given interval and
+// re-applies it where the config drifted (e.g. after a reboot reset).
+func runReconciler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		reconcile.run()
+	}
+}
+
+func (r *reconciler) run() {
+	r.runMu.Lock()
+	defer r.runMu.Unlock()
+
+	// Miners are shut down on purpose; don't wake them
+	if emergency.locked() {
+		return
+	}
+
+	states, err := database.FetchDesiredStates()
+	if err != nil {
+		log.Printf("Reconciler: failed to fetch desired states: %v", err)
+		return
+	}
+
+	// Miners in maintenance are being worked on by hand
+	active := states[:0]
+	for _, s := range states {
+		if !inMaintenance(s.MachineIP) {
+			active = append(active, s)
+		}
+	}
+	states = active
+
+	results := make([]DriftInfo, len(states))
+	var wg sync.WaitGroup
+	for i, s := range states {
+		wg.Add(1)
+		go func(i int, s db.DesiredState) {
+			defer wg.Done()
+			info := DriftInfo{
+				PlannedChange: planConfigChange(context.Background(), s.MachineIP, desiredRequest(s)),
+				Desired:       s,
+				CheckedAt:     time.Now(),
+			}
+			// Unreachable miners are usually powered off on purpose; only report them
+			if info.Reachable && info.Changes {
+				if err := applyDesiredState(s); err != nil {
+					info.ApplyError = err.Error()
+					log.Printf("Reconciler: failed to apply desired state to %s: %v", s.MachineIP, err)
+				} else {
+					info.Applied = true
+					recordEvent("reconciler", "restored %s mode on %s (was %v)", s.WorkMode, info.Name, info.Current)
+				}
+			}
+			results[i] = info
+		}(i, s)
+	}
+	wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	drift := make(map[string]DriftInfo, len(results))
+	for _, info := range results {
+		info.Corrections = r.drift[info.IP].Corrections
+		if info.Applied {
+			info.Corrections++
+		}
+		drift[info.IP] = info
+	}
+	r.drift = drift
+}
+
+// report returns the last drift results sorted by machine name.
+func (r *reconciler) report() []DriftInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]DriftInfo, 0, len(r.drift))
+	for _, info := range r.drift {
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+func getDriftHandler(c *gin.Context) {
+	report := reconcile.report()
+	drifted := 0
+	for _, info := range report {
+		if info.Changes {
+			drifted++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"machines": report,
+		"drifted":  drifted,
+	})
+}
+
+func getDesiredStatesHandler(c *gin.Context) {
+	states, err := database.FetchDesiredStates()
+	if err != nil {
+		log.Printf("Failed to fetch desired states: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch desired states"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"states": states})
+}
+
+type DesiredStateRequest struct {
+	IP       string  `json:"ip" binding:"required"`
+	WorkMode string  `json:"workMode" binding:"required"`
+	Power    int     `json:"power"`
+	Freq     float64 `json:"freq"`
+	Volt     float64 `json:"volt"`
+}
+
+func saveDesiredStateHandler(c *gin.Context) {
+	var req DesiredStateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch {
+	case req.WorkMode == "Auto" && req.Power <= 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "power required for Auto mode"})
+		return
+	case req.WorkMode == "Fixed" && (req.Freq <= 0 || req.Volt <= 0):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "freq and volt required for Fixed mode"})
+		return
+	case req.WorkMode != "Auto" && req.WorkMode != "Fixed" && req.WorkMode != "Sleep":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "workMode must be Auto, Fixed or Sleep"})
+		return
+	}
+	if _, ok := visibleMachine(c, req.IP); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown machine " + req.IP})
+		return
+	}
+
+	state := db.DesiredState{
+		MachineIP:   req.IP,
+		WorkMode:    req.WorkMode,
+		PowerTarget: req.Power,
+		Freq:        req.Freq,
+		Volt:        req.Volt,
+	}
+	// Stored within limits, so the reconciler doesn't fight a clamp
+	var err error
+	switch req.WorkMode {
+	case "Auto":
+		state.PowerTarget, err = guardPower(req.IP, req.Power)
+	case "Fixed":
+		state.Freq, state.Volt, err = guardFreqVolt(req.IP, req.Freq, req.Volt)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.SaveDesiredState(state); err != nil {
+		log.Printf("Failed to save desired state for %s: %v", req.IP, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save desired state"})
+		return
+	}
+
+	recordEvent("reconciler", "desired state of %s set to %s (from %s)", minerName(req.IP), req.WorkMode, c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"state":   state,
+	})
+}
+
+func deleteDesiredStateHandler(c *gin.Context) {
+	ip := ipParam(c)
+	if err := database.DeleteDesiredState(ip); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete desired state"})
+		return
+	}
+
+	reconcile.mu.Lock()
+	delete(reconcile.drift, ip)
+	reconcile.mu.Unlock()
+
+	recordEvent("reconciler", "desired state of %s removed (from %s)", minerName(ip), c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"ip":      ip,
+	})
+}
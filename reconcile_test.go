@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"miningRoom/db"
+	"miningRoom/internal/testsupport"
+)
+
+func TestApplyDesiredStateRechecksLimits(t *testing.T) {
+	d := useTestDatabase(t)
+	useMinerCredentials(t, "root", "secret")
+	defer func(mode string) { limitMode = mode }(limitMode)
+
+	miner := testsupport.NewMiner("root", "secret")
+	defer miner.Close()
+	useMachines(t, db.Machine{Name: "a", IP: miner.Addr(), Model: "S19"})
+	// The profile was narrowed after the desired state was saved
+	if err := d.SaveModelProfile(db.ModelProfile{Model: "S19", MaxFreq: 500, MaxVolt: 14, MaxPower: 3000}); err != nil {
+		t.Fatal(err)
+	}
+	fixed := db.DesiredState{MachineIP: miner.Addr(), WorkMode: "Fixed", Freq: 600, Volt: 13}
+	auto := db.DesiredState{MachineIP: miner.Addr(), WorkMode: "Auto", PowerTarget: 3500}
+
+	limitMode = limitRefuse
+	for _, s := range []db.DesiredState{fixed, auto} {
+		if err := applyDesiredState(s); err == nil {
+			t.Errorf("%s state outside the profile applied", s.WorkMode)
+		}
+	}
+	if n := len(miner.Posts()); n != 0 {
+		t.Fatalf("%d config(s) sent to the miner, want none", n)
+	}
+
+	limitMode = limitClamp
+	if err := applyDesiredState(fixed); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := miner.Mode()["fixed"].(map[string]interface{})
+	if got["freq"] != 500.0 || got["volt"] != 13.0 {
+		t.Errorf("applied fixed %v, want freq clamped to 500 and volt 13", got)
+	}
+	if req := desiredRequest(fixed); req["freq"] != 500.0 {
+		t.Errorf("compared freq %v, want the clamped 500 so the state doesn't drift", req["freq"])
+	}
+}
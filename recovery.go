@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Hung miner recovery settings.
+var (
+	recoverMinutes         int    // --recover-minutes
+	recoverSteps           string // --recover-steps
+	recoverStepMinutes     int    // --recover-step-minutes
+	recoverCooldownMinutes int    // --recover-cooldown-minutes
+)
+
+// Recovery steps, in the order they escalate by default.
+const (
+	recoverRestart    = "restart"    // restart the mining process
+	recoverReboot     = "reboot"     // reboot the control board
+	recoverPowerCycle = "powercycle" // switch the outlet off and on
+)
+
+// powerCycleOff is how long the outlet stays off during a power cycle, so the
+// PSU capacitors drain and the control board really resets.
+const powerCycleOff = 15 * time.Second
+
+// parseRecoverySteps reads --recover-steps.
+func parseRecoverySteps(s string) ([]string, error) {
+	steps := splitList(s)
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("no steps")
+	}
+	for _, step := range steps {
+		switch step {
+		case recoverRestart, recoverReboot, recoverPowerCycle:
+		default:
+			return nil, fmt.Errorf("unknown step %q (use restart, reboot or powercycle)", step)
+		}
+	}
+	return steps, nil
+}
+
+// Recovery is the recovery state of a miner that reports zero hashrate while
+// powered and reachable.
+type Recovery struct {
+	MinerIP       string     `json:"minerIp"`
+	Name          string     `json:"name"`
+	ZeroSince     *time.Time `json:"zeroSince,omitempty"` // zero hashrate first seen
+	Step          int        `json:"step"`                // steps taken in this sequence, -1 when given up
+	LastAction    string     `json:"lastAction,omitempty"`
+	LastActionAt  *time.Time `json:"lastActionAt,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+	CooldownUntil *time.Time `json:"cooldownUntil,omitempty"` // no new sequence before
+}
+
+// recoveryPolicy walks hung miners through the recovery steps: each step gets
+// --recover-step-minutes to bring the hashrate back before the next one runs.
+// A finished sequence, successful or not, starts the miner's cooldown.
+type recoveryPolicy struct {
+	mu     sync.Mutex
+	steps  []string
+	states map[string]*Recovery // by miner IP
+}
+
+var recovery = &recoveryPolicy{states: make(map[string]*Recovery)}
+
+// runRecoveryPolicy checks the miners at the given interval.
+func runRecoveryPolicy(steps []string, interval time.Duration) {
+	recovery.mu.Lock()
+	recovery.steps = steps
+	recovery.mu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		recovery.check()
+	}
+}
+
+// recoveryAction is a step to run after the state lock is released.
+type recoveryAction struct {
+	ip, name, step, reason string
+}
+
+func (r *recoveryPolicy) check() {
+	// Relays were cut on purpose
+	if emergency.locked() {
+		return
+	}
+
+	// Without hashrate data every miner would look hung
+	statuses, err := tsStore.GetMinerStatuses()
+	if err != nil {
+		log.Printf("Recovery: failed to get miner statuses: %v", err)
+		return
+	}
+	if !statuses.HasData {
+		return
+	}
+	zero := make(map[string]bool)
+	mining := make(map[string]bool)
+	for _, s := range statuses.Miners {
+		if !isTimestampRecent(s.Timestamp, 5*time.Minute) {
+			continue
+		}
+		switch {
+		case s.Hashrate > 0:
+			mining[s.MinerIP] = true
+		case !strings.EqualFold(s.WorkMode, "Sleep"):
+			// Sleeping miners report zero hashrate on purpose
+			zero[s.MinerIP] = true
+		}
+	}
+
+	now := time.Now()
+	cooldown := time.Duration(recoverCooldownMinutes) * time.Minute
+	stepWait := time.Duration(recoverStepMinutes) * time.Minute
+
+	var actions []recoveryAction
+	r.mu.Lock()
+	seen := make(map[string]bool)
+	for _, s := range shellyWatch.list() {
+		seen[s.MinerIP] = true
+		key := "recovery:" + s.MinerIP
+		st := r.states[s.MinerIP]
+		if st == nil {
+			st = &Recovery{MinerIP: s.MinerIP, Name: s.Name}
+			r.states[s.MinerIP] = st
+		}
+		// A sequence in progress (or given up) waits out reboots and power
+		// cycles, during which the miner is unreachable
+		inProgress := st.Step != 0
+
+		if mining[s.MinerIP] {
+			if inProgress {
+				recordEvent("recovery", "%s: hashrate back after %s", s.Name, st.LastAction)
+				alerts.clear(key)
+				until := now.Add(cooldown)
+				st.CooldownUntil = &until
+			}
+			st.reset()
+			continue
+		}
+		if inMaintenance(s.MinerIP) || !s.On {
+			// Switched off or taken over by a human
+			alerts.clear(key)
+			st.reset()
+			continue
+		}
+		hung := s.Reachable && s.MinerOnline && zero[s.MinerIP]
+		if !hung && !inProgress {
+			st.reset()
+			continue
+		}
+
+		if st.ZeroSince == nil {
+			st.ZeroSince = &now
+		}
+		switch {
+		case !inProgress && now.Sub(*st.ZeroSince) < time.Duration(recoverMinutes)*time.Minute:
+			continue
+		case !inProgress && st.CooldownUntil != nil && now.Before(*st.CooldownUntil):
+			continue
+		case inProgress && st.LastActionAt != nil && now.Sub(*st.LastActionAt) < stepWait:
+			continue
+		}
+
+		if st.Step < 0 {
+			// Given up: try again once the cooldown is over
+			if now.After(*st.CooldownUntil) {
+				st.reset()
+			}
+			continue
+		}
+		step := r.nextStep(st)
+		if step == "" {
+			// Out of steps: leave the miner to a human until its hashrate
+			// returns or the cooldown ends
+			msg := fmt.Sprintf("%s has no hashrate after automatic recovery (%s)", s.Name, st.LastAction)
+			if st.LastAction == "" {
+				msg = fmt.Sprintf("%s has no hashrate and supports none of the recovery steps", s.Name)
+			}
+			recordEvent("recovery", "%s: %s, giving up", s.Name, strings.TrimPrefix(msg, s.Name+" "))
+			alerts.raise(key, severityCritical, "recovery", msg)
+			until := now.Add(cooldown)
+			st.CooldownUntil = &until
+			st.Step = -1
+			continue
+		}
+
+		reason := fmt.Sprintf("no hashrate for %s while powered and reachable", now.Sub(*st.ZeroSince).Round(time.Minute))
+		if inProgress {
+			reason = fmt.Sprintf("still no hashrate %s after %s", now.Sub(*st.LastActionAt).Round(time.Minute), st.LastAction)
+		}
+		actions = append(actions, recoveryAction{ip: s.MinerIP, name: s.Name, step: step, reason: reason})
+		st.Step++
+		st.LastAction = step
+		st.LastActionAt = &now
+	}
+	for ip := range r.states {
+		if !seen[ip] {
+			delete(r.states, ip)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, a := range actions {
+		err := runRecoveryStep(a.ip, a.step)
+		r.mu.Lock()
+		if st, ok := r.states[a.ip]; ok {
+			st.LastError = ""
+			if err != nil {
+				st.LastError = err.Error()
+			}
+		}
+		r.mu.Unlock()
+		if err != nil {
+			recordEvent("recovery", "%s: %s failed: %v", a.name, a.step, err)
+			continue
+		}
+		recordEvent("recovery", "%s: %s, ran %s", a.name, a.reason, a.step)
+	}
+}
+
+// nextStep returns the next configured step the miner's firmware supports, or
+// "" when the sequence is done. Unsupported steps are skipped.
+func (r *recoveryPolicy) nextStep(st *Recovery) string {
+	caps := driverFor(st.MinerIP).Capabilities()
+	for st.Step < len(r.steps) {
+		step := r.steps[st.Step]
+		switch {
+		case step == recoverRestart && !caps.SupportsRestart,
+			step == recoverReboot && !caps.SupportsReboot:
+			st.Step++
+			continue
+		}
+		return step
+	}
+	return ""
+}
+
+// reset ends a miner's sequence but keeps its cooldown.
+func (st *Recovery) reset() {
+	st.ZeroSince = nil
+	st.Step = 0
+	st.LastAction = ""
+	st.LastActionAt = nil
+	st.LastError = ""
+	if st.CooldownUntil != nil && time.Now().After(*st.CooldownUntil) {
+		st.CooldownUntil = nil
+	}
+}
+
+// runRecoveryStep performs one recovery step on a miner.
+func runRecoveryStep(ip, step string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	switch step {
+	case recoverRestart:
+		return driverFor(ip).RestartMining(ctx, ip)
+	case recoverReboot:
+		return driverFor(ip).Reboot(ctx, ip)
+	case recoverPowerCycle:
+		// The relay must come back on even if the step ran out of time
+		if err := switchMinerRelay(context.Background(), ip, false); err != nil {
+			return err
+		}
+		time.Sleep(powerCycleOff)
+		return switchMinerRelay(context.Background(), ip, true)
+	}
+	return fmt.Errorf("unknown step %q", step)
+}
+
+// list returns the recovery state of each miner with zero hashrate, a
+// sequence in progress or a cooldown, in machine order.
+func (r *recoveryPolicy) list() []Recovery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]Recovery, 0, len(r.states))
+	for _, m := range currentMachines() {
+		st, ok := r.states[m.IP]
+		if !ok || (st.ZeroSince == nil && st.CooldownUntil == nil) {
+			continue
+		}
+		result = append(result, *st)
+	}
+	return result
+}
+
+func getRecoveryHandler(c *gin.Context) {
+	states := recovery.list()
+	c.JSON(http.StatusOK, gin.H{
+		"miners":          states,
+		"enabled":         recoverMinutes > 0,
+		"steps":           splitList(recoverSteps),
+		"minutes":         recoverMinutes,
+		"stepMinutes":     recoverStepMinutes,
+		"cooldownMinutes": recoverCooldownMinutes,
+		"hasData":         len(states) > 0,
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// replayMiddleware lets a route answer as of a past moment: `?asOf=` (RFC3339)
+// makes questdbFor read the data as it was then, for post-mortems of
+// incidents. Replayed responses carry an X-Replay-As-Of header; routes without
+// the middleware ignore the parameter and answer live.
+func replayMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := c.Query("asOf")
+		if raw == "" {
+			c.Next()
+			return
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "asOf must be an RFC3339 timestamp"})
+			return
+		}
+		if t.After(time.Now()) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "asOf is in the future"})
+			return
+		}
+		c.Set("AsOf", t)
+		c.Header("X-Replay-As-Of", t.UTC().Format(time.RFC3339))
+		c.Next()
+	}
+}
+
+// asOfFor returns the replay time of the request, or the zero time when it is
+// answered live.
+func asOfFor(c *gin.Context) time.Time {
+	if t, ok := c.Get("AsOf"); ok {
+		return t.(time.Time)
+	}
+	return time.Time{}
+}
+
+// replaying reports whether the request is answered as of a past moment.
+// Handlers leave out live state (presence, maintenance, alerts, the Shelly
+// watcher cache) then, since it can't be reconstructed.
+func replaying(c *gin.Context) bool {
+	return !asOfFor(c).IsZero()
+}
+
+// requestNow is the moment the request is answered for: the replay time, or
+// now. Freshness checks compare data timestamps against it.
+func requestNow(c *gin.Context) time.Time {
+	if t := asOfFor(c); !t.IsZero() {
+		return t.In(roomLocation)
+	}
+	return roomNow()
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"miningRoom/db"
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// efficiencyRegressionPct is the default J/TH increase versus the previous week
+// that is flagged as a regression.
+const efficiencyRegressionPct = 5.0
+
+// EfficiencyEntry is one miner's row in the efficiency leaderboard.
+type EfficiencyEntry struct {
+	Rank           int     `json:"rank"`
+	Name           string  `json:"name"`
+	IP             string  `json:"ip"`
+	Efficiency     float64 `json:"efficiency"`     // J/TH over the window
+	PrevEfficiency float64 `json:"prevEfficiency"` // J/TH over the same window a week earlier
+	ChangePct      float64 `json:"changePct"`      // positive = worse
+	HashrateTH     float64 `json:"hashrateTh"`
+	PowerW         float64 `json:"powerW"`
+	Regression     bool    `json:"regression"`
+	HasData        bool    `json:"hasData"`
+}
+
+// EfficiencyReport ranks miners by J/TH over a window and compares each to its
+// own figures from the previous week.
+type EfficiencyReport struct {
+	Window          string            `json:"window"`
+	From            time.Time         `json:"from"`
+	To              time.Time         `json:"to"`
+	ThresholdPct    float64           `json:"thresholdPct"`
+	FleetEfficiency float64           `json:"fleetEfficiency"`
+	Regressions     int               `json:"regressions"`
+	Miners          []EfficiencyEntry `json:"miners"`
+	GeneratedAt     time.Time         `json:"generatedAt"`
+}
+
+// efficiency returns J/TH for power in W and hashrate in GH/s.
+func efficiency(powerW, hashrateGH float64) float64 {
+	if hashrateGH <= 0 {
+		return 0
+	}
+	return math.Round(powerW/(hashrateGH/1000)*10) / 10
+}
+
+// buildEfficiencyReport ranks configured machines by efficiency over the window
+// ending now. Miners without data are listed last.
+func buildEfficiencyReport(window time.Duration, windowLabel string, thresholdPct float64) (*EfficiencyReport, error) {
+	to := time.Now()
+	from := to.Add(-window)
+	week := 7 * 24 * time.Hour
+
+	current, err := questdbClient.GetMinerAverages(from, to)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := questdbClient.GetMinerAverages(from.Add(-week), to.Add(-week))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &EfficiencyReport{
+		Window:       windowLabel,
+		From:         from,
+		To:           to,
+		ThresholdPct: thresholdPct,
+		GeneratedAt:  time.Now(),
+	}
+
+	fleetPower, fleetHashrate := 0.0, 0.0
+	for _, m := range currentMachines() {
+		entry := EfficiencyEntry{Name: m.Name, IP: m.IP}
+		if cur, ok := current[m.IP]; ok {
+			entry.HasData = true
+			entry.Efficiency = efficiency(cur.AvgPower, cur.AvgHashrate)
+			entry.PowerW = math.Round(cur.AvgPower)
+			entry.HashrateTH = math.Round(cur.AvgHashrate/1000*10) / 10
+			fleetPower += cur.AvgPower
+			fleetHashrate += cur.AvgHashrate
+		}
+		if prev, ok := previous[m.IP]; ok {
+			entry.PrevEfficiency = efficiency(prev.AvgPower, prev.AvgHashrate)
+		}
+		if entry.Efficiency > 0 && entry.PrevEfficiency > 0 {
+			entry.ChangePct = math.Round((entry.Efficiency/entry.PrevEfficiency-1)*1000) / 10
+			entry.Regression = entry.ChangePct >= thresholdPct
+		}
+		if entry.Regression {
+			report.Regressions++
+		}
+		report.Miners = append(report.Miners, entry)
+	}
+	report.FleetEfficiency = efficiency(fleetPower, fleetHashrate)
+
+	sort.SliceStable(report.Miners, func(i, j int) bool {
+		a, b := report.Miners[i], report.Miners[j]
+		if a.HasData != b.HasData {
+			return a.HasData
+		}
+		return a.Efficiency < b.Efficiency
+	})
+	for i := range report.Miners {
+		report.Miners[i].Rank = i + 1
+	}
+
+	return report, nil
+}
+
+// efficiencyReportParams reads ?window= (default 7d) and ?threshold= (percent),
+// writing a 400 response if invalid.
+func efficiencyReportParams(c *gin.Context) (time.Duration, string, float64, bool) {
+	label := c.DefaultQuery("window", "7d")
+	window, err := parseHistoryRange(label)
+	if err != nil || window <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid window"})
+		return 0, "", 0, false
+	}
+	threshold := efficiencyRegressionPct
+	if s := c.Query("threshold"); s != "" {
+		if threshold, err = strconv.ParseFloat(s, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid threshold"})
+			return 0, "", 0, false
+		}
+	}
+	return window, label, threshold, true
+}
+
+func getEfficiencyReportHandler(c *gin.Context) {
+	window, label, threshold, ok := efficiencyReportParams(c)
+	if !ok {
+		return
+	}
+
+	report, err := buildEfficiencyReport(window, label, threshold)
+	if err != nil {
+		log.Printf("Failed to build efficiency report: %v", err)
+		c.JSON(http.StatusOK, gin.H{
+			"miners":  []interface{}{},
+			"hasData": false,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// efficiencyReportPageHandler renders the efficiency report as a self-contained
+// HTML page with inline styles so it can also be sent as an email body.
+func efficiencyReportPageHandler(c *gin.Context) {
+	window, label, threshold, ok := efficiencyReportParams(c)
+	if !ok {
+		return
+	}
+
+	report, err := buildEfficiencyReport(window, label, threshold)
+	if err != nil {
+		log.Printf("Failed to build efficiency report: %v", err)
+	}
+
+	c.HTML(http.StatusOK, "report-efficiency.html", gin.H{
+		"Title":  "Efficiency Report",
+		"Lang":   localeFor(c).Lang,
+		"Report": report,
+	})
+}
+
+// Scheduled report settings. reportSchedule is a comma-separated list of
+// periods ("weekly", "monthly"); empty disables scheduled reports.
+var (
+	reportRecipients string
+	reportSchedule   string
+)
+
+// reportSendHour is the local hour from which a due report is sent.
+const reportSendHour = 8
+
+// PeriodReport summarizes the mining room over a completed week or month.
+type PeriodReport struct {
+	Period       string                        `json:"period"`
+	From         time.Time                     `json:"from"`
+	To           time.Time                     `json:"to"`
+	EnergyKWh    float64                       `json:"energyKwh"`
+	CostEUR      float64                       `json:"costEur"`
+	BTCEarned    float64                       `json:"btcEarned"` // estimate at current network hashrate
+	BTCPriceEUR  float64                       `json:"btcPriceEur"`
+	PriceSource  string                        `json:"btcPriceSource"` // "history" (period average) or "current"
+	RevenueEUR   float64                       `json:"revenueEur"`     // BTCEarned at BTCPriceEUR
+	AvgHashrate  float64                       `json:"avgHashrateTh"`
+	UptimePct    float64                       `json:"uptimePct"`
+	Temperatures []questdb.TemperatureExtremes `json:"temperatures"`
+	Incidents    []db.Event                    `json:"incidents"`
+	Efficiency   *EfficiencyReport             `json:"efficiency,omitempty"`
+	HeatRecovery *HeatRecoverySummary          `json:"heatRecovery,omitempty"` // monthly, with heat recovery readings
+	HasData      bool                          `json:"hasData"`
+	GeneratedAt  time.Time                     `json:"generatedAt"`
+}
+
+// LastDay returns the last day included in the report period.
+func (r *PeriodReport) LastDay() time.Time {
+	return r.To.AddDate(0, 0, -1)
+}
+
+// reportPeriodBounds returns the last completed period before now: the previous
+// Monday-to-Monday week, or the previous calendar month.
+func reportPeriodBounds(period string, now time.Time) (time.Time, time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case "weekly":
+		to := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		return to.AddDate(0, 0, -7), to, nil
+	case "monthly":
+		to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		return to.AddDate(0, -1, 0), to, nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("unknown report period %q", period)
+}
+
+// parseMonthWindow returns the calendar month of ?month=2026-01, or without it
+// the month offset months from the current one. A month that isn't over ends
+// at now.
+func parseMonthWindow(c *gin.Context, now time.Time, offset int) (from, to time.Time, err error) {
+	from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, offset, 0)
+	if s := c.Query("month"); s != "" {
+		t, err := time.ParseInLocation("2006-01", s, now.Location())
+		if err != nil || t.After(now) {
+			return from, to, fmt.Errorf("invalid month")
+		}
+		from = t
+	}
+	to = from.AddDate(0, 1, 0)
+	if to.After(now) {
+		to = now
+	}
+	return from, to, nil
+}
+
+// isIncident reports whether an event is a raised warning or critical alert.
+func isIncident(e db.Event) bool {
+	return strings.Contains(e.Message, "warning alert:") || strings.Contains(e.Message, "critical alert:")
+}
+
+// buildPeriodReport gathers the summary for the last completed period.
+func buildPeriodReport(period string) (*PeriodReport, error) {
+	from, to, err := reportPeriodBounds(period, roomNow())
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PeriodReport{
+		Period:      period,
+		From:        from,
+		To:          to,
+		Incidents:   []db.Event{},
+		GeneratedAt: time.Now(),
+	}
+
+	stats, err := questdbClient.GetPeriodStats(from, to, maintenanceIPs())
+	if err != nil {
+		log.Printf("Failed to get period stats from QuestDB: %v", err)
+	} else {
+		days := to.Sub(from).Hours() / 24
+		report.HasData = stats.HasData
+		report.EnergyKWh = math.Round(stats.EnergyKWh*10) / 10
+		report.CostEUR = math.Round(stats.EnergyKWh*elecPrice*100) / 100
+		report.AvgHashrate = math.Round(stats.AvgHashrate/1000*10) / 10
+		report.UptimePct = math.Round(stats.UptimePct*10) / 10
+		report.Temperatures = stats.Temperatures
+		if market, err := fetchMiningMarket(); err != nil {
+			log.Printf("Failed to fetch mining market for report: %v", err)
+		} else {
+			report.BTCEarned = stats.AvgHashrate / 1000 * market.dailyBTCPerTH() * days
+			report.BTCPriceEUR, report.PriceSource = periodBTCPriceEUR(from, to, market)
+			report.RevenueEUR = math.Round(report.BTCEarned*report.BTCPriceEUR*100) / 100
+		}
+	}
+
+	events, err := database.FetchEventsBetween(from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		if isIncident(e) {
+			report.Incidents = append(report.Incidents, e)
+		}
+	}
+
+	if period == "weekly" {
+		if eff, err := buildEfficiencyReport(7*24*time.Hour, "7d", efficiencyRegressionPct); err != nil {
+			log.Printf("Failed to build efficiency report: %v", err)
+		} else {
+			report.Efficiency = eff
+		}
+	}
+	if period == "monthly" {
+		if heat, err := buildHeatRecoverySummary(questdbClient, from, to); err != nil {
+			log.Printf("Failed to build heat recovery summary: %v", err)
+		} else if heat.HasData {
+			report.HeatRecovery = heat
+		}
+	}
+
+	return report, nil
+}
+
+// renderPeriodReport renders a period report as a standalone HTML email body.
+func renderPeriodReport(report *PeriodReport) (string, error) {
+	return renderTemplate("report-period.html", gin.H{
+		"Title":  periodReportSubject(report),
+		"Lang":   "en",
+		"Report": report,
+	})
+}
+
+func periodReportSubject(report *PeriodReport) string {
+	return fmt.Sprintf("Mining room %s report %s – %s", report.Period,
+		report.From.Format("2006-01-02"), report.LastDay().Format("2006-01-02"))
+}
+
+// sendPeriodReport builds, renders and emails a period report to the
+// configured recipients.
+func sendPeriodReport(period string) error {
+	report, err := buildPeriodReport(period)
+	if err != nil {
+		return err
+	}
+	html, err := renderPeriodReport(report)
+	if err != nil {
+		return err
+	}
+	return sendMail(splitList(reportRecipients), periodReportSubject(report), html)
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// runReportScheduler checks at the given interval whether a scheduled report is
+// due and sends it. A report is due once its period has ended and it is past
+// reportSendHour; the last send is stored so restarts don't resend.
+func runReportScheduler(interval time.Duration) {
+	periods := splitList(reportSchedule)
+	if len(periods) == 0 {
+		return
+	}
+	if !mailEnabled() || reportRecipients == "" {
+		log.Printf("Report schedule %q set but SMTP or recipients are not configured; scheduled reports disabled", reportSchedule)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := roomNow()
+		if now.Hour() < reportSendHour {
+			continue
+		}
+		for _, period := range periods {
+			_, to, err := reportPeriodBounds(period, now)
+			if err != nil {
+				log.Printf("Report scheduler: %v", err)
+				continue
+			}
+			last, err := database.LastReportRun(period)
+			if err != nil {
+				log.Printf("Report scheduler: failed to read last %s run: %v", period, err)
+				continue
+			}
+			if !last.Before(to) {
+				continue
+			}
+			if err := sendPeriodReport(period); err != nil {
+				log.Printf("Report scheduler: failed to send %s report: %v", period, err)
+				continue
+			}
+			if err := database.SetReportRun(period, now); err != nil {
+				log.Printf("Report scheduler: failed to record %s run: %v", period, err)
+			}
+			recordEvent("reports", "sent %s report to %s", period, reportRecipients)
+		}
+	}
+}
+
+// reportPreviewPeriod reads ?period= (default weekly), writing a 400 response if
+// invalid.
+func reportPreviewPeriod(c *gin.Context) (string, bool) {
+	period := c.DefaultQuery("period", "weekly")
+	if period != "weekly" && period != "monthly" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be weekly or monthly"})
+		return "", false
+	}
+	return period, true
+}
+
+// previewReportHandler renders the report email for the last completed period
+// without sending it. ?format=json returns the underlying data instead.
+func previewReportHandler(c *gin.Context) {
+	period, ok := reportPreviewPeriod(c)
+	if !ok {
+		return
+	}
+
+	report, err := buildPeriodReport(period)
+	if err != nil {
+		log.Printf("Failed to build %s report: %v", period, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build report"})
+		return
+	}
+	if c.Query("format") == "json" {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	html, err := renderPeriodReport(report)
+	if err != nil {
+		log.Printf("Failed to render %s report: %v", period, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render report"})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(html))
+}
+
+// sendReportHandler emails the report for the last completed period right away,
+// for testing the SMTP settings.
+func sendReportHandler(c *gin.Context) {
+	period, ok := reportPreviewPeriod(c)
+	if !ok {
+		return
+	}
+	if !mailEnabled() || reportRecipients == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "SMTP host, sender and report recipients must be configured"})
+		return
+	}
+
+	if err := sendPeriodReport(period); err != nil {
+		log.Printf("Failed to send %s report: %v", period, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	recordEvent("reports", "test %s report sent to %s (from %s)", period, reportRecipients, c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"period":     period,
+		"recipients": splitList(reportRecipients),
+	})
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Machines and Shellies registered by hostname are dialed through a cache of
+// their resolved address, so a device renumbered by DHCP is followed by DNS
+// or, for .local names, multicast DNS. The cache is refreshed every
+// --resolve-minutes; when a lookup fails, the last known address is used.
+
+var resolveMinutes int
+
+const (
+	hostCacheTTL = 5 * time.Minute
+	mdnsTimeout  = 2 * time.Second
+)
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+type resolvedHost struct {
+	addr       string
+	resolvedAt time.Time
+}
+
+type hostResolver struct {
+	mu    sync.Mutex
+	hosts map[string]resolvedHost
+}
+
+var deviceHosts = &hostResolver{hosts: make(map[string]resolvedHost)}
+
+// lookup returns the address of host, resolving it when the cached one is
+// older than hostCacheTTL.
+func (r *hostResolver) lookup(ctx context.Context, host string) (string, error) {
+	r.mu.Lock()
+	cached, ok := r.hosts[host]
+	r.mu.Unlock()
+	if ok && time.Since(cached.resolvedAt) < hostCacheTTL {
+		return cached.addr, nil
+	}
+	addr, err := r.resolve(ctx, host)
+	if err != nil {
+		if ok {
+			log.Printf("Failed to resolve %s, using last known address %s: %v", host, cached.addr, err)
+			return cached.addr, nil
+		}
+		return "", err
+	}
+	return addr, nil
+}
+
+// resolve looks host up and caches the result, recording an event when its
+// address changed.
+func (r *hostResolver) resolve(ctx context.Context, host string) (string, error) {
+	addr, err := lookupHostAddr(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	prev, ok := r.hosts[host]
+	r.hosts[host] = resolvedHost{addr: addr, resolvedAt: time.Now()}
+	r.mu.Unlock()
+	if ok && prev.addr != addr {
+		recordEvent("network", "%s moved from %s to %s", host, prev.addr, addr)
+	}
+	return addr, nil
+}
+
+// cached returns the resolved addresses by hostname.
+func (r *hostResolver) cached() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	addrs := make(map[string]string, len(r.hosts))
+	for host, h := range r.hosts {
+		addrs[host] = h.addr
+	}
+	return addrs
+}
+
+// lookupHostAddr resolves a hostname through multicast DNS for .local names
+// and the system resolver otherwise, preferring an IPv4 address.
+func lookupHostAddr(ctx context.Context, host string) (string, error) {
+	var addrs []netip.Addr
+	if strings.HasSuffix(host, ".local") {
+		var err error
+		if addrs, err = mdnsLookup(ctx, host); err != nil {
+			return "", err
+		}
+	} else {
+		ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return "", err
+		}
+		addrs = ips
+	}
+	if len(addrs) == 0 {
+		return "", fmt.Errorf("no address for %s", host)
+	}
+	for _, addr := range addrs {
+		if addr.Unmap().Is4() {
+			return addr.Unmap().String(), nil
+		}
+	}
+	return addrs[0].String(), nil
+}
+
+// mdnsLookup asks the local link for the A and AAAA records of a .local name.
+// The query is sent from an ephemeral port, so responders answer by unicast.
+func mdnsLookup(ctx context.Context, host string) ([]netip.Addr, error) {
+	name, err := dnsmessage.NewName(host + ".")
+	if err != nil {
+		return nil, err
+	}
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{})
+	b.StartQuestions()
+	for _, t := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		if err := b.Question(dnsmessage.Question{Name: name, Type: t, Class: dnsmessage.ClassINET}); err != nil {
+			return nil, err
+		}
+	}
+	query, err := b.Finish()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(ctxDeadline(ctx, mdnsTimeout))
+	if _, err := conn.WriteToUDP(query, mdnsGroup); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return nil, fmt.Errorf("no mDNS answer for %s", host)
+		}
+		if addrs := mdnsAnswers(buf[:n], host); len(addrs) > 0 {
+			return addrs, nil
+		}
+	}
+}
+
+// mdnsAnswers returns the addresses of host in an mDNS response.
+func mdnsAnswers(msg []byte, host string) []netip.Addr {
+	var p dnsmessage.Parser
+	h, err := p.Start(msg)
+	if err != nil || !h.Response {
+		return nil
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil
+	}
+	var addrs []netip.Addr
+	for {
+		rh, err := p.AnswerHeader()
+		if err != nil {
+			return addrs
+		}
+		if !strings.EqualFold(strings.TrimSuffix(rh.Name.String(), "."), host) {
+			p.SkipAnswer()
+			continue
+		}
+		switch rh.Type {
+		case dnsmessage.TypeA:
+			r, err := p.AResource()
+			if err != nil {
+				return addrs
+			}
+			addrs = append(addrs, netip.AddrFrom4(r.A))
+		case dnsmessage.TypeAAAA:
+			r, err := p.AAAAResource()
+			if err != nil {
+				return addrs
+			}
+			addrs = append(addrs, netip.AddrFrom16(r.AAAA))
+		default:
+			p.SkipAnswer()
+		}
+	}
+}
+
+// deviceHostnames lists the hostnames machines and Shellies are registered by.
+func deviceHostnames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, m := range currentMachines() {
+		for _, addr := range []string{m.IP, m.ShellyIP} {
+			if isHostname(addr) && !seen[addr] {
+				seen[addr] = true
+				names = append(names, addr)
+			}
+		}
+	}
+	return names
+}
+
+func isDeviceHostname(host string) bool {
+	for _, name := range deviceHostnames() {
+		if name == host {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveHostPort replaces a device hostname in a host:port address with its
+// cached address. Other addresses, and hostnames that don't resolve, are
+// returned as they are.
+func resolveHostPort(ctx context.Context, hostport string) string {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil || !isDeviceHostname(host) {
+		return hostport
+	}
+	addr, err := deviceHosts.lookup(ctx, host)
+	if err != nil {
+		log.Printf("Failed to resolve %s: %v", host, err)
+		return hostport
+	}
+	return net.JoinHostPort(addr, port)
+}
+
+// dialDevice is the dialer of the default HTTP transport: it dials device
+// hostnames at their cached address.
+func dialDevice(ctx context.Context, network, addr string) (net.Conn, error) {
+	d := net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return d.DialContext(ctx, network, resolveHostPort(ctx, addr))
+}
+
+// useDeviceResolver routes HTTP requests through dialDevice.
+func useDeviceResolver() {
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		t.DialContext = dialDevice
+	}
+}
+
+// runHostResolver re-resolves the device hostnames at the given interval.
+func runHostResolver(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, host := range deviceHostnames() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if _, err := deviceHosts.resolve(ctx, host); err != nil {
+				log.Printf("Failed to resolve %s: %v", host, err)
+			}
+			cancel()
+		}
+		<-ticker.C
+	}
+}
+
+// getResolvedHostsHandler returns the cached address of each device hostname.
+func getResolvedHostsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"hosts": deviceHosts.cached()})
+}
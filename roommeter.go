@@ -0,0 +1,360 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Room meter settings. The whole-room 3-phase meter is the authoritative
+// total power source when it reports; miner plugs are compared against it.
+var (
+	roomMeterURL         string
+	roomMeterPollSeconds int
+	unmeteredWatts       float64
+)
+
+// unmeteredMinutes is how long the meter must exceed the plugs by
+// --unmetered-watts before an alert is raised.
+const unmeteredMinutes = 10
+
+// roomMeter reads the per-phase power, voltage and current of a 3-phase meter.
+type roomMeter interface {
+	Read() ([]questdb.PhaseReading, error)
+}
+
+// roomMeterKinds maps room meter URL schemes to their constructor.
+var roomMeterKinds = map[string]func(u *url.URL) (roomMeter, error){
+	"shellyem": func(u *url.URL) (roomMeter, error) { return shellyEMMeter{host: u.Host}, nil },
+	"modbus":   newModbusMeter,
+}
+
+func parseRoomMeter(spec string) (roomMeter, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	newMeter, ok := roomMeterKinds[u.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("unknown room meter kind %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("room meter URL needs a host")
+	}
+	return newMeter(u)
+}
+
+// shellyEMMeter is a Shelly Pro 3EM (Gen2 RPC) or a Shelly 3EM (Gen1 API).
+type shellyEMMeter struct {
+	host string
+}
+
+func (s shellyEMMeter) Read() ([]questdb.PhaseReading, error) {
+	resp, err := plugClient.Get(fmt.Sprintf("http://%s/rpc/EM.GetStatus?id=0", s.host))
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach shelly meter at %s: %w", s.host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return s.readGen1()
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("shelly meter %s returned status %d: %s", s.host, resp.StatusCode, string(body))
+	}
+
+	var status map[string]float64
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("failed to decode shelly meter status: %w", err)
+	}
+	phases := make([]questdb.PhaseReading, 0, 3)
+	for _, p := range []string{"a", "b", "c"} {
+		phases = append(phases, questdb.PhaseReading{
+			Phase:   p,
+			Power:   status[p+"_act_power"],
+			Voltage: status[p+"_voltage"],
+			Current: status[p+"_current"],
+		})
+	}
+	return phases, nil
+}
+
+// readGen1 reads the emeters of a Gen1 Shelly 3EM.
+func (s shellyEMMeter) readGen1() ([]questdb.PhaseReading, error) {
+	resp, err := plugClient.Get(fmt.Sprintf("http://%s/status", s.host))
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach shelly meter at %s: %w", s.host, err)
+	}
+	defer resp.Body.Close()
+
+	var status struct {
+		Emeters []struct {
+			Power   float64 `json:"power"`
+			Voltage float64 `json:"voltage"`
+			Current float64 `json:"current"`
+		} `json:"emeters"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("failed to decode shelly meter status: %w", err)
+	}
+	if len(status.Emeters) < 3 {
+		return nil, fmt.Errorf("shelly meter %s reports %d phases", s.host, len(status.Emeters))
+	}
+	phases := make([]questdb.PhaseReading, 0, 3)
+	for i, p := range []string{"a", "b", "c"} {
+		e := status.Emeters[i]
+		phases = append(phases, questdb.PhaseReading{Phase: p, Power: e.Power, Voltage: e.Voltage, Current: e.Current})
+	}
+	return phases, nil
+}
+
+// modbusRegisters lists the input registers (32-bit floats, two registers
+// each) of a meter family per phase.
+type modbusRegisters struct {
+	Voltage, Current, Power [3]uint16
+}
+
+var modbusProfiles = map[string]modbusRegisters{
+	// Eastron SDM630 and compatible DIN-rail meters
+	"sdm630": {
+		Voltage: [3]uint16{0x00, 0x02, 0x04},
+		Current: [3]uint16{0x06, 0x08, 0x0a},
+		Power:   [3]uint16{0x0c, 0x0e, 0x10},
+	},
+}
+
+// modbusMeter is a meter read over Modbus TCP (directly or through an
+// RS485 gateway), configured as modbus://<host>[:502]/<unit>?profile=sdm630.
+type modbusMeter struct {
+	addr      string
+	unit      byte
+	registers modbusRegisters
+}
+
+func newModbusMeter(u *url.URL) (roomMeter, error) {
+	m := modbusMeter{addr: u.Host, unit: 1}
+	if _, _, err := net.SplitHostPort(m.addr); err != nil {
+		m.addr = net.JoinHostPort(m.addr, "502")
+	}
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		unit, err := strconv.ParseUint(path, 10, 8)
+		if err != nil {
+			return nil, errors.New("room meter URL path must be the Modbus unit ID")
+		}
+		m.unit = byte(unit)
+	}
+	profile := u.Query().Get("profile")
+	if profile == "" {
+		profile = "sdm630"
+	}
+	registers, ok := modbusProfiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("unknown meter profile %q", profile)
+	}
+	m.registers = registers
+	return m, nil
+}
+
+func (m modbusMeter) Read() ([]questdb.PhaseReading, error) {
+	conn, err := net.DialTimeout("tcp", m.addr, 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach modbus meter at %s: %w", m.addr, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	read := func(register uint16) (float64, error) {
+		words, err := m.readInputRegisters(conn, register, 2)
+		if err != nil {
+			return 0, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(words))), nil
+	}
+	phases := make([]questdb.PhaseReading, 0, 3)
+	for i, p := range []string{"a", "b", "c"} {
+		r := questdb.PhaseReading{Phase: p}
+		if r.Voltage, err = read(m.registers.Voltage[i]); err != nil {
+			return nil, err
+		}
+		if r.Current, err = read(m.registers.Current[i]); err != nil {
+			return nil, err
+		}
+		if r.Power, err = read(m.registers.Power[i]); err != nil {
+			return nil, err
+		}
+		phases = append(phases, r)
+	}
+	return phases, nil
+}
+
+// readInputRegisters sends a Modbus TCP "read input registers" (function 4)
+// request and returns the register bytes.
+func (m modbusMeter) readInputRegisters(conn net.Conn, register, count uint16) ([]byte, error) {
+	req := make([]byte, 12)
+	binary.BigEndian.PutUint16(req[0:], uint16(time.Now().UnixNano())) // transaction ID
+	binary.BigEndian.PutUint16(req[4:], 6)                             // length of unit ID and PDU
+	req[6] = m.unit
+	req[7] = 4
+	binary.BigEndian.PutUint16(req[8:], register)
+	binary.BigEndian.PutUint16(req[10:], count)
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	var header [9]byte // MBAP header, function code, byte count or exception
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return nil, err
+	}
+	if header[0] != req[0] || header[1] != req[1] {
+		return nil, errors.New("modbus transaction ID mismatch")
+	}
+	if header[7] == 4|0x80 {
+		return nil, fmt.Errorf("modbus meter %s returned exception %d", m.addr, header[8])
+	}
+	data := make([]byte, header[8])
+	if _, err := io.ReadFull(conn, data); err != nil {
+		return nil, err
+	}
+	if len(data) != int(count)*2 {
+		return nil, fmt.Errorf("modbus meter %s returned %d bytes", m.addr, len(data))
+	}
+	return data, nil
+}
+
+// roomMeterMonitor tracks how long the meter has exceeded the plugs.
+type roomMeterMonitor struct {
+	mu             sync.Mutex
+	unmeteredSince time.Time
+}
+
+var roomMeterMon = &roomMeterMonitor{}
+
+// runRoomMeter polls the room meter at the given interval and writes its
+// readings to room_meter.
+func runRoomMeter(meter roomMeter, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		phases, err := meter.Read()
+		if err != nil {
+			log.Printf("Room meter: %v", err)
+			continue
+		}
+		total := 0.0
+		fields := make(map[string]interface{})
+		for _, p := range phases {
+			total += p.Power
+			fields["power_"+p.Phase] = p.Power
+			fields["voltage_"+p.Phase] = p.Voltage
+			fields["current_"+p.Phase] = p.Current
+		}
+		fields["power"] = total
+		err = tsStore.Write([]questdb.Point{{
+			Table:   "room_meter",
+			Symbols: map[string]string{"device_id": "room"},
+			Fields:  fields,
+		}})
+		if err != nil {
+			log.Printf("Room meter: failed to write reading: %v", err)
+		}
+		roomMeterMon.evaluate(total)
+	}
+}
+
+// plugsPower returns the sum of the latest plug readings, if recent.
+func plugsPower() (float64, bool) {
+	plugs, err := questdbClient.GetPlugsPower()
+	if err != nil || !plugs.HasData || !isTimestampRecent(plugs.Timestamp, 5*time.Minute) {
+		return 0, false
+	}
+	return plugs.TotalPower, true
+}
+
+// evaluate raises an alert when the meter reads more than --unmetered-watts
+// above the plugs for unmeteredMinutes, e.g. a heater or a miner without a plug.
+func (r *roomMeterMonitor) evaluate(meterPower float64) {
+	const key = "room-meter:unmetered"
+	plugs, ok := plugsPower()
+	if unmeteredWatts <= 0 || !ok || meterPower-plugs < unmeteredWatts {
+		r.mu.Lock()
+		r.unmeteredSince = time.Time{}
+		r.mu.Unlock()
+		alerts.clear(key)
+		return
+	}
+
+	r.mu.Lock()
+	if r.unmeteredSince.IsZero() {
+		r.unmeteredSince = time.Now()
+	}
+	since := r.unmeteredSince
+	r.mu.Unlock()
+	if time.Since(since) >= unmeteredMinutes*time.Minute {
+		alerts.raise(key, severityWarning, "power", fmt.Sprintf(
+			"Room meter reads %.0f W, %.0f W more than the miner plugs (unmetered load?)", meterPower, meterPower-plugs))
+	}
+}
+
+// phaseImbalance is the largest deviation of a phase from the average phase
+// power, in percent of the average.
+func phaseImbalance(phases []questdb.PhaseReading) float64 {
+	if len(phases) == 0 {
+		return 0
+	}
+	avg := 0.0
+	for _, p := range phases {
+		avg += p.Power
+	}
+	avg /= float64(len(phases))
+	if avg <= 0 {
+		return 0
+	}
+	maxDev := 0.0
+	for _, p := range phases {
+		maxDev = math.Max(maxDev, math.Abs(p.Power-avg))
+	}
+	return math.Round(maxDev/avg*1000) / 10
+}
+
+// getPhasesHandler returns the latest room meter reading per phase with the
+// phase imbalance and the power not accounted for by the miner plugs.
+func getPhasesHandler(c *gin.Context) {
+	reading, err := questdbFor(c).GetLatestRoomMeter()
+	if err != nil {
+		log.Printf("Failed to get room meter from QuestDB: %v", err)
+	}
+	if reading == nil {
+		c.JSON(http.StatusOK, gin.H{"hasData": false})
+		return
+	}
+
+	response := gin.H{
+		"timestamp":    reading.Timestamp,
+		"power":        math.Round(reading.Power),
+		"phases":       reading.Phases,
+		"imbalancePct": phaseImbalance(reading.Phases),
+		"stale":        !isTimestampRecentAt(reading.Timestamp, 5*time.Minute, requestNow(c)),
+		"hasData":      true,
+	}
+	if plugs, ok := plugsPower(); ok && !replaying(c) {
+		response["plugsPower"] = math.Round(plugs)
+		response["unmeteredPower"] = math.Round(reading.Power - plugs)
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+var scenarioSecret string // signs the scenario button tokens; empty disables GET /api/scenarios/:name/activate
+
+// defaultScenarios are available unless a stored scenario of the same name
+// replaces them.
+var defaultScenarios = []db.Scenario{
+	{Name: "away", PowerPercent: 80, AlertSeverity: severityWarning, Away: true},
+	{Name: "quiet-night", PowerPercent: 70, QuietHours: "22-7"},
+	{Name: "max-heat", PowerPercent: 120},
+	{Name: "max-profit", PowerPercent: 100},
+}
+
+// scenarioState caches the active scenario, read by the reconciler, the noise
+// policy and notification routing.
+type scenarioState struct {
+	mu     sync.Mutex
+	active *db.Scenario
+	since  time.Time
+	by     string
+}
+
+var scenarios = &scenarioState{}
+
+// load restores the scenario that was active before a restart. A scenario
+// deleted since is dropped.
+func (s *scenarioState) load() error {
+	active, err := database.FetchActiveScenario()
+	if err != nil || active == nil {
+		return err
+	}
+	scenario, err := findScenario(active.Name)
+	if err != nil {
+		return err
+	}
+	if scenario == nil {
+		log.Printf("Active scenario %s no longer exists", active.Name)
+		return database.ClearActiveScenario()
+	}
+	s.set(scenario, active.ActivatedBy, active.ActivatedAt)
+	return nil
+}
+
+func (s *scenarioState) set(scenario *db.Scenario, by string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active, s.by, s.since = scenario, by, at
+}
+
+// current returns the active scenario, or nil.
+func (s *scenarioState) current() *db.Scenario {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active
+}
+
+// name returns the name of the active scenario, empty when none is.
+func (s *scenarioState) name() string {
+	if a := s.current(); a != nil {
+		return a.Name
+	}
+	return ""
+}
+
+// adjustPower scales an Auto power target to the active scenario's percentage.
+func (s *scenarioState) adjustPower(power int) int {
+	a := s.current()
+	if a == nil || a.PowerPercent == 0 {
+		return power
+	}
+	return int(math.Round(float64(power) * float64(a.PowerPercent) / 100))
+}
+
+// quietHours returns the quiet hours in effect: the active scenario's, or
+// --quiet-hours.
+func (s *scenarioState) quietHours() string {
+	if a := s.current(); a != nil && a.QuietHours != "" {
+		return a.QuietHours
+	}
+	return quietHours
+}
+
+// alertSeverity returns the minimum severity the active scenario notifies,
+// empty when the channels' own applies.
+func (s *scenarioState) alertSeverity() string {
+	if a := s.current(); a != nil {
+		return a.AlertSeverity
+	}
+	return ""
+}
+
+// fetchScenarios returns the stored scenarios and the defaults they don't
+// replace, by name.
+func fetchScenarios() ([]db.Scenario, error) {
+	stored, err := database.FetchScenarios()
+	if err != nil {
+		return nil, err
+	}
+	result := stored
+	for _, d := range defaultScenarios {
+		replaced := false
+		for _, s := range stored {
+			replaced = replaced || s.Name == d.Name
+		}
+		if !replaced {
+			result = append(result, d)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// findScenario returns a stored or default scenario by name, or nil.
+func findScenario(name string) (*db.Scenario, error) {
+	s, err := database.FetchScenario(name)
+	if err != nil || s != nil {
+		return s, err
+	}
+	for _, d := range defaultScenarios {
+		if d.Name == name {
+			return &d, nil
+		}
+	}
+	return nil, nil
+}
+
+// validateScenario checks a scenario's settings and that its template and
+// actuators exist.
+func validateScenario(s db.Scenario) error {
+	if s.PowerPercent < 0 || s.PowerPercent > 150 {
+		return fmt.Errorf("powerPercent must be between 0 and 150")
+	}
+	if s.QuietHours != "" {
+		if _, _, err := parseQuietHours(s.QuietHours); err != nil {
+			return fmt.Errorf("invalid quietHours: %w", err)
+		}
+	}
+	if _, ok := severityRank[s.AlertSeverity]; s.AlertSeverity != "" && !ok {
+		return fmt.Errorf("alertSeverity must be warning or critical")
+	}
+	if s.Template != "" {
+		t, err := database.FetchConfigTemplate(s.Template)
+		if err != nil {
+			return fmt.Errorf("failed to fetch config template: %w", err)
+		}
+		if t == nil {
+			return fmt.Errorf("unknown template %s", s.Template)
+		}
+	}
+	for name, mode := range s.Actuators {
+		if mode != "auto" && mode != "on" && mode != "off" {
+			return fmt.Errorf("actuator %s: mode must be auto, on or off", name)
+		}
+		a, err := findActuator(name)
+		if err != nil {
+			return fmt.Errorf("failed to fetch actuators: %w", err)
+		}
+		if a == nil {
+			return fmt.Errorf("unknown actuator %s", name)
+		}
+	}
+	return nil
+}
+
+// ScenarioActivation is the outcome of switching to a scenario.
+type ScenarioActivation struct {
+	Scenario string   `json:"scenario"`
+	JobID    int64    `json:"jobId,omitempty"` // template job
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// activateScenario makes a scenario the active one: its power percentage,
+// quiet hours and alert severity take effect at once, the reconciler
+// re-applies the power targets, actuators are switched and the template is
+// queued for the miners not in maintenance. Failed actuators and a template
+// that can't be queued are warnings; the scenario stays active.
+func activateScenario(s db.Scenario, by string) (ScenarioActivation, error) {
+	result := ScenarioActivation{Scenario: s.Name}
+	if emergency.locked() {
+		return result, fmt.Errorf("emergency lockout active")
+	}
+	if err := validateScenario(s); err != nil {
+		return result, err
+	}
+
+	now := time.Now()
+	if err := database.SetActiveScenario(s.Name, by, now); err != nil {
+		return result, fmt.Errorf("failed to save active scenario: %w", err)
+	}
+	scenarios.set(&s, by, now)
+	recordEvent("scenario", "scenario %s activated by %s", s.Name, by)
+	go reconcile.run()
+
+	names := make([]string, 0, len(s.Actuators))
+	for name := range s.Actuators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := setActuatorMode(name, s.Actuators[name]); err != nil {
+			log.Printf("Scenario %s: failed to set actuator %s %s: %v", s.Name, name, s.Actuators[name], err)
+			result.Warnings = append(result.Warnings, fmt.Sprintf("actuator %s: %v", name, err))
+		}
+	}
+
+	if s.Template != "" {
+		id, err := queueScenarioTemplate(s.Template)
+		if err != nil {
+			log.Printf("Scenario %s: failed to queue template %s: %v", s.Name, s.Template, err)
+			result.Warnings = append(result.Warnings, fmt.Sprintf("template %s: %v", s.Template, err))
+		}
+		result.JobID = id
+	}
+	return result, nil
+}
+
+// setActuatorMode hands an actuator to the thermal controller ("auto") or
+// switches it manually.
+func setActuatorMode(name, mode string) error {
+	a, err := findActuator(name)
+	if err != nil {
+		return err
+	}
+	if a == nil {
+		return fmt.Errorf("unknown actuator")
+	}
+	if err := database.SetActuatorAuto(name, mode == "auto"); err != nil {
+		return err
+	}
+	thermal.forget(name)
+	if mode == "auto" {
+		return nil
+	}
+	return controlShelly(context.Background(), a.ShellyIP, mode == "on")
+}
+
+// queueScenarioTemplate queues a template job for the ASIC miners not in
+// maintenance.
+func queueScenarioTemplate(name string) (int64, error) {
+	t, err := database.FetchConfigTemplate(name)
+	if err != nil {
+		return 0, err
+	}
+	if t == nil {
+		return 0, fmt.Errorf("template not found")
+	}
+	var ips []string
+	for _, m := range currentMachines() {
+		if !driverFor(m.IP).Capabilities().GPU {
+			ips = append(ips, m.IP)
+		}
+	}
+	ips, _ = excludeMaintenance(ips)
+	if len(ips) == 0 {
+		return 0, fmt.Errorf("no miners to apply it to")
+	}
+	return jobs.enqueue("template", TemplateJobParams{Template: *t, IPs: ips}, ips)
+}
+
+// scenarioButtonToken is the token of GET /api/scenarios/:name/activate, an
+// HMAC of the scenario name so each button URL only switches its scenario.
+func scenarioButtonToken(name string) string {
+	mac := hmac.New(sha256.New, []byte(scenarioSecret))
+	mac.Write([]byte("scenario:" + name))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ScenarioRequest saves a scenario.
+type ScenarioRequest struct {
+	Name          string            `json:"name" binding:"required"`
+	PowerPercent  int               `json:"powerPercent"`
+	Template      string            `json:"template"`
+	Actuators     map[string]string `json:"actuators"`
+	QuietHours    string            `json:"quietHours"`
+	AlertSeverity string            `json:"alertSeverity"`
+	Away          bool              `json:"away"`
+}
+
+// getScenariosHandler lists the scenarios and the active one.
+func getScenariosHandler(c *gin.Context) {
+	list, err := fetchScenarios()
+	if err != nil {
+		log.Printf("Failed to fetch scenarios: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scenarios"})
+		return
+	}
+
+	scenarios.mu.Lock()
+	active := gin.H{"name": "", "activatedBy": scenarios.by, "activatedAt": scenarios.since}
+	if scenarios.active != nil {
+		active["name"] = scenarios.active.Name
+	}
+	scenarios.mu.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"scenarios": list,
+		"active":    active,
+		"away":      awayActive(),
+	})
+}
+
+func saveScenarioHandler(c *gin.Context) {
+	var req ScenarioRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s := db.Scenario{
+		Name:          strings.TrimSpace(req.Name),
+		PowerPercent:  req.PowerPercent,
+		Template:      req.Template,
+		Actuators:     req.Actuators,
+		QuietHours:    strings.TrimSpace(req.QuietHours),
+		AlertSeverity: req.AlertSeverity,
+		Away:          req.Away,
+	}
+	if strings.ContainsAny(s.Name, "/? ") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must not contain spaces, / or ?"})
+		return
+	}
+	if err := validateScenario(s); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.SaveScenario(s); err != nil {
+		log.Printf("Failed to save scenario %s: %v", s.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save scenario"})
+		return
+	}
+
+	// Edits of the active scenario apply at once; its actuators and template
+	// wait for the next activation
+	if scenarios.name() == s.Name {
+		scenarios.mu.Lock()
+		scenarios.active = &s
+		scenarios.mu.Unlock()
+		go reconcile.run()
+	}
+
+	recordEvent("scenario", "scenario %s saved from %s", s.Name, c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"scenario": s,
+	})
+}
+
+func deleteScenarioHandler(c *gin.Context) {
+	name := c.Param("name")
+	found, err := database.DeleteScenario(name)
+	if err != nil {
+		log.Printf("Failed to delete scenario %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete scenario"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+		return
+	}
+
+	// A default of the same name takes its place; without one the room
+	// leaves the scenario
+	if scenarios.name() == name {
+		d, _ := findScenario(name)
+		if d == nil {
+			if err := database.ClearActiveScenario(); err != nil {
+				log.Printf("Failed to clear active scenario: %v", err)
+			}
+		}
+		scenarios.mu.Lock()
+		scenarios.active = d
+		scenarios.mu.Unlock()
+		go reconcile.run()
+	}
+
+	recordEvent("scenario", "scenario %s removed from %s", name, c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"name":    name,
+	})
+}
+
+func activateScenarioHandler(c *gin.Context) {
+	respondScenarioActivation(c, c.Param("name"), c.ClientIP())
+}
+
+// scenarioButtonHandler is the GET variant for physical buttons:
+// /api/scenarios/:name/activate?token=<token>.
+func scenarioButtonHandler(c *gin.Context) {
+	if scenarioSecret == "" {
+		render404(c)
+		return
+	}
+	name := c.Param("name")
+	if !hmac.Equal([]byte(c.Query("token")), []byte(scenarioButtonToken(name))) {
+		recordEvent("scenario", "rejected scenario %s button from %s: invalid token", name, c.ClientIP())
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid token"})
+		return
+	}
+	respondScenarioActivation(c, name, "button ("+c.ClientIP()+")")
+}
+
+func respondScenarioActivation(c *gin.Context, name, by string) {
+	s, err := findScenario(name)
+	if err != nil {
+		log.Printf("Failed to fetch scenario %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scenario"})
+		return
+	}
+	if s == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+		return
+	}
+
+	result, err := activateScenario(*s, by)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"activation": result,
+	})
+}
+
+// deactivateScenarioHandler leaves the active scenario: power targets, quiet
+// hours and alert routing return to their configured values. Actuators and
+// miner configs set by the scenario stay as they are.
+func deactivateScenarioHandler(c *gin.Context) {
+	name := scenarios.name()
+	if name == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no scenario is active"})
+		return
+	}
+	if err := database.ClearActiveScenario(); err != nil {
+		log.Printf("Failed to clear active scenario: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear active scenario"})
+		return
+	}
+	scenarios.set(nil, "", time.Time{})
+	recordEvent("scenario", "scenario %s deactivated from %s", name, c.ClientIP())
+	go reconcile.run()
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"name":    name,
+	})
+}
+
+// getScenarioButtonHandler returns the activation URL to configure on a
+// button.
+func getScenarioButtonHandler(c *gin.Context) {
+	if scenarioSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "--scenario-secret is not configured"})
+		return
+	}
+	name := c.Param("name")
+	c.JSON(http.StatusOK, gin.H{
+		"path": "/api/scenarios/" + name + "/activate?token=" + scenarioButtonToken(name),
+	})
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"miningRoom/db"
+	"miningRoom/questdb"
+)
+
+// Environment sensors can fail without going quiet: a hung sensor keeps
+// repeating its last reading, a failing one reports values no room can
+// produce. The location monitor flags such locations as suspect; suspect
+// locations are left out of indoorLocations, outdoorLocations and
+// airflowLocations, and so of the room temperature, thermal insulation,
+// condensation, degree day and airflow calculations, until the readings of
+// the last --sensor-stuck-minutes look sane again.
+
+var sensorStuckMinutes int // --sensor-stuck-minutes: 0 disables the check
+
+const (
+	// sensorMinStuckReadings is the number of identical readings, spanning
+	// at least half of --sensor-stuck-minutes, that make a sensor stuck.
+	sensorMinStuckReadings = 10
+
+	// A change larger than these between readings at most sensorJumpWindow
+	// apart is not something room air does.
+	sensorJumpWindow      = 5 * time.Minute
+	sensorMaxTempJump     = 5.0  // °C
+	sensorMaxHumidityJump = 25.0 // %RH
+	sensorMaxPressureJump = 10.0 // hPa
+)
+
+// sensorRange is the measuring range of a BME280 field; readings outside it
+// are impossible.
+type sensorRange struct {
+	name, unit string
+	min, max   float64
+	maxJump    float64
+	value      func(questdb.EnvironmentSample) *float64
+}
+
+var sensorRanges = []sensorRange{
+	{"temperature", "°C", -40, 85, sensorMaxTempJump, func(s questdb.EnvironmentSample) *float64 { return s.Temperature }},
+	{"humidity", "%RH", 0, 100, sensorMaxHumidityJump, func(s questdb.EnvironmentSample) *float64 { return s.Humidity }},
+	{"pressure", "hPa", 300, 1100, sensorMaxPressureJump, func(s questdb.EnvironmentSample) *float64 { return s.Pressure }},
+}
+
+// SensorSuspect is a location whose readings are not trusted.
+type SensorSuspect struct {
+	Location string    `json:"location"`
+	Reason   string    `json:"reason"`
+	Since    time.Time `json:"since"`
+}
+
+// sensorAnomalyMonitor keeps the suspect locations between checks.
+type sensorAnomalyMonitor struct {
+	mu       sync.Mutex
+	suspects map[string]*SensorSuspect
+}
+
+var sensorAnomalies = &sensorAnomalyMonitor{suspects: make(map[string]*SensorSuspect)}
+
+// suspect reports whether a location's readings are currently not trusted.
+func (m *sensorAnomalyMonitor) suspect(location string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.suspects[location]
+	return ok
+}
+
+// get returns the suspicion about a location, or nil.
+func (m *sensorAnomalyMonitor) get(location string) *SensorSuspect {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.suspects[location]; ok {
+		suspect := *s
+		return &suspect
+	}
+	return nil
+}
+
+// sensorAnomaly returns why the samples of a location, oldest first, look
+// broken, or "" when they look sane.
+func sensorAnomaly(samples []questdb.EnvironmentSample, window time.Duration) string {
+	for _, r := range sensorRanges {
+		for _, s := range samples {
+			if v := r.value(s); v != nil && (*v < r.min || *v > r.max || math.IsNaN(*v)) {
+				return fmt.Sprintf("impossible %s %.1f %s at %s", r.name, *v, r.unit, s.Timestamp.UTC().Format(time.RFC3339))
+			}
+		}
+	}
+
+	for _, r := range sensorRanges {
+		for i := 1; i < len(samples); i++ {
+			prev, cur := r.value(samples[i-1]), r.value(samples[i])
+			if prev == nil || cur == nil || samples[i].Timestamp.Sub(samples[i-1].Timestamp) > sensorJumpWindow {
+				continue
+			}
+			if math.Abs(*cur-*prev) > r.maxJump {
+				return fmt.Sprintf("%s jumped from %.1f to %.1f %s at %s", r.name, *prev, *cur, r.unit, samples[i].Timestamp.UTC().Format(time.RFC3339))
+			}
+		}
+	}
+
+	if len(samples) < sensorMinStuckReadings || samples[len(samples)-1].Timestamp.Sub(samples[0].Timestamp) < window/2 {
+		return ""
+	}
+	for _, r := range sensorRanges {
+		first := r.value(samples[0])
+		for _, s := range samples[1:] {
+			v := r.value(s)
+			if (first == nil) != (v == nil) || (v != nil && *v != *first) {
+				return ""
+			}
+		}
+	}
+	if samples[0].Temperature == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d identical readings since %s", len(samples), samples[0].Timestamp.UTC().Format(time.RFC3339))
+}
+
+// check updates the suspect locations from the readings of the last window
+// and raises or clears their alerts.
+func (m *sensorAnomalyMonitor) check(locations []db.SensorLocation, samples map[string][]questdb.EnvironmentSample, window time.Duration, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[string]bool)
+	for _, l := range locations {
+		seen[l.Name] = true
+		key := "sensor-suspect:" + l.Name
+		reason := sensorAnomaly(samples[l.Name], window)
+		if reason == "" {
+			if _, ok := m.suspects[l.Name]; ok {
+				delete(m.suspects, l.Name)
+				alerts.clear(key)
+			}
+			continue
+		}
+		if s, ok := m.suspects[l.Name]; ok {
+			s.Reason = reason
+		} else {
+			m.suspects[l.Name] = &SensorSuspect{Location: l.Name, Reason: reason, Since: now}
+		}
+		alerts.raise(key, severityWarning, "sensors", fmt.Sprintf(
+			"Sensor at %s is suspect: %s; left out of room averages and thermal calculations", locationLabel(l), reason))
+	}
+	for name := range m.suspects {
+		if !seen[name] {
+			delete(m.suspects, name)
+			alerts.clear("sensor-suspect:" + name)
+		}
+	}
+}
+
+// checkSensorAnomalies reads the last --sensor-stuck-minutes of readings,
+// including those --outlier-rules hides, and updates the suspect locations.
+func checkSensorAnomalies(now time.Time) {
+	if sensorStuckMinutes <= 0 {
+		return
+	}
+	window := time.Duration(sensorStuckMinutes) * time.Minute
+	samples, err := questdbClient.WithValidRanges(nil).GetEnvironmentSamples(now.Add(-window))
+	if err != nil {
+		log.Printf("Location monitor: failed to get environment samples: %v", err)
+		return
+	}
+	sensorAnomalies.check(configuredLocations(), samples, window, now)
+}
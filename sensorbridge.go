@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+
+	"miningRoom/questdb"
+	"miningRoom/questdb/schema"
+)
+
+// Sensor bridge settings. Zigbee2MQTT publishes device state on
+// zigbee2mqtt/<friendly name>; BLE gateways such as Theengs or OpenMQTTGateway
+// publish Xiaomi/SwitchBot advertisements on <base>/BTtoMQTT/<MAC>.
+var (
+	sensorTopics    string // comma-separated MQTT topic filters
+	sensorLocations string // comma-separated device=location pairs
+)
+
+// sensorReadingKeys are the payload keys read per quantity, in order of
+// preference (Zigbee2MQTT names first, then Theengs/OMG names).
+var sensorReadingKeys = map[string][]string{
+	"temperature": {"temperature", "tempc"},
+	"humidity":    {"humidity", "hum"},
+	"pressure":    {"pressure"},
+}
+
+// sensorLocation returns the location mapped to a bridged device in
+// --sensor-locations, or the device ID itself.
+func sensorLocation(device string) string {
+	for _, pair := range splitList(sensorLocations) {
+		if d, location, ok := strings.Cut(pair, "="); ok && strings.EqualFold(strings.TrimSpace(d), device) {
+			return strings.TrimSpace(location)
+		}
+	}
+	return device
+}
+
+// runSensorBridge stores readings of MQTT-bridged thermometers in
+// bme280_readings so they show up as extra environment locations, door or
+// window contacts in contact_sensors and smoke/CO detectors in smoke_alarms.
+func runSensorBridge() {
+	runMQTTSubscriber("sensors", splitList(sensorTopics), func(topic string, payload []byte) {
+		points := parseSensorMessage(topic, payload)
+		if len(points) == 0 {
+			return
+		}
+		checkSmokeAlarms(points)
+		if err := tsStore.Write(points); err != nil {
+			log.Printf("Sensor bridge: failed to write reading from %s: %v", topic, err)
+		}
+	})
+}
+
+// parseSensorMessage converts a bridged sensor message to bme280_readings,
+// contact_sensors, smoke_alarms and sound_levels points. The device is the "id" field (BLE gateways) or the
+// last topic level (Zigbee2MQTT friendly name).
+func parseSensorMessage(topic string, payload []byte) []questdb.Point {
+	if strings.Contains(topic, "/bridge/") || strings.HasSuffix(topic, "/availability") {
+		return nil
+	}
+	var msg map[string]interface{}
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return nil
+	}
+
+	device, _ := msg["id"].(string)
+	if device == "" {
+		device = topic[strings.LastIndex(topic, "/")+1:]
+	}
+	location := sensorLocation(device)
+
+	var points []questdb.Point
+	// Zigbee2MQTT contact sensors report contact=true while closed
+	if contact, ok := msg["contact"].(bool); ok {
+		points = append(points, contactPoint(device, location, !contact))
+	}
+	if smoke, ok := msg["smoke"].(bool); ok {
+		points = append(points, smokePoint(device, location, "smoke", smoke))
+	}
+	if co, ok := msg["carbon_monoxide"].(bool); ok {
+		points = append(points, smokePoint(device, location, "co", co))
+	}
+	if level, ok := msg["noise"].(float64); ok {
+		points = append(points, questdb.Point{
+			Table:   "sound_levels",
+			Symbols: map[string]string{"sensor_id": device, "location": location},
+			Fields:  map[string]interface{}{"db": level},
+		})
+	}
+
+	reading := func(quantity string) *float64 {
+		for _, k := range sensorReadingKeys[quantity] {
+			if v, ok := msg[k].(float64); ok {
+				return &v
+			}
+		}
+		return nil
+	}
+	row := schema.BME280Reading{
+		DeviceID:    device,
+		Location:    location,
+		Temperature: reading("temperature"),
+		Humidity:    reading("humidity"),
+		Pressure:    reading("pressure"),
+	}
+	if row.Temperature != nil {
+		if point, err := questdb.PointOf(row); err == nil {
+			points = append(points, point)
+		}
+	}
+	return points
+}
+
+// contactPoint is a contact_sensors reading.
+func contactPoint(sensorID, location string, open bool) questdb.Point {
+	return questdb.Point{
+		Table:   "contact_sensors",
+		Symbols: map[string]string{"sensor_id": sensorID, "location": location},
+		Fields:  map[string]interface{}{"open": open},
+	}
+}
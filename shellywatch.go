@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"miningRoom/db"
+	"miningRoom/questdb"
+	"miningRoom/questdb/schema"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Shelly watcher settings
+var (
+	shellyPollSeconds  int
+	unreachableMinutes int
+)
+
+// ShellyState is the last polled state of a miner's Shelly relay.
+type ShellyState struct {
+	MinerIP      string    `json:"minerIp"`
+	Name         string    `json:"name"`
+	ShellyIP     string    `json:"shellyIp"`
+	Outlet       string    `json:"outlet,omitempty"` // PDU or plug outlet instead of a Shelly
+	Reachable    bool      `json:"reachable"`        // the Shelly answered
+	On           bool      `json:"on"`
+	Power        float64   `json:"power"` // W
+	MinerOnline  bool      `json:"minerOnline"`
+	OfflineSince time.Time `json:"offlineSince,omitempty"` // relay on, miner unreachable
+	Requested    *bool     `json:"requested,omitempty"`    // last relay state set by this server
+	Error        string    `json:"error,omitempty"`
+	CheckedAt    time.Time `json:"checkedAt"`
+}
+
+// shellyWatcher polls miner Shellies and other outlets in the background and
+// caches their state, raising alerts when a relay and its miner disagree.
+type shellyWatcher struct {
+	mu        sync.Mutex
+	states    map[string]ShellyState // by miner IP
+	requested map[string]bool        // by Shelly IP or outlet key
+}
+
+var shellyWatch = &shellyWatcher{
+	states:    make(map[string]ShellyState),
+	requested: make(map[string]bool),
+}
+
+// request records the relay state last asked for through controlShelly.
+func (w *shellyWatcher) request(shellyIP string, on bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.requested[shellyIP] = on
+}
+
+// requestedState returns the relay state last asked for, if any.
+func (w *shellyWatcher) requestedState(shellyIP string) (on, ok bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	on, ok = w.requested[shellyIP]
+	return on, ok
+}
+
+// runShellyWatcher polls every miner Shelly at the given interval.
+func runShellyWatcher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		shellyWatch.poll()
+	}
+}
+
+func (w *shellyWatcher) poll() {
+	machines := currentMachines()
+	results := make([]ShellyState, 0, len(machines))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var points []questdb.Point
+	for _, m := range machines {
+		outlet, err := outletFor(m)
+		if err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(m db.Machine, outlet outletController) {
+			defer wg.Done()
+			state, point := w.check(m, outlet)
+			mu.Lock()
+			results = append(results, state)
+			if point != nil {
+				points = append(points, *point)
+			}
+			mu.Unlock()
+		}(m, outlet)
+	}
+	wg.Wait()
+
+	if len(points) > 0 {
+		if err := tsStore.Write(points); err != nil {
+			log.Printf("Shelly watcher: failed to write outlet power: %v", err)
+		}
+	}
+
+	w.mu.Lock()
+	states := make(map[string]ShellyState, len(results))
+	for _, s := range results {
+		// Keep counting unreachable time across polls
+		if prev, ok := w.states[s.MinerIP]; ok && !s.OfflineSince.IsZero() && !prev.OfflineSince.IsZero() {
+			s.OfflineSince = prev.OfflineSince
+		}
+		if requested, ok := w.requested[s.key()]; ok {
+			s.Requested = &requested
+		}
+		states[s.MinerIP] = s
+	}
+	w.states = states
+	w.mu.Unlock()
+
+	for _, s := range states {
+		w.evaluate(s)
+	}
+}
+
+// key returns the outlet key relay requests are recorded under.
+func (s ShellyState) key() string {
+	if s.Outlet != "" {
+		return s.Outlet
+	}
+	return s.ShellyIP
+}
+
+// check reads the relay and, while it is on, whether the miner answers.
+// Shellies push their own readings; the power of other metered outlets is
+// returned as a shellies point.
+func (w *shellyWatcher) check(m db.Machine, outlet outletController) (ShellyState, *questdb.Point) {
+	state := ShellyState{
+		MinerIP:   m.IP,
+		Name:      m.Name,
+		ShellyIP:  m.ShellyIP,
+		CheckedAt: time.Now(),
+	}
+	shelly := isShellyOutlet(outlet)
+	if !shelly {
+		state.Outlet = outlet.Key()
+	}
+	status, err := outlet.Status(context.Background())
+	if err != nil {
+		state.Error = err.Error()
+		return state, nil
+	}
+	state.Reachable = true
+	state.On = status.On
+	state.Power = status.Power
+	// Caches the device ID so pushed notifications can be matched to the miner
+	deviceID, _ := outlet.DeviceID()
+
+	var point *questdb.Point
+	if !shelly && status.Metered {
+		row := schema.Shelly{
+			Timestamp: state.CheckedAt,
+			DeviceID:  deviceID,
+			Power:     &status.Power,
+			Output:    &status.On,
+		}
+		if status.Voltage > 0 {
+			row.Voltage = &status.Voltage
+		}
+		if status.Current > 0 {
+			row.Current = &status.Current
+		}
+		if p, err := questdb.PointOf(row); err == nil {
+			point = &p
+		}
+	}
+
+	if state.On {
+		if _, err := driverFor(m.IP).Config(context.Background(), m.IP); err == nil {
+			state.MinerOnline = true
+		} else {
+			state.OfflineSince = state.CheckedAt
+		}
+	}
+	return state, point
+}
+
+// evaluate raises or clears the desync alerts of one miner.
+func (w *shellyWatcher) evaluate(s ShellyState) {
+	idleKey := "shelly-idle:" + s.MinerIP
+	offKey := "shelly-off:" + s.MinerIP
+	if inMaintenance(s.MinerIP) {
+		alerts.clear(idleKey)
+		alerts.clear(offKey)
+		return
+	}
+	if !s.Reachable {
+		return
+	}
+
+	limit := time.Duration(unreachableMinutes) * time.Minute
+	if s.On && !s.OfflineSince.IsZero() && time.Since(s.OfflineSince) >= limit {
+		alerts.raise(idleKey, severityWarning, "shelly", fmt.Sprintf(
+			"%s: relay on (%.0f W) but miner %s for %s", s.Name, s.Power, presence.describe(s.MinerIP), time.Since(s.OfflineSince).Round(time.Minute)))
+	} else {
+		alerts.clear(idleKey)
+	}
+
+	if !s.On && s.Requested != nil && *s.Requested {
+		alerts.raise(offKey, severityWarning, "shelly", fmt.Sprintf(
+			"%s: relay off although a start was requested", s.Name))
+	} else {
+		alerts.clear(offKey)
+	}
+}
+
+// list returns the cached states in machine order.
+func (w *shellyWatcher) list() []ShellyState {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	result := make([]ShellyState, 0, len(w.states))
+	for _, m := range currentMachines() {
+		if s, ok := w.states[m.IP]; ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func getShellyStatesHandler(c *gin.Context) {
+	states := shellyWatch.list()
+	c.JSON(http.StatusOK, gin.H{
+		"shellies": states,
+		"hasData":  len(states) > 0,
+	})
+}
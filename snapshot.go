@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SnapshotRequest is the body of POST /api/snapshot.
+type SnapshotRequest struct {
+	Label string `json:"label"`
+}
+
+// snapshotSources lists what a room snapshot captures: the miners' configs
+// and pools, the outlets, the latest metrics, active alerts and the settings
+// stored in SQLite. Secrets (SSH credentials, notification tokens, owner
+// passwords, the TOTP secret, notification targets) are left out.
+func snapshotSources() []manageSource {
+	settings := func(name string, fetch func() (interface{}, error)) manageSource {
+		return manageSource{name: name, timeout: manageSourceTimeout, fetch: func(context.Context) (interface{}, error) {
+			return fetch()
+		}}
+	}
+	metric := func(name string, fetch func(TimeseriesStore) (interface{}, error)) manageSource {
+		return manageSource{name: name, timeout: manageSourceTimeout, fetch: func(ctx context.Context) (interface{}, error) {
+			return fetch(storeWith(ctx))
+		}}
+	}
+	return []manageSource{
+		{name: "minerConfigs", timeout: manageMinerTimeout + time.Second, fetch: func(ctx context.Context) (interface{}, error) {
+			return manageMinerConfigs(ctx), nil
+		}},
+		{name: "minerPools", timeout: manageMinerTimeout + time.Second, fetch: func(ctx context.Context) (interface{}, error) {
+			return snapshotMinerPools(ctx), nil
+		}},
+		{name: "shellies", timeout: manageSourceTimeout, fetch: func(context.Context) (interface{}, error) {
+			return shellyWatch.list(), nil
+		}},
+		{name: "alerts", timeout: manageSourceTimeout, fetch: func(context.Context) (interface{}, error) {
+			return alerts.list(), nil
+		}},
+
+		metric("totalHashrate", func(s TimeseriesStore) (interface{}, error) { return s.GetTotalHashrate() }),
+		metric("totalPower", func(s TimeseriesStore) (interface{}, error) { return s.GetTotalPower() }),
+		metric("maxTemperature", func(s TimeseriesStore) (interface{}, error) { return s.GetMaxTemperature() }),
+		metric("roomTemperature", func(s TimeseriesStore) (interface{}, error) { return s.GetRoomTemperature(indoorLocations()) }),
+		metric("minerStatuses", func(s TimeseriesStore) (interface{}, error) { return s.GetMinerStatuses() }),
+		metric("shelliesPower", func(s TimeseriesStore) (interface{}, error) { return s.GetShelliesPower() }),
+		metric("hashboardsDetailed", func(s TimeseriesStore) (interface{}, error) { return s.GetHashboardsDetailed() }),
+
+		settings("machines", func() (interface{}, error) { return database.FetchMachines() }),
+		settings("groups", func() (interface{}, error) { return database.FetchGroups() }),
+		settings("coolingLoops", func() (interface{}, error) { return database.FetchCoolingLoops() }),
+		settings("actuators", func() (interface{}, error) { return database.FetchActuators() }),
+		settings("desiredStates", func() (interface{}, error) { return database.FetchDesiredStates() }),
+		settings("gauges", func() (interface{}, error) { return database.FetchGauges() }),
+		settings("sensorLocations", func() (interface{}, error) { return database.FetchSensorLocations() }),
+		settings("notifyChannels", func() (interface{}, error) {
+			channels, err := database.FetchNotifyChannels()
+			for i := range channels {
+				channels[i].Target = "" // webhook URLs carry their credentials
+			}
+			return channels, err
+		}),
+		settings("hostingOwners", func() (interface{}, error) { return database.FetchHostingOwners() }),
+		settings("emergencyLockout", func() (interface{}, error) { return database.FetchLockout() }),
+		settings("ipBans", func() (interface{}, error) { return database.FetchIPBans() }),
+	}
+}
+
+// snapshotMinerPools reads the pools of every miner, keyed by IP. Miners that
+// don't answer are left out.
+func snapshotMinerPools(ctx context.Context) map[string][]PoolInfo {
+	machines := currentMachines()
+	pools := make(map[string][]PoolInfo, len(machines))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, m := range machines {
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			minerCtx, cancel := context.WithTimeout(ctx, manageMinerTimeout)
+			defer cancel()
+			p, err := driverFor(ip).Pools(minerCtx, ip)
+			if err != nil {
+				log.Printf("Snapshot: failed to read pools of %s: %v", ip, err)
+				return
+			}
+			mu.Lock()
+			pools[ip] = p
+			mu.Unlock()
+		}(m.IP)
+	}
+	wg.Wait()
+	return pools
+}
+
+// createSnapshotHandler captures the room's state into one JSON document and
+// archives it. Sections that fail are listed in the document's errors
+// instead of failing the snapshot.
+func createSnapshotHandler(c *gin.Context) {
+	var req SnapshotRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	takenAt := time.Now().UTC()
+	data, errs := fetchManageSources(c.Request.Context(), snapshotSources())
+	doc := gin.H{"takenAt": takenAt, "label": req.Label, "errors": errs}
+	for name, v := range data {
+		doc[name] = v
+	}
+	document, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		log.Printf("Failed to encode snapshot: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode snapshot"})
+		return
+	}
+
+	s, err := database.AddSnapshot(req.Label, takenAt, document)
+	if err != nil {
+		log.Printf("Failed to save snapshot: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save snapshot"})
+		return
+	}
+	log.Printf("Took snapshot %d (%s, %d bytes, %d sections failed)", s.ID, s.Label, s.Size, len(errs))
+	c.JSON(http.StatusOK, gin.H{"success": true, "snapshot": s, "errors": errs})
+}
+
+func getSnapshotsHandler(c *gin.Context) {
+	snapshots, err := database.FetchSnapshots()
+	if err != nil {
+		log.Printf("Failed to fetch snapshots: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch snapshots"})
+		return
+	}
+	if snapshots == nil {
+		snapshots = []db.Snapshot{}
+	}
+	c.JSON(http.StatusOK, gin.H{"snapshots": snapshots})
+}
+
+// downloadSnapshotHandler sends a snapshot's document as a JSON file.
+func downloadSnapshotHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid snapshot id"})
+		return
+	}
+	s, err := database.FetchSnapshot(id)
+	if err != nil {
+		log.Printf("Failed to fetch snapshot %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch snapshot"})
+		return
+	}
+	if s == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "snapshot not found"})
+		return
+	}
+	name := fmt.Sprintf("snapshot-%d-%s.json", s.ID, s.CreatedAt.UTC().Format("20060102-150405"))
+	c.Header("Content-Disposition", "attachment; filename="+name)
+	c.Data(http.StatusOK, "application/json", s.Document)
+}
+
+func deleteSnapshotHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid snapshot id"})
+		return
+	}
+	found, err := database.DeleteSnapshot(id)
+	if err != nil {
+		log.Printf("Failed to delete snapshot %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete snapshot"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "snapshot not found"})
+		return
+	}
+	log.Printf("Deleted snapshot %d", id)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
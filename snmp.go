@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Minimal SNMPv2c client: GET and SET of single integer/gauge variables, which
+// is all PDU outlet control needs.
+
+const (
+	snmpTimeout = 3 * time.Second
+	snmpRetries = 2
+
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berNull        = 0x05
+	berOID         = 0x06
+	berSequence    = 0x30
+	berCounter32   = 0x41
+	berGauge32     = 0x42
+	berTimeTicks   = 0x43
+	berNoSuchObj   = 0x80
+	berNoSuchInst  = 0x81
+	berEndOfView   = 0x82
+	pduGetRequest  = 0xa0
+	pduGetResponse = 0xa2
+	pduSetRequest  = 0xa3
+)
+
+// errSNMPNoSuchName is returned for variables the agent doesn't have.
+var errSNMPNoSuchName = errors.New("no such object")
+
+// snmpGet reads an integer variable.
+func snmpGet(ctx context.Context, addr, community, oid string) (int64, error) {
+	return snmpRequest(ctx, addr, community, pduGetRequest, oid, nil)
+}
+
+// snmpSet writes an integer variable.
+func snmpSet(ctx context.Context, addr, community, oid string, value int64) error {
+	_, err := snmpRequest(ctx, addr, community, pduSetRequest, oid, &value)
+	return err
+}
+
+func snmpRequest(ctx context.Context, addr, community string, pduType byte, oid string, value *int64) (int64, error) {
+	encodedOID, err := berEncodeOID(oid)
+	if err != nil {
+		return 0, err
+	}
+	val := []byte{berNull, 0}
+	if value != nil {
+		val = berTLV(berInteger, berEncodeInt(*value))
+	}
+	reqID := rand.Int31()
+	varbind := berTLV(berSequence, append(berTLV(berOID, encodedOID), val...))
+	pdu := berTLV(pduType, concat(
+		berTLV(berInteger, berEncodeInt(int64(reqID))),
+		berTLV(berInteger, []byte{0}), // error-status
+		berTLV(berInteger, []byte{0}), // error-index
+		berTLV(berSequence, varbind),
+	))
+	packet := berTLV(berSequence, concat(
+		berTLV(berInteger, []byte{1}), // version 2c
+		berTLV(berOctetString, []byte(community)),
+		pdu,
+	))
+
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "161")
+	}
+	conn, err := dialContext(ctx, "udp", addr, snmpTimeout*(snmpRetries+1))
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 65535)
+	for attempt := 0; attempt <= snmpRetries; attempt++ {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		if _, err = conn.Write(packet); err != nil {
+			return 0, err
+		}
+		conn.SetReadDeadline(ctxDeadline(ctx, snmpTimeout))
+		var n int
+		n, err = conn.Read(buf)
+		if err != nil {
+			continue
+		}
+		var id int64
+		var result int64
+		id, result, err = parseSNMPResponse(buf[:n])
+		if err == nil && id != int64(reqID) {
+			// Late answer to an earlier attempt
+			continue
+		}
+		return result, err
+	}
+	return 0, fmt.Errorf("snmp %s: %w", addr, err)
+}
+
+// parseSNMPResponse returns the request ID and the integer value of the first
+// variable of a GetResponse.
+func parseSNMPResponse(b []byte) (reqID, value int64, err error) {
+	msg, _, err := berRead(b, berSequence)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, msg, err = berRead(msg, berInteger); err != nil { // version
+		return 0, 0, err
+	}
+	if _, msg, err = berRead(msg, berOctetString); err != nil { // community
+		return 0, 0, err
+	}
+	pdu, _, err := berRead(msg, pduGetResponse)
+	if err != nil {
+		return 0, 0, err
+	}
+	var field []byte
+	if field, pdu, err = berRead(pdu, berInteger); err != nil {
+		return 0, 0, err
+	}
+	reqID = berDecodeInt(field)
+	if field, pdu, err = berRead(pdu, berInteger); err != nil {
+		return 0, 0, err
+	}
+	if status := berDecodeInt(field); status != 0 {
+		return reqID, 0, fmt.Errorf("agent returned error status %d", status)
+	}
+	if _, pdu, err = berRead(pdu, berInteger); err != nil { // error-index
+		return 0, 0, err
+	}
+	varbinds, _, err := berRead(pdu, berSequence)
+	if err != nil {
+		return 0, 0, err
+	}
+	varbind, _, err := berRead(varbinds, berSequence)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, varbind, err = berRead(varbind, berOID); err != nil {
+		return 0, 0, err
+	}
+	if len(varbind) < 2 {
+		return reqID, 0, errors.New("truncated value")
+	}
+	switch varbind[0] {
+	case berInteger, berCounter32, berGauge32, berTimeTicks:
+		field, _, err = berRead(varbind, varbind[0])
+		return reqID, berDecodeInt(field), err
+	case berNoSuchObj, berNoSuchInst, berEndOfView:
+		return reqID, 0, errSNMPNoSuchName
+	}
+	return reqID, 0, fmt.Errorf("unsupported value type 0x%02x", varbind[0])
+}
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// berTLV encodes a tag-length-value element.
+func berTLV(tag byte, value []byte) []byte {
+	out := []byte{tag}
+	switch n := len(value); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n < 0x100:
+		out = append(out, 0x81, byte(n))
+	default:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	}
+	return append(out, value...)
+}
+
+// berRead returns the value of the element at the start of b, which must have
+// the given tag, and the bytes after it.
+func berRead(b []byte, tag byte) (value, rest []byte, err error) {
+	if len(b) < 2 {
+		return nil, nil, errors.New("truncated element")
+	}
+	if b[0] != tag {
+		return nil, nil, fmt.Errorf("expected tag 0x%02x, got 0x%02x", tag, b[0])
+	}
+	length, offset := int(b[1]), 2
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 2 || len(b) < 2+n {
+			return nil, nil, errors.New("bad length")
+		}
+		length = 0
+		for _, c := range b[2 : 2+n] {
+			length = length<<8 | int(c)
+		}
+		offset += n
+	}
+	if len(b) < offset+length {
+		return nil, nil, errors.New("truncated element")
+	}
+	return b[offset : offset+length], b[offset+length:], nil
+}
+
+func berEncodeInt(v int64) []byte {
+	var out []byte
+	for {
+		out = append([]byte{byte(v)}, out...)
+		v >>= 8
+		if (v == 0 && out[0]&0x80 == 0) || (v == -1 && out[0]&0x80 != 0) {
+			return out
+		}
+	}
+}
+
+func berDecodeInt(b []byte) int64 {
+	var v int64
+	for i, c := range b {
+		if i == 0 && c&0x80 != 0 {
+			v = -1
+		}
+		v = v<<8 | int64(c)
+	}
+	return v
+}
+
+// berEncodeOID encodes a dotted OID such as 1.3.6.1.2.1.1.3.0.
+func berEncodeOID(oid string) ([]byte, error) {
+	parts := strings.Split(strings.TrimPrefix(oid, "."), ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid OID %q", oid)
+	}
+	nums := make([]uint64, len(parts))
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OID %q", oid)
+		}
+		nums[i] = n
+	}
+	out := []byte{byte(nums[0]*40 + nums[1])}
+	for _, n := range nums[2:] {
+		chunk := []byte{byte(n & 0x7f)}
+		for n >>= 7; n > 0; n >>= 7 {
+			chunk = append([]byte{byte(n&0x7f) | 0x80}, chunk...)
+		}
+		out = append(out, chunk...)
+	}
+	return out, nil
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/ssh"
+)
+
+// Default SSH login for miners without stored credentials.
+var (
+	sshUser    string
+	sshPass    string
+	sshKeyPath string
+)
+
+const (
+	sshTimeout   = 30 * time.Second
+	sshMaxOutput = 64 << 10
+)
+
+// sshCommand is a whitelisted shell command. Only commands listed by a driver's
+// SSHCommands can be run; arbitrary input never reaches the shell.
+type sshCommand struct {
+	Command     string `json:"-"`
+	Description string `json:"description"`
+	Destructive bool   `json:"destructive"` // requires a 2FA code once enrolled
+	// Arg names an optional integer argument substituted for %d in Command.
+	Arg    string `json:"arg,omitempty"`
+	ArgMin int    `json:"argMin,omitempty"`
+	ArgMax int    `json:"argMax,omitempty"`
+}
+
+// render returns the shell command line with the argument substituted.
+func (c sshCommand) render(arg *int) (string, error) {
+	if c.Arg == "" {
+		return c.Command, nil
+	}
+	if arg == nil {
+		return "", fmt.Errorf("%s required", c.Arg)
+	}
+	if *arg < c.ArgMin || *arg > c.ArgMax {
+		return "", fmt.Errorf("%s must be between %d and %d", c.Arg, c.ArgMin, c.ArgMax)
+	}
+	return fmt.Sprintf(c.Command, *arg), nil
+}
+
+// sshClientConfig builds the login for a miner from its stored credentials,
+// falling back to the --ssh-* defaults. The host key is pinned on first use.
+func sshClientConfig(ip string) (*ssh.ClientConfig, string, error) {
+	creds, err := database.FetchSSHCredential(ip)
+	if err != nil {
+		return nil, "", err
+	}
+	if creds == nil {
+		creds = &db.SSHCredentials{MachineIP: ip}
+	}
+
+	user, port := creds.User, creds.Port
+	if user == "" {
+		user = sshUser
+	}
+	if port == 0 {
+		port = 22
+	}
+
+	var auth []ssh.AuthMethod
+	key := []byte(creds.PrivateKey)
+	if len(key) == 0 && creds.Password == "" && sshKeyPath != "" {
+		if key, err = os.ReadFile(sshKeyPath); err != nil {
+			return nil, "", fmt.Errorf("failed to read SSH key: %w", err)
+		}
+	}
+	if len(key) > 0 {
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to parse SSH key: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	password := creds.Password
+	if password == "" && creds.PrivateKey == "" {
+		password = sshPass
+	}
+	if password != "" {
+		auth = append(auth, ssh.Password(password))
+	}
+	if len(auth) == 0 {
+		return nil, "", errors.New("no SSH password or key configured")
+	}
+
+	pinned := creds.HostKey
+	config := &ssh.ClientConfig{
+		User:    user,
+		Auth:    auth,
+		Timeout: 10 * time.Second,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			fingerprint := ssh.FingerprintSHA256(key)
+			if pinned == "" {
+				recordEvent("ssh", "pinned host key %s for %s", fingerprint, minerName(ip))
+				return database.PinSSHHostKey(ip, fingerprint)
+			}
+			if fingerprint != pinned {
+				return fmt.Errorf("host key mismatch: got %s, pinned %s", fingerprint, pinned)
+			}
+			return nil
+		},
+	}
+	return config, net.JoinHostPort(ip, strconv.Itoa(port)), nil
+}
+
+// limitedBuffer keeps the first max bytes written to it. The session copies
+// stdout and stderr into it from separate goroutines.
+type limitedBuffer struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if room := b.max - b.buf.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// String returns the output kept, marking a truncated one.
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.truncated {
+		return b.buf.String() + "\n[output truncated]"
+	}
+	return b.buf.String()
+}
+
+// runSSHCommand runs a command line on a miner and returns its combined
+// output. The session is closed when ctx is done.
+func runSSHCommand(ctx context.Context, ip, command string) (string, error) {
+	config, addr, err := sshClientConfig(ip)
+	if err != nil {
+		return "", err
+	}
+	client, err := ssh.Dial("tcp", resolveHostPort(ctx, addr), config)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect: %w", err)
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to open session: %w", err)
+	}
+	defer session.Close()
+
+	out := &limitedBuffer{max: sshMaxOutput}
+	session.Stdout = out
+	session.Stderr = out
+
+	done := make(chan error, 1)
+	go func() { done <- session.Run(command) }()
+
+	select {
+	case err = <-done:
+	case <-time.After(sshTimeout):
+		client.Close()
+		<-done // closing the client ends Run once the output copies finish
+		err = fmt.Errorf("timed out after %s", sshTimeout)
+	case <-ctx.Done():
+		client.Close()
+		<-done
+		err = ctx.Err()
+	}
+	return out.String(), err
+}
+
+func getSSHCommandsHandler(c *gin.Context) {
+	ip := c.Query("ip")
+	if ip == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ip required"})
+		return
+	}
+	if _, ok := visibleMachine(c, ip); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown machine " + ip})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ip":       ip,
+		"commands": driverFor(ip).SSHCommands(),
+	})
+}
+
+type SSHExecRequest struct {
+	IP      string `json:"ip" binding:"required"`
+	Command string `json:"command" binding:"required"`
+	Arg     *int   `json:"arg"`
+}
+
+// execSSHHandler runs a whitelisted command on a miner. Destructive commands
+// need a 2FA code in the X-TOTP-Code header once 2FA is enrolled.
+func execSSHHandler(c *gin.Context) {
+	var req SSHExecRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, ok := visibleMachine(c, req.IP); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown machine " + req.IP})
+		return
+	}
+
+	cmd, ok := driverFor(req.IP).SSHCommands()[req.Command]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "command not allowed: " + req.Command})
+		return
+	}
+	line, err := cmd.render(req.Arg)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if cmd.Destructive {
+		active, ok, lockout, err := checkTOTP(c.ClientIP(), c.GetHeader(totpHeader))
+		if err != nil {
+			log.Printf("Failed to verify TOTP: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify TOTP"})
+			return
+		}
+		if lockout > 0 {
+			rejectTOTPLockout(c, lockout)
+			return
+		}
+		if active && !ok {
+			recordEvent("2fa", "rejected ssh %s on %s from %s: missing or invalid code", req.Command, req.IP, c.ClientIP())
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":        "TOTP code required",
+				"totpRequired": true,
+			})
+			return
+		}
+	}
+
+	output, err := runSSHCommand(c.Request.Context(), req.IP, line)
+	if err != nil {
+		recordEvent("ssh", "%s on %s failed (from %s): %v", req.Command, minerName(req.IP), c.ClientIP(), err)
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":  err.Error(),
+			"output": output,
+		})
+		return
+	}
+
+	recordEvent("ssh", "ran %s on %s (from %s)", req.Command, minerName(req.IP), c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"ip":      req.IP,
+		"command": req.Command,
+		"output":  output,
+	})
+}
+
+// SSHCredentialsInfo is stored SSH login info without the secrets.
+type SSHCredentialsInfo struct {
+	db.SSHCredentials
+	HasPassword bool `json:"hasPassword"`
+	HasKey      bool `json:"hasKey"`
+}
+
+func getSSHCredentialsHandler(c *gin.Context) {
+	creds, err := database.FetchSSHCredentials()
+	if err != nil {
+		log.Printf("Failed to fetch SSH credentials: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch SSH credentials"})
+		return
+	}
+
+	infos := make([]SSHCredentialsInfo, 0, len(creds))
+	for _, cr := range creds {
+		infos = append(infos, SSHCredentialsInfo{
+			SSHCredentials: cr,
+			HasPassword:    cr.Password != "",
+			HasKey:         cr.PrivateKey != "",
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"credentials": infos,
+		"defaultUser": sshUser,
+	})
+}
+
+type SSHCredentialsRequest struct {
+	IP         string `json:"ip" binding:"required"`
+	User       string `json:"user"`
+	Port       int    `json:"port"`
+	Password   string `json:"password"`
+	PrivateKey string `json:"privateKey"`
+}
+
+func saveSSHCredentialsHandler(c *gin.Context) {
+	var req SSHCredentialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Port < 0 || req.Port > 65535 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid port"})
+		return
+	}
+	req.PrivateKey = strings.TrimSpace(req.PrivateKey)
+	if req.PrivateKey != "" {
+		if _, err := ssh.ParsePrivateKey([]byte(req.PrivateKey)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid private key: " + err.Error()})
+			return
+		}
+	}
+
+	creds := db.SSHCredentials{
+		MachineIP:  req.IP,
+		User:       req.User,
+		Port:       req.Port,
+		Password:   req.Password,
+		PrivateKey: req.PrivateKey,
+	}
+	if err := database.SaveSSHCredentials(creds); err != nil {
+		log.Printf("Failed to save SSH credentials for %s: %v", req.IP, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save SSH credentials"})
+		return
+	}
+
+	recordEvent("ssh", "credentials for %s updated (from %s)", minerName(req.IP), c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"ip":      req.IP,
+	})
+}
+
+// deleteSSHCredentialsHandler removes stored credentials and the pinned host
+// key, e.g. after a control board was replaced.
+func deleteSSHCredentialsHandler(c *gin.Context) {
+	ip := ipParam(c)
+	if err := database.DeleteSSHCredentials(ip); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete SSH credentials"})
+		return
+	}
+
+	recordEvent("ssh", "credentials and host key for %s removed (from %s)", minerName(ip), c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"ip":      ip,
+	})
+}
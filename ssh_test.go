@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLimitedBufferConcurrentWrites(t *testing.T) {
+	out := &limitedBuffer{max: 1000}
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				out.Write([]byte("0123456789"))
+			}
+		}()
+	}
+	wg.Wait()
+	got := out.String()
+	if !strings.HasSuffix(got, "\n[output truncated]") || len(got) != 1000+len("\n[output truncated]") {
+		t.Errorf("output of %d bytes, want 1000 and the truncation mark", len(got))
+	}
+}
+
+func TestSSHRoutesRejectUnknownMachines(t *testing.T) {
+	useTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/ssh/commands", getSSHCommandsHandler)
+	r.POST("/api/ssh/exec", execSSHHandler)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/api/ssh/commands?ip=203.0.113.9", nil),
+		httptest.NewRequest(http.MethodPost, "/api/ssh/exec", strings.NewReader(`{"ip":"203.0.113.9","command":"reboot"}`)),
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s %s = %d, want 404", req.Method, req.URL, w.Code)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+// Adds the CSRF token from the csrf_token cookie to same-origin state-changing fetch requests.
+(function() {
+    const originalFetch = window.fetch;
+
+    function csrfToken() {
+        const match = document.cookie.match(/(?:^|;\s*)csrf_token=([^;]+)/);
+        return match ? decodeURIComponent(match[1]) : '';
+    }
+
+    window.fetch = function(input, init) {
+        init = init || {};
+        const method = (init.method || 'GET').toUpperCase();
+        const url = new URL(typeof input === 'string' ? input : input.url, window.location.href);
+        if (method !== 'GET' && method !== 'HEAD' && url.origin === window.location.origin) {
+            const headers = new Headers(init.headers || {});
+            headers.set('X-CSRF-Token', csrfToken());
+            init.headers = headers;
+        }
+        return originalFetch(input, init);
+    };
+})();
@@ -3,19 +3,48 @@ document.getElementById('sidebarCollapse').addEventListener('click', function ()
     document.getElementById('sidebar').classList.toggle('active');
 });
 
+// Replay: ?asOf= shows the dashboard as it was at that moment
+const replayAsOf = new URLSearchParams(location.search).get('asOf');
+
+function apiURL(path) {
+    return replayAsOf ? `${path}?asOf=${encodeURIComponent(replayAsOf)}` : path;
+}
+
+// The moment data freshness is judged against
+function referenceTime() {
+    return replayAsOf ? Date.parse(replayAsOf) : Date.now();
+}
+
+document.getElementById('replayForm').addEventListener('submit', function (e) {
+    e.preventDefault();
+    const value = document.getElementById('replayAt').value;
+    if (!value) return;
+    const asOf = new Date(value).toISOString().replace(/\.\d{3}Z$/, 'Z');
+    location.search = '?asOf=' + encodeURIComponent(asOf);
+});
+
+if (replayAsOf) {
+    // datetime-local wants local time without a zone
+    const d = new Date(replayAsOf);
+    document.getElementById('replayAt').value =
+        new Date(d.getTime() - d.getTimezoneOffset() * 60000).toISOString().slice(0, 16);
+}
+
 // Update current time
 function updateTime() {
-    const now = new Date();
-    const timeString = now.toLocaleTimeString();
+    const now = replayAsOf ? new Date(replayAsOf) : new Date();
+    const timeString = replayAsOf ? now.toLocaleString() : now.toLocaleTimeString();
     document.getElementById('currentTime').textContent = timeString;
 }
 updateTime();
-setInterval(updateTime, 1000);
+if (!replayAsOf) {
+    setInterval(updateTime, 1000);
+}
 
 // Environment Temperature Boxes
 async function loadEnvironmentTemps() {
     try {
-        const response = await fetch('/api/environment/latest');
+        const response = await fetch(apiURL('/api/environment/latest'));
         const data = await response.json();
         const container = document.getElementById('envTempContainer');
 
@@ -33,7 +62,7 @@ async function loadEnvironmentTemps() {
 
         container.innerHTML = data.readings.map(r => {
             const ts = new Date(r.timestamp.endsWith('Z') ? r.timestamp : r.timestamp + 'Z');
-            const ageMs = Date.now() - ts.getTime();
+            const ageMs = referenceTime() - ts.getTime();
             const stale = isNaN(ageMs) || ageMs > 120 * 1000;
             const bgClass = stale ? 'bg-danger text-white' : 'bg-light';
             const textClass = stale ? 'text-white' : 'text-primary';
@@ -53,17 +82,19 @@ async function loadEnvironmentTemps() {
 }
 
 loadEnvironmentTemps();
-setInterval(loadEnvironmentTemps, 60 * 1000);
+if (!replayAsOf) {
+    setInterval(loadEnvironmentTemps, 60 * 1000);
+}
 
 // Miner Status Table
 async function loadMinerStatus() {
     try {
-        const response = await fetch('/api/miners/status');
+        const response = await fetch(apiURL('/api/miners/status'));
         const data = await response.json();
         const tbody = document.getElementById('minerStatusBody');
 
         if (!data.hasData || !data.miners || data.miners.length === 0) {
-            tbody.innerHTML = '<tr><td colspan="8" class="text-center text-muted py-3">No miner data available</td></tr>';
+            tbody.innerHTML = '<tr><td colspan="9" class="text-center text-muted py-3">No miner data available</td></tr>';
             return;
         }
 
@@ -74,6 +105,13 @@ async function loadMinerStatus() {
             const power = Math.round(m.power);
             const efficiency = m.efficiency.toFixed(1);
             const temp = m.temperatureMax.toFixed(1);
+            let pool = '<span class="text-muted">-</span>';
+            if (m.pool) {
+                const host = m.pool.url.replace(/^[a-z+]+:\/\//, '');
+                const poolClass = !m.pool.alive ? 'bg-danger' : m.pool.rejectPct >= 5 ? 'bg-warning' : 'bg-success';
+                pool = `<span class="badge ${poolClass}" title="${m.pool.status}: ${m.pool.accepted} accepted, ${m.pool.rejected} rejected, ${m.pool.stale} stale">${host}</span>
+                    <small class="text-muted">${m.pool.rejectPct.toFixed(1)}% rej</small>`;
+            }
             return `<tr>
                 <td class="fw-semibold">${m.name}</td>
                 <td><code>${m.minerIp}</code></td>
@@ -83,6 +121,7 @@ async function loadMinerStatus() {
                 <td>${power} W</td>
                 <td>${efficiency} J/TH</td>
                 <td>${temp} &deg;C</td>
+                <td>${pool}</td>
             </tr>`;
         }).join('');
     } catch (error) {
@@ -91,4 +130,6 @@ async function loadMinerStatus() {
 }
 
 loadMinerStatus();
-setInterval(loadMinerStatus, 60 * 1000);
+if (!replayAsOf) {
+    setInterval(loadMinerStatus, 60 * 1000);
+}
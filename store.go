@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+
+	"miningRoom/influxdb"
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TimeseriesStore is what the live dashboard, its 24 hour charts and the
+// metric writers need from the timeseries database. *questdb.Client and
+// *influxdb.Client implement it; reports, billing, history and the other
+// views query QuestDB directly and have no data with another backend.
+type TimeseriesStore interface {
+	GetTotalHashrate() (*questdb.TotalHashrateResult, error)
+	GetTotalPower() (*questdb.TotalPowerResult, error)
+	GetMaxTemperature() (*questdb.MaxTemperatureResult, error)
+	GetAvgMaxTemperature() (*questdb.AvgTemperatureResult, error)
+	GetRoomTemperature(locations []string) (*questdb.RoomTemperatureResult, error)
+	GetMinerStatuses() (*questdb.MinerStatusData, error)
+	GetShelliesPower() (*questdb.ShelliesPowerData, error)
+	GetHashboardsDetailed() (*questdb.HashboardDetailedData, error)
+	GetMinerTemperatures() (*questdb.MinerTemperatureChartData, error)
+	GetEnvironmentTemperatures() (*questdb.EnvironmentChartData, error)
+	GetEnvironmentHumidity() (*questdb.HumidityChartData, error)
+	GetEnvironmentPressure() (*questdb.PressureChartData, error)
+	GetPowerTimeSeries() (*questdb.TimeSeriesData, error)
+	GetHashrateTimeSeries() (*questdb.TimeSeriesData, error)
+	GetPerMinerHashrateTimeSeries() (*questdb.MinerHashrateChartData, error)
+	GetPerDevicePowerTimeSeries() (*questdb.DevicePowerChartData, error)
+
+	Write(points []questdb.Point) error
+	WriteLines(lines string) error
+}
+
+// Timeseries backends (--tsdb)
+const (
+	tsdbQuestDB  = "questdb"
+	tsdbInfluxDB = "influxdb"
+)
+
+// influxClient is set when --tsdb is influxdb; nil keeps everything on
+// QuestDB.
+var influxClient *influxdb.Client
+
+// tsStore is the store of background loops.
+var tsStore TimeseriesStore
+
+// storeFor returns the timeseries store bound to the request's context and,
+// on replayed requests, to the replay time. With QuestDB it is questdbFor(c),
+// which also applies the owner's scope. Owner scopes are only applied to
+// QuestDB queries, so owners read QuestDB even with --tsdb influxdb, like
+// grpcStore; --require-owner-login needs QuestDB.
+func storeFor(c *gin.Context) TimeseriesStore {
+	if influxClient == nil || ownerFor(c) != "" {
+		return questdbFor(c)
+	}
+	s := influxClient.WithContext(c.Request.Context())
+	if t := asOfFor(c); !t.IsZero() {
+		s = s.WithAsOf(t)
+	}
+	return s
+}
+
+// storeWith returns the timeseries store bound to ctx.
+func storeWith(ctx context.Context) TimeseriesStore {
+	if influxClient == nil {
+		return questdbClient.WithContext(ctx)
+	}
+	return influxClient.WithContext(ctx)
+}
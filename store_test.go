@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"miningRoom/influxdb"
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStoreForScopesOwnersWithInfluxDB(t *testing.T) {
+	defer func(c *influxdb.Client, q *questdb.Client) { influxClient, questdbClient = c, q }(influxClient, questdbClient)
+	influxClient = influxdb.NewClient("http://127.0.0.1:1", "org", "bucket", "")
+	questdbClient = questdb.NewClient("127.0.0.1", 1)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/status", nil)
+	if _, ok := storeFor(c).(*influxdb.Client); !ok {
+		t.Errorf("admin store = %T, want InfluxDB", storeFor(c))
+	}
+
+	c.Set("Owner", "alice")
+	if _, ok := storeFor(c).(*questdb.Client); !ok {
+		t.Errorf("owner store = %T, want the scoped QuestDB store", storeFor(c))
+	}
+}
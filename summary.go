@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// summaryTopAlerts is the number of alerts included in the summary document.
+const summaryTopAlerts = 3
+
+// SummaryMiner is a compact per-miner line for wall displays.
+type SummaryMiner struct {
+	Name   string `json:"name"`
+	Online bool   `json:"online"`
+	Line   string `json:"line"` // e.g. "Rig-01 Mining 110 TH/s 3400 W 72°C"
+}
+
+// getSummaryHandler returns a single compact document with everything a wall
+// display needs, so e-ink clients only have to make one request.
+func getSummaryHandler(c *gin.Context) {
+	online := false
+	label := "No Data"
+	if result, err := storeFor(c).GetTotalHashrate(); err != nil {
+		log.Printf("Failed to get hashrate from QuestDB: %v", err)
+	} else if result.HasData {
+		online = isTimestampRecentAt(result.Timestamp, 5*time.Minute, requestNow(c))
+		label = "Online"
+		if !online {
+			label = "Stale Data"
+		}
+	}
+
+	loc := localeFor(c)
+	roomTemp := 0.0
+	if result, err := storeFor(c).GetRoomTemperature(indoorLocations()); err != nil {
+		log.Printf("Failed to get room temperature from QuestDB: %v", err)
+	} else if result.HasData {
+		roomTemp = loc.Temp(math.Round(result.Temperature*10) / 10)
+	}
+
+	g := fetchGaugeValues(storeFor(c))
+
+	topAlerts := []string{}
+	if !replaying(c) {
+		active := alerts.list()
+		if len(active) > summaryTopAlerts {
+			active = active[:summaryTopAlerts]
+		}
+		for _, a := range active {
+			topAlerts = append(topAlerts, a.Message)
+		}
+	}
+
+	ipToName := make(map[string]string)
+	for _, m := range currentMachines() {
+		ipToName[m.IP] = m.Name
+	}
+
+	miners := []SummaryMiner{}
+	if statuses, err := storeFor(c).GetMinerStatuses(); err != nil {
+		log.Printf("Failed to get miner statuses from QuestDB: %v", err)
+	} else {
+		for _, s := range statuses.Miners {
+			name, ok := ipToName[s.MinerIP]
+			if !ok {
+				name = s.MinerIP
+			}
+			minerOnline := isTimestampRecentAt(s.Timestamp, 2*time.Minute, requestNow(c))
+			status := s.Status
+			if !minerOnline {
+				status = "Offline"
+			}
+			miners = append(miners, SummaryMiner{
+				Name:   name,
+				Online: minerOnline,
+				Line: fmt.Sprintf("%s %s %.0f TH/s %.0f W %.0f%s",
+					name, loc.T(status), s.Hashrate/1000, s.Power, loc.Temp(s.TemperatureMax), loc.TempUnit()),
+			})
+		}
+	}
+	sort.Slice(miners, func(i, j int) bool {
+		return miners[i].Name < miners[j].Name
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": gin.H{
+			"online":   online,
+			"label":    loc.T(label),
+			"roomTemp": roomTemp,
+			"tempUnit": loc.TempUnit(),
+		},
+		"gauges": gin.H{
+			"power":      g.Power,
+			"hashrate":   g.Hashrate,
+			"efficiency": g.Efficiency,
+			"elecCost":   g.ElecCost,
+			"revenue":    g.Revenue,
+		},
+		"alerts":    topAlerts,
+		"miners":    miners,
+		"updatedAt": requestNow(c).UTC().Format(time.RFC3339),
+	})
+}
+
+// kioskHandler renders a read-only full-screen page for wall displays. Management
+// controls are never shown, regardless of the client network.
+func kioskHandler(c *gin.Context) {
+	c.HTML(http.StatusOK, "kiosk.html", gin.H{
+		"Title":      "Mining Dashboard",
+		"ShowManage": false,
+		"Lang":       localeFor(c).Lang,
+	})
+}
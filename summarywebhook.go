@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The summary webhook posts the DailySummary once a day from reportSendHour,
+// away or not, so it can feed anything that takes an HTTP request: a Nostr
+// bridge, a Lightning wallet note, a chat bot. Without --summary-template the
+// body is the summary as JSON; with it, the template renders the body.
+
+var (
+	summaryWebhookURL    string // --summary-webhook, empty disables
+	summaryTemplatePath  string // --summary-template: text/template file
+	summaryContentType   string // --summary-content-type
+	summaryWebhookHeader string // --summary-webhook-header: "Name: value", e.g. an auth token
+)
+
+const summaryWebhookPeriod = "summary-webhook" // report_runs key of the webhook post
+
+// summaryTemplate is parsed once at startup; nil posts the JSON summary.
+var summaryTemplate *template.Template
+
+// summaryTemplateFuncs are available to --summary-template besides the
+// text/template built-ins.
+var summaryTemplateFuncs = template.FuncMap{
+	// json encodes a value, e.g. {{json .Alerts}}, or quotes a string for a
+	// JSON payload: {"content": {{json (printf "%.2f EUR" .Profit)}}}
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	// sats converts EUR at the current BTC price, e.g. {{sats .Profit}}
+	"sats": func(eur float64) (int64, error) {
+		price, err := fetchBTCPriceEUR()
+		if err != nil || price <= 0 {
+			return 0, fmt.Errorf("no BTC price: %v", err)
+		}
+		return int64(eur / price * satsPerBTC), nil
+	},
+}
+
+// loadSummaryTemplate parses --summary-template, if set.
+func loadSummaryTemplate(path string) error {
+	if path == "" {
+		return nil
+	}
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New("summary").Funcs(summaryTemplateFuncs).Parse(string(text))
+	if err != nil {
+		return err
+	}
+	summaryTemplate = tmpl
+	return nil
+}
+
+// renderSummaryPayload is the webhook body for the summary.
+func renderSummaryPayload(s DailySummary) ([]byte, error) {
+	if summaryTemplate == nil {
+		return json.Marshal(s)
+	}
+	var buf bytes.Buffer
+	if err := summaryTemplate.Execute(&buf, s); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// postSummaryWebhook renders the summary as of now and posts it, returning
+// the body that was sent.
+func postSummaryWebhook(now time.Time) ([]byte, error) {
+	body, err := renderSummaryPayload(collectDailySummary(now))
+	if err != nil {
+		return nil, fmt.Errorf("failed to render payload: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, summaryWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", summaryContentType)
+	if name, value, ok := splitHeader(summaryWebhookHeader); ok {
+		req.Header.Set(name, value)
+	}
+	return body, postNotification(req)
+}
+
+// splitHeader parses a "Name: value" header.
+func splitHeader(h string) (string, string, bool) {
+	name, value, ok := strings.Cut(h, ":")
+	name = strings.TrimSpace(name)
+	return name, strings.TrimSpace(value), ok && name != ""
+}
+
+// runSummaryWebhook posts the summary once a day from reportSendHour; the
+// last post is stored so restarts don't resend.
+func runSummaryWebhook(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := roomNow()
+		if now.Hour() < reportSendHour {
+			continue
+		}
+		last, err := database.LastReportRun(summaryWebhookPeriod)
+		if err != nil {
+			log.Printf("Summary webhook: failed to read last post: %v", err)
+			continue
+		}
+		if !last.Before(roomMidnight(now)) {
+			continue
+		}
+		if _, err := postSummaryWebhook(now); err != nil {
+			log.Printf("Summary webhook: %v", err)
+			continue
+		}
+		if err := database.SetReportRun(summaryWebhookPeriod, now); err != nil {
+			log.Printf("Summary webhook: failed to record post: %v", err)
+		}
+	}
+}
+
+// previewSummaryWebhookHandler renders the payload without sending it, for
+// writing a template.
+func previewSummaryWebhookHandler(c *gin.Context) {
+	body, err := renderSummaryPayload(collectDailySummary(roomNow()))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, summaryContentType, body)
+}
+
+// testSummaryWebhookHandler posts the summary right away, for testing the
+// receiving end.
+func testSummaryWebhookHandler(c *gin.Context) {
+	if summaryWebhookURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "--summary-webhook is not configured"})
+		return
+	}
+	body, err := postSummaryWebhook(roomNow())
+	if err != nil {
+		log.Printf("Summary webhook: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	recordEvent("summary", "test summary webhook posted (from %s)", c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{"success": true, "payload": string(body)})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// elecTariff is --elec-tariff: time-of-use prices by hour of the day in the
+// room's time zone; hours it leaves out cost --elec-price.
+var elecTariff string
+
+// tariffPrices holds the EUR/kWh of each hour of the day, parsed from
+// --elec-tariff in main.
+var tariffPrices [24]float64
+
+// parseTariff parses "22-6=0.15,6-22=0.25" into prices per hour of the day.
+// Ranges are start-end hours like --quiet-hours and may wrap past midnight;
+// later ranges override earlier ones, and hours not covered get base.
+func parseTariff(s string, base float64) ([24]float64, error) {
+	var prices [24]float64
+	for h := range prices {
+		prices[h] = base
+	}
+	if strings.TrimSpace(s) == "" {
+		return prices, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		hours, price, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return prices, fmt.Errorf("%q: expected start-end=price, e.g. 22-6=0.15", part)
+		}
+		start, end, err := parseQuietHours(hours)
+		if err != nil {
+			return prices, fmt.Errorf("%q: %v", part, err)
+		}
+		p, err := strconv.ParseFloat(strings.TrimSpace(price), 64)
+		if err != nil || p < 0 {
+			return prices, fmt.Errorf("%q: invalid price %q", part, price)
+		}
+		for h := start; h != end; h = (h + 1) % 24 {
+			prices[h] = p
+		}
+	}
+	return prices, nil
+}
+
+// tariffPrice returns the electricity price in EUR/kWh at t.
+func tariffPrice(t time.Time) float64 {
+	return tariffPrices[t.In(roomLocation).Hour()]
+}
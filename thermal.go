@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"miningRoom/db"
+	"miningRoom/internal/control"
+	"miningRoom/questdb"
+)
+
+// thermalController switches actuators in auto mode based on room temperature and
+// humidity thresholds. It works independently of miner power settings.
+type thermalController struct {
+	mu   sync.Mutex
+	last map[string]bool // last state commanded per actuator name
+}
+
+var thermal = &thermalController{last: make(map[string]bool)}
+
+// forget drops the remembered state of an actuator so the next evaluation
+// re-applies the desired state.
+func (t *thermalController) forget(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.last, name)
+}
+
+// runThermalController evaluates actuator rules at the given interval.
+func runThermalController(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		thermal.evaluate()
+	}
+}
+
+func (t *thermalController) evaluate() {
+	// Relays were cut on purpose
+	if emergency.locked() {
+		return
+	}
+
+	actuators, err := database.FetchActuators()
+	if err != nil {
+		log.Printf("Thermal controller: failed to fetch actuators: %v", err)
+		return
+	}
+
+	var auto []db.Actuator
+	for _, a := range actuators {
+		if a.Auto {
+			auto = append(auto, a)
+		}
+	}
+	if len(auto) == 0 {
+		return
+	}
+
+	env, err := questdbClient.GetLatestEnvironmentTemperatures()
+	if err != nil {
+		log.Printf("Thermal controller: failed to get environment readings: %v", err)
+		return
+	}
+
+	for _, a := range auto {
+		reading := findEnvironmentReading(env, a.Location)
+		if reading == nil || !isTimestampRecent(reading.Timestamp, 10*time.Minute) {
+			continue // no fresh data, leave the actuator alone
+		}
+
+		value := reading.Temperature
+		if a.Metric == "humidity" {
+			value = reading.Humidity
+		}
+
+		t.mu.Lock()
+		current, known := t.last[a.Name]
+		t.mu.Unlock()
+
+		desired, crossed := actuatorDecision(a, value, current)
+		if isVentilation(a) && condensation.blocksVentilation() {
+			desired, crossed = false, true
+		}
+		if !crossed || (known && desired == current) {
+			continue
+		}
+
+		if err := controlShelly(context.Background(), a.ShellyIP, desired); err != nil {
+			log.Printf("Thermal controller: failed to switch %s %s: %v", a.Name, onOff(desired), err)
+			continue
+		}
+		log.Printf("Thermal controller: switched %s %s (%s %.1f at %s)", a.Name, onOff(desired), a.Metric, value, a.Location)
+
+		t.mu.Lock()
+		t.last[a.Name] = desired
+		t.mu.Unlock()
+	}
+}
+
+// isVentilation reports whether an actuator moves outside air into the room.
+func isVentilation(a db.Actuator) bool {
+	return a.Kind == "fan" || a.Kind == "damper"
+}
+
+// actuatorDecision applies the hysteresis rule of an actuator to a reading.
+// It returns the desired state and whether a threshold was crossed; between the
+// thresholds the current state is kept. Thresholds with on above off cool or
+// ventilate, on below off heat.
+func actuatorDecision(a db.Actuator, value float64, current bool) (bool, bool) {
+	return control.Hysteresis{On: a.OnThreshold, Off: a.OffThreshold}.Next(value, current)
+}
+
+// findEnvironmentReading returns the latest reading for a location, or nil.
+func findEnvironmentReading(env *questdb.LatestEnvironmentData, location string) *questdb.LatestEnvironmentReading {
+	for i := range env.Readings {
+		if env.Readings[i].Location == location {
+			return &env.Readings[i]
+		}
+	}
+	return nil
+}
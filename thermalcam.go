@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Thermal array cameras (MLX90640 on an ESP32 and the like) push whole frames
+// to /api/ingest/thermal. Frames go to QuestDB thermal_frames with their
+// statistics, and a hottest cell at or above --thermal-max-cell raises an
+// alert per camera.
+
+var thermalMaxCell float64 // --thermal-max-cell: °C, 0 disables the alerts
+
+const (
+	thermalAlertHysteresis = 2.0 // °C below --thermal-max-cell that clears the alert
+	thermalDefaultWidth    = 32  // MLX90640
+	thermalDefaultHeight   = 24
+	thermalMaxCells        = 320 * 240
+	thermalMinReading      = -40.0 // MLX90640 measuring range, °C
+	thermalMaxReading      = 300.0
+)
+
+// ThermalFrameRequest is a pushed frame: Frame holds Width x Height cells in
+// °C, row by row from the top left. Width and Height default to the
+// MLX90640's 32x24; Timestamp is unix seconds, omitted uses the server time.
+type ThermalFrameRequest struct {
+	Camera    string    `json:"camera" binding:"required"`
+	Location  string    `json:"location"`
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+	Frame     []float64 `json:"frame" binding:"required"`
+	Timestamp float64   `json:"timestamp"`
+}
+
+// thermalStats are the figures stored alongside a frame.
+type thermalStats struct {
+	Min, Max, Mean float64
+	MaxX, MaxY     int
+}
+
+// validate fills in the default size and checks the frame.
+func (r *ThermalFrameRequest) validate() error {
+	r.Camera = strings.TrimSpace(r.Camera)
+	if r.Camera == "" {
+		return fmt.Errorf("camera required")
+	}
+	if r.Width == 0 && r.Height == 0 {
+		r.Width, r.Height = thermalDefaultWidth, thermalDefaultHeight
+	}
+	if r.Width <= 0 || r.Height <= 0 || r.Width*r.Height > thermalMaxCells {
+		return fmt.Errorf("invalid frame size %dx%d", r.Width, r.Height)
+	}
+	if len(r.Frame) != r.Width*r.Height {
+		return fmt.Errorf("frame has %d cells, expected %dx%d", len(r.Frame), r.Width, r.Height)
+	}
+	for i, v := range r.Frame {
+		if math.IsNaN(v) || v < thermalMinReading || v > thermalMaxReading {
+			return fmt.Errorf("cell %d out of range: %v", i, v)
+		}
+	}
+	return nil
+}
+
+func (r *ThermalFrameRequest) stats() thermalStats {
+	s := thermalStats{Min: r.Frame[0], Max: r.Frame[0]}
+	sum := 0.0
+	for i, v := range r.Frame {
+		sum += v
+		s.Min = math.Min(s.Min, v)
+		if v > s.Max {
+			s.Max, s.MaxX, s.MaxY = v, i%r.Width, i/r.Width
+		}
+	}
+	s.Mean = math.Round(sum/float64(len(r.Frame))*10) / 10
+	return s
+}
+
+// ingestThermalHandler stores a pushed thermal frame and checks its hottest
+// cell.
+func ingestThermalHandler(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, ingestMaxBody)
+	var req ThermalFrameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Location == "" {
+		req.Location = sensorLocation(req.Camera)
+	}
+
+	s := req.stats()
+	point := questdb.Point{
+		Table:   "thermal_frames",
+		Symbols: map[string]string{"camera": req.Camera, "location": req.Location},
+		Fields: map[string]interface{}{
+			"width":  int64(req.Width),
+			"height": int64(req.Height),
+			"min":    s.Min,
+			"max":    s.Max,
+			"mean":   s.Mean,
+			"max_x":  int64(s.MaxX),
+			"max_y":  int64(s.MaxY),
+			"frame":  questdb.EncodeThermalFrame(req.Frame),
+		},
+		Time: unixFloatTime(req.Timestamp),
+	}
+	if err := questdbFor(c).Write([]questdb.Point{point}); err != nil {
+		log.Printf("Failed to write thermal frame from %s: %v", req.Camera, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to write to QuestDB"})
+		return
+	}
+	checkThermalFrame(req.Camera, req.Location, s)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"max":     s.Max,
+		"maxX":    s.MaxX,
+		"maxY":    s.MaxY,
+	})
+}
+
+// checkThermalFrame raises the camera's alert when its hottest cell reaches
+// --thermal-max-cell and clears it once the frame has cooled down.
+func checkThermalFrame(camera, location string, s thermalStats) {
+	if thermalMaxCell <= 0 {
+		return
+	}
+	key := "thermal-camera:" + camera
+	switch {
+	case s.Max >= thermalMaxCell:
+		alerts.raise(key, severityWarning, "thermal-camera", fmt.Sprintf(
+			"Thermal camera %s (%s) sees %.1f °C at cell %d,%d (limit %.1f °C)", camera, location, s.Max, s.MaxX, s.MaxY, thermalMaxCell))
+	case s.Max < thermalMaxCell-thermalAlertHysteresis:
+		alerts.clear(key)
+	}
+}
+
+// getThermalFramesHandler returns the latest frame of each camera with the
+// cells at or above --thermal-max-cell, and the active thermal camera alerts.
+func getThermalFramesHandler(c *gin.Context) {
+	frames, err := questdbFor(c).GetLatestThermalFrames()
+	if err != nil {
+		log.Printf("Failed to get thermal frames from QuestDB: %v", err)
+		c.JSON(http.StatusOK, gin.H{"cameras": []interface{}{}, "alerts": []Alert{}, "hasData": false})
+		return
+	}
+
+	cameras := make([]gin.H, 0, len(frames))
+	for _, f := range frames {
+		cells, err := f.Cells()
+		if err != nil {
+			log.Printf("Failed to decode thermal frame: %v", err)
+			continue
+		}
+		hot := [][2]int{}
+		if thermalMaxCell > 0 {
+			for y, row := range cells {
+				for x, v := range row {
+					if v >= thermalMaxCell {
+						hot = append(hot, [2]int{x, y})
+					}
+				}
+			}
+		}
+		cameras = append(cameras, gin.H{
+			"camera":   f.Camera,
+			"location": f.Location,
+			"time":     f.Timestamp,
+			"width":    f.Width,
+			"height":   f.Height,
+			"min":      f.Min,
+			"max":      f.Max,
+			"mean":     f.Mean,
+			"maxCell":  [2]int{f.MaxX, f.MaxY},
+			"hotCells": hot,
+			"stale":    !f.Timestamp.After(requestNow(c).Add(-5 * time.Minute)),
+			"cells":    cells,
+		})
+	}
+
+	active := []Alert{}
+	if !replaying(c) {
+		for _, a := range alerts.list() {
+			if a.Source == "thermal-camera" {
+				active = append(active, a)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"cameras": cameras,
+		"maxCell": thermalMaxCell,
+		"alerts":  active,
+		"hasData": len(cameras) > 0,
+	})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestTimeoutSeconds bounds how long an API request may wait on miners,
+// outlets and QuestDB; 0 leaves requests bounded only by the client timeouts.
+var requestTimeoutSeconds int
+
+// requestTimeoutMiddleware gives each request a context deadline. Calls made
+// with the request context are canceled when it passes or the client goes
+// away, instead of finishing for nobody.
+func requestTimeoutMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if requestTimeoutSeconds <= 0 {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), time.Duration(requestTimeoutSeconds)*time.Second)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// questdbFor returns the QuestDB client bound to the request's context and,
+// on replayed requests, to the replay time.
+func questdbFor(c *gin.Context) *questdb.Client {
+	qdb := questdbClient.WithContext(c.Request.Context())
+	if t := asOfFor(c); !t.IsZero() {
+		qdb = qdb.WithAsOf(t)
+	}
+	if ownerFor(c) != "" {
+		qdb = qdb.WithScope(ownerScope(machinesFor(c)))
+	}
+	return qdb
+}
+
+// httpGet issues a GET that is canceled with ctx.
+func httpGet(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// dialContext opens a TCP or UDP connection that gives up after timeout or
+// when ctx is done. Device hostnames are dialed at their cached address. The connection's deadline is the earlier of timeout from
+// now and ctx's deadline, and it is closed if ctx is canceled.
+func dialContext(ctx context.Context, network, addr string, timeout time.Duration) (net.Conn, error) {
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, network, resolveHostPort(ctx, addr))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(ctxDeadline(ctx, timeout))
+	return &ctxConn{Conn: conn, stop: context.AfterFunc(ctx, func() { conn.Close() })}, nil
+}
+
+// ctxConn is a connection closed when its context is canceled.
+type ctxConn struct {
+	net.Conn
+	stop func() bool
+}
+
+func (c *ctxConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
+
+// ctxDeadline returns the earlier of timeout from now and ctx's deadline.
+func ctxDeadline(ctx context.Context, timeout time.Duration) time.Time {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		return d
+	}
+	return deadline
+}
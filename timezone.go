@@ -0,0 +1,19 @@
+package main
+
+import "time"
+
+var (
+	roomTimezone string                      // --timezone
+	roomLocation *time.Location = time.Local // calendar days, hours of day and schedules
+)
+
+// roomNow returns the current time in the room's time zone.
+func roomNow() time.Time {
+	return time.Now().In(roomLocation)
+}
+
+// roomMidnight returns the start of t's calendar day in the room's time zone.
+func roomMidnight(t time.Time) time.Time {
+	t = t.In(roomLocation)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, roomLocation)
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	totpStep          = 30 * time.Second
+	totpDigits        = 6
+	totpRecoveryCodes = 10
+	totpIssuer        = "miningRoom"
+)
+
+// totpHeader carries the TOTP or recovery code for protected actions.
+const totpHeader = "X-TOTP-Code"
+
+// totpLastCounter is the last accepted time step, so a code cannot be replayed
+// for a second action.
+var (
+	totpMu          sync.Mutex
+	totpLastCounter uint64
+)
+
+// totpCode computes the RFC 6238 code for a base32 secret and time step counter.
+func totpCode(secret string, counter uint64) (string, error) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000), nil
+}
+
+// verifyTOTP checks a code against the current time step and one step either
+// side for clock skew. Each time step is accepted only once.
+func verifyTOTP(secret, code string) bool {
+	now := uint64(time.Now().Unix()) / uint64(totpStep.Seconds())
+
+	totpMu.Lock()
+	defer totpMu.Unlock()
+
+	for _, counter := range []uint64{now - 1, now, now + 1} {
+		if counter <= totpLastCounter {
+			continue
+		}
+		expected, err := totpCode(secret, counter)
+		if err != nil {
+			return false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			totpLastCounter = counter
+			return true
+		}
+	}
+	return false
+}
+
+// hashRecoveryCode returns the stored form of a recovery code.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomRecoveryCode returns a code like "a1b2c-3d4e5".
+func randomRecoveryCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	h := hex.EncodeToString(b)
+	return h[:5] + "-" + h[5:], nil
+}
+
+// checkTOTP verifies a TOTP or unused recovery code from ip against the enrolled
+// secret. It reports whether protection is active and whether the code was
+// accepted. Rejected codes count towards ip's login lockout like failed owner
+// logins; while ip is locked out every code is rejected and lockout is set.
+func checkTOTP(ip, code string) (active, ok bool, lockout time.Duration, err error) {
+	secret, err := database.FetchTOTPSecret()
+	if err != nil {
+		return false, false, 0, err
+	}
+	if secret == nil || !secret.Confirmed {
+		return false, true, 0, nil
+	}
+	now := time.Now()
+	if d := logins.lockedFor(ip, now); d > 0 {
+		return true, false, d, nil
+	}
+	if code == "" {
+		return true, false, 0, nil
+	}
+
+	if len(code) == totpDigits && verifyTOTP(secret.Secret, code) {
+		logins.succeed(ip)
+		return true, true, 0, nil
+	}
+	used, err := database.UseRecoveryCode(hashRecoveryCode(code))
+	if err != nil {
+		return true, false, 0, err
+	}
+	if !used {
+		return true, false, logins.fail(ip, now), nil
+	}
+	logins.succeed(ip)
+	recordEvent("2fa", "recovery code used")
+	return true, true, 0, nil
+}
+
+// rejectTOTPLockout answers a request from a client locked out for lockout.
+func rejectTOTPLockout(c *gin.Context, lockout time.Duration) {
+	retryAfter(c, lockout)
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many failed codes, try again later"})
+}
+
+// requireTOTP protects destructive management actions once TOTP is enrolled.
+// Each request must carry a fresh code in the X-TOTP-Code header; only dry runs
+// of routes whose handlers honor them are exempt, since they change nothing.
+func requireTOTP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isDryRun(c) {
+			c.Next()
+			return
+		}
+		active, ok, lockout, err := checkTOTP(c.ClientIP(), c.GetHeader(totpHeader))
+		if err != nil {
+			log.Printf("Failed to verify TOTP: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify TOTP"})
+			return
+		}
+		if lockout > 0 {
+			rejectTOTPLockout(c, lockout)
+			return
+		}
+		if active && !ok {
+			recordEvent("2fa", "rejected %s %s from %s: missing or invalid code", c.Request.Method, c.FullPath(), c.ClientIP())
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error":        "TOTP code required",
+				"totpRequired": true,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+func getTOTPStatusHandler(c *gin.Context) {
+	secret, err := database.FetchTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch TOTP status"})
+		return
+	}
+	remaining, err := database.CountRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch TOTP status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enrolled":      secret != nil && secret.Confirmed,
+		"pending":       secret != nil && secret.PendingSecret() != "",
+		"recoveryCodes": remaining,
+	})
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// enrollTOTPHandler creates a new secret pending confirmation. Replacing an
+// active secret requires a valid code for it, and the active secret keeps
+// protecting actions until the new one is confirmed.
+func enrollTOTPHandler(c *gin.Context) {
+	var req TOTPCodeRequest
+	c.ShouldBindJSON(&req)
+
+	active, ok, lockout, err := checkTOTP(c.ClientIP(), req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify TOTP"})
+		return
+	}
+	if lockout > 0 {
+		rejectTOTPLockout(c, lockout)
+		return
+	}
+	if active && !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "valid code required to re-enroll"})
+		return
+	}
+
+	key := make([]byte, 20)
+	if _, err := rand.Read(key); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(key)
+	if err := database.SaveTOTPSecret(secret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save secret"})
+		return
+	}
+
+	otpauth := fmt.Sprintf("otpauth://totp/%s:manage?secret=%s&issuer=%s&digits=%d&period=%d",
+		url.PathEscape(totpIssuer), secret, url.QueryEscape(totpIssuer), totpDigits, int(totpStep.Seconds()))
+
+	recordEvent("2fa", "enrollment started from %s", c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"secret":     secret,
+		"otpauthUrl": otpauth,
+	})
+}
+
+// confirmTOTPHandler activates a pending secret and returns the recovery codes.
+// The codes are only stored hashed and cannot be shown again.
+func confirmTOTPHandler(c *gin.Context) {
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret, err := database.FetchTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch secret"})
+		return
+	}
+	if secret == nil || secret.PendingSecret() == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no pending enrollment"})
+		return
+	}
+	if !verifyTOTP(secret.PendingSecret(), req.Code) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+		return
+	}
+
+	codes := make([]string, totpRecoveryCodes)
+	hashes := make([]string, totpRecoveryCodes)
+	for i := range codes {
+		code, err := randomRecoveryCode()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+			return
+		}
+		codes[i] = code
+		hashes[i] = hashRecoveryCode(code)
+	}
+	if err := database.ConfirmTOTPSecret(hashes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm enrollment"})
+		return
+	}
+
+	recordEvent("2fa", "enrolled from %s", c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"recoveryCodes": codes,
+	})
+}
+
+func disableTOTPHandler(c *gin.Context) {
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, ok, lockout, err := checkTOTP(c.ClientIP(), req.Code); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify TOTP"})
+		return
+	} else if lockout > 0 {
+		rejectTOTPLockout(c, lockout)
+		return
+	} else if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+		return
+	}
+
+	if err := database.DeleteTOTPSecret(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable 2FA"})
+		return
+	}
+
+	recordEvent("2fa", "disabled from %s", c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// enrollTestTOTP confirms a TOTP secret with a single recovery code.
+func enrollTestTOTP(t *testing.T, d *db.DB, recovery string) {
+	t.Helper()
+	if err := d.SaveTOTPSecret("JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ConfirmTOTPSecret([]string{hashRecoveryCode(recovery)}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// useTestLoginGuard gives the test its own login failures and bans.
+func useTestLoginGuard(t *testing.T, maxFailures int) {
+	prevLogins, prevBans := logins, bans
+	prevMax, prevLockout := loginMaxFailures, loginLockoutSeconds
+	logins = &loginGuard{failures: make(map[string]*loginFailures)}
+	bans = &banList{bans: make(map[string]db.IPBan)}
+	loginMaxFailures, loginLockoutSeconds = maxFailures, 60
+	t.Cleanup(func() {
+		logins, bans = prevLogins, prevBans
+		loginMaxFailures, loginLockoutSeconds = prevMax, prevLockout
+	})
+}
+
+func TestRequireTOTPExemptsOnlyHonoredDryRuns(t *testing.T) {
+	d := useTestDatabase(t)
+	enrollTestTOTP(t, d, "recovery")
+	useTestLoginGuard(t, 100)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	r.POST("/api/miner/shutdown", requireTOTP(), ok)
+	r.POST("/api/miners/shutdown", requireTOTP(), ok)
+	r.DELETE("/api/miners/shutdown", requireTOTP(), ok)
+
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPost, "/api/miners/shutdown", `{"ips":["10.0.0.1"],"dryRun":true}`, http.StatusNoContent},
+		{http.MethodPost, "/api/miners/shutdown", `{"ips":["10.0.0.1"]}`, http.StatusUnauthorized},
+		{http.MethodPost, "/api/miners/shutdown", `{"dryRun":"yes"}`, http.StatusUnauthorized},
+		{http.MethodPost, "/api/miner/shutdown", `{"ip":"10.0.0.1","dryRun":true}`, http.StatusUnauthorized},
+		{http.MethodDelete, "/api/miners/shutdown", `{"dryRun":true}`, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if w.Code != tt.want {
+			t.Errorf("%s %s %s = %d, want %d", tt.method, tt.path, tt.body, w.Code, tt.want)
+		}
+	}
+}
+
+func TestCheckTOTPLocksOutFailedCodes(t *testing.T) {
+	d := useTestDatabase(t)
+	enrollTestTOTP(t, d, "recovery")
+	useTestLoginGuard(t, 2)
+
+	if _, ok, lockout, err := checkTOTP("10.0.0.5", "000000"); err != nil || ok || lockout != 0 {
+		t.Fatalf("first bad code: ok = %v, lockout = %v, err = %v", ok, lockout, err)
+	}
+	if _, ok, lockout, err := checkTOTP("10.0.0.5", "wrong"); err != nil || ok || lockout == 0 {
+		t.Fatalf("second bad code: ok = %v, lockout = %v, err = %v, want a lockout", ok, lockout, err)
+	}
+	if _, ok, lockout, err := checkTOTP("10.0.0.5", "recovery"); err != nil || ok || lockout == 0 {
+		t.Fatalf("code while locked out: ok = %v, lockout = %v, err = %v, want refused", ok, lockout, err)
+	}
+	if _, ok, lockout, err := checkTOTP("10.0.0.6", "recovery"); err != nil || !ok || lockout != 0 {
+		t.Errorf("other client: ok = %v, lockout = %v, err = %v, want the unused recovery code accepted", ok, lockout, err)
+	}
+}
@@ -0,0 +1,512 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tuning defaults and limits.
+const (
+	tuneDwellMinutes      = 10   // measured per point
+	tuneSettleMinutes     = 2    // waited after changing freq/volt before measuring
+	tuneMaxHWErrorsPerMin = 2.0  // hardware errors per minute above which a point is unstable
+	tuneMaxHashrateCV     = 0.10 // hashrate standard deviation over mean above which a point is unstable
+	tuneMaxPoints         = 100
+	tuneSampleInterval    = 30 * time.Second
+	tuneMaxFailedReadings = 3 // summaries in a row the miner may miss before a point is unstable
+	tuneRunsListed        = 20
+)
+
+// Tuning goals: what makes the baseline among the stable points.
+const (
+	tuneGoalEfficiency = "efficiency"
+	tuneGoalHashrate   = "hashrate"
+)
+
+// TuneRequest starts a tuning run: the miner steps through the freq/volt
+// grid from the lowest freq up, volt rising within a freq, and the run is
+// aborted and the miner rolled back at the first unstable point. Zero
+// thresholds and durations take the defaults.
+type TuneRequest struct {
+	IP       string  `json:"ip" binding:"required"`
+	FreqMin  float64 `json:"freqMin" binding:"required"` // MHz
+	FreqMax  float64 `json:"freqMax"`                    // defaults to freqMin
+	FreqStep float64 `json:"freqStep"`
+	VoltMin  float64 `json:"voltMin" binding:"required"` // V
+	VoltMax  float64 `json:"voltMax"`                    // defaults to voltMin
+	VoltStep float64 `json:"voltStep"`
+
+	DwellMinutes      int     `json:"dwellMinutes"`
+	SettleMinutes     int     `json:"settleMinutes"`
+	MaxHWErrorsPerMin float64 `json:"maxHwErrorsPerMin"`
+	MaxHashrateCV     float64 `json:"maxHashrateCv"`
+
+	// Goal picks the baseline among the stable points: "efficiency" (lowest
+	// J/TH, needs power in miner_status) or "hashrate"
+	Goal string `json:"goal"`
+	// ApplyBest leaves the miner at the baseline instead of its previous settings
+	ApplyBest bool `json:"applyBest"`
+}
+
+// TuneParams are the params of a "tune" job.
+type TuneParams struct {
+	RunID int64 `json:"runId"`
+	TuneRequest
+}
+
+// withDefaults fills in the defaults of unset fields.
+func (r TuneRequest) withDefaults() TuneRequest {
+	if r.FreqMax == 0 {
+		r.FreqMax = r.FreqMin
+	}
+	if r.VoltMax == 0 {
+		r.VoltMax = r.VoltMin
+	}
+	if r.DwellMinutes == 0 {
+		r.DwellMinutes = tuneDwellMinutes
+	}
+	if r.SettleMinutes == 0 {
+		r.SettleMinutes = tuneSettleMinutes
+	}
+	if r.MaxHWErrorsPerMin == 0 {
+		r.MaxHWErrorsPerMin = tuneMaxHWErrorsPerMin
+	}
+	if r.MaxHashrateCV == 0 {
+		r.MaxHashrateCV = tuneMaxHashrateCV
+	}
+	if r.Goal == "" {
+		r.Goal = tuneGoalEfficiency
+	}
+	return r
+}
+
+func (r TuneRequest) validate() error {
+	switch {
+	case r.FreqMin <= 0 || r.VoltMin <= 0:
+		return errors.New("freqMin and voltMin must be positive")
+	case r.FreqMax < r.FreqMin || r.VoltMax < r.VoltMin:
+		return errors.New("freqMax and voltMax must not be below their minimum")
+	case r.FreqMax > r.FreqMin && r.FreqStep <= 0, r.VoltMax > r.VoltMin && r.VoltStep <= 0:
+		return errors.New("freqStep and voltStep are required for a range")
+	case r.DwellMinutes < 1 || r.DwellMinutes > 120:
+		return errors.New("dwellMinutes must be between 1 and 120")
+	case r.SettleMinutes < 0 || r.SettleMinutes > 30:
+		return errors.New("settleMinutes must be between 0 and 30")
+	case r.MaxHWErrorsPerMin < 0 || r.MaxHashrateCV < 0:
+		return errors.New("thresholds must not be negative")
+	case r.Goal != tuneGoalEfficiency && r.Goal != tuneGoalHashrate:
+		return errors.New("goal must be efficiency or hashrate")
+	}
+	if n := len(r.grid()); n > tuneMaxPoints {
+		return fmt.Errorf("grid has %d points, at most %d are allowed", n, tuneMaxPoints)
+	}
+	return nil
+}
+
+// gridValues returns min, min+step, ... up to max.
+func gridValues(min, max, step float64) []float64 {
+	if step <= 0 || max <= min {
+		return []float64{min}
+	}
+	var values []float64
+	for i := 0; ; i++ {
+		v := math.Round((min+float64(i)*step)*100) / 100
+		if v > max+1e-9 || i > tuneMaxPoints {
+			break
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// grid returns the freq/volt points in the order they are tried.
+func (r TuneRequest) grid() [][2]float64 {
+	var points [][2]float64
+	for _, f := range gridValues(r.FreqMin, r.FreqMax, r.FreqStep) {
+		for _, v := range gridValues(r.VoltMin, r.VoltMax, r.VoltStep) {
+			points = append(points, [2]float64{f, v})
+		}
+	}
+	return points
+}
+
+// cgminerSummary reads a miner's current hashrate (GH/s) and hardware error
+// counter from the summary command.
+func cgminerSummary(ctx context.Context, ip string) (hashrate, hwErrors float64, err error) {
+	resp, err := cgminerCommand(ctx, ip, "summary", "")
+	if err != nil {
+		return 0, 0, err
+	}
+	s, ok := firstObject(resp, "SUMMARY")
+	if !ok {
+		return 0, 0, errors.New("summary returned no data")
+	}
+	hashrate = numberField(s, "GHS 5s")
+	if hashrate == 0 {
+		hashrate = numberField(s, "MHS 5s") / 1000
+	}
+	return hashrate, numberField(s, "Hardware Errors"), nil
+}
+
+// sleepCtx waits for d, returning early with ctx's error when it is done.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// measurePoint lets the miner settle at a freq/volt and samples its hashrate
+// and hardware errors for the dwell time. Power is the miner_status average
+// over the dwell.
+func measurePoint(ctx context.Context, ip string, freq, volt float64, r TuneRequest) (db.TuningPoint, error) {
+	p := db.TuningPoint{Freq: freq, Volt: volt}
+	if err := sleepCtx(ctx, time.Duration(r.SettleMinutes)*time.Minute); err != nil {
+		return p, err
+	}
+
+	from := time.Now()
+	_, hwStart, err := cgminerSummary(ctx, ip)
+	if err != nil {
+		p.Reason = fmt.Sprintf("miner did not answer after the change: %v", err)
+		p.MeasuredAt = time.Now()
+		return p, nil
+	}
+
+	var samples []float64
+	hwEnd, failures := hwStart, 0
+	for deadline := from.Add(time.Duration(r.DwellMinutes) * time.Minute); time.Now().Before(deadline); {
+		if err := sleepCtx(ctx, tuneSampleInterval); err != nil {
+			return p, err
+		}
+		hashrate, hw, err := cgminerSummary(ctx, ip)
+		if err != nil {
+			if failures++; failures >= tuneMaxFailedReadings {
+				p.Reason = fmt.Sprintf("miner stopped answering: %v", err)
+				break
+			}
+			continue
+		}
+		failures = 0
+		if hw < hwEnd {
+			p.Reason = "miner restarted"
+			break
+		}
+		samples = append(samples, hashrate)
+		hwEnd = hw
+	}
+	p.MeasuredAt = time.Now()
+	if p.Reason != "" {
+		return p, nil
+	}
+
+	var sum, sumSq float64
+	for _, s := range samples {
+		sum += s
+		sumSq += s * s
+	}
+	if len(samples) > 0 {
+		mean := sum / float64(len(samples))
+		p.Hashrate = math.Round(mean*10) / 10
+		if mean > 0 {
+			variance := math.Max(sumSq/float64(len(samples))-mean*mean, 0)
+			p.HashrateCV = math.Round(math.Sqrt(variance)/mean*1000) / 1000
+		}
+	}
+	p.HWErrorsPerMin = math.Round((hwEnd-hwStart)/p.MeasuredAt.Sub(from).Minutes()*100) / 100
+
+	if averages, err := questdbClient.WithContext(ctx).GetMinerAverages(from, p.MeasuredAt); err != nil {
+		log.Printf("Tuning %s: failed to read power: %v", ip, err)
+	} else if a, ok := averages[ip]; ok && a.AvgPower > 0 {
+		p.Power = math.Round(a.AvgPower)
+		if p.Hashrate > 0 {
+			p.Efficiency = math.Round(p.Power/(p.Hashrate/1000)*10) / 10
+		}
+	}
+
+	switch {
+	case p.Hashrate <= 0:
+		p.Reason = "no hashrate"
+	case p.HWErrorsPerMin > r.MaxHWErrorsPerMin:
+		p.Reason = fmt.Sprintf("%.2f hardware errors/min is above %.2f", p.HWErrorsPerMin, r.MaxHWErrorsPerMin)
+	case p.HashrateCV > r.MaxHashrateCV:
+		p.Reason = fmt.Sprintf("hashrate variation %.3f is above %.3f", p.HashrateCV, r.MaxHashrateCV)
+	default:
+		p.Stable = true
+	}
+	return p, nil
+}
+
+// bestPoint picks the baseline among the stable points. Points without a
+// power reading can't be judged on efficiency and lose to those with one;
+// between them the highest hashrate wins.
+func bestPoint(points []db.TuningPoint, goal string) *db.TuningPoint {
+	better := func(p, best *db.TuningPoint) bool {
+		if goal == tuneGoalEfficiency && (p.Efficiency > 0) != (best.Efficiency > 0) {
+			return p.Efficiency > 0
+		}
+		if goal == tuneGoalEfficiency && p.Efficiency > 0 {
+			return p.Efficiency < best.Efficiency
+		}
+		return p.Hashrate > best.Hashrate
+	}
+	var best *db.TuningPoint
+	for i := range points {
+		if p := &points[i]; p.Stable && (best == nil || better(p, best)) {
+			best = p
+		}
+	}
+	return best
+}
+
+// runTuning is the "tune" job kind. The miner's settings before the run are
+// put back when it ends, unless applyBest leaves it at the baseline of a
+// completed run.
+func runTuning(ctx context.Context, params json.RawMessage, ip string) error {
+	var p TuneParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return err
+	}
+	r := p.TuneRequest
+
+	var points []db.TuningPoint
+	finish := func(status string, best *db.TuningPoint, runErr error) error {
+		msg := ""
+		if runErr != nil {
+			msg = runErr.Error()
+		}
+		if err := database.UpdateTuningRun(p.RunID, status, points, best, msg); err != nil {
+			log.Printf("Tuning run %d: failed to record %s: %v", p.RunID, status, err)
+		}
+		recordEvent("tuning", "tuning run %d of %s %s", p.RunID, minerName(ip), status)
+		return runErr
+	}
+
+	previous, err := currentState(ctx, ip)
+	if err != nil {
+		return finish(db.TuningFailed, nil, fmt.Errorf("current settings can't be restored afterwards: %w", err))
+	}
+	rollback := func() error {
+		// The rollback also runs for cancelled runs, so it can't use ctx
+		if err := restoreState(context.Background(), ip, *previous); err != nil {
+			log.Printf("Tuning run %d: failed to roll back %s: %v", p.RunID, ip, err)
+			return fmt.Errorf("rollback failed: %w", err)
+		}
+		log.Printf("Tuning run %d: rolled back %s to %s", p.RunID, ip, previous.WorkMode)
+		return nil
+	}
+	if err := database.UpdateTuningRun(p.RunID, db.TuningRunning, nil, nil, ""); err != nil {
+		log.Printf("Tuning run %d: failed to record start: %v", p.RunID, err)
+	}
+
+	limits, err := loadLimits()
+	if err != nil {
+		return finish(db.TuningFailed, nil, err)
+	}
+
+	for _, fv := range r.grid() {
+		freq, volt := fv[0], fv[1]
+		if _, _, violation := limits.freqVolt(ip, freq, volt); violation != "" {
+			points = append(points, db.TuningPoint{Freq: freq, Volt: volt, Reason: "skipped: " + violation, MeasuredAt: time.Now()})
+			continue
+		}
+		if emergency.locked() {
+			return finish(db.TuningAborted, nil, errors.Join(errors.New("emergency lockout active"), rollback()))
+		}
+
+		if err := driverFor(ip).SetFreqVolt(context.Background(), ip, freq, volt); err != nil {
+			return finish(db.TuningAborted, nil, errors.Join(fmt.Errorf("set %.0f MHz / %.2f V: %w", freq, volt, err), rollback()))
+		}
+		point, err := measurePoint(ctx, ip, freq, volt, r)
+		if err != nil {
+			return finish(db.TuningCancelled, nil, errors.Join(err, rollback()))
+		}
+		points = append(points, point)
+		if err := database.UpdateTuningRun(p.RunID, db.TuningRunning, points, nil, ""); err != nil {
+			log.Printf("Tuning run %d: failed to record point: %v", p.RunID, err)
+		}
+		log.Printf("Tuning run %d: %s at %.0f MHz / %.2f V: %.1f GH/s, cv %.3f, %.2f HW errors/min, stable %v",
+			p.RunID, ip, freq, volt, point.Hashrate, point.HashrateCV, point.HWErrorsPerMin, point.Stable)
+		if !point.Stable {
+			return finish(db.TuningAborted, bestPoint(points, r.Goal), errors.Join(
+				fmt.Errorf("unstable at %.0f MHz / %.2f V: %s", freq, volt, point.Reason), rollback()))
+		}
+	}
+
+	best := bestPoint(points, r.Goal)
+	if best != nil {
+		baseline := db.Baseline{
+			MachineIP:  ip,
+			Freq:       best.Freq,
+			Volt:       best.Volt,
+			Hashrate:   best.Hashrate,
+			Power:      best.Power,
+			Efficiency: best.Efficiency,
+			RunID:      p.RunID,
+			MeasuredAt: best.MeasuredAt,
+		}
+		if err := database.SaveBaseline(baseline); err != nil {
+			log.Printf("Tuning run %d: failed to save baseline of %s: %v", p.RunID, ip, err)
+		}
+	}
+
+	if r.ApplyBest && best != nil {
+		if err := driverFor(ip).SetFreqVolt(context.Background(), ip, best.Freq, best.Volt); err != nil {
+			return finish(db.TuningFailed, best, errors.Join(fmt.Errorf("apply baseline: %w", err), rollback()))
+		}
+		return finish(db.TuningDone, best, nil)
+	}
+	return finish(db.TuningDone, best, rollback())
+}
+
+// hasActiveTuning reports whether a tuning run of the miner is queued or
+// running.
+func hasActiveTuning(ip string) (bool, error) {
+	runs, err := database.FetchTuningRuns(ip, 1)
+	if err != nil {
+		return false, err
+	}
+	return len(runs) > 0 && (runs[0].Status == db.TuningQueued || runs[0].Status == db.TuningRunning), nil
+}
+
+// startTuningHandler queues a "tune" job for one miner. Grids reaching
+// outside the miner's model profile are refused, or with --limit-mode clamp
+// have those points skipped.
+func startTuningHandler(c *gin.Context) {
+	var req TuneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req = req.withDefaults()
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	known := false
+	for _, m := range currentMachines() {
+		known = known || m.IP == req.IP
+	}
+	if !known {
+		c.JSON(http.StatusNotFound, gin.H{"error": "machine not found"})
+		return
+	}
+	if !driverFor(req.IP).Capabilities().SupportsFreqVolt {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "firmware does not support freq/volt"})
+		return
+	}
+
+	if _, ok := checkLimits(c, []string{req.IP}, false, func(l operatingLimits, ip string) string {
+		for _, fv := range req.grid() {
+			if _, _, violation := l.freqVolt(ip, fv[0], fv[1]); violation != "" {
+				return violation
+			}
+		}
+		return ""
+	}); !ok {
+		return
+	}
+
+	active, err := hasActiveTuning(req.IP)
+	if err != nil {
+		log.Printf("Failed to fetch tuning runs of %s: %v", req.IP, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tuning runs"})
+		return
+	}
+	if active {
+		c.JSON(http.StatusConflict, gin.H{"error": "a tuning run of this miner is already queued or running"})
+		return
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start tuning run"})
+		return
+	}
+	runID, err := database.CreateTuningRun(req.IP, string(data))
+	if err != nil {
+		log.Printf("Failed to create tuning run of %s: %v", req.IP, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start tuning run"})
+		return
+	}
+	jobID, err := jobs.enqueue("tune", TuneParams{RunID: runID, TuneRequest: req}, []string{req.IP})
+	if err != nil {
+		log.Printf("Failed to queue tune job: %v", err)
+		if err := database.UpdateTuningRun(runID, db.TuningFailed, nil, nil, "failed to queue job"); err != nil {
+			log.Printf("Tuning run %d: failed to record failure: %v", runID, err)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue job"})
+		return
+	}
+	if err := database.SetTuningRunJob(runID, jobID); err != nil {
+		log.Printf("Tuning run %d: failed to record job %d: %v", runID, jobID, err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"runId":   runID,
+		"jobId":   jobID,
+		"points":  len(req.grid()),
+	})
+}
+
+// getTuningRunsHandler lists recent tuning runs, of one miner with ?ip=.
+func getTuningRunsHandler(c *gin.Context) {
+	runs, err := database.FetchTuningRuns(c.Query("ip"), tuneRunsListed)
+	if err != nil {
+		log.Printf("Failed to fetch tuning runs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tuning runs"})
+		return
+	}
+	if runs == nil {
+		runs = []db.TuningRun{}
+	}
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
+
+func getTuningRunHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid run id"})
+		return
+	}
+	run, err := database.FetchTuningRun(id)
+	if err != nil {
+		log.Printf("Failed to fetch tuning run %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tuning run"})
+		return
+	}
+	if run == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tuning run not found"})
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+// getBaselinesHandler lists the freq/volt baselines found by tuning runs.
+func getBaselinesHandler(c *gin.Context) {
+	baselines, err := database.FetchBaselines()
+	if err != nil {
+		log.Printf("Failed to fetch baselines: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch baselines"})
+		return
+	}
+	if baselines == nil {
+		baselines = []db.Baseline{}
+	}
+	c.JSON(http.StatusOK, gin.H{"baselines": baselines})
+}
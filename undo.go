@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"miningRoom/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// undoMinutes is how long after it finished a bulk job can be undone.
+var undoMinutes int
+
+// undoableKinds are the job kinds whose per-miner previous state is recorded.
+var undoableKinds = map[string]bool{
+	"power":    true,
+	"freqvolt": true,
+	"sleep":    true,
+}
+
+// RestoreParams are the params of a "restore" job: the state to restore per
+// miner, as recorded by the job being undone.
+type RestoreParams struct {
+	UndoOf int64                      `json:"undoOf"`
+	States map[string]db.DesiredState `json:"states"`
+}
+
+// currentState reads the miner's current work mode and targets in a form
+// restoreState can put back.
+func currentState(ctx context.Context, ip string) (*db.DesiredState, error) {
+	cfg, err := driverFor(ip).Config(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+	state := &db.DesiredState{MachineIP: ip, WorkMode: cfg.WorkMode}
+	switch cfg.WorkMode {
+	case "Auto":
+		if cfg.ModeSelect != "PowerTarget" {
+			return nil, fmt.Errorf("auto mode %q cannot be restored", cfg.ModeSelect)
+		}
+		state.PowerTarget = int(cfg.TargetValue)
+	case "Fixed":
+		state.Freq, state.Volt = cfg.TargetFreq, cfg.TargetVolt
+	case "Sleep":
+	default:
+		return nil, fmt.Errorf("work mode %q cannot be restored", cfg.WorkMode)
+	}
+	return state, nil
+}
+
+// snapshotMiner reads the miner's current work mode and targets as JSON.
+func snapshotMiner(ctx context.Context, ip string) (string, error) {
+	state, err := currentState(ctx, ip)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(state)
+	return string(data), err
+}
+
+// recordPrevious stores a miner's state before an undoable job changes it.
+// Miners whose state can't be read are changed anyway but can't be undone.
+func recordPrevious(ctx context.Context, job *db.Job, ip string) {
+	if !undoableKinds[job.Kind] {
+		return
+	}
+	previous, err := snapshotMiner(ctx, ip)
+	if err != nil {
+		log.Printf("Job %d: no undo state for %s: %v", job.ID, ip, err)
+		return
+	}
+	if err := database.SetJobTargetPrevious(job.ID, ip, previous); err != nil {
+		log.Printf("Job %d: failed to store undo state for %s: %v", job.ID, ip, err)
+	}
+}
+
+// restoreMiner is the "restore" job kind. Power targets are restored as
+// recorded, without the forecast/noise adjustment.
+func restoreMiner(ctx context.Context, params json.RawMessage, ip string) error {
+	var req RestoreParams
+	if err := json.Unmarshal(params, &req); err != nil {
+		return err
+	}
+	s, ok := req.States[ip]
+	if !ok {
+		return errors.New("no recorded state")
+	}
+	return restoreState(ctx, ip, s)
+}
+
+// restoreState puts a miner back into a recorded work mode and targets.
+func restoreState(ctx context.Context, ip string, s db.DesiredState) error {
+	d := driverFor(ip)
+	switch s.WorkMode {
+	case "Auto":
+		return d.SetPowerTarget(ctx, ip, s.PowerTarget)
+	case "Fixed":
+		return d.SetFreqVolt(ctx, ip, s.Freq, s.Volt)
+	case "Sleep":
+		return d.SetSleep(ctx, ip)
+	}
+	return fmt.Errorf("unknown work mode %q", s.WorkMode)
+}
+
+// undoDeadline returns until when a job can be undone, or nil if it can't.
+func undoDeadline(j db.Job) *time.Time {
+	if !undoableKinds[j.Kind] || j.FinishedAt == nil || j.UndoneBy != 0 {
+		return nil
+	}
+	deadline := j.FinishedAt.Add(time.Duration(undoMinutes) * time.Minute)
+	return &deadline
+}
+
+// undoJobHandler queues a restore job that puts the miners changed by a bulk
+// job back into their previous state.
+func undoJobHandler(c *gin.Context) {
+	id, ok := jobIDParam(c)
+	if !ok {
+		return
+	}
+
+	// Serialized so a job is only undone once
+	jobs.claimMu.Lock()
+	defer jobs.claimMu.Unlock()
+
+	job, err := database.FetchJob(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to fetch job %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch job"})
+		return
+	}
+	switch {
+	case !undoableKinds[job.Kind]:
+		c.JSON(http.StatusBadRequest, gin.H{"error": job.Kind + " jobs cannot be undone"})
+		return
+	case job.UndoneBy != 0:
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("job already undone by job %d", job.UndoneBy)})
+		return
+	case job.FinishedAt == nil:
+		c.JSON(http.StatusConflict, gin.H{"error": "job is still " + job.Status})
+		return
+	case time.Now().After(*undoDeadline(*job)):
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("undo window of %d minutes has passed", undoMinutes)})
+		return
+	}
+
+	params := RestoreParams{UndoOf: id, States: make(map[string]db.DesiredState)}
+	var ips []string
+	for _, t := range job.Targets {
+		if t.Status != db.TargetDone || t.Previous == "" {
+			continue
+		}
+		var s db.DesiredState
+		if err := json.Unmarshal([]byte(t.Previous), &s); err != nil {
+			continue
+		}
+		params.States[t.IP] = s
+		ips = append(ips, t.IP)
+	}
+	if len(ips) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "no miner of the job has a recorded previous state"})
+		return
+	}
+
+	undoID, err := jobs.enqueue("restore", params, ips)
+	if err != nil {
+		log.Printf("Failed to queue undo of job %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue job"})
+		return
+	}
+	if err := database.SetJobUndone(id, undoID); err != nil {
+		log.Printf("Failed to mark job %d undone: %v", id, err)
+	}
+
+	recordEvent("jobs", "job %d (%s) undone by job %d from %s", id, job.Kind, undoID, c.ClientIP())
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"jobId":   undoID,
+		"ips":     ips,
+		"count":   len(ips),
+	})
+}
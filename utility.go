@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"miningRoom/db"
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	utilityCSVDelimiter   string // --utility-csv-delimiter
+	utilityCSVDecimal     string // --utility-csv-decimal: decimal separator of kWh values
+	utilityCSVTimeFormat  string // --utility-csv-time-format: Go time layout
+	utilityCSVColumns     string // --utility-csv-columns: timestamp and kWh column headers
+	utilityCSVIntervalEnd bool   // --utility-csv-interval-end: timestamps mark the end of the interval
+	utilityTimezone       string // --utility-timezone: zone of the CSV timestamps and report days, empty for --timezone
+
+	utilityLayout utilityCSVLayout // parsed from the flags in main
+)
+
+const (
+	// utilityInterval is the metering interval of utility smart meters.
+	utilityInterval = 15 * time.Minute
+	// utilityMaxRange is the longest window of the export and reconciliation.
+	utilityMaxRange = 366 * 24 * time.Hour
+	// utilityDefaultRange is the reconciliation window without ?range= or ?from=.
+	utilityDefaultRange = 30 * 24 * time.Hour
+	// utilityMinDayKWh is the billed energy below which a day is left out of
+	// the drift trend, since its percentage difference is mostly noise.
+	utilityMinDayKWh = 1.0
+	// utilityMinTrendDays is the number of days the drift trend needs.
+	utilityMinTrendDays = 7
+	// utilityMaxBody fits a year of intervals with a few extra columns.
+	utilityMaxBody = 8 << 20
+)
+
+// utilityCSVLayout is the CSV layout of the utility's interval export, used
+// both to export measured consumption and to import billed consumption.
+type utilityCSVLayout struct {
+	comma       rune
+	decimal     string
+	timeFormat  string
+	timeColumn  string
+	kwhColumn   string
+	intervalEnd bool
+	location    *time.Location
+}
+
+// parseUtilityCSVLayout builds the layout from the --utility-csv-* flags.
+func parseUtilityCSVLayout() (utilityCSVLayout, error) {
+	l := utilityCSVLayout{
+		decimal:     utilityCSVDecimal,
+		timeFormat:  utilityCSVTimeFormat,
+		intervalEnd: utilityCSVIntervalEnd,
+	}
+	delimiter := utilityCSVDelimiter
+	if delimiter == `\t` {
+		delimiter = "\t"
+	}
+	if utf8.RuneCountInString(delimiter) != 1 {
+		return l, fmt.Errorf("delimiter must be a single character")
+	}
+	l.comma, _ = utf8.DecodeRuneInString(delimiter)
+	if l.decimal != "." && l.decimal != "," {
+		return l, fmt.Errorf("decimal separator must be . or ,")
+	}
+	if l.decimal == delimiter {
+		return l, fmt.Errorf("decimal separator and delimiter must differ")
+	}
+	if l.timeFormat == "" {
+		return l, fmt.Errorf("time format is empty")
+	}
+	columns := strings.Split(utilityCSVColumns, ",")
+	if len(columns) != 2 || strings.TrimSpace(columns[0]) == "" || strings.TrimSpace(columns[1]) == "" {
+		return l, fmt.Errorf("columns must be the timestamp and kWh headers, comma-separated")
+	}
+	l.timeColumn, l.kwhColumn = strings.TrimSpace(columns[0]), strings.TrimSpace(columns[1])
+	l.location = roomLocation
+	if utilityTimezone != "" {
+		loc, err := time.LoadLocation(utilityTimezone)
+		if err != nil {
+			return l, err
+		}
+		l.location = loc
+	}
+	return l, nil
+}
+
+// formatTime renders the timestamp of the interval starting at start.
+func (l utilityCSVLayout) formatTime(start time.Time) string {
+	if l.intervalEnd {
+		start = start.Add(utilityInterval)
+	}
+	return start.In(l.location).Format(l.timeFormat)
+}
+
+// parseTime returns the start of the interval labeled s.
+func (l utilityCSVLayout) parseTime(s string) (time.Time, error) {
+	t, err := time.ParseInLocation(l.timeFormat, strings.TrimSpace(s), l.location)
+	if err != nil {
+		return t, err
+	}
+	if l.intervalEnd {
+		t = t.Add(-utilityInterval)
+	}
+	return t.UTC(), nil
+}
+
+func (l utilityCSVLayout) formatKWh(kwh float64) string {
+	s := strconv.FormatFloat(kwh, 'f', 3, 64)
+	if l.decimal == "," {
+		s = strings.Replace(s, ".", ",", 1)
+	}
+	return s
+}
+
+func (l utilityCSVLayout) parseKWh(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if l.decimal == "," {
+		s = strings.Replace(s, ",", ".", 1)
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// writeCSV renders the intervals with a header row.
+func (l utilityCSVLayout) writeCSV(intervals []questdb.IntervalEnergy) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = l.comma
+	w.UseCRLF = true
+	w.Write([]string{l.timeColumn, l.kwhColumn})
+	for _, in := range intervals {
+		w.Write([]string{l.formatTime(in.Start), l.formatKWh(in.KWh)})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// readCSV parses a utility export. The timestamp and kWh columns are found by
+// their headers, or taken as the first two columns without a header row; other
+// columns and rows before the header are ignored. Rows without a valid
+// timestamp, such as totals, are skipped and counted; rows with an empty value
+// are skipped.
+func (l utilityCSVLayout) readCSV(data []byte) (intervals []db.UtilityInterval, skipped int, err error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	r.Comma = l.comma
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	timeCol, kwhCol, first := 0, 1, 0
+	for i, rec := range records {
+		t, k := -1, -1
+		for j, field := range rec {
+			switch strings.TrimSpace(field) {
+			case l.timeColumn:
+				t = j
+			case l.kwhColumn:
+				k = j
+			}
+		}
+		if t >= 0 && k >= 0 {
+			timeCol, kwhCol, first = t, k, i+1
+			break
+		}
+	}
+
+	for i, rec := range records[first:] {
+		line := first + i + 1
+		if len(rec) <= timeCol || len(rec) <= kwhCol {
+			skipped++
+			continue
+		}
+		start, err := l.parseTime(rec[timeCol])
+		if err != nil {
+			skipped++
+			continue
+		}
+		if strings.TrimSpace(rec[kwhCol]) == "" {
+			continue
+		}
+		kwh, err := l.parseKWh(rec[kwhCol])
+		if err != nil || kwh < 0 || math.IsNaN(kwh) || math.IsInf(kwh, 0) {
+			return nil, 0, fmt.Errorf("line %d: invalid kWh %q", line, rec[kwhCol])
+		}
+		intervals = append(intervals, db.UtilityInterval{Start: start, KWh: kwh})
+	}
+	return intervals, skipped, nil
+}
+
+// utilityWindow parses ?range= or ?from=&to= cut to whole intervals. Without
+// either, the window is def ending now.
+func utilityWindow(c *gin.Context, def time.Duration) (from, to time.Time, err error) {
+	from, to, err = parseTimeWindow(c, requestNow(c))
+	if err != nil {
+		return from, to, err
+	}
+	if c.Query("range") == "" && c.Query("from") == "" {
+		from = to.Add(-def)
+	}
+	from, to = from.UTC().Truncate(utilityInterval), to.UTC().Truncate(utilityInterval)
+	if !from.Before(to) {
+		return from, to, fmt.Errorf("window must contain a whole interval")
+	}
+	if to.Sub(from) > utilityMaxRange {
+		return from, to, fmt.Errorf("range is limited to 366 days")
+	}
+	return from, to, nil
+}
+
+// exportUtilityCSVHandler downloads the measured consumption per 15 minute
+// interval over ?range= or ?from=&to= (default 24 hours) in the utility's CSV
+// layout.
+func exportUtilityCSVHandler(c *gin.Context) {
+	from, to, err := utilityWindow(c, 24*time.Hour)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	intervals, err := questdbFor(c).GetIntervalEnergy(from, to)
+	if err != nil {
+		log.Printf("Failed to get interval energy from QuestDB: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to query QuestDB"})
+		return
+	}
+	data, err := utilityLayout.writeCSV(intervals)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := fmt.Sprintf("consumption-%s-%s.csv", from.In(utilityLayout.location).Format("20060102"), to.In(utilityLayout.location).Format("20060102"))
+	c.Header("Content-Disposition", "attachment; filename="+name)
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
+}
+
+// importUtilityCSVHandler stores the utility's interval export from the
+// request body. Intervals imported before are replaced.
+func importUtilityCSVHandler(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, utilityMaxBody))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "body too large"})
+		return
+	}
+	intervals, skipped, err := utilityLayout.readCSV(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(intervals) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no intervals found; check --utility-csv-time-format and --utility-csv-columns"})
+		return
+	}
+	if err := database.SaveUtilityIntervals(intervals); err != nil {
+		log.Printf("Failed to save utility intervals: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save intervals"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"intervals": len(intervals),
+		"skipped":   skipped,
+		"from":      intervals[0].Start,
+		"to":        intervals[len(intervals)-1].Start.Add(utilityInterval),
+	})
+}
+
+// UtilityReconciliationDay compares one day (in --utility-timezone) of
+// measured and billed energy over the intervals present in both.
+type UtilityReconciliationDay struct {
+	Date        string   `json:"date"`
+	Intervals   int      `json:"intervals"`
+	MeasuredKWh float64  `json:"measuredKwh"`
+	UtilityKWh  float64  `json:"utilityKwh"`
+	DiffKWh     float64  `json:"diffKwh"` // measured - utility
+	DiffPct     *float64 `json:"diffPct"` // nil without billed energy
+}
+
+// UtilityReconciliation compares the energy measured by the plugs and room
+// meter with the utility's imported interval data. A steady DiffPct is a
+// calibration offset; DriftPctPer30d is how fast it changes.
+type UtilityReconciliation struct {
+	From            time.Time                  `json:"from"`
+	To              time.Time                  `json:"to"`
+	Intervals       int                        `json:"intervals"`       // present in both
+	MissingMeasured int                        `json:"missingMeasured"` // billed intervals without measurements
+	MissingUtility  int                        `json:"missingUtility"`  // measured intervals not imported
+	MeasuredKWh     float64                    `json:"measuredKwh"`
+	UtilityKWh      float64                    `json:"utilityKwh"`
+	DiffKWh         float64                    `json:"diffKwh"`
+	DiffPct         *float64                   `json:"diffPct"`
+	DriftPctPer30d  *float64                   `json:"driftPctPer30d"` // nil with too few days
+	Days            []UtilityReconciliationDay `json:"days"`
+	HasData         bool                       `json:"hasData"`
+}
+
+// buildUtilityReconciliation matches measured and billed intervals between from
+// and to.
+func buildUtilityReconciliation(qdb *questdb.Client, from, to time.Time) (*UtilityReconciliation, error) {
+	billed, err := database.FetchUtilityIntervals(from, to)
+	if err != nil {
+		return nil, err
+	}
+	measured, err := qdb.GetIntervalEnergy(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	utility := make(map[time.Time]float64, len(billed))
+	for _, in := range billed {
+		utility[in.Start.UTC()] = in.KWh
+	}
+
+	rec := &UtilityReconciliation{From: from, To: to, Days: []UtilityReconciliationDay{}}
+	var day *UtilityReconciliationDay
+	matched := 0
+	for _, m := range measured {
+		kwh, ok := utility[m.Start.UTC()]
+		if !ok {
+			rec.MissingUtility++
+			continue
+		}
+		matched++
+		date := m.Start.In(utilityLayout.location).Format("2006-01-02")
+		if day == nil || day.Date != date {
+			rec.Days = append(rec.Days, UtilityReconciliationDay{Date: date})
+			day = &rec.Days[len(rec.Days)-1]
+		}
+		day.Intervals++
+		day.MeasuredKWh += m.KWh
+		day.UtilityKWh += kwh
+		rec.MeasuredKWh += m.KWh
+		rec.UtilityKWh += kwh
+	}
+	rec.Intervals = matched
+	rec.MissingMeasured = len(billed) - matched
+
+	var xs, ys []float64
+	for i := range rec.Days {
+		d := &rec.Days[i]
+		d.DiffKWh = math.Round((d.MeasuredKWh-d.UtilityKWh)*1000) / 1000
+		d.DiffPct = diffPct(d.MeasuredKWh, d.UtilityKWh)
+		if d.UtilityKWh >= utilityMinDayKWh {
+			t, _ := time.Parse("2006-01-02", d.Date)
+			xs = append(xs, t.Sub(from).Hours()/24)
+			ys = append(ys, *d.DiffPct)
+		}
+		d.MeasuredKWh = math.Round(d.MeasuredKWh*1000) / 1000
+		d.UtilityKWh = math.Round(d.UtilityKWh*1000) / 1000
+	}
+	if slope, ok := linearSlope(xs, ys); ok && len(xs) >= utilityMinTrendDays {
+		v := math.Round(slope*30*100) / 100
+		rec.DriftPctPer30d = &v
+	}
+
+	rec.DiffKWh = math.Round((rec.MeasuredKWh-rec.UtilityKWh)*100) / 100
+	rec.DiffPct = diffPct(rec.MeasuredKWh, rec.UtilityKWh)
+	rec.MeasuredKWh = math.Round(rec.MeasuredKWh*100) / 100
+	rec.UtilityKWh = math.Round(rec.UtilityKWh*100) / 100
+	rec.HasData = matched > 0
+	return rec, nil
+}
+
+// diffPct is how far measured is from billed, in percent of billed.
+func diffPct(measured, billed float64) *float64 {
+	if billed <= 0 {
+		return nil
+	}
+	v := math.Round((measured-billed)/billed*10000) / 100
+	return &v
+}
+
+// linearSlope is the least squares slope of ys against xs; false without
+// spread in xs.
+func linearSlope(xs, ys []float64) (float64, bool) {
+	n := float64(len(xs))
+	if len(xs) < 2 {
+		return 0, false
+	}
+	var sx, sy, sxx, sxy float64
+	for i := range xs {
+		sx += xs[i]
+		sy += ys[i]
+		sxx += xs[i] * xs[i]
+		sxy += xs[i] * ys[i]
+	}
+	d := sxx - sx*sx/n
+	if d <= 1e-9 {
+		return 0, false
+	}
+	return (sxy - sx*sy/n) / d, true
+}
+
+// getUtilityReconciliationHandler compares measured and imported utility
+// energy over ?range= or ?from=&to= (default 30 days).
+func getUtilityReconciliationHandler(c *gin.Context) {
+	from, to, err := utilityWindow(c, utilityDefaultRange)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rec, err := buildUtilityReconciliation(questdbFor(c), from, to)
+	if err != nil {
+		log.Printf("Failed to build utility reconciliation: %v", err)
+		c.JSON(http.StatusOK, gin.H{
+			"days":    []interface{}{},
+			"hasData": false,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, rec)
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// vnishPass is the web password used to unlock the Vnish API.
+var vnishPass string
+
+// vnishDriver controls Antminers running Vnish through its HTTP JSON API.
+// Power is set by switching to the closest autotune preset within the target.
+type vnishDriver struct{}
+
+// vnishRequest calls the Vnish API. Write endpoints need a bearer token from
+// unlock; pass an empty token for reads.
+func vnishRequest(ctx context.Context, ip, method, path, token string, payload, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("http://%s/api/v1%s", urlHost(ip), path), body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s returned status %d: %s", method, path, resp.StatusCode, string(respBody))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// vnishUnlock returns an API token for write requests.
+func vnishUnlock(ctx context.Context, ip string) (string, error) {
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := vnishRequest(ctx, ip, http.MethodPost, "/unlock", "", map[string]string{"pw": vnishPass}, &resp); err != nil {
+		return "", err
+	}
+	if resp.Token == "" {
+		return "", errors.New("unlock returned no token")
+	}
+	return resp.Token, nil
+}
+
+// vnishPresets lists the autotune presets. Preset names are their power in
+// watts, e.g. "1800".
+func vnishPresets(ctx context.Context, ip string) ([]powerProfile, error) {
+	var presets []struct {
+		Name string `json:"name"`
+	}
+	if err := vnishRequest(ctx, ip, http.MethodGet, "/autotune/presets", "", nil, &presets); err != nil {
+		return nil, err
+	}
+
+	var profiles []powerProfile
+	for _, p := range presets {
+		watts, err := strconv.ParseFloat(strings.TrimSpace(p.Name), 64)
+		if err != nil {
+			continue // e.g. "disabled"
+		}
+		profiles = append(profiles, powerProfile{Name: p.Name, Watts: watts})
+	}
+	return profiles, nil
+}
+
+func (vnishDriver) Config(ctx context.Context, ip string) (*MinerManageInfo, error) {
+	var summary struct {
+		Miner struct {
+			MinerStatus struct {
+				MinerState string `json:"miner_state"`
+			} `json:"miner_status"`
+			Chains []struct {
+				ID         int     `json:"id"`
+				HashrateRT float64 `json:"hashrate_rt"` // GH/s
+				PowerW     float64 `json:"power_consumption"`
+				PcbTemp    struct {
+					Max float64 `json:"max"`
+				} `json:"pcb_temp"`
+				ChipTemp struct {
+					Max float64 `json:"max"`
+				} `json:"chip_temp"`
+				Status struct {
+					State string `json:"state"`
+				} `json:"status"`
+			} `json:"chains"`
+		} `json:"miner"`
+	}
+	if err := vnishRequest(ctx, ip, http.MethodGet, "/summary", "", nil, &summary); err != nil {
+		return nil, err
+	}
+
+	info := &MinerManageInfo{
+		Online:              true,
+		WorkMode:            "Auto",
+		ModeSelect:          "PowerTarget",
+		ModeSelectAvailable: []string{"PowerTarget"},
+	}
+	if state := summary.Miner.MinerStatus.MinerState; state == "stopped" || state == "paused" {
+		info.WorkMode = "Sleep"
+	}
+	for _, ch := range summary.Miner.Chains {
+		info.Chains = append(info.Chains, ChainInfo{
+			Index:      ch.ID,
+			Status:     ch.Status.State,
+			HashrateGH: math.Round(ch.HashrateRT*10) / 10,
+			BoardTemp:  ch.PcbTemp.Max,
+			ChipTemp:   ch.ChipTemp.Max,
+			PowerW:     ch.PowerW,
+		})
+	}
+
+	var settings struct {
+		Miner struct {
+			Overclock struct {
+				Preset string `json:"preset"`
+			} `json:"overclock"`
+		} `json:"miner"`
+	}
+	if err := vnishRequest(ctx, ip, http.MethodGet, "/settings", "", nil, &settings); err == nil {
+		info.Profile = settings.Miner.Overclock.Preset
+		if watts, err := strconv.ParseFloat(info.Profile, 64); err == nil {
+			info.TargetValue = watts
+		}
+	}
+	return info, nil
+}
+
+func (vnishDriver) SetPowerTarget(ctx context.Context, ip string, power int) error {
+	presets, err := vnishPresets(ctx, ip)
+	if err != nil {
+		return err
+	}
+	preset, err := pickProfile(presets, power)
+	if err != nil {
+		return err
+	}
+	token, err := vnishUnlock(ctx, ip)
+	if err != nil {
+		return err
+	}
+
+	settings := map[string]interface{}{
+		"miner": map[string]interface{}{
+			"overclock": map[string]interface{}{"preset": preset.Name},
+		},
+	}
+	if err := vnishRequest(ctx, ip, http.MethodPost, "/settings", token, settings, nil); err != nil {
+		return err
+	}
+	// Restarting applies the preset and resumes a stopped miner
+	return vnishRequest(ctx, ip, http.MethodPost, "/mining/restart", token, nil, nil)
+}
+
+func (vnishDriver) SetFreqVolt(ctx context.Context, ip string, freq, volt float64) error {
+	return errors.New("fixed frequency/voltage is not supported on Vnish; use a power target")
+}
+
+func (vnishDriver) SetSleep(ctx context.Context, ip string) error {
+	token, err := vnishUnlock(ctx, ip)
+	if err != nil {
+		return err
+	}
+	return vnishRequest(ctx, ip, http.MethodPost, "/mining/stop", token, nil, nil)
+}
+
+func (vnishDriver) RestartMining(ctx context.Context, ip string) error {
+	token, err := vnishUnlock(ctx, ip)
+	if err != nil {
+		return err
+	}
+	return vnishRequest(ctx, ip, http.MethodPost, "/mining/restart", token, nil, nil)
+}
+
+func (d vnishDriver) Reboot(ctx context.Context, ip string) error {
+	return runDriverCommand(ctx, ip, d, "reboot", nil)
+}
+
+// Pools reads the stock cgminer API, which Vnish keeps running for reads.
+func (vnishDriver) Pools(ctx context.Context, ip string) ([]PoolInfo, error) {
+	return cgminerPools(ctx, ip)
+}
+
+func (vnishDriver) SSHCommands() map[string]sshCommand {
+	return map[string]sshCommand{
+		"reboot": {Command: "reboot", Description: "Reboot the control board", Destructive: true},
+		"logs":   {Command: "tail -n 200 /var/log/messages", Description: "Last 200 lines of the system log"},
+	}
+}
+
+// Identify reads /info and the nominal hashrate from /summary.
+func (vnishDriver) Identify(ctx context.Context, ip string) (*MinerIdentity, error) {
+	var info struct {
+		Miner     string `json:"miner"`
+		Model     string `json:"model"`
+		FWName    string `json:"fw_name"`
+		FWVersion string `json:"fw_version"`
+		System    struct {
+			NetworkStatus struct {
+				MAC string `json:"mac"`
+			} `json:"network_status"`
+		} `json:"system"`
+	}
+	if err := vnishRequest(ctx, ip, http.MethodGet, "/info", "", nil, &info); err != nil {
+		return nil, err
+	}
+	id := &MinerIdentity{
+		Model:           info.Miner,
+		FirmwareVersion: strings.TrimSpace(info.FWName + " " + info.FWVersion),
+		MAC:             info.System.NetworkStatus.MAC,
+	}
+	if id.Model == "" {
+		id.Model = info.Model
+	}
+
+	var summary struct {
+		Miner struct {
+			HRNominal float64 `json:"hr_nominal"` // GH/s
+		} `json:"miner"`
+	}
+	if err := vnishRequest(ctx, ip, http.MethodGet, "/summary", "", nil, &summary); err == nil {
+		id.NominalHashrate = summary.Miner.HRNominal
+	}
+	return id, nil
+}
+
+func (vnishDriver) Capabilities() MinerCapabilities {
+	return MinerCapabilities{SupportsPowerTarget: true, SupportsSleep: true, SupportsReboot: true, SupportsRestart: true, SupportsPools: true}
+}
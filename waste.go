@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"miningRoom/questdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Idle power waste settings: an outlet drawing more than idleWatts while its
+// miner reports no hashrate (hung firmware, or sleeping with the PSU on).
+var (
+	idleWatts      float64
+	idleMinutes    int
+	idleCutMinutes int
+)
+
+// IdlePeriod is a stretch of consecutive idle buckets.
+type IdlePeriod struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	AvgPowerW float64   `json:"avgPowerW"`
+	EnergyKWh float64   `json:"energyKwh"`
+}
+
+// MinerWaste is the idle power wasted by one machine over the analyzed window.
+type MinerWaste struct {
+	Name         string       `json:"name"`
+	IP           string       `json:"ip"`
+	IdleMinutes  int          `json:"idleMinutes"`
+	WastedKWh    float64      `json:"wastedKwh"`
+	WastedEUR    float64      `json:"wastedEur"`
+	MonthlyKWh   float64      `json:"monthlyKwh"` // extrapolated to 30 days
+	MonthlyEUR   float64      `json:"monthlyEur"`
+	Periods      []IdlePeriod `json:"periods"`
+	Maintenance  bool         `json:"maintenance"`
+	HasPowerData bool         `json:"hasPowerData"`
+}
+
+// idlePeriods finds the periods of at least idleMinutes in which a device drew
+// more than idleWatts while its miner reported no hashrate. A bucket without
+// power data ends a period; one without hashrate data counts as no hashrate.
+func idlePeriods(power, hashrate map[string]float64) []IdlePeriod {
+	type bucket struct {
+		start time.Time
+		power float64
+	}
+	var idle []bucket
+	for ts, w := range power {
+		if w <= idleWatts || hashrate[ts] > 0 {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			continue
+		}
+		idle = append(idle, bucket{start: t, power: w})
+	}
+	sort.Slice(idle, func(i, j int) bool { return idle[i].start.Before(idle[j].start) })
+
+	var periods []IdlePeriod
+	flush := func(run []bucket) {
+		if len(run) == 0 || time.Duration(len(run))*questdb.WasteBucket < time.Duration(idleMinutes)*time.Minute {
+			return
+		}
+		total := 0.0
+		for _, b := range run {
+			total += b.power
+		}
+		periods = append(periods, IdlePeriod{
+			Start:     run[0].start,
+			End:       run[len(run)-1].start.Add(questdb.WasteBucket),
+			AvgPowerW: math.Round(total / float64(len(run))),
+			EnergyKWh: total * questdb.WasteBucket.Hours() / 1000,
+		})
+	}
+	start := 0
+	for i := 1; i <= len(idle); i++ {
+		if i == len(idle) || idle[i].start.Sub(idle[i-1].start) != questdb.WasteBucket {
+			flush(idle[start:i])
+			start = i
+		}
+	}
+	return periods
+}
+
+// getWasteHandler reports idle power waste per machine over ?window= (default
+// 30d), worst first, with kWh and cost extrapolated to a month.
+func getWasteHandler(c *gin.Context) {
+	label := c.DefaultQuery("window", "30d")
+	window, err := parseHistoryRange(label)
+	if err != nil || window <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid window"})
+		return
+	}
+	to := time.Now()
+	from := to.Add(-window)
+
+	power, err := questdbFor(c).GetDevicePowerBuckets(from, to)
+	if err != nil {
+		log.Printf("Failed to get device power from QuestDB: %v", err)
+		c.JSON(http.StatusOK, gin.H{"miners": []interface{}{}, "hasData": false})
+		return
+	}
+	hashrate, err := questdbFor(c).GetMinerHashrateBuckets(from, to)
+	if err != nil {
+		log.Printf("Failed to get miner hashrate from QuestDB: %v", err)
+		c.JSON(http.StatusOK, gin.H{"miners": []interface{}{}, "hasData": false})
+		return
+	}
+
+	machines := currentMachines()
+	deviceIDs := outletDeviceIDs(machines)
+	months := window.Hours() / (30 * 24)
+	miners := make([]MinerWaste, 0, len(machines))
+	totalKWh := 0.0
+	for i, m := range machines {
+		w := MinerWaste{
+			Name:        m.Name,
+			IP:          m.IP,
+			Periods:     []IdlePeriod{},
+			Maintenance: m.Maintenance,
+		}
+		devicePower, ok := power[deviceIDs[i]]
+		if deviceIDs[i] != "" && ok {
+			w.HasPowerData = true
+			for _, p := range idlePeriods(devicePower, hashrate[m.IP]) {
+				w.IdleMinutes += int(p.End.Sub(p.Start).Minutes())
+				w.WastedKWh += p.EnergyKWh
+				p.EnergyKWh = math.Round(p.EnergyKWh*100) / 100
+				w.Periods = append(w.Periods, p)
+			}
+		}
+		totalKWh += w.WastedKWh
+		w.WastedEUR = math.Round(w.WastedKWh*elecPrice*100) / 100
+		w.MonthlyKWh = math.Round(w.WastedKWh/months*100) / 100
+		w.MonthlyEUR = math.Round(w.WastedKWh/months*elecPrice*100) / 100
+		w.WastedKWh = math.Round(w.WastedKWh*100) / 100
+		miners = append(miners, w)
+	}
+	sort.SliceStable(miners, func(i, j int) bool { return miners[i].WastedKWh > miners[j].WastedKWh })
+
+	c.JSON(http.StatusOK, gin.H{
+		"miners":      miners,
+		"window":      label,
+		"idleWatts":   idleWatts,
+		"idleMinutes": idleMinutes,
+		"cutMinutes":  idleCutMinutes, // 0: auto-cut disabled
+		"totalKwh":    math.Round(totalKWh*100) / 100,
+		"totalEur":    math.Round(totalKWh*elecPrice*100) / 100,
+		"monthlyKwh":  math.Round(totalKWh/months*100) / 100,
+		"monthlyEur":  math.Round(totalKWh/months*elecPrice*100) / 100,
+		"elecPrice":   elecPrice,
+		"hasData":     len(power) > 0,
+	})
+}
+
+// idleCutter switches off the outlets of miners that stay idle for
+// --idle-cut-minutes.
+type idleCutter struct {
+	mu    sync.Mutex
+	since map[string]time.Time // by miner IP
+}
+
+var idleCut = &idleCutter{since: make(map[string]time.Time)}
+
+// runIdleCutter checks the Shelly watcher's cached outlet states at the given
+// interval.
+func runIdleCutter(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		idleCut.check()
+	}
+}
+
+func (ic *idleCutter) check() {
+	// Without hashrate data every miner would look idle
+	statuses, err := tsStore.GetMinerStatuses()
+	if err != nil {
+		log.Printf("Idle cutter: failed to get miner statuses: %v", err)
+		return
+	}
+	if !statuses.HasData {
+		return
+	}
+	mining := make(map[string]bool)
+	for _, s := range statuses.Miners {
+		if s.Hashrate > 0 && isTimestampRecent(s.Timestamp, 5*time.Minute) {
+			mining[s.MinerIP] = true
+		}
+	}
+
+	limit := time.Duration(idleCutMinutes) * time.Minute
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	seen := make(map[string]bool)
+	for _, s := range shellyWatch.list() {
+		// GPU rigs don't write miner_status; the GPU poller knows their hashrate
+		hashing := mining[s.MinerIP] || gpuRigs.hashing(s.MinerIP, 5*time.Minute)
+		idle := s.Reachable && s.On && s.Power > idleWatts && (!s.MinerOnline || !hashing)
+		if !idle || inMaintenance(s.MinerIP) {
+			continue
+		}
+		seen[s.MinerIP] = true
+		since, ok := ic.since[s.MinerIP]
+		if !ok {
+			ic.since[s.MinerIP] = s.CheckedAt
+			continue
+		}
+		if time.Since(since) < limit {
+			continue
+		}
+		if err := switchMinerRelay(context.Background(), s.MinerIP, false); err != nil {
+			log.Printf("Idle cutter: failed to cut %s: %v", s.Name, err)
+			continue
+		}
+		recordEvent("waste", "%s: cut outlet after %s drawing %.0f W without hashrate (miner %s)",
+			s.Name, time.Since(since).Round(time.Minute), s.Power, presence.describe(s.MinerIP))
+		delete(ic.since, s.MinerIP)
+	}
+	for ip := range ic.since {
+		if !seen[ip] {
+			delete(ic.since, ip)
+		}
+	}
+}